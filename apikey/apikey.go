@@ -0,0 +1,240 @@
+// Package apikey authenticates the machine-to-machine callers with static API keys, the
+// services and integrations which cannot go through the user-centric jwt flow.
+//
+// A key is made of a prefix naming the application, e.g. "wtp", and a random secret. Its
+// plaintext is shown once, when it is created or rotated, the repository only keeps its
+// SHA-256, so a leaked repository does not leak the keys. A Manager creates, revokes and rotates
+// the keys and authenticates the requests with its Middleware.
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Status is the state of an API key.
+type Status string
+
+const (
+	// StatusActive is the status of the keys accepted by the middleware.
+	StatusActive Status = "active"
+	// StatusRevoked is the status of the keys revoked by Manager.Revoke.
+	StatusRevoked Status = "revoked"
+)
+
+// secretSize is the number of random bytes of a key.
+const secretSize = 32
+
+// hintLength is the number of characters of the secret kept in Key.Hint.
+const hintLength = 6
+
+// Key is an API key as stored by the repository, without its plaintext.
+//
+// Fields:
+//   - ID: The ID of the key, to revoke or rotate it.
+//   - Hash: The hex SHA-256 of the plaintext key, see Hash.
+//   - Hint: The prefix and the first characters of the key, to tell the keys apart in a list.
+//   - Owner: The caller the key is issued to, the identity of the requests made with it.
+//   - Scopes: The scopes granted to the key, e.g. "orders:read".
+//   - Status: Whether the key is active or revoked.
+//   - CreatedAt: The time the key was created.
+//   - ExpiresAt: The time the key stops being accepted, never when zero.
+type Key struct {
+	ID        string    `json:"id"`
+	Hash      string    `json:"hash"`
+	Hint      string    `json:"hint"`
+	Owner     string    `json:"owner"`
+	Scopes    []string  `json:"scopes"`
+	Status    Status    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+// Valid reports whether the key is accepted at a time: active and not expired.
+//
+// Parameters:
+//   - now: The current time.
+//
+// Returns:
+//   - Whether the key is valid.
+func (k Key) Valid(now time.Time) bool {
+	return k.Status == StatusActive && (k.ExpiresAt.IsZero() || now.Before(k.ExpiresAt))
+}
+
+// HasScopes reports whether the key is granted every scope.
+//
+// Parameters:
+//   - scopes: The required scopes.
+//
+// Returns:
+//   - The first missing scope, empty when the key has them all.
+func (k Key) HasScopes(scopes ...string) string {
+	for _, scope := range scopes {
+		if !slices.Contains(k.Scopes, scope) {
+			return scope
+		}
+	}
+	return ""
+}
+
+// KeyRepository stores the API keys.
+type KeyRepository interface {
+	// CreateKey stores a new key.
+	CreateKey(ctx context.Context, key Key) error
+
+	// UpdateKey replaces a stored key, matched by its ID, or returns ErrKeyNotFound.
+	UpdateKey(ctx context.Context, key Key) error
+
+	// FindKeyByHash returns the key with a hash, revoked ones included, or ErrInvalidKey.
+	FindKeyByHash(ctx context.Context, hash string) (*Key, error)
+
+	// FindKeyByID returns the key with an ID or ErrKeyNotFound.
+	FindKeyByID(ctx context.Context, id string) (*Key, error)
+}
+
+// Generate creates the plaintext of a new key: the prefix, an underscore and 32 random bytes
+// in base64url, e.g. "wtp_Zk9x...".
+//
+// Parameters:
+//   - prefix: The prefix naming the application, none when empty.
+//
+// Returns:
+//   - The plaintext key.
+//   - An error if the random source fails.
+func Generate(prefix string) (string, error) {
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	key := base64.RawURLEncoding.EncodeToString(secret)
+	if prefix != "" {
+		key = prefix + "_" + key
+	}
+	return key, nil
+}
+
+// Hash returns the hex SHA-256 of a plaintext key, the value stored by the repository. The
+// secret is random, so a salt or a slow hash would not make it harder to guess.
+//
+// Parameters:
+//   - key: The plaintext key.
+//
+// Returns:
+//   - The hash.
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// hint returns the prefix and the first characters of the secret of a plaintext key.
+func hint(key string) string {
+	prefix, secret, found := strings.Cut(key, "_")
+	if !found {
+		prefix, secret = "", key
+	}
+	if len(secret) > hintLength {
+		secret = secret[:hintLength]
+	}
+	if prefix == "" {
+		return secret
+	}
+	return prefix + "_" + secret
+}
+
+// MemoryRepository is a KeyRepository keeping the keys in memory, for the tests. It is safe for
+// concurrent use.
+type MemoryRepository struct {
+	mu   sync.Mutex
+	keys map[string]Key
+}
+
+// Ensure MemoryRepository and PostgresRepository implement the KeyRepository interface.
+var (
+	_ KeyRepository = (*MemoryRepository)(nil)
+	_ KeyRepository = (*PostgresRepository)(nil)
+)
+
+// NewMemoryRepository creates an empty MemoryRepository.
+//
+// Returns:
+//   - The repository.
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{keys: map[string]Key{}}
+}
+
+func (r *MemoryRepository) CreateKey(_ context.Context, key Key) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key.Scopes = slices.Clone(key.Scopes)
+	r.keys[key.ID] = key
+	return nil
+}
+
+func (r *MemoryRepository) UpdateKey(_ context.Context, key Key) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.keys[key.ID]; !ok {
+		return ErrKeyNotFound.Var(key.ID)
+	}
+	key.Scopes = slices.Clone(key.Scopes)
+	r.keys[key.ID] = key
+	return nil
+}
+
+func (r *MemoryRepository) FindKeyByHash(_ context.Context, hash string) (*Key, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, key := range r.keys {
+		if key.Hash == hash {
+			key.Scopes = slices.Clone(key.Scopes)
+			return &key, nil
+		}
+	}
+	return nil, ErrInvalidKey
+}
+
+func (r *MemoryRepository) FindKeyByID(_ context.Context, id string) (*Key, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key, ok := r.keys[id]
+	if !ok {
+		return nil, ErrKeyNotFound.Var(id)
+	}
+	key.Scopes = slices.Clone(key.Scopes)
+	return &key, nil
+}
+
+// keyContextKey is the context key of the API key of the request.
+type keyContextKey struct{}
+
+// WithKey returns a copy of the context carrying the API key the request is authenticated with.
+//
+// Parameters:
+//   - ctx: The parent context.
+//   - key: The API key.
+//
+// Returns:
+//   - A new context containing the key.
+func WithKey(ctx context.Context, key Key) context.Context {
+	return context.WithValue(ctx, keyContextKey{}, key)
+}
+
+// KeyFromContext retrieves the API key set by the middleware, see WithKey.
+//
+// Parameters:
+//   - ctx: The context of the request.
+//
+// Returns:
+//   - The key, the zero value when there is none.
+//   - Whether the context carries a key.
+func KeyFromContext(ctx context.Context) (Key, bool) {
+	key, ok := ctx.Value(keyContextKey{}).(Key)
+	return key, ok
+}
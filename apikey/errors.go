@@ -0,0 +1,35 @@
+package apikey
+
+import (
+	"net/http"
+
+	"github.com/a-aslani/wotop/model/apperror"
+)
+
+const (
+	// ErrMissingKey indicates a request without API key.
+	ErrMissingKey apperror.ErrorType = "ER0981 the API key is missing"
+	// ErrInvalidKey indicates an API key which is not known.
+	ErrInvalidKey apperror.ErrorType = "ER0982 the API key is invalid"
+	// ErrKeyRevoked indicates an API key which was revoked or has expired.
+	ErrKeyRevoked apperror.ErrorType = "ER0983 the API key is revoked"
+	// ErrInsufficientScope indicates an API key without a scope required by the route.
+	ErrInsufficientScope apperror.ErrorType = "ER0984 the API key lacks the scope %s"
+	// ErrKeyNotFound indicates an API key ID which is not in the repository.
+	ErrKeyNotFound apperror.ErrorType = "ER0985 the API key %s is not found"
+)
+
+func init() {
+	apperror.Register("apikey",
+		apperror.Entry{Err: ErrMissingKey, Description: "The request has no API key header."},
+		apperror.Entry{Err: ErrInvalidKey, Description: "The API key of the request is not known."},
+		apperror.Entry{Err: ErrKeyRevoked, Description: "The API key of the request was revoked or has expired, a new key is needed."},
+		apperror.Entry{Err: ErrInsufficientScope, Description: "The API key is valid but not granted the scope the route requires."},
+		apperror.Entry{Err: ErrKeyNotFound, Description: "No API key has the ID."},
+	)
+	apperror.MapCode(ErrMissingKey.Code(), http.StatusUnauthorized)
+	apperror.MapCode(ErrInvalidKey.Code(), http.StatusUnauthorized)
+	apperror.MapCode(ErrKeyRevoked.Code(), http.StatusUnauthorized)
+	apperror.MapCode(ErrInsufficientScope.Code(), http.StatusForbidden)
+	apperror.MapCode(ErrKeyNotFound.Code(), http.StatusNotFound)
+}
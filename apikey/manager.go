@@ -0,0 +1,365 @@
+package apikey
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/logger"
+	"github.com/a-aslani/wotop/model/apperror"
+	"github.com/a-aslani/wotop/model/payload"
+	"github.com/a-aslani/wotop/util"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultHeader is the header carrying the API key.
+	DefaultHeader = "X-Api-Key"
+	// DefaultCacheTTL is the time a key read from the repository is cached by the Manager.
+	DefaultCacheTTL = 30 * time.Second
+)
+
+// options holds the settings of a Manager.
+type options struct {
+	prefix   string
+	header   string
+	cacheTTL time.Duration
+	clock    util.Clock
+	log      logger.Logger
+}
+
+// Option configures a Manager.
+type Option func(*options)
+
+// WithPrefix sets the prefix of the keys created by the Manager, e.g. "wtp", so a leaked key
+// is recognized by the secret scanners.
+//
+// Parameters:
+//   - prefix: The prefix, none by default.
+//
+// Returns:
+//   - The option setting the prefix.
+func WithPrefix(prefix string) Option {
+	return func(o *options) {
+		o.prefix = prefix
+	}
+}
+
+// WithHeader sets the header the middleware reads the key from.
+//
+// Parameters:
+//   - header: The header, DefaultHeader by default.
+//
+// Returns:
+//   - The option setting the header.
+func WithHeader(header string) Option {
+	return func(o *options) {
+		if header != "" {
+			o.header = header
+		}
+	}
+}
+
+// WithCacheTTL sets the time a key read from the repository is cached. A key revoked by another
+// replica is accepted by this one until its cached copy expires.
+//
+// Parameters:
+//   - ttl: The time, DefaultCacheTTL by default, no caching when zero.
+//
+// Returns:
+//   - The option setting the TTL.
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.cacheTTL = max(ttl, 0)
+	}
+}
+
+// WithClock sets the clock of the expiry of the keys and of the cache.
+//
+// Parameters:
+//   - clock: The clock, util.SystemClock by default.
+//
+// Returns:
+//   - The option setting the clock.
+func WithClock(clock util.Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// WithLogger sets the logger of the repository failures met by the middleware.
+//
+// Parameters:
+//   - log: The logger, none by default.
+//
+// Returns:
+//   - The option setting the logger.
+func WithLogger(log logger.Logger) Option {
+	return func(o *options) {
+		o.log = log
+	}
+}
+
+// Manager creates, revokes and rotates the API keys of a repository and authenticates the
+// requests made with them. It is safe for concurrent use.
+type Manager struct {
+	repo  KeyRepository
+	o     options
+	mu    sync.Mutex
+	cache map[string]cachedKey // by hash
+}
+
+// cachedKey is a key cached by a Manager.
+type cachedKey struct {
+	key       Key
+	expiresAt time.Time
+}
+
+// NewManager creates a Manager.
+//
+// Parameters:
+//   - repo: The repository of the keys, e.g. a PostgresRepository.
+//   - opts: The prefix of the keys, the header, the cache TTL, the clock and the logger.
+//
+// Returns:
+//   - The manager.
+func NewManager(repo KeyRepository, opts ...Option) *Manager {
+	o := options{header: DefaultHeader, cacheTTL: DefaultCacheTTL, clock: util.SystemClock}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Manager{repo: repo, o: o, cache: map[string]cachedKey{}}
+}
+
+// Create creates an active key.
+//
+// Parameters:
+//   - ctx: The context of the operation.
+//   - owner: The caller the key is issued to.
+//   - scopes: The scopes granted to the key.
+//   - ttl: The lifetime of the key, none when zero.
+//
+// Returns:
+//   - The plaintext key, to hand to the caller, it cannot be read again.
+//   - The stored key.
+//   - An error if the key cannot be generated or stored.
+func (m *Manager) Create(ctx context.Context, owner string, scopes []string, ttl time.Duration) (string, *Key, error) {
+
+	plaintext, err := Generate(m.o.prefix)
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := m.o.clock.Now()
+	key := Key{
+		ID:        uuid.NewString(),
+		Hash:      Hash(plaintext),
+		Hint:      hint(plaintext),
+		Owner:     owner,
+		Scopes:    scopes,
+		Status:    StatusActive,
+		CreatedAt: now,
+	}
+	if ttl > 0 {
+		key.ExpiresAt = now.Add(ttl)
+	}
+
+	if err = m.repo.CreateKey(ctx, key); err != nil {
+		return "", nil, err
+	}
+	return plaintext, &key, nil
+}
+
+// Revoke revokes a key. The other replicas accept it until their cached copy expires.
+//
+// Parameters:
+//   - ctx: The context of the operation.
+//   - id: The ID of the key.
+//
+// Returns:
+//   - ErrKeyNotFound if there is no key with the ID, or an error if it cannot be stored.
+func (m *Manager) Revoke(ctx context.Context, id string) error {
+
+	key, err := m.repo.FindKeyByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	key.Status = StatusRevoked
+	if err = m.repo.UpdateKey(ctx, *key); err != nil {
+		return err
+	}
+	m.forget(key.Hash)
+	return nil
+}
+
+// Rotate replaces a key by a new one with the same owner, scopes and lifetime. The old key
+// keeps working for a grace period, so the callers can switch to the new one without failed
+// requests, and is revoked at once without one.
+//
+// Parameters:
+//   - ctx: The context of the operation.
+//   - id: The ID of the key.
+//   - grace: The time the old key is still accepted, none when zero.
+//
+// Returns:
+//   - The plaintext of the new key.
+//   - The new stored key.
+//   - ErrKeyNotFound if there is no key with the ID, ErrKeyRevoked if it is not valid anymore,
+//     or an error if the keys cannot be stored.
+func (m *Manager) Rotate(ctx context.Context, id string, grace time.Duration) (string, *Key, error) {
+
+	old, err := m.repo.FindKeyByID(ctx, id)
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := m.o.clock.Now()
+	if !old.Valid(now) {
+		return "", nil, ErrKeyRevoked
+	}
+
+	var ttl time.Duration
+	if !old.ExpiresAt.IsZero() {
+		ttl = old.ExpiresAt.Sub(old.CreatedAt)
+	}
+	plaintext, key, err := m.Create(ctx, old.Owner, old.Scopes, ttl)
+	if err != nil {
+		return "", nil, err
+	}
+
+	switch {
+	case grace <= 0:
+		old.Status = StatusRevoked
+	case old.ExpiresAt.IsZero() || now.Add(grace).Before(old.ExpiresAt):
+		old.ExpiresAt = now.Add(grace)
+	}
+	if err = m.repo.UpdateKey(ctx, *old); err != nil {
+		return "", nil, err
+	}
+	m.forget(old.Hash)
+
+	return plaintext, key, nil
+}
+
+// Authenticate returns the valid key matching a plaintext key, read from the cache or else from
+// the repository.
+//
+// Parameters:
+//   - ctx: The context of the request.
+//   - plaintext: The key sent by the caller.
+//
+// Returns:
+//   - The key.
+//   - ErrMissingKey, ErrInvalidKey or ErrKeyRevoked if the key is not accepted, or an error if the
+//     repository fails.
+func (m *Manager) Authenticate(ctx context.Context, plaintext string) (*Key, error) {
+
+	if plaintext == "" {
+		return nil, ErrMissingKey
+	}
+
+	hash := Hash(plaintext)
+	now := m.o.clock.Now()
+
+	key, ok := m.cached(hash, now)
+	if !ok {
+		found, err := m.repo.FindKeyByHash(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+		key = *found
+		m.remember(key, now)
+	}
+
+	// the repository may match the hashes in a way that leaks their timing, e.g. by index
+	if subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hash)) != 1 {
+		return nil, ErrInvalidKey
+	}
+	if !key.Valid(now) {
+		return nil, ErrKeyRevoked
+	}
+	return &key, nil
+}
+
+// Middleware returns a Gin middleware authenticating the requests with the key of the header,
+// see WithHeader, and requiring scopes of it. The key is set in the request context, see
+// KeyFromContext, and its owner as the identity of the caller, see wotop.IdentityFromContext.
+// The requests without key, with an unknown or a revoked key are aborted with 401, the ones
+// whose key lacks a scope with 403.
+//
+// The unknown keys are not cached, each of them reads the repository, so the routes should be
+// rate limited too.
+//
+// Parameters:
+//   - scopes: The scopes the key must have, e.g. "orders:read".
+//
+// Returns:
+//   - A gin.HandlerFunc to register with gin.Engine.Use or on the routes.
+func (m *Manager) Middleware(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		ctx := c.Request.Context()
+		traceID := logger.GetTraceID(ctx)
+
+		key, err := m.Authenticate(ctx, c.GetHeader(m.o.header))
+		if err != nil {
+			var appErr apperror.ErrorType
+			if !errors.As(err, &appErr) && m.o.log != nil {
+				m.o.log.Error(ctx, "apikey: cannot read the key: %v", err)
+			}
+			payload.WriteError(c, err, traceID)
+			c.Abort()
+			return
+		}
+
+		if missing := key.HasScopes(scopes...); missing != "" {
+			payload.WriteError(c, ErrInsufficientScope.Var(missing), traceID)
+			c.Abort()
+			return
+		}
+
+		ctx = wotop.WithIdentity(WithKey(ctx, *key), wotop.Identity{ID: key.Owner})
+		c.Request = c.Request.WithContext(ctx)
+		c.Set("ID", key.Owner)
+
+		c.Next()
+	}
+}
+
+// cached returns the cached copy of a key unless it has expired.
+func (m *Manager) cached(hash string, now time.Time) (Key, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.cache[hash]
+	if !ok {
+		return Key{}, false
+	}
+	if !now.Before(entry.expiresAt) {
+		delete(m.cache, hash)
+		return Key{}, false
+	}
+	return entry.key, true
+}
+
+// remember caches a key read from the repository.
+func (m *Manager) remember(key Key, now time.Time) {
+	if m.o.cacheTTL <= 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cache[key.Hash] = cachedKey{key: key, expiresAt: now.Add(m.o.cacheTTL)}
+}
+
+// forget drops the cached copy of a key.
+func (m *Manager) forget(hash string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.cache, hash)
+}
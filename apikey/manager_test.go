@@ -0,0 +1,201 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/model/apperror"
+	"github.com/a-aslani/wotop/util"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var t0 = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// countingRepository counts the lookups of the keys of a MemoryRepository.
+type countingRepository struct {
+	*MemoryRepository
+	lookups int
+}
+
+func (r *countingRepository) FindKeyByHash(ctx context.Context, hash string) (*Key, error) {
+	r.lookups++
+	return r.MemoryRepository.FindKeyByHash(ctx, hash)
+}
+
+func newTestManager(opts ...Option) (*Manager, *countingRepository, *util.FrozenClock) {
+	clock := util.NewFrozenClock(t0)
+	repo := &countingRepository{MemoryRepository: NewMemoryRepository()}
+	return NewManager(repo, append([]Option{WithPrefix("wtp"), WithClock(clock)}, opts...)...), repo, clock
+}
+
+// serve sends a request with a key to a route requiring scopes and returns the response and
+// the identity seen by the handler.
+func serve(m *Manager, key string, scopes ...string) (*httptest.ResponseRecorder, wotop.Identity) {
+	gin.SetMode(gin.TestMode)
+	var identity wotop.Identity
+	r := gin.New()
+	r.GET("/orders", m.Middleware(scopes...), func(c *gin.Context) {
+		identity, _ = wotop.IdentityFromContext(c.Request.Context())
+		c.Status(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	if key != "" {
+		req.Header.Set(DefaultHeader, key)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w, identity
+}
+
+func TestMiddleware(t *testing.T) {
+	m, _, _ := newTestManager()
+	plaintext, key, err := m.Create(context.Background(), "billing-service", []string{"orders:read"}, 0)
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(plaintext, "wtp_"))
+	assert.Equal(t, Hash(plaintext), key.Hash)
+	assert.Equal(t, plaintext[:10], key.Hint)
+
+	w, identity := serve(m, plaintext, "orders:read")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, wotop.Identity{ID: "billing-service"}, identity)
+
+	tests := map[string]struct {
+		key    string
+		scopes []string
+		status int
+		code   string
+	}{
+		"missing key":   {status: http.StatusUnauthorized, code: "ER0981"},
+		"unknown key":   {key: plaintext + "x", status: http.StatusUnauthorized, code: "ER0982"},
+		"missing scope": {key: plaintext, scopes: []string{"orders:read", "orders:write"}, status: http.StatusForbidden, code: "ER0984"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			w, _ := serve(m, tt.key, tt.scopes...)
+			assert.Equal(t, tt.status, w.Code)
+			assert.Contains(t, w.Body.String(), tt.code)
+		})
+	}
+}
+
+func TestRevokedAndExpiredKeys(t *testing.T) {
+	ctx := context.Background()
+	m, _, clock := newTestManager()
+
+	revoked, key, err := m.Create(ctx, "billing-service", nil, 0)
+	require.NoError(t, err)
+	require.NoError(t, m.Revoke(ctx, key.ID))
+
+	w, _ := serve(m, revoked)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "ER0983")
+
+	expiring, _, err := m.Create(ctx, "billing-service", nil, time.Hour)
+	require.NoError(t, err)
+	w, _ = serve(m, expiring)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	clock.Advance(time.Hour)
+	w, _ = serve(m, expiring)
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "the cached key expires too")
+
+	assertCode(t, m.Revoke(ctx, "unknown"), "ER0985")
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	m, repo, clock := newTestManager(WithCacheTTL(time.Minute))
+	plaintext, key, err := m.Create(ctx, "billing-service", nil, 0)
+	require.NoError(t, err)
+
+	for range 3 {
+		_, err = m.Authenticate(ctx, plaintext)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, repo.lookups, "the key is cached")
+
+	clock.Advance(time.Minute)
+	_, err = m.Authenticate(ctx, plaintext)
+	require.NoError(t, err)
+	assert.Equal(t, 2, repo.lookups, "the cached key expires")
+
+	// a key revoked by another replica is accepted until its cached copy expires
+	other := NewManager(repo, WithClock(clock))
+	require.NoError(t, other.Revoke(ctx, key.ID))
+	_, err = m.Authenticate(ctx, plaintext)
+	assert.NoError(t, err)
+
+	clock.Advance(time.Minute)
+	_, err = m.Authenticate(ctx, plaintext)
+	assert.ErrorIs(t, err, ErrKeyRevoked)
+
+	// the unknown keys are not cached
+	for range 2 {
+		_, err = m.Authenticate(ctx, "wtp_unknown")
+		assert.ErrorIs(t, err, ErrInvalidKey)
+	}
+	assert.Equal(t, 5, repo.lookups)
+}
+
+func TestRevokeDropsCachedKey(t *testing.T) {
+	ctx := context.Background()
+	m, _, _ := newTestManager()
+	plaintext, key, err := m.Create(ctx, "billing-service", nil, 0)
+	require.NoError(t, err)
+
+	_, err = m.Authenticate(ctx, plaintext)
+	require.NoError(t, err)
+	require.NoError(t, m.Revoke(ctx, key.ID))
+
+	_, err = m.Authenticate(ctx, plaintext)
+	assert.ErrorIs(t, err, ErrKeyRevoked)
+}
+
+func TestRotate(t *testing.T) {
+	ctx := context.Background()
+	m, _, clock := newTestManager()
+	old, key, err := m.Create(ctx, "billing-service", []string{"orders:read"}, 24*time.Hour)
+	require.NoError(t, err)
+
+	clock.Advance(time.Hour)
+	rotated, newKey, err := m.Rotate(ctx, key.ID, 10*time.Minute)
+	require.NoError(t, err)
+	assert.NotEqual(t, old, rotated)
+	assert.Equal(t, "billing-service", newKey.Owner)
+	assert.Equal(t, []string{"orders:read"}, newKey.Scopes)
+	assert.Equal(t, clock.Now().Add(24*time.Hour), newKey.ExpiresAt, "the new key has the lifetime of the old one")
+
+	_, err = m.Authenticate(ctx, old)
+	assert.NoError(t, err, "the old key works during the grace period")
+	clock.Advance(10 * time.Minute)
+	_, err = m.Authenticate(ctx, old)
+	assert.ErrorIs(t, err, ErrKeyRevoked)
+	_, err = m.Authenticate(ctx, rotated)
+	assert.NoError(t, err)
+
+	// without grace period, the old key is revoked at once
+	_, _, err = m.Rotate(ctx, newKey.ID, 0)
+	require.NoError(t, err)
+	_, err = m.Authenticate(ctx, rotated)
+	assert.ErrorIs(t, err, ErrKeyRevoked)
+
+	_, _, err = m.Rotate(ctx, newKey.ID, 0)
+	assert.ErrorIs(t, err, ErrKeyRevoked, "a revoked key cannot be rotated")
+}
+
+func assertCode(t *testing.T, err error, code string) {
+	t.Helper()
+	var et apperror.ErrorType
+	require.True(t, errors.As(err, &et), "unexpected error %v", err)
+	assert.Equal(t, code, et.Code())
+}
@@ -0,0 +1,131 @@
+package apikey
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// DefaultKeysTable is the table of PostgresRepository when it is given none.
+const DefaultKeysTable = "api_keys"
+
+// PostgresRepository is a KeyRepository keeping the keys in a Postgres table created by Schema,
+// looked up by the unique index of their hash.
+type PostgresRepository struct {
+	db    *sql.DB
+	table string
+}
+
+// NewPostgresRepository creates a PostgresRepository.
+//
+// Parameters:
+//   - db: The connection pool.
+//   - table: The table of the keys, DefaultKeysTable when empty.
+//
+// Returns:
+//   - The repository.
+func NewPostgresRepository(db *sql.DB, table string) *PostgresRepository {
+	if table == "" {
+		table = DefaultKeysTable
+	}
+	return &PostgresRepository{db: db, table: table}
+}
+
+// Schema returns the statement creating the table of the keys, e.g. for a migration.
+//
+// Parameters:
+//   - table: The table of the keys, DefaultKeysTable when empty.
+//
+// Returns:
+//   - The CREATE TABLE statement.
+func Schema(table string) string {
+	if table == "" {
+		table = DefaultKeysTable
+	}
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id TEXT PRIMARY KEY,
+	hash TEXT NOT NULL UNIQUE,
+	hint TEXT NOT NULL,
+	owner TEXT NOT NULL,
+	scopes TEXT[] NOT NULL,
+	status TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	expires_at TIMESTAMPTZ
+)`, pq.QuoteIdentifier(table))
+}
+
+// CreateSchema creates the table of the keys unless it exists.
+func (r *PostgresRepository) CreateSchema(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, Schema(r.table))
+	return err
+}
+
+func (r *PostgresRepository) CreateKey(ctx context.Context, key Key) error {
+	_, err := r.db.ExecContext(ctx, fmt.Sprintf(
+		"INSERT INTO %s (id, hash, hint, owner, scopes, status, created_at, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+		pq.QuoteIdentifier(r.table)),
+		key.ID, key.Hash, key.Hint, key.Owner, pq.Array(key.Scopes), string(key.Status), key.CreatedAt, nullTime(key.ExpiresAt))
+	return err
+}
+
+func (r *PostgresRepository) UpdateKey(ctx context.Context, key Key) error {
+	res, err := r.db.ExecContext(ctx, fmt.Sprintf(
+		"UPDATE %s SET owner = $2, scopes = $3, status = $4, expires_at = $5 WHERE id = $1",
+		pq.QuoteIdentifier(r.table)),
+		key.ID, key.Owner, pq.Array(key.Scopes), string(key.Status), nullTime(key.ExpiresAt))
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrKeyNotFound.Var(key.ID)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) FindKeyByHash(ctx context.Context, hash string) (*Key, error) {
+	key, err := r.find(ctx, "hash", hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidKey
+	}
+	return key, err
+}
+
+func (r *PostgresRepository) FindKeyByID(ctx context.Context, id string) (*Key, error) {
+	key, err := r.find(ctx, "id", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrKeyNotFound.Var(id)
+	}
+	return key, err
+}
+
+// find reads the key whose column has a value.
+func (r *PostgresRepository) find(ctx context.Context, column, value string) (*Key, error) {
+	var (
+		key       Key
+		status    string
+		expiresAt sql.NullTime
+	)
+	err := r.db.QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT id, hash, hint, owner, scopes, status, created_at, expires_at FROM %s WHERE %s = $1",
+		pq.QuoteIdentifier(r.table), column), value).
+		Scan(&key.ID, &key.Hash, &key.Hint, &key.Owner, pq.Array(&key.Scopes), &status, &key.CreatedAt, &expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	key.Status = Status(status)
+	key.ExpiresAt = expiresAt.Time
+	return &key, nil
+}
+
+// nullTime returns NULL for the zero time.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
@@ -0,0 +1,64 @@
+package apikey
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresRepository(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	repo := NewPostgresRepository(db, "")
+	key := Key{ID: "key-1", Hash: Hash("wtp_secret"), Hint: "wtp_secret", Owner: "billing-service", Scopes: []string{"orders:read"}, Status: StatusActive, CreatedAt: t0}
+	columns := []string{"id", "hash", "hint", "owner", "scopes", "status", "created_at", "expires_at"}
+
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "api_keys" (id, hash, hint, owner, scopes, status, created_at, expires_at)`)).
+		WithArgs("key-1", key.Hash, "wtp_secret", "billing-service", pq.Array(key.Scopes), "active", t0, nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, hash, hint, owner, scopes, status, created_at, expires_at FROM "api_keys" WHERE hash = $1`)).
+		WithArgs(key.Hash).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("key-1", key.Hash, "wtp_secret", "billing-service", "{orders:read}", "active", t0, nil))
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM "api_keys" WHERE hash = $1`)).
+		WithArgs("unknown").
+		WillReturnRows(sqlmock.NewRows(columns))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "api_keys" SET owner = $2, scopes = $3, status = $4, expires_at = $5 WHERE id = $1`)).
+		WithArgs("key-1", "billing-service", pq.Array(key.Scopes), "revoked", t0.Add(time.Hour)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "api_keys"`)).
+		WithArgs("key-2", "", pq.Array([]string(nil)), "", nil).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM "api_keys" WHERE id = $1`)).
+		WithArgs("key-1").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("key-1", key.Hash, "wtp_secret", "billing-service", "{orders:read}", "revoked", t0, t0.Add(time.Hour)))
+
+	require.NoError(t, repo.CreateKey(ctx, key))
+
+	found, err := repo.FindKeyByHash(ctx, key.Hash)
+	require.NoError(t, err)
+	assert.Equal(t, key, *found)
+
+	_, err = repo.FindKeyByHash(ctx, "unknown")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	revoked := key
+	revoked.Status, revoked.ExpiresAt = StatusRevoked, t0.Add(time.Hour)
+	require.NoError(t, repo.UpdateKey(ctx, revoked))
+	assertCode(t, repo.UpdateKey(ctx, Key{ID: "key-2"}), "ER0985")
+
+	found, err = repo.FindKeyByID(ctx, "key-1")
+	require.NoError(t, err)
+	assert.Equal(t, revoked, *found)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Contains(t, Schema(""), `hash TEXT NOT NULL UNIQUE`)
+}
@@ -1,10 +1,19 @@
 package wotop
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/a-aslani/wotop/util"
+	"os/signal"
+	"slices"
+	"syscall"
 	"time"
 )
 
+// AllApps is the name selecting every runner of RunAll, e.g. `go run main.go all`.
+const AllApps = "all"
+
 // Runner defines a generic interface for running a task with a given configuration.
 //
 // Type Parameters:
@@ -20,16 +29,184 @@ type Runner[T any] interface {
 	Run(cfg *T) error
 }
 
+// LifecycleRunner is a Runner whose startup is split in hooks, run by RunApp in order:
+// OnStarting, before anything accepts traffic, then Run, which passes the runner to
+// Lifecycle.WithHooks so OnReady is called once the controllers are started and OnStopping
+// before they are stopped.
+//
+// Type Parameters:
+//   - T: The type of the configuration object.
+type LifecycleRunner[T any] interface {
+	Runner[T]
+	LifecycleHooks
+
+	// OnStarting prepares the application, e.g. runs the migrations or warms the caches.
+	//
+	// Parameters:
+	//   - ctx: A context canceled on SIGINT or SIGTERM.
+	//   - cfg: A pointer to the configuration object of type T.
+	//
+	// Returns:
+	//   - An error aborting the startup, Run is not called.
+	OnStarting(ctx context.Context, cfg *T) error
+}
+
+// ContextRunner is a Runner run with a context, which RunAll cancels when another runner
+// returns. Run should pass the context to Lifecycle.Run, so the runner stops with the others.
+//
+// Type Parameters:
+//   - T: The type of the configuration object.
+type ContextRunner[T any] interface {
+	Runner[T]
+
+	// RunContext executes the task until it is done or the context is canceled.
+	//
+	// Parameters:
+	//   - ctx: The context of the task, never canceled by RunApp.
+	//   - cfg: A pointer to the configuration object of type T.
+	//
+	// Returns:
+	//   - An error if the task execution fails, otherwise nil.
+	RunContext(ctx context.Context, cfg *T) error
+}
+
+// runAllStopTimeout is the time RunAll waits for the other runners once one of them returned.
+var runAllStopTimeout = DefaultShutdownTimeout
+
+// RunApp runs a Runner, it is meant to be called by main. The OnStarting hook of a
+// LifecycleRunner is called first, with a context canceled on SIGINT or SIGTERM, and the
+// application is not run when it fails or a signal is received meanwhile. Run then handles
+// the signals itself, with a Lifecycle.
+//
+// Parameters:
+//   - cfg: A pointer to the configuration object of type T.
+//   - runner: The runner of the application.
+//
+// Returns:
+//   - The error of OnStarting or of Run.
+func RunApp[T any](cfg *T, runner Runner[T]) error {
+	return runApp(context.Background(), cfg, runner)
+}
+
+// runApp runs a Runner with RunContext when it is a ContextRunner, with Run otherwise.
+func runApp[T any](ctx context.Context, cfg *T, runner Runner[T]) error {
+	if hooked, ok := runner.(LifecycleRunner[T]); ok {
+		if err := starting(ctx, cfg, hooked); err != nil {
+			return err
+		}
+	}
+	if r, ok := runner.(ContextRunner[T]); ok {
+		return r.RunContext(ctx, cfg)
+	}
+	return runner.Run(cfg)
+}
+
+// RunAll runs several runners in one process, each with RunApp in its own goroutine, e.g. the
+// HTTP application, the consumer and the scheduler for local development. The runners share
+// the termination signals, and the first one to return stops the others: the context of the
+// ContextRunners is canceled, so the Lifecycles they run are stopped as on SIGTERM. The runners
+// still running after DefaultShutdownTimeout, e.g. the Runners without a context, are
+// abandoned, so a fatal error of one of them shuts the process down.
+//
+// Type Parameters:
+//   - T: The type of the configuration object.
+//
+// Parameters:
+//   - cfg: A pointer to the configuration object of type T, shared by the runners.
+//   - runners: The runners by name, e.g. the appMap of main.
+//   - names: The names of the runners to run, AllApps or none for all of them.
+//
+// Returns:
+//   - The first error returned by a runner, prefixed by its name and joined with the errors of
+//     the others and of the abandoned runners, or an error if a name is unknown.
+func RunAll[T any](cfg *T, runners map[string]Runner[T], names ...string) error {
+
+	if len(names) == 0 || slices.Contains(names, AllApps) {
+		names = make([]string, 0, len(runners))
+		for name := range runners {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+	}
+
+	for _, name := range names {
+		if _, ok := runners[name]; !ok {
+			return fmt.Errorf("wotop: unknown application %q", name)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type result struct {
+		name string
+		err  error
+	}
+	// buffered, so an abandoned runner does not leak its goroutine forever
+	results := make(chan result, len(names))
+	for _, name := range names {
+		go func() {
+			results <- result{name: name, err: runApp(ctx, cfg, runners[name])}
+		}()
+	}
+
+	running := make(map[string]bool, len(names))
+	for _, name := range names {
+		running[name] = true
+	}
+
+	var errs []error
+	var timeout <-chan time.Time
+	for len(running) > 0 {
+		select {
+		case r := <-results:
+			delete(running, r.name)
+			if r.err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", r.name, r.err))
+			}
+			if timeout == nil {
+				cancel() // the first runner to return stops the others
+				timeout = time.After(runAllStopTimeout)
+			}
+		case <-timeout:
+			for _, name := range names {
+				if running[name] {
+					errs = append(errs, fmt.Errorf("%s: not stopped within %s", name, runAllStopTimeout))
+				}
+			}
+			return errors.Join(errs...)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// starting calls the OnStarting hook of a runner with a context canceled on SIGINT or SIGTERM.
+func starting[T any](ctx context.Context, cfg *T, runner LifecycleRunner[T]) error {
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := runner.OnStarting(ctx, cfg); err != nil {
+		return fmt.Errorf("starting: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("starting: interrupted: %w", err)
+	}
+	return nil
+}
+
 // ApplicationData represents metadata about the application instance.
 //
 // Fields:
 //   - AppName: The name of the application.
 //   - AppInstanceID: A unique identifier for the application instance.
 //   - StartTime: The start time of the application instance in the format "YYYY-MM-DD HH:MM:SS".
+//   - Build: The build of the application, see GetBuildInfo.
 type ApplicationData struct {
-	AppName       string `json:"app_name"`        // The name of the application.
-	AppInstanceID string `json:"app_instance_id"` // A unique identifier for the application instance.
-	StartTime     string `json:"start_time"`      // The start time of the application instance.
+	AppName       string    `json:"app_name"`        // The name of the application.
+	AppInstanceID string    `json:"app_instance_id"` // A unique identifier for the application instance.
+	StartTime     string    `json:"start_time"`      // The start time of the application instance.
+	Build         BuildInfo `json:"build,omitzero"`  // The version, commit and build time of the application.
 }
 
 // NewApplicationData creates a new ApplicationData instance with the given application name.
@@ -38,11 +215,12 @@ type ApplicationData struct {
 //   - appName: The name of the application.
 //
 // Returns:
-//   - An ApplicationData instance populated with the application name, a generated instance ID, and the current start time.
+//   - An ApplicationData instance populated with the application name, a generated instance ID, the current start time and the build.
 func NewApplicationData(appName string) ApplicationData {
 	return ApplicationData{
 		AppName:       appName,
 		AppInstanceID: util.GenerateID(4),                       // Generate a unique 4-character ID for the application instance.
 		StartTime:     time.Now().Format("2006-01-02 15:04:05"), // Set the current time as the start time.
+		Build:         GetBuildInfo(),
 	}
 }
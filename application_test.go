@@ -0,0 +1,268 @@
+package wotop
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type scriptedConfig struct {
+	Name string
+}
+
+// scriptedRunner is a LifecycleRunner recording its hooks, a hook fails with its error.
+type scriptedRunner struct {
+	events *events
+	errs   map[string]error
+	cancel context.CancelFunc
+}
+
+func (r *scriptedRunner) fail(hook string) error {
+	r.events.add(hook)
+	return r.errs[hook]
+}
+
+func (r *scriptedRunner) OnStarting(_ context.Context, cfg *scriptedConfig) error {
+	return r.fail("starting " + cfg.Name)
+}
+
+// OnReady stops the application at once, unless it must fail.
+func (r *scriptedRunner) OnReady(context.Context) error {
+	if err := r.fail("ready"); err != nil {
+		return err
+	}
+	if r.errs["serve"] == nil {
+		r.cancel()
+	}
+	return nil
+}
+
+func (r *scriptedRunner) OnStopping(context.Context) error {
+	return r.fail("stopping")
+}
+
+// Run serves until the server fails with the error of "serve", or OnReady stops it.
+func (r *scriptedRunner) Run(*scriptedConfig) error {
+	r.events.add("run")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r.cancel = cancel
+
+	return NewLifecycle().
+		WithHooks(r).
+		RegisterFunc("server", func(ctx context.Context) error {
+			r.events.add("start server")
+			if err := r.errs["serve"]; err != nil {
+				return err
+			}
+			<-ctx.Done()
+			return nil
+		}, func(context.Context) error {
+			r.events.add("stop server")
+			return nil
+		}).
+		Run(ctx)
+}
+
+func TestRunAppHookOrder(t *testing.T) {
+	ev := &events{}
+	runner := &scriptedRunner{events: ev}
+
+	require.NoError(t, RunApp(&scriptedConfig{Name: "shop"}, Runner[scriptedConfig](runner)))
+
+	assert.Equal(t, []string{"starting shop", "run", "ready", "stopping", "stop server"}, hooks(ev))
+}
+
+// hooks returns the events but the start of the server, which runs concurrently with the hooks.
+func hooks(ev *events) []string {
+	var got []string
+	for _, e := range ev.get() {
+		if e != "start server" {
+			got = append(got, e)
+		}
+	}
+	return got
+}
+
+func TestRunAppAbortsOnStartingError(t *testing.T) {
+	ev := &events{}
+	fail := errors.New("migration failed")
+	runner := &scriptedRunner{events: ev, errs: map[string]error{"starting shop": fail}}
+
+	err := RunApp(&scriptedConfig{Name: "shop"}, Runner[scriptedConfig](runner))
+
+	assert.ErrorIs(t, err, fail)
+	assert.EqualError(t, err, "starting: migration failed")
+	assert.Equal(t, []string{"starting shop"}, ev.get(), "the application is not run")
+}
+
+func TestRunAppStopsOnReadyError(t *testing.T) {
+	ev := &events{}
+	fail := errors.New("health check not registered")
+	runner := &scriptedRunner{events: ev, errs: map[string]error{"ready": fail}}
+
+	err := RunApp(&scriptedConfig{Name: "shop"}, Runner[scriptedConfig](runner))
+
+	assert.ErrorIs(t, err, fail)
+	assert.ErrorContains(t, err, "ready: health check not registered")
+	assert.Equal(t, []string{"starting shop", "run", "ready", "stopping", "stop server"}, hooks(ev), "the started components are stopped")
+}
+
+func TestRunAppJoinsStoppingError(t *testing.T) {
+	ev := &events{}
+	serveErr := errors.New("address in use")
+	stopErr := errors.New("cannot flush")
+	runner := &scriptedRunner{events: ev, errs: map[string]error{"serve": serveErr, "stopping": stopErr}}
+
+	err := RunApp(&scriptedConfig{Name: "shop"}, Runner[scriptedConfig](runner))
+
+	assert.ErrorIs(t, err, serveErr)
+	assert.ErrorIs(t, err, stopErr)
+	assert.ErrorContains(t, err, "stopping: cannot flush")
+	assert.Equal(t, []string{"starting shop", "run", "ready", "stopping", "stop server"}, hooks(ev), "the components are stopped anyway")
+}
+
+// plainRunner is a Runner without hooks.
+type plainRunner struct {
+	ran bool
+}
+
+func (r *plainRunner) Run(*scriptedConfig) error {
+	r.ran = true
+	return nil
+}
+
+func TestRunAppPlainRunner(t *testing.T) {
+	runner := &plainRunner{}
+	require.NoError(t, RunApp(&scriptedConfig{}, Runner[scriptedConfig](runner)))
+	assert.True(t, runner.ran)
+}
+
+// groupRunner is a ContextRunner of RunAll, it waits for the other runners to be started and
+// then serves until its Lifecycle is stopped, or fails with err.
+type groupRunner struct {
+	name    string
+	events  *events
+	started chan<- string
+	all     <-chan struct{}
+	err     error
+}
+
+func (r *groupRunner) Run(cfg *scriptedConfig) error {
+	return r.RunContext(context.Background(), cfg)
+}
+
+func (r *groupRunner) RunContext(ctx context.Context, _ *scriptedConfig) error {
+	r.started <- r.name
+	select {
+	case <-r.all:
+	case <-time.After(5 * time.Second):
+		return errors.New("the other runners were not started")
+	}
+
+	if r.err != nil {
+		return r.err
+	}
+
+	return NewLifecycle().
+		RegisterFunc(r.name, func(ctx context.Context) error {
+			<-ctx.Done()
+			return nil
+		}, func(context.Context) error {
+			r.events.add("stop " + r.name)
+			return nil
+		}).
+		Run(ctx)
+}
+
+func TestRunAllStartsConcurrentlyAndPropagatesError(t *testing.T) {
+	ev := &events{}
+	started := make(chan string, 3)
+	all := make(chan struct{})
+	fail := errors.New("invalid cron spec")
+
+	runners := map[string]Runner[scriptedConfig]{
+		"product":   &groupRunner{name: "product", events: ev, started: started, all: all},
+		"consumer":  &groupRunner{name: "consumer", events: ev, started: started, all: all},
+		"scheduler": &groupRunner{name: "scheduler", events: ev, started: started, all: all, err: fail},
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- RunAll(&scriptedConfig{}, runners, AllApps) }()
+
+	// every runner is started before any of them returns
+	var names []string
+	for range runners {
+		names = append(names, <-started)
+	}
+	close(all)
+	assert.ElementsMatch(t, []string{"product", "consumer", "scheduler"}, names)
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, fail)
+		assert.EqualError(t, err, "scheduler: invalid cron spec")
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunAll did not return")
+	}
+	assert.ElementsMatch(t, []string{"stop product", "stop consumer"}, ev.get(), "the failure stops the other runners")
+}
+
+// blockingRunner is a Runner without a context, it runs until release is closed.
+type blockingRunner struct {
+	release <-chan struct{}
+}
+
+func (r *blockingRunner) Run(*scriptedConfig) error {
+	<-r.release
+	return nil
+}
+
+func TestRunAllAbandonsRunnersNotStopped(t *testing.T) {
+	previous := runAllStopTimeout
+	runAllStopTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { runAllStopTimeout = previous })
+
+	ev := &events{}
+	started := make(chan string, 2)
+	all := make(chan struct{})
+	close(all)
+	release := make(chan struct{})
+	defer close(release)
+	fail := errors.New("invalid cron spec")
+
+	runners := map[string]Runner[scriptedConfig]{
+		"product":   &groupRunner{name: "product", events: ev, started: started, all: all},
+		"worker":    &blockingRunner{release: release},
+		"scheduler": &groupRunner{name: "scheduler", events: ev, started: started, all: all, err: fail},
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- RunAll(&scriptedConfig{}, runners) }()
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, fail)
+		assert.EqualError(t, err, "scheduler: invalid cron spec\nworker: not stopped within 50ms")
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunAll waits for a runner without a context")
+	}
+	assert.Equal(t, []string{"stop product"}, ev.get(), "the failure stops the runners with a context")
+}
+
+func TestRunAllSelectsRunners(t *testing.T) {
+	product, consumer := &plainRunner{}, &plainRunner{}
+	runners := map[string]Runner[scriptedConfig]{"product": product, "consumer": consumer}
+
+	require.NoError(t, RunAll(&scriptedConfig{}, runners, "product"))
+	assert.True(t, product.ran)
+	assert.False(t, consumer.ran)
+
+	assert.EqualError(t, RunAll(&scriptedConfig{}, runners, "product", "billing"), `wotop: unknown application "billing"`)
+	assert.False(t, consumer.ran, "nothing runs when a name is unknown")
+}
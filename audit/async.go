@@ -0,0 +1,169 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/a-aslani/wotop/logger"
+)
+
+const (
+	// DefaultQueueSize is the number of records an AsyncSink buffers.
+	DefaultQueueSize = 1024
+	// DefaultBatchSize is the largest number of records an AsyncSink writes at once.
+	DefaultBatchSize = 100
+	// DefaultFlushInterval is the longest time an AsyncSink holds a record.
+	DefaultFlushInterval = time.Second
+)
+
+var (
+	// ErrQueueFull is returned by AsyncSink.Write when its queue is full, the records are dropped.
+	ErrQueueFull = errors.New("audit: the queue is full, the records are dropped")
+	// ErrClosed is returned by AsyncSink.Write once the sink is closed.
+	ErrClosed = errors.New("audit: the sink is closed")
+)
+
+// AsyncOptions configures an AsyncSink.
+//
+// Fields:
+//   - QueueSize: The number of records buffered, DefaultQueueSize when zero.
+//   - BatchSize: The largest number of records written at once, DefaultBatchSize when zero.
+//   - FlushInterval: The longest time a record is held before it is written,
+//     DefaultFlushInterval when zero.
+//   - Log: Logs the batches the sink cannot store, optional.
+type AsyncOptions struct {
+	QueueSize     int
+	BatchSize     int
+	FlushInterval time.Duration
+	Log           logger.Logger
+}
+
+// AsyncSink is a Sink buffering the records in a bounded queue and writing them in batches to
+// another sink from a goroutine, so the requests do not wait for the store. When the store
+// falls behind and the queue is full, the records are dropped rather than the requests slowed
+// down, see Dropped. The records still queued are lost if the process stops without Close.
+type AsyncSink struct {
+	sink    Sink
+	opts    AsyncOptions
+	queue   chan Record
+	mu      sync.RWMutex // guards closed against the writes racing with Close
+	closed  bool
+	done    chan struct{}
+	dropped atomic.Int64
+}
+
+// NewAsyncSink creates an AsyncSink and starts its goroutine.
+//
+// Parameters:
+//   - sink: The sink the batches are written to, e.g. a PostgresSink.
+//   - opts: The size of the queue and of the batches, and the flush interval.
+//
+// Returns:
+//   - The sink, to close on shutdown.
+func NewAsyncSink(sink Sink, opts AsyncOptions) *AsyncSink {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultQueueSize
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = DefaultFlushInterval
+	}
+
+	s := &AsyncSink{sink: sink, opts: opts, queue: make(chan Record, opts.QueueSize), done: make(chan struct{})}
+	go s.run()
+	return s
+}
+
+// Write queues records without waiting for them to be stored.
+//
+// Parameters:
+//   - ctx: The context of the request, not used by the write of the batch.
+//   - records: The records.
+//
+// Returns:
+//   - ErrQueueFull if records are dropped, ErrClosed once the sink is closed.
+func (s *AsyncSink) Write(_ context.Context, records []Record) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+	for i, record := range records {
+		select {
+		case s.queue <- record:
+		default:
+			s.dropped.Add(int64(len(records) - i))
+			return ErrQueueFull
+		}
+	}
+	return nil
+}
+
+// Dropped returns the number of records dropped because the queue was full.
+func (s *AsyncSink) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Close stops accepting records and waits for the queued ones to be written.
+//
+// Parameters:
+//   - ctx: The context bounding the wait.
+//
+// Returns:
+//   - The error of the context if the records are not written in time.
+func (s *AsyncSink) Close(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run writes the queued records in batches, when a batch is full or at the flush interval.
+func (s *AsyncSink) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Record, 0, s.opts.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.sink.Write(context.Background(), batch); err != nil && s.opts.Log != nil {
+			s.opts.Log.Error(context.Background(), "audit: cannot store %d records: %v", len(batch), err)
+		}
+		batch = make([]Record, 0, s.opts.BatchSize)
+	}
+
+	for {
+		select {
+		case record, ok := <-s.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, record)
+			if len(batch) >= s.opts.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package audit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingSink records the batches written to it, once released.
+type blockingSink struct {
+	mu      sync.Mutex
+	release chan struct{}
+	batches [][]Record
+}
+
+func (s *blockingSink) Write(_ context.Context, records []Record) error {
+	<-s.release
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, records)
+	return nil
+}
+
+func (s *blockingSink) sizes() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sizes := make([]int, 0, len(s.batches))
+	for _, batch := range s.batches {
+		sizes = append(sizes, len(batch))
+	}
+	return sizes
+}
+
+func TestAsyncSinkBatches(t *testing.T) {
+	target := &blockingSink{release: make(chan struct{})}
+	close(target.release)
+	sink := NewAsyncSink(target, AsyncOptions{BatchSize: 2, FlushInterval: time.Hour})
+
+	for _, id := range []string{"1", "2", "3"} {
+		require.NoError(t, sink.Write(context.Background(), []Record{{ID: id}}))
+	}
+	assert.Eventually(t, func() bool { return len(target.sizes()) == 1 }, time.Second, time.Millisecond, "a full batch is written at once")
+
+	require.NoError(t, sink.Close(context.Background()))
+	assert.Equal(t, []int{2, 1}, target.sizes(), "the queued records are written on close")
+	assert.ErrorIs(t, sink.Write(context.Background(), []Record{{ID: "4"}}), ErrClosed)
+}
+
+func TestAsyncSinkFlushInterval(t *testing.T) {
+	target := &blockingSink{release: make(chan struct{})}
+	close(target.release)
+	sink := NewAsyncSink(target, AsyncOptions{FlushInterval: 10 * time.Millisecond})
+	defer sink.Close(context.Background())
+
+	require.NoError(t, sink.Write(context.Background(), []Record{{ID: "1"}}))
+	assert.Eventually(t, func() bool { return len(target.sizes()) == 1 }, time.Second, time.Millisecond)
+}
+
+func TestAsyncSinkDropsWhenFull(t *testing.T) {
+	target := &blockingSink{release: make(chan struct{})}
+	sink := NewAsyncSink(target, AsyncOptions{QueueSize: 2, BatchSize: 1})
+
+	// the first record is taken by the goroutine, blocked in the sink, two more fill the queue
+	require.NoError(t, sink.Write(context.Background(), []Record{{ID: "1"}}))
+	assert.Eventually(t, func() bool { return len(sink.queue) == 0 }, time.Second, time.Millisecond)
+	require.NoError(t, sink.Write(context.Background(), []Record{{ID: "2"}, {ID: "3"}}))
+
+	start := time.Now()
+	assert.ErrorIs(t, sink.Write(context.Background(), []Record{{ID: "4"}, {ID: "5"}}), ErrQueueFull)
+	assert.Less(t, time.Since(start), 10*time.Millisecond, "the write does not wait")
+	assert.Equal(t, int64(2), sink.Dropped())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, sink.Close(ctx), context.DeadlineExceeded)
+
+	close(target.release)
+	require.NoError(t, sink.Close(context.Background()))
+	assert.Equal(t, []int{1, 1, 1}, target.sizes())
+}
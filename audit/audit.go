@@ -0,0 +1,97 @@
+// Package audit keeps the audit trail of the mutations: who did what, when and with which
+// outcome. Middleware records the authenticated mutating requests, with their redacted payload,
+// to a Sink: a PostgresSink, which is also queried with Find, or a PubSubSink handing the
+// records to another service. Wrapping the sink in an AsyncSink keeps its writes off the
+// requests.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Record is the audit record of a request.
+//
+// Fields:
+//   - ID: The ID of the record.
+//   - Time: The time the request was received.
+//   - UserID: The ID of the caller.
+//   - Tenant: The tenant of the caller, empty when it has none.
+//   - Method: The HTTP method of the request.
+//   - Route: The route of the request as registered, e.g. "/orders/:id".
+//   - Path: The path of the request, e.g. "/orders/42".
+//   - Status: The status of the response.
+//   - Request: The JSON body of the request with the sensitive fields redacted, empty when the
+//     body is not JSON or larger than the limit of the middleware.
+//   - Truncated: Whether the body was left out for being larger than the limit.
+//   - TraceID: The trace ID of the request.
+//   - ClientIP: The IP of the client.
+//   - Latency: The time the request took.
+type Record struct {
+	ID        string          `json:"id"`
+	Time      time.Time       `json:"time"`
+	UserID    string          `json:"user_id"`
+	Tenant    string          `json:"tenant,omitempty"`
+	Method    string          `json:"method"`
+	Route     string          `json:"route"`
+	Path      string          `json:"path"`
+	Status    int             `json:"status"`
+	Request   json.RawMessage `json:"request,omitempty"`
+	Truncated bool            `json:"truncated,omitempty"`
+	TraceID   string          `json:"trace_id,omitempty"`
+	ClientIP  string          `json:"client_ip,omitempty"`
+	Latency   time.Duration   `json:"latency"`
+}
+
+// Sink stores the audit records.
+type Sink interface {
+	// Write stores records.
+	//
+	// Parameters:
+	//   - ctx: The context of the write.
+	//   - records: The records, in the order of their requests.
+	//
+	// Returns:
+	//   - An error if the records cannot be stored.
+	Write(ctx context.Context, records []Record) error
+}
+
+// Ensure the sinks implement the Sink interface.
+var (
+	_ Sink = (*MemorySink)(nil)
+	_ Sink = (*PostgresSink)(nil)
+	_ Sink = (*PubSubSink)(nil)
+	_ Sink = (*AsyncSink)(nil)
+)
+
+// MemorySink is a Sink keeping the records in memory, for the tests. It is safe for concurrent
+// use.
+type MemorySink struct {
+	mu      sync.Mutex
+	records []Record
+}
+
+// NewMemorySink creates an empty MemorySink.
+//
+// Returns:
+//   - The sink.
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+func (s *MemorySink) Write(_ context.Context, records []Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, records...)
+	return nil
+}
+
+// Records returns a copy of the records written so far.
+func (s *MemorySink) Records() []Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.records)
+}
@@ -0,0 +1,221 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/logger"
+	"github.com/a-aslani/wotop/model/payload"
+	"github.com/a-aslani/wotop/util"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultMaxBodySize is the largest request body recorded, 64 KiB.
+	DefaultMaxBodySize = 64 << 10
+	// Redacted replaces the values of the redacted fields.
+	Redacted = "[REDACTED]"
+)
+
+// DefaultRedactFields are the fields redacted when Options.RedactFields is empty.
+var DefaultRedactFields = []string{"password", "password_confirmation", "old_password", "new_password", "token", "access_token", "refresh_token", "secret", "api_key", "card_number", "cvv"}
+
+// Options configures Middleware.
+//
+// Fields:
+//   - Methods: The methods recorded, POST, PUT, PATCH and DELETE when empty.
+//   - SkipPaths: The routes not recorded, as registered, e.g. "/sessions/refresh".
+//   - MaxBodySize: The largest request body recorded, DefaultMaxBodySize when zero.
+//   - RedactFields: The JSON fields whose values are redacted at any depth, case-insensitively,
+//     DefaultRedactFields when empty.
+//   - Log: Logs the records the sink cannot store, optional.
+//   - Clock: Timestamps the records, util.SystemClock when nil.
+type Options struct {
+	Methods      []string
+	SkipPaths    []string
+	MaxBodySize  int
+	RedactFields []string
+	Log          logger.Logger
+	Clock        util.Clock
+}
+
+// Middleware returns a Gin middleware recording the authenticated mutating requests to a sink,
+// once the handler ran: the caller and its tenant, the route, the redacted JSON body of the
+// request, the status of the response and the time. The caller is the identity set by the jwt
+// or the apikey middleware, which may run before or after this one, the anonymous requests are
+// not recorded.
+//
+// The request body is read up to MaxBodySize and handed on whole to the handler. A larger body
+// is left out of the record, as is a body which is not JSON, since their secrets could not be
+// redacted. The response is not buffered, its status is read once it is written, so the
+// streamed responses go through as usual.
+//
+// The sink is called from the request, wrap it in an AsyncSink so a slow store does not delay
+// the responses.
+//
+// Parameters:
+//   - sink: The sink of the records.
+//   - opts: The methods and routes recorded, the body limit and the redacted fields.
+//
+// Returns:
+//   - A gin.HandlerFunc to register with gin.Engine.Use.
+func Middleware(sink Sink, opts Options) gin.HandlerFunc {
+
+	if len(opts.Methods) == 0 {
+		opts.Methods = []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	}
+	if opts.MaxBodySize <= 0 {
+		opts.MaxBodySize = DefaultMaxBodySize
+	}
+	if len(opts.RedactFields) == 0 {
+		opts.RedactFields = DefaultRedactFields
+	}
+	if opts.Clock == nil {
+		opts.Clock = util.SystemClock
+	}
+
+	redact := make(map[string]struct{}, len(opts.RedactFields))
+	for _, field := range opts.RedactFields {
+		redact[strings.ToLower(field)] = struct{}{}
+	}
+
+	return func(c *gin.Context) {
+		if !slices.Contains(opts.Methods, c.Request.Method) || slices.Contains(opts.SkipPaths, c.FullPath()) {
+			c.Next()
+			return
+		}
+
+		start := opts.Clock.Now()
+		body, truncated, err := peekBody(c.Request, opts.MaxBodySize)
+		if err != nil {
+			payload.WriteError(c, err, logger.GetTraceID(c.Request.Context()))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+
+		// the authentication middleware may run after this one and replace the request context
+		ctx := c.Request.Context()
+		identity, _ := wotop.IdentityFromContext(ctx)
+		if identity.ID == "" {
+			identity.ID = c.GetString("ID")
+			identity.Tenant = c.GetString("Tenant")
+		}
+		if identity.ID == "" {
+			return
+		}
+
+		record := Record{
+			ID:        uuid.NewString(),
+			Time:      start,
+			UserID:    identity.ID,
+			Tenant:    identity.Tenant,
+			Method:    c.Request.Method,
+			Route:     c.FullPath(),
+			Path:      c.Request.URL.Path,
+			Status:    c.Writer.Status(),
+			Truncated: truncated,
+			TraceID:   logger.GetTraceID(ctx),
+			ClientIP:  c.ClientIP(),
+			Latency:   opts.Clock.Now().Sub(start),
+		}
+		if !truncated && isJSON(c.Request.Header.Get("Content-Type")) {
+			record.Request = redactJSON(body, redact)
+		}
+
+		if err = sink.Write(ctx, []Record{record}); err != nil && opts.Log != nil {
+			opts.Log.Error(ctx, "audit: cannot record %s %s of %s: %v", record.Method, record.Path, record.UserID, err)
+		}
+	}
+}
+
+// peekBody reads the request body up to a limit and puts it back for the handler.
+//
+// Parameters:
+//   - r: The request.
+//   - limit: The largest body read.
+//
+// Returns:
+//   - The body, nil when it is larger than the limit.
+//   - Whether the body is larger than the limit.
+//   - An error if the body cannot be read.
+func peekBody(r *http.Request, limit int) ([]byte, bool, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, false, nil
+	}
+
+	head, err := io.ReadAll(io.LimitReader(r.Body, int64(limit)+1))
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(head) > limit {
+		r.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(head), r.Body), Closer: r.Body}
+		return nil, true, nil
+	}
+	r.Body = readCloser{Reader: bytes.NewReader(head), Closer: r.Body}
+	return head, false, nil
+}
+
+// readCloser reads a body put back by peekBody and closes the original one.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// isJSON reports whether a content type is JSON, e.g. "application/json" or
+// "application/merge-patch+json".
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
+
+// redactJSON replaces the values of the redacted fields of a JSON document.
+//
+// Parameters:
+//   - body: The JSON document.
+//   - fields: The lowercase names of the redacted fields.
+//
+// Returns:
+//   - The redacted document, nil when the body is empty or not valid JSON.
+func redactJSON(body []byte, fields map[string]struct{}) json.RawMessage {
+	var doc any
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return nil
+	}
+
+	redacted, err := json.Marshal(redactValue(doc, fields))
+	if err != nil {
+		return nil
+	}
+	return redacted
+}
+
+// redactValue redacts the fields of the objects of a decoded JSON value, at any depth.
+func redactValue(v any, fields map[string]struct{}) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if _, ok := fields[strings.ToLower(key)]; ok {
+				v[key] = Redacted
+			} else {
+				v[key] = redactValue(value, fields)
+			}
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = redactValue(value, fields)
+		}
+	}
+	return v
+}
@@ -0,0 +1,128 @@
+package audit
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/util"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var t0 = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// newTestEngine returns an engine recording to a MemorySink, whose routes are authenticated
+// as user-1 of acme by the X-User header, and echo the body they read.
+func newTestEngine(opts Options) (*gin.Engine, *MemorySink) {
+	gin.SetMode(gin.TestMode)
+	sink := NewMemorySink()
+	opts.Clock = util.NewFrozenClock(t0)
+
+	r := gin.New()
+	r.Use(Middleware(sink, opts))
+	auth := func(c *gin.Context) {
+		if user := c.GetHeader("X-User"); user != "" {
+			c.Request = c.Request.WithContext(wotop.WithIdentity(c.Request.Context(), wotop.Identity{ID: user, Tenant: "acme"}))
+		}
+	}
+	echo := func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.Data(http.StatusCreated, "text/plain", body)
+	}
+	r.POST("/orders/:id", auth, echo)
+	r.GET("/orders/:id", auth, echo)
+	r.POST("/sessions", auth, echo)
+	return r, sink
+}
+
+func send(r *gin.Engine, method, path, user, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	if user != "" {
+		req.Header.Set("X-User", user)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestMiddlewareRecordsMutations(t *testing.T) {
+	r, sink := newTestEngine(Options{})
+
+	body := `{"item":"book","password":"s3cr3t","card":{"CVV":"123","last4":"4242"},"items":[{"token":"t"}]}`
+	w := send(r, http.MethodPost, "/orders/42", "user-1", "application/json; charset=utf-8", body)
+	assert.Equal(t, body, w.Body.String(), "the handler reads the whole body")
+
+	records := sink.Records()
+	require.Len(t, records, 1)
+	record := records[0]
+	assert.NotEmpty(t, record.ID)
+	assert.Equal(t, t0, record.Time)
+	assert.Equal(t, "user-1", record.UserID)
+	assert.Equal(t, "acme", record.Tenant)
+	assert.Equal(t, http.MethodPost, record.Method)
+	assert.Equal(t, "/orders/:id", record.Route)
+	assert.Equal(t, "/orders/42", record.Path)
+	assert.Equal(t, http.StatusCreated, record.Status)
+	assert.JSONEq(t, `{"item":"book","password":"[REDACTED]","card":{"CVV":"[REDACTED]","last4":"4242"},"items":[{"token":"[REDACTED]"}]}`, string(record.Request))
+	assert.False(t, record.Truncated)
+}
+
+func TestMiddlewareSkipsRequests(t *testing.T) {
+	r, sink := newTestEngine(Options{SkipPaths: []string{"/sessions"}})
+
+	send(r, http.MethodGet, "/orders/42", "user-1", "", "")
+	send(r, http.MethodPost, "/orders/42", "", "application/json", `{}`)
+	send(r, http.MethodPost, "/sessions", "user-1", "application/json", `{}`)
+
+	assert.Empty(t, sink.Records(), "the reads, anonymous requests and skipped routes are not recorded")
+}
+
+func TestMiddlewareLeavesOutBodies(t *testing.T) {
+	r, sink := newTestEngine(Options{MaxBodySize: 16, RedactFields: []string{"note"}})
+
+	large := `{"password":"a very long secret"}`
+	w := send(r, http.MethodPost, "/orders/1", "user-1", "application/json", large)
+	assert.Equal(t, large, w.Body.String(), "the handler reads the whole large body")
+
+	send(r, http.MethodPost, "/orders/2", "user-1", "application/x-www-form-urlencoded", "password=x")
+	send(r, http.MethodPost, "/orders/3", "user-1", "application/json", `{"note":"x"}`)
+
+	records := sink.Records()
+	require.Len(t, records, 3)
+	assert.Nil(t, records[0].Request)
+	assert.True(t, records[0].Truncated)
+	assert.Nil(t, records[1].Request, "a body which is not JSON is left out")
+	assert.False(t, records[1].Truncated)
+	assert.JSONEq(t, `{"note":"[REDACTED]"}`, string(records[2].Request))
+}
+
+// BenchmarkMiddleware measures the overhead of recording a request with redaction to an
+// AsyncSink, which stays in the microseconds whatever the latency of the store.
+func BenchmarkMiddleware(b *testing.B) {
+	sink := NewAsyncSink(NewMemorySink(), AsyncOptions{QueueSize: 1 << 16})
+	defer sink.Close(context.Background())
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware(sink, Options{}))
+	r.POST("/orders/:id", func(c *gin.Context) {
+		c.Request = c.Request.WithContext(wotop.WithIdentity(c.Request.Context(), wotop.Identity{ID: "user-1"}))
+		c.Status(http.StatusNoContent)
+	})
+	body := `{"item":"book","quantity":2,"password":"s3cr3t","address":{"city":"Berlin"}}`
+
+	b.ReportAllocs()
+	for b.Loop() {
+		req := httptest.NewRequest(http.MethodPost, "/orders/42", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
@@ -0,0 +1,209 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/a-aslani/wotop/model/payload"
+	"github.com/lib/pq"
+)
+
+// DefaultTable is the table of PostgresSink when it is given none.
+const DefaultTable = "audit_records"
+
+// SortFields are the fields Find sorts by, to allow in payload.PaginationDefaults.
+var SortFields = []string{"time", "user_id", "tenant", "route", "status"}
+
+// Query filters the records returned by Find, the zero values match every record.
+//
+// Fields:
+//   - UserID: The caller.
+//   - Tenant: The tenant of the caller.
+//   - Route: The route, as registered, e.g. "/orders/:id".
+//   - From: The earliest time, inclusive.
+//   - To: The latest time, exclusive.
+type Query struct {
+	UserID string
+	Tenant string
+	Route  string
+	From   time.Time
+	To     time.Time
+}
+
+// PostgresSink is a Sink keeping the records in a Postgres table created by Schema, and reading
+// them back with Find.
+type PostgresSink struct {
+	db    *sql.DB
+	table string
+}
+
+// NewPostgresSink creates a PostgresSink.
+//
+// Parameters:
+//   - db: The connection pool.
+//   - table: The table of the records, DefaultTable when empty.
+//
+// Returns:
+//   - The sink.
+func NewPostgresSink(db *sql.DB, table string) *PostgresSink {
+	if table == "" {
+		table = DefaultTable
+	}
+	return &PostgresSink{db: db, table: table}
+}
+
+// Schema returns the statements creating the table of the records and the indexes of Find, e.g.
+// for a migration.
+//
+// Parameters:
+//   - table: The table of the records, DefaultTable when empty.
+//
+// Returns:
+//   - The CREATE TABLE and CREATE INDEX statements.
+func Schema(table string) string {
+	if table == "" {
+		table = DefaultTable
+	}
+	quoted := pq.QuoteIdentifier(table)
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %[1]s (
+	id TEXT PRIMARY KEY,
+	time TIMESTAMPTZ NOT NULL,
+	user_id TEXT NOT NULL,
+	tenant TEXT NOT NULL DEFAULT '',
+	method TEXT NOT NULL,
+	route TEXT NOT NULL,
+	path TEXT NOT NULL,
+	status INTEGER NOT NULL,
+	request JSONB,
+	truncated BOOLEAN NOT NULL DEFAULT FALSE,
+	trace_id TEXT NOT NULL DEFAULT '',
+	client_ip TEXT NOT NULL DEFAULT '',
+	latency_us BIGINT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS %[2]s ON %[1]s (time);
+CREATE INDEX IF NOT EXISTS %[3]s ON %[1]s (user_id, time);
+CREATE INDEX IF NOT EXISTS %[4]s ON %[1]s (tenant, time)`,
+		quoted, pq.QuoteIdentifier(table+"_time_idx"), pq.QuoteIdentifier(table+"_user_id_idx"), pq.QuoteIdentifier(table+"_tenant_idx"))
+}
+
+// CreateSchema creates the table of the records unless it exists.
+func (s *PostgresSink) CreateSchema(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, Schema(s.table))
+	return err
+}
+
+// recordColumns are the columns of a record, in the order of the values of Write and Find.
+const recordColumns = "id, time, user_id, tenant, method, route, path, status, request, truncated, trace_id, client_ip, latency_us"
+
+// Write inserts the records with one statement.
+func (s *PostgresSink) Write(ctx context.Context, records []Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	const columns = 13
+	rows := make([]string, 0, len(records))
+	args := make([]any, 0, len(records)*columns)
+	for i, r := range records {
+		placeholders := make([]string, columns)
+		for j := range placeholders {
+			placeholders[j] = fmt.Sprintf("$%d", i*columns+j+1)
+		}
+		rows = append(rows, "("+strings.Join(placeholders, ", ")+")")
+
+		var request any
+		if len(r.Request) > 0 {
+			request = []byte(r.Request)
+		}
+		args = append(args, r.ID, r.Time, r.UserID, r.Tenant, r.Method, r.Route, r.Path, r.Status, request, r.Truncated, r.TraceID, r.ClientIP, r.Latency.Microseconds())
+	}
+
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", pq.QuoteIdentifier(s.table), recordColumns, strings.Join(rows, ", ")), args...)
+	return err
+}
+
+// Find returns a page of the records matching a query, the latest first unless the page is
+// sorted by one of SortFields.
+//
+// Parameters:
+//   - ctx: The context of the query.
+//   - q: The filters.
+//   - p: The page, e.g. read by payload.FromGinQuery.
+//
+// Returns:
+//   - The records of the page.
+//   - The number of records matching the query, for payload.NewPaginatedResponse.
+//   - An error if the records cannot be read.
+func (s *PostgresSink) Find(ctx context.Context, q Query, p payload.Pagination) ([]Record, int64, error) {
+
+	var (
+		conditions []string
+		args       []any
+	)
+	where := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if q.UserID != "" {
+		where("user_id = $%d", q.UserID)
+	}
+	if q.Tenant != "" {
+		where("tenant = $%d", q.Tenant)
+	}
+	if q.Route != "" {
+		where("route = $%d", q.Route)
+	}
+	if !q.From.IsZero() {
+		where("time >= $%d", q.From)
+	}
+	if !q.To.IsZero() {
+		where("time < $%d", q.To)
+	}
+
+	filter := ""
+	if len(conditions) > 0 {
+		filter = " WHERE " + strings.Join(conditions, " AND ")
+	}
+	table := pq.QuoteIdentifier(s.table)
+
+	var total int64
+	if err := s.db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s%s", table, filter), args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	orderBy := "time DESC"
+	if slices.Contains(SortFields, p.Sort) {
+		orderBy = p.OrderBy()
+	}
+	limit := p.Limit()
+	if limit <= 0 {
+		limit = 20
+	}
+
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s%s ORDER BY %s, id LIMIT %d OFFSET %d",
+		recordColumns, table, filter, orderBy, limit, max(p.Offset(), 0)), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	records := make([]Record, 0, limit)
+	for rows.Next() {
+		var (
+			r         Record
+			request   []byte
+			latencyUS int64
+		)
+		if err = rows.Scan(&r.ID, &r.Time, &r.UserID, &r.Tenant, &r.Method, &r.Route, &r.Path, &r.Status, &request, &r.Truncated, &r.TraceID, &r.ClientIP, &latencyUS); err != nil {
+			return nil, 0, err
+		}
+		r.Request = request
+		r.Latency = time.Duration(latencyUS) * time.Microsecond
+		records = append(records, r)
+	}
+	return records, total, rows.Err()
+}
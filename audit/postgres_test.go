@@ -0,0 +1,58 @@
+package audit
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/a-aslani/wotop/model/payload"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresSink(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	sink := NewPostgresSink(db, "")
+	records := []Record{
+		{ID: "r1", Time: t0, UserID: "user-1", Tenant: "acme", Method: "POST", Route: "/orders", Path: "/orders", Status: 201, Request: []byte(`{"item":"book"}`), TraceID: "trace-1", ClientIP: "10.0.0.1", Latency: 1500 * time.Microsecond},
+		{ID: "r2", Time: t0.Add(time.Second), UserID: "user-1", Tenant: "acme", Method: "DELETE", Route: "/orders/:id", Path: "/orders/1", Status: 204, Truncated: true},
+	}
+	columns := []string{"id", "time", "user_id", "tenant", "method", "route", "path", "status", "request", "truncated", "trace_id", "client_ip", "latency_us"}
+
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "audit_records" (`+recordColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13), ($14, `)).
+		WithArgs("r1", t0, "user-1", "acme", "POST", "/orders", "/orders", 201, []byte(`{"item":"book"}`), false, "trace-1", "10.0.0.1", int64(1500),
+			"r2", t0.Add(time.Second), "user-1", "acme", "DELETE", "/orders/:id", "/orders/1", 204, nil, true, "", "", int64(0)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*) FROM "audit_records" WHERE user_id = $1 AND time >= $2`)).
+		WithArgs("user-1", t0).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(12))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT `+recordColumns+` FROM "audit_records" WHERE user_id = $1 AND time >= $2 ORDER BY time DESC, id LIMIT 10 OFFSET 10`)).
+		WithArgs("user-1", t0).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("r2", t0.Add(time.Second), "user-1", "acme", "DELETE", "/orders/:id", "/orders/1", 204, nil, true, "", "", 0).
+			AddRow("r1", t0, "user-1", "acme", "POST", "/orders", "/orders", 201, []byte(`{"item":"book"}`), false, "trace-1", "10.0.0.1", 1500))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*) FROM "audit_records"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM "audit_records" ORDER BY status ASC, id LIMIT 20 OFFSET 0`)).
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	require.NoError(t, sink.Write(ctx, records))
+	require.NoError(t, sink.Write(ctx, nil))
+
+	found, total, err := sink.Find(ctx, Query{UserID: "user-1", From: t0}, payload.Pagination{Page: 2, PerPage: 10, Sort: "name; DROP TABLE", Order: "asc"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), total)
+	assert.Equal(t, []Record{records[1], records[0]}, found)
+
+	_, _, err = sink.Find(ctx, Query{}, payload.Pagination{Page: 1, PerPage: 20, Sort: "status", Order: "asc"})
+	require.NoError(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Contains(t, Schema(""), `CREATE INDEX IF NOT EXISTS "audit_records_user_id_idx" ON "audit_records" (user_id, time)`)
+}
@@ -0,0 +1,48 @@
+package audit
+
+import (
+	"context"
+	"errors"
+
+	"github.com/a-aslani/wotop/pubsub"
+)
+
+// EventName is the name of the events published by PubSubSink.
+const EventName = "audit.Record"
+
+// Publisher publishes the events to a broker. It is implemented by *pubsub.Event.
+type Publisher interface {
+	PublishData(data pubsub.EventData) error
+}
+
+var _ Publisher = (*pubsub.Event)(nil)
+
+// PubSubSink is a Sink publishing each record as an EventName event, for a service keeping the
+// audit trail of several others. The records are published one at a time, wrap the sink in an
+// AsyncSink to keep the broker off the requests.
+type PubSubSink struct {
+	publisher Publisher
+}
+
+// NewPubSubSink creates a PubSubSink.
+//
+// Parameters:
+//   - publisher: The publisher, usually the *pubsub.Event of the application.
+//
+// Returns:
+//   - The sink.
+func NewPubSubSink(publisher Publisher) *PubSubSink {
+	return &PubSubSink{publisher: publisher}
+}
+
+// Write publishes the records, with their IDs as the IDs of the events so the consumers can
+// drop the duplicates.
+func (s *PubSubSink) Write(_ context.Context, records []Record) error {
+	var errs []error
+	for _, record := range records {
+		if err := s.publisher.PublishData(pubsub.EventData{ID: record.ID, Name: EventName, Payload: record}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
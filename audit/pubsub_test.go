@@ -0,0 +1,35 @@
+package audit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/a-aslani/wotop/pubsub"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingPublisher records the published events and fails the ones named by fail.
+type recordingPublisher struct {
+	events []pubsub.EventData
+	fail   string
+}
+
+func (p *recordingPublisher) PublishData(data pubsub.EventData) error {
+	if data.ID == p.fail {
+		return assert.AnError
+	}
+	p.events = append(p.events, data)
+	return nil
+}
+
+func TestPubSubSink(t *testing.T) {
+	publisher := &recordingPublisher{fail: "r2"}
+	sink := NewPubSubSink(publisher)
+
+	err := sink.Write(context.Background(), []Record{{ID: "r1", UserID: "user-1"}, {ID: "r2"}, {ID: "r3"}})
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Equal(t, []pubsub.EventData{
+		{ID: "r1", Name: EventName, Payload: Record{ID: "r1", UserID: "user-1"}},
+		{ID: "r3", Name: EventName, Payload: Record{ID: "r3"}},
+	}, publisher.events, "a failed record does not stop the others")
+}
@@ -0,0 +1,91 @@
+package wotop
+
+import (
+	"runtime"
+	"runtime/debug"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// The build of the application, set when it is built with the -X flags of the linker, e.g.
+//
+//	go build -ldflags "-X github.com/a-aslani/wotop.Version=v1.4.0 \
+//	    -X github.com/a-aslani/wotop.Commit=$(git rev-parse --short HEAD) \
+//	    -X github.com/a-aslani/wotop.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// The ones left empty are read from the build information of the binary, see GetBuildInfo.
+var (
+	Version   = ""
+	Commit    = ""
+	BuildTime = ""
+)
+
+// BuildInfo identifies the build of the application, it is part of ApplicationData so /ping
+// shows which version of a service answers.
+//
+// Fields:
+//   - Version: The version of the application, e.g. "v1.4.0", "dev" when unknown.
+//   - Commit: The VCS revision the application is built from.
+//   - BuildTime: The time of the build, else of the commit.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+}
+
+// GetBuildInfo returns the build of the application: Version, Commit and BuildTime, and for
+// the empty ones the version of the main module and the revision and time of the commit
+// embedded by go build.
+//
+// Returns:
+//   - The BuildInfo of the application.
+func GetBuildInfo() BuildInfo {
+
+	info := BuildInfo{Version: Version, Commit: Commit, BuildTime: BuildTime}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.BuildTime == "":
+				info.BuildTime = s.Value
+			}
+		}
+	}
+
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	return info
+}
+
+// RegisterBuildInfo registers the build_info gauge of the application, always 1 and labelled
+// with its build, so the dashboards can show which version each instance runs, and the
+// collectors of the Go runtime and of the process. The collectors registered before, e.g. the
+// ones of prometheus.DefaultRegisterer, are kept.
+//
+// Parameters:
+//   - reg: The registerer, prometheus.DefaultRegisterer when nil.
+//   - appName: The name of the application, the "app" label.
+//   - info: The build of the application, usually GetBuildInfo().
+func RegisterBuildInfo(reg prometheus.Registerer, appName string, info BuildInfo) {
+
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+
+	registerCollector(reg, collectors.NewGoCollector())
+	registerCollector(reg, collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+
+	buildInfo := registerCollector(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "build_info",
+		Help: "Build of the application, always 1.",
+	}, []string{"app", "version", "commit", "build_time", "go_version"}))
+
+	buildInfo.WithLabelValues(appName, info.Version, info.Commit, info.BuildTime, runtime.Version()).Set(1)
+}
@@ -0,0 +1,74 @@
+package wotop
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setBuild sets the variables set by the linker for the test.
+func setBuild(t *testing.T, version, commit, buildTime string) {
+	previous := [3]string{Version, Commit, BuildTime}
+	Version, Commit, BuildTime = version, commit, buildTime
+	t.Cleanup(func() {
+		Version, Commit, BuildTime = previous[0], previous[1], previous[2]
+	})
+}
+
+func TestGetBuildInfo(t *testing.T) {
+	setBuild(t, "v1.4.0", "3f2c1ab", "2026-10-01T12:00:00Z")
+	assert.Equal(t, BuildInfo{Version: "v1.4.0", Commit: "3f2c1ab", BuildTime: "2026-10-01T12:00:00Z"}, GetBuildInfo())
+
+	// the test binary has no version, nor VCS settings
+	setBuild(t, "", "", "")
+	assert.Equal(t, "dev", GetBuildInfo().Version)
+}
+
+func TestPingShowsBuildInfo(t *testing.T) {
+	setBuild(t, "v1.4.0", "3f2c1ab", "2026-10-01T12:00:00Z")
+	appData := NewApplicationData("product")
+	appData.AppInstanceID, appData.StartTime = "a1b2", "2026-10-16 09:30:00"
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/product/ping", func(c *gin.Context) {
+		c.JSON(http.StatusOK, appData)
+	})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/product/ping", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{
+		"app_name": "product",
+		"app_instance_id": "a1b2",
+		"start_time": "2026-10-16 09:30:00",
+		"build": {"version": "v1.4.0", "commit": "3f2c1ab", "build_time": "2026-10-01T12:00:00Z"}
+	}`, rec.Body.String())
+}
+
+func TestRegisterBuildInfo(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collectors.NewGoCollector())
+
+	info := BuildInfo{Version: "v1.4.0", Commit: "3f2c1ab", BuildTime: "2026-10-01T12:00:00Z"}
+	RegisterBuildInfo(reg, "product", info)
+	RegisterBuildInfo(reg, "product", info)
+
+	expected := `
+# HELP build_info Build of the application, always 1.
+# TYPE build_info gauge
+build_info{app="product",build_time="2026-10-01T12:00:00Z",commit="3f2c1ab",go_version="` + runtime.Version() + `",version="v1.4.0"} 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "build_info"))
+	assert.Positive(t, testutil.CollectAndCount(reg, "go_goroutines"))
+}
@@ -0,0 +1,257 @@
+package centrifugo_api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/a-aslani/wotop/jwt"
+	"github.com/a-aslani/wotop/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// The error codes of the connect proxy responses, the ones the Centrifugo clients know.
+const (
+	// ProxyErrorUnauthorized rejects a connection whose token is missing or invalid.
+	ProxyErrorUnauthorized uint32 = 101
+	// ProxyErrorTokenExpired rejects a connection whose token is expired, the clients get a
+	// new token and reconnect.
+	ProxyErrorTokenExpired uint32 = 109
+)
+
+// ConnectRequest is the body of the connect proxy requests of Centrifugo.
+//
+// Fields:
+//   - Client: The ID of the connection.
+//   - Transport: The transport of the connection, e.g. "websocket".
+//   - Protocol: The protocol of the connection, "json" or "protobuf".
+//   - Encoding: The encoding of the data, "json" or "binary".
+//   - Data: The data of the connect command of the client, see ConnectData.
+type ConnectRequest struct {
+	Client    string          `json:"client"`
+	Transport string          `json:"transport"`
+	Protocol  string          `json:"protocol"`
+	Encoding  string          `json:"encoding"`
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+// ConnectData is the data a client sends with its connect command, the tokens of its session.
+//
+// Fields:
+//   - AccessToken: The access token.
+//   - RefreshToken: The refresh token the connection is extended with, optional.
+//   - Csrf: The CSRF secret of the access token, required with the refresh token.
+type ConnectData struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Csrf         string `json:"csrf,omitempty"`
+}
+
+// ConnectResult accepts a connection.
+//
+// Fields:
+//   - User: The ID of the user of the connection.
+//   - ExpireAt: The Unix time the connection is refreshed at, see RefreshRequest.
+//   - Meta: The data Centrifugo keeps with the connection, hidden from the client and sent back
+//     with the refresh proxy requests: the tokens of the connection.
+type ConnectResult struct {
+	User     string          `json:"user"`
+	ExpireAt int64           `json:"expire_at,omitempty"`
+	Meta     json.RawMessage `json:"meta,omitempty"`
+}
+
+// ProxyError rejects a proxy request.
+//
+// Fields:
+//   - Code: The error code, e.g. ProxyErrorUnauthorized.
+//   - Message: The error message.
+type ProxyError struct {
+	Code    uint32 `json:"code"`
+	Message string `json:"message"`
+}
+
+// ConnectResponse is the body of the responses to the connect proxy requests, with a result or
+// an error.
+type ConnectResponse struct {
+	Result *ConnectResult `json:"result,omitempty"`
+	Error  *ProxyError    `json:"error,omitempty"`
+}
+
+// RefreshRequest is the body of the refresh proxy requests Centrifugo sends when a connection
+// reaches its expiry.
+//
+// Fields:
+//   - Client: The ID of the connection.
+//   - Transport: The transport of the connection.
+//   - Protocol: The protocol of the connection.
+//   - Encoding: The encoding of the data.
+//   - User: The ID of the user of the connection.
+//   - Meta: The meta of the connection, set by the connect or the last refresh result.
+type RefreshRequest struct {
+	Client    string          `json:"client"`
+	Transport string          `json:"transport"`
+	Protocol  string          `json:"protocol"`
+	Encoding  string          `json:"encoding"`
+	User      string          `json:"user"`
+	Meta      json.RawMessage `json:"meta,omitempty"`
+}
+
+// RefreshResult extends or expires a connection.
+//
+// Fields:
+//   - Expired: Whether the connection is expired, Centrifugo closes it.
+//   - ExpireAt: The Unix time the connection is refreshed at again.
+//   - Meta: The new meta of the connection, with the renewed tokens.
+type RefreshResult struct {
+	Expired  bool            `json:"expired,omitempty"`
+	ExpireAt int64           `json:"expire_at,omitempty"`
+	Meta     json.RawMessage `json:"meta,omitempty"`
+}
+
+// RefreshResponse is the body of the responses to the refresh proxy requests.
+type RefreshResponse struct {
+	Result *RefreshResult `json:"result,omitempty"`
+}
+
+// ProxyOptions configures a Proxy.
+//
+// Fields:
+//   - Extractors: Where the access token is looked for when the connect data has none, in the
+//     headers forwarded by Centrifugo, e.g. jwt.BearerHeader or jwt.Cookie, none when empty.
+//   - Log: Logs the refused connections and refreshes, optional.
+type ProxyOptions struct {
+	Extractors []jwt.TokenExtractor
+	Log        logger.Logger
+}
+
+// Proxy serves the connect and refresh proxy requests of Centrifugo, authenticating the
+// connections with the access tokens of the jwt package instead of Centrifugo tokens. A
+// connection expires with its access token: on refresh, the token is renewed silently with the
+// refresh token of the connection, and the connection is extended until the new token expires.
+//
+// The tokens live in the meta of the connection, which Centrifugo v5 replaces by the one of a
+// refresh result. Renewing revokes the old refresh token, so the client should connect with a
+// refresh token of its own rather than the one of its HTTP session.
+type Proxy struct {
+	token jwt.Token
+	opts  ProxyOptions
+}
+
+// NewProxy creates a Proxy.
+//
+// Parameters:
+//   - token: The Token verifying and renewing the tokens.
+//   - opts: The extractors of the access token and the logger.
+//
+// Returns:
+//   - The proxy, whose handlers are registered on the proxy endpoints of Centrifugo.
+func NewProxy(token jwt.Token, opts ProxyOptions) *Proxy {
+	return &Proxy{token: token, opts: opts}
+}
+
+// ConnectHandler returns the handler of the connect proxy requests. The access token of the
+// connect data, else of the extractors, is verified, and renewed when it is about to expire.
+//
+// Returns:
+//   - A gin.HandlerFunc to register on the connect proxy endpoint.
+func (p *Proxy) ConnectHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		var req ConnectRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+
+		var data ConnectData
+		if len(req.Data) > 0 {
+			_ = json.Unmarshal(req.Data, &data)
+		}
+		if data.AccessToken == "" {
+			data.AccessToken = p.extract(c.Request)
+		}
+
+		ctx := c.Request.Context()
+		refresh, err := p.token.ValidateForConnectionRefresh(ctx, data.AccessToken, data.RefreshToken, data.Csrf)
+		if err != nil {
+			p.logRefused(ctx, "connection", req.Client, err)
+			code := ProxyErrorUnauthorized
+			if errors.Is(err, jwt.ErrExpiredToken) {
+				code = ProxyErrorTokenExpired
+			}
+			c.JSON(http.StatusOK, ConnectResponse{Error: &ProxyError{Code: code, Message: err.Error()}})
+			return
+		}
+
+		c.JSON(http.StatusOK, ConnectResponse{Result: &ConnectResult{
+			User:     refresh.UserID,
+			ExpireAt: refresh.ExpiresAt.Unix(),
+			Meta:     connectionMeta(refresh),
+		}})
+	}
+}
+
+// RefreshHandler returns the handler of the refresh proxy requests. The connection is extended
+// while its access token is valid, renewed with its refresh token when it is about to expire,
+// and expired otherwise.
+//
+// Returns:
+//   - A gin.HandlerFunc to register on the refresh proxy endpoint.
+func (p *Proxy) RefreshHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		var req RefreshRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+
+		expired := RefreshResponse{Result: &RefreshResult{Expired: true}}
+
+		var data ConnectData
+		if err := json.Unmarshal(req.Meta, &data); err != nil || data.AccessToken == "" {
+			c.JSON(http.StatusOK, expired)
+			return
+		}
+
+		ctx := c.Request.Context()
+		refresh, err := p.token.ValidateForConnectionRefresh(ctx, data.AccessToken, data.RefreshToken, data.Csrf)
+		if err == nil && refresh.UserID != req.User {
+			err = jwt.ErrUnauthorized
+		}
+		if err != nil {
+			p.logRefused(ctx, "refresh", req.Client, err)
+			c.JSON(http.StatusOK, expired)
+			return
+		}
+
+		result := &RefreshResult{ExpireAt: refresh.ExpiresAt.Unix()}
+		if refresh.Renewed {
+			result.Meta = connectionMeta(refresh)
+		}
+		c.JSON(http.StatusOK, RefreshResponse{Result: result})
+	}
+}
+
+// extract returns the access token found by the first extractor which finds one.
+func (p *Proxy) extract(r *http.Request) string {
+	for _, extract := range p.opts.Extractors {
+		if token, err := extract(r); err == nil {
+			return token
+		}
+	}
+	return ""
+}
+
+func (p *Proxy) logRefused(ctx context.Context, what, client string, err error) {
+	if p.opts.Log != nil {
+		p.opts.Log.Info(ctx, "centrifugo: the %s of the client %s is refused: %v", what, client, err)
+	}
+}
+
+// connectionMeta returns the meta of a connection, its tokens.
+func connectionMeta(refresh *jwt.ConnectionRefresh) json.RawMessage {
+	meta, _ := json.Marshal(ConnectData{AccessToken: refresh.AccessToken, RefreshToken: refresh.RefreshToken, Csrf: refresh.CsrfSecret})
+	return meta
+}
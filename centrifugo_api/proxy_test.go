@@ -0,0 +1,125 @@
+package centrifugo_api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop/jwt"
+	"github.com/a-aslani/wotop/util"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var t0 = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestProxy(t *testing.T) (*gin.Engine, jwt.Token, *util.FrozenClock) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	clock := util.NewFrozenClock(t0)
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	token, err := jwt.NewHS256JWT(context.Background(), "secret", jwt.NewRedisRepository(rdb), 24*time.Hour, 5*time.Minute, jwt.WithClock(clock))
+	require.NoError(t, err)
+
+	proxy := NewProxy(token, ProxyOptions{Extractors: []jwt.TokenExtractor{jwt.BearerHeader()}})
+	r := gin.New()
+	r.POST("/centrifugo/connect", proxy.ConnectHandler())
+	r.POST("/centrifugo/refresh", proxy.RefreshHandler())
+	return r, token, clock
+}
+
+func post[T any](t *testing.T, r *gin.Engine, path string, body any, header http.Header) T {
+	t.Helper()
+	b, err := json.Marshal(body)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp T
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp
+}
+
+func connectData(t *testing.T, data ConnectData) json.RawMessage {
+	b, err := json.Marshal(data)
+	require.NoError(t, err)
+	return b
+}
+
+func TestProxyConnectionOutlivesAccessToken(t *testing.T) {
+	r, token, clock := newTestProxy(t)
+	accessToken, refreshToken, csrf, _, err := token.GenerateToken(context.Background(), "user-1", "member", "user-1", "acme")
+	require.NoError(t, err)
+
+	connect := post[ConnectResponse](t, r, "/centrifugo/connect", ConnectRequest{
+		Client: "client-1",
+		Data:   connectData(t, ConnectData{AccessToken: accessToken, RefreshToken: refreshToken, Csrf: csrf}),
+	}, nil)
+	require.NotNil(t, connect.Result, connect.Error)
+	assert.Equal(t, "user-1", connect.Result.User)
+	assert.Equal(t, t0.Add(5*time.Minute).Unix(), connect.Result.ExpireAt, "the connection expires with the access token")
+
+	// Centrifugo refreshes the connection when it expires, the token is renewed silently
+	clock.Set(t0.Add(5 * time.Minute))
+	refresh := post[RefreshResponse](t, r, "/centrifugo/refresh", RefreshRequest{Client: "client-1", User: "user-1", Meta: connect.Result.Meta}, nil)
+	require.NotNil(t, refresh.Result)
+	assert.False(t, refresh.Result.Expired)
+	assert.Equal(t, t0.Add(10*time.Minute).Unix(), refresh.Result.ExpireAt)
+
+	var meta ConnectData
+	require.NoError(t, json.Unmarshal(refresh.Result.Meta, &meta))
+	assert.NotEqual(t, accessToken, meta.AccessToken)
+	_, claims, err := token.VerifyToken(meta.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.ID)
+
+	// a refresh with the stale meta finds its refresh token revoked
+	stale := post[RefreshResponse](t, r, "/centrifugo/refresh", RefreshRequest{Client: "client-1", User: "user-1", Meta: connect.Result.Meta}, nil)
+	assert.True(t, stale.Result.Expired)
+
+	// a refresh before the token is about to expire keeps the meta
+	clock.Set(t0.Add(6 * time.Minute))
+	kept := post[RefreshResponse](t, r, "/centrifugo/refresh", RefreshRequest{Client: "client-1", User: "user-1", Meta: refresh.Result.Meta}, nil)
+	assert.Equal(t, &RefreshResult{ExpireAt: t0.Add(10 * time.Minute).Unix()}, kept.Result)
+
+	// the meta of another user expires the connection
+	other := post[RefreshResponse](t, r, "/centrifugo/refresh", RefreshRequest{Client: "client-1", User: "user-2", Meta: refresh.Result.Meta}, nil)
+	assert.True(t, other.Result.Expired)
+}
+
+func TestProxyConnectionWithoutRefreshToken(t *testing.T) {
+	r, token, clock := newTestProxy(t)
+	accessToken, _, _, _, err := token.GenerateToken(context.Background(), "user-1", "member", "user-1", "acme")
+	require.NoError(t, err)
+
+	// the access token is forwarded in the headers
+	connect := post[ConnectResponse](t, r, "/centrifugo/connect", ConnectRequest{Client: "client-1"}, http.Header{"Authorization": {"Bearer " + accessToken}})
+	require.NotNil(t, connect.Result, connect.Error)
+
+	clock.Set(t0.Add(5*time.Minute + time.Second))
+	refresh := post[RefreshResponse](t, r, "/centrifugo/refresh", RefreshRequest{Client: "client-1", User: "user-1", Meta: connect.Result.Meta}, nil)
+	assert.True(t, refresh.Result.Expired, "the connection expires with its access token")
+
+	expired := post[ConnectResponse](t, r, "/centrifugo/connect", ConnectRequest{Client: "client-2", Data: connectData(t, ConnectData{AccessToken: accessToken})}, nil)
+	require.NotNil(t, expired.Error)
+	assert.Equal(t, ProxyErrorTokenExpired, expired.Error.Code)
+
+	missing := post[ConnectResponse](t, r, "/centrifugo/connect", ConnectRequest{Client: "client-3"}, nil)
+	require.NotNil(t, missing.Error)
+	assert.Equal(t, ProxyErrorUnauthorized, missing.Error.Code)
+}
@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"reflect"
+	"strconv"
+)
+
+// goFile is a parsed Go source file that can be edited through its AST and printed back.
+type goFile struct {
+	path    string
+	fset    *token.FileSet
+	file    *ast.File
+	changed bool
+}
+
+// parseGoFile parses the Go file at path, keeping its comments.
+func parseGoFile(path string) (*goFile, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	return &goFile{path: path, fset: fset, file: file}, nil
+}
+
+// bytes prints the file with gofmt style.
+func (g *goFile) bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, g.fset, g.file); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// addImport adds the import path to the file unless it is imported already.
+func (g *goFile) addImport(path string) {
+	for _, imp := range g.file.Imports {
+		if p, _ := strconv.Unquote(imp.Path.Value); p == path {
+			return
+		}
+	}
+
+	spec := &ast.ImportSpec{Path: &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(path)}}
+
+	var decl *ast.GenDecl
+	for _, d := range g.file.Decls {
+		if gd, ok := d.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+			decl = gd
+			break
+		}
+	}
+
+	if decl == nil {
+		decl = &ast.GenDecl{Tok: token.IMPORT}
+		g.file.Decls = append([]ast.Decl{decl}, g.file.Decls...)
+	}
+
+	// a single import without parentheses must get them before a second spec is added
+	if !decl.Lparen.IsValid() && len(decl.Specs) > 0 {
+		decl.Lparen = decl.Pos()
+		decl.Rparen = decl.End()
+	}
+
+	// the new spec is placed at the end of the block so it does not disturb existing comments
+	if decl.Rparen.IsValid() {
+		spec.Path.ValuePos = decl.Rparen - 1
+	}
+
+	decl.Specs = append(decl.Specs, spec)
+	g.file.Imports = append(g.file.Imports, spec)
+	g.changed = true
+}
+
+// appendCallArg appends expr to the arguments of the first call of the given method,
+// e.g. AddUsecase, unless an identical argument is passed already.
+// It reports whether such a call was found.
+func (g *goFile) appendCallArg(method, expr string) (bool, error) {
+	arg, err := parser.ParseExpr(expr)
+	if err != nil {
+		return false, err
+	}
+
+	var call *ast.CallExpr
+	ast.Inspect(g.file, func(n ast.Node) bool {
+		if call != nil {
+			return false
+		}
+		if c, ok := n.(*ast.CallExpr); ok {
+			if sel, ok := c.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == method {
+				call = c
+			}
+		}
+		return true
+	})
+	if call == nil {
+		return false, nil
+	}
+
+	for _, a := range call.Args {
+		if types.ExprString(a) == types.ExprString(arg) {
+			return true, nil
+		}
+	}
+
+	if len(call.Args) > 0 {
+		setPos(arg, call.Args[len(call.Args)-1].End())
+	} else {
+		setPos(arg, call.Lparen)
+	}
+	call.Args = append(call.Args, arg)
+	g.changed = true
+	return true, nil
+}
+
+// appendStmt appends the expression statement at the end of the body of the given function or
+// method, unless the body already contains it. It reports whether the function was found.
+func (g *goFile) appendStmt(funcName, stmt string) (bool, error) {
+	expr, err := parser.ParseExpr(stmt)
+	if err != nil {
+		return false, fmt.Errorf("invalid statement %q: %w", stmt, err)
+	}
+
+	for _, decl := range g.file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Name.Name != funcName || fn.Body == nil {
+			continue
+		}
+
+		for _, s := range fn.Body.List {
+			if es, ok := s.(*ast.ExprStmt); ok && types.ExprString(es.X) == types.ExprString(expr) {
+				return true, nil
+			}
+		}
+
+		setPos(expr, fn.Body.Rbrace-1)
+		fn.Body.List = append(fn.Body.List, &ast.ExprStmt{X: expr})
+		g.changed = true
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// setPos moves every position of a node parsed on its own to pos. The printer lays nodes out by
+// their positions, so nodes keeping the positions of another file get broken across lines.
+func setPos(node ast.Node, pos token.Pos) {
+	posType := reflect.TypeOf(token.NoPos)
+	objType := reflect.TypeOf((*ast.Object)(nil))
+
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		switch v.Kind() {
+		case reflect.Pointer, reflect.Interface:
+			if !v.IsNil() {
+				walk(v.Elem())
+			}
+		case reflect.Slice:
+			for i := 0; i < v.Len(); i++ {
+				walk(v.Index(i))
+			}
+		case reflect.Struct:
+			for i := 0; i < v.NumField(); i++ {
+				f := v.Field(i)
+				if f.Type() == objType {
+					continue // resolved objects point back to their declarations
+				}
+				if f.Type() == posType && f.CanSet() && token.Pos(f.Int()).IsValid() {
+					f.SetInt(int64(pos))
+				} else if f.CanSet() {
+					walk(f)
+				}
+			}
+		}
+	}
+
+	walk(reflect.ValueOf(node))
+}
+
+// callsMethod reports whether the file contains a call of the given method.
+func (g *goFile) callsMethod(method string) bool {
+	found := false
+	ast.Inspect(g.file, func(n ast.Node) bool {
+		if c, ok := n.(*ast.CallExpr); ok {
+			if sel, ok := c.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == method {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// usesSelector reports whether the file contains the selector x.sel, e.g. r.api.
+func (g *goFile) usesSelector(x, sel string) bool {
+	found := false
+	ast.Inspect(g.file, func(n ast.Node) bool {
+		if s, ok := n.(*ast.SelectorExpr); ok && s.Sel.Name == sel {
+			if id, ok := s.X.(*ast.Ident); ok && id.Name == x {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// save writes the file back when it was changed.
+func (g *goFile) save() error {
+	if !g.changed {
+		return nil
+	}
+	src, err := g.bytes()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(g.path, src, 0644)
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/require"
+)
+
+// originalWD is the directory of the cmd package, the tests change the working directory.
+var originalWD, _ = os.Getwd()
+
+// newTestProject creates an empty project with a go.mod in a temp dir and makes it the working directory.
+func newTestProject(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Chdir(dir)
+	require.NoError(t, os.WriteFile("go.mod", []byte("module example.com/app\n\ngo 1.24\n"), 0644))
+	return dir
+}
+
+// runCommand executes the root command with the given arguments.
+// Flags are reset first because the commands are package level and keep their values between runs.
+func runCommand(t *testing.T, args ...string) error {
+	t.Helper()
+	resetFlags(rootCmd)
+	rootCmd.SetArgs(args)
+	return rootCmd.Execute()
+}
+
+// resetFlags restores the default value of the flags of c and its sub commands.
+func resetFlags(c *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		_ = f.Value.Set(f.DefValue)
+		f.Changed = false
+	}
+	c.Flags().VisitAll(reset)
+	c.PersistentFlags().VisitAll(reset)
+	for _, sub := range c.Commands() {
+		resetFlags(sub)
+	}
+}
+
+// assertGoFilesCompile parses every generated Go file and, when the go tool is available,
+// builds the packages that do not depend on anything outside the standard library.
+func assertGoFilesCompile(t *testing.T, dir string, pkgs ...string) {
+	t.Helper()
+
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".go") {
+			return err
+		}
+		_, err = parser.ParseFile(token.NewFileSet(), path, nil, parser.AllErrors)
+		return err
+	})
+	require.NoError(t, err)
+
+	if len(pkgs) == 0 {
+		return
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Log("go tool not found, skipping build of the generated code")
+		return
+	}
+
+	build := exec.Command("go", append([]string{"build"}, pkgs...)...)
+	build.Dir = dir
+	build.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+	out, err := build.CombinedOutput()
+	require.NoError(t, err, string(out))
+}
+
+// buildWithFramework builds packages of the generated project importing the framework.
+func buildWithFramework(t *testing.T, dir string, pkgs ...string) {
+	t.Helper()
+	goWithFramework(t, dir, append([]string{"build"}, pkgs...)...)
+}
+
+// goWithFramework runs the go command in the generated project with the framework replaced by
+// the repository the tests run in. Dependencies are resolved from the module cache only and the
+// command is skipped when they are not available offline.
+func goWithFramework(t *testing.T, dir string, args ...string) {
+	t.Helper()
+
+	if testing.Short() {
+		t.Log("short mode, skipping build of the generated code")
+		return
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Log("go tool not found, skipping build of the generated code")
+		return
+	}
+
+	repoRoot, err := filepath.Abs(filepath.Join(originalWD, ".."))
+	require.NoError(t, err)
+
+	gomod := fmt.Sprintf("module example.com/app\n\ngo 1.24\n\nrequire github.com/a-aslani/wotop v0.0.0\n\nreplace github.com/a-aslani/wotop => %s\n", repoRoot)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte(gomod), 0644))
+
+	build := exec.Command("go", args...)
+	build.Dir = dir
+	build.Env = append(os.Environ(), "GOPROXY=off", "GOSUMDB=off", "GOFLAGS=-mod=mod", "GOWORK=off")
+	out, err := build.CombinedOutput()
+	if err != nil && strings.Contains(string(out), "module lookup disabled") {
+		t.Skipf("dependencies are not available offline: %s", out)
+	}
+	require.NoError(t, err, string(out))
+}
@@ -0,0 +1,369 @@
+package cmd
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/a-aslani/wotop/model/apperror"
+	"github.com/a-aslani/wotop/util"
+	"github.com/a-aslani/wotop/validator"
+	"go/ast"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Embed the templates for the configs package.
+//
+//go:embed templates/config/*.tmpl
+var configFS embed.FS
+
+// loadConfigTemplates loads and parses the embedded configs package templates.
+func loadConfigTemplates() (*template.Template, error) {
+	sub, err := fs.Sub(configFS, "templates/config")
+	if err != nil {
+		return nil, err
+	}
+	return template.ParseFS(sub, "*.tmpl")
+}
+
+// configData holds what the configs package templates need. The `new` command passes its own
+// data, which has the same fields.
+type configData struct {
+	App          string
+	WithRabbitMQ bool
+	WithPostgres bool
+	WithJWT      bool
+}
+
+// configKey is a key of the Config struct of a project, read from the tags of its fields.
+// The keys of a map section use "*" in place of the map key, e.g. servers.*.address.
+type configKey struct {
+	Key      string
+	Env      string
+	Type     string
+	Required bool
+}
+
+// concrete replaces the "*" of a map section by its key.
+func (k configKey) concrete(name string) configKey {
+	k.Key = strings.Replace(k.Key, "*", name, 1)
+	k.Env = strings.Replace(k.Env, "*", strings.ToUpper(name), 1)
+	return k
+}
+
+// configKeys reads the keys of the Config struct declared in the Go file, following the
+// struct types declared in the same file.
+func configKeys(path string) ([]configKey, error) {
+	f, err := parseGoFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	structs := map[string]*ast.StructType{}
+	ast.Inspect(f.file, func(n ast.Node) bool {
+		if ts, ok := n.(*ast.TypeSpec); ok {
+			if st, ok := ts.Type.(*ast.StructType); ok {
+				structs[ts.Name.Name] = st
+			}
+		}
+		return true
+	})
+
+	if structs["Config"] == nil {
+		return nil, fmt.Errorf("type Config not found in %s", path)
+	}
+
+	var keys []configKey
+
+	var walk func(st *ast.StructType, key, env string)
+	walk = func(st *ast.StructType, key, env string) {
+		for _, field := range st.Fields.List {
+			if field.Tag == nil {
+				continue
+			}
+			tag, _ := strconv.Unquote(field.Tag.Value)
+			k, e := reflect.StructTag(tag).Get("mapstructure"), reflect.StructTag(tag).Get("env")
+			if k == "" || e == "" {
+				continue
+			}
+			k, _, _ = strings.Cut(k, ",")
+			if key != "" {
+				k, e = key+"."+k, env+"_"+e
+			}
+
+			typ := field.Type
+			if m, ok := typ.(*ast.MapType); ok {
+				typ, k, e = m.Value, k+".*", e+"_*"
+			}
+
+			if ident, ok := typ.(*ast.Ident); ok && structs[ident.Name] != nil {
+				walk(structs[ident.Name], k, e)
+				continue
+			}
+
+			keys = append(keys, configKey{
+				Key:      k,
+				Env:      e,
+				Type:     exprString(typ),
+				Required: strings.Contains(reflect.StructTag(tag).Get("validate"), "required"),
+			})
+		}
+	}
+	walk(structs["Config"], "", "")
+
+	return keys, nil
+}
+
+// exprString prints a type expression, e.g. time.Duration.
+func exprString(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.SelectorExpr:
+		return exprString(e.X) + "." + e.Sel.Name
+	}
+	return ""
+}
+
+// checkConfigValue reports whether the value can be decoded into the Go type of the key.
+// Types the command does not know are accepted.
+func checkConfigValue(typ, value string) bool {
+	var err error
+	switch typ {
+	case "int", "int8", "int16", "int32", "int64":
+		_, err = strconv.ParseInt(value, 10, 64)
+	case "uint", "uint8", "uint16", "uint32", "uint64":
+		_, err = strconv.ParseUint(value, 10, 64)
+	case "float32", "float64":
+		_, err = strconv.ParseFloat(value, 64)
+	case "bool":
+		_, err = strconv.ParseBool(value)
+	case "time.Duration":
+		_, err = time.ParseDuration(value)
+	}
+	return err == nil
+}
+
+// checkConfig validates the values of the config file, overridden by the env file, against the
+// keys of the Config struct. The keys of map sections are taken from the config file, like
+// LoadConfig of the generated configs package does.
+func checkConfig(keys []configKey, configFile, envFile string) ([]validator.Message, error) {
+
+	cfg := viper.New()
+	if configFile != "" {
+		cfg.SetConfigFile(configFile)
+		if err := cfg.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("cannot read config %q: %w", configFile, err)
+		}
+	}
+
+	env := viper.New()
+	env.SetConfigFile(envFile)
+	env.SetConfigType("env")
+	if err := env.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("cannot read env file %q: %w", envFile, err)
+	}
+
+	messages := make([]validator.Message, 0)
+	report := func(key string, e apperror.ErrorType) {
+		messages = append(messages, validator.Message{FieldName: key, Code: e.Code(), Message: e.Error()})
+	}
+
+	seen := map[string]bool{}
+	for _, k := range keys {
+
+		concrete := []configKey{k}
+
+		if section, _, ok := strings.Cut(k.Key, ".*"); ok {
+			names := make([]string, 0)
+			for name := range cfg.GetStringMap(section) {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			if len(names) == 0 {
+				if !seen[section] {
+					seen[section] = true
+					report(section, validator.ErrIsRequired.Var(section))
+				}
+				continue
+			}
+
+			concrete = concrete[:0]
+			for _, name := range names {
+				concrete = append(concrete, k.concrete(name))
+			}
+		}
+
+		for _, c := range concrete {
+			value := cfg.GetString(c.Key)
+			if env.IsSet(strings.ToLower(c.Env)) {
+				value = env.GetString(strings.ToLower(c.Env))
+			}
+
+			switch {
+			case strings.TrimSpace(value) == "":
+				if c.Required {
+					report(c.Key, validator.ErrIsRequired.Var(c.Key))
+				}
+			case !checkConfigValue(c.Type, value):
+				report(c.Key, validator.ErrInvalidValue.Var(c.Key, value, c.Type))
+			}
+		}
+	}
+
+	return messages, nil
+}
+
+// writeEnvExample writes an env file listing the environment variables of the keys of the
+// Config struct declared in dataFile, with the map sections keyed by app.
+func writeEnvExample(dataFile, app, outPath string) error {
+	keys, err := configKeys(dataFile)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	b.WriteString("# Environment variables overriding config.yaml, copy this file to .env and fill in the values\n")
+	for _, k := range keys {
+		k = k.concrete(app)
+		if k.Required {
+			fmt.Fprintf(&b, "# %s (required)\n", k.Key)
+		} else {
+			fmt.Fprintf(&b, "# %s\n", k.Key)
+		}
+		fmt.Fprintf(&b, "%s=\n", k.Env)
+	}
+
+	return writeFile(outPath, []byte(b.String()))
+}
+
+// configCmd groups the commands managing the configs package.
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Generate and check the configs package",
+}
+
+// configInitCmd generates the configs package and the .env.example file.
+// Usage: `config init [app-name] --with-postgres --with-rabbitmq --with-jwt`
+// - `app-name`: The application whose server config is used in .env.example.
+var configInitCmd = &cobra.Command{
+	Use:   "init [app-name]",
+	Short: "Generate the configs package with validation and a .env.example",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		force, _ := cmd.Flags().GetBool("force")
+
+		data := configData{App: util.SnakeCase(args[0])}
+		data.WithRabbitMQ, _ = cmd.Flags().GetBool("with-rabbitmq")
+		data.WithPostgres, _ = cmd.Flags().GetBool("with-postgres")
+		data.WithJWT, _ = cmd.Flags().GetBool("with-jwt")
+
+		files := map[string]string{
+			"config.go.tmpl": filepath.Join("configs", "config.go"),
+			"data.go.tmpl":   filepath.Join("configs", "data.go"),
+		}
+
+		if !force {
+			for _, path := range files {
+				if _, err := os.Stat(path); err == nil {
+					return fmt.Errorf("%s already exists, use --force to overwrite it", path)
+				}
+			}
+		}
+
+		tpl, err := loadConfigTemplates()
+		if err != nil {
+			return err
+		}
+
+		for _, name := range []string{"config.go.tmpl", "data.go.tmpl"} {
+			if err := writeGoTemplate(tpl, name, files[name], data); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "✅ Generated %s\n", files[name])
+		}
+
+		if err := writeEnvExample(files["data.go.tmpl"], data.App, ".env.example"); err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), "✅ Generated .env.example")
+
+		return nil
+	},
+}
+
+// configCheckCmd checks an env file against the Config struct of the project.
+// Usage: `config check [file] --config config.yaml`
+// - `file`: The env file, defaults to .env.
+//
+// The missing and invalid keys are printed in the format of validator.Message.
+var configCheckCmd = &cobra.Command{
+	Use:   "check [file]",
+	Short: "Check an env file against the Config struct of the project",
+	Args:  cobra.MaximumNArgs(1),
+	// the messages are the output of a failed check, the usage would only bury them
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envFile := ".env"
+		if len(args) > 0 {
+			envFile = args[0]
+		}
+
+		configFile, _ := cmd.Flags().GetString("config")
+		configsDir, _ := cmd.Flags().GetString("configs")
+
+		// the default config file is optional, an explicit one must exist
+		if _, err := os.Stat(configFile); errors.Is(err, os.ErrNotExist) && !cmd.Flags().Changed("config") {
+			configFile = ""
+		}
+
+		keys, err := configKeys(filepath.Join(configsDir, "data.go"))
+		if err != nil {
+			return fmt.Errorf("cannot read the Config struct, generate it with `wotop config init`: %w", err)
+		}
+
+		messages, err := checkConfig(keys, configFile, envFile)
+		if err != nil {
+			return err
+		}
+
+		if len(messages) == 0 {
+			fmt.Fprintf(cmd.OutOrStdout(), "✅ %s is valid\n", envFile)
+			return nil
+		}
+
+		out, err := json.MarshalIndent(messages, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), string(out))
+
+		return fmt.Errorf("%s has %d missing or invalid keys", envFile, len(messages))
+	},
+}
+
+// init adds the config commands to the root command.
+func init() {
+	configInitCmd.Flags().Bool("with-rabbitmq", false, "add the rabbitmq section")
+	configInitCmd.Flags().Bool("with-postgres", false, "add the database section")
+	configInitCmd.Flags().Bool("with-jwt", false, "add the jwt section")
+	configInitCmd.Flags().Bool("force", false, "overwrite an existing configs package")
+
+	configCheckCmd.Flags().String("config", "config.yaml", "config file whose values the env file overrides")
+	configCheckCmd.Flags().String("configs", "configs", "directory of the configs package")
+
+	configCmd.AddCommand(configInitCmd, configCheckCmd)
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/a-aslani/wotop/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadConfigTest is written into the generated project to run its LoadConfig.
+const loadConfigTest = `package configs
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	yaml := "stage: dev\nservers:\n  shop:\n    address: \":8000\"\ndatabase:\n  host: localhost\n  port: \"5432\"\n  user: postgres\n"
+	if err := os.WriteFile("config.yaml", []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := LoadConfig("config.yaml")
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Messages) != 1 || verr.Messages[0].FieldName != "database.name" {
+		t.Fatalf("expected database.name to be reported, got %v", err)
+	}
+
+	t.Setenv("DATABASE_NAME", "shop")
+	cfg, err := LoadConfig("config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Servers["shop"].Address != ":8000" || cfg.Database.Name != "shop" {
+		t.Fatalf("unexpected config %+v", cfg)
+	}
+}
+`
+
+func TestConfigInit(t *testing.T) {
+	dir := newTestProject(t)
+
+	require.NoError(t, runCommand(t, "config", "init", "shop", "--with-postgres"))
+
+	data, err := os.ReadFile(filepath.Join(dir, "configs", "data.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `env:"DATABASE"`)
+	assert.NotContains(t, string(data), "RabbitMQ")
+
+	env, err := os.ReadFile(filepath.Join(dir, ".env.example"))
+	require.NoError(t, err)
+	assert.Contains(t, string(env), "SERVERS_SHOP_ADDRESS=\n")
+	assert.Contains(t, string(env), "# database.host (required)\nDATABASE_HOST=\n")
+	assert.Contains(t, string(env), "# database.password\nDATABASE_PASSWORD=\n")
+
+	assert.ErrorContains(t, runCommand(t, "config", "init", "shop"), "use --force")
+	require.NoError(t, runCommand(t, "config", "init", "shop", "--with-postgres", "--force"))
+
+	assertGoFilesCompile(t, dir)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "configs", "config_test.go"), []byte(loadConfigTest), 0644))
+	goWithFramework(t, dir, "test", "./configs")
+}
+
+func TestConfigCheck(t *testing.T) {
+	newTestProject(t)
+	require.NoError(t, runCommand(t, "config", "init", "shop", "--with-postgres", "--with-jwt"))
+	require.NoError(t, os.WriteFile("config.yaml", []byte("stage: dev\nservers:\n  shop:\n    address: \":8000\"\n  admin:\n    proxy_path: /admin\njwt:\n  access_token_ttl: 15m\n  refresh_token_ttl: 720h\n"), 0644))
+
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	t.Cleanup(func() { rootCmd.SetOut(nil) })
+
+	t.Run("valid", func(t *testing.T) {
+		out.Reset()
+		env := "SERVERS_ADMIN_ADDRESS=:8001\nDATABASE_HOST=localhost\nDATABASE_PORT=5432\nDATABASE_USER=postgres\nDATABASE_NAME=shop\nJWT_SECRET_KEY=secret\nJWT_REDIS_ADDRESS=localhost:6379\n"
+		require.NoError(t, os.WriteFile("valid.env", []byte(env), 0644))
+
+		require.NoError(t, runCommand(t, "config", "check", "valid.env"))
+		assert.Contains(t, out.String(), "valid.env is valid")
+	})
+
+	t.Run("broken", func(t *testing.T) {
+		out.Reset()
+		env := "DATABASE_HOST=localhost\nDATABASE_PORT=5432\nDATABASE_USER=postgres\nJWT_SECRET_KEY=secret\nJWT_REDIS_ADDRESS=localhost:6379\nJWT_ACCESS_TOKEN_TTL=soon\n"
+		require.NoError(t, os.WriteFile("broken.env", []byte(env), 0644))
+
+		err := runCommand(t, "config", "check", "broken.env")
+		assert.ErrorContains(t, err, "broken.env has 3 missing or invalid keys")
+
+		var messages []validator.Message
+		require.NoError(t, json.Unmarshal(out.Bytes(), &messages))
+		assert.Equal(t, []validator.Message{
+			{FieldName: "servers.admin.address", Code: "ER0003", Message: "servers.admin.address is required"},
+			{FieldName: "database.name", Code: "ER0003", Message: "database.name is required"},
+			{FieldName: "jwt.access_token_ttl", Code: "ER0007", Message: `jwt.access_token_ttl has the invalid value "soon", expected time.Duration`},
+		}, messages)
+	})
+
+	t.Run("without servers", func(t *testing.T) {
+		out.Reset()
+		require.NoError(t, os.WriteFile("empty.yaml", []byte("stage: dev\n"), 0644))
+
+		err := runCommand(t, "config", "check", "valid.env", "--config", "empty.yaml")
+		assert.Error(t, err)
+		assert.Contains(t, out.String(), `"field_name": "servers"`)
+	})
+
+	t.Run("missing files", func(t *testing.T) {
+		assert.ErrorContains(t, runCommand(t, "config", "check", "missing.env"), "cannot read env file")
+		assert.ErrorContains(t, runCommand(t, "config", "check", "valid.env", "--configs", "missing"), "wotop config init")
+	})
+}
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"github.com/a-aslani/wotop/util"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/spf13/cobra"
+)
+
+// Embed the templates for event generation.
+//
+//go:embed templates/event/*.tmpl
+var eventFS embed.FS
+
+// loadEventTemplates loads and parses the embedded event templates.
+func loadEventTemplates() (*template.Template, error) {
+	sub, err := fs.Sub(eventFS, "templates/event")
+	if err != nil {
+		return nil, err
+	}
+	return template.ParseFS(sub, "*.tmpl")
+}
+
+// eventData holds what the event templates need.
+type eventData struct {
+	Domain      string
+	Event       string // the payload type, e.g. OrderCreated
+	Name        string // the routing key, e.g. shop.order_created
+	Module      string // the module path of the project, set with a usecase
+	Usecase     string // the package of the usecase executed by the event, e.g. reserve_stock
+	UsecaseType string // the usecase in camel case, e.g. ReserveStock
+}
+
+// eventFile is a file generated for an event.
+type eventFile struct {
+	template string
+	path     string
+}
+
+// eventFiles returns the files to generate for the event in dir. The event executing a usecase
+// gets a mapper to the request of the usecase instead of a handler.
+func eventFiles(dir, snakeName, usecase string, publisher, consumer bool) []eventFile {
+	files := []eventFile{{"payload.tmpl", filepath.Join(dir, snakeName+".go")}}
+	if publisher {
+		files = append(files, eventFile{"publisher.tmpl", filepath.Join(dir, snakeName+"_publisher.go")})
+	}
+	switch {
+	case consumer && usecase != "":
+		files = append(files, eventFile{"mapper.tmpl", filepath.Join(dir, snakeName+"_to_"+usecase+".go")})
+	case consumer:
+		files = append(files,
+			eventFile{"consumer.tmpl", filepath.Join(dir, "consumer.go")},
+			eventFile{"handler.tmpl", filepath.Join(dir, snakeName+"_handler.go")},
+		)
+	}
+	return files
+}
+
+// eventCmd defines a Cobra command for generating a domain event published and consumed through pubsub.
+// Usage: `event [domain] [EventName] [--publisher-only | --consumer-only] [--usecase name]`
+// - `domain`: The domain raising or consuming the event.
+// - `EventName`: The name of the event, e.g. OrderCreated.
+//
+// The files are generated in internal/<domain>/event: the payload type, a publisher helper, a
+// consumer dispatching messages to typed handlers and the handler skeleton, which is bound in
+// Consumer.registerHandlers. With --usecase the event executes the inport of a usecase of the
+// domain instead of a handler: a mapper from the payload to the request of the usecase is
+// generated, to register with rabbitmq_controller.MapInport. Existing files are never overwritten.
+var eventCmd = &cobra.Command{
+	Use:   "event [domain] [EventName]",
+	Short: "Generate a pubsub event payload, publisher and consumer handler",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		domain, rawName := args[0], args[1]
+		snakeName := util.SnakeCase(rawName)
+
+		publisherOnly, _ := cmd.Flags().GetBool("publisher-only")
+		consumerOnly, _ := cmd.Flags().GetBool("consumer-only")
+		if publisherOnly && consumerOnly {
+			return errors.New("--publisher-only and --consumer-only cannot be used together")
+		}
+		usecase, _ := cmd.Flags().GetString("usecase")
+		usecase = util.SnakeCase(usecase)
+		if publisherOnly && usecase != "" {
+			return errors.New("--publisher-only and --usecase cannot be used together")
+		}
+
+		tpl, err := loadEventTemplates()
+		if err != nil {
+			return err
+		}
+
+		data := eventData{
+			Domain: domain,
+			Event:  toCamelCase(snakeName),
+			Name:   fmt.Sprintf("%s.%s", util.SnakeCase(domain), snakeName),
+		}
+		if usecase != "" {
+			if data.Module, err = modulePath(); err != nil {
+				return err
+			}
+			data.Usecase, data.UsecaseType = usecase, toCamelCase(usecase)
+		}
+
+		dir := filepath.Join("internal", domain, "event")
+
+		for _, f := range eventFiles(dir, snakeName, usecase, !consumerOnly, !publisherOnly) {
+			if _, err := os.Stat(f.path); err == nil {
+				fmt.Fprintf(cmd.OutOrStdout(), "⏭️  %s already exists, skipped\n", f.path)
+				continue
+			}
+			if err := writeGoTemplate(tpl, f.template, f.path, data); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "✅ Generated %s\n", f.path)
+		}
+
+		if publisherOnly {
+			return nil
+		}
+
+		if usecase != "" {
+			register := fmt.Sprintf("consumer.AddUsecase(%[1]s.NewUsecase(nil))\n\tconsumer.Handle(event.%[2]sName, rabbitmq_controller.MapInport[%[1]s.InportRequest, %[1]s.InportResponse](consumer, event.Map%[2]sTo%[3]s))",
+				usecase, data.Event, data.UsecaseType)
+			fmt.Fprintf(cmd.OutOrStdout(), "⚠️  Register the usecase and the event in the consumer controller:\n\n\t%s\n\n", register)
+			return nil
+		}
+
+		consumer, err := parseGoFile(filepath.Join(dir, "consumer.go"))
+		if err != nil {
+			return err
+		}
+
+		bind := fmt.Sprintf("c.bind(%sName, typed(handle%s))", data.Event, data.Event)
+		registered, err := consumer.appendStmt("registerHandlers", bind)
+		if err != nil {
+			return err
+		}
+		if !registered {
+			fmt.Fprintf(cmd.OutOrStdout(), "⚠️  Bind the handler in the consumer manually:\n\n\t%s\n\n", bind)
+			return nil
+		}
+		if err = consumer.save(); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "✅ Bound %s to handle%s\n", data.Name, data.Event)
+		return nil
+	},
+}
+
+// init adds the `eventCmd` to the root command.
+func init() {
+	eventCmd.Flags().Bool("publisher-only", false, "generate the payload and the publisher only")
+	eventCmd.Flags().Bool("consumer-only", false, "generate the payload and the consumer handler only")
+	eventCmd.Flags().String("usecase", "", "execute the inport of this usecase of the domain instead of a handler")
+	rootCmd.AddCommand(eventCmd)
+}
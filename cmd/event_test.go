@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventCmd(t *testing.T) {
+
+	dir := newTestProject(t)
+	eventDir := filepath.Join(dir, "internal", "shop", "event")
+
+	require.NoError(t, runCommand(t, "event", "shop", "OrderCreated"))
+	require.NoError(t, runCommand(t, "event", "shop", "order_paid"))
+
+	payload, err := os.ReadFile(filepath.Join(eventDir, "order_created.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(payload), `const OrderCreatedName = "shop.order_created"`)
+	assert.Contains(t, string(payload), "type OrderCreated struct")
+	assert.Contains(t, string(payload), "`json:\"occurred_at\"`")
+
+	publisher, err := os.ReadFile(filepath.Join(eventDir, "order_created_publisher.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(publisher), "func PublishOrderCreated(e *pubsub.Event, payload OrderCreated) error")
+
+	handler, err := os.ReadFile(filepath.Join(eventDir, "order_paid_handler.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(handler), "func handleOrderPaid(ctx context.Context, payload OrderPaid) error")
+
+	consumer, err := os.ReadFile(filepath.Join(eventDir, "consumer.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(consumer), "c.bind(OrderCreatedName, typed(handleOrderCreated))")
+	assert.Contains(t, string(consumer), "c.bind(OrderPaidName, typed(handleOrderPaid))")
+	assert.Contains(t, string(consumer), "func (c *Consumer) Register(ctrl *rabbitmq_controller.Controller)")
+
+	// running the command again keeps the files and binds the handler once
+	require.NoError(t, runCommand(t, "event", "shop", "OrderCreated"))
+	again, err := os.ReadFile(filepath.Join(eventDir, "consumer.go"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(again), "typed(handleOrderCreated)"))
+
+	assertGoFilesCompile(t, dir)
+	buildWithFramework(t, dir, "./internal/shop/event")
+}
+
+func TestEventCmdOnly(t *testing.T) {
+
+	t.Run("publisher only", func(t *testing.T) {
+		dir := newTestProject(t)
+		require.NoError(t, runCommand(t, "event", "shop", "OrderCreated", "--publisher-only"))
+
+		eventDir := filepath.Join(dir, "internal", "shop", "event")
+		assert.FileExists(t, filepath.Join(eventDir, "order_created_publisher.go"))
+		assert.NoFileExists(t, filepath.Join(eventDir, "consumer.go"))
+		assert.NoFileExists(t, filepath.Join(eventDir, "order_created_handler.go"))
+		buildWithFramework(t, dir, "./internal/shop/event")
+	})
+
+	t.Run("consumer only", func(t *testing.T) {
+		dir := newTestProject(t)
+		require.NoError(t, runCommand(t, "event", "billing", "OrderCreated", "--consumer-only"))
+
+		eventDir := filepath.Join(dir, "internal", "billing", "event")
+		assert.NoFileExists(t, filepath.Join(eventDir, "order_created_publisher.go"))
+		assert.FileExists(t, filepath.Join(eventDir, "order_created_handler.go"))
+		buildWithFramework(t, dir, "./internal/billing/event")
+	})
+
+	t.Run("both", func(t *testing.T) {
+		newTestProject(t)
+		assert.Error(t, runCommand(t, "event", "shop", "OrderCreated", "--publisher-only", "--consumer-only"))
+		assert.Error(t, runCommand(t, "event", "shop", "OrderCreated", "--publisher-only", "--usecase", "reserve_stock"))
+	})
+}
+
+func TestEventCmdUsecase(t *testing.T) {
+
+	dir := newTestProject(t)
+	eventDir := filepath.Join(dir, "internal", "warehouse", "event")
+
+	require.NoError(t, runCommand(t, "usecase", "warehouse", "reserve_stock"))
+	require.NoError(t, runCommand(t, "event", "warehouse", "OrderPlaced", "--consumer-only", "--usecase", "ReserveStock"))
+
+	mapper, err := os.ReadFile(filepath.Join(eventDir, "order_placed_to_reserve_stock.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(mapper), `"example.com/app/internal/warehouse/usecase/reserve_stock"`)
+	assert.Contains(t, string(mapper), "func MapOrderPlacedToReserveStock(ctx context.Context, payload OrderPlaced) (reserve_stock.InportRequest, error)")
+
+	assert.NoFileExists(t, filepath.Join(eventDir, "order_placed_handler.go"), "the usecase replaces the handler")
+	assert.NoFileExists(t, filepath.Join(eventDir, "consumer.go"))
+
+	buildWithFramework(t, dir, "./internal/warehouse/...")
+}
+
+func TestEventTemplatesExecute(t *testing.T) {
+	tpl, err := loadEventTemplates()
+	require.NoError(t, err)
+
+	data := eventData{Domain: "shop", Event: "OrderCreated", Name: "shop.order_created", Module: "example.com/app", Usecase: "reserve_stock", UsecaseType: "ReserveStock"}
+	for _, f := range append(eventFiles("event", "order_created", "", true, true), eventFiles("event", "order_created", "reserve_stock", true, true)...) {
+		_, err := renderGoTemplate(tpl.Option("missingkey=error"), f.template, data)
+		assert.NoError(t, err, f.template)
+	}
+}
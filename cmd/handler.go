@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"embed"
+	"fmt"
+	"github.com/a-aslani/wotop/util"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+)
+
+// Embed the templates for handler generation.
+//
+//go:embed templates/handler/*.tmpl
+var handlerFS embed.FS
+
+// loadHandlerTemplates loads and parses the embedded handler templates.
+func loadHandlerTemplates() (*template.Template, error) {
+	sub, err := fs.Sub(handlerFS, "templates/handler")
+	if err != nil {
+		return nil, err
+	}
+	return template.ParseFS(sub, "*.tmpl")
+}
+
+// findRouterFile returns the router.go of the HTTP controller serving the domain. A controller
+// inside the domain, internal/<domain>/controller/http, takes precedence over the shared one in
+// internal/controller/http. An empty string is returned when neither exists.
+func findRouterFile(domain string) string {
+	for _, dir := range []string{
+		filepath.Join("internal", domain, "controller", "http"),
+		filepath.Join("internal", "controller", "http"),
+	} {
+		if _, err := os.Stat(filepath.Join(dir, "router.go")); err == nil {
+			return filepath.Join(dir, "router.go")
+		}
+	}
+	return ""
+}
+
+// handlerData holds what the handler template and the route registration need.
+type handlerData struct {
+	Module  string
+	Domain  string
+	Package string
+	Handler string
+	Method  string
+	Path    string
+	Bind    string
+}
+
+// newHandlerData validates the method and fills the defaults of a handler for the usecase.
+func newHandlerData(module, domain, snakeName, method, path string) (handlerData, error) {
+	method = strings.ToUpper(strings.TrimSpace(method))
+	switch method {
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		return handlerData{}, fmt.Errorf("unsupported method %q", method)
+	}
+
+	if path == "" {
+		path = "/" + strings.ReplaceAll(snakeName, "_", "-")
+	}
+
+	// Query parameters are bound for methods without a body.
+	bind := "ShouldBindJSON"
+	if method == http.MethodGet || method == http.MethodDelete {
+		bind = "ShouldBindQuery"
+	}
+
+	handler := toCamelCase(snakeName)
+	handler = strings.ToLower(handler[:1]) + handler[1:] + "Handler"
+
+	return handlerData{
+		Module:  module,
+		Domain:  domain,
+		Package: snakeName,
+		Handler: handler,
+		Method:  method,
+		Path:    path,
+		Bind:    bind,
+	}, nil
+}
+
+// route returns the statement registering the handler in RegisterRouter. A documented route is
+// registered with the openapi DSL of the controller, r.api, so it is listed in /openapi.json.
+func (h handlerData) route(documented bool) string {
+	if !documented {
+		return fmt.Sprintf("r.Router.%s(%q, r.%s())", h.Method, h.Path, h.Handler)
+	}
+
+	method := "http.Method" + h.Method[:1] + strings.ToLower(h.Method[1:])
+	summary := strings.ReplaceAll(h.Package, "_", " ")
+	summary = strings.ToUpper(summary[:1]) + summary[1:]
+
+	return fmt.Sprintf("r.api.Route(%s, %q).Summary(%q).Request(%s.InportRequest{}).Response(%s.InportResponse{}).Handle(r.%s())",
+		method, h.Path, summary, h.Package, h.Package, h.Handler)
+}
+
+// appendRoute appends the route of the handler to RegisterRouter in the router file. The
+// controllers generated before the openapi DSL have no r.api, their routes are registered on
+// Gin only.
+//
+// It returns the statement and whether RegisterRouter was found.
+func appendRoute(router *goFile, h handlerData) (string, bool, error) {
+	documented := router.usesSelector("r", "api")
+	route := h.route(documented)
+
+	found, err := router.appendStmt("RegisterRouter", route)
+	if err != nil || !found {
+		return route, found, err
+	}
+
+	if documented {
+		router.addImport("net/http")
+		router.addImport(h.usecaseImport())
+	}
+	return route, true, nil
+}
+
+// usecaseImport returns the import path of the usecase package of the handler.
+func (h handlerData) usecaseImport() string {
+	return h.Module + "/internal/" + h.Domain + "/usecase/" + h.Package
+}
+
+// handlerCmd defines a Cobra command for generating a Gin handler for an existing usecase.
+// Usage: `handler [domain] [usecase] --method POST --path /orders`
+// - `domain`: The domain name the usecase belongs to.
+// - `usecase`: The name of the usecase generated with the `usecase` command.
+//
+// The handler is generated next to the router.go of the controller serving the domain and the
+// route is appended to RegisterRouter, or printed when no router.go can be found. The route is
+// registered with the openapi DSL when the controller has one, so it is documented with the
+// request and response types of the usecase.
+var handlerCmd = &cobra.Command{
+	Use:   "handler [domain] [usecase]",
+	Short: "Generate a Gin handler scaffold for a usecase",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		domain, rawName := args[0], args[1]
+		snakeName := util.SnakeCase(rawName)
+
+		method, _ := cmd.Flags().GetString("method")
+		path, _ := cmd.Flags().GetString("path")
+
+		module, err := modulePath()
+		if err != nil {
+			return err
+		}
+
+		usecaseDir := filepath.Join("internal", domain, "usecase", snakeName)
+		if _, err := os.Stat(usecaseDir); err != nil {
+			return fmt.Errorf("usecase %s not found, generate it first with `wotop usecase %s %s`", usecaseDir, domain, rawName)
+		}
+
+		tpl, err := loadHandlerTemplates()
+		if err != nil {
+			return err
+		}
+
+		data, err := newHandlerData(module, domain, snakeName, method, path)
+		if err != nil {
+			return err
+		}
+
+		// The handler lives next to the router, or in the shared controller when there is none yet.
+		routerPath := findRouterFile(domain)
+		controllerDir := filepath.Join("internal", "controller", "http")
+		if routerPath != "" {
+			controllerDir = filepath.Dir(routerPath)
+		}
+
+		outPath := filepath.Join(controllerDir, "handler_"+snakeName+".go")
+		if err := writeGoTemplate(tpl, "handler.tmpl", outPath, data); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Generated handler at %s\n", outPath)
+
+		route := data.route(false)
+
+		registered := false
+		if routerPath != "" {
+			router, err := parseGoFile(routerPath)
+			if err != nil {
+				return err
+			}
+			if route, registered, err = appendRoute(router, data); err != nil {
+				return err
+			}
+			if err = router.save(); err != nil {
+				return err
+			}
+		}
+
+		if !registered {
+			fmt.Printf("⚠️  Add the route to RegisterRouter manually:\n\n\t%s\n\n", route)
+			return nil
+		}
+
+		fmt.Printf("✅ Registered route %s %s\n", data.Method, data.Path)
+		return nil
+	},
+}
+
+// init adds the `handlerCmd` to the root command.
+func init() {
+	handlerCmd.Flags().String("method", http.MethodPost, "HTTP method of the route")
+	handlerCmd.Flags().String("path", "", "path of the route, defaults to the kebab-case usecase name")
+	rootCmd.AddCommand(handlerCmd)
+}
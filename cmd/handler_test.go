@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerCmd(t *testing.T) {
+
+	dir := newTestProject(t)
+	require.NoError(t, runCommand(t, "usecase", "shop", "createOrder"))
+
+	routerPath := filepath.Join(dir, "internal", "controller", "http", "router.go")
+	require.NoError(t, os.MkdirAll(filepath.Dir(routerPath), 0755))
+	require.NoError(t, os.WriteFile(routerPath, []byte("package http\n\nfunc (r *controller) RegisterRouter() {\n}\n"), 0644))
+
+	require.NoError(t, runCommand(t, "handler", "shop", "createOrder", "--method", "put", "--path", "/orders"))
+
+	src, err := os.ReadFile(filepath.Join(dir, "internal", "controller", "http", "handler_create_order.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(src), "func (r *controller) createOrderHandler() gin.HandlerFunc")
+	assert.Contains(t, string(src), `"example.com/app/internal/shop/usecase/create_order"`)
+	assert.Contains(t, string(src), "c.ShouldBindJSON(&req)")
+
+	router, err := os.ReadFile(routerPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(router), `r.Router.PUT("/orders", r.createOrderHandler())`)
+
+	// running the command again must not register the route twice
+	require.NoError(t, runCommand(t, "handler", "shop", "createOrder", "--method", "put", "--path", "/orders"))
+	again, err := os.ReadFile(routerPath)
+	require.NoError(t, err)
+	assert.Equal(t, string(router), string(again))
+
+	assertGoFilesCompile(t, dir)
+
+	assert.Error(t, runCommand(t, "handler", "shop", "missing"))
+}
+
+func TestHandlerCmdDocumentedRoute(t *testing.T) {
+
+	dir := newTestProject(t)
+	require.NoError(t, runCommand(t, "usecase", "shop", "createOrder"))
+
+	routerPath := filepath.Join(dir, "internal", "controller", "http", "router.go")
+	require.NoError(t, os.MkdirAll(filepath.Dir(routerPath), 0755))
+	require.NoError(t, os.WriteFile(routerPath, []byte("package http\n\nfunc (r *controller) RegisterRouter() {\n\tr.api.API()\n}\n"), 0644))
+
+	require.NoError(t, runCommand(t, "handler", "shop", "createOrder", "--method", "put", "--path", "/orders"))
+
+	router, err := os.ReadFile(routerPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(router), `r.api.Route(http.MethodPut, "/orders").Summary("Create order").Request(create_order.InportRequest{}).Response(create_order.InportResponse{}).Handle(r.createOrderHandler())`)
+	assert.Contains(t, string(router), `"net/http"`)
+	assert.Contains(t, string(router), `"example.com/app/internal/shop/usecase/create_order"`)
+
+	// running the command again must not register the route twice
+	require.NoError(t, runCommand(t, "handler", "shop", "createOrder", "--method", "put", "--path", "/orders"))
+	again, err := os.ReadFile(routerPath)
+	require.NoError(t, err)
+	assert.Equal(t, string(router), string(again))
+
+	assertGoFilesCompile(t, dir)
+}
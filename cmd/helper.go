@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// modulePath reads the module path from the go.mod file in the current directory.
+// Generated files use it to import the packages of the project they are generated into.
+func modulePath() (string, error) {
+	f, err := os.Open("go.mod")
+	if err != nil {
+		return "", fmt.Errorf("go.mod not found, run the command from the project root: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "module ") {
+			return strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "module")), `"`), nil
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return "", err
+	}
+
+	return "", errors.New("module directive not found in go.mod")
+}
+
+// renderGoTemplate executes the template and formats the result with gofmt.
+// Formatting fails on invalid Go code, so a broken template never produces a file.
+func renderGoTemplate(tpl *template.Template, name string, data any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tpl.ExecuteTemplate(&buf, name, data); err != nil {
+		return nil, err
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated code of %s is invalid: %w", name, err)
+	}
+
+	return src, nil
+}
+
+// writeGoTemplate renders the template with renderGoTemplate and writes it to outPath.
+func writeGoTemplate(tpl *template.Template, name, outPath string, data any) error {
+	src, err := renderGoTemplate(tpl, name, data)
+	if err != nil {
+		return err
+	}
+	return writeFile(outPath, src)
+}
+
+// writeFile writes content to outPath, creating the parent directories when needed.
+func writeFile(outPath string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(outPath, content, 0644)
+}
+
+// writeTemplate executes the template and writes the result to outPath as it is.
+func writeTemplate(tpl *template.Template, name, outPath string, data any) error {
+	var buf bytes.Buffer
+	if err := tpl.ExecuteTemplate(&buf, name, data); err != nil {
+		return err
+	}
+
+	return writeFile(outPath, buf.Bytes())
+}
@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/a-aslani/wotop/jwt"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// jwtCmd groups the token commands.
+var jwtCmd = &cobra.Command{
+	Use:   "jwt",
+	Short: "Inspect the tokens issued by the jwt package",
+}
+
+// jwtInspectCmd decodes a token offline and prints its header, claims and validity window.
+// Usage: `jwt inspect <token|-> --key secret --key-file key.pub --config config.yaml --json`
+// - `token`: The access, refresh or action token, "-" to read it from stdin so it stays out of the shell history.
+//
+// The signature is checked when a key is given by --key, --key-file or the jwt.secret_key
+// of the config file, and is never printed.
+var jwtInspectCmd = &cobra.Command{
+	Use:   "inspect <token|->",
+	Short: "Decode a token offline and check its signature",
+	Args:  cobra.ExactArgs(1),
+	// a token failing the check is the output, the usage would only bury it
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		token := args[0]
+		if token == "-" {
+			in, err := io.ReadAll(cmd.InOrStdin())
+			if err != nil {
+				return err
+			}
+			token = string(in)
+		}
+
+		key, err := inspectKey(cmd)
+		if err != nil {
+			return err
+		}
+
+		report, err := jwt.DebugWithKey(token, key)
+		if err != nil {
+			return err
+		}
+
+		if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+			out, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), string(out))
+		} else if err = printTokenReport(cmd.OutOrStdout(), report, len(key) > 0); err != nil {
+			return err
+		}
+
+		if report.VerifyError != "" {
+			return fmt.Errorf("the signature is not valid: %s", report.VerifyError)
+		}
+		return nil
+	},
+}
+
+// inspectKey returns the key of --key, else of --key-file, else the jwt.secret_key of the
+// config file, which the JWT_SECRET_KEY environment variable overrides. It is empty when none
+// is given, the signature is not checked then.
+func inspectKey(cmd *cobra.Command) ([]byte, error) {
+	if key, _ := cmd.Flags().GetString("key"); key != "" {
+		return []byte(key), nil
+	}
+
+	if file, _ := cmd.Flags().GetString("key-file"); file != "" {
+		key, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read the key file: %w", err)
+		}
+		return bytes.TrimSpace(key), nil
+	}
+
+	// the default config file is optional, an explicit one must exist
+	configFile, _ := cmd.Flags().GetString("config")
+	if _, err := os.Stat(configFile); errors.Is(err, os.ErrNotExist) && !cmd.Flags().Changed("config") {
+		return []byte(os.Getenv("JWT_SECRET_KEY")), nil
+	}
+
+	v := viper.New()
+	v.SetConfigFile(configFile)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("cannot read config %q, pass it with --config: %w", configFile, err)
+	}
+
+	return []byte(v.GetString("jwt.secret_key")), nil
+}
+
+// printTokenReport prints a report as a table, the claims in order.
+func printTokenReport(out io.Writer, r jwt.TokenReport, checked bool) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(w, "KIND\t%s\n", r.Kind)
+	fmt.Fprintf(w, "ALGORITHM\t%s\n", r.Algorithm)
+	if r.KeyID != "" {
+		fmt.Fprintf(w, "KEY ID\t%s\n", r.KeyID)
+	}
+
+	for _, t := range []struct {
+		name string
+		at   time.Time
+	}{{"ISSUED AT", r.IssuedAt}, {"NOT BEFORE", r.NotBefore}, {"AUTH TIME", r.AuthTime}, {"EXPIRES AT", r.ExpiresAt}} {
+		if !t.at.IsZero() {
+			fmt.Fprintf(w, "%s\t%s\n", t.name, t.at.UTC().Format(time.RFC3339))
+		}
+	}
+
+	switch {
+	case r.ExpiresAt.IsZero():
+		fmt.Fprintf(w, "VALIDITY\tno expiry\n")
+	case r.Expired:
+		fmt.Fprintf(w, "VALIDITY\texpired %s ago\n", (-r.ExpiresIn).Round(time.Second))
+	case r.NotYetValid:
+		fmt.Fprintf(w, "VALIDITY\tnot valid yet\n")
+	default:
+		fmt.Fprintf(w, "VALIDITY\texpires in %s\n", r.ExpiresIn.Round(time.Second))
+	}
+
+	switch {
+	case r.Verified:
+		fmt.Fprintf(w, "SIGNATURE\tverified\n")
+	case checked:
+		fmt.Fprintf(w, "SIGNATURE\tinvalid: %s\n", r.VerifyError)
+	default:
+		fmt.Fprintf(w, "SIGNATURE\tnot checked, pass --key, --key-file or --config\n")
+	}
+
+	if len(r.Missing) > 0 {
+		fmt.Fprintf(w, "MISSING\t%s\n", strings.Join(r.Missing, ", "))
+	}
+
+	names := make([]string, 0, len(r.Claims))
+	for name := range r.Claims {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "CLAIMS\t")
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s\t%v\n", name, r.Claims[name])
+	}
+
+	return w.Flush()
+}
+
+// init adds the jwt commands to the root command.
+func init() {
+	jwtInspectCmd.Flags().String("key", "", "HMAC secret the signature is checked with")
+	jwtInspectCmd.Flags().String("key-file", "", "file with the HMAC secret or the PEM public key of RS256")
+	jwtInspectCmd.Flags().String("config", "config.yaml", "config file whose jwt.secret_key checks the signature")
+	jwtInspectCmd.Flags().Bool("json", false, "print the report as JSON")
+
+	jwtCmd.AddCommand(jwtInspectCmd)
+	rootCmd.AddCommand(jwtCmd)
+}
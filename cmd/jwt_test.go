@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop/jwt"
+	"github.com/a-aslani/wotop/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJWTInspect(t *testing.T) {
+	newTestProject(t)
+
+	token, err := jwt.NewHS256JWT(context.Background(), "secret", jwt.NewMemoryRepository(util.SystemClock), time.Hour, time.Minute)
+	require.NoError(t, err)
+	access, _, _, _, err := token.GenerateToken(context.Background(), "user-1", "admin", "sub-1", "acme")
+	require.NoError(t, err)
+	signature := access[strings.LastIndex(access, ".")+1:]
+
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	t.Cleanup(func() { rootCmd.SetOut(nil) })
+
+	t.Run("without key", func(t *testing.T) {
+		out.Reset()
+		require.NoError(t, runCommand(t, "jwt", "inspect", access))
+		assert.Regexp(t, `KIND\s+access`, out.String())
+		assert.Regexp(t, `ALGORITHM\s+HS256`, out.String())
+		assert.Regexp(t, `VALIDITY\s+expires in`, out.String())
+		assert.Regexp(t, `SIGNATURE\s+not checked`, out.String())
+		assert.Regexp(t, `tenant\s+acme`, out.String())
+		assert.NotContains(t, out.String(), signature)
+	})
+
+	t.Run("key of the config", func(t *testing.T) {
+		require.NoError(t, os.WriteFile("config.yaml", []byte("jwt:\n  secret_key: secret\n"), 0644))
+		t.Cleanup(func() { _ = os.Remove("config.yaml") })
+
+		out.Reset()
+		require.NoError(t, runCommand(t, "jwt", "inspect", access))
+		assert.Regexp(t, `SIGNATURE\s+verified`, out.String())
+	})
+
+	t.Run("token from stdin", func(t *testing.T) {
+		rootCmd.SetIn(strings.NewReader(access + "\n"))
+		t.Cleanup(func() { rootCmd.SetIn(nil) })
+
+		out.Reset()
+		require.NoError(t, runCommand(t, "jwt", "inspect", "-", "--key", "secret", "--json"))
+		assert.Contains(t, out.String(), `"verified": true`)
+		assert.NotContains(t, out.String(), signature)
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		out.Reset()
+		err := runCommand(t, "jwt", "inspect", access, "--key", "another")
+		assert.ErrorContains(t, err, "the signature is not valid")
+		assert.Regexp(t, `SIGNATURE\s+invalid`, out.String())
+	})
+
+	t.Run("malformed token", func(t *testing.T) {
+		err := runCommand(t, "jwt", "inspect", "garbage")
+		assert.ErrorContains(t, err, "cannot decode the token")
+	})
+}
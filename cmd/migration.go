@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/a-aslani/wotop/postgres_db"
+	"github.com/a-aslani/wotop/util"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// migrationNow returns the time used for new migration versions, replaced in tests.
+var migrationNow = time.Now
+
+// migrationNameRegex lists the characters replaced by "_" in migration names.
+var migrationNameRegex = regexp.MustCompile(`[^a-z0-9]+`)
+
+// migrationDatabase holds the connection settings read from the database section of the config file.
+type migrationDatabase struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	Name     string
+}
+
+// openMigrationDB connects to the database through postgres_db, replaced in tests.
+var openMigrationDB = func(cfg migrationDatabase) (*sql.DB, error) {
+	return postgres_db.New(cfg.Host, "postgres", cfg.Port, cfg.User, cfg.Password, cfg.Name, 0, 1, 1)
+}
+
+// loadMigrationDatabase reads the database section of the config file. Environment variables
+// such as DATABASE_PASSWORD override the values of the file.
+func loadMigrationDatabase(file string) (migrationDatabase, error) {
+	v := viper.New()
+	v.SetConfigFile(file)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	if err := v.ReadInConfig(); err != nil {
+		return migrationDatabase{}, fmt.Errorf("cannot read config %q, pass it with --config: %w", file, err)
+	}
+
+	var cfg migrationDatabase
+	for key, dst := range map[string]*string{
+		"database.host":     &cfg.Host,
+		"database.port":     &cfg.Port,
+		"database.user":     &cfg.User,
+		"database.password": &cfg.Password,
+		"database.name":     &cfg.Name,
+	} {
+		*dst = v.GetString(key)
+	}
+
+	if cfg.Host == "" || cfg.Name == "" {
+		return migrationDatabase{}, fmt.Errorf("config %q has no database.host or database.name", file)
+	}
+
+	return cfg, nil
+}
+
+// checkMigrationDir returns an actionable error when the migrations directory is missing.
+func checkMigrationDir(dir string) error {
+	info, err := os.Stat(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("migrations directory %q does not exist, create it or pass another one with --dir", dir)
+	}
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%q is not a directory", dir)
+	}
+	return nil
+}
+
+// migrationCmd groups the commands managing SQL migrations.
+var migrationCmd = &cobra.Command{
+	Use:   "migration",
+	Short: "Manage the SQL migrations applied by postgres_db.Migrate",
+}
+
+// migrationCreateCmd creates a pair of timestamped up and down SQL files.
+// Usage: `migration create [name] --dir db/migrations`
+var migrationCreateCmd = &cobra.Command{
+	Use:   "create [name]",
+	Short: "Create timestamped up and down migration files",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, _ := cmd.Flags().GetString("dir")
+
+		name := strings.Trim(migrationNameRegex.ReplaceAllString(util.SnakeCase(args[0]), "_"), "_")
+		if name == "" {
+			return fmt.Errorf("invalid migration name %q", args[0])
+		}
+
+		if err := checkMigrationDir(dir); err != nil {
+			return err
+		}
+
+		now := migrationNow()
+		version := now.UTC().Format(postgres_db.MigrationVersionLayout)
+
+		existing, err := filepath.Glob(filepath.Join(dir, version+"_*.sql"))
+		if err != nil {
+			return err
+		}
+		if len(existing) > 0 {
+			return fmt.Errorf("migration version %s is already used by %s, wait a second and run the command again", version, existing[0])
+		}
+
+		for _, direction := range []string{"up", "down"} {
+			path := filepath.Join(dir, fmt.Sprintf("%s_%s.%s.sql", version, name, direction))
+			header := fmt.Sprintf("-- Migration: %s (%s)\n-- Version: %s\n-- Created at: %s\n\n", name, direction, version, now.UTC().Format(time.RFC3339))
+
+			if err := os.WriteFile(path, []byte(header), 0644); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "✅ Created %s\n", path)
+		}
+
+		return nil
+	},
+}
+
+// migrationStatusCmd prints the applied and pending migrations.
+// Usage: `migration status --dir db/migrations --config config.yaml`
+var migrationStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Print the applied and pending migrations",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, _ := cmd.Flags().GetString("dir")
+		configFile, _ := cmd.Flags().GetString("config")
+
+		if err := checkMigrationDir(dir); err != nil {
+			return err
+		}
+
+		cfg, err := loadMigrationDatabase(configFile)
+		if err != nil {
+			return err
+		}
+
+		db, err := openMigrationDB(cfg)
+		if err != nil {
+			return fmt.Errorf("cannot connect to database %q at %s:%s: %w", cfg.Name, cfg.Host, cfg.Port, err)
+		}
+		defer db.Close()
+
+		status, err := postgres_db.MigrationsStatus(context.Background(), db, dir)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS")
+
+		pending := 0
+		for _, s := range status {
+			state := "applied"
+			if !s.Applied {
+				state = "pending"
+				pending++
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", s.Version, s.Name, state)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "\n%d applied, %d pending\n", len(status)-pending, pending)
+		return nil
+	},
+}
+
+// init adds the migration commands to the root command.
+func init() {
+	migrationCmd.PersistentFlags().String("dir", filepath.Join("db", "migrations"), "directory of the migration files")
+	migrationStatusCmd.Flags().String("config", "config.yaml", "config file with the database section")
+
+	migrationCmd.AddCommand(migrationCreateCmd, migrationStatusCmd)
+	rootCmd.AddCommand(migrationCmd)
+}
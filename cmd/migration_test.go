@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"bytes"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixMigrationNow freezes the clock used for migration versions.
+func fixMigrationNow(t *testing.T, now time.Time) {
+	t.Helper()
+	old := migrationNow
+	migrationNow = func() time.Time { return now }
+	t.Cleanup(func() { migrationNow = old })
+}
+
+func TestMigrationCreate(t *testing.T) {
+	dir := newTestProject(t)
+	require.NoError(t, os.MkdirAll(filepath.Join("db", "migrations"), 0755))
+
+	fixMigrationNow(t, time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	require.NoError(t, runCommand(t, "migration", "create", "Create Users-Table"))
+
+	up, err := os.ReadFile(filepath.Join(dir, "db", "migrations", "20250102030405_create_users_table.up.sql"))
+	require.NoError(t, err)
+	assert.Contains(t, string(up), "-- Migration: create_users_table (up)")
+
+	_, err = os.Stat(filepath.Join(dir, "db", "migrations", "20250102030405_create_users_table.down.sql"))
+	require.NoError(t, err)
+
+	t.Run("duplicate timestamp", func(t *testing.T) {
+		err := runCommand(t, "migration", "create", "add_email")
+		assert.ErrorContains(t, err, "migration version 20250102030405 is already used")
+	})
+
+	t.Run("ordering", func(t *testing.T) {
+		fixMigrationNow(t, time.Date(2025, 1, 2, 3, 4, 6, 0, time.UTC))
+		require.NoError(t, runCommand(t, "migration", "create", "add_email"))
+
+		entries, err := os.ReadDir(filepath.Join(dir, "db", "migrations"))
+		require.NoError(t, err)
+		require.Len(t, entries, 4)
+		assert.Equal(t, "20250102030405_create_users_table.down.sql", entries[0].Name())
+		assert.Equal(t, "20250102030406_add_email.up.sql", entries[3].Name())
+	})
+}
+
+func TestMigrationCreateMissingDir(t *testing.T) {
+	newTestProject(t)
+
+	err := runCommand(t, "migration", "create", "create_users")
+	assert.ErrorContains(t, err, "create it or pass another one with --dir")
+
+	require.NoError(t, os.Mkdir("sql", 0755))
+	assert.NoError(t, runCommand(t, "migration", "create", "create_users", "--dir", "sql"))
+}
+
+func TestMigrationStatus(t *testing.T) {
+	newTestProject(t)
+	require.NoError(t, os.MkdirAll(filepath.Join("db", "migrations"), 0755))
+	for _, name := range []string{"20250101000000_create_users.up.sql", "20250102000000_add_email.up.sql"} {
+		require.NoError(t, os.WriteFile(filepath.Join("db", "migrations", name), nil, 0644))
+	}
+	require.NoError(t, os.WriteFile("config.yaml", []byte("database:\n  host: localhost\n  port: 5432\n  user: postgres\n  password: secret\n  name: app\n"), 0644))
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	mock.ExpectQuery("SELECT version FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow("20250101000000"))
+
+	var got migrationDatabase
+	old := openMigrationDB
+	openMigrationDB = func(cfg migrationDatabase) (*sql.DB, error) {
+		got = cfg
+		return db, nil
+	}
+	t.Cleanup(func() { openMigrationDB = old })
+
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	t.Cleanup(func() { rootCmd.SetOut(nil) })
+
+	require.NoError(t, runCommand(t, "migration", "status"))
+	assert.Equal(t, migrationDatabase{Host: "localhost", Port: "5432", User: "postgres", Password: "secret", Name: "app"}, got)
+	assert.Regexp(t, `20250101000000\s+create_users\s+applied`, out.String())
+	assert.Regexp(t, `20250102000000\s+add_email\s+pending`, out.String())
+	assert.Contains(t, out.String(), "1 applied, 1 pending")
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	t.Run("missing config", func(t *testing.T) {
+		err := runCommand(t, "migration", "status", "--config", "missing.yaml")
+		assert.ErrorContains(t, err, "pass it with --config")
+	})
+}
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"embed"
+	"fmt"
+	"go/ast"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+)
+
+// mockgenVersion is the mockgen release used when no mockgen binary is installed, it matches
+// the go.uber.org/mock version the framework depends on.
+const mockgenVersion = "go.uber.org/mock/mockgen@v0.5.1"
+
+// Embed the templates for mock generation.
+//
+//go:embed templates/mocks/*.tmpl
+var mocksFS embed.FS
+
+// loadMocksTemplates loads and parses the embedded mock helper templates.
+func loadMocksTemplates() (*template.Template, error) {
+	sub, err := fs.Sub(mocksFS, "templates/mocks")
+	if err != nil {
+		return nil, err
+	}
+	return template.ParseFS(sub, "*.tmpl")
+}
+
+// mockgenCommand returns the command running mockgen. An installed binary is preferred, then the
+// mockgen of the go.uber.org/mock version the project depends on, then mockgenVersion.
+func mockgenCommand(args ...string) *exec.Cmd {
+	if path, err := exec.LookPath("mockgen"); err == nil {
+		return exec.Command(path, args...)
+	}
+	if dependsOnGomock() {
+		return exec.Command("go", append([]string{"run", "go.uber.org/mock/mockgen"}, args...)...)
+	}
+	return exec.Command("go", append([]string{"run", mockgenVersion}, args...)...)
+}
+
+// dependsOnGomock reports whether the go.mod of the project requires go.uber.org/mock.
+func dependsOnGomock() bool {
+	src, err := os.ReadFile("go.mod")
+	return err == nil && strings.Contains(string(src), "go.uber.org/mock ")
+}
+
+// interfaceNames returns the names of the interfaces declared in the Go file, sorted.
+func interfaceNames(path string) ([]string, error) {
+	f, err := parseGoFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	ast.Inspect(f.file, func(n ast.Node) bool {
+		if ts, ok := n.(*ast.TypeSpec); ok {
+			if _, ok := ts.Type.(*ast.InterfaceType); ok && ts.Name.IsExported() {
+				names = append(names, ts.Name.Name)
+			}
+		}
+		return true
+	})
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// mocksCmd defines a Cobra command for generating gomock mocks of the outports of a domain.
+// Usage: `mocks [domain]`
+// - `domain`: The domain whose usecases are scanned.
+//
+// For every internal/<domain>/usecase/<name>/outport.go the mocks are generated into the
+// internal/<domain>/usecase/<name>/mocks package, together with a helper exposing typed
+// constructors that create the gomock controller from the test.
+var mocksCmd = &cobra.Command{
+	Use:   "mocks [domain]",
+	Short: "Generate gomock mocks for the outports of a domain",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		domain := args[0]
+
+		module, err := modulePath()
+		if err != nil {
+			return err
+		}
+
+		outports, err := filepath.Glob(filepath.Join("internal", domain, "usecase", "*", "outport.go"))
+		if err != nil {
+			return err
+		}
+		if len(outports) == 0 {
+			return fmt.Errorf("no outport.go found in internal/%s/usecase", domain)
+		}
+
+		tpl, err := loadMocksTemplates()
+		if err != nil {
+			return err
+		}
+
+		for _, outport := range outports {
+			usecaseDir := filepath.Dir(outport)
+			mocksDir := filepath.Join(usecaseDir, "mocks")
+
+			names, err := interfaceNames(outport)
+			if err != nil {
+				return err
+			}
+			if len(names) == 0 {
+				fmt.Printf("⚠️  No interface found in %s, skipping\n", outport)
+				continue
+			}
+
+			// Embedded interfaces of other packages are resolved through the import path of the outport package.
+			mockgen := mockgenCommand(
+				"-source", outport,
+				"-destination", filepath.Join(mocksDir, "outport_mock.go"),
+				"-package", "mocks",
+				"-self_package", module+"/"+filepath.ToSlash(mocksDir),
+			)
+			mockgen.Stdout, mockgen.Stderr = os.Stdout, os.Stderr
+			if err := mockgen.Run(); err != nil {
+				return fmt.Errorf("mockgen failed for %s: %w", outport, err)
+			}
+
+			helperPath := filepath.Join(mocksDir, "helper.go")
+			if err := writeGoTemplate(tpl, "helper.tmpl", helperPath, struct{ Interfaces []string }{names}); err != nil {
+				return err
+			}
+
+			fmt.Printf("✅ Generated mocks for %s in %s\n", strings.Join(names, ", "), mocksDir)
+		}
+
+		// The generated code imports gomock, which the project may not depend on yet.
+		if !dependsOnGomock() {
+			fmt.Printf("⚠️  Run `go get go.uber.org/mock` to add gomock to the project dependencies\n")
+		}
+
+		return nil
+	},
+}
+
+// init adds the `mocksCmd` to the root command.
+func init() {
+	rootCmd.AddCommand(mocksCmd)
+}
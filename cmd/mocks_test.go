@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMocksCmd(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs mockgen and builds the generated mocks")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go tool not found")
+	}
+
+	t.Setenv("GOPROXY", "off")
+	t.Setenv("GOSUMDB", "off")
+	t.Setenv("GOFLAGS", "-mod=mod")
+	t.Setenv("GOWORK", "off")
+
+	repoRoot, err := filepath.Abs("..")
+	require.NoError(t, err)
+
+	dir := newTestProject(t)
+	// x/tools is pinned to the version of the framework, older ones do not build with recent toolchains
+	require.NoError(t, os.WriteFile("go.mod", []byte("module example.com/app\n\ngo 1.24\n\nrequire (\n\tgo.uber.org/mock v0.5.1\n\tgolang.org/x/tools v0.31.0 // indirect\n)\n\nreplace github.com/a-aslani/wotop => "+repoRoot+"\n"), 0644))
+
+	require.NoError(t, writeFile(filepath.Join("internal", "shop", "model", "repository", "order.go"), []byte(`package repository
+
+import "context"
+
+type SaveOrderRepo interface {
+	SaveOrder(ctx context.Context, id string) error
+}
+`)))
+	require.NoError(t, writeFile(filepath.Join("internal", "shop", "usecase", "create_order", "outport.go"), []byte(`package create_order
+
+import "example.com/app/internal/shop/model/repository"
+
+type Outport interface {
+	repository.SaveOrderRepo
+	GenerateID() string
+}
+`)))
+	require.NoError(t, runCommand(t, "usecase", "shop", "listOrders"))
+
+	if err := runCommand(t, "mocks", "shop"); err != nil {
+		t.Skipf("mockgen is not available offline: %v", err)
+	}
+
+	for _, uc := range []string{"create_order", "list_orders"} {
+		assert.FileExists(t, filepath.Join(dir, "internal", "shop", "usecase", uc, "mocks", "outport_mock.go"))
+		assert.FileExists(t, filepath.Join(dir, "internal", "shop", "usecase", uc, "mocks", "helper.go"))
+	}
+
+	mock, err := os.ReadFile(filepath.Join(dir, "internal", "shop", "usecase", "create_order", "mocks", "outport_mock.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(mock), "func (m *MockOutport) SaveOrder(")
+
+	// the typed constructor must be usable from a test
+	require.NoError(t, writeFile(filepath.Join("internal", "shop", "usecase", "create_order", "interactor_test.go"), []byte(`package create_order
+
+import (
+	"testing"
+
+	"example.com/app/internal/shop/usecase/create_order/mocks"
+)
+
+func TestOutportMock(t *testing.T) {
+	outport := mocks.NewOutport(t)
+	outport.EXPECT().GenerateID().Return("1")
+	if outport.GenerateID() != "1" {
+		t.Fail()
+	}
+}
+`)))
+
+	test := exec.Command("go", "test", "./...")
+	test.Dir = dir
+	out, err := test.CombinedOutput()
+	require.NoError(t, err, string(out))
+}
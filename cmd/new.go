@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"embed"
+	"fmt"
+	"github.com/a-aslani/wotop/util"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+)
+
+// Embed the templates for project generation.
+//
+//go:embed templates/project/*.tmpl
+var projectFS embed.FS
+
+// loadProjectTemplates loads and parses the embedded project templates.
+func loadProjectTemplates() (*template.Template, error) {
+	sub, err := fs.Sub(projectFS, "templates/project")
+	if err != nil {
+		return nil, err
+	}
+	return template.ParseFS(sub, "*.tmpl")
+}
+
+// newCmd defines a Cobra command for bootstrapping a new project.
+// Usage: `new [module-path] [app-name]`
+// - `module-path`: The Go module path of the project, e.g. github.com/acme/shop.
+// - `app-name`: The name of the first application, used for the runner and its server config.
+//
+// The project is created in a directory named after the last element of the module path and
+// contains a runnable HTTP application with a sample `hello` usecase.
+var newCmd = &cobra.Command{
+	Use:   "new [module-path] [app-name]",
+	Short: "Generate a new project skeleton",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		module, app := args[0], util.SnakeCase(args[1])
+
+		withRabbitMQ, _ := cmd.Flags().GetBool("with-rabbitmq")
+		withPostgres, _ := cmd.Flags().GetBool("with-postgres")
+		withJWT, _ := cmd.Flags().GetBool("with-jwt")
+		skipTidy, _ := cmd.Flags().GetBool("skip-tidy")
+		replace, _ := cmd.Flags().GetString("replace")
+
+		destDir := path.Base(module)
+		if _, err := os.Stat(destDir); err == nil {
+			return fmt.Errorf("directory %s already exists", destDir)
+		}
+
+		if replace != "" {
+			abs, err := filepath.Abs(replace)
+			if err != nil {
+				return err
+			}
+			replace = abs
+		}
+
+		tpl, err := loadProjectTemplates()
+		if err != nil {
+			return err
+		}
+
+		configTpl, err := loadConfigTemplates()
+		if err != nil {
+			return err
+		}
+
+		handlerTpl, err := loadHandlerTemplates()
+		if err != nil {
+			return err
+		}
+
+		// Define the data to pass to the templates.
+		data := struct {
+			Module       string
+			App          string
+			AppCamel     string
+			WithRabbitMQ bool
+			WithPostgres bool
+			WithJWT      bool
+			JWTSecret    string
+			Replace      string
+		}{
+			Module:       module,
+			App:          app,
+			AppCamel:     toCamelCase(app),
+			WithRabbitMQ: withRabbitMQ,
+			WithPostgres: withPostgres,
+			WithJWT:      withJWT,
+			JWTSecret:    util.GenerateID(32),
+			Replace:      replace,
+		}
+
+		httpDir := filepath.Join("internal", "controller", "http")
+		helloDir := filepath.Join("internal", "sample", "usecase", "hello")
+
+		files := []struct {
+			tmplName string
+			outPath  string
+		}{
+			{"go.mod.tmpl", "go.mod"},
+			{"config.yaml.tmpl", "config.yaml"},
+			{"env.tmpl", ".env"},
+			{"gitignore.tmpl", ".gitignore"},
+			{"main.go.tmpl", "main.go"},
+			{"app.go.tmpl", filepath.Join("cmd", app+".go")},
+			{"controller.go.tmpl", filepath.Join(httpDir, "controller.go")},
+			{"metrics.go.tmpl", filepath.Join(httpDir, "metrics.go")},
+			{"router.go.tmpl", filepath.Join(httpDir, "router.go")},
+			{"hello_inport.go.tmpl", filepath.Join(helloDir, "inport.go")},
+			{"hello_outport.go.tmpl", filepath.Join(helloDir, "outport.go")},
+			{"hello_interactor.go.tmpl", filepath.Join(helloDir, "interactor.go")},
+		}
+
+		for _, f := range files {
+			outPath := filepath.Join(destDir, f.outPath)
+			if strings.HasSuffix(outPath, ".go") {
+				err = writeGoTemplate(tpl, f.tmplName, outPath, data)
+			} else {
+				err = writeTemplate(tpl, f.tmplName, outPath, data)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		// The configs package is produced by the same templates as the `config init` command.
+		for name, outPath := range map[string]string{
+			"config.go.tmpl": filepath.Join(destDir, "configs", "config.go"),
+			"data.go.tmpl":   filepath.Join(destDir, "configs", "data.go"),
+		} {
+			if err = writeGoTemplate(configTpl, name, outPath, data); err != nil {
+				return err
+			}
+		}
+		if err = writeEnvExample(filepath.Join(destDir, "configs", "data.go"), app, filepath.Join(destDir, ".env.example")); err != nil {
+			return err
+		}
+
+		// The sample handler is produced by the same template as the `handler` command.
+		hello, err := newHandlerData(module, "sample", "hello", "GET", "/v1/hello")
+		if err != nil {
+			return err
+		}
+		if err = writeGoTemplate(handlerTpl, "handler.tmpl", filepath.Join(destDir, httpDir, "handler_hello.go"), hello); err != nil {
+			return err
+		}
+
+		fmt.Printf("✅ Generated project %s in %s\n", module, destDir)
+
+		if skipTidy {
+			fmt.Printf("Run `go mod tidy` in %s to resolve the dependencies\n", destDir)
+			return nil
+		}
+
+		tidy := exec.Command("go", "mod", "tidy")
+		tidy.Dir = destDir
+		tidy.Stdout, tidy.Stderr = os.Stdout, os.Stderr
+		if err := tidy.Run(); err != nil {
+			return fmt.Errorf("go mod tidy failed: %w", err)
+		}
+
+		fmt.Printf("✅ Resolved dependencies, run it with `cd %s && go run main.go %s`\n", destDir, app)
+		return nil
+	},
+}
+
+// init adds the `newCmd` to the root command.
+func init() {
+	newCmd.Flags().Bool("with-rabbitmq", false, "wire a RabbitMQ connection")
+	newCmd.Flags().Bool("with-postgres", false, "wire a Postgres connection")
+	newCmd.Flags().Bool("with-jwt", false, "wire JWT token handling backed by Redis")
+	newCmd.Flags().Bool("skip-tidy", false, "do not run `go mod tidy` after generating the project")
+	newCmd.Flags().String("replace", "", "local path of the wotop module, added as a replace directive")
+	_ = newCmd.Flags().MarkHidden("replace")
+	rootCmd.AddCommand(newCmd)
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCmd(t *testing.T) {
+	if testing.Short() {
+		t.Skip("resolves and vets a whole project")
+	}
+
+	repoRoot, err := filepath.Abs("..")
+	require.NoError(t, err)
+
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go tool not found")
+	}
+
+	// resolve the dependencies from the module cache only, the test must not depend on the network
+	t.Setenv("GOPROXY", "off")
+	t.Setenv("GOSUMDB", "off")
+	t.Setenv("GOFLAGS", "-mod=mod")
+	t.Setenv("GOWORK", "off")
+
+	for name, flags := range map[string][]string{
+		"minimal":         nil,
+		"with everything": {"--with-rabbitmq", "--with-postgres", "--with-jwt"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Chdir(dir)
+
+			args := append([]string{"new", "example.com/acme/shop", "shop", "--replace", repoRoot}, flags...)
+			err := runCommand(t, args...)
+			if err != nil && strings.Contains(err.Error(), "go mod tidy failed") {
+				t.Skipf("dependencies are not available offline: %v", err)
+			}
+
+			require.NoError(t, err)
+
+			project := filepath.Join(dir, "shop")
+			assert.FileExists(t, filepath.Join(project, "cmd", "shop.go"))
+			assert.FileExists(t, filepath.Join(project, "internal", "controller", "http", "handler_hello.go"))
+
+			vet := exec.Command("go", "vet", "./...")
+			vet.Dir = project
+			out, err := vet.CombinedOutput()
+			require.NoError(t, err, string(out))
+		})
+	}
+
+	t.Run("existing directory", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		require.NoError(t, os.Mkdir("shop", 0755))
+		assert.ErrorContains(t, runCommand(t, "new", "example.com/acme/shop", "shop", "--skip-tidy"), "already exists")
+	})
+}
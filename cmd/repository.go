@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"embed"
+	"fmt"
+	"github.com/a-aslani/wotop/util"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+)
+
+// Embed the templates for repository generation.
+//
+//go:embed templates/repository/*.tmpl
+var repositoryFS embed.FS
+
+// loadRepositoryTemplates loads and parses the embedded repository templates.
+func loadRepositoryTemplates() (*template.Template, error) {
+	sub, err := fs.Sub(repositoryFS, "templates/repository")
+	if err != nil {
+		return nil, err
+	}
+	return template.ParseFS(sub, "*.tmpl")
+}
+
+// entityField describes a struct field of an entity together with its database column.
+type entityField struct {
+	Name   string // Go field name, e.g. "CreatedAt"
+	Type   string // Go type expression, e.g. "time.Time"
+	Column string // column name, e.g. "created_at"
+}
+
+// entityInfo holds what the repository templates need to know about an entity.
+type entityInfo struct {
+	Fields  []entityField
+	IDType  string
+	Imports []string
+}
+
+// defaultEntityInfo is used when the entity file does not exist yet.
+func defaultEntityInfo() entityInfo {
+	return entityInfo{
+		Fields: []entityField{{Name: "ID", Type: "string", Column: "id"}},
+		IDType: "string",
+	}
+}
+
+// inspectEntity parses the entity file and collects the exported fields of the entity struct.
+// The ID field is mandatory because the repositories look entities up by it. Imports needed by
+// the type of the ID field are returned so the generated code can reference it.
+func inspectEntity(path, entityName string) (entityInfo, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+	if err != nil {
+		return entityInfo{}, err
+	}
+
+	var st *ast.StructType
+	ast.Inspect(file, func(n ast.Node) bool {
+		ts, ok := n.(*ast.TypeSpec)
+		if !ok || ts.Name.Name != entityName {
+			return true
+		}
+		st, _ = ts.Type.(*ast.StructType)
+		return false
+	})
+	if st == nil {
+		return entityInfo{}, fmt.Errorf("struct %s not found in %s", entityName, path)
+	}
+
+	info := entityInfo{}
+	var idExpr ast.Expr
+
+	for _, field := range st.Fields.List {
+		for _, name := range field.Names {
+			if !name.IsExported() {
+				continue
+			}
+			info.Fields = append(info.Fields, entityField{
+				Name:   name.Name,
+				Type:   types.ExprString(field.Type),
+				Column: util.SnakeCase(name.Name),
+			})
+			if name.Name == "ID" {
+				idExpr = field.Type
+			}
+		}
+	}
+
+	if idExpr == nil {
+		return entityInfo{}, fmt.Errorf("entity %s has no ID field, add one before generating its repository", entityName)
+	}
+	info.IDType = types.ExprString(idExpr)
+
+	// a qualified ID type such as uuid.UUID needs the import of its package
+	if sel, ok := idExpr.(*ast.SelectorExpr); ok {
+		if pkg, ok := sel.X.(*ast.Ident); ok {
+			for _, imp := range file.Imports {
+				p, _ := strconv.Unquote(imp.Path.Value)
+				if (imp.Name != nil && imp.Name.Name == pkg.Name) || (imp.Name == nil && filepath.Base(p) == pkg.Name) {
+					info.Imports = append(info.Imports, imp.Path.Value)
+				}
+			}
+		}
+	}
+
+	return info, nil
+}
+
+// repositoryCmd defines a Cobra command for generating a repository scaffold.
+// Usage: `repository [domain] [entity] --table name`
+// - `domain`: The domain name the entity belongs to.
+// - `entity`: The name of the entity the repository persists.
+// - `--table`: The name of the table, the plural of the entity in snake case by default, e.g. "categories".
+//
+// It generates the repository interface in internal/<domain>/model/repository, a Postgres
+// implementation in internal/<domain>/gateway/postgres and an in-memory implementation for
+// tests in internal/<domain>/gateway/inmemory.
+var repositoryCmd = &cobra.Command{
+	Use:   "repository [domain] [entity]",
+	Short: "Generate a repository scaffold with postgres and in-memory implementations",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		domain, rawName := args[0], args[1]
+		entityName := toCamelCase(rawName)
+		snakeName := util.SnakeCase(rawName)
+
+		table, _ := cmd.Flags().GetString("table")
+		if table == "" {
+			table = util.Pluralize(snakeName)
+		}
+
+		module, err := modulePath()
+		if err != nil {
+			return err
+		}
+
+		// Infer the fields from the entity when it was generated before.
+		info := defaultEntityInfo()
+		entityPath := filepath.Join("internal", domain, "model", "entity", snakeName+".go")
+		if _, err := os.Stat(entityPath); err == nil {
+			if info, err = inspectEntity(entityPath, entityName); err != nil {
+				return err
+			}
+		}
+
+		tpl, err := loadRepositoryTemplates()
+		if err != nil {
+			return err
+		}
+
+		// Build the plain SQL fragments, the ID is always the first argument of an update.
+		columns := make([]string, 0, len(info.Fields))
+		updates := make([]string, 0, len(info.Fields))
+		var others []entityField
+		for _, f := range info.Fields {
+			columns = append(columns, f.Column)
+			if f.Name != "ID" {
+				others = append(others, f)
+				updates = append(updates, fmt.Sprintf("%s = $%d", f.Column, len(others)+1))
+			}
+		}
+
+		// Define the data to pass to the templates.
+		data := struct {
+			Module       string
+			Domain       string
+			Entity       string
+			Snake        string
+			Table        string
+			Fields       []entityField
+			OtherFields  []entityField
+			IDType       string
+			Imports      []string
+			Columns      string
+			Placeholders string
+			Updates      string
+		}{
+			Module:       module,
+			Domain:       domain,
+			Entity:       entityName,
+			Snake:        snakeName,
+			Table:        table,
+			Fields:       info.Fields,
+			OtherFields:  others,
+			IDType:       info.IDType,
+			Imports:      info.Imports,
+			Columns:      strings.Join(columns, ", "),
+			Placeholders: placeholders(len(columns)),
+			Updates:      strings.Join(updates, ", "),
+		}
+
+		files := []struct {
+			tmplName string
+			outPath  string
+		}{
+			{"repository.tmpl", filepath.Join("internal", domain, "model", "repository", snakeName+"_repository.go")},
+			{"postgres.tmpl", filepath.Join("internal", domain, "gateway", "postgres", snakeName+"_repository.go")},
+			{"inmemory.tmpl", filepath.Join("internal", domain, "gateway", "inmemory", snakeName+"_repository.go")},
+		}
+
+		for _, f := range files {
+			if err := writeGoTemplate(tpl, f.tmplName, f.outPath, data); err != nil {
+				return err
+			}
+			fmt.Printf("✅ Generated repository at %s\n", f.outPath)
+		}
+
+		return nil
+	},
+}
+
+// placeholders returns "$1, $2, ..." for n query arguments.
+func placeholders(n int) string {
+	p := make([]string, n)
+	for i := range p {
+		p[i] = fmt.Sprintf("$%d", i+1)
+	}
+	return strings.Join(p, ", ")
+}
+
+// init adds the `repositoryCmd` to the root command.
+func init() {
+	repositoryCmd.Flags().String("table", "", "name of the table, the plural of the entity by default")
+	rootCmd.AddCommand(repositoryCmd)
+}
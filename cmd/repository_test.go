@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoryCmd(t *testing.T) {
+
+	t.Run("without entity", func(t *testing.T) {
+		dir := newTestProject(t)
+		require.NoError(t, os.MkdirAll(filepath.Join("internal", "shop", "model", "entity"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join("internal", "shop", "model", "entity", "other.go"), []byte("package entity\n\ntype Order struct{ ID string }\n"), 0644))
+
+		require.NoError(t, runCommand(t, "repository", "shop", "order"))
+
+		assert.FileExists(t, filepath.Join(dir, "internal", "shop", "model", "repository", "order_repository.go"))
+		assertGoFilesCompile(t, dir)
+		buildWithFramework(t, dir, "./...")
+	})
+
+	t.Run("fields are inferred from the entity", func(t *testing.T) {
+		dir := newTestProject(t)
+		entityPath := filepath.Join("internal", "shop", "model", "entity", "product_item.go")
+		require.NoError(t, os.MkdirAll(filepath.Dir(entityPath), 0755))
+		require.NoError(t, os.WriteFile(entityPath, []byte(`package entity
+
+import "time"
+
+type ProductItem struct {
+	ID        int64
+	Title     string
+	CreatedAt time.Time
+	internal  bool
+}
+`), 0644))
+
+		require.NoError(t, runCommand(t, "repository", "shop", "product_item"))
+
+		src, err := os.ReadFile(filepath.Join(dir, "internal", "shop", "gateway", "postgres", "product_item_repository.go"))
+		require.NoError(t, err)
+		assert.Contains(t, string(src), "INSERT INTO product_items (id, title, created_at) VALUES ($1, $2, $3)")
+		assert.Contains(t, string(src), "UPDATE product_items SET title = $2, created_at = $3 WHERE id = $1")
+		assert.Contains(t, string(src), "id int64")
+		assert.Contains(t, string(src), "postgres_db.Conn(ctx, r.db).ExecContext(ctx, query")
+		assertGoFilesCompile(t, dir)
+		buildWithFramework(t, dir, "./...")
+	})
+
+	t.Run("table name", func(t *testing.T) {
+		dir := newTestProject(t)
+
+		require.NoError(t, runCommand(t, "repository", "shop", "category"))
+		require.NoError(t, runCommand(t, "repository", "shop", "box", "--table", "shop_boxes"))
+
+		src, err := os.ReadFile(filepath.Join(dir, "internal", "shop", "gateway", "postgres", "category_repository.go"))
+		require.NoError(t, err)
+		assert.Contains(t, string(src), "INSERT INTO categories (id) VALUES ($1)")
+
+		src, err = os.ReadFile(filepath.Join(dir, "internal", "shop", "gateway", "postgres", "box_repository.go"))
+		require.NoError(t, err)
+		assert.Contains(t, string(src), "INSERT INTO shop_boxes (id) VALUES ($1)")
+	})
+
+	t.Run("entity without ID", func(t *testing.T) {
+		newTestProject(t)
+		entityPath := filepath.Join("internal", "shop", "model", "entity", "cart.go")
+		require.NoError(t, os.MkdirAll(filepath.Dir(entityPath), 0755))
+		require.NoError(t, os.WriteFile(entityPath, []byte("package entity\n\ntype Cart struct{ Total int }\n"), 0644))
+
+		assert.ErrorContains(t, runCommand(t, "repository", "shop", "cart"), "no ID field")
+	})
+}
@@ -2,11 +2,13 @@ package cmd
 
 import (
 	"embed"
+	"errors"
 	"fmt"
 	"github.com/a-aslani/wotop/util"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 	"text/template"
 
 	"github.com/spf13/cobra"
@@ -14,7 +16,6 @@ import (
 
 // usecaseTemplates embeds all template files located in the "templates/usecase" directory.
 //
-//go:embed templates/usecase/*.t
 //go:embed templates/usecase/*.tmpl
 var usecaseTemplates embed.FS
 
@@ -29,9 +30,111 @@ func loadUsecaseTemplates() (*template.Template, error) {
 	return template.ParseFS(sub, "*.tmpl")
 }
 
+// plannedFile is a file the usecase command is about to create, overwrite or update.
+type plannedFile struct {
+	path    string
+	content []byte
+	action  string // "create", "overwrite" or "update"
+}
+
+// findRunnerFile returns the first Go file in the cmd directory registering usecases with
+// AddUsecase, or nil when there is none.
+func findRunnerFile() (*goFile, error) {
+	paths, err := filepath.Glob(filepath.Join("cmd", "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range paths {
+		f, err := parseGoFile(p)
+		if err != nil {
+			return nil, err
+		}
+		if f.callsMethod("AddUsecase") {
+			return f, nil
+		}
+	}
+	return nil, nil
+}
+
+// planUsecaseRegistration plans the edits wiring the usecase into the project: the usecase is
+// passed to AddUsecase in the cmd runner, a handler calling GetInport is generated next to the
+// router and its route is appended to RegisterRouter. Edits that are already in place are skipped.
+func planUsecaseRegistration(module, domain, snakeName string) ([]plannedFile, []string, error) {
+	var planned []plannedFile
+	var warnings []string
+
+	runner, err := findRunnerFile()
+	if err != nil {
+		return nil, nil, err
+	}
+	if runner == nil {
+		warnings = append(warnings, "no cmd runner calling AddUsecase found, register the usecase manually")
+	} else {
+		runner.addImport(fmt.Sprintf("%s/internal/%s/usecase/%s", module, domain, snakeName))
+		if _, err := runner.appendCallArg("AddUsecase", snakeName+".NewUsecase(nil)"); err != nil {
+			return nil, nil, err
+		}
+		if runner.changed {
+			src, err := runner.bytes()
+			if err != nil {
+				return nil, nil, err
+			}
+			planned = append(planned, plannedFile{path: runner.path, content: src, action: "update"})
+		}
+	}
+
+	routerPath := findRouterFile(domain)
+	if routerPath == "" {
+		warnings = append(warnings, "no router.go found, generate the handler with `wotop handler`")
+		return planned, warnings, nil
+	}
+
+	data, err := newHandlerData(module, domain, snakeName, "POST", "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	handlerPath := filepath.Join(filepath.Dir(routerPath), "handler_"+snakeName+".go")
+	if _, err := os.Stat(handlerPath); errors.Is(err, os.ErrNotExist) {
+		tpl, err := loadHandlerTemplates()
+		if err != nil {
+			return nil, nil, err
+		}
+		src, err := renderGoTemplate(tpl, "handler.tmpl", data)
+		if err != nil {
+			return nil, nil, err
+		}
+		planned = append(planned, plannedFile{path: handlerPath, content: src, action: "create"})
+	}
+
+	router, err := parseGoFile(routerPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	route, found, err := appendRoute(router, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !found {
+		warnings = append(warnings, fmt.Sprintf("RegisterRouter not found in %s, add the route manually: %s", routerPath, route))
+	}
+	if router.changed {
+		src, err := router.bytes()
+		if err != nil {
+			return nil, nil, err
+		}
+		planned = append(planned, plannedFile{path: routerPath, content: src, action: "update"})
+	}
+
+	return planned, warnings, nil
+}
+
 // usecaseCmd defines a Cobra command for generating a usecase scaffold.
 // It takes two arguments: [domain] and [name], and generates a set of files
 // in the "internal/<domain>/usecase/<name>" directory.
+//
+// Existing files are never overwritten unless --force is given. With --register the usecase
+// is also wired into the cmd runner and the HTTP router, and --dry-run only prints the changes.
 var usecaseCmd = &cobra.Command{
 	Use:   "usecase [domain] [name]",     // Command usage format
 	Short: "Generate a usecase scaffold", // Short description of the command
@@ -42,12 +145,12 @@ var usecaseCmd = &cobra.Command{
 		// Convert the raw name to snake_case
 		snakeName := util.SnakeCase(rawName)
 
+		force, _ := cmd.Flags().GetBool("force")
+		register, _ := cmd.Flags().GetBool("register")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
 		// Destination directory: internal/<domain>/usecase/<name>
 		destDir := filepath.Join("internal", domain, "usecase", snakeName)
-		// Create the destination directory and any necessary parent directories
-		if err := os.MkdirAll(destDir, 0755); err != nil {
-			return err
-		}
 
 		// Load the usecase templates
 		tpl, err := loadUsecaseTemplates()
@@ -55,6 +158,15 @@ var usecaseCmd = &cobra.Command{
 			return err
 		}
 
+		// Data to be passed to the templates
+		data := struct {
+			Package string // Package name (snake_case)
+			Domain  string // Domain name
+		}{
+			Package: snakeName,
+			Domain:  domain,
+		}
+
 		// Define the list of template files and their corresponding output file names
 		files := []struct {
 			tmplName string // Template file name
@@ -65,40 +177,80 @@ var usecaseCmd = &cobra.Command{
 			{"interactor.tmpl", "interactor.go"},
 		}
 
-		// Iterate over the files, generate content from templates, and write to output files
+		var planned []plannedFile
+		var conflicts []string
+
+		// Render every file first, so nothing is written when one of them conflicts
 		for _, f := range files {
-			// Full path to the output file
 			outPath := filepath.Join(destDir, f.outName)
 
-			// Create the output file
-			outFile, err := os.Create(outPath)
+			src, err := renderGoTemplate(tpl, f.tmplName, data)
 			if err != nil {
 				return err
 			}
-			defer outFile.Close()
-
-			// Data to be passed to the template
-			data := struct {
-				Package string // Package name (snake_case)
-				Domain  string // Domain name
-			}{
-				Package: snakeName,
-				Domain:  domain,
+
+			action := "create"
+			if _, err := os.Stat(outPath); err == nil {
+				action = "overwrite"
+				conflicts = append(conflicts, outPath)
 			}
 
-			// Execute the template and write the output to the file
-			if err := tpl.ExecuteTemplate(outFile, f.tmplName, data); err != nil {
+			planned = append(planned, plannedFile{path: outPath, content: src, action: action})
+		}
+
+		if len(conflicts) > 0 && !force {
+			return fmt.Errorf("refusing to overwrite existing files, use --force to replace them:\n  %s", strings.Join(conflicts, "\n  "))
+		}
+
+		if register {
+			module, err := modulePath()
+			if err != nil {
 				return err
 			}
 
-			// Print a success message for the generated file
-			fmt.Printf("✅ Generated usecase at %s\n", outPath)
+			wiring, warnings, err := planUsecaseRegistration(module, domain, snakeName)
+			if err != nil {
+				return err
+			}
+			planned = append(planned, wiring...)
+
+			for _, w := range warnings {
+				fmt.Printf("⚠️  %s\n", w)
+			}
 		}
+
+		if dryRun {
+			for _, p := range planned {
+				fmt.Printf("%-9s %s\n", p.action, p.path)
+				if p.action == "update" {
+					fmt.Printf("%s\n", p.content)
+				}
+			}
+			return nil
+		}
+
+		for _, p := range planned {
+			if err := writeFile(p.path, p.content); err != nil {
+				return err
+			}
+			fmt.Printf("✅ %s %s\n", actionPastTense[p.action], p.path)
+		}
+
 		return nil
 	},
 }
 
+// actionPastTense is used to report the applied changes.
+var actionPastTense = map[string]string{
+	"create":    "Generated",
+	"overwrite": "Overwrote",
+	"update":    "Updated",
+}
+
 // init adds the usecaseCmd to the root command.
 func init() {
+	usecaseCmd.Flags().Bool("force", false, "overwrite existing usecase files")
+	usecaseCmd.Flags().Bool("register", false, "wire the usecase into the cmd runner and the HTTP router")
+	usecaseCmd.Flags().Bool("dry-run", false, "print the planned changes without writing anything")
 	rootCmd.AddCommand(usecaseCmd)
 }
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fixtureRunner = `package cmd
+
+import (
+	"example.com/app/internal/controller/http"
+)
+
+type app struct{}
+
+func (app) Run() error {
+	primaryDriver := http.NewController()
+
+	// usecases served by this app
+	primaryDriver.AddUsecase()
+
+	return nil
+}
+`
+
+const fixtureRouter = `package http
+
+// RegisterRouter sets up the HTTP routes.
+func (r *controller) RegisterRouter() {
+	// existing routes
+	r.Router.GET("/ping", r.ping())
+}
+`
+
+// newFixtureProject creates a project with a cmd runner and a router to be wired by --register.
+func newFixtureProject(t *testing.T) (runnerPath, routerPath string) {
+	dir := newTestProject(t)
+
+	runnerPath = filepath.Join(dir, "cmd", "app.go")
+	routerPath = filepath.Join(dir, "internal", "controller", "http", "router.go")
+
+	require.NoError(t, writeFile(runnerPath, []byte(fixtureRunner)))
+	require.NoError(t, writeFile(routerPath, []byte(fixtureRouter)))
+
+	return runnerPath, routerPath
+}
+
+func TestUsecaseCmd(t *testing.T) {
+
+	t.Run("refuses to overwrite without --force", func(t *testing.T) {
+		newTestProject(t)
+		require.NoError(t, runCommand(t, "usecase", "shop", "createOrder"))
+
+		interactor := filepath.Join("internal", "shop", "usecase", "create_order", "interactor.go")
+		require.NoError(t, os.WriteFile(interactor, []byte("package create_order\n// edited\n"), 0644))
+
+		err := runCommand(t, "usecase", "shop", "createOrder")
+		assert.ErrorContains(t, err, interactor)
+
+		src, _ := os.ReadFile(interactor)
+		assert.Contains(t, string(src), "// edited")
+
+		require.NoError(t, runCommand(t, "usecase", "shop", "createOrder", "--force"))
+		src, _ = os.ReadFile(interactor)
+		assert.NotContains(t, string(src), "// edited")
+	})
+
+	t.Run("register wires runner and router idempotently", func(t *testing.T) {
+		runnerPath, routerPath := newFixtureProject(t)
+
+		require.NoError(t, runCommand(t, "usecase", "shop", "createOrder", "--register"))
+
+		runner, err := os.ReadFile(runnerPath)
+		require.NoError(t, err)
+		assert.Contains(t, string(runner), `"example.com/app/internal/shop/usecase/create_order"`)
+		assert.Contains(t, string(runner), "primaryDriver.AddUsecase(create_order.NewUsecase(nil))")
+		assert.Contains(t, string(runner), "// usecases served by this app")
+
+		router, err := os.ReadFile(routerPath)
+		require.NoError(t, err)
+		assert.Contains(t, string(router), `r.Router.POST("/create-order", r.createOrderHandler())`)
+		assert.Contains(t, string(router), "// existing routes")
+		assert.FileExists(t, filepath.Join(filepath.Dir(routerPath), "handler_create_order.go"))
+
+		// the second run only overwrites the usecase files, the wiring stays as it is
+		require.NoError(t, runCommand(t, "usecase", "shop", "createOrder", "--register", "--force"))
+
+		runnerAgain, _ := os.ReadFile(runnerPath)
+		routerAgain, _ := os.ReadFile(routerPath)
+		assert.Equal(t, string(runner), string(runnerAgain))
+		assert.Equal(t, string(router), string(routerAgain))
+
+		// a second usecase is appended next to the first one
+		require.NoError(t, runCommand(t, "usecase", "shop", "cancelOrder", "--register"))
+		runner, _ = os.ReadFile(runnerPath)
+		assert.Contains(t, string(runner), "primaryDriver.AddUsecase(create_order.NewUsecase(nil), cancel_order.NewUsecase(nil))")
+
+		assertGoFilesCompile(t, filepath.Dir(filepath.Dir(runnerPath)))
+	})
+
+	t.Run("dry run writes nothing", func(t *testing.T) {
+		runnerPath, _ := newFixtureProject(t)
+
+		require.NoError(t, runCommand(t, "usecase", "shop", "createOrder", "--register", "--dry-run"))
+
+		assert.NoDirExists(t, filepath.Join("internal", "shop"))
+		runner, _ := os.ReadFile(runnerPath)
+		assert.Equal(t, fixtureRunner, string(runner))
+	})
+}
@@ -0,0 +1,345 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/a-aslani/wotop/logger"
+	"github.com/a-aslani/wotop/validator"
+	"github.com/spf13/viper"
+)
+
+// DefaultDotEnvFile is the .env file read by Load unless WithDotEnv sets another one.
+const DefaultDotEnvFile = ".env"
+
+// ValidationError lists the missing or invalid keys of the configuration.
+//
+// Fields:
+//   - Messages: The validation messages, their field names are the keys, e.g. "database.host".
+type ValidationError struct {
+	Messages []validator.Message
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Messages))
+	for _, m := range e.Messages {
+		parts = append(parts, fmt.Sprintf("%s %s", m.Code, m.Message))
+	}
+	return "invalid config: " + strings.Join(parts, "; ")
+}
+
+// options holds the settings of Load and Watch.
+type options struct {
+	envPrefix string
+	dotEnv    string
+	interval  time.Duration
+	log       logger.Logger
+}
+
+// Option configures Load and Watch.
+type Option func(*options)
+
+// WithEnvPrefix prefixes the names of the environment variables overriding the keys, e.g.
+// SHOP_DATABASE_HOST for database.host with the prefix "SHOP".
+//
+// Parameters:
+//   - prefix: The prefix, without the trailing underscore.
+//
+// Returns:
+//   - The option setting the prefix.
+func WithEnvPrefix(prefix string) Option {
+	return func(o *options) {
+		o.envPrefix = strings.ToUpper(strings.TrimSuffix(prefix, "_"))
+	}
+}
+
+// WithDotEnv sets the .env file whose variables are exported before the configuration is read.
+//
+// Parameters:
+//   - file: The file, DefaultDotEnvFile by default, none when empty.
+//
+// Returns:
+//   - The option setting the file.
+func WithDotEnv(file string) Option {
+	return func(o *options) {
+		o.dotEnv = file
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{dotEnv: DefaultDotEnvFile, interval: DefaultWatchInterval}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Load reads the configuration of an application into a T, a struct whose fields are mapped to
+// the keys by their mapstructure tags, e.g. `mapstructure:"database"`, else by their lowercase
+// name. The values are taken, by precedence, from:
+//
+//   - the environment variables, also the ones of the .env file not set in the environment: the
+//     variable of a key is named by the env tags of its field and its parents joined by "_", e.g.
+//     DATABASE_HOST for database.host, else by the key in uppercase, see WithEnvPrefix.
+//   - the file, a YAML, JSON or TOML one, whose ${VAR} and ${VAR:-default} references are replaced
+//     by the environment variables. A .env file only sets environment variables.
+//   - the default tags, e.g. `default:"5s"`, a comma separated list for the slices.
+//
+// The fields are then checked against their validate tags, e.g. `validate:"required"`, the ones
+// of the nested structs and of the struct values of the maps included.
+//
+// Parameters:
+//   - path: The configuration file.
+//   - opts: The prefix of the environment variables and the .env file.
+//
+// Returns:
+//   - The configuration.
+//   - A *ValidationError if keys are missing or invalid, or an error if the files cannot be read.
+func Load[T any](path string, opts ...Option) (*T, error) {
+	cfg, _, err := load[T](path, newOptions(opts))
+	return cfg, err
+}
+
+// load reads the configuration, see Load, and returns the content of the file too.
+func load[T any](path string, o options) (*T, []byte, error) {
+
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("config: %s is not a struct", t)
+	}
+
+	if o.dotEnv != "" {
+		if err := loadDotEnv(o.dotEnv, false); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	v := viper.New()
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.SetEnvPrefix(o.envPrefix)
+	v.AutomaticEnv()
+
+	if isDotEnv(path) {
+		if err = loadDotEnv(path, true); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		v.SetConfigType(strings.TrimPrefix(filepath.Ext(path), "."))
+		if err = v.ReadConfig(bytes.NewReader(expand(content))); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err = bind(v, t, "", "", o.envPrefix); err != nil {
+		return nil, nil, err
+	}
+
+	cfg := new(T)
+	if err = v.Unmarshal(cfg); err != nil {
+		return nil, nil, err
+	}
+
+	if messages := validate("", reflect.ValueOf(cfg).Elem()); len(messages) > 0 {
+		return nil, nil, &ValidationError{Messages: messages}
+	}
+
+	return cfg, content, nil
+}
+
+// bind sets the defaults of the keys of a struct and binds them to their environment variables,
+// so the keys missing from the file can be set from the environment. The keys of maps are only
+// known from the file, so the file is read first: the struct values of its maps are bound with
+// the variables of the map named by the key, e.g. SERVERS_SHOP_ADDRESS for servers.shop.address.
+//
+// Parameters:
+//   - v: The viper instance.
+//   - t: The struct type.
+//   - key: The key of the struct, empty for the configuration.
+//   - env: The environment variable of the struct, empty for the configuration.
+//   - prefix: The prefix of the environment variables.
+//
+// Returns:
+//   - An error if a key cannot be bound.
+func bind(v *viper.Viper, t reflect.Type, key, env, prefix string) error {
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		k, e := fieldKey(f), f.Tag.Get("env")
+		if k == "" {
+			continue
+		}
+		if e == "" {
+			e = strings.ToUpper(k)
+		}
+		if key != "" {
+			k, e = key+"."+k, env+"_"+e
+		}
+
+		if def, ok := f.Tag.Lookup("default"); ok {
+			v.SetDefault(k, def)
+		}
+
+		switch {
+		case f.Type.Kind() == reflect.Map && f.Type.Elem().Kind() == reflect.Struct:
+			for name := range v.GetStringMap(k) {
+				if err := bind(v, f.Type.Elem(), k+"."+name, e+"_"+strings.ToUpper(name), prefix); err != nil {
+					return err
+				}
+			}
+		case f.Type.Kind() == reflect.Map:
+			continue
+		case f.Type.Kind() == reflect.Struct && f.Type != reflect.TypeOf(time.Time{}):
+			if err := bind(v, f.Type, k, e, prefix); err != nil {
+				return err
+			}
+		default:
+			name := e
+			if prefix != "" {
+				name = prefix + "_" + e
+			}
+			if err := v.BindEnv(k, name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// fieldKey returns the key of a field, its mapstructure tag else its lowercase name, empty for
+// the fields skipped with "-".
+func fieldKey(f reflect.StructField) string {
+	k, _, _ := strings.Cut(f.Tag.Get("mapstructure"), ",")
+	switch k {
+	case "-":
+		return ""
+	case "":
+		return strings.ToLower(f.Name)
+	}
+	return k
+}
+
+// validate runs the validator on a section of the configuration, its nested structs and the
+// struct values of its maps, and prefixes the field names of the messages with the key of the
+// section.
+//
+// Parameters:
+//   - prefix: The key of the section followed by a dot, empty for the configuration.
+//   - section: The struct value of the section.
+//
+// Returns:
+//   - The messages of the invalid keys.
+func validate(prefix string, section reflect.Value) []validator.Message {
+
+	vld := validator.New()
+	if _, err := vld.Validate(section.Interface()); err != nil {
+		e := validator.ErrInvalidTypeInputData
+		return []validator.Message{{FieldName: strings.TrimSuffix(prefix, "."), Code: e.Code(), Message: e.Error()}}
+	}
+
+	messages := make([]validator.Message, 0, len(vld.Errors))
+	for _, e := range vld.Errors {
+		m := e.(validator.Message)
+		m.Message = strings.Replace(m.Message, m.FieldName, prefix+m.FieldName, 1)
+		m.FieldName = prefix + m.FieldName
+		messages = append(messages, m)
+	}
+
+	t := section.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		k := fieldKey(f)
+		if !f.IsExported() || k == "" {
+			continue
+		}
+
+		field := section.Field(i)
+		switch {
+		case f.Type.Kind() == reflect.Struct && f.Type != reflect.TypeOf(time.Time{}):
+			messages = append(messages, validate(prefix+k+".", field)...)
+		case f.Type.Kind() == reflect.Map && f.Type.Elem().Kind() == reflect.Struct && f.Type.Key().Kind() == reflect.String:
+			names := make([]string, 0, field.Len())
+			for _, name := range field.MapKeys() {
+				names = append(names, name.String())
+			}
+			sort.Strings(names)
+
+			for _, name := range names {
+				messages = append(messages, validate(prefix+k+"."+name+".", field.MapIndex(reflect.ValueOf(name).Convert(f.Type.Key())))...)
+			}
+		}
+	}
+
+	return messages
+}
+
+// reference matches the ${VAR} and ${VAR:-default} references of a configuration file.
+var reference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// expand replaces the ${VAR} references by the environment variables, empty when they are not
+// set, and the ${VAR:-default} ones by their default when the variables are empty. The $VAR
+// without braces are kept, they may be part of a password.
+func expand(content []byte) []byte {
+	return reference.ReplaceAllFunc(content, func(ref []byte) []byte {
+		m := reference.FindSubmatch(ref)
+		if value := os.Getenv(string(m[1])); value != "" {
+			return []byte(value)
+		}
+		return m[2]
+	})
+}
+
+// isDotEnv reports whether a file is a .env file, e.g. ".env" or "prod.env".
+func isDotEnv(path string) bool {
+	return filepath.Ext(path) == ".env" || filepath.Base(path) == ".env"
+}
+
+// loadDotEnv exports the variables of a .env file which are not set in the environment yet.
+//
+// Parameters:
+//   - file: The .env file.
+//   - required: Whether a missing file is an error, it is skipped otherwise.
+//
+// Returns:
+//   - An error if the file cannot be read.
+func loadDotEnv(file string, required bool) error {
+
+	if _, err := os.Stat(file); errors.Is(err, os.ErrNotExist) && !required {
+		return nil
+	}
+
+	v := viper.New()
+	v.SetConfigFile(file)
+	v.SetConfigType("env")
+	if err := v.ReadInConfig(); err != nil {
+		return err
+	}
+
+	for _, key := range v.AllKeys() {
+		name := strings.ToUpper(key)
+		if _, ok := os.LookupEnv(name); !ok {
+			if err := os.Setenv(name, v.GetString(key)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type appConfig struct {
+	Stage    string            `mapstructure:"stage" env:"STAGE" validate:"required"`
+	LogLevel string            `mapstructure:"log_level" default:"info"`
+	Servers  map[string]server `mapstructure:"servers" validate:"required"`
+	Database database          `mapstructure:"database" env:"DB"`
+}
+
+type server struct {
+	Address         string        `mapstructure:"address" validate:"required"`
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout" default:"5s"`
+}
+
+type database struct {
+	Host     string   `mapstructure:"host" validate:"required"`
+	Port     int      `mapstructure:"port" default:"5432"`
+	Password string   `mapstructure:"password"`
+	Replicas []string `mapstructure:"replicas" default:"r1,r2"`
+}
+
+const appYAML = `
+stage: dev
+servers:
+  shop:
+    address: ":8000"
+database:
+  host: localhost
+  password: ${DB_SECRET:-changeme}
+`
+
+// writeFile writes a file in the temporary directory of the test, which is the working
+// directory so a stray .env file is not read.
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestLoadDefaultsAndExpansion(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	cfg, err := Load[appConfig](writeFile(t, "config.yaml", appYAML))
+	require.NoError(t, err)
+
+	assert.Equal(t, "dev", cfg.Stage)
+	assert.Equal(t, "info", cfg.LogLevel)
+	assert.Equal(t, server{Address: ":8000", ShutdownTimeout: 5 * time.Second}, cfg.Servers["shop"])
+	assert.Equal(t, database{Host: "localhost", Port: 5432, Password: "changeme", Replicas: []string{"r1", "r2"}}, cfg.Database)
+
+	t.Setenv("DB_SECRET", "s3cr3t")
+	cfg, err = Load[appConfig](writeFile(t, "config.yaml", appYAML))
+	require.NoError(t, err)
+	assert.Equal(t, "s3cr3t", cfg.Database.Password)
+}
+
+func TestLoadEnvOverridesFile(t *testing.T) {
+	t.Chdir(t.TempDir())
+	path := writeFile(t, "config.yaml", appYAML)
+
+	t.Setenv("STAGE", "prod")
+	t.Setenv("DB_HOST", "db.internal")
+	t.Setenv("DB_PORT", "6432")
+	t.Setenv("LOG_LEVEL", "debug")
+	t.Setenv("SERVERS_SHOP_ADDRESS", ":9000")
+
+	cfg, err := Load[appConfig](path)
+	require.NoError(t, err)
+	assert.Equal(t, "prod", cfg.Stage)
+	assert.Equal(t, "db.internal", cfg.Database.Host, "the env tags name the variables")
+	assert.Equal(t, 6432, cfg.Database.Port, "the environment overrides the defaults")
+	assert.Equal(t, "debug", cfg.LogLevel, "the keys without env tag are named in uppercase")
+	assert.Equal(t, ":9000", cfg.Servers["shop"].Address)
+
+	// with a prefix, the variables without it are ignored
+	t.Setenv("SHOP_DB_HOST", "db.shop")
+	cfg, err = Load[appConfig](path, WithEnvPrefix("SHOP"))
+	require.NoError(t, err)
+	assert.Equal(t, "db.shop", cfg.Database.Host)
+	assert.Equal(t, "dev", cfg.Stage)
+}
+
+func TestLoadDotEnv(t *testing.T) {
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.WriteFile(".env", []byte("DB_HOST=db.env\nSTAGE=staging\n"), 0644))
+	t.Setenv("STAGE", "prod")
+	t.Cleanup(func() { os.Unsetenv("DB_HOST") })
+
+	cfg, err := Load[appConfig](writeFile(t, "config.yaml", appYAML))
+	require.NoError(t, err)
+	assert.Equal(t, "db.env", cfg.Database.Host, "the .env file overrides the file")
+	assert.Equal(t, "prod", cfg.Stage, "the environment overrides the .env file")
+	require.NoError(t, os.Unsetenv("DB_HOST"))
+
+	// a .env file can be the configuration
+	type dbConfig struct {
+		Database database `mapstructure:"database" env:"DB"`
+	}
+	db, err := Load[dbConfig](writeFile(t, "prod.env", "DB_HOST=db.prod\n"), WithDotEnv(""))
+	require.NoError(t, err)
+	assert.Equal(t, "db.prod", db.Database.Host)
+}
+
+func TestLoadRequiredKeys(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	_, err := Load[appConfig](writeFile(t, "config.yaml", "servers:\n  shop:\n    shutdown_timeout: 1s\n  admin:\n    address: \":8001\"\n"))
+
+	var verr *ValidationError
+	require.True(t, errors.As(err, &verr), err)
+	assert.Equal(t, []validator.Message{
+		{FieldName: "stage", Code: "ER0003", Message: "stage is required", Rule: "required"},
+		{FieldName: "servers.shop.address", Code: "ER0003", Message: "servers.shop.address is required", Rule: "required"},
+		{FieldName: "database.host", Code: "ER0003", Message: "database.host is required", Rule: "required"},
+	}, verr.Messages)
+	assert.EqualError(t, err, "invalid config: ER0003 stage is required; ER0003 servers.shop.address is required; ER0003 database.host is required")
+
+	_, err = Load[appConfig](writeFile(t, "config.yaml", "stage: dev\ndatabase:\n  host: localhost\n"))
+	require.True(t, errors.As(err, &verr), err)
+	assert.Equal(t, "servers", verr.Messages[0].FieldName)
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	_, err := Load[appConfig](filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	_, err = Load[appConfig](writeFile(t, "config.yaml", "stage: [dev\n"))
+	assert.Error(t, err)
+
+	_, err = Load[string](writeFile(t, "config.yaml", appYAML))
+	assert.ErrorContains(t, err, "not a struct")
+}
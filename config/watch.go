@@ -0,0 +1,95 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"time"
+
+	"github.com/a-aslani/wotop/logger"
+)
+
+// DefaultWatchInterval is the interval at which Watch checks the configuration file.
+const DefaultWatchInterval = 2 * time.Second
+
+// WithWatchInterval sets the interval at which Watch checks the configuration file.
+//
+// Parameters:
+//   - interval: The interval, DefaultWatchInterval by default.
+//
+// Returns:
+//   - The option setting the interval.
+func WithWatchInterval(interval time.Duration) Option {
+	return func(o *options) {
+		if interval > 0 {
+			o.interval = interval
+		}
+	}
+}
+
+// WithLogger sets the logger of the reloads failed by Watch.
+//
+// Parameters:
+//   - log: The logger, the failed reloads are not logged by default.
+//
+// Returns:
+//   - The option setting the logger.
+func WithLogger(log logger.Logger) Option {
+	return func(o *options) {
+		o.log = log
+	}
+}
+
+// Watch loads the configuration, see Load, and reloads it each time its file changes, for the
+// settings changed without a restart such as the log level. The file is checked at an interval,
+// so it may be replaced by a new one, as a Kubernetes ConfigMap is. A configuration that cannot
+// be loaded is logged and skipped, onChange keeps getting the valid ones.
+//
+// Parameters:
+//   - ctx: The context stopping the watch when it is done.
+//   - path: The configuration file.
+//   - onChange: The function called with each reloaded configuration, from the watching goroutine.
+//   - opts: The options of Load, the interval and the logger of the watch.
+//
+// Returns:
+//   - The configuration loaded first.
+//   - An error if it cannot be loaded, the file is not watched then.
+func Watch[T any](ctx context.Context, path string, onChange func(*T), opts ...Option) (*T, error) {
+
+	o := newOptions(opts)
+
+	cfg, content, err := load[T](path, o)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		ticker := time.NewTicker(o.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			current, err := os.ReadFile(path)
+			if err != nil || bytes.Equal(current, content) {
+				continue
+			}
+			content = current
+
+			reloaded, _, err := load[T](path, o)
+			if err != nil {
+				if o.log != nil {
+					o.log.Error(ctx, "config: cannot reload %s, the previous configuration is kept: %v", path, err)
+				}
+				continue
+			}
+			onChange(reloaded)
+		}
+	}()
+
+	return cfg, nil
+}
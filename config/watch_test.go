@@ -0,0 +1,57 @@
+package config
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop/logger"
+	"github.com/a-aslani/wotop/logger/loggertest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatch(t *testing.T) {
+	t.Chdir(t.TempDir())
+	path := writeFile(t, "config.yaml", appYAML)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	log := &loggertest.Recorder{}
+	changes := make(chan *appConfig, 1)
+	cfg, err := Watch(ctx, path, func(c *appConfig) { changes <- c }, WithWatchInterval(10*time.Millisecond), WithLogger(log))
+	require.NoError(t, err)
+	assert.Equal(t, "info", cfg.LogLevel)
+
+	require.NoError(t, os.WriteFile(path, []byte(appYAML+"log_level: debug\n"), 0644))
+	select {
+	case cfg = <-changes:
+		assert.Equal(t, "debug", cfg.LogLevel)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the change was not reported")
+	}
+
+	// an invalid configuration is logged and skipped
+	require.NoError(t, os.WriteFile(path, []byte("log_level: warn\n"), 0644))
+	require.Eventually(t, func() bool { return len(log.Messages(logger.LevelError)) == 1 }, 5*time.Second, 10*time.Millisecond)
+	assert.Contains(t, log.Messages(logger.LevelError)[0], "stage is required")
+	assert.Empty(t, changes)
+
+	// the watch stops with the context
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, os.WriteFile(path, []byte(appYAML+"log_level: error\n"), 0644))
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, changes)
+}
+
+func TestWatchInvalidConfig(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	_, err := Watch(context.Background(), writeFile(t, "config.yaml", "log_level: warn\n"), func(*appConfig) {
+		t.Error("onChange is not called")
+	})
+	assert.ErrorContains(t, err, "stage is required")
+}
@@ -0,0 +1,274 @@
+package wotop
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// ErrNoProvider is returned by Resolve when nothing provides the requested type.
+var ErrNoProvider = errors.New("no provider")
+
+// ErrDependencyCycle is returned by Resolve when a constructor depends on itself, directly or not.
+var ErrDependencyCycle = errors.New("dependency cycle")
+
+// ErrNilInstance is returned by Resolve when the constructor of an interface type returned nil
+// without error, so the callers never get a nil interface they would panic on.
+var ErrNilInstance = errors.New("nil instance")
+
+// bindingKey identifies a binding by its type and its optional name.
+type bindingKey struct {
+	typ  reflect.Type
+	name string
+}
+
+func (k bindingKey) String() string {
+	if k.name == "" {
+		return k.typ.String()
+	}
+	return fmt.Sprintf("%s(%s)", k.typ, k.name)
+}
+
+// containerState is shared by a Container and the views given to the constructors.
+type containerState struct {
+	mu        sync.RWMutex
+	build     sync.Mutex // serializes the constructions started by a top level Resolve
+	providers map[bindingKey]func(*Container) (any, error)
+	overrides map[bindingKey]func(*Container) (any, error)
+	instances map[bindingKey]any
+}
+
+// Container builds the outports, interactors and controllers of an application from the
+// constructors provided for their types. Every type is built once, the first time it is
+// resolved, and the same instance is returned afterwards.
+//
+// Constructors receive the container to resolve their own dependencies, they must resolve
+// them through it rather than through another reference to the container, so cycles are detected.
+type Container struct {
+	state *containerState
+	path  []bindingKey // the bindings being built, for cycle detection
+}
+
+// NewContainer creates an empty Container.
+//
+// Returns:
+//   - A new Container.
+func NewContainer() *Container {
+	return &Container{state: &containerState{
+		providers: map[bindingKey]func(*Container) (any, error){},
+		overrides: map[bindingKey]func(*Container) (any, error){},
+		instances: map[bindingKey]any{},
+	}}
+}
+
+// Provide registers the constructor of T. It panics when T is provided already.
+//
+// Type Parameters:
+//   - T: The type built by the constructor, usually an interface such as an outport.
+//
+// Parameters:
+//   - c: The container.
+//   - constructor: Builds T, resolving its dependencies from the given container.
+func Provide[T any](c *Container, constructor func(*Container) (T, error)) {
+	ProvideNamed(c, "", constructor)
+}
+
+// ProvideNamed is like Provide but registers the constructor under a name, so several
+// bindings of the same type can coexist, e.g. a primary and a replica database.
+//
+// Type Parameters:
+//   - T: The type built by the constructor.
+//
+// Parameters:
+//   - c: The container.
+//   - name: The name of the binding.
+//   - constructor: Builds T, resolving its dependencies from the given container.
+func ProvideNamed[T any](c *Container, name string, constructor func(*Container) (T, error)) {
+	key := bindingKeyOf[T](name)
+
+	c.state.mu.Lock()
+	defer c.state.mu.Unlock()
+
+	if _, ok := c.state.providers[key]; ok {
+		panic(fmt.Sprintf("wotop: %s is already provided", key))
+	}
+	c.state.providers[key] = erase(constructor)
+}
+
+// Override replaces the constructor of T, whether it is provided or not, e.g. to swap a
+// repository for a fake in tests. The instance of T built before is dropped, the instances
+// depending on it are not, so overrides should be set up before resolving anything.
+//
+// Type Parameters:
+//   - T: The type built by the constructor.
+//
+// Parameters:
+//   - c: The container.
+//   - constructor: Builds T in place of the provided constructor.
+func Override[T any](c *Container, constructor func(*Container) (T, error)) {
+	OverrideNamed(c, "", constructor)
+}
+
+// OverrideNamed is like Override for a named binding.
+//
+// Type Parameters:
+//   - T: The type built by the constructor.
+//
+// Parameters:
+//   - c: The container.
+//   - name: The name of the binding.
+//   - constructor: Builds T in place of the provided constructor.
+func OverrideNamed[T any](c *Container, name string, constructor func(*Container) (T, error)) {
+	key := bindingKeyOf[T](name)
+
+	c.state.mu.Lock()
+	defer c.state.mu.Unlock()
+
+	c.state.overrides[key] = erase(constructor)
+	delete(c.state.instances, key)
+}
+
+// Resolve returns the instance of T, building it and its dependencies on the first call.
+// Resolve is safe for concurrent use.
+//
+// Type Parameters:
+//   - T: The requested type.
+//
+// Parameters:
+//   - c: The container.
+//
+// Returns:
+//   - The instance of T.
+//   - An error wrapping ErrNoProvider, ErrDependencyCycle, ErrNilInstance or the error of a constructor.
+func Resolve[T any](c *Container) (T, error) {
+	return ResolveNamed[T](c, "")
+}
+
+// ResolveNamed is like Resolve for a named binding.
+//
+// Type Parameters:
+//   - T: The requested type.
+//
+// Parameters:
+//   - c: The container.
+//   - name: The name of the binding.
+//
+// Returns:
+//   - The instance of T.
+//   - An error wrapping ErrNoProvider, ErrDependencyCycle, ErrNilInstance or the error of a constructor.
+func ResolveNamed[T any](c *Container, name string) (T, error) {
+	var zero T
+
+	key := bindingKeyOf[T](name)
+	v, err := c.resolve(key)
+	if err != nil {
+		return zero, err
+	}
+
+	t, ok := v.(T)
+	if !ok {
+		return zero, fmt.Errorf("wotop: %w for %s", ErrNilInstance, key)
+	}
+	return t, nil
+}
+
+// MustResolve is like Resolve but panics when T cannot be resolved, it is meant for cmd runners.
+//
+// Type Parameters:
+//   - T: The requested type.
+//
+// Parameters:
+//   - c: The container.
+//
+// Returns:
+//   - The instance of T.
+func MustResolve[T any](c *Container) T {
+	v, err := Resolve[T](c)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// resolve returns the cached instance of key or builds it.
+func (c *Container) resolve(key bindingKey) (any, error) {
+	if v, ok := c.cached(key); ok {
+		return v, nil
+	}
+
+	// the constructions are serialized, the nested ones run under the lock of the top level one
+	if len(c.path) == 0 {
+		c.state.build.Lock()
+		defer c.state.build.Unlock()
+
+		if v, ok := c.cached(key); ok {
+			return v, nil
+		}
+	}
+
+	for i, k := range c.path {
+		if k == key {
+			return nil, fmt.Errorf("wotop: %w: %s", ErrDependencyCycle, formatPath(append(c.path[i:], key)))
+		}
+	}
+
+	c.state.mu.RLock()
+	constructor, ok := c.state.overrides[key]
+	if !ok {
+		constructor, ok = c.state.providers[key]
+	}
+	c.state.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("wotop: %w for %s", ErrNoProvider, key)
+	}
+
+	path := make([]bindingKey, len(c.path), len(c.path)+1)
+	copy(path, c.path)
+
+	v, err := constructor(&Container{state: c.state, path: append(path, key)})
+	if err != nil {
+		// errors of the dependencies already carry their path
+		if errors.Is(err, ErrNoProvider) || errors.Is(err, ErrDependencyCycle) || errors.Is(err, ErrNilInstance) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("wotop: cannot build %s: %w", key, err)
+	}
+
+	c.state.mu.Lock()
+	c.state.instances[key] = v
+	c.state.mu.Unlock()
+
+	return v, nil
+}
+
+// cached returns the instance of key built before.
+func (c *Container) cached(key bindingKey) (any, bool) {
+	c.state.mu.RLock()
+	defer c.state.mu.RUnlock()
+	v, ok := c.state.instances[key]
+	return v, ok
+}
+
+// bindingKeyOf returns the key of the binding of T with the given name.
+func bindingKeyOf[T any](name string) bindingKey {
+	return bindingKey{typ: reflect.TypeFor[T](), name: name}
+}
+
+// erase turns a typed constructor into the constructor stored by the container.
+func erase[T any](constructor func(*Container) (T, error)) func(*Container) (any, error) {
+	return func(c *Container) (any, error) {
+		return constructor(c)
+	}
+}
+
+// formatPath returns the bindings of path separated by arrows.
+func formatPath(path []bindingKey) string {
+	names := make([]string, len(path))
+	for i, k := range path {
+		names[i] = k.String()
+	}
+	return strings.Join(names, " -> ")
+}
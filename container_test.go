@@ -0,0 +1,181 @@
+package wotop
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type greeter interface{ Greet() string }
+
+type staticGreeter string
+
+func (g staticGreeter) Greet() string { return string(g) }
+
+type greetService struct{ greeter greeter }
+
+func TestContainerResolutionOrder(t *testing.T) {
+	var built []string
+
+	c := NewContainer()
+	Provide(c, func(c *Container) (*greetService, error) {
+		g, err := Resolve[greeter](c)
+		if err != nil {
+			return nil, err
+		}
+		built = append(built, "service")
+		return &greetService{greeter: g}, nil
+	})
+	Provide(c, func(c *Container) (greeter, error) {
+		built = append(built, "greeter")
+		return staticGreeter("hello"), nil
+	})
+
+	svc, err := Resolve[*greetService](c)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", svc.greeter.Greet())
+
+	again, err := Resolve[*greetService](c)
+	require.NoError(t, err)
+	assert.Same(t, svc, again)
+	assert.Equal(t, []string{"greeter", "service"}, built)
+}
+
+func TestContainerNamedBindings(t *testing.T) {
+	c := NewContainer()
+	Provide(c, func(*Container) (greeter, error) { return staticGreeter("default"), nil })
+	ProvideNamed(c, "formal", func(*Container) (greeter, error) { return staticGreeter("good morning"), nil })
+
+	g, err := Resolve[greeter](c)
+	require.NoError(t, err)
+	assert.Equal(t, "default", g.Greet())
+
+	formal, err := ResolveNamed[greeter](c, "formal")
+	require.NoError(t, err)
+	assert.Equal(t, "good morning", formal.Greet())
+
+	_, err = ResolveNamed[greeter](c, "casual")
+	assert.ErrorIs(t, err, ErrNoProvider)
+	assert.EqualError(t, err, "wotop: no provider for wotop.greeter(casual)")
+}
+
+func TestContainerCycle(t *testing.T) {
+	c := NewContainer()
+	Provide(c, func(c *Container) (*greetService, error) {
+		g, err := Resolve[greeter](c)
+		return &greetService{greeter: g}, err
+	})
+	Provide(c, func(c *Container) (greeter, error) {
+		_, err := Resolve[*greetService](c)
+		return staticGreeter("never"), err
+	})
+
+	_, err := Resolve[*greetService](c)
+	assert.ErrorIs(t, err, ErrDependencyCycle)
+	assert.EqualError(t, err, "wotop: dependency cycle: *wotop.greetService -> wotop.greeter -> *wotop.greetService")
+}
+
+func TestContainerConstructorError(t *testing.T) {
+	boom := errors.New("boom")
+
+	c := NewContainer()
+	Provide(c, func(c *Container) (*greetService, error) {
+		g, err := Resolve[greeter](c)
+		return &greetService{greeter: g}, err
+	})
+	Provide(c, func(*Container) (greeter, error) { return nil, boom })
+
+	_, err := Resolve[*greetService](c)
+	assert.ErrorIs(t, err, boom)
+	assert.EqualError(t, err, "wotop: cannot build *wotop.greetService: wotop: cannot build wotop.greeter: boom")
+}
+
+func TestContainerNilInstance(t *testing.T) {
+	c := NewContainer()
+	Provide(c, func(c *Container) (*greetService, error) {
+		g, err := Resolve[greeter](c)
+		return &greetService{greeter: g}, err
+	})
+	Provide(c, func(*Container) (greeter, error) { return nil, nil })
+
+	var g greeter
+	assert.NotPanics(t, func() {
+		var err error
+		g, err = Resolve[greeter](c)
+		assert.ErrorIs(t, err, ErrNilInstance)
+		assert.EqualError(t, err, "wotop: nil instance for wotop.greeter")
+	})
+	assert.Nil(t, g)
+
+	_, err := Resolve[*greetService](c)
+	assert.ErrorIs(t, err, ErrNilInstance, "the dependents fail too")
+	assert.EqualError(t, err, "wotop: nil instance for wotop.greeter")
+
+	// a nil pointer is a value of its type
+	ProvideNamed(c, "none", func(*Container) (*greetService, error) { return nil, nil })
+	s, err := ResolveNamed[*greetService](c, "none")
+	assert.NoError(t, err)
+	assert.Nil(t, s)
+}
+
+func TestContainerOverride(t *testing.T) {
+	c := NewContainer()
+	Provide(c, func(*Container) (greeter, error) { return staticGreeter("real"), nil })
+	Provide(c, func(c *Container) (*greetService, error) {
+		g, err := Resolve[greeter](c)
+		return &greetService{greeter: g}, err
+	})
+
+	Override(c, func(*Container) (greeter, error) { return staticGreeter("fake"), nil })
+
+	svc, err := Resolve[*greetService](c)
+	require.NoError(t, err)
+	assert.Equal(t, "fake", svc.greeter.Greet())
+
+	// overriding drops the instance built before
+	Override(c, func(*Container) (greeter, error) { return staticGreeter("other fake"), nil })
+	assert.Equal(t, "other fake", MustResolve[greeter](c).Greet())
+}
+
+func TestContainerProvideTwicePanics(t *testing.T) {
+	c := NewContainer()
+	Provide(c, func(*Container) (greeter, error) { return staticGreeter("a"), nil })
+
+	assert.PanicsWithValue(t, "wotop: wotop.greeter is already provided", func() {
+		Provide(c, func(*Container) (greeter, error) { return staticGreeter("b"), nil })
+	})
+}
+
+func TestContainerConcurrentResolve(t *testing.T) {
+	var calls atomic.Int32
+
+	c := NewContainer()
+	Provide(c, func(*Container) (greeter, error) {
+		calls.Add(1)
+		return staticGreeter("hello"), nil
+	})
+	Provide(c, func(c *Container) (*greetService, error) {
+		g, err := Resolve[greeter](c)
+		return &greetService{greeter: g}, err
+	})
+
+	var wg sync.WaitGroup
+	services := make([]*greetService, 50)
+	for i := range services {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			services[i] = MustResolve[*greetService](c)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+	for _, svc := range services {
+		assert.Same(t, services[0], svc)
+	}
+}
@@ -0,0 +1,106 @@
+package wotop
+
+import "context"
+
+// Identity describes the authenticated caller of a request.
+//
+// Fields:
+//   - ID: The identifier of the user.
+//   - Role: The role of the user.
+//   - Tenant: The tenant the user belongs to.
+type Identity struct {
+	ID     string `json:"id"`
+	Role   string `json:"role"`
+	Tenant string `json:"tenant"`
+}
+
+// RequestContext is the request metadata carried by a context, so interactors can read it
+// without knowing the transport the request came from.
+//
+// Fields:
+//   - Identity: The authenticated caller, the zero value for anonymous requests.
+//   - Authenticated: Whether the identity was set by an authentication middleware.
+//   - TraceID: The trace ID of the request, empty when none was set.
+type RequestContext struct {
+	Identity      Identity
+	Authenticated bool
+	TraceID       string
+}
+
+type requestContextKey int
+
+const (
+	identityKey requestContextKey = iota // Key used to store and retrieve the identity in the context.
+	traceIDKey                           // Key used to store and retrieve the trace ID, shared with package logger.
+)
+
+// WithIdentity returns a copy of the context carrying the identity of the caller.
+//
+// Parameters:
+//   - ctx: The parent context.
+//   - identity: The authenticated caller.
+//
+// Returns:
+//   - A new context containing the identity.
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityKey, identity)
+}
+
+// IdentityFromContext retrieves the identity stored by WithIdentity.
+//
+// Parameters:
+//   - ctx: The context from which the identity will be retrieved.
+//
+// Returns:
+//   - The identity, the zero value when there is none.
+//   - A boolean indicating whether the context carries an identity.
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	if ctx == nil {
+		return Identity{}, false
+	}
+	identity, ok := ctx.Value(identityKey).(Identity)
+	return identity, ok
+}
+
+// WithTraceID returns a copy of the context carrying the trace ID. It is the key
+// logger.SetTraceID and logger.GetTraceID use, so both packages see the same trace ID.
+//
+// Parameters:
+//   - ctx: The parent context.
+//   - traceID: The trace ID of the request.
+//
+// Returns:
+//   - A new context containing the trace ID.
+func WithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey, traceID)
+}
+
+// TraceIDFromContext retrieves the trace ID stored by WithTraceID.
+//
+// Parameters:
+//   - ctx: The context from which the trace ID will be retrieved.
+//
+// Returns:
+//   - The trace ID, empty when there is none.
+//   - A boolean indicating whether the context carries a trace ID.
+func TraceIDFromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	traceID, ok := ctx.Value(traceIDKey).(string)
+	return traceID, ok
+}
+
+// RequestContextFrom collects the request metadata carried by the context.
+//
+// Parameters:
+//   - ctx: The context of the request.
+//
+// Returns:
+//   - The RequestContext of the request.
+func RequestContextFrom(ctx context.Context) RequestContext {
+	var rc RequestContext
+	rc.Identity, rc.Authenticated = IdentityFromContext(ctx)
+	rc.TraceID, _ = TraceIDFromContext(ctx)
+	return rc
+}
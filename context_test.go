@@ -0,0 +1,28 @@
+package wotop
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestContext(t *testing.T) {
+	ctx := context.Background()
+
+	_, ok := IdentityFromContext(ctx)
+	assert.False(t, ok)
+	assert.Equal(t, RequestContext{}, RequestContextFrom(ctx))
+
+	ctx = WithTraceID(WithIdentity(ctx, Identity{ID: "1", Role: "admin", Tenant: "acme"}), "trace")
+
+	identity, ok := IdentityFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, Identity{ID: "1", Role: "admin", Tenant: "acme"}, identity)
+
+	traceID, ok := TraceIDFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "trace", traceID)
+
+	assert.Equal(t, RequestContext{Identity: identity, Authenticated: true, TraceID: "trace"}, RequestContextFrom(ctx))
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"reflect"
+	"sort"
 	"strings"
 )
 
@@ -48,16 +49,20 @@ type ControllerRegisterer interface {
 }
 
 // RabbitmqConsumerRegisterer defines an interface for registering and consuming RabbitMQ messages.
+// The rabbitmq_controller package implements it: ConsumeMessage decodes the pubsub.EventData of
+// the message, executes the inport registered for the name of the event with the ID of the event
+// as trace ID, and acks or nacks the message by the result.
 type RabbitmqConsumerRegisterer interface {
 	UsecaseRegisterer
 
 	// Start initializes and starts the RabbitMQ consumer.
 	Start()
 
-	// ConsumeMessage processes a RabbitMQ message.
+	// ConsumeMessage processes a RabbitMQ message and settles it.
 	//
 	// Parameters:
-	//   - index: The index of the message.
+	//   - index: The position of the message among the ones received since the consumer started,
+	//     for the logs, it does not identify the message across restarts.
 	//   - msg: The RabbitMQ message to be consumed.
 	ConsumeMessage(index int, msg *amqp.Delivery)
 }
@@ -72,12 +77,12 @@ type ServiceRegisterer interface {
 
 // BaseController provides a base implementation for use case registration.
 type BaseController struct {
-	inportObjs map[any]any // A map to store registered use cases.
+	inportObjs map[string]any // The registered use cases keyed by the import path of their package.
 }
 
 // BaseConsumer provides a base implementation for use case registration in consumers.
 type BaseConsumer struct {
-	inportObjs map[any]any // A map to store registered use cases.
+	inportObjs map[string]any // The registered use cases keyed by the import path of their package.
 }
 
 // NewBaseController creates a new instance of BaseController.
@@ -86,7 +91,7 @@ type BaseConsumer struct {
 //   - A UsecaseRegisterer instance for registering use cases.
 func NewBaseController() UsecaseRegisterer {
 	return &BaseController{
-		inportObjs: map[any]any{},
+		inportObjs: map[string]any{},
 	}
 }
 
@@ -96,7 +101,7 @@ func NewBaseController() UsecaseRegisterer {
 //   - A UsecaseRegisterer instance for registering use cases.
 func NewBaseConsumer() UsecaseRegisterer {
 	return &BaseConsumer{
-		inportObjs: map[any]any{},
+		inportObjs: map[string]any{},
 	}
 }
 
@@ -106,7 +111,7 @@ func NewBaseConsumer() UsecaseRegisterer {
 //   - A UsecaseRegisterer instance for registering use cases.
 func NewBaseService() UsecaseRegisterer {
 	return &BaseConsumer{
-		inportObjs: map[any]any{},
+		inportObjs: map[string]any{},
 	}
 }
 
@@ -118,26 +123,17 @@ func NewBaseService() UsecaseRegisterer {
 // Returns:
 //   - The registered use case, or an error if it is not found.
 func (r *BaseController) GetUsecase(nameStructType any) (any, error) {
-	x := reflect.TypeOf(nameStructType).String()
-	packageName := x[:strings.Index(x, ".")]
-	uc, ok := r.inportObjs[packageName]
-	if !ok {
-		msg := "usecase with package \"%s\" is not registered yet in application"
-		return nil, fmt.Errorf(msg, packageName)
-	}
-	return uc, nil
+	return getUsecase(r.inportObjs, nameStructType)
 }
 
-// AddUsecase registers one or more use cases.
+// AddUsecase registers one or more use cases under the import path of their package. Inports
+// decorated with middlewares built by Wrap are registered under the package of their interactor.
+// It panics when a use case of the same package is registered already.
 //
 // Parameters:
 //   - inports: Variadic parameter representing the use cases to be registered.
 func (r *BaseController) AddUsecase(inports ...any) {
-	for _, inport := range inports {
-		x := reflect.ValueOf(inport).Elem().Type().String()
-		packagePath := x[:strings.Index(x, ".")]
-		r.inportObjs[packagePath] = inport
-	}
+	addUsecase(r.inportObjs, inports...)
 }
 
 // GetUsecase retrieves a registered use case by its type.
@@ -148,24 +144,75 @@ func (r *BaseController) AddUsecase(inports ...any) {
 // Returns:
 //   - The registered use case, or an error if it is not found.
 func (b BaseConsumer) GetUsecase(nameStructType any) (any, error) {
-	x := reflect.TypeOf(nameStructType).String()
-	packageName := x[:strings.Index(x, ".")]
-	uc, ok := b.inportObjs[packageName]
-	if !ok {
-		msg := "usecase with package \"%s\" is not registered yet in application"
-		return nil, fmt.Errorf(msg, packageName)
-	}
-	return uc, nil
+	return getUsecase(b.inportObjs, nameStructType)
 }
 
-// AddUsecase registers one or more use cases.
+// AddUsecase registers one or more use cases under the import path of their package. Inports
+// decorated with middlewares built by Wrap are registered under the package of their interactor.
+// It panics when a use case of the same package is registered already.
 //
 // Parameters:
 //   - inports: Variadic parameter representing the use cases to be registered.
 func (b BaseConsumer) AddUsecase(inports ...any) {
+	addUsecase(b.inportObjs, inports...)
+}
+
+// packagePath returns the import path of the package declaring the type of v, or of the type
+// it points to.
+func packagePath(v any) string {
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil {
+		return ""
+	}
+	return t.PkgPath()
+}
+
+// addUsecase registers the use cases in inportObjs.
+//
+// Parameters:
+//   - inportObjs: The registered use cases keyed by package path.
+//   - inports: The use cases to be registered.
+func addUsecase(inportObjs map[string]any, inports ...any) {
 	for _, inport := range inports {
-		x := reflect.ValueOf(inport).Elem().Type().String()
-		packagePath := x[:strings.Index(x, ".")]
-		b.inportObjs[packagePath] = inport
+		interactor := unwrapInport(inport)
+		path := packagePath(interactor)
+		if path == "" {
+			panic(fmt.Sprintf("wotop: usecase %T is not declared in a usecase package", interactor))
+		}
+		if existing, ok := inportObjs[path]; ok {
+			panic(fmt.Sprintf("wotop: usecase %T cannot be registered, package %q is already registered by %T", interactor, path, unwrapInport(existing)))
+		}
+		inportObjs[path] = inport
 	}
 }
+
+// getUsecase looks up the use case registered for the package of the given type.
+//
+// Parameters:
+//   - inportObjs: The registered use cases keyed by package path.
+//   - nameStructType: A value of a type declared in the package of the use case.
+//
+// Returns:
+//   - The registered use case, or an error listing the registered packages if it is not found.
+func getUsecase(inportObjs map[string]any, nameStructType any) (any, error) {
+	path := packagePath(nameStructType)
+	if path == "" {
+		return nil, fmt.Errorf("type %T is not declared in a usecase package", nameStructType)
+	}
+
+	uc, ok := inportObjs[path]
+	if !ok {
+		registered := make([]string, 0, len(inportObjs))
+		for p := range inportObjs {
+			registered = append(registered, fmt.Sprintf("%q", p))
+		}
+		sort.Strings(registered)
+
+		msg := "usecase with package \"%s\" is not registered yet in application, registered packages: [%s]"
+		return nil, fmt.Errorf(msg, path, strings.Join(registered, ", "))
+	}
+	return uc, nil
+}
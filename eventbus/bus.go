@@ -0,0 +1,399 @@
+// Package eventbus dispatches the domain events in process, so the domains of a monolith react
+// to each other's events instead of importing each other's use cases. The events are wrapped in
+// the pubsub.EventData envelope, so a handler can later consume them from RabbitMQ unchanged,
+// and the bus can publish them to RabbitMQ too, see WithRemote.
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"sync"
+
+	"github.com/a-aslani/wotop/logger"
+	"github.com/a-aslani/wotop/pubsub"
+	"github.com/google/uuid"
+)
+
+// Named is implemented by the events naming themselves, e.g. "order.placed". The other events
+// are named after their type, e.g. "order.OrderPlaced".
+type Named interface {
+	EventName() string
+}
+
+// Publisher publishes the events to a broker. It is implemented by *pubsub.Event.
+type Publisher interface {
+	PublishData(data pubsub.EventData) error
+}
+
+var _ Publisher = (*pubsub.Event)(nil)
+
+// Mode is where Publish sends the events.
+type Mode int
+
+const (
+	// ModeMemory dispatches the events to the handlers subscribed to the bus.
+	ModeMemory Mode = iota
+	// ModeRemote publishes the events to the Publisher only.
+	ModeRemote
+	// ModeBoth dispatches the events in process and publishes them, e.g. while the handlers
+	// are moved to another service.
+	ModeBoth
+)
+
+// ParseMode parses the mode of a configuration: "memory", "rabbitmq" or "both".
+//
+// Parameters:
+//   - s: The mode.
+//
+// Returns:
+//   - The mode.
+//   - An error if the mode is unknown.
+func ParseMode(s string) (Mode, error) {
+	switch s {
+	case "", "memory":
+		return ModeMemory, nil
+	case "rabbitmq":
+		return ModeRemote, nil
+	case "both":
+		return ModeBoth, nil
+	}
+	return ModeMemory, fmt.Errorf("eventbus: unknown mode %q, expected memory, rabbitmq or both", s)
+}
+
+// ErrClosed is returned by Publish once the bus is closed.
+var ErrClosed = errors.New("eventbus: the bus is closed")
+
+// Bus dispatches the events to the handlers subscribed to their type. It is safe for
+// concurrent use.
+//
+// The events are dispatched synchronously by default: Publish runs the handlers one after the
+// other and returns their errors. With WithAsync, Publish queues the events for a pool of
+// workers and returns at once, the errors are logged. In both modes a failing or panicking
+// handler does not prevent the other handlers from getting the event.
+type Bus struct {
+	mu            sync.RWMutex
+	subscriptions []*subscription
+	closed        bool
+
+	opts    options
+	workers []chan job
+	wg      sync.WaitGroup
+	sending sync.WaitGroup // the Publish calls queueing events, the queues are closed after them
+	stop    chan struct{}  // closed by Close, so the Publish calls waiting for a full queue return
+}
+
+// subscription is a handler subscribed to the events of a type.
+type subscription struct {
+	typ     reflect.Type
+	handler func(ctx context.Context, event any) error
+	worker  int // the worker of the async handler, so it gets the events in order
+}
+
+// job is an event queued for an async handler.
+type job struct {
+	ctx  context.Context
+	sub  *subscription
+	data pubsub.EventData
+}
+
+// options holds the settings of the bus.
+type options struct {
+	workers   int
+	queueSize int
+	mode      Mode
+	remote    Publisher
+	log       logger.Logger
+}
+
+// Option configures a Bus.
+type Option func(*options)
+
+// WithAsync dispatches the events asynchronously, with a pool of workers. The events of a
+// handler are handled by the same worker, in the order they were published. Publish waits while
+// the queue of a worker is full, until its context is done or the bus is closed.
+//
+// Parameters:
+//   - workers: The number of workers, 1 when lower.
+//   - queueSize: The number of events queued per worker.
+//
+// Returns:
+//   - The option enabling the async dispatch.
+func WithAsync(workers, queueSize int) Option {
+	return func(o *options) {
+		o.workers, o.queueSize = max(workers, 1), max(queueSize, 0)
+	}
+}
+
+// WithRemote publishes the events to a broker too, or instead of dispatching them in process.
+//
+// Parameters:
+//   - publisher: The publisher, usually the *pubsub.Event of the application.
+//   - mode: Where the events are sent, ModeMemory ignores the publisher.
+//
+// Returns:
+//   - The option setting the publisher.
+func WithRemote(publisher Publisher, mode Mode) Option {
+	return func(o *options) {
+		o.remote, o.mode = publisher, mode
+	}
+}
+
+// WithLogger sets the logger of the failed handlers.
+//
+// Parameters:
+//   - log: The logger, none by default.
+//
+// Returns:
+//   - The option setting the logger.
+func WithLogger(log logger.Logger) Option {
+	return func(o *options) {
+		o.log = log
+	}
+}
+
+// New creates a Bus, whose workers run until Close when it is async.
+//
+// Parameters:
+//   - opts: The async dispatch, the remote publisher and the logger.
+//
+// Returns:
+//   - The bus.
+func New(opts ...Option) *Bus {
+	b := &Bus{stop: make(chan struct{})}
+	for _, opt := range opts {
+		opt(&b.opts)
+	}
+
+	for range b.opts.workers {
+		queue := make(chan job, b.opts.queueSize)
+		b.workers = append(b.workers, queue)
+		b.wg.Add(1)
+		go b.work(queue)
+	}
+
+	return b
+}
+
+// Subscribe subscribes a handler to the events of type T, or implementing T when it is an
+// interface. A *E and an E are distinct types.
+//
+// Parameters:
+//   - b: The bus.
+//   - handler: The handler, the envelope of the event is in its context, see EventDataFromContext.
+//
+// Returns:
+//   - The function unsubscribing the handler.
+func Subscribe[T any](b *Bus, handler func(ctx context.Context, event T) error) (unsubscribe func()) {
+
+	sub := &subscription{
+		typ: reflect.TypeOf((*T)(nil)).Elem(),
+		handler: func(ctx context.Context, event any) error {
+			return handler(ctx, event.(T))
+		},
+	}
+
+	b.mu.Lock()
+	if len(b.workers) > 0 {
+		sub.worker = len(b.subscriptions) % len(b.workers)
+	}
+	b.subscriptions = append(b.subscriptions, sub)
+	b.mu.Unlock()
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for i, s := range b.subscriptions {
+			if s == sub {
+				b.subscriptions = append(b.subscriptions[:i:i], b.subscriptions[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Publish sends an event, wrapped in an envelope with a new ID, to the handlers subscribed to
+// its type and, depending on the mode, to the broker.
+//
+// Parameters:
+//   - ctx: The context of the operation, its values are passed to the handlers.
+//   - event: The event, named after Named or its type.
+//
+// Returns:
+//   - The errors of the sync handlers and of the broker, joined, ErrClosed once the bus is
+//     closed, also when it is closed while an async queue is full, or the error of ctx when it
+//     is done while an async queue is full.
+func (b *Bus) Publish(ctx context.Context, event any) error {
+
+	data := pubsub.EventData{ID: uuid.NewString(), Name: EventName(event), Payload: event}
+
+	var errs []error
+
+	if b.opts.remote != nil && b.opts.mode != ModeMemory {
+		if err := b.opts.remote.PublishData(data); err != nil {
+			errs = append(errs, fmt.Errorf("eventbus: cannot publish %s: %w", data.Name, err))
+		}
+		if b.opts.mode == ModeRemote {
+			return errors.Join(errs...)
+		}
+	}
+
+	b.mu.RLock()
+
+	if b.closed {
+		b.mu.RUnlock()
+		return ErrClosed
+	}
+
+	typ := reflect.TypeOf(event)
+	subs := make([]*subscription, 0, len(b.subscriptions))
+	for _, sub := range b.subscriptions {
+		if typ == sub.typ || (sub.typ.Kind() == reflect.Interface && typ != nil && typ.Implements(sub.typ)) {
+			subs = append(subs, sub)
+		}
+	}
+
+	if len(b.workers) > 0 {
+		// the lock is released before queueing, so the handlers blocking a full queue may
+		// subscribe or publish, and Close waits for the sends instead of the queues being closed
+		// under them
+		b.sending.Add(1)
+		b.mu.RUnlock()
+		defer b.sending.Done()
+		return errors.Join(append(errs, b.enqueue(ctx, subs, data))...)
+	}
+
+	// the sync handlers may subscribe or publish
+	b.mu.RUnlock()
+	for _, sub := range subs {
+		if err := b.handle(ctx, sub, data); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// enqueue queues an event for the async handlers. The jobs keep the values of ctx only, as the
+// handlers outlive the request.
+func (b *Bus) enqueue(ctx context.Context, subs []*subscription, data pubsub.EventData) error {
+	jobCtx := context.WithoutCancel(ctx)
+	for _, sub := range subs {
+		select {
+		case b.workers[sub.worker] <- job{ctx: jobCtx, sub: sub, data: data}:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-b.stop:
+			return ErrClosed
+		}
+	}
+	return nil
+}
+
+// work handles the events queued for a worker until the bus is closed.
+func (b *Bus) work(queue <-chan job) {
+	defer b.wg.Done()
+	for j := range queue {
+		if err := b.handle(j.ctx, j.sub, j.data); err != nil && b.opts.log != nil {
+			b.opts.log.Error(j.ctx, "%v", err)
+		}
+	}
+}
+
+// handle runs a handler, turning its panic into an error.
+func (b *Bus) handle(ctx context.Context, sub *subscription, data pubsub.EventData) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("eventbus: handler of %s panicked: %v\n%s", data.Name, r, debug.Stack())
+		}
+	}()
+
+	if err = sub.handler(withEventData(ctx, data), data.Payload); err != nil {
+		return fmt.Errorf("eventbus: handler of %s failed: %w", data.Name, err)
+	}
+	return nil
+}
+
+// Close stops the async workers once they handled the queued events, and rejects the events
+// published after it.
+//
+// Parameters:
+//   - ctx: The context bounding the wait for the queued events.
+//
+// Returns:
+//   - The error of ctx if it is done before the queued events are handled.
+func (b *Bus) Close(ctx context.Context) error {
+
+	b.mu.Lock()
+	closing := !b.closed
+	if closing {
+		b.closed = true
+		close(b.stop)
+	}
+	b.mu.Unlock()
+
+	if closing {
+		// no Publish queues events once the bus is closed and the waiting ones returned
+		b.sending.Wait()
+		for _, queue := range b.workers {
+			close(queue)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// EventName returns the name of an event: its EventName when it implements Named, else its
+// type, e.g. "order.OrderPlaced" for an order.OrderPlaced or a *order.OrderPlaced.
+//
+// Parameters:
+//   - event: The event.
+//
+// Returns:
+//   - The name of the event.
+func EventName(event any) string {
+	if n, ok := event.(Named); ok {
+		return n.EventName()
+	}
+	typ := reflect.TypeOf(event)
+	for typ != nil && typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ == nil {
+		return "nil"
+	}
+	return typ.String()
+}
+
+type eventDataKey struct{}
+
+func withEventData(ctx context.Context, data pubsub.EventData) context.Context {
+	return context.WithValue(ctx, eventDataKey{}, data)
+}
+
+// EventDataFromContext returns the envelope of the event a handler is called with, e.g. to
+// deduplicate the events by ID like the consumers of RabbitMQ do.
+//
+// Parameters:
+//   - ctx: The context of the handler.
+//
+// Returns:
+//   - The envelope, its Payload is the event.
+//   - Whether the context is the one of a handler.
+func EventDataFromContext(ctx context.Context) (pubsub.EventData, bool) {
+	data, ok := ctx.Value(eventDataKey{}).(pubsub.EventData)
+	return data, ok
+}
@@ -0,0 +1,260 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/logger"
+	"github.com/a-aslani/wotop/logger/loggertest"
+	"github.com/a-aslani/wotop/pubsub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type orderPlaced struct {
+	OrderID string `json:"order_id"`
+	Seq     int    `json:"seq"`
+}
+
+type orderCancelled struct {
+	OrderID string `json:"order_id"`
+}
+
+func (orderCancelled) EventName() string { return "order.cancelled" }
+
+// orderEvent is implemented by the events of an order.
+type orderEvent interface{ order() string }
+
+func (e orderPlaced) order() string    { return e.OrderID }
+func (e orderCancelled) order() string { return e.OrderID }
+
+// recordingPublisher records the envelopes published to the broker.
+type recordingPublisher struct {
+	mu        sync.Mutex
+	published []pubsub.EventData
+	err       error
+}
+
+func (p *recordingPublisher) PublishData(data pubsub.EventData) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.published = append(p.published, data)
+	return p.err
+}
+
+func TestTypedRouting(t *testing.T) {
+	bus := New()
+	ctx := context.Background()
+
+	var placed []orderPlaced
+	var all []string
+	Subscribe(bus, func(ctx context.Context, e orderPlaced) error {
+		placed = append(placed, e)
+		return nil
+	})
+	Subscribe(bus, func(ctx context.Context, e orderEvent) error {
+		data, ok := EventDataFromContext(ctx)
+		require.True(t, ok)
+		all = append(all, data.Name+" "+e.order())
+		return nil
+	})
+	unsubscribe := Subscribe(bus, func(ctx context.Context, e orderCancelled) error {
+		t.Error("the unsubscribed handler is called")
+		return nil
+	})
+	unsubscribe()
+
+	require.NoError(t, bus.Publish(ctx, orderPlaced{OrderID: "o1"}))
+	require.NoError(t, bus.Publish(ctx, orderCancelled{OrderID: "o1"}))
+	require.NoError(t, bus.Publish(ctx, &orderPlaced{OrderID: "o2"}))
+
+	assert.Equal(t, []orderPlaced{{OrderID: "o1"}}, placed, "a *orderPlaced is not an orderPlaced")
+	assert.Equal(t, []string{"eventbus.orderPlaced o1", "order.cancelled o1", "eventbus.orderPlaced o2"}, all)
+}
+
+func TestHandlerErrorIsolation(t *testing.T) {
+	bus := New()
+	failed := errors.New("failed")
+
+	calls := 0
+	Subscribe(bus, func(context.Context, orderPlaced) error { return failed })
+	Subscribe(bus, func(context.Context, orderPlaced) error { panic("boom") })
+	Subscribe(bus, func(context.Context, orderPlaced) error {
+		calls++
+		return nil
+	})
+
+	err := bus.Publish(context.Background(), orderPlaced{OrderID: "o1"})
+	assert.ErrorIs(t, err, failed)
+	assert.ErrorContains(t, err, "eventbus: handler of eventbus.orderPlaced panicked: boom")
+	assert.Equal(t, 1, calls, "the handlers after the failing ones get the event")
+}
+
+func TestAsyncDispatchKeepsOrder(t *testing.T) {
+	log := &loggertest.Recorder{}
+	bus := New(WithAsync(4, 8), WithLogger(log))
+
+	const handlers, events = 6, 200
+	received := make([][]int, handlers)
+	var mu sync.Mutex
+	for h := range handlers {
+		Subscribe(bus, func(ctx context.Context, e orderPlaced) error {
+			_, canceled := ctx.Deadline()
+			assert.False(t, canceled)
+			mu.Lock()
+			received[h] = append(received[h], e.Seq)
+			mu.Unlock()
+			if h == 0 && e.Seq == 10 {
+				return errors.New("failed")
+			}
+			return nil
+		})
+	}
+
+	ctx := wotop.WithTraceID(context.Background(), "trace-1")
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	for i := range events {
+		require.NoError(t, bus.Publish(ctx, orderPlaced{OrderID: "o", Seq: i}))
+	}
+	cancel()
+
+	require.NoError(t, bus.Close(context.Background()), "the queued events are handled")
+	for h := range handlers {
+		require.Len(t, received[h], events)
+		for i, seq := range received[h] {
+			require.Equal(t, i, seq, "handler %d gets the events in order", h)
+		}
+	}
+	assert.Equal(t, []string{"eventbus: handler of eventbus.orderPlaced failed: failed"}, log.Messages(logger.LevelError))
+
+	assert.ErrorIs(t, bus.Publish(context.Background(), orderPlaced{}), ErrClosed)
+}
+
+func TestAsyncPublishWaitsForFullQueue(t *testing.T) {
+	bus := New(WithAsync(1, 1))
+	release := make(chan struct{})
+	Subscribe(bus, func(context.Context, orderPlaced) error {
+		<-release
+		return nil
+	})
+
+	ctx := context.Background()
+	require.NoError(t, bus.Publish(ctx, orderPlaced{Seq: 1})) // handled
+	require.Eventually(t, func() bool { return bus.Publish(ctx, orderPlaced{Seq: 2}) == nil }, time.Second, time.Millisecond)
+
+	timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, bus.Publish(timeout, orderPlaced{Seq: 3}), context.DeadlineExceeded)
+
+	close(release)
+	require.NoError(t, bus.Close(ctx))
+}
+
+func TestAsyncHandlerSubscribesWhileQueueIsFull(t *testing.T) {
+	bus := New(WithAsync(1, 1))
+	subscribe, subscribed := make(chan struct{}), make(chan struct{})
+	Subscribe(bus, func(ctx context.Context, e orderPlaced) error {
+		if e.Seq == 1 {
+			<-subscribe
+			Subscribe(bus, func(context.Context, orderCancelled) error { return nil })
+			close(subscribed)
+		}
+		return nil
+	})
+
+	ctx := context.Background()
+	require.NoError(t, bus.Publish(ctx, orderPlaced{Seq: 1})) // handled, waiting
+	require.Eventually(t, func() bool { return bus.Publish(ctx, orderPlaced{Seq: 2}) == nil }, time.Second, time.Millisecond)
+
+	published := make(chan error, 1)
+	go func() { published <- bus.Publish(ctx, orderPlaced{Seq: 3}) }() // waits for the full queue
+	time.Sleep(20 * time.Millisecond)
+
+	close(subscribe)
+	select {
+	case <-subscribed:
+	case <-time.After(time.Second):
+		t.Fatal("the handler cannot subscribe while Publish waits for the full queue")
+	}
+	require.NoError(t, <-published)
+	require.NoError(t, bus.Close(ctx))
+}
+
+func TestAsyncCloseReleasesWaitingPublish(t *testing.T) {
+	bus := New(WithAsync(1, 1))
+	release := make(chan struct{})
+	Subscribe(bus, func(context.Context, orderPlaced) error {
+		<-release
+		return nil
+	})
+
+	ctx := context.Background()
+	require.NoError(t, bus.Publish(ctx, orderPlaced{Seq: 1}))
+	require.Eventually(t, func() bool { return bus.Publish(ctx, orderPlaced{Seq: 2}) == nil }, time.Second, time.Millisecond)
+
+	published := make(chan error, 1)
+	go func() { published <- bus.Publish(ctx, orderPlaced{Seq: 3}) }()
+	time.Sleep(20 * time.Millisecond)
+
+	closed := make(chan error, 1)
+	go func() { closed <- bus.Close(ctx) }()
+	assert.ErrorIs(t, <-published, ErrClosed)
+
+	close(release)
+	require.NoError(t, <-closed)
+}
+
+func TestRemoteModes(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("both", func(t *testing.T) {
+		remote := &recordingPublisher{}
+		bus := New(WithRemote(remote, ModeBoth))
+
+		var id string
+		Subscribe(bus, func(ctx context.Context, e orderCancelled) error {
+			data, _ := EventDataFromContext(ctx)
+			id = data.ID
+			return nil
+		})
+
+		require.NoError(t, bus.Publish(ctx, orderCancelled{OrderID: "o1"}))
+		require.Len(t, remote.published, 1)
+		assert.Equal(t, "order.cancelled", remote.published[0].Name)
+		assert.Equal(t, orderCancelled{OrderID: "o1"}, remote.published[0].Payload)
+		assert.Equal(t, remote.published[0].ID, id, "the same envelope is dispatched and published")
+	})
+
+	t.Run("remote", func(t *testing.T) {
+		remote := &recordingPublisher{err: errors.New("broker down")}
+		bus := New(WithRemote(remote, ModeRemote))
+		Subscribe(bus, func(context.Context, orderCancelled) error {
+			t.Error("the events are not dispatched in process")
+			return nil
+		})
+
+		assert.ErrorContains(t, bus.Publish(ctx, orderCancelled{OrderID: "o1"}), "eventbus: cannot publish order.cancelled: broker down")
+		assert.Len(t, remote.published, 1)
+	})
+
+	t.Run("memory", func(t *testing.T) {
+		remote := &recordingPublisher{}
+		bus := New(WithRemote(remote, ModeMemory))
+		require.NoError(t, bus.Publish(ctx, orderCancelled{}))
+		assert.Empty(t, remote.published)
+	})
+}
+
+func TestParseMode(t *testing.T) {
+	for s, want := range map[string]Mode{"": ModeMemory, "memory": ModeMemory, "rabbitmq": ModeRemote, "both": ModeBoth} {
+		mode, err := ParseMode(s)
+		require.NoError(t, err)
+		assert.Equal(t, want, mode, s)
+	}
+	_, err := ParseMode("kafka")
+	assert.Error(t, err)
+}
@@ -0,0 +1,285 @@
+package eventstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/a-aslani/wotop/pubsub"
+)
+
+// Aggregate is an event sourced aggregate: its state is the result of applying its events in
+// order. Embed AggregateBase to implement everything but Apply.
+type Aggregate interface {
+	// StreamID returns the ID of the stream of the aggregate.
+	StreamID() string
+
+	// Version returns the version of the last event applied, 0 for a new aggregate.
+	Version() int
+
+	// Apply changes the state of the aggregate with an event.
+	Apply(event StoredEvent) error
+
+	// Changes returns the events raised since the aggregate was loaded or saved.
+	Changes() []StoredEvent
+
+	// Record adds a raised event to the changes.
+	Record(event StoredEvent)
+
+	// Commit clears the changes once they are stored, the aggregate being at version.
+	Commit(version int)
+}
+
+// AggregateBase implements the bookkeeping of an Aggregate.
+type AggregateBase struct {
+	id      string
+	version int
+	changes []StoredEvent
+}
+
+// NewAggregateBase creates the base of the aggregate of a stream.
+// Parameters:
+// - streamID: The ID of the stream of the aggregate.
+// Returns:
+// - AggregateBase: The base to embed.
+func NewAggregateBase(streamID string) AggregateBase {
+	return AggregateBase{id: streamID}
+}
+
+func (b *AggregateBase) StreamID() string { return b.id }
+
+func (b *AggregateBase) Version() int { return b.version }
+
+func (b *AggregateBase) Changes() []StoredEvent { return b.changes }
+
+func (b *AggregateBase) Record(event StoredEvent) { b.changes = append(b.changes, event) }
+
+func (b *AggregateBase) Commit(version int) {
+	b.version = version
+	b.changes = nil
+}
+
+// Raise applies a new event to an aggregate and records it in its changes, so it is appended
+// by AggregateRepository.Save.
+// Parameters:
+// - a: The aggregate.
+// - eventType: The type of the event, e.g. "AccountOpened".
+// - data: The data of the event, marshaled to JSON.
+// Returns:
+// - error: An error if the data cannot be marshaled or the aggregate rejects the event.
+func Raise(a Aggregate, eventType string, data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("cannot marshal the event %s: %w", eventType, err)
+	}
+
+	event := StoredEvent{
+		StreamID: a.StreamID(),
+		Version:  a.Version() + len(a.Changes()) + 1,
+		Type:     eventType,
+		Data:     raw,
+	}
+	if err := a.Apply(event); err != nil {
+		return err
+	}
+
+	a.Record(event)
+	return nil
+}
+
+// Publisher publishes the events of the saved aggregates. It is implemented by *pubsub.Event.
+type Publisher interface {
+	Publish(eventName string, payload pubsub.Payload) error
+}
+
+var _ Publisher = (*pubsub.Event)(nil)
+
+// AggregateRepository loads the aggregates by replaying their events and saves their changes.
+type AggregateRepository[T Aggregate] struct {
+	store     EventStore
+	factory   func(streamID string) T
+	publisher Publisher
+
+	snapshots     SnapshotStore // nil when the aggregates are not snapshotted
+	snapshotEvery int
+}
+
+// NewAggregateRepository creates an AggregateRepository.
+// Parameters:
+// - store: The event store of the aggregates.
+// - factory: Creates an empty aggregate, the events of its stream are applied to.
+// - publisher: Publishes the saved events, named after their type, nil to publish nothing.
+// Returns:
+// - *AggregateRepository[T]: The repository.
+func NewAggregateRepository[T Aggregate](store EventStore, factory func(streamID string) T, publisher Publisher) *AggregateRepository[T] {
+	return &AggregateRepository[T]{store: store, factory: factory, publisher: publisher}
+}
+
+// WithSnapshots makes the repository snapshot the aggregates implementing Snapshotter every
+// n events, and rehydrate them from their latest snapshot and the events following it.
+// Parameters:
+// - store: The snapshot store.
+// - every: The number of events between two snapshots of an aggregate, at least 1.
+// Returns:
+// - *AggregateRepository[T]: The repository, for chaining.
+func (r *AggregateRepository[T]) WithSnapshots(store SnapshotStore, every int) *AggregateRepository[T] {
+	r.snapshots = store
+	r.snapshotEvery = max(every, 1)
+	return r
+}
+
+// Load rehydrates an aggregate by applying the events of its stream in order, from its latest
+// snapshot when the repository has snapshots. A snapshot which cannot be restored or is ahead of
+// the stream, e.g. the stream was rebuilt, is ignored and all the events are replayed.
+// Parameters:
+// - ctx: The context of the operation.
+// - streamID: The ID of the stream of the aggregate.
+// Returns:
+// - T: The aggregate at the version of its last event.
+// - error: ErrStreamNotFound if the stream has no events, or an error if an event or the snapshot cannot be read or an event cannot be applied.
+func (r *AggregateRepository[T]) Load(ctx context.Context, streamID string) (T, error) {
+	var zero T
+
+	a, events, ok, err := r.loadSnapshot(ctx, streamID)
+	if err != nil {
+		return zero, err
+	}
+
+	if !ok {
+		a = r.factory(streamID)
+		events, err = r.store.ReadStream(ctx, streamID, 1)
+		if err != nil {
+			return zero, err
+		}
+		if len(events) == 0 {
+			return zero, ErrStreamNotFound.Var(streamID)
+		}
+	}
+
+	for _, e := range events {
+		if err := a.Apply(e); err != nil {
+			return zero, fmt.Errorf("cannot apply the event %d of the stream %s: %w", e.Version, streamID, err)
+		}
+	}
+
+	if len(events) > 0 {
+		a.Commit(events[len(events)-1].Version)
+	}
+	return a, nil
+}
+
+// loadSnapshot restores an aggregate from the latest snapshot of its stream.
+// Parameters:
+// - ctx: The context of the operation.
+// - streamID: The ID of the stream of the aggregate.
+// Returns:
+// - T: The aggregate at the version of the snapshot.
+// - []StoredEvent: The events following the snapshot.
+// - bool: False if there is no usable snapshot.
+// - error: An error if the snapshot or the events cannot be read.
+func (r *AggregateRepository[T]) loadSnapshot(ctx context.Context, streamID string) (T, []StoredEvent, bool, error) {
+	var zero T
+
+	if r.snapshots == nil {
+		return zero, nil, false, nil
+	}
+
+	a := r.factory(streamID)
+	snapshotter, ok := any(a).(Snapshotter)
+	if !ok {
+		return zero, nil, false, nil
+	}
+
+	version, state, err := r.snapshots.LoadSnapshot(ctx, streamID)
+	if err != nil {
+		return zero, nil, false, err
+	}
+	if state == nil || version < 1 {
+		return zero, nil, false, nil
+	}
+
+	// the event of the snapshot is read as well, to check the snapshot is not ahead of the stream
+	events, err := r.store.ReadStream(ctx, streamID, version)
+	if err != nil {
+		return zero, nil, false, err
+	}
+	if len(events) == 0 || events[0].Version != version {
+		return zero, nil, false, nil
+	}
+
+	if err := snapshotter.UnmarshalSnapshot(state); err != nil {
+		return zero, nil, false, nil
+	}
+
+	a.Commit(version)
+	return a, events[1:], true, nil
+}
+
+// Save appends the changes of an aggregate at the version it was loaded at, snapshots it when
+// it crossed a multiple of the snapshot interval, then publishes the changes. The changes are
+// committed once appended, even when they cannot be snapshotted or published. When ctx
+// carries a transaction, see postgres_db.WithTx, the events are published before it commits.
+// Parameters:
+// - ctx: The context of the operation.
+// - a: The aggregate.
+// Returns:
+// - error: ErrConcurrencyConflict if the stream changed since the aggregate was loaded, or an error if the events cannot be appended, snapshotted or published.
+func (r *AggregateRepository[T]) Save(ctx context.Context, a T) error {
+	changes := a.Changes()
+	if len(changes) == 0 {
+		return nil
+	}
+
+	events := make([]StoredEvent, len(changes))
+	copy(events, changes)
+
+	if err := r.store.AppendToStream(ctx, a.StreamID(), a.Version(), events); err != nil {
+		return err
+	}
+	from, to := a.Version(), events[len(events)-1].Version
+	a.Commit(to)
+
+	var errs []error
+	if err := r.saveSnapshot(ctx, a, from, to); err != nil {
+		errs = append(errs, err)
+	}
+
+	if r.publisher != nil {
+		for _, e := range events {
+			if err := r.publisher.Publish(e.Type, e); err != nil {
+				errs = append(errs, fmt.Errorf("cannot publish the event %d of the stream %s: %w", e.Version, e.StreamID, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// saveSnapshot snapshots an aggregate saved from a version to another when it crossed a multiple
+// of the snapshot interval.
+// Parameters:
+// - ctx: The context of the operation.
+// - a: The saved aggregate.
+// - from: The version of the aggregate before the save.
+// - to: The version of the aggregate after the save.
+// Returns:
+// - error: An error if the snapshot cannot be taken or stored.
+func (r *AggregateRepository[T]) saveSnapshot(ctx context.Context, a T, from, to int) error {
+	if r.snapshots == nil || from/r.snapshotEvery == to/r.snapshotEvery {
+		return nil
+	}
+
+	snapshotter, ok := any(a).(Snapshotter)
+	if !ok {
+		return nil
+	}
+
+	state, err := snapshotter.MarshalSnapshot()
+	if err == nil {
+		err = r.snapshots.SaveSnapshot(ctx, a.StreamID(), to, state)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot snapshot the stream %s at version %d: %w", a.StreamID(), to, err)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package eventstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/a-aslani/wotop/pubsub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// account is an event sourced bank account.
+type account struct {
+	AggregateBase
+	balance int
+}
+
+type amountChanged struct {
+	Amount int `json:"amount"`
+}
+
+func newAccount(id string) *account {
+	return &account{AggregateBase: NewAggregateBase(id)}
+}
+
+func (a *account) Apply(event StoredEvent) error {
+	var data amountChanged
+	if err := event.Decode(&data); err != nil {
+		return err
+	}
+
+	switch event.Type {
+	case "Deposited":
+		a.balance += data.Amount
+	case "Withdrawn":
+		if data.Amount > a.balance {
+			return fmt.Errorf("insufficient balance %d", a.balance)
+		}
+		a.balance -= data.Amount
+	default:
+		return fmt.Errorf("unknown event %s", event.Type)
+	}
+	return nil
+}
+
+// recordingPublisher is a Publisher recording the published events.
+type recordingPublisher struct {
+	names []string
+	err   error
+}
+
+func (p *recordingPublisher) Publish(eventName string, _ pubsub.Payload) error {
+	p.names = append(p.names, eventName)
+	return p.err
+}
+
+func TestAggregateRepositoryReplay(t *testing.T) {
+	ctx := context.Background()
+	publisher := &recordingPublisher{}
+	repo := NewAggregateRepository(NewMemoryEventStore(), newAccount, publisher)
+
+	a := newAccount("account-1")
+	require.NoError(t, Raise(a, "Deposited", amountChanged{Amount: 100}))
+	require.NoError(t, Raise(a, "Withdrawn", amountChanged{Amount: 30}))
+	assert.Error(t, Raise(a, "Withdrawn", amountChanged{Amount: 500}))
+	require.Len(t, a.Changes(), 2)
+
+	require.NoError(t, repo.Save(ctx, a))
+	assert.Equal(t, 2, a.Version())
+	assert.Empty(t, a.Changes())
+	assert.Equal(t, []string{"Deposited", "Withdrawn"}, publisher.names)
+
+	loaded, err := repo.Load(ctx, "account-1")
+	require.NoError(t, err)
+	assert.Equal(t, 70, loaded.balance)
+	assert.Equal(t, 2, loaded.Version())
+
+	require.NoError(t, Raise(loaded, "Deposited", amountChanged{Amount: 5}))
+	require.NoError(t, repo.Save(ctx, loaded))
+	assert.Equal(t, 3, loaded.Version())
+
+	loaded, err = repo.Load(ctx, "account-1")
+	require.NoError(t, err)
+	assert.Equal(t, 75, loaded.balance)
+}
+
+func TestAggregateRepositoryNotFound(t *testing.T) {
+	repo := NewAggregateRepository(NewMemoryEventStore(), newAccount, nil)
+
+	_, err := repo.Load(context.Background(), "account-1")
+	assertCode(t, err, ErrStreamNotFound)
+}
+
+func TestAggregateRepositoryConcurrencyConflict(t *testing.T) {
+	ctx := context.Background()
+	publisher := &recordingPublisher{}
+	repo := NewAggregateRepository(NewMemoryEventStore(), newAccount, publisher)
+
+	a := newAccount("account-1")
+	require.NoError(t, Raise(a, "Deposited", amountChanged{Amount: 100}))
+	require.NoError(t, repo.Save(ctx, a))
+
+	first, err := repo.Load(ctx, "account-1")
+	require.NoError(t, err)
+	second, err := repo.Load(ctx, "account-1")
+	require.NoError(t, err)
+
+	require.NoError(t, Raise(first, "Withdrawn", amountChanged{Amount: 80}))
+	require.NoError(t, Raise(second, "Withdrawn", amountChanged{Amount: 80}))
+
+	require.NoError(t, repo.Save(ctx, first))
+	err = repo.Save(ctx, second)
+	assertCode(t, err, ErrConcurrencyConflict)
+
+	// the rejected events are neither committed nor published
+	assert.Len(t, second.Changes(), 1)
+	assert.Equal(t, []string{"Deposited", "Withdrawn"}, publisher.names)
+
+	loaded, err := repo.Load(ctx, "account-1")
+	require.NoError(t, err)
+	assert.Equal(t, 20, loaded.balance)
+}
+
+func TestAggregateRepositoryPublishError(t *testing.T) {
+	ctx := context.Background()
+	publisher := &recordingPublisher{err: errors.New("connection closed")}
+	repo := NewAggregateRepository(NewMemoryEventStore(), newAccount, publisher)
+
+	a := newAccount("account-1")
+	require.NoError(t, Raise(a, "Deposited", amountChanged{Amount: 100}))
+
+	err := repo.Save(ctx, a)
+	assert.ErrorContains(t, err, "cannot publish the event 1 of the stream account-1: connection closed")
+
+	// the events are stored anyway
+	assert.Empty(t, a.Changes())
+	loaded, err := repo.Load(ctx, "account-1")
+	require.NoError(t, err)
+	assert.Equal(t, 100, loaded.balance)
+}
+
+func TestMemoryEventStoreReadStream(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryEventStore()
+
+	require.NoError(t, s.AppendToStream(ctx, "account-1", 0, []StoredEvent{{Type: "Deposited"}, {Type: "Withdrawn"}, {Type: "Deposited"}}))
+
+	events, err := s.ReadStream(ctx, "account-1", 2)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, 2, events[0].Version)
+
+	events, err = s.ReadStream(ctx, "account-1", 10)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+
+	err = s.AppendToStream(ctx, "account-1", 0, []StoredEvent{{Type: "Deposited"}})
+	assertCode(t, err, ErrConcurrencyConflict)
+}
@@ -0,0 +1,59 @@
+package eventstore
+
+import (
+	"context"
+	"sync"
+)
+
+// CheckpointStore stores the position of the last event handled by each projection of a
+// ProjectionRunner, so the projections resume where they stopped after a restart.
+type CheckpointStore interface {
+	// LoadCheckpoint loads the checkpoint of a projection.
+	// Parameters:
+	// - ctx: The context of the operation.
+	// - projection: The name of the projection.
+	// Returns:
+	// - int64: The position of the last event handled, 0 when the projection has no checkpoint.
+	// - error: An error if the checkpoint cannot be loaded.
+	LoadCheckpoint(ctx context.Context, projection string) (int64, error)
+
+	// SaveCheckpoint stores the checkpoint of a projection.
+	// Parameters:
+	// - ctx: The context of the operation.
+	// - projection: The name of the projection.
+	// - position: The position of the last event handled.
+	// Returns:
+	// - error: An error if the checkpoint cannot be stored.
+	SaveCheckpoint(ctx context.Context, projection string, position int64) error
+}
+
+// MemoryCheckpointStore is a CheckpointStore keeping the checkpoints in memory, e.g. for tests.
+// It is safe for concurrent use.
+type MemoryCheckpointStore struct {
+	mu          sync.RWMutex
+	checkpoints map[string]int64
+}
+
+var _ CheckpointStore = (*MemoryCheckpointStore)(nil)
+
+// NewMemoryCheckpointStore creates an empty MemoryCheckpointStore.
+// Returns:
+// - *MemoryCheckpointStore: The checkpoint store.
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{checkpoints: map[string]int64{}}
+}
+
+func (s *MemoryCheckpointStore) LoadCheckpoint(_ context.Context, projection string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.checkpoints[projection], nil
+}
+
+func (s *MemoryCheckpointStore) SaveCheckpoint(_ context.Context, projection string, position int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.checkpoints[projection] = position
+	return nil
+}
@@ -0,0 +1,148 @@
+// Package eventstore provides the building blocks of event sourced aggregates: an EventStore
+// appending the events of a stream with optimistic concurrency, implemented on Postgres and in
+// memory, and an AggregateRepository rehydrating the aggregates from their events.
+package eventstore
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/a-aslani/wotop/model/apperror"
+)
+
+const (
+	ErrConcurrencyConflict apperror.ErrorType = "ER0701 the stream %s is at version %d, expected version %d"
+	ErrStreamNotFound      apperror.ErrorType = "ER0702 the stream %s has no events"
+	ErrInvalidEvent        apperror.ErrorType = "ER0703 invalid event of the stream %s: %s"
+	ErrUnknownProjection   apperror.ErrorType = "ER0704 unknown projection %s"
+)
+
+func init() {
+	apperror.Register("eventstore",
+		apperror.Entry{Err: ErrConcurrencyConflict, Description: "The aggregate was changed by another request since it was loaded, load it again and retry."},
+		apperror.Entry{Err: ErrStreamNotFound, Description: "The aggregate does not exist."},
+		apperror.Entry{Err: ErrInvalidEvent, Description: "An event cannot be appended, e.g. it has no type or belongs to another stream."},
+		apperror.Entry{Err: ErrUnknownProjection, Description: "The projection runner has no projection of the name."},
+	)
+
+	apperror.MapCode(ErrConcurrencyConflict.Code(), http.StatusConflict)
+	apperror.MapCode(ErrStreamNotFound.Code(), http.StatusNotFound)
+	apperror.MapCode(ErrUnknownProjection.Code(), http.StatusNotFound)
+}
+
+// AnyVersion is the expected version appending to a stream whatever its version.
+const AnyVersion = -1
+
+// StoredEvent is an event of a stream. The version of the first event of a stream is 1, the
+// version of a stream being the one of its last event, 0 when it has none. The position of an
+// event orders the events of all the streams, see Feed.
+type StoredEvent struct {
+	StreamID   string          `json:"stream_id"`
+	Version    int             `json:"version"`
+	Position   int64           `json:"position"`
+	Type       string          `json:"type"`
+	Data       json.RawMessage `json:"data"`
+	Metadata   json.RawMessage `json:"metadata,omitempty"`
+	RecordedAt time.Time       `json:"recorded_at"`
+}
+
+// Decode unmarshals the data of the event.
+// Parameters:
+// - v: A pointer to the value the data is decoded into.
+// Returns:
+// - error: An error if the data cannot be decoded into v.
+func (e StoredEvent) Decode(v any) error {
+	return json.Unmarshal(e.Data, v)
+}
+
+// EventStore stores the events of the streams, each stream holding the events of an aggregate.
+type EventStore interface {
+	// AppendToStream appends the events to a stream, numbering them from the version following
+	// expectedVersion. The versions and the recording times of the events are set.
+	// Parameters:
+	// - ctx: The context of the operation.
+	// - streamID: The ID of the stream.
+	// - expectedVersion: The version the stream must be at, 0 for a new stream, AnyVersion to skip the check.
+	// - events: The events to append, all or none of them are appended.
+	// Returns:
+	// - error: ErrConcurrencyConflict if the stream is not at the expected version.
+	AppendToStream(ctx context.Context, streamID string, expectedVersion int, events []StoredEvent) error
+
+	// ReadStream reads the events of a stream in order.
+	// Parameters:
+	// - ctx: The context of the operation.
+	// - streamID: The ID of the stream.
+	// - fromVersion: The version of the first event to read, 1 to read the whole stream.
+	// Returns:
+	// - []StoredEvent: The events, none for an unknown stream.
+	// - error: An error if the events cannot be read.
+	ReadStream(ctx context.Context, streamID string, fromVersion int) ([]StoredEvent, error)
+}
+
+// Feed reads the events of all the streams in the order they were appended, e.g. to feed the
+// projections of a ProjectionRunner. It is implemented by the event stores of the package.
+type Feed interface {
+	// ReadAll reads the events of all the streams in order of position.
+	// Parameters:
+	// - ctx: The context of the operation.
+	// - afterPosition: The position of the last event read, 0 to read from the first event.
+	// - limit: The maximum number of events to read.
+	// Returns:
+	// - []StoredEvent: The events following afterPosition.
+	// - error: An error if the events cannot be read.
+	ReadAll(ctx context.Context, afterPosition int64, limit int) ([]StoredEvent, error)
+
+	// HeadPosition returns the position of the last event appended, 0 when there is none.
+	// Parameters:
+	// - ctx: The context of the operation.
+	// Returns:
+	// - int64: The position of the last event.
+	// - error: An error if the position cannot be read.
+	HeadPosition(ctx context.Context) (int64, error)
+}
+
+// prepareEvents checks the events to append and numbers them from the version following
+// current.
+// Parameters:
+// - streamID: The ID of the stream.
+// - current: The version of the stream.
+// - events: The events to append.
+// - now: The recording time of the events.
+// Returns:
+// - error: ErrInvalidEvent if an event has no type or belongs to another stream.
+func prepareEvents(streamID string, current int, events []StoredEvent, now time.Time) error {
+	for i := range events {
+		e := &events[i]
+
+		if e.Type == "" {
+			return ErrInvalidEvent.Var(streamID, "the event has no type")
+		}
+		if e.StreamID != "" && e.StreamID != streamID {
+			return ErrInvalidEvent.Var(streamID, "the event belongs to the stream "+e.StreamID)
+		}
+		if len(e.Data) == 0 {
+			e.Data = json.RawMessage("null")
+		}
+
+		e.StreamID = streamID
+		e.Version = current + i + 1
+		e.RecordedAt = now
+	}
+	return nil
+}
+
+// checkVersion checks that a stream is at the expected version.
+// Parameters:
+// - streamID: The ID of the stream.
+// - current: The version of the stream.
+// - expectedVersion: The expected version, AnyVersion to skip the check.
+// Returns:
+// - error: ErrConcurrencyConflict if the versions differ.
+func checkVersion(streamID string, current, expectedVersion int) error {
+	if expectedVersion != AnyVersion && current != expectedVersion {
+		return ErrConcurrencyConflict.Var(streamID, current, expectedVersion)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package eventstore
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/a-aslani/wotop/util"
+)
+
+// MemoryEventStore is an EventStore keeping the streams in memory, e.g. for tests. It is safe
+// for concurrent use.
+type MemoryEventStore struct {
+	mu      sync.RWMutex
+	streams map[string][]StoredEvent
+	all     []StoredEvent // the events of all the streams, the position of an event is its index + 1
+	clock   util.Clock
+}
+
+var (
+	_ EventStore = (*MemoryEventStore)(nil)
+	_ Feed       = (*MemoryEventStore)(nil)
+)
+
+// NewMemoryEventStore creates an empty MemoryEventStore.
+// Returns:
+// - *MemoryEventStore: The event store.
+func NewMemoryEventStore() *MemoryEventStore {
+	return &MemoryEventStore{streams: map[string][]StoredEvent{}, clock: util.SystemClock}
+}
+
+func (s *MemoryEventStore) AppendToStream(_ context.Context, streamID string, expectedVersion int, events []StoredEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := len(s.streams[streamID])
+	if err := checkVersion(streamID, current, expectedVersion); err != nil {
+		return err
+	}
+
+	if err := prepareEvents(streamID, current, events, s.clock.Now().UTC()); err != nil {
+		return err
+	}
+
+	for i := range events {
+		events[i].Position = int64(len(s.all) + 1)
+
+		e := cloneEvent(events[i])
+		s.streams[streamID] = append(s.streams[streamID], e)
+		s.all = append(s.all, e)
+	}
+	return nil
+}
+
+func (s *MemoryEventStore) ReadStream(_ context.Context, streamID string, fromVersion int) ([]StoredEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stream := s.streams[streamID]
+	from := min(max(fromVersion, 1), len(stream)+1) - 1
+
+	events := make([]StoredEvent, 0, len(stream)-from)
+	for _, e := range stream[from:] {
+		events = append(events, cloneEvent(e))
+	}
+	return events, nil
+}
+
+func (s *MemoryEventStore) ReadAll(_ context.Context, afterPosition int64, limit int) ([]StoredEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	from := int(min(max(afterPosition, 0), int64(len(s.all))))
+	to := min(from+max(limit, 0), len(s.all))
+
+	events := make([]StoredEvent, 0, to-from)
+	for _, e := range s.all[from:to] {
+		events = append(events, cloneEvent(e))
+	}
+	return events, nil
+}
+
+func (s *MemoryEventStore) HeadPosition(context.Context) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return int64(len(s.all)), nil
+}
+
+// cloneEvent copies an event, so the events kept by the store are not shared with the callers.
+func cloneEvent(e StoredEvent) StoredEvent {
+	e.Data = slices.Clone(e.Data)
+	e.Metadata = slices.Clone(e.Metadata)
+	return e
+}
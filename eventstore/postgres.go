@@ -0,0 +1,376 @@
+package eventstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/a-aslani/wotop/postgres_db"
+	"github.com/a-aslani/wotop/util"
+	"github.com/lib/pq"
+)
+
+// DefaultTable is the table of the events when NewPostgresEventStore is given none.
+const DefaultTable = "events"
+
+// uniqueViolation is the Postgres error code of a unique constraint violation.
+const uniqueViolation = "23505"
+
+// PostgresEventStore is an EventStore keeping the events in a Postgres table created by
+// Schema. The unique (stream_id, version) constraint of the table guarantees that two
+// concurrent appends at the same version cannot both succeed. The position of an event is its
+// id. The appends hold a transaction level advisory lock of the table, so they commit in order
+// of position and a Feed reader never skips an event committed after a later one.
+type PostgresEventStore struct {
+	db    *sql.DB
+	table string
+	clock util.Clock
+}
+
+var (
+	_ EventStore = (*PostgresEventStore)(nil)
+	_ Feed       = (*PostgresEventStore)(nil)
+)
+
+// NewPostgresEventStore creates a PostgresEventStore. The appends take part in the transaction
+// of the context, see postgres_db.WithTx, or run in their own.
+// Parameters:
+// - db: The connection pool.
+// - table: The table of the events, DefaultTable when empty.
+// Returns:
+// - *PostgresEventStore: The event store.
+func NewPostgresEventStore(db *sql.DB, table string) *PostgresEventStore {
+	if table == "" {
+		table = DefaultTable
+	}
+	return &PostgresEventStore{db: db, table: table, clock: util.SystemClock}
+}
+
+// Schema returns the statement creating the table of the events, e.g. for a migration.
+// Parameters:
+// - table: The table of the events, DefaultTable when empty.
+// Returns:
+// - string: The CREATE TABLE statement.
+func Schema(table string) string {
+	if table == "" {
+		table = DefaultTable
+	}
+	t := pq.QuoteIdentifier(table)
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id BIGSERIAL PRIMARY KEY,
+	stream_id TEXT NOT NULL,
+	version INTEGER NOT NULL,
+	type TEXT NOT NULL,
+	data JSONB NOT NULL,
+	metadata JSONB,
+	recorded_at TIMESTAMPTZ NOT NULL,
+	UNIQUE (stream_id, version)
+)`, t)
+}
+
+// CreateSchema creates the table of the events unless it exists.
+// Parameters:
+// - ctx: The context of the operation.
+// Returns:
+// - error: An error if the table cannot be created.
+func (s *PostgresEventStore) CreateSchema(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, Schema(s.table)); err != nil {
+		return fmt.Errorf("cannot create the table %s: %w", s.table, err)
+	}
+	return nil
+}
+
+func (s *PostgresEventStore) AppendToStream(ctx context.Context, streamID string, expectedVersion int, events []StoredEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	table := pq.QuoteIdentifier(s.table)
+
+	return postgres_db.WithTx(ctx, s.db, func(ctx context.Context) error {
+		conn := postgres_db.Conn(ctx, s.db)
+
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", s.table); err != nil {
+			return fmt.Errorf("cannot lock the table %s: %w", s.table, err)
+		}
+
+		var current int
+		err := conn.QueryRowContext(ctx,
+			fmt.Sprintf("SELECT COALESCE(MAX(version), 0) FROM %s WHERE stream_id = $1", table),
+			streamID,
+		).Scan(&current)
+		if err != nil {
+			return fmt.Errorf("cannot read the version of the stream %s: %w", streamID, err)
+		}
+
+		if err := checkVersion(streamID, current, expectedVersion); err != nil {
+			return err
+		}
+
+		if err := prepareEvents(streamID, current, events, s.clock.Now().UTC()); err != nil {
+			return err
+		}
+
+		insert := fmt.Sprintf("INSERT INTO %s (stream_id, version, type, data, metadata, recorded_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id", table)
+		for i := range events {
+			e := &events[i]
+
+			var metadata any
+			if len(e.Metadata) > 0 {
+				metadata = []byte(e.Metadata)
+			}
+
+			err := conn.QueryRowContext(ctx, insert, streamID, e.Version, e.Type, []byte(e.Data), metadata, e.RecordedAt).Scan(&e.Position)
+
+			// another append took the version between the check and the insert
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+				return ErrConcurrencyConflict.Var(streamID, e.Version, expectedVersion)
+			}
+			if err != nil {
+				return fmt.Errorf("cannot append to the stream %s: %w", streamID, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *PostgresEventStore) ReadStream(ctx context.Context, streamID string, fromVersion int) ([]StoredEvent, error) {
+	events, err := s.query(ctx,
+		fmt.Sprintf("SELECT id, stream_id, version, type, data, metadata, recorded_at FROM %s WHERE stream_id = $1 AND version >= $2 ORDER BY version", pq.QuoteIdentifier(s.table)),
+		streamID, fromVersion,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the stream %s: %w", streamID, err)
+	}
+	return events, nil
+}
+
+func (s *PostgresEventStore) ReadAll(ctx context.Context, afterPosition int64, limit int) ([]StoredEvent, error) {
+	events, err := s.query(ctx,
+		fmt.Sprintf("SELECT id, stream_id, version, type, data, metadata, recorded_at FROM %s WHERE id > $1 ORDER BY id LIMIT $2", pq.QuoteIdentifier(s.table)),
+		afterPosition, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the events after position %d: %w", afterPosition, err)
+	}
+	return events, nil
+}
+
+func (s *PostgresEventStore) HeadPosition(ctx context.Context) (int64, error) {
+	var position int64
+	err := postgres_db.Conn(ctx, s.db).QueryRowContext(ctx,
+		fmt.Sprintf("SELECT COALESCE(MAX(id), 0) FROM %s", pq.QuoteIdentifier(s.table)),
+	).Scan(&position)
+	if err != nil {
+		return 0, fmt.Errorf("cannot read the head position: %w", err)
+	}
+	return position, nil
+}
+
+// query reads the events selected by a query.
+// Parameters:
+// - ctx: The context of the query.
+// - query: The query selecting the id, stream_id, version, type, data, metadata and recorded_at columns.
+// - args: The arguments of the query.
+// Returns:
+// - []StoredEvent: The events.
+// - error: An error if the query fails.
+func (s *PostgresEventStore) query(ctx context.Context, query string, args ...any) ([]StoredEvent, error) {
+	rows, err := postgres_db.Conn(ctx, s.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []StoredEvent
+	for rows.Next() {
+		var (
+			e              StoredEvent
+			data, metadata []byte
+		)
+		if err := rows.Scan(&e.Position, &e.StreamID, &e.Version, &e.Type, &data, &metadata, &e.RecordedAt); err != nil {
+			return nil, err
+		}
+		e.Data = data
+		e.Metadata = metadata
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// DefaultSnapshotTable is the table of the snapshots when NewPostgresSnapshotStore is given
+// none.
+const DefaultSnapshotTable = "snapshots"
+
+// PostgresSnapshotStore is a SnapshotStore keeping the latest snapshot of each stream in a
+// Postgres table created by SnapshotSchema.
+type PostgresSnapshotStore struct {
+	db    *sql.DB
+	table string
+	clock util.Clock
+}
+
+var _ SnapshotStore = (*PostgresSnapshotStore)(nil)
+
+// NewPostgresSnapshotStore creates a PostgresSnapshotStore.
+// Parameters:
+// - db: The connection pool.
+// - table: The table of the snapshots, DefaultSnapshotTable when empty.
+// Returns:
+// - *PostgresSnapshotStore: The snapshot store.
+func NewPostgresSnapshotStore(db *sql.DB, table string) *PostgresSnapshotStore {
+	if table == "" {
+		table = DefaultSnapshotTable
+	}
+	return &PostgresSnapshotStore{db: db, table: table, clock: util.SystemClock}
+}
+
+// SnapshotSchema returns the statement creating the table of the snapshots, e.g. for a
+// migration.
+// Parameters:
+// - table: The table of the snapshots, DefaultSnapshotTable when empty.
+// Returns:
+// - string: The CREATE TABLE statement.
+func SnapshotSchema(table string) string {
+	if table == "" {
+		table = DefaultSnapshotTable
+	}
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	stream_id TEXT PRIMARY KEY,
+	version INTEGER NOT NULL,
+	state BYTEA NOT NULL,
+	taken_at TIMESTAMPTZ NOT NULL
+)`, pq.QuoteIdentifier(table))
+}
+
+// CreateSchema creates the table of the snapshots unless it exists.
+// Parameters:
+// - ctx: The context of the operation.
+// Returns:
+// - error: An error if the table cannot be created.
+func (s *PostgresSnapshotStore) CreateSchema(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, SnapshotSchema(s.table)); err != nil {
+		return fmt.Errorf("cannot create the table %s: %w", s.table, err)
+	}
+	return nil
+}
+
+func (s *PostgresSnapshotStore) SaveSnapshot(ctx context.Context, streamID string, version int, state []byte) error {
+	table := pq.QuoteIdentifier(s.table)
+
+	// a snapshot taken by a slower concurrent save must not replace a newer one
+	_, err := postgres_db.Conn(ctx, s.db).ExecContext(ctx,
+		fmt.Sprintf(`INSERT INTO %[1]s (stream_id, version, state, taken_at) VALUES ($1, $2, $3, $4)
+ON CONFLICT (stream_id) DO UPDATE SET version = EXCLUDED.version, state = EXCLUDED.state, taken_at = EXCLUDED.taken_at
+WHERE %[1]s.version < EXCLUDED.version`, table),
+		streamID, version, state, s.clock.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("cannot save the snapshot of the stream %s: %w", streamID, err)
+	}
+	return nil
+}
+
+func (s *PostgresSnapshotStore) LoadSnapshot(ctx context.Context, streamID string) (int, []byte, error) {
+	var (
+		version int
+		state   []byte
+	)
+	err := postgres_db.Conn(ctx, s.db).QueryRowContext(ctx,
+		fmt.Sprintf("SELECT version, state FROM %s WHERE stream_id = $1", pq.QuoteIdentifier(s.table)),
+		streamID,
+	).Scan(&version, &state)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil, nil
+	}
+	if err != nil {
+		return 0, nil, fmt.Errorf("cannot load the snapshot of the stream %s: %w", streamID, err)
+	}
+	return version, state, nil
+}
+
+// DefaultCheckpointTable is the table of the checkpoints when NewPostgresCheckpointStore is
+// given none.
+const DefaultCheckpointTable = "projection_checkpoints"
+
+// PostgresCheckpointStore is a CheckpointStore keeping the checkpoints in a Postgres table
+// created by CheckpointSchema. Saving a checkpoint in the transaction of the context, see
+// postgres_db.WithTx, commits it together with the read model updated by the projection.
+type PostgresCheckpointStore struct {
+	db    *sql.DB
+	table string
+	clock util.Clock
+}
+
+var _ CheckpointStore = (*PostgresCheckpointStore)(nil)
+
+// NewPostgresCheckpointStore creates a PostgresCheckpointStore.
+// Parameters:
+// - db: The connection pool.
+// - table: The table of the checkpoints, DefaultCheckpointTable when empty.
+// Returns:
+// - *PostgresCheckpointStore: The checkpoint store.
+func NewPostgresCheckpointStore(db *sql.DB, table string) *PostgresCheckpointStore {
+	if table == "" {
+		table = DefaultCheckpointTable
+	}
+	return &PostgresCheckpointStore{db: db, table: table, clock: util.SystemClock}
+}
+
+// CheckpointSchema returns the statement creating the table of the checkpoints, e.g. for a
+// migration.
+// Parameters:
+// - table: The table of the checkpoints, DefaultCheckpointTable when empty.
+// Returns:
+// - string: The CREATE TABLE statement.
+func CheckpointSchema(table string) string {
+	if table == "" {
+		table = DefaultCheckpointTable
+	}
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	projection TEXT PRIMARY KEY,
+	position BIGINT NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+)`, pq.QuoteIdentifier(table))
+}
+
+// CreateSchema creates the table of the checkpoints unless it exists.
+// Parameters:
+// - ctx: The context of the operation.
+// Returns:
+// - error: An error if the table cannot be created.
+func (s *PostgresCheckpointStore) CreateSchema(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, CheckpointSchema(s.table)); err != nil {
+		return fmt.Errorf("cannot create the table %s: %w", s.table, err)
+	}
+	return nil
+}
+
+func (s *PostgresCheckpointStore) LoadCheckpoint(ctx context.Context, projection string) (int64, error) {
+	var position int64
+	err := postgres_db.Conn(ctx, s.db).QueryRowContext(ctx,
+		fmt.Sprintf("SELECT position FROM %s WHERE projection = $1", pq.QuoteIdentifier(s.table)),
+		projection,
+	).Scan(&position)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("cannot load the checkpoint of the projection %s: %w", projection, err)
+	}
+	return position, nil
+}
+
+func (s *PostgresCheckpointStore) SaveCheckpoint(ctx context.Context, projection string, position int64) error {
+	_, err := postgres_db.Conn(ctx, s.db).ExecContext(ctx,
+		fmt.Sprintf(`INSERT INTO %s (projection, position, updated_at) VALUES ($1, $2, $3)
+ON CONFLICT (projection) DO UPDATE SET position = EXCLUDED.position, updated_at = EXCLUDED.updated_at`, pq.QuoteIdentifier(s.table)),
+		projection, position, s.clock.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("cannot save the checkpoint of the projection %s: %w", projection, err)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package eventstore
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/a-aslani/wotop/model/apperror"
+	"github.com/a-aslani/wotop/util"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// assertCode checks the apperror code of the error.
+func assertCode(t *testing.T, err error, want apperror.ErrorType) {
+	t.Helper()
+	var et apperror.ErrorType
+	require.ErrorAs(t, err, &et)
+	assert.Equal(t, want.Code(), et.Code(), err.Error())
+}
+
+var (
+	lockTable     = regexp.QuoteMeta(`SELECT pg_advisory_xact_lock(hashtext($1))`)
+	selectVersion = regexp.QuoteMeta(`SELECT COALESCE(MAX(version), 0) FROM "events" WHERE stream_id = $1`)
+	insertEvent   = regexp.QuoteMeta(`INSERT INTO "events" (stream_id, version, type, data, metadata, recorded_at)`)
+	selectEvents  = regexp.QuoteMeta(`SELECT id, stream_id, version, type, data, metadata, recorded_at FROM "events" WHERE stream_id = $1 AND version >= $2 ORDER BY version`)
+	selectAll     = regexp.QuoteMeta(`SELECT id, stream_id, version, type, data, metadata, recorded_at FROM "events" WHERE id > $1 ORDER BY id LIMIT $2`)
+	eventColumns  = []string{"id", "stream_id", "version", "type", "data", "metadata", "recorded_at"}
+)
+
+func newPostgresStore(t *testing.T) (*PostgresEventStore, sqlmock.Sqlmock, time.Time) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	s := NewPostgresEventStore(db, "")
+	s.clock = util.NewFrozenClock(now)
+	return s, mock, now
+}
+
+func TestPostgresAppendToStream(t *testing.T) {
+	s, mock, now := newPostgresStore(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(lockTable).WithArgs("events").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(selectVersion).WithArgs("account-1").WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(2))
+	mock.ExpectQuery(insertEvent).
+		WithArgs("account-1", 3, "Deposited", []byte(`{"amount":10}`), nil, now).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(41))
+	mock.ExpectQuery(insertEvent).
+		WithArgs("account-1", 4, "Withdrawn", []byte(`{"amount":5}`), []byte(`{"user":"u-1"}`), now).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
+	mock.ExpectCommit()
+
+	events := []StoredEvent{
+		{Type: "Deposited", Data: json.RawMessage(`{"amount":10}`)},
+		{Type: "Withdrawn", Data: json.RawMessage(`{"amount":5}`), Metadata: json.RawMessage(`{"user":"u-1"}`)},
+	}
+	require.NoError(t, s.AppendToStream(context.Background(), "account-1", 2, events))
+
+	assert.Equal(t, 3, events[0].Version)
+	assert.Equal(t, 4, events[1].Version)
+	assert.Equal(t, int64(42), events[1].Position)
+	assert.Equal(t, "account-1", events[1].StreamID)
+	assert.Equal(t, now, events[1].RecordedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresAppendToStreamConflict(t *testing.T) {
+
+	t.Run("stale expected version", func(t *testing.T) {
+		s, mock, _ := newPostgresStore(t)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(lockTable).WithArgs("events").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(selectVersion).WithArgs("account-1").WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(3))
+		mock.ExpectRollback()
+
+		err := s.AppendToStream(context.Background(), "account-1", 2, []StoredEvent{{Type: "Deposited"}})
+		assertCode(t, err, ErrConcurrencyConflict)
+		assert.EqualError(t, err, "the stream account-1 is at version 3, expected version 2")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("concurrent insert", func(t *testing.T) {
+		s, mock, _ := newPostgresStore(t)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(lockTable).WithArgs("events").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(selectVersion).WithArgs("account-1").WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(2))
+		mock.ExpectQuery(insertEvent).WillReturnError(&pq.Error{Code: uniqueViolation})
+		mock.ExpectRollback()
+
+		err := s.AppendToStream(context.Background(), "account-1", 2, []StoredEvent{{Type: "Deposited"}})
+		assertCode(t, err, ErrConcurrencyConflict)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("any version", func(t *testing.T) {
+		s, mock, _ := newPostgresStore(t)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(lockTable).WithArgs("events").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(selectVersion).WithArgs("account-1").WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(7))
+		mock.ExpectQuery(insertEvent).WithArgs("account-1", 8, "Deposited", []byte("null"), nil, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+
+		require.NoError(t, s.AppendToStream(context.Background(), "account-1", AnyVersion, []StoredEvent{{Type: "Deposited"}}))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgresAppendToStreamInvalidEvent(t *testing.T) {
+	s, mock, _ := newPostgresStore(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(lockTable).WithArgs("events").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(selectVersion).WithArgs("account-1").WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(0))
+	mock.ExpectRollback()
+
+	err := s.AppendToStream(context.Background(), "account-1", 0, []StoredEvent{{StreamID: "account-2", Type: "Deposited"}})
+	assertCode(t, err, ErrInvalidEvent)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresReadStream(t *testing.T) {
+	s, mock, now := newPostgresStore(t)
+
+	mock.ExpectQuery(selectEvents).WithArgs("account-1", 2).WillReturnRows(
+		sqlmock.NewRows(eventColumns).
+			AddRow(12, "account-1", 2, "Deposited", []byte(`{"amount":10}`), nil, now).
+			AddRow(15, "account-1", 3, "Withdrawn", []byte(`{"amount":5}`), []byte(`{"user":"u-1"}`), now),
+	)
+
+	events, err := s.ReadStream(context.Background(), "account-1", 2)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, StoredEvent{StreamID: "account-1", Version: 2, Position: 12, Type: "Deposited", Data: json.RawMessage(`{"amount":10}`), RecordedAt: now}, events[0])
+	assert.JSONEq(t, `{"user":"u-1"}`, string(events[1].Metadata))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresReadAll(t *testing.T) {
+	s, mock, now := newPostgresStore(t)
+
+	mock.ExpectQuery(selectAll).WithArgs(int64(12), 2).WillReturnRows(
+		sqlmock.NewRows(eventColumns).
+			AddRow(13, "account-2", 1, "Opened", []byte(`{}`), nil, now).
+			AddRow(15, "account-1", 3, "Withdrawn", []byte(`{"amount":5}`), nil, now),
+	)
+	events, err := s.ReadAll(context.Background(), 12, 2)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "account-2", events[0].StreamID)
+	assert.Equal(t, int64(15), events[1].Position)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE(MAX(id), 0) FROM "events"`)).WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(15))
+	head, err := s.HeadPosition(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(15), head)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSchema(t *testing.T) {
+	schema := Schema("account_events")
+	assert.Contains(t, schema, `CREATE TABLE IF NOT EXISTS "account_events"`)
+	assert.Contains(t, schema, "UNIQUE (stream_id, version)")
+	assert.Contains(t, Schema(""), `"events"`)
+}
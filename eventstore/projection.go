@@ -0,0 +1,414 @@
+package eventstore
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Projection builds a read model from the events of all the streams, handed in order of
+// position by a ProjectionRunner. An event may be handed again after a restart, so Handle
+// should be idempotent.
+type Projection interface {
+	// Name returns the name of the projection, the key of its checkpoint.
+	Name() string
+
+	// Handle updates the read model with an event.
+	Handle(ctx context.Context, event StoredEvent) error
+}
+
+// Resetter is implemented by the projections clearing their read model before a rebuild, see
+// ProjectionRunner.Rebuild.
+type Resetter interface {
+	Reset(ctx context.Context) error
+}
+
+const (
+	// DefaultProjectionBatchSize is the number of events read at once by a projection.
+	DefaultProjectionBatchSize = 100
+
+	// DefaultProjectionPollInterval is the interval between two reads of a projection which
+	// caught up with the feed.
+	DefaultProjectionPollInterval = time.Second
+
+	// DefaultProjectionRetries is the number of retries of a failing event before the
+	// projection is parked.
+	DefaultProjectionRetries = 5
+
+	// DefaultProjectionBackoff is the delay before the first retry of a failing event, doubled
+	// at each retry.
+	DefaultProjectionBackoff = 100 * time.Millisecond
+
+	// maxProjectionBackoff caps the delay between two retries.
+	maxProjectionBackoff = time.Minute
+)
+
+// ProjectionRunner feeds projections with the events of a Feed. Each projection runs on its
+// own, from its checkpoint, so a slow or failing projection does not hold back the others. An
+// event failing after the retries parks its projection until Resume or Rebuild is called.
+//
+// The runner reports the Prometheus gauges eventstore_projection_position,
+// eventstore_projection_lag, the number of events the projection is behind the feed, and
+// eventstore_projection_parked, labeled by projection.
+type ProjectionRunner struct {
+	feed        Feed
+	checkpoints CheckpointStore
+	workers     map[string]*projectionWorker
+	order       []string // the names of the projections, in the order they were given
+
+	batchSize    int
+	pollInterval time.Duration
+	retries      int
+	backoff      time.Duration
+
+	onPark  func(ctx context.Context, projection string, event StoredEvent, err error)
+	onError func(ctx context.Context, projection string, err error)
+
+	position *prometheus.GaugeVec
+	lag      *prometheus.GaugeVec
+	parked   *prometheus.GaugeVec
+}
+
+// projectionWorker is the state of a projection of a ProjectionRunner.
+type projectionWorker struct {
+	projection Projection
+
+	mu       sync.Mutex // held while the projection handles events
+	position int64
+	loaded   bool // the position was loaded from the checkpoint store
+	parked   bool
+
+	wake chan struct{}
+}
+
+// NewProjectionRunner creates the runner of projections, its gauges are registered with reg
+// unless it is nil.
+// Parameters:
+// - feed: The events of all the streams, e.g. a PostgresEventStore.
+// - checkpoints: The store of the positions of the projections.
+// - reg: The registerer of the gauges, nil to register none.
+// - projections: The projections, their names must be unique.
+// Returns:
+// - *ProjectionRunner: The runner.
+// - error: An error if two projections share a name or a gauge cannot be registered.
+func NewProjectionRunner(feed Feed, checkpoints CheckpointStore, reg prometheus.Registerer, projections ...Projection) (*ProjectionRunner, error) {
+	r := &ProjectionRunner{
+		feed:         feed,
+		checkpoints:  checkpoints,
+		workers:      map[string]*projectionWorker{},
+		batchSize:    DefaultProjectionBatchSize,
+		pollInterval: DefaultProjectionPollInterval,
+		retries:      DefaultProjectionRetries,
+		backoff:      DefaultProjectionBackoff,
+		onPark:       func(context.Context, string, StoredEvent, error) {},
+		onError:      func(context.Context, string, error) {},
+		position: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "eventstore_projection_position",
+			Help: "Position of the last event handled by the projection.",
+		}, []string{"projection"}),
+		lag: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "eventstore_projection_lag",
+			Help: "Events appended to the feed and not handled by the projection yet.",
+		}, []string{"projection"}),
+		parked: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "eventstore_projection_parked",
+			Help: "1 when the projection is parked after an event failed, 0 otherwise.",
+		}, []string{"projection"}),
+	}
+
+	for _, p := range projections {
+		name := p.Name()
+		if _, ok := r.workers[name]; ok {
+			return nil, fmt.Errorf("duplicate projection %s", name)
+		}
+		r.workers[name] = &projectionWorker{projection: p, wake: make(chan struct{}, 1)}
+		r.order = append(r.order, name)
+		r.parked.WithLabelValues(name).Set(0)
+	}
+
+	if reg != nil {
+		for _, c := range []prometheus.Collector{r.position, r.lag, r.parked} {
+			if err := reg.Register(c); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return r, nil
+}
+
+// WithBatchSize sets the number of events read at once by a projection.
+// Parameters:
+// - n: The number of events, at least 1.
+// Returns:
+// - *ProjectionRunner: The runner, for chaining.
+func (r *ProjectionRunner) WithBatchSize(n int) *ProjectionRunner {
+	r.batchSize = max(n, 1)
+	return r
+}
+
+// WithPollInterval sets the interval between two reads of a projection which caught up with
+// the feed.
+// Parameters:
+// - d: The interval.
+// Returns:
+// - *ProjectionRunner: The runner, for chaining.
+func (r *ProjectionRunner) WithPollInterval(d time.Duration) *ProjectionRunner {
+	r.pollInterval = d
+	return r
+}
+
+// WithRetries sets the retries of a failing event before its projection is parked.
+// Parameters:
+// - retries: The number of retries, 0 to park the projection at the first failure.
+// - backoff: The delay before the first retry, doubled at each retry up to a minute.
+// Returns:
+// - *ProjectionRunner: The runner, for chaining.
+func (r *ProjectionRunner) WithRetries(retries int, backoff time.Duration) *ProjectionRunner {
+	r.retries = max(retries, 0)
+	r.backoff = backoff
+	return r
+}
+
+// WithParkHandler sets the function called when a projection is parked, e.g. to alert the
+// operators.
+// Parameters:
+// - fn: Called with the name of the projection, the failing event and its last error.
+// Returns:
+// - *ProjectionRunner: The runner, for chaining.
+func (r *ProjectionRunner) WithParkHandler(fn func(ctx context.Context, projection string, event StoredEvent, err error)) *ProjectionRunner {
+	r.onPark = fn
+	return r
+}
+
+// WithErrorHandler sets the function called when the feed or the checkpoints of a projection
+// cannot be read or written, the projection tries again at the next poll.
+// Parameters:
+// - fn: Called with the name of the projection and the error.
+// Returns:
+// - *ProjectionRunner: The runner, for chaining.
+func (r *ProjectionRunner) WithErrorHandler(fn func(ctx context.Context, projection string, err error)) *ProjectionRunner {
+	r.onError = fn
+	return r
+}
+
+// Run feeds the projections until the context is done.
+// Parameters:
+// - ctx: The context of the runner.
+func (r *ProjectionRunner) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, name := range r.order {
+		w := r.workers[name]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.run(ctx, w)
+		}()
+	}
+	wg.Wait()
+}
+
+// run feeds a projection until the context is done.
+func (r *ProjectionRunner) run(ctx context.Context, w *projectionWorker) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		caughtUp, err := r.Step(ctx, w.projection.Name())
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			r.onError(ctx, w.projection.Name(), err)
+		}
+
+		if !caughtUp && err == nil {
+			continue
+		}
+
+		timer.Reset(r.pollInterval)
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// Step hands a batch of events to a projection, from its checkpoint, and saves its checkpoint.
+// Run calls it in a loop, it is exposed to drive a projection by hand, e.g. in tests.
+// Parameters:
+// - ctx: The context of the operation.
+// - projection: The name of the projection.
+// Returns:
+// - bool: True if the projection caught up with the feed or is parked.
+// - error: ErrUnknownProjection, or an error if the feed or the checkpoint cannot be read or written.
+func (r *ProjectionRunner) Step(ctx context.Context, projection string) (bool, error) {
+	w, ok := r.workers[projection]
+	if !ok {
+		return false, ErrUnknownProjection.Var(projection)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.parked {
+		r.report(ctx, w)
+		return true, nil
+	}
+
+	if !w.loaded {
+		position, err := r.checkpoints.LoadCheckpoint(ctx, projection)
+		if err != nil {
+			return false, err
+		}
+		w.position, w.loaded = position, true
+	}
+
+	events, err := r.feed.ReadAll(ctx, w.position, r.batchSize)
+	if err != nil {
+		return false, err
+	}
+
+	handled := w.position
+	for _, e := range events {
+		if err := r.handle(ctx, w.projection, e); err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			w.parked = true
+			r.parked.WithLabelValues(projection).Set(1)
+			r.onPark(ctx, projection, e, err)
+			break
+		}
+		handled = e.Position
+	}
+
+	if handled != w.position {
+		if err := r.checkpoints.SaveCheckpoint(ctx, projection, handled); err != nil {
+			// the events are handled again from the saved checkpoint
+			w.loaded = false
+			return false, err
+		}
+		w.position = handled
+	}
+
+	r.report(ctx, w)
+	return w.parked || len(events) < r.batchSize, nil
+}
+
+// handle hands an event to a projection, retrying with an exponential backoff.
+// Parameters:
+// - ctx: The context of the operation.
+// - p: The projection.
+// - e: The event.
+// Returns:
+// - error: The last error of the projection, or the error of the context.
+func (r *ProjectionRunner) handle(ctx context.Context, p Projection, e StoredEvent) error {
+	backoff := r.backoff
+
+	for attempt := 0; ; attempt++ {
+		err := p.Handle(ctx, e)
+		if err == nil || attempt >= r.retries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxProjectionBackoff)
+	}
+}
+
+// report sets the gauges of a projection.
+func (r *ProjectionRunner) report(ctx context.Context, w *projectionWorker) {
+	name := w.projection.Name()
+	r.position.WithLabelValues(name).Set(float64(w.position))
+
+	if head, err := r.feed.HeadPosition(ctx); err == nil {
+		r.lag.WithLabelValues(name).Set(float64(max(head-w.position, 0)))
+	}
+}
+
+// Resume unparks a projection, which hands the failing event again.
+// Parameters:
+// - projection: The name of the projection.
+// Returns:
+// - error: ErrUnknownProjection if the runner has no projection of the name.
+func (r *ProjectionRunner) Resume(projection string) error {
+	w, ok := r.workers[projection]
+	if !ok {
+		return ErrUnknownProjection.Var(projection)
+	}
+
+	w.mu.Lock()
+	w.parked = false
+	w.mu.Unlock()
+
+	r.parked.WithLabelValues(projection).Set(0)
+	w.signal()
+	return nil
+}
+
+// Rebuild replays all the events into a projection: its read model is reset when it
+// implements Resetter, its checkpoint is set to 0 and it is unparked.
+// Parameters:
+// - ctx: The context of the operation.
+// - projection: The name of the projection.
+// Returns:
+// - error: ErrUnknownProjection, or an error if the read model or the checkpoint cannot be reset.
+func (r *ProjectionRunner) Rebuild(ctx context.Context, projection string) error {
+	w, ok := r.workers[projection]
+	if !ok {
+		return ErrUnknownProjection.Var(projection)
+	}
+
+	w.mu.Lock()
+	defer w.signal()
+	defer w.mu.Unlock()
+
+	if resetter, ok := w.projection.(Resetter); ok {
+		if err := resetter.Reset(ctx); err != nil {
+			return fmt.Errorf("cannot reset the projection %s: %w", projection, err)
+		}
+	}
+
+	if err := r.checkpoints.SaveCheckpoint(ctx, projection, 0); err != nil {
+		return err
+	}
+
+	w.position, w.loaded, w.parked = 0, true, false
+	r.parked.WithLabelValues(projection).Set(0)
+	r.report(ctx, w)
+	return nil
+}
+
+// Parked reports whether a projection is parked.
+// Parameters:
+// - projection: The name of the projection.
+// Returns:
+// - bool: True if the projection is parked, false for an unknown projection.
+func (r *ProjectionRunner) Parked(projection string) bool {
+	w, ok := r.workers[projection]
+	if !ok {
+		return false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.parked
+}
+
+// signal wakes the worker up if it waits for the next poll.
+func (w *projectionWorker) signal() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,249 @@
+package eventstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// balanceProjection sums the amounts of the deposits, failing on the events of failOn.
+type balanceProjection struct {
+	name string
+
+	mu       sync.Mutex
+	total    int
+	handled  []int64
+	failOn   int64
+	attempts int
+	resets   int
+}
+
+func (p *balanceProjection) Name() string { return p.name }
+
+func (p *balanceProjection) Handle(_ context.Context, event StoredEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if event.Position == p.failOn {
+		p.attempts++
+		return errors.New("read model unavailable")
+	}
+
+	var data amountChanged
+	if err := event.Decode(&data); err != nil {
+		return err
+	}
+	p.total += data.Amount
+	p.handled = append(p.handled, event.Position)
+	return nil
+}
+
+func (p *balanceProjection) Reset(context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.total, p.handled = 0, nil
+	p.resets++
+	return nil
+}
+
+func (p *balanceProjection) snapshot() (int, []int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.total, append([]int64(nil), p.handled...)
+}
+
+// appendDeposits appends a deposit of 10 to each stream.
+func appendDeposits(t *testing.T, store EventStore, streams ...string) {
+	t.Helper()
+	for _, id := range streams {
+		require.NoError(t, store.AppendToStream(context.Background(), id, AnyVersion, []StoredEvent{
+			{Type: "Deposited", Data: []byte(`{"amount":10}`)},
+		}))
+	}
+}
+
+// drain steps a projection until it caught up.
+func drain(t *testing.T, r *ProjectionRunner, projection string) {
+	t.Helper()
+	for {
+		caughtUp, err := r.Step(context.Background(), projection)
+		require.NoError(t, err)
+		if caughtUp {
+			return
+		}
+	}
+}
+
+func TestProjectionRunnerResumesFromCheckpoint(t *testing.T) {
+	store := NewMemoryEventStore()
+	checkpoints := NewMemoryCheckpointStore()
+	appendDeposits(t, store, "account-1", "account-2", "account-1")
+
+	p := &balanceProjection{name: "balances"}
+	r, err := NewProjectionRunner(store, checkpoints, nil, p)
+	require.NoError(t, err)
+	r.WithBatchSize(2)
+	drain(t, r, "balances")
+
+	total, handled := p.snapshot()
+	assert.Equal(t, 30, total)
+	assert.Equal(t, []int64{1, 2, 3}, handled)
+
+	position, err := checkpoints.LoadCheckpoint(context.Background(), "balances")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), position)
+
+	// after a restart, only the new events are handled
+	appendDeposits(t, store, "account-3")
+	restarted := &balanceProjection{name: "balances"}
+	r, err = NewProjectionRunner(store, checkpoints, nil, restarted)
+	require.NoError(t, err)
+	drain(t, r, "balances")
+
+	_, handled = restarted.snapshot()
+	assert.Equal(t, []int64{4}, handled)
+}
+
+func TestProjectionRunnerRebuild(t *testing.T) {
+	store := NewMemoryEventStore()
+	appendDeposits(t, store, "account-1", "account-2")
+
+	p := &balanceProjection{name: "balances"}
+	r, err := NewProjectionRunner(store, NewMemoryCheckpointStore(), nil, p)
+	require.NoError(t, err)
+	drain(t, r, "balances")
+
+	require.NoError(t, r.Rebuild(context.Background(), "balances"))
+	assert.Equal(t, 1, p.resets)
+	total, _ := p.snapshot()
+	assert.Zero(t, total)
+
+	drain(t, r, "balances")
+	total, handled := p.snapshot()
+	assert.Equal(t, 20, total)
+	assert.Equal(t, []int64{1, 2}, handled)
+
+	assertCode(t, r.Rebuild(context.Background(), "orders"), ErrUnknownProjection)
+}
+
+func TestProjectionRunnerParksFailingProjection(t *testing.T) {
+	store := NewMemoryEventStore()
+	appendDeposits(t, store, "account-1", "account-2", "account-3")
+
+	failing := &balanceProjection{name: "failing", failOn: 2}
+	healthy := &balanceProjection{name: "healthy"}
+
+	type parking struct {
+		projection string
+		position   int64
+		err        error
+	}
+	parked := make(chan parking, 1)
+
+	reg := prometheus.NewRegistry()
+	r, err := NewProjectionRunner(store, NewMemoryCheckpointStore(), reg, failing, healthy)
+	require.NoError(t, err)
+	r.WithRetries(2, time.Millisecond).
+		WithPollInterval(5 * time.Millisecond).
+		WithParkHandler(func(_ context.Context, projection string, event StoredEvent, err error) {
+			parked <- parking{projection, event.Position, err}
+		})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	select {
+	case p := <-parked:
+		assert.Equal(t, "failing", p.projection)
+		assert.Equal(t, int64(2), p.position)
+		assert.EqualError(t, p.err, "read model unavailable")
+	case <-time.After(5 * time.Second):
+		t.Fatal("the failing projection was not parked")
+	}
+	assert.True(t, r.Parked("failing"))
+
+	// the healthy projection keeps up with the feed
+	appendDeposits(t, store, "account-4")
+	require.Eventually(t, func() bool {
+		total, _ := healthy.snapshot()
+		return total == 40
+	}, 5*time.Second, 5*time.Millisecond)
+
+	failing.mu.Lock()
+	assert.Equal(t, 3, failing.attempts)
+	failing.mu.Unlock()
+	_, handled := failing.snapshot()
+	assert.Equal(t, []int64{1}, handled)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(r.parked.WithLabelValues("failing")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(r.parked.WithLabelValues("healthy")))
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(r.lag.WithLabelValues("failing")) == 3 &&
+			testutil.ToFloat64(r.lag.WithLabelValues("healthy")) == 0
+	}, 5*time.Second, 5*time.Millisecond)
+
+	// once the read model is fixed, the projection resumes from the failing event
+	failing.mu.Lock()
+	failing.failOn = 0
+	failing.mu.Unlock()
+	require.NoError(t, r.Resume("failing"))
+
+	require.Eventually(t, func() bool {
+		total, _ := failing.snapshot()
+		return total == 40
+	}, 5*time.Second, 5*time.Millisecond)
+	assert.False(t, r.Parked("failing"))
+}
+
+func TestNewProjectionRunnerDuplicateName(t *testing.T) {
+	_, err := NewProjectionRunner(NewMemoryEventStore(), NewMemoryCheckpointStore(), nil,
+		&balanceProjection{name: "balances"}, &balanceProjection{name: "balances"})
+	assert.EqualError(t, err, "duplicate projection balances")
+}
+
+func TestPostgresCheckpointStore(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	s := NewPostgresCheckpointStore(db, "")
+	load := regexp.QuoteMeta(`SELECT position FROM "projection_checkpoints" WHERE projection = $1`)
+
+	mock.ExpectQuery(load).WithArgs("balances").WillReturnError(sql.ErrNoRows)
+	position, err := s.LoadCheckpoint(ctx, "balances")
+	require.NoError(t, err)
+	assert.Zero(t, position)
+
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "projection_checkpoints" (projection, position, updated_at) VALUES ($1, $2, $3)`)).
+		WithArgs("balances", int64(42), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, s.SaveCheckpoint(ctx, "balances", 42))
+
+	mock.ExpectQuery(load).WithArgs("balances").WillReturnRows(sqlmock.NewRows([]string{"position"}).AddRow(42))
+	position, err = s.LoadCheckpoint(ctx, "balances")
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), position)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Contains(t, CheckpointSchema(""), `CREATE TABLE IF NOT EXISTS "projection_checkpoints"`)
+}
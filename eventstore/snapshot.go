@@ -0,0 +1,88 @@
+package eventstore
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// SnapshotStore stores the latest snapshot of the streams, so long lived aggregates are
+// rehydrated from their snapshot and the events following it rather than from all their events.
+type SnapshotStore interface {
+	// SaveSnapshot stores the state of an aggregate at a version, unless a newer snapshot of the
+	// stream is stored.
+	// Parameters:
+	// - ctx: The context of the operation.
+	// - streamID: The ID of the stream of the aggregate.
+	// - version: The version of the aggregate.
+	// - state: The state of the aggregate, see Snapshotter.
+	// Returns:
+	// - error: An error if the snapshot cannot be stored.
+	SaveSnapshot(ctx context.Context, streamID string, version int, state []byte) error
+
+	// LoadSnapshot loads the latest snapshot of a stream.
+	// Parameters:
+	// - ctx: The context of the operation.
+	// - streamID: The ID of the stream of the aggregate.
+	// Returns:
+	// - int: The version of the snapshot, 0 when the stream has none.
+	// - []byte: The state of the aggregate, nil when the stream has no snapshot.
+	// - error: An error if the snapshot cannot be loaded.
+	LoadSnapshot(ctx context.Context, streamID string) (version int, state []byte, err error)
+}
+
+// Snapshotter is implemented by the aggregates opting in to the snapshots of
+// AggregateRepository.WithSnapshots.
+type Snapshotter interface {
+	// MarshalSnapshot encodes the state of the aggregate.
+	MarshalSnapshot() ([]byte, error)
+
+	// UnmarshalSnapshot restores the state of the aggregate encoded by MarshalSnapshot. An error,
+	// e.g. for a snapshot of a former shape of the aggregate, makes the repository replay all
+	// the events.
+	UnmarshalSnapshot(state []byte) error
+}
+
+// snapshot is a snapshot kept by MemorySnapshotStore.
+type snapshot struct {
+	version int
+	state   []byte
+}
+
+// MemorySnapshotStore is a SnapshotStore keeping the snapshots in memory, e.g. for tests. It is
+// safe for concurrent use.
+type MemorySnapshotStore struct {
+	mu        sync.RWMutex
+	snapshots map[string]snapshot
+}
+
+var _ SnapshotStore = (*MemorySnapshotStore)(nil)
+
+// NewMemorySnapshotStore creates an empty MemorySnapshotStore.
+// Returns:
+// - *MemorySnapshotStore: The snapshot store.
+func NewMemorySnapshotStore() *MemorySnapshotStore {
+	return &MemorySnapshotStore{snapshots: map[string]snapshot{}}
+}
+
+func (s *MemorySnapshotStore) SaveSnapshot(_ context.Context, streamID string, version int, state []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if current, ok := s.snapshots[streamID]; ok && current.version >= version {
+		return nil
+	}
+	s.snapshots[streamID] = snapshot{version: version, state: slices.Clone(state)}
+	return nil
+}
+
+func (s *MemorySnapshotStore) LoadSnapshot(_ context.Context, streamID string) (int, []byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snap, ok := s.snapshots[streamID]
+	if !ok {
+		return 0, nil, nil
+	}
+	return snap.version, slices.Clone(snap.state), nil
+}
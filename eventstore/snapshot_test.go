@@ -0,0 +1,204 @@
+package eventstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// snapshottedAccount is an account opting in to the snapshots, counting the events it applies.
+type snapshottedAccount struct {
+	account
+	applied int
+}
+
+func newSnapshottedAccount(id string) *snapshottedAccount {
+	return &snapshottedAccount{account: account{AggregateBase: NewAggregateBase(id)}}
+}
+
+func (a *snapshottedAccount) Apply(event StoredEvent) error {
+	a.applied++
+	return a.account.Apply(event)
+}
+
+func (a *snapshottedAccount) MarshalSnapshot() ([]byte, error) {
+	return json.Marshal(map[string]int{"balance": a.balance})
+}
+
+func (a *snapshottedAccount) UnmarshalSnapshot(state []byte) error {
+	var s struct {
+		Balance *int `json:"balance"`
+	}
+	if err := json.Unmarshal(state, &s); err != nil {
+		return err
+	}
+	if s.Balance == nil {
+		return assert.AnError
+	}
+	a.balance = *s.Balance
+	return nil
+}
+
+// deposit loads the account, unless it is new, and saves a deposit.
+func deposit(t testing.TB, repo *AggregateRepository[*snapshottedAccount], id string, amount int) {
+	t.Helper()
+	ctx := context.Background()
+
+	a, err := repo.Load(ctx, id)
+	if err != nil {
+		a = newSnapshottedAccount(id)
+	}
+	require.NoError(t, Raise(a, "Deposited", amountChanged{Amount: amount}))
+	require.NoError(t, repo.Save(ctx, a))
+}
+
+func TestAggregateRepositorySnapshots(t *testing.T) {
+	ctx := context.Background()
+	snapshots := NewMemorySnapshotStore()
+	repo := NewAggregateRepository(NewMemoryEventStore(), newSnapshottedAccount, nil).WithSnapshots(snapshots, 3)
+
+	for range 7 {
+		deposit(t, repo, "account-1", 10)
+	}
+
+	version, state, err := snapshots.LoadSnapshot(ctx, "account-1")
+	require.NoError(t, err)
+	assert.Equal(t, 6, version)
+	assert.JSONEq(t, `{"balance":60}`, string(state))
+
+	a, err := repo.Load(ctx, "account-1")
+	require.NoError(t, err)
+	assert.Equal(t, 70, a.balance)
+	assert.Equal(t, 7, a.Version())
+	assert.Equal(t, 1, a.applied)
+
+	// saving several events at once snapshots when crossing the interval
+	for range 3 {
+		require.NoError(t, Raise(a, "Deposited", amountChanged{Amount: 1}))
+	}
+	require.NoError(t, repo.Save(ctx, a))
+	version, _, err = snapshots.LoadSnapshot(ctx, "account-1")
+	require.NoError(t, err)
+	assert.Equal(t, 10, version)
+}
+
+func TestAggregateRepositoryStaleSnapshot(t *testing.T) {
+	ctx := context.Background()
+
+	for name, snap := range map[string]struct {
+		version int
+		state   string
+	}{
+		"ahead of the stream":  {version: 10, state: `{"balance":1000}`},
+		"unknown shape":        {version: 3, state: `{"amount":1000}`},
+		"not a snapshot":       {version: 3, state: `garbage`},
+		"version of no events": {version: 0, state: `{"balance":1000}`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			snapshots := NewMemorySnapshotStore()
+			repo := NewAggregateRepository(NewMemoryEventStore(), newSnapshottedAccount, nil).WithSnapshots(snapshots, 100)
+			for range 5 {
+				deposit(t, repo, "account-1", 10)
+			}
+			require.NoError(t, snapshots.SaveSnapshot(ctx, "account-1", snap.version, []byte(snap.state)))
+
+			a, err := repo.Load(ctx, "account-1")
+			require.NoError(t, err)
+			assert.Equal(t, 50, a.balance)
+			assert.Equal(t, 5, a.Version())
+			assert.Equal(t, 5, a.applied)
+		})
+	}
+}
+
+func TestAggregateRepositoryWithoutSnapshotter(t *testing.T) {
+	ctx := context.Background()
+	snapshots := NewMemorySnapshotStore()
+	repo := NewAggregateRepository(NewMemoryEventStore(), newAccount, nil).WithSnapshots(snapshots, 1)
+
+	a := newAccount("account-1")
+	require.NoError(t, Raise(a, "Deposited", amountChanged{Amount: 10}))
+	require.NoError(t, repo.Save(ctx, a))
+
+	version, state, err := snapshots.LoadSnapshot(ctx, "account-1")
+	require.NoError(t, err)
+	assert.Zero(t, version)
+	assert.Nil(t, state)
+}
+
+func TestMemorySnapshotStoreKeepsNewest(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemorySnapshotStore()
+
+	require.NoError(t, s.SaveSnapshot(ctx, "account-1", 6, []byte("six")))
+	require.NoError(t, s.SaveSnapshot(ctx, "account-1", 3, []byte("three")))
+
+	version, state, err := s.LoadSnapshot(ctx, "account-1")
+	require.NoError(t, err)
+	assert.Equal(t, 6, version)
+	assert.Equal(t, "six", string(state))
+}
+
+func TestPostgresSnapshotStore(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	s := NewPostgresSnapshotStore(db, "")
+
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "snapshots" (stream_id, version, state, taken_at) VALUES ($1, $2, $3, $4)`)).
+		WithArgs("account-1", 6, []byte("state"), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, s.SaveSnapshot(ctx, "account-1", 6, []byte("state")))
+
+	load := regexp.QuoteMeta(`SELECT version, state FROM "snapshots" WHERE stream_id = $1`)
+	mock.ExpectQuery(load).WithArgs("account-1").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "state"}).AddRow(6, []byte("state")))
+	version, state, err := s.LoadSnapshot(ctx, "account-1")
+	require.NoError(t, err)
+	assert.Equal(t, 6, version)
+	assert.Equal(t, "state", string(state))
+
+	mock.ExpectQuery(load).WithArgs("account-2").WillReturnError(sql.ErrNoRows)
+	version, state, err = s.LoadSnapshot(ctx, "account-2")
+	require.NoError(t, err)
+	assert.Zero(t, version)
+	assert.Nil(t, state)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Contains(t, SnapshotSchema(""), `CREATE TABLE IF NOT EXISTS "snapshots"`)
+}
+
+func BenchmarkAggregateRepositoryLoad(b *testing.B) {
+	const events = 2000
+
+	for name, snapshotEvery := range map[string]int{"replay": 0, "snapshot every 100": 100} {
+		b.Run(name, func(b *testing.B) {
+			repo := NewAggregateRepository(NewMemoryEventStore(), newSnapshottedAccount, nil)
+			if snapshotEvery > 0 {
+				repo.WithSnapshots(NewMemorySnapshotStore(), snapshotEvery)
+			}
+
+			a := newSnapshottedAccount("order-1")
+			for range events {
+				require.NoError(b, Raise(a, "Deposited", amountChanged{Amount: 1}))
+			}
+			require.NoError(b, repo.Save(context.Background(), a))
+
+			b.ResetTimer()
+			for b.Loop() {
+				a, err := repo.Load(context.Background(), "order-1")
+				if err != nil || a.balance != events {
+					b.Fatal(err, a.balance)
+				}
+			}
+		})
+	}
+}
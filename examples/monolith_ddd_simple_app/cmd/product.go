@@ -1,38 +1,150 @@
 package cmd
 
 import (
+	"context"
 	"github.com/a-aslani/wotop"
 	"github.com/a-aslani/wotop/examples/monolith_ddd_simple_app/configs"
+	"github.com/a-aslani/wotop/examples/monolith_ddd_simple_app/internal/controller/http"
+	productevent "github.com/a-aslani/wotop/examples/monolith_ddd_simple_app/internal/product/event"
+	"github.com/a-aslani/wotop/examples/monolith_ddd_simple_app/internal/product/gateway"
+	"github.com/a-aslani/wotop/examples/monolith_ddd_simple_app/internal/product/usecase/reserve_stock"
 	"github.com/a-aslani/wotop/logger"
+	"github.com/a-aslani/wotop/pubsub"
+	"github.com/a-aslani/wotop/rabbitmq_controller"
 )
 
-type product struct{}
+const appName = "product"
+
+type product struct {
+	log syncLogger
+}
+
+// syncLogger is a logger buffering its entries, as the GrayLog logger.
+type syncLogger interface {
+	logger.Logger
+	Sync() error
+}
 
 func NewProduct() wotop.Runner[configs.Config] {
 	return &product{}
 }
 
-func (p product) Run(cfg *configs.Config) error {
+// OnStarting connects the logger before anything accepts traffic.
+func (p *product) OnStarting(_ context.Context, cfg *configs.Config) error {
+	log, err := logger.NewGrayLog(cfg.GraylogAddr, cfg.Stage)
+	if err != nil {
+		return err
+	}
+	p.log = log
+	return nil
+}
 
-	const appName = "product"
+// OnReady is called once the HTTP server and the consumer are started.
+func (p *product) OnReady(ctx context.Context) error {
+	p.log.Info(ctx, "%s is ready", appName)
+	return nil
+}
 
-	appData := wotop.NewApplicationData(appName)
+// OnStopping is called on SIGINT or SIGTERM, before the HTTP server and the consumer are stopped.
+func (p *product) OnStopping(ctx context.Context) error {
+	p.log.Info(ctx, "%s is stopping", appName)
+	return nil
+}
 
-	_ = appData
+func (p *product) Run(cfg *configs.Config) error {
+	return p.RunContext(context.Background(), cfg)
+}
 
-	log, err := logger.NewGrayLog(cfg.GraylogAddr, cfg.Stage)
+// RunContext runs the application until SIGINT, SIGTERM or, with wotop.RunAll, another
+// application returns.
+func (p *product) RunContext(ctx context.Context, cfg *configs.Config) error {
+
+	defer p.log.Sync()
+
+	c := p.container(appName, cfg, p.log)
+
+	primaryDriver, err := wotop.Resolve[wotop.ControllerRegisterer](c)
 	if err != nil {
 		return err
 	}
 
-	defer log.Sync()
+	primaryDriver.RegisterMetrics(appName)
+	primaryDriver.RegisterRouter()
 
-	return nil
+	lifecycle := wotop.NewLifecycle().WithHooks(p).Register(primaryDriver)
+
+	if cfg.RabbitMQ.Host != "" {
+		consumer, err := wotop.Resolve[wotop.RabbitmqConsumerRegisterer](c)
+		if err != nil {
+			return err
+		}
+		lifecycle.Register(consumer)
+	}
 
+	// The lifecycle stops the HTTP server and the consumer on SIGINT, SIGTERM or when ctx is canceled.
+	return lifecycle.Run(ctx)
 }
 
-func (product) registerUsecase(
-	log logger.Logger,
-) {
+// container provides the dependencies of the application, tests replace them with wotop.Override.
+func (product) container(appName string, cfg *configs.Config, log logger.Logger) *wotop.Container {
+
+	c := wotop.NewContainer()
+
+	wotop.Provide(c, func(*wotop.Container) (wotop.ApplicationData, error) {
+		return wotop.NewApplicationData(appName), nil
+	})
+
+	wotop.Provide(c, func(*wotop.Container) (logger.Logger, error) {
+		return log, nil
+	})
+
+	wotop.Provide(c, func(c *wotop.Container) (wotop.ControllerRegisterer, error) {
+		appData, err := wotop.Resolve[wotop.ApplicationData](c)
+		if err != nil {
+			return nil, err
+		}
+
+		log, err := wotop.Resolve[logger.Logger](c)
+		if err != nil {
+			return nil, err
+		}
+
+		primaryDriver := http.NewController(appData, log, cfg, nil)
+
+		// register the interactors here, resolving their outports from the container, e.g.
+		// primaryDriver.AddUsecase(create_product.NewUsecase(wotop.MustResolve[create_product.Outport](c)))
+
+		return primaryDriver, nil
+	})
+
+	wotop.Provide(c, func(*wotop.Container) (*pubsub.Event, error) {
+		return pubsub.NewEvent(appName, cfg.RabbitMQ.Username, cfg.RabbitMQ.Password, cfg.RabbitMQ.Host, cfg.RabbitMQ.VHost)
+	})
+
+	wotop.Provide(c, func(c *wotop.Container) (wotop.RabbitmqConsumerRegisterer, error) {
+		appData, err := wotop.Resolve[wotop.ApplicationData](c)
+		if err != nil {
+			return nil, err
+		}
+
+		event, err := wotop.Resolve[*pubsub.Event](c)
+		if err != nil {
+			return nil, err
+		}
+
+		consumer := rabbitmq_controller.NewConsumerController(appData, wotop.MustResolve[logger.Logger](c), cfg.RabbitMQ.Consumer, event)
+
+		// each event executes the inport of a usecase: the payload is mapped to the request, the
+		// ID of the event is the trace ID and the message is acked when the inport succeeds
+		consumer.AddUsecase(reserve_stock.NewUsecase(wotop.MustResolve[reserve_stock.Outport](c)))
+		consumer.Handle(productevent.OrderPlacedName, rabbitmq_controller.MapInport[reserve_stock.InportRequest, reserve_stock.InportResponse](consumer, productevent.MapOrderPlacedToReserveStock))
+
+		return consumer, nil
+	})
+
+	wotop.Provide(c, func(*wotop.Container) (reserve_stock.Outport, error) {
+		return gateway.NewStock(map[string]int{"p-1": 10}), nil
+	})
 
+	return c
 }
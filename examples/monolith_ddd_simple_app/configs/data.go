@@ -1,12 +1,27 @@
 package configs
 
+import "github.com/a-aslani/wotop/rabbitmq_controller"
+
 type Config struct {
 	Stage       string            `mapstructure:"stage"`
 	Servers     map[string]Server `mapstructure:"servers"`
 	GraylogAddr string            `mapstructure:"graylog_address"`
+	RabbitMQ    RabbitMQ          `mapstructure:"rabbitmq"`
+}
+
+// RabbitMQ configures the event connection, the consumer is not started when Host is empty.
+type RabbitMQ struct {
+	Username string                     `mapstructure:"username"`
+	Password string                     `mapstructure:"password"`
+	Host     string                     `mapstructure:"host"`
+	VHost    string                     `mapstructure:"vhost"`
+	Consumer rabbitmq_controller.Config `mapstructure:"consumer"`
 }
 
 type Server struct {
 	Address   string `mapstructure:"address,omitempty"`
 	ProxyPath string `mapstructure:"proxy_path,omitempty"`
+	// LegacyMetrics also records the deprecated per-service http_request_counter and
+	// http_request_latency metrics, for the dashboards not moved to http_requests_total yet.
+	LegacyMetrics bool `mapstructure:"legacy_metrics,omitempty"`
 }
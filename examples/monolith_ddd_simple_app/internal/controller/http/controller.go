@@ -1,6 +1,7 @@
 package http
 
 import (
+	"context"
 	"fmt"
 	"github.com/a-aslani/wotop"
 	"github.com/a-aslani/wotop/examples/monolith_ddd_simple_app/configs"
@@ -8,24 +9,21 @@ import (
 	"github.com/a-aslani/wotop/logger"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"github.com/prometheus/client_golang/prometheus"
 	"net/http"
 	"time"
 )
 
 // controller represents the HTTP controller for the application.
-// It includes the router, logger, configuration, Token handler, and metrics for monitoring.
+// It includes the router, logger, configuration, and Token handler.
 type controller struct {
-	wotop.ControllerRegisterer                      // Embeds the ControllerStarter interface for starting the controller.
-	wotop.UsecaseRegisterer                         // Embeds the UsecaseRegisterer interface for registering use cases.
-	Router                     *gin.Engine          // The Gin router instance for handling HTTP requests.
-	log                        logger.Logger        // Logger for logging application events.
-	cfg                        *configs.Config      // Configuration settings for the application.
-	jwt                        jwt.Token            // Token handler for managing JSON Web Tokens.
-	reqCounter                 prometheus.Counter   // Prometheus counter for tracking HTTP request counts.
-	reqLatency                 prometheus.Histogram // Prometheus histogram for measuring request latency.
-	proxyPath                  string               // Proxy path for the application.
-	appName                    string               // Name of the application.
+	wotop.ControllerStarter                 // Embeds the ControllerStarter interface for starting the controller.
+	wotop.UsecaseRegisterer                 // Embeds the UsecaseRegisterer interface for registering use cases.
+	Router                  *gin.Engine     // The Gin router instance for handling HTTP requests.
+	log                     logger.Logger   // Logger for logging application events.
+	cfg                     *configs.Config // Configuration settings for the application.
+	jwt                     jwt.Token       // Token handler for managing JSON Web Tokens.
+	proxyPath               string          // Proxy path for the application.
+	appName                 string          // Name of the application.
 }
 
 // NewController creates a new instance of the HTTP controller.
@@ -41,8 +39,9 @@ type controller struct {
 //	A wotop.ControllerRegisterer instance for registering the controller.
 func NewController(appData wotop.ApplicationData, log logger.Logger, cfg *configs.Config, jwt jwt.Token) wotop.ControllerRegisterer {
 
-	// Create a new Gin router instance.
-	router := gin.Default()
+	// Create a new Gin router instance, recovering the panics with error responses carrying the trace ID.
+	router := gin.New()
+	router.Use(gin.Logger(), logger.RecoveryMiddleware(log))
 
 	// PING API
 	// Define a ping endpoint to check the health of the application.
@@ -69,7 +68,7 @@ func NewController(appData wotop.ApplicationData, log logger.Logger, cfg *config
 
 	// Return a new controller instance with the configured router and dependencies.
 	return &controller{
-		ControllerStarter: NewGracefullyShutdown(log, router, address),
+		ControllerStarter: wotop.NewGracefulHTTPServer(log, router, address, wotop.GracefulOptions{}),
 		UsecaseRegisterer: wotop.NewBaseController(),
 		Router:            router,
 		log:               log,
@@ -79,3 +78,18 @@ func NewController(appData wotop.ApplicationData, log logger.Logger, cfg *config
 		appName:           appData.AppName,
 	}
 }
+
+// Stop shuts the HTTP server down gracefully, it is called by wotop.Lifecycle.
+//
+// Parameters:
+//   - ctx: The context bounding the shutdown.
+//
+// Returns:
+//
+//	An error if the server cannot be shut down before the context is done.
+func (r *controller) Stop(ctx context.Context) error {
+	if stopper, ok := r.ControllerStarter.(wotop.ControllerStopper); ok {
+		return stopper.Stop(ctx)
+	}
+	return nil
+}
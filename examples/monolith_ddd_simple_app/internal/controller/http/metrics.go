@@ -1,11 +1,8 @@
 package http
 
 import (
-	"fmt"
-	"github.com/gin-gonic/gin"
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/http_metrics"
 )
 
 // RegisterMetrics sets up Prometheus metrics for the HTTP server.
@@ -13,37 +10,21 @@ import (
 // Parameters:
 //   - serviceName: The name of the service for which metrics are being registered.
 //
-// This function registers a `/metrics` endpoint for Prometheus to scrape metrics.
-// It also initializes a request counter and a latency histogram for monitoring HTTP requests.
+// This function registers the build_info gauge and the Go runtime metrics, records every
+// request by method, route and status class, and registers a
+// `/metrics` endpoint for Prometheus to scrape metrics. It must be called before RegisterRouter,
+// the routes registered earlier are not recorded.
 func (r *controller) RegisterMetrics(serviceName string) {
 
-	// Register the `/metrics` endpoint to expose Prometheus metrics.
-	r.Router.GET("/metrics", prometheusHandler())
-
-	// Initialize a Prometheus counter to track the number of HTTP requests.
-	r.reqCounter = promauto.NewCounter(prometheus.CounterOpts{
-		Namespace: "http_request_counter",
-		Name:      serviceName,
-		Help:      fmt.Sprintf("Count of request to the %s service", serviceName),
-	})
-
-	// Initialize a Prometheus histogram to measure the latency of HTTP requests.
-	r.reqLatency = promauto.NewHistogram(prometheus.HistogramOpts{
-		Namespace: "http_request_latency",
-		Name:      serviceName,
-		Buckets:   []float64{0.1, 0.5, 1.0},
-	})
+	// Expose the version, commit and build time of the application, and the Go runtime.
+	wotop.RegisterBuildInfo(nil, serviceName, wotop.GetBuildInfo())
 
-}
+	// Record the requests, the 404s included, with an engine middleware.
+	r.Router.Use(http_metrics.Middleware(serviceName, http_metrics.Options{
+		SkipPaths: []string{"/metrics"},
+		Legacy:    r.cfg.Servers[r.appName].LegacyMetrics,
+	}))
 
-// prometheusHandler returns a Gin middleware handler for serving Prometheus metrics.
-//
-// This function wraps the Prometheus HTTP handler and integrates it with the Gin framework.
-func prometheusHandler() gin.HandlerFunc {
-	// Create the Prometheus HTTP handler.
-	h := promhttp.Handler()
-	return func(c *gin.Context) {
-		// Serve the Prometheus metrics using the HTTP handler.
-		h.ServeHTTP(c.Writer, c.Request)
-	}
+	// Register the `/metrics` endpoint to expose Prometheus metrics.
+	r.Router.GET("/metrics", http_metrics.Handler(nil))
 }
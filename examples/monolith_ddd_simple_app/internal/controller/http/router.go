@@ -1,13 +1,18 @@
 package http
 
+import (
+	"github.com/a-aslani/wotop/jwt"
+	"github.com/a-aslani/wotop/logger"
+)
+
 // RegisterRouter sets up the HTTP routes for the application.
-// It defines a resource group with middleware for metrics and latency checks,
+// It defines a resource group, recorded by the metrics middleware of RegisterMetrics,
 // and registers the v1 API endpoints, including an authenticated POST endpoint
-// for managing affiliates.
+// for managing affiliates, and the admin endpoints, such as the log level.
 func (r *controller) RegisterRouter() {
 
-	// Create a resource group with middleware for metrics and latency checks
-	resource := r.Router.Group(r.proxyPath, r.metricRequestCounter(), r.metricLatencyRequestChecker())
+	// Create a resource group under the proxy path
+	resource := r.Router.Group(r.proxyPath)
 
 	// APPLICATION API
 	// Define the v1 API group
@@ -15,4 +20,16 @@ func (r *controller) RegisterRouter() {
 
 	// Register a POST endpoint for affiliates with authentication middleware
 	v1.POST("/affiliates", r.authentication())
+
+	// ADMIN API
+	// Only the admins may read and change the log level at runtime
+	middleware := jwt.NewGinMiddleware(r.log)
+	auth := r.authentication()
+	if r.jwt != nil {
+		auth = middleware.Authentication(r.jwt)
+	}
+	admin := resource.Group("/admin", auth, middleware.RequireRole("admin"))
+	logLevel := logger.LevelHandler(r.log)
+	admin.GET("/log-level", logLevel)
+	admin.PUT("/log-level", logLevel)
 }
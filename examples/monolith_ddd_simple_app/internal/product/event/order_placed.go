@@ -0,0 +1,19 @@
+package event
+
+import "time"
+
+// OrderPlacedName is the routing key the OrderPlaced event is published with by the order service.
+const OrderPlacedName = "order.placed"
+
+// OrderPlaced is the payload of the order.placed event.
+type OrderPlaced struct {
+	ID         string      `json:"id"`
+	Lines      []OrderLine `json:"lines"`
+	OccurredAt time.Time   `json:"occurred_at"`
+}
+
+// OrderLine is a product of a placed order.
+type OrderLine struct {
+	ProductID string `json:"product_id"`
+	Quantity  int    `json:"quantity"`
+}
@@ -0,0 +1,63 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/examples/monolith_ddd_simple_app/internal/product/gateway"
+	"github.com/a-aslani/wotop/examples/monolith_ddd_simple_app/internal/product/usecase/reserve_stock"
+	"github.com/a-aslani/wotop/pubsub"
+	"github.com/a-aslani/wotop/rabbitmq_controller"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// settlement records how a delivery is settled.
+type settlement struct {
+	outcome string
+}
+
+func (s *settlement) Ack(uint64, bool) error        { s.outcome = "ack"; return nil }
+func (s *settlement) Nack(uint64, bool, bool) error { s.outcome = "nack"; return nil }
+func (s *settlement) Reject(uint64, bool) error     { s.outcome = "reject"; return nil }
+
+type nopLogger struct{}
+
+func (nopLogger) Info(context.Context, string, ...any)    {}
+func (nopLogger) Error(context.Context, string, ...any)   {}
+func (nopLogger) Warning(context.Context, string, ...any) {}
+
+// deliver consumes the order.placed event as published by pubsub.Event.Publish.
+func deliver(t *testing.T, consumer wotop.RabbitmqConsumerRegisterer, payload OrderPlaced) string {
+	t.Helper()
+	body, err := json.Marshal(pubsub.EventData{ID: "evt-" + payload.ID, Name: OrderPlacedName, Payload: payload})
+	require.NoError(t, err)
+
+	s := &settlement{}
+	consumer.ConsumeMessage(0, &amqp.Delivery{Acknowledger: s, RoutingKey: OrderPlacedName, Body: body})
+	return s.outcome
+}
+
+func TestOrderPlacedReservesStock(t *testing.T) {
+	stock := gateway.NewStock(map[string]int{"p-1": 3})
+
+	consumer := rabbitmq_controller.NewConsumerController(wotop.NewApplicationData("product"), nopLogger{}, rabbitmq_controller.Config{}, nil)
+	consumer.AddUsecase(reserve_stock.NewUsecase(stock))
+	consumer.Handle(OrderPlacedName, rabbitmq_controller.MapInport[reserve_stock.InportRequest, reserve_stock.InportResponse](consumer, MapOrderPlacedToReserveStock))
+
+	assert.Equal(t, "ack", deliver(t, consumer, OrderPlaced{ID: "o-1", Lines: []OrderLine{{ProductID: "p-1", Quantity: 2}}}))
+	assert.Equal(t, "ack", deliver(t, consumer, OrderPlaced{ID: "o-1", Lines: []OrderLine{{ProductID: "p-1", Quantity: 2}}}), "a redelivered order is reserved once")
+
+	// the stock may be replenished, the message is retried
+	assert.Equal(t, "nack", deliver(t, consumer, OrderPlaced{ID: "o-2", Lines: []OrderLine{{ProductID: "p-1", Quantity: 2}}}))
+
+	// an order the mapper refuses is not retried
+	assert.Equal(t, "reject", deliver(t, consumer, OrderPlaced{ID: "o-3"}))
+
+	remaining, err := stock.ReserveStock(context.Background(), "o-4", "p-1", 1)
+	require.NoError(t, err)
+	assert.Zero(t, remaining)
+}
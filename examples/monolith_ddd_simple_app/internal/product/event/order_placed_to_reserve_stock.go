@@ -0,0 +1,22 @@
+package event
+
+import (
+	"context"
+	"errors"
+
+	"github.com/a-aslani/wotop/examples/monolith_ddd_simple_app/internal/product/usecase/reserve_stock"
+)
+
+// MapOrderPlacedToReserveStock builds the request of the reserve_stock usecase from the
+// order.placed event, see rabbitmq_controller.MapInport.
+func MapOrderPlacedToReserveStock(ctx context.Context, payload OrderPlaced) (reserve_stock.InportRequest, error) {
+	// the example reserves orders of a single product
+	if len(payload.Lines) != 1 {
+		return reserve_stock.InportRequest{}, errors.New("the order must have one line")
+	}
+	return reserve_stock.InportRequest{
+		OrderID:   payload.ID,
+		ProductID: payload.Lines[0].ProductID,
+		Quantity:  payload.Lines[0].Quantity,
+	}, nil
+}
@@ -0,0 +1,39 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Stock is an in-memory stock of products, the outport of the reserve_stock usecase. A real
+// service keeps it in its database.
+type Stock struct {
+	mu       sync.Mutex
+	quantity map[string]int
+	reserved map[string]int // the remaining quantity after each reserved order, as messages are redelivered
+}
+
+// NewStock creates a Stock with the quantities of the products.
+func NewStock(quantity map[string]int) *Stock {
+	return &Stock{quantity: quantity, reserved: map[string]int{}}
+}
+
+// ReserveStock takes the quantity of the product from its stock for the order, once per order.
+func (s *Stock) ReserveStock(_ context.Context, orderID, productID string, quantity int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if remaining, ok := s.reserved[orderID]; ok {
+		return remaining, nil
+	}
+
+	available := s.quantity[productID]
+	if available < quantity {
+		return 0, fmt.Errorf("product %s has %d items left, %d are ordered", productID, available, quantity)
+	}
+
+	s.quantity[productID] = available - quantity
+	s.reserved[orderID] = available - quantity
+	return available - quantity, nil
+}
@@ -0,0 +1,16 @@
+package reserve_stock
+
+import "github.com/a-aslani/wotop"
+
+type Inport = wotop.Inport[InportRequest, InportResponse]
+
+// InportRequest reserves the quantity of a product for an order.
+type InportRequest struct {
+	OrderID   string
+	ProductID string
+	Quantity  int
+}
+
+type InportResponse struct {
+	Remaining int
+}
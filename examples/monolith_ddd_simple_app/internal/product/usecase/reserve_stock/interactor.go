@@ -0,0 +1,30 @@
+package reserve_stock
+
+import (
+	"context"
+	"errors"
+)
+
+type interactor struct {
+	outport Outport
+}
+
+func NewUsecase(outport Outport) Inport {
+	return &interactor{
+		outport: outport,
+	}
+}
+
+func (i interactor) Execute(ctx context.Context, req InportRequest) (*InportResponse, error) {
+
+	if req.Quantity <= 0 {
+		return nil, errors.New("the quantity to reserve must be positive")
+	}
+
+	remaining, err := i.outport.ReserveStock(ctx, req.OrderID, req.ProductID, req.Quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	return &InportResponse{Remaining: remaining}, nil
+}
@@ -0,0 +1,8 @@
+package reserve_stock
+
+import "context"
+
+type Outport interface {
+	// ReserveStock takes the quantity of the product from its stock for the order, once per order.
+	ReserveStock(ctx context.Context, orderID, productID string, quantity int) (remaining int, err error)
+}
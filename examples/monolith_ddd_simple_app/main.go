@@ -9,11 +9,9 @@ import (
 	"os"
 )
 
-// Version specifies the current version of the application.
-var Version = "0.0.1"
-
 // main is the entry point of the application.
 // It loads the configuration, initializes the application map, and runs the selected application.
+// The version of the application is set at build time with the -X flags of the linker, see wotop.Version.
 func main() {
 
 	// Retrieve the configuration file path from the environment variable CONFIG_FILE.
@@ -40,23 +38,29 @@ func main() {
 	// Parse command-line flags.
 	flag.Parse()
 
-	// Retrieve the application name from the command-line arguments.
+	// Retrieve the application name from the command-line arguments, "all" runs every application in one process.
 	app, exist := appMap[flag.Arg(0)]
-	if !exist {
+	if !exist && flag.Arg(0) != wotop.AllApps {
 		// Print usage instructions if the application name is not found in the map.
 		fmt.Printf("You may try :\n\n")
 		for appName := range appMap {
 			fmt.Printf("    go run main.go %s\n", appName)
 		}
+		fmt.Printf("    go run main.go %s\n", wotop.AllApps)
 		fmt.Printf("\n")
 		return
 	}
 
 	// Print the configuration file path and application version.
-	fmt.Printf("Config: %s - Version: %s\n", configFile, Version)
+	fmt.Printf("Config: %s - Version: %s\n", configFile, wotop.GetBuildInfo().Version)
 
-	// Run the selected application with the loaded configuration.
-	err = app.Run(cfg)
+	// Run the selected application with the loaded configuration, calling its lifecycle hooks.
+	// All of them run concurrently with "all", the first one failing stops the others.
+	if exist {
+		err = wotop.RunApp(cfg, app)
+	} else {
+		err = wotop.RunAll(cfg, appMap, flag.Args()...)
+	}
 	if err != nil {
 		// Print an error message if the application fails to run.
 		fmt.Printf("run error: %s", err.Error())
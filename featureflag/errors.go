@@ -0,0 +1,19 @@
+package featureflag
+
+import (
+	"net/http"
+
+	"github.com/a-aslani/wotop/model/apperror"
+)
+
+const (
+	// ErrFeatureDisabled indicates a use case gated by RequireFlag whose flag is disabled for the caller.
+	ErrFeatureDisabled apperror.ErrorType = "ER0961 the feature %s is not available"
+)
+
+func init() {
+	apperror.Register("featureflag",
+		apperror.Entry{Err: ErrFeatureDisabled, Description: "The feature is disabled, or not rolled out to the user or the tenant yet."},
+	)
+	apperror.MapCode(ErrFeatureDisabled.Code(), http.StatusForbidden)
+}
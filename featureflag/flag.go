@@ -0,0 +1,191 @@
+package featureflag
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"slices"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/logger"
+	"github.com/a-aslani/wotop/util"
+)
+
+// The attributes read by the rules of a Flag, see Attributes.
+const (
+	AttrUserID   = "user_id"
+	AttrTenantID = "tenant_id"
+	AttrRole     = "role"
+)
+
+// Provider tells whether the flags are enabled.
+type Provider interface {
+	// IsEnabled reports whether a flag is enabled for a caller. The unknown flags are disabled.
+	//
+	// Parameters:
+	//   - ctx: The context of the request.
+	//   - flag: The name of the flag.
+	//   - attrs: The attributes of the caller the rules of the flag are evaluated with, e.g. AttrUserID.
+	//
+	// Returns:
+	//   - Whether the flag is enabled.
+	IsEnabled(ctx context.Context, flag string, attrs map[string]any) bool
+}
+
+// Flag is the definition of a feature flag, evaluated locally by the providers.
+//
+// Fields:
+//   - Enabled: Whether the flag is on, the other rules only narrow it.
+//   - Rollout: The percentage of the users the flag is enabled for, by AttrUserID, 0 or 100 for all.
+//   - Tenants: The tenants the flag is enabled for, by AttrTenantID, all when empty.
+type Flag struct {
+	Enabled bool     `json:"enabled" mapstructure:"enabled"`
+	Rollout int      `json:"rollout,omitempty" mapstructure:"rollout" validate:"min:0,max:100"`
+	Tenants []string `json:"tenants,omitempty" mapstructure:"tenants"`
+}
+
+// Evaluate reports whether the flag is enabled for a caller. The rollout assigns a user to one
+// of 100 buckets by the hash of the flag name and the user ID, so a user keeps the flag while
+// its rollout grows, and the users of the first percents differ from one flag to another. The
+// callers without user ID only get the flags rolled out to everybody.
+//
+// Parameters:
+//   - name: The name of the flag.
+//   - attrs: The attributes of the caller.
+//
+// Returns:
+//   - Whether the flag is enabled.
+func (f Flag) Evaluate(name string, attrs map[string]any) bool {
+
+	if !f.Enabled {
+		return false
+	}
+
+	if len(f.Tenants) > 0 && !slices.Contains(f.Tenants, attribute(attrs, AttrTenantID)) {
+		return false
+	}
+
+	if f.Rollout > 0 && f.Rollout < 100 {
+		userID := attribute(attrs, AttrUserID)
+		if userID == "" {
+			return false
+		}
+		return bucket(name, userID) < uint32(f.Rollout)
+	}
+
+	return true
+}
+
+// bucket returns the rollout bucket, from 0 to 99, of a user for a flag.
+func bucket(flag, userID string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(flag + ":" + userID))
+	return h.Sum32() % 100
+}
+
+// attribute returns an attribute as a string, empty when it is missing.
+func attribute(attrs map[string]any, key string) string {
+	v, ok := attrs[key]
+	if !ok || v == nil {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+// Attributes returns the attributes of the caller identified in the context, see
+// wotop.WithIdentity, empty for the anonymous requests.
+//
+// Parameters:
+//   - ctx: The context of the request.
+//
+// Returns:
+//   - The AttrUserID, AttrTenantID and AttrRole attributes which are set.
+func Attributes(ctx context.Context) map[string]any {
+	attrs := map[string]any{}
+	identity, ok := wotop.IdentityFromContext(ctx)
+	if !ok {
+		return attrs
+	}
+	for key, value := range map[string]string{AttrUserID: identity.ID, AttrTenantID: identity.Tenant, AttrRole: identity.Role} {
+		if value != "" {
+			attrs[key] = value
+		}
+	}
+	return attrs
+}
+
+// options holds the settings of the providers.
+type options struct {
+	log      logger.Logger
+	fallback Provider
+	clock    util.Clock
+}
+
+// Option configures a provider.
+type Option func(*options)
+
+// WithLogger sets the logger of a provider. The evaluations are logged at the debug level, when
+// the logger is a logger.DebugLogger, and the failures of the Redis provider as warnings.
+//
+// Parameters:
+//   - log: The logger, none by default.
+//
+// Returns:
+//   - The option setting the logger.
+func WithLogger(log logger.Logger) Option {
+	return func(o *options) {
+		o.log = log
+	}
+}
+
+// WithFallback sets the provider asked by the Redis provider for the flags it cannot read while
+// Redis is down, e.g. a StaticProvider with the flags of the configuration.
+//
+// Parameters:
+//   - fallback: The provider, the flags are disabled by default.
+//
+// Returns:
+//   - The option setting the fallback.
+func WithFallback(fallback Provider) Option {
+	return func(o *options) {
+		o.fallback = fallback
+	}
+}
+
+// WithClock sets the clock expiring the flags cached by the Redis provider.
+//
+// Parameters:
+//   - clock: The clock, util.SystemClock by default.
+//
+// Returns:
+//   - The option setting the clock.
+func WithClock(clock util.Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{clock: util.SystemClock}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// logEvaluation logs the evaluation of a flag at the debug level.
+func (o options) logEvaluation(ctx context.Context, flag string, attrs map[string]any, enabled bool, source string) {
+	if o.log != nil {
+		logger.Debug(o.log, ctx, "featureflag: %s is %s for %v (%s)", flag, state(enabled), attrs, source)
+	}
+}
+
+func state(enabled bool) string {
+	if enabled {
+		return "enabled"
+	}
+	return "disabled"
+}
@@ -0,0 +1,135 @@
+package featureflag
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/logger/loggertest"
+	"github.com/a-aslani/wotop/model/apperror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func users(n int) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("user-%d", i)
+	}
+	return ids
+}
+
+func TestRolloutIsDeterministic(t *testing.T) {
+	flag := Flag{Enabled: true, Rollout: 30}
+
+	enabled := map[string]bool{}
+	for _, id := range users(1000) {
+		enabled[id] = flag.Evaluate("new-checkout", map[string]any{AttrUserID: id})
+		for range 3 {
+			assert.Equal(t, enabled[id], flag.Evaluate("new-checkout", map[string]any{AttrUserID: id}), "a user keeps its bucket")
+		}
+	}
+
+	count := 0
+	for _, on := range enabled {
+		if on {
+			count++
+		}
+	}
+	assert.InDelta(t, 300, count, 60, "about 30%% of the users get the flag")
+
+	// growing the rollout keeps the users which had the flag
+	grown := Flag{Enabled: true, Rollout: 60}
+	for id, on := range enabled {
+		if on {
+			assert.True(t, grown.Evaluate("new-checkout", map[string]any{AttrUserID: id}), id)
+		}
+	}
+
+	// the buckets differ from one flag to another
+	differ := 0
+	for id, on := range enabled {
+		if flag.Evaluate("new-search", map[string]any{AttrUserID: id}) != on {
+			differ++
+		}
+	}
+	assert.Positive(t, differ)
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := map[string]struct {
+		flag  Flag
+		attrs map[string]any
+		want  bool
+	}{
+		"disabled":                  {flag: Flag{Rollout: 100}, attrs: map[string]any{AttrUserID: "u1"}, want: false},
+		"enabled":                   {flag: Flag{Enabled: true}, want: true},
+		"full rollout":              {flag: Flag{Enabled: true, Rollout: 100}, want: true},
+		"partial rollout anonymous": {flag: Flag{Enabled: true, Rollout: 99}, want: false},
+		"targeted tenant":           {flag: Flag{Enabled: true, Tenants: []string{"acme"}}, attrs: map[string]any{AttrTenantID: "acme"}, want: true},
+		"other tenant":              {flag: Flag{Enabled: true, Tenants: []string{"acme"}}, attrs: map[string]any{AttrTenantID: "globex"}, want: false},
+		"no tenant":                 {flag: Flag{Enabled: true, Tenants: []string{"acme"}}, want: false},
+		"numeric tenant":            {flag: Flag{Enabled: true, Tenants: []string{"42"}}, attrs: map[string]any{AttrTenantID: 42}, want: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.flag.Evaluate("flag", tt.attrs))
+		})
+	}
+}
+
+func TestStaticProvider(t *testing.T) {
+	ctx := context.Background()
+	log := &loggertest.Recorder{}
+	p := NewStaticProvider(map[string]Flag{"beta": {Enabled: true, Tenants: []string{"acme"}}}, WithLogger(log))
+
+	assert.True(t, p.IsEnabled(ctx, "beta", map[string]any{AttrTenantID: "acme"}))
+	assert.False(t, p.IsEnabled(ctx, "beta", map[string]any{AttrTenantID: "globex"}))
+	assert.False(t, p.IsEnabled(ctx, "unknown", nil), "the unknown flags are disabled")
+	assert.Equal(t, []string{
+		"featureflag: beta is enabled for map[tenant_id:acme] (static)",
+		"featureflag: beta is disabled for map[tenant_id:globex] (static)",
+		"featureflag: unknown is disabled for map[] (static)",
+	}, log.Messages(loggertest.LevelDebug))
+
+	p.Set(map[string]Flag{"beta": {Enabled: true}})
+	assert.True(t, p.IsEnabled(ctx, "beta", map[string]any{AttrTenantID: "globex"}))
+}
+
+// greet is the inport gated in the tests.
+type greet struct{ executed int }
+
+func (g *greet) Execute(_ context.Context, name string) (*string, error) {
+	g.executed++
+	res := "hello " + name
+	return &res, nil
+}
+
+func TestRequireFlag(t *testing.T) {
+	p := NewStaticProvider(map[string]Flag{"greeting": {Enabled: true, Tenants: []string{"acme"}}})
+	inport := &greet{}
+	gated := RequireFlag[string, string](p, "greeting")(inport)
+
+	ctx := wotop.WithIdentity(context.Background(), wotop.Identity{ID: "u1", Tenant: "acme"})
+	res, err := gated.Execute(ctx, "bob")
+	require.NoError(t, err)
+	assert.Equal(t, "hello bob", *res)
+
+	ctx = wotop.WithIdentity(context.Background(), wotop.Identity{ID: "u2", Tenant: "globex"})
+	_, err = gated.Execute(ctx, "alice")
+	var et apperror.ErrorType
+	require.ErrorAs(t, err, &et)
+	assert.Equal(t, ErrFeatureDisabled.Code(), et.Code())
+	assert.EqualError(t, err, "the feature greeting is not available")
+	assert.Equal(t, 1, inport.executed, "the inport is not executed")
+
+	assert.Equal(t, inport, gated.(wotop.Wrapper).Unwrap())
+}
+
+func TestAttributes(t *testing.T) {
+	assert.Empty(t, Attributes(context.Background()))
+
+	ctx := wotop.WithIdentity(context.Background(), wotop.Identity{ID: "u1", Role: "admin"})
+	assert.Equal(t, map[string]any{AttrUserID: "u1", AttrRole: "admin"}, Attributes(ctx))
+}
@@ -0,0 +1,28 @@
+package featureflag
+
+import (
+	"context"
+
+	"github.com/a-aslani/wotop"
+)
+
+// RequireFlag gates a use case behind a flag, evaluated with the Attributes of the caller of
+// each execution. The inport is not executed while the flag is disabled, ErrFeatureDisabled is
+// returned instead, a 403.
+//
+// Parameters:
+//   - provider: The provider of the flag.
+//   - flag: The name of the flag.
+//
+// Returns:
+//   - A Middleware gating the executions.
+func RequireFlag[REQ, RES any](provider Provider, flag string) wotop.Middleware[REQ, RES] {
+	return func(next wotop.Inport[REQ, RES]) wotop.Inport[REQ, RES] {
+		return wotop.Wrap(next, func(ctx context.Context, req REQ) (*RES, error) {
+			if !provider.IsEnabled(ctx, flag, Attributes(ctx)) {
+				return nil, ErrFeatureDisabled.Var(flag)
+			}
+			return next.Execute(ctx, req)
+		})
+	}
+}
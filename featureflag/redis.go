@@ -0,0 +1,166 @@
+package featureflag
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisKeyPrefix prefixes the keys of the flags stored by RedisProvider.
+const RedisKeyPrefix = "featureflag:"
+
+// DefaultCacheTTL is the time RedisProvider caches a flag, when NewRedisProvider gets no TTL.
+const DefaultCacheTTL = 30 * time.Second
+
+// RedisProvider is a Provider reading the flags from Redis, where they are stored as JSON, so
+// they can be changed at runtime for all the replicas. A flag is cached for a TTL: a change
+// takes up to the TTL to be seen, and Redis is read once per flag and TTL only.
+//
+// While Redis cannot be read, the cached flags are kept, even the expired ones, and the flags
+// never read are asked to the fallback provider. A failed read is retried after the TTL, the
+// failures are logged as warnings. It is safe for concurrent use.
+type RedisProvider struct {
+	rdb  *redis.Client
+	ttl  time.Duration
+	opts options
+
+	mu    sync.Mutex
+	cache map[string]cachedFlag
+}
+
+// cachedFlag is a flag cached by RedisProvider.
+type cachedFlag struct {
+	flag      Flag
+	fallback  bool // the flag could not be read, the fallback provider is asked
+	expiresAt time.Time
+}
+
+// NewRedisProvider creates a RedisProvider.
+//
+// Parameters:
+//   - rdb: The Redis client.
+//   - ttl: The time a flag is cached, DefaultCacheTTL when zero.
+//   - opts: The fallback provider, the logger and the clock.
+//
+// Returns:
+//   - The provider.
+func NewRedisProvider(rdb *redis.Client, ttl time.Duration, opts ...Option) *RedisProvider {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	return &RedisProvider{rdb: rdb, ttl: ttl, opts: newOptions(opts), cache: map[string]cachedFlag{}}
+}
+
+func (p *RedisProvider) IsEnabled(ctx context.Context, flag string, attrs map[string]any) bool {
+
+	cached := p.lookup(ctx, flag)
+
+	if cached.fallback {
+		enabled := p.opts.fallback != nil && p.opts.fallback.IsEnabled(ctx, flag, attrs)
+		p.opts.logEvaluation(ctx, flag, attrs, enabled, "fallback")
+		return enabled
+	}
+
+	enabled := cached.flag.Evaluate(flag, attrs)
+	p.opts.logEvaluation(ctx, flag, attrs, enabled, "redis")
+	return enabled
+}
+
+// lookup returns the cached flag, read again from Redis once it expired.
+func (p *RedisProvider) lookup(ctx context.Context, name string) cachedFlag {
+
+	now := p.opts.clock.Now()
+
+	p.mu.Lock()
+	cached, ok := p.cache[name]
+	p.mu.Unlock()
+
+	if ok && now.Before(cached.expiresAt) {
+		return cached
+	}
+
+	flag, err := p.read(ctx, name)
+	if err != nil {
+		if p.opts.log != nil {
+			p.opts.log.Warning(ctx, "featureflag: cannot read %s from Redis, retrying in %s: %v", name, p.ttl, err)
+		}
+		if !ok {
+			cached = cachedFlag{fallback: true}
+		}
+	} else {
+		cached = cachedFlag{flag: flag}
+	}
+	cached.expiresAt = now.Add(p.ttl)
+
+	p.mu.Lock()
+	p.cache[name] = cached
+	p.mu.Unlock()
+
+	return cached
+}
+
+// read reads a flag from Redis, the zero Flag, disabled, when it is not stored.
+func (p *RedisProvider) read(ctx context.Context, name string) (Flag, error) {
+	var flag Flag
+
+	value, err := p.rdb.Get(ctx, RedisKeyPrefix+name).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return flag, nil
+	}
+	if err != nil {
+		return flag, err
+	}
+
+	err = json.Unmarshal(value, &flag)
+	return flag, err
+}
+
+// SetFlag stores a flag in Redis. The other replicas see it once their cached flag expires,
+// this one at once.
+//
+// Parameters:
+//   - ctx: The context of the request.
+//   - name: The name of the flag.
+//   - flag: The flag.
+//
+// Returns:
+//   - An error if the flag cannot be stored.
+func (p *RedisProvider) SetFlag(ctx context.Context, name string, flag Flag) error {
+	value, err := json.Marshal(flag)
+	if err != nil {
+		return err
+	}
+	if err = p.rdb.Set(ctx, RedisKeyPrefix+name, value, 0).Err(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.cache[name] = cachedFlag{flag: flag, expiresAt: p.opts.clock.Now().Add(p.ttl)}
+	p.mu.Unlock()
+
+	return nil
+}
+
+// DeleteFlag deletes a flag from Redis, which disables it.
+//
+// Parameters:
+//   - ctx: The context of the request.
+//   - name: The name of the flag.
+//
+// Returns:
+//   - An error if the flag cannot be deleted.
+func (p *RedisProvider) DeleteFlag(ctx context.Context, name string) error {
+	if err := p.rdb.Del(ctx, RedisKeyPrefix+name).Err(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	delete(p.cache, name)
+	p.mu.Unlock()
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package featureflag
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop/logger"
+	"github.com/a-aslani/wotop/logger/loggertest"
+	"github.com/a-aslani/wotop/util"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var t0 = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestRedisProviderCachesFlags(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	clock := util.NewFrozenClock(t0)
+	p := NewRedisProvider(redis.NewClient(&redis.Options{Addr: mr.Addr()}), time.Minute, WithClock(clock))
+
+	require.NoError(t, p.SetFlag(ctx, "beta", Flag{Enabled: true}))
+	assert.JSONEq(t, `{"enabled":true}`, mustGet(t, mr, RedisKeyPrefix+"beta"))
+	assert.True(t, p.IsEnabled(ctx, "beta", nil))
+
+	// another replica disables the flag, the cached one is used until it expires
+	require.NoError(t, mr.Set(RedisKeyPrefix+"beta", `{"enabled":false}`))
+	assert.True(t, p.IsEnabled(ctx, "beta", nil))
+	clock.Advance(time.Minute)
+	assert.False(t, p.IsEnabled(ctx, "beta", nil))
+
+	assert.False(t, p.IsEnabled(ctx, "unknown", nil), "the flags not stored are disabled")
+
+	require.NoError(t, p.SetFlag(ctx, "beta", Flag{Enabled: true, Tenants: []string{"acme"}}))
+	assert.True(t, p.IsEnabled(ctx, "beta", map[string]any{AttrTenantID: "acme"}))
+	require.NoError(t, p.DeleteFlag(ctx, "beta"))
+	assert.False(t, mr.Exists(RedisKeyPrefix+"beta"))
+	assert.False(t, p.IsEnabled(ctx, "beta", map[string]any{AttrTenantID: "acme"}))
+}
+
+func TestRedisProviderFallback(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	clock := util.NewFrozenClock(t0)
+	log := &loggertest.Recorder{}
+	fallback := NewStaticProvider(map[string]Flag{"beta": {Enabled: true}, "search": {Enabled: true}})
+	p := NewRedisProvider(redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1}), time.Minute,
+		WithClock(clock), WithFallback(fallback), WithLogger(log))
+
+	require.NoError(t, p.SetFlag(ctx, "beta", Flag{Enabled: false}))
+
+	mr.SetError("LOADING Redis is loading the dataset in memory")
+	clock.Advance(time.Minute)
+
+	assert.False(t, p.IsEnabled(ctx, "beta", nil), "the expired flag read before is kept")
+	assert.True(t, p.IsEnabled(ctx, "search", nil), "the flags never read are asked to the fallback")
+	assert.Len(t, log.Messages(logger.LevelWarning), 2)
+	assert.Contains(t, log.Messages(logger.LevelWarning)[1], "cannot read search from Redis")
+
+	// the failed reads are retried after the TTL only
+	assert.True(t, p.IsEnabled(ctx, "search", nil))
+	assert.Len(t, log.Messages(logger.LevelWarning), 2)
+
+	mr.SetError("")
+	require.NoError(t, mr.Set(RedisKeyPrefix+"search", `{"enabled":false}`))
+	clock.Advance(time.Minute)
+	assert.False(t, p.IsEnabled(ctx, "search", nil), "the flag is read again once Redis is back")
+
+	// without fallback, the flags which cannot be read are disabled
+	addr := mr.Addr()
+	mr.Close()
+	assert.False(t, NewRedisProvider(redis.NewClient(&redis.Options{Addr: addr, MaxRetries: -1}), 0).IsEnabled(ctx, "search", nil))
+}
+
+func mustGet(t *testing.T, mr *miniredis.Miniredis, key string) string {
+	t.Helper()
+	value, err := mr.Get(key)
+	require.NoError(t, err)
+	return value
+}
@@ -0,0 +1,68 @@
+package featureflag
+
+import (
+	"context"
+	"maps"
+	"sync"
+)
+
+// StaticProvider is a Provider whose flags are set by the application, typically from its
+// configuration, e.g. a `mapstructure:"feature_flags"` field of type map[string]Flag. It is
+// safe for concurrent use.
+type StaticProvider struct {
+	mu    sync.RWMutex
+	flags map[string]Flag
+	opts  options
+}
+
+// Ensure StaticProvider and RedisProvider implement the Provider interface.
+var (
+	_ Provider = (*StaticProvider)(nil)
+	_ Provider = (*RedisProvider)(nil)
+)
+
+// NewStaticProvider creates a StaticProvider.
+//
+// Parameters:
+//   - flags: The flags by name.
+//   - opts: The logger of the evaluations.
+//
+// Returns:
+//   - The provider.
+func NewStaticProvider(flags map[string]Flag, opts ...Option) *StaticProvider {
+	return &StaticProvider{flags: maps.Clone(flags), opts: newOptions(opts)}
+}
+
+// Set replaces the flags, e.g. with the ones of a configuration reloaded by config.Watch.
+//
+// Parameters:
+//   - flags: The flags by name.
+func (p *StaticProvider) Set(flags map[string]Flag) {
+	flags = maps.Clone(flags)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.flags = flags
+}
+
+// Flag returns the definition of a flag.
+//
+// Parameters:
+//   - name: The name of the flag.
+//
+// Returns:
+//   - The flag.
+//   - Whether the flag is defined.
+func (p *StaticProvider) Flag(name string) (Flag, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	f, ok := p.flags[name]
+	return f, ok
+}
+
+func (p *StaticProvider) IsEnabled(ctx context.Context, flag string, attrs map[string]any) bool {
+	f, _ := p.Flag(flag)
+	enabled := f.Evaluate(flag, attrs)
+	p.opts.logEvaluation(ctx, flag, attrs, enabled, "static")
+	return enabled
+}
@@ -0,0 +1,275 @@
+package wotop
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// DefaultGracefulShutdownTimeout is the time a GracefulHTTPServer gives the in-flight requests
+// to complete.
+const DefaultGracefulShutdownTimeout = 5 * time.Second
+
+// GracefulOptions configures a GracefulHTTPServer, the zero value serves plain HTTP and drains
+// the requests within DefaultGracefulShutdownTimeout. Over TLS the server negotiates HTTP/2.
+//
+// Fields:
+//   - ShutdownTimeout: The time the in-flight requests have to complete, DefaultGracefulShutdownTimeout when zero.
+//   - PreShutdown: Called in order before the server stops accepting requests, e.g. to deregister from service discovery.
+//   - PostShutdown: Called in order once the server is stopped, e.g. to close the connections it used.
+//   - TLSCertFile: The certificate file served over TLS, with TLSKeyFile.
+//   - TLSKeyFile: The private key of TLSCertFile.
+//   - TLSConfig: The TLS configuration, the server serves TLS with its certificates when TLSCertFile is empty.
+//   - AutoCert: Serves TLS with certificates obtained from Let's Encrypt, it replaces the other TLS options.
+//   - H2C: Also serves unencrypted HTTP/2 to the clients with prior knowledge, e.g. internal gRPC-web traffic.
+type GracefulOptions struct {
+	ShutdownTimeout time.Duration
+	PreShutdown     []func(ctx context.Context) error
+	PostShutdown    []func(ctx context.Context) error
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSConfig       *tls.Config
+	AutoCert        *AutoCertOptions
+	H2C             bool
+}
+
+// AutoCertOptions configures the certificates obtained from an ACME server, Let's Encrypt by
+// default, with the TLS-ALPN-01 challenge and the HTTP-01 one when ChallengeAddress is set.
+//
+// Fields:
+//   - Hosts: The host names a certificate is requested for, the others are refused.
+//   - CacheDir: The directory keeping the certificates across restarts, DefaultAutoCertCacheDir when empty.
+//   - Email: The contact address of the ACME account, optional.
+//   - ChallengeAddress: The address answering the HTTP-01 challenges, e.g. ":80", and redirecting the rest to HTTPS.
+//   - DirectoryURL: The ACME directory, e.g. the Let's Encrypt staging one, Let's Encrypt when empty.
+type AutoCertOptions struct {
+	Hosts            []string
+	CacheDir         string
+	Email            string
+	ChallengeAddress string
+	DirectoryURL     string
+}
+
+// DefaultAutoCertCacheDir is the directory keeping the certificates of AutoCertOptions.
+const DefaultAutoCertCacheDir = "autocert"
+
+// manager creates the autocert manager of the options.
+func (o *AutoCertOptions) manager() *autocert.Manager {
+	cacheDir := o.CacheDir
+	if cacheDir == "" {
+		cacheDir = DefaultAutoCertCacheDir
+	}
+
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(cacheDir),
+		HostPolicy: autocert.HostWhitelist(o.Hosts...),
+		Email:      o.Email,
+	}
+	if o.DirectoryURL != "" {
+		m.Client = &acme.Client{DirectoryURL: o.DirectoryURL}
+	}
+	return m
+}
+
+// GracefulHTTPServer is an HTTP server shut down gracefully on SIGINT, SIGTERM or Stop. It
+// implements ControllerStarter and ControllerStopper, so a Lifecycle can run it.
+type GracefulHTTPServer struct {
+	httpServer      *http.Server
+	challengeServer *http.Server // Answers the HTTP-01 challenges of AutoCert, nil without.
+	log             Logger
+	opts            GracefulOptions
+
+	listening chan struct{} // Closed once the server listens.
+	addr      net.Addr
+
+	stopped  chan struct{} // Closed by Stop to release Start.
+	stopOnce sync.Once
+
+	shutdownOnce sync.Once
+	shutdownErr  error
+}
+
+// NewGracefulHTTPServer creates a GracefulHTTPServer.
+//
+// Parameters:
+//   - log: The logger of the server events, e.g. a logger.Logger.
+//   - handler: The HTTP handler to process incoming requests.
+//   - address: The address on which the server will listen.
+//   - opts: The shutdown timeout, hooks and TLS configuration.
+//
+// Returns:
+//   - A new GracefulHTTPServer, started with Start.
+func NewGracefulHTTPServer(log Logger, handler http.Handler, address string, opts GracefulOptions) *GracefulHTTPServer {
+	if opts.ShutdownTimeout <= 0 {
+		opts.ShutdownTimeout = DefaultGracefulShutdownTimeout
+	}
+
+	s := &GracefulHTTPServer{
+		httpServer: &http.Server{
+			Addr:      address,
+			Handler:   handler,
+			TLSConfig: opts.TLSConfig,
+		},
+		log:       log,
+		opts:      opts,
+		listening: make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+
+	if opts.H2C {
+		protocols := new(http.Protocols)
+		protocols.SetHTTP1(true)
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+		s.httpServer.Protocols = protocols
+	}
+
+	if opts.AutoCert != nil {
+		m := opts.AutoCert.manager()
+		s.httpServer.TLSConfig = m.TLSConfig()
+		if opts.AutoCert.ChallengeAddress != "" {
+			s.challengeServer = &http.Server{Addr: opts.AutoCert.ChallengeAddress, Handler: m.HTTPHandler(nil)}
+		}
+	}
+
+	return s
+}
+
+// Start serves the requests and blocks until SIGINT or SIGTERM is received, the server is then
+// shut down gracefully, or until Stop has shut it down, e.g. when it is run by a Lifecycle. The
+// process exits when the server cannot listen or is forced to shut down on a signal.
+func (s *GracefulHTTPServer) Start() {
+
+	ctx := context.Background()
+
+	l, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		s.log.Error(ctx, "listen: %s", err)
+		os.Exit(1)
+	}
+	s.addr = l.Addr()
+	close(s.listening)
+
+	go func() {
+		if err := s.serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.log.Error(ctx, "listen: %s", err)
+			os.Exit(1)
+		}
+	}()
+
+	if s.challengeServer != nil {
+		go func() {
+			if err := s.challengeServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				s.log.Error(ctx, "listen for the ACME challenges: %s", err)
+			}
+		}()
+	}
+
+	s.log.Info(ctx, "server is running at %v", s.addr)
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(quit)
+
+	select {
+	case <-quit:
+	case <-s.stopped:
+		return
+	}
+
+	s.log.Info(ctx, "Shutting down server...")
+
+	if err := s.shutdown(ctx); err != nil {
+		s.log.Error(ctx, "Server forced to shutdown: %v", err)
+		os.Exit(1)
+	}
+
+	s.log.Info(ctx, "Server stopped.")
+}
+
+// Stop shuts the server down gracefully and releases Start.
+//
+// Parameters:
+//   - ctx: The context bounding the shutdown, along with the shutdown timeout.
+//
+// Returns:
+//   - The errors of the hooks, and the error of the context if the in-flight requests did not
+//     complete in time, their connections are then closed.
+func (s *GracefulHTTPServer) Stop(ctx context.Context) error {
+	s.stopOnce.Do(func() { close(s.stopped) })
+	return s.shutdown(ctx)
+}
+
+// Addr returns the address the server listens on, e.g. to know the port of a server listening
+// on ":0". It is nil until Start listens.
+//
+// Returns:
+//   - The address of the listener.
+func (s *GracefulHTTPServer) Addr() net.Addr {
+	select {
+	case <-s.listening:
+		return s.addr
+	default:
+		return nil
+	}
+}
+
+// serve serves the requests accepted by the listener, over TLS when it is configured.
+func (s *GracefulHTTPServer) serve(l net.Listener) error {
+	if s.opts.AutoCert != nil {
+		return s.httpServer.ServeTLS(l, "", "")
+	}
+	if s.opts.TLSCertFile != "" || s.opts.TLSConfig != nil {
+		return s.httpServer.ServeTLS(l, s.opts.TLSCertFile, s.opts.TLSKeyFile)
+	}
+	return s.httpServer.Serve(l)
+}
+
+// shutdown runs the pre-shutdown hooks, drains the in-flight requests within the shutdown
+// timeout and runs the post-shutdown hooks. It runs once, later calls return its result.
+func (s *GracefulHTTPServer) shutdown(ctx context.Context) error {
+	s.shutdownOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(ctx, s.opts.ShutdownTimeout)
+		defer cancel()
+
+		var errs []error
+		for i, hook := range s.opts.PreShutdown {
+			if err := hook(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("pre-shutdown hook %d: %w", i, err))
+			}
+		}
+
+		if err := s.httpServer.Shutdown(ctx); err != nil {
+			// the requests still running are abandoned
+			_ = s.httpServer.Close()
+			errs = append(errs, err)
+		}
+		if s.challengeServer != nil {
+			if err := s.challengeServer.Shutdown(ctx); err != nil {
+				_ = s.challengeServer.Close()
+				errs = append(errs, err)
+			}
+		}
+
+		for i, hook := range s.opts.PostShutdown {
+			if err := hook(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("post-shutdown hook %d: %w", i, err))
+			}
+		}
+
+		s.shutdownErr = errors.Join(errs...)
+	})
+	return s.shutdownErr
+}
@@ -0,0 +1,270 @@
+package wotop
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// nopLogger discards the messages, the loggertest recorder cannot be imported by the tests of
+// this package as package logger depends on it.
+type nopLogger struct{}
+
+func (nopLogger) Info(context.Context, string, ...any)  {}
+func (nopLogger) Error(context.Context, string, ...any) {}
+
+// startGraceful starts the server and returns its base URL once it listens.
+func startGraceful(t *testing.T, s *GracefulHTTPServer, scheme string) string {
+	t.Helper()
+
+	go s.Start()
+	select {
+	case <-s.listening:
+	case <-time.After(time.Second):
+		t.Fatal("the server does not listen")
+	}
+	return fmt.Sprintf("%s://%s", scheme, s.Addr())
+}
+
+func TestGracefulHTTPServerHookOrder(t *testing.T) {
+	ev := &events{}
+	var url string
+	hook := func(name string) func(context.Context) error {
+		return func(context.Context) error {
+			ev.add(name)
+			return nil
+		}
+	}
+
+	s := NewGracefulHTTPServer(nopLogger{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ev.add("request")
+	}), "127.0.0.1:0", GracefulOptions{
+		PreShutdown: []func(context.Context) error{
+			hook("deregister"),
+			func(context.Context) error {
+				// the requests are still served while deregistering
+				res, err := http.Get(url)
+				if err != nil {
+					return err
+				}
+				return res.Body.Close()
+			},
+			func(context.Context) error { return errors.New("boom") },
+		},
+		PostShutdown: []func(context.Context) error{hook("close db"), hook("flush logs")},
+	})
+	url = startGraceful(t, s, "http")
+
+	stopErr := s.Stop(context.Background())
+	assert.EqualError(t, stopErr, "pre-shutdown hook 2: boom", "a failed hook does not stop the shutdown")
+	assert.Equal(t, []string{"deregister", "request", "close db", "flush logs"}, ev.get())
+
+	_, err := http.Get(url)
+	assert.Error(t, err, "the server is stopped")
+
+	assert.Equal(t, stopErr, s.Stop(context.Background()), "the hooks run once")
+	assert.Len(t, ev.get(), 4)
+}
+
+func TestGracefulHTTPServerTimeout(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+
+	s := NewGracefulHTTPServer(nopLogger{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	}), "127.0.0.1:0", GracefulOptions{ShutdownTimeout: 100 * time.Millisecond})
+	url := startGraceful(t, s, "http")
+
+	requestErr := make(chan error, 1)
+	go func() {
+		res, err := http.Get(url)
+		if err == nil {
+			res.Body.Close()
+		}
+		requestErr <- err
+	}()
+	<-entered
+
+	start := time.Now()
+	err := s.Stop(context.Background())
+	elapsed := time.Since(start)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.GreaterOrEqual(t, elapsed, 100*time.Millisecond, "the in-flight request is waited for")
+	assert.Less(t, elapsed, time.Second, "the in-flight request is abandoned after the timeout")
+	assert.Error(t, <-requestErr, "the connection of the abandoned request is closed")
+}
+
+// selfSignedCert creates a certificate for shop.test and 127.0.0.1 in PEM, it is its own CA.
+func selfSignedCert(t *testing.T) (certPEM, keyPEM []byte, pool *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "shop.test"},
+		DNSNames:              []string{"shop.test"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	pool = x509.NewCertPool()
+	pool.AppendCertsFromPEM(certPEM)
+	return certPEM, keyPEM, pool
+}
+
+// tlsClient trusts the pool and negotiates HTTP/2.
+func tlsClient(pool *x509.CertPool, serverName string) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: pool, ServerName: serverName},
+		ForceAttemptHTTP2: true,
+	}}
+}
+
+func TestGracefulHTTPServerTLSFiles(t *testing.T) {
+	certPEM, keyPEM, pool := selfSignedCert(t)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0o600))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0o600))
+
+	entered, release := make(chan struct{}), make(chan struct{})
+	s := NewGracefulHTTPServer(nopLogger{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+		_, _ = io.WriteString(w, r.Proto)
+	}), "127.0.0.1:0", GracefulOptions{TLSCertFile: certFile, TLSKeyFile: keyFile})
+	url := startGraceful(t, s, "https")
+
+	type response struct {
+		proto string
+		err   error
+	}
+	responses := make(chan response, 1)
+	go func() {
+		res, err := tlsClient(pool, "").Get(url)
+		if err != nil {
+			responses <- response{err: err}
+			return
+		}
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		responses <- response{proto: string(body), err: err}
+	}()
+	<-entered
+
+	// the in-flight request completes during the shutdown
+	stopped := make(chan error, 1)
+	go func() { stopped <- s.Stop(context.Background()) }()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	assert.NoError(t, <-stopped)
+	res := <-responses
+	require.NoError(t, res.err)
+	assert.Equal(t, "HTTP/2.0", res.proto)
+}
+
+func TestGracefulHTTPServerTLSConfig(t *testing.T) {
+	certPEM, keyPEM, pool := selfSignedCert(t)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+
+	s := NewGracefulHTTPServer(nopLogger{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.Proto)
+	}), "127.0.0.1:0", GracefulOptions{TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}}})
+	url := startGraceful(t, s, "https")
+	defer s.Stop(context.Background())
+
+	res, err := tlsClient(pool, "").Get(url)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.NotNil(t, res.TLS)
+}
+
+func TestGracefulHTTPServerAutoCert(t *testing.T) {
+	certPEM, keyPEM, pool := selfSignedCert(t)
+
+	// a certificate in the cache is served without contacting the ACME server, its file holds
+	// the key followed by the chain
+	cacheDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(cacheDir, "shop.test"), append(keyPEM, certPEM...), 0o600))
+
+	s := NewGracefulHTTPServer(nopLogger{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.Proto)
+	}), "127.0.0.1:0", GracefulOptions{AutoCert: &AutoCertOptions{
+		Hosts:        []string{"shop.test"},
+		CacheDir:     cacheDir,
+		DirectoryURL: "http://127.0.0.1:1/directory",
+	}})
+	url := startGraceful(t, s, "https")
+
+	res, err := tlsClient(pool, "shop.test").Get(url)
+	require.NoError(t, err)
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	assert.Equal(t, "HTTP/2.0", string(body))
+
+	_, err = tlsClient(pool, "other.test").Get(url)
+	assert.Error(t, err, "the hosts not whitelisted are refused")
+
+	assert.NoError(t, s.Stop(context.Background()))
+}
+
+func TestGracefulHTTPServerH2C(t *testing.T) {
+	s := NewGracefulHTTPServer(nopLogger{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.Proto)
+	}), "127.0.0.1:0", GracefulOptions{H2C: true})
+	url := startGraceful(t, s, "http")
+	defer s.Stop(context.Background())
+
+	get := func(protocols *http.Protocols) string {
+		res, err := (&http.Client{Transport: &http.Transport{Protocols: protocols}}).Get(url)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	h2c := new(http.Protocols)
+	h2c.SetUnencryptedHTTP2(true)
+	assert.Equal(t, "HTTP/2.0", get(h2c))
+
+	http1 := new(http.Protocols)
+	http1.SetHTTP1(true)
+	assert.Equal(t, "HTTP/1.1", get(http1), "HTTP/1.1 is still served")
+}
@@ -0,0 +1,218 @@
+package grpc_controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/jwt"
+	"github.com/a-aslani/wotop/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// DefaultShutdownTimeout is the time in-flight calls get to finish when the server is shut down.
+const DefaultShutdownTimeout = 5 * time.Second
+
+// Config configures the gRPC controller.
+//
+// Fields:
+//   - Address: The address the server listens on, e.g. ":9090".
+//   - Listener: An optional listener used instead of Address, e.g. a bufconn listener in tests.
+//   - MetricsAddress: The address serving /metrics, empty when the metrics are exposed elsewhere.
+//   - Token: Verifies the access token of the authorization metadata, nil disables authentication.
+//   - PublicMethods: The full method names, e.g. "/grpc.health.v1.Health/Check", callable without a token.
+//   - ShutdownTimeout: The time in-flight calls get to finish, DefaultShutdownTimeout when zero.
+//   - Registerer: The registerer of the metrics, prometheus.DefaultRegisterer when nil.
+//   - ServerOptions: Additional options of the gRPC server.
+type Config struct {
+	Address         string
+	Listener        net.Listener
+	MetricsAddress  string
+	Token           jwt.Token
+	PublicMethods   []string
+	ShutdownTimeout time.Duration
+	Registerer      prometheus.Registerer
+	ServerOptions   []grpc.ServerOption
+}
+
+// Controller serves the gRPC services of the application. It implements the semantics of
+// wotop.ControllerRegisterer: the services are registered on Server by RegisterRouter, usually
+// in the controller of the project embedding it, and Start blocks until SIGINT, SIGTERM or Stop.
+//
+// Every call goes through the interceptors in this order: recovery, trace ID, metrics and authentication.
+type Controller struct {
+	wotop.UsecaseRegisterer
+	Server   *grpc.Server
+	Health   *health.Server
+	log      logger.Logger
+	cfg      Config
+	appData  wotop.ApplicationData
+	metrics  *metrics
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+// NewController creates the gRPC controller and its server with the interceptors installed.
+//
+// Parameters:
+//   - appData: The data of the application.
+//   - log: The logger of the controller.
+//   - cfg: The configuration of the controller.
+//
+// Returns:
+//   - A new Controller.
+func NewController(appData wotop.ApplicationData, log logger.Logger, cfg Config) *Controller {
+
+	if cfg.ShutdownTimeout == 0 {
+		cfg.ShutdownTimeout = DefaultShutdownTimeout
+	}
+	if cfg.Registerer == nil {
+		cfg.Registerer = prometheus.DefaultRegisterer
+	}
+
+	c := &Controller{
+		UsecaseRegisterer: wotop.NewBaseController(),
+		Health:            health.NewServer(),
+		log:               log,
+		cfg:               cfg,
+		appData:           appData,
+		stopped:           make(chan struct{}),
+	}
+
+	unary := []grpc.UnaryServerInterceptor{
+		RecoveryUnaryInterceptor(log),
+		TraceIDUnaryInterceptor(),
+		c.metricsUnaryInterceptor(),
+	}
+	stream := []grpc.StreamServerInterceptor{
+		RecoveryStreamInterceptor(log),
+		TraceIDStreamInterceptor(),
+		c.metricsStreamInterceptor(),
+	}
+	if cfg.Token != nil {
+		unary = append(unary, AuthUnaryInterceptor(cfg.Token, cfg.PublicMethods...))
+		stream = append(stream, AuthStreamInterceptor(cfg.Token, cfg.PublicMethods...))
+	}
+
+	opts := append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}, cfg.ServerOptions...)
+
+	c.Server = grpc.NewServer(opts...)
+
+	return c
+}
+
+// RegisterRouter registers the standard gRPC health service. Controllers embedding Controller
+// register their own services on Server next to it.
+func (c *Controller) RegisterRouter() {
+	grpc_health_v1.RegisterHealthServer(c.Server, c.Health)
+}
+
+// Start listens on the configured address and serves the registered services. It runs the
+// server in a wotop.Lifecycle, which handles SIGINT and SIGTERM and stops the server gracefully
+// within the shutdown timeout, and returns once it is stopped, or when Stop is called.
+func (c *Controller) Start() {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Stop called from outside, e.g. by the Lifecycle of the application, releases Start
+	go func() {
+		select {
+		case <-c.stopped:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	err := wotop.NewLifecycle().
+		WithShutdownTimeout(c.cfg.ShutdownTimeout).
+		RegisterFunc("gRPC server", c.serve, c.Stop).
+		Run(ctx)
+	if err != nil {
+		c.log.Error(ctx, "gRPC server: %v", err)
+	}
+
+	c.log.Info(ctx, "gRPC server stopped.")
+}
+
+// serve listens on the configured address and serves the calls until the server is stopped.
+func (c *Controller) serve(ctx context.Context) error {
+
+	lis := c.cfg.Listener
+	if lis == nil {
+		var err error
+		lis, err = net.Listen("tcp", c.cfg.Address)
+		if err != nil {
+			return fmt.Errorf("listen: %w", err)
+		}
+	}
+
+	if c.cfg.MetricsAddress != "" {
+		metricsServer := c.serveMetrics()
+		defer metricsServer.Close()
+	}
+
+	c.log.Info(ctx, "gRPC server is listening on %s", lis.Addr())
+	if err := c.Server.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return fmt.Errorf("serve: %w", err)
+	}
+	return nil
+}
+
+// Stop stops the server gracefully and releases Start, it is called by wotop.Lifecycle.
+// The calls still running when the context is done are canceled.
+//
+// Parameters:
+//   - ctx: The context bounding the shutdown.
+//
+// Returns:
+//   - The error of the context when the calls did not finish in time.
+func (c *Controller) Stop(ctx context.Context) error {
+	c.stopOnce.Do(func() {
+		c.log.Info(ctx, "Shutting down gRPC server...")
+		close(c.stopped)
+	})
+
+	c.Health.Shutdown()
+
+	done := make(chan struct{})
+	go func() {
+		c.Server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		c.Server.Stop()
+		return ctx.Err()
+	}
+}
+
+// serveMetrics serves the metrics of the default gatherer on MetricsAddress.
+func (c *Controller) serveMetrics() *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	srv := &http.Server{Addr: c.cfg.MetricsAddress, Handler: mux}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			c.log.Error(context.Background(), "metrics server: %v", err)
+		}
+	}()
+
+	return srv
+}
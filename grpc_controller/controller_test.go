@@ -0,0 +1,211 @@
+package grpc_controller
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/jwt"
+	"github.com/a-aslani/wotop/logger"
+	"github.com/a-aslani/wotop/logger/loggertest"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const checkMethod = "/grpc.health.v1.Health/Check"
+
+// probe is a health service running check on every call.
+type probe struct {
+	grpc_health_v1.UnimplementedHealthServer
+	check func(ctx context.Context) error
+}
+
+func (p probe) Check(ctx context.Context, _ *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	if err := p.check(ctx); err != nil {
+		return nil, err
+	}
+	return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+}
+
+// startController starts a controller serving the probe on a bufconn listener and returns a client of it.
+func startController(t *testing.T, cfg Config, check func(ctx context.Context) error) (*Controller, grpc_health_v1.HealthClient) {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	cfg.Listener = lis
+	if cfg.Registerer == nil {
+		cfg.Registerer = prometheus.NewRegistry()
+	}
+
+	c := NewController(wotop.NewApplicationData("test"), &loggertest.Recorder{}, cfg)
+	grpc_health_v1.RegisterHealthServer(c.Server, probe{check: check})
+
+	started := make(chan struct{})
+	go func() {
+		defer close(started)
+		c.Start()
+	}()
+	t.Cleanup(func() {
+		require.NoError(t, c.Stop(context.Background()))
+		<-started
+	})
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return c, grpc_health_v1.NewHealthClient(conn)
+}
+
+func TestTraceIDInterceptor(t *testing.T) {
+	var traceIDs []string
+	_, client := startController(t, Config{}, func(ctx context.Context) error {
+		traceIDs = append(traceIDs, logger.GetTraceID(ctx))
+		return nil
+	})
+
+	var header metadata.MD
+	ctx := metadata.AppendToOutgoingContext(context.Background(), TraceIDMetadataKey, "trace-1")
+	_, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{}, grpc.Header(&header))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"trace-1"}, header.Get(TraceIDMetadataKey))
+
+	_, err = client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{}, grpc.Header(&header))
+	require.NoError(t, err)
+
+	require.Len(t, traceIDs, 2)
+	assert.Equal(t, "trace-1", traceIDs[0])
+	assert.Len(t, traceIDs[1], 16)
+	assert.Equal(t, []string{traceIDs[1]}, header.Get(TraceIDMetadataKey))
+
+	// the invalid trace IDs are replaced
+	for _, invalid := range []string{strings.Repeat("a", 65), "trace 1 level=error", "trace/1"} {
+		ctx = metadata.AppendToOutgoingContext(context.Background(), TraceIDMetadataKey, invalid)
+		_, err = client.Check(ctx, &grpc_health_v1.HealthCheckRequest{}, grpc.Header(&header))
+		require.NoError(t, err)
+
+		traceID := traceIDs[len(traceIDs)-1]
+		assert.Len(t, traceID, 16, invalid)
+		assert.Equal(t, []string{traceID}, header.Get(TraceIDMetadataKey))
+	}
+}
+
+func TestRecoveryInterceptor(t *testing.T) {
+	panicking := true
+	c, client := startController(t, Config{}, func(context.Context) error {
+		if panicking {
+			panic("boom")
+		}
+		return nil
+	})
+
+	_, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	log := c.log.(*loggertest.Recorder)
+	require.Len(t, log.Messages(logger.LevelError), 1)
+	assert.Contains(t, log.Messages(logger.LevelError)[0], "panic in "+checkMethod+": boom")
+
+	// the server keeps serving after a panic
+	panicking = false
+	_, err = client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	assert.NoError(t, err)
+}
+
+func TestAuthInterceptor(t *testing.T) {
+	ctx := context.Background()
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+
+	token, err := jwt.NewHS256JWT(ctx, "secret", jwt.NewRedisRepository(rdb), time.Hour, time.Minute)
+	require.NoError(t, err)
+
+	accessToken, _, _, _, err := token.GenerateToken(ctx, "user-1", "admin", "user-1", "acme")
+	require.NoError(t, err)
+
+	var identity wotop.Identity
+	_, client := startController(t, Config{Token: token}, func(ctx context.Context) error {
+		identity, _ = wotop.IdentityFromContext(ctx)
+		return nil
+	})
+
+	_, err = client.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.Check(metadata.AppendToOutgoingContext(ctx, AuthorizationMetadataKey, "Bearer invalid"), &grpc_health_v1.HealthCheckRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.Check(metadata.AppendToOutgoingContext(ctx, AuthorizationMetadataKey, "Bearer "+accessToken), &grpc_health_v1.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, wotop.Identity{ID: "user-1", Role: "admin", Tenant: "acme"}, identity)
+}
+
+func TestAuthInterceptorPublicMethods(t *testing.T) {
+	_, client := startController(t, Config{Token: nopToken{}, PublicMethods: []string{checkMethod}}, func(context.Context) error {
+		return nil
+	})
+
+	_, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	assert.NoError(t, err)
+}
+
+// nopToken is a Token the public methods must never use.
+type nopToken struct{ jwt.Token }
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	fail := false
+	c, client := startController(t, Config{Registerer: reg}, func(context.Context) error {
+		if fail {
+			return status.Error(codes.NotFound, "not found")
+		}
+		return nil
+	})
+	c.RegisterMetrics("product")
+
+	_, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	require.NoError(t, err)
+	fail = true
+	_, err = client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	require.Error(t, err)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.metrics.handled.WithLabelValues("product", checkMethod, "OK")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.metrics.handled.WithLabelValues("product", checkMethod, "NotFound")))
+	assert.Equal(t, 1, testutil.CollectAndCount(c.metrics.duration))
+}
+
+func TestStopReleasesStart(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	c := NewController(wotop.NewApplicationData("test"), &loggertest.Recorder{}, Config{Listener: lis, Registerer: prometheus.NewRegistry()})
+	c.RegisterRouter()
+
+	started := make(chan struct{})
+	go func() {
+		defer close(started)
+		c.Start()
+	}()
+
+	require.NoError(t, c.Stop(context.Background()))
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("Start did not return after Stop")
+	}
+}
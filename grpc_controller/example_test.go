@@ -0,0 +1,44 @@
+package grpc_controller_test
+
+import (
+	"context"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/grpc_controller"
+	"github.com/a-aslani/wotop/logger"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// controller is the gRPC controller of a project, it registers the services of the project
+// next to the health service of the embedded controller.
+type controller struct {
+	*grpc_controller.Controller
+}
+
+func (c controller) RegisterRouter() {
+	c.Controller.RegisterRouter()
+
+	// register the generated services of the project here, e.g.
+	// productpb.RegisterProductServiceServer(c.Server, newProductServer(c))
+	c.Health.SetServingStatus("product", grpc_health_v1.HealthCheckResponse_SERVING)
+}
+
+func ExampleController() {
+	var log logger.Logger // e.g. logger.NewGrayLog(cfg.GraylogAddr, cfg.Stage)
+
+	var primaryDriver wotop.ControllerRegisterer = controller{grpc_controller.NewController(
+		wotop.NewApplicationData("product"),
+		log,
+		grpc_controller.Config{
+			Address:       ":9090",
+			PublicMethods: []string{"/grpc.health.v1.Health/Check"},
+		},
+	)}
+
+	primaryDriver.RegisterMetrics("product")
+	primaryDriver.RegisterRouter()
+
+	_ = wotop.NewLifecycle().
+		Register(primaryDriver).
+		Run(context.Background())
+}
@@ -0,0 +1,189 @@
+package grpc_controller
+
+import (
+	"context"
+	"regexp"
+	"runtime/debug"
+	"slices"
+	"strings"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/jwt"
+	"github.com/a-aslani/wotop/logger"
+	"github.com/a-aslani/wotop/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// TraceIDMetadataKey is the metadata key carrying the trace ID of a call, it is read from the
+	// incoming metadata and sent back in the header of the response.
+	TraceIDMetadataKey = "x-trace-id"
+
+	// AuthorizationMetadataKey is the metadata key carrying the access token as "Bearer <token>".
+	AuthorizationMetadataKey = "authorization"
+)
+
+// wrappedStream is a server stream with another context.
+type wrappedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (w *wrappedStream) Context() context.Context {
+	return w.ctx
+}
+
+// RecoveryUnaryInterceptor recovers the panics of the handlers, logs them with their stack and
+// returns codes.Internal to the client instead of crashing the server.
+//
+// Parameters:
+//   - log: The logger of the panics.
+//
+// Returns:
+//   - The unary interceptor.
+func RecoveryUnaryInterceptor(log logger.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (res any, err error) {
+		defer func() {
+			if v := recover(); v != nil {
+				err = recovered(ctx, log, info.FullMethod, v)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// RecoveryStreamInterceptor is the streaming counterpart of RecoveryUnaryInterceptor.
+//
+// Parameters:
+//   - log: The logger of the panics.
+//
+// Returns:
+//   - The stream interceptor.
+func RecoveryStreamInterceptor(log logger.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if v := recover(); v != nil {
+				err = recovered(ss.Context(), log, info.FullMethod, v)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+// recovered logs the recovered value v and returns the status sent to the client.
+func recovered(ctx context.Context, log logger.Logger, method string, v any) error {
+	log.Error(ctx, "panic in %s: %v\n%s", method, v, debug.Stack())
+	return status.Error(codes.Internal, "internal error")
+}
+
+// TraceIDUnaryInterceptor stores the trace ID of the incoming metadata in the context of the
+// call with logger.SetTraceID, or a new one when the client did not send any or sent one longer
+// than 64 characters or with others than letters, digits, '.', '_' and '-', and returns it in
+// the header of the response.
+//
+// Returns:
+//   - The unary interceptor.
+func TraceIDUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(withTraceID(ctx), req)
+	}
+}
+
+// TraceIDStreamInterceptor is the streaming counterpart of TraceIDUnaryInterceptor.
+//
+// Returns:
+//   - The stream interceptor.
+func TraceIDStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &wrappedStream{ServerStream: ss, ctx: withTraceID(ss.Context())})
+	}
+}
+
+// traceIDPattern matches the trace IDs accepted from the clients, so a client cannot inject
+// control characters or kilobytes of data in the logs.
+var traceIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// withTraceID returns the context of the call with its trace ID.
+func withTraceID(ctx context.Context) context.Context {
+	traceID := firstMetadata(ctx, TraceIDMetadataKey)
+	if !traceIDPattern.MatchString(traceID) {
+		traceID = util.GenerateID(16)
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(TraceIDMetadataKey, traceID))
+	return logger.SetTraceID(ctx, traceID)
+}
+
+// AuthUnaryInterceptor verifies the access token of the authorization metadata and stores the
+// identity of the caller in the context of the call, see wotop.IdentityFromContext. Calls
+// without a valid token fail with codes.Unauthenticated, except the public methods.
+//
+// Parameters:
+//   - token: The Token verifying the access tokens.
+//   - publicMethods: The full method names callable without a token.
+//
+// Returns:
+//   - The unary interceptor.
+func AuthUnaryInterceptor(token jwt.Token, publicMethods ...string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if slices.Contains(publicMethods, info.FullMethod) {
+			return handler(ctx, req)
+		}
+		ctx, err := authenticate(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// AuthStreamInterceptor is the streaming counterpart of AuthUnaryInterceptor.
+//
+// Parameters:
+//   - token: The Token verifying the access tokens.
+//   - publicMethods: The full method names callable without a token.
+//
+// Returns:
+//   - The stream interceptor.
+func AuthStreamInterceptor(token jwt.Token, publicMethods ...string) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if slices.Contains(publicMethods, info.FullMethod) {
+			return handler(srv, ss)
+		}
+		ctx, err := authenticate(ss.Context(), token)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &wrappedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authenticate verifies the access token of the call and returns its context with the identity of the caller.
+func authenticate(ctx context.Context, token jwt.Token) (context.Context, error) {
+	scheme, accessToken, ok := strings.Cut(firstMetadata(ctx, AuthorizationMetadataKey), " ")
+	if !ok || scheme != "Bearer" || accessToken == "" {
+		return nil, status.Error(codes.Unauthenticated, jwt.ErrUnauthorized.Error())
+	}
+
+	_, claims, err := token.VerifyToken(accessToken)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	return wotop.WithIdentity(ctx, wotop.Identity{
+		ID:     claims.ID,
+		Role:   claims.Role,
+		Tenant: claims.Tenant,
+	}), nil
+}
+
+// firstMetadata returns the first value of the incoming metadata key, or "".
+func firstMetadata(ctx context.Context, key string) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
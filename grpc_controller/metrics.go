@@ -0,0 +1,87 @@
+package grpc_controller
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// metrics are the Prometheus collectors of the calls handled by the controller.
+type metrics struct {
+	service  string
+	handled  *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// RegisterMetrics sets up the Prometheus metrics of the gRPC server, the gRPC counterpart of
+// the request counter and latency histogram of the HTTP controller. Every call is counted by
+// method and status code in grpc_server_handled_total and timed in grpc_server_handling_seconds.
+//
+// Parameters:
+//   - serviceName: The name of the service for which metrics are being registered.
+func (c *Controller) RegisterMetrics(serviceName string) {
+
+	c.metrics = &metrics{
+		service: serviceName,
+		handled: registerCollector(c.cfg.Registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_handled_total",
+			Help: "Number of gRPC calls handled by the server, by status code.",
+		}, []string{"service", "method", "code"})),
+		duration: registerCollector(c.cfg.Registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "grpc_server_handling_seconds",
+			Help:    "Duration of the gRPC calls handled by the server.",
+			Buckets: []float64{0.1, 0.5, 1.0},
+		}, []string{"service", "method"})),
+	}
+}
+
+// observe records a call of the method ending with err.
+func (m *metrics) observe(method string, start time.Time, err error) {
+	m.handled.WithLabelValues(m.service, method, status.Code(err).String()).Inc()
+	m.duration.WithLabelValues(m.service, method).Observe(time.Since(start).Seconds())
+}
+
+// metricsUnaryInterceptor records the unary calls once RegisterMetrics has been called.
+func (c *Controller) metricsUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if c.metrics == nil {
+			return handler(ctx, req)
+		}
+		start := time.Now()
+		res, err := handler(ctx, req)
+		c.metrics.observe(info.FullMethod, start, err)
+		return res, err
+	}
+}
+
+// metricsStreamInterceptor records the streaming calls once RegisterMetrics has been called.
+func (c *Controller) metricsStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if c.metrics == nil {
+			return handler(srv, ss)
+		}
+		start := time.Now()
+		err := handler(srv, ss)
+		c.metrics.observe(info.FullMethod, start, err)
+		return err
+	}
+}
+
+// registerCollector registers col, or returns the collector registered before with the same
+// descriptors, so several controllers can share the metrics.
+func registerCollector[C prometheus.Collector](reg prometheus.Registerer, col C) C {
+	if err := reg.Register(col); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(C); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return col
+}
@@ -0,0 +1,106 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/a-aslani/wotop/util"
+	"github.com/redis/go-redis/v9"
+)
+
+// Postgres checks the database opened with postgres_db.New by pinging it.
+//
+// Parameters:
+//   - db: The database connection pool.
+//
+// Returns:
+//   - The Check.
+func Postgres(db *sql.DB) Check {
+	return db.PingContext
+}
+
+// Redis checks the Redis server by sending a PING.
+//
+// Parameters:
+//   - client: The Redis client, e.g. *redis.Client.
+//
+// Returns:
+//   - The Check.
+func Redis(client redis.UniversalClient) Check {
+	return func(ctx context.Context) error {
+		return client.Ping(ctx).Err()
+	}
+}
+
+// Connection is a connection reporting whether it is closed, e.g. *pubsub.Connection or *pubsub.Event.
+type Connection interface {
+	IsClosed() bool
+}
+
+// PubSub checks that the RabbitMQ connection is open. The connection reconnects on its own,
+// so the check fails only while it is down.
+//
+// Parameters:
+//   - conn: The pubsub connection or event.
+//
+// Returns:
+//   - The Check.
+func PubSub(conn Connection) Check {
+	return func(context.Context) error {
+		if conn.IsClosed() {
+			return ErrConnectionClosed
+		}
+		return nil
+	}
+}
+
+// QueueStatser reports the stats of a queue, e.g. *pubsub.Event.
+type QueueStatser interface {
+	QueueStats(ctx context.Context) (messages, consumers int, err error)
+}
+
+// QueueDepth checks that a queue does not back up: the check fails once the queue has held
+// more than threshold messages for longer than sustained, so a burst does not fail readiness.
+//
+// Parameters:
+//   - queue: The queue, e.g. the pubsub event of the consumer.
+//   - threshold: The number of messages above which the queue is backing up.
+//   - sustained: How long the queue may stay above the threshold.
+//
+// Returns:
+//   - The Check.
+func QueueDepth(queue QueueStatser, threshold int, sustained time.Duration) Check {
+	return queueDepth(queue, threshold, sustained, util.SystemClock)
+}
+
+// queueDepth is QueueDepth reading the time from the clock.
+func queueDepth(queue QueueStatser, threshold int, sustained time.Duration, clock util.Clock) Check {
+	var mu sync.Mutex
+	var above time.Time // when the queue went above the threshold, zero while it is below
+
+	return func(ctx context.Context) error {
+		messages, _, err := queue.QueueStats(ctx)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if messages <= threshold {
+			above = time.Time{}
+			return nil
+		}
+
+		now := clock.Now()
+		if above.IsZero() {
+			above = now
+		}
+		if backlog := now.Sub(above); backlog >= sustained {
+			return ErrQueueBacklog.Var(messages, threshold, backlog)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,17 @@
+package health
+
+import "github.com/a-aslani/wotop/model/apperror"
+
+const (
+	ErrNotReady         apperror.ErrorType = "ER0401 not ready, failing checks: %s"
+	ErrConnectionClosed apperror.ErrorType = "ER0402 the connection is closed"
+	ErrQueueBacklog     apperror.ErrorType = "ER0403 the queue has %d messages, above %d for %s"
+)
+
+func init() {
+	apperror.Register("health",
+		apperror.Entry{Err: ErrNotReady, Description: "A dependency of the application is not available."},
+		apperror.Entry{Err: ErrConnectionClosed, Description: "The connection to the message broker is closed."},
+		apperror.Entry{Err: ErrQueueBacklog, Description: "A queue is backing up, its consumers do not keep up."},
+	)
+}
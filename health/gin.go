@@ -0,0 +1,66 @@
+package health
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/logger"
+	"github.com/a-aslani/wotop/model/payload"
+	"github.com/gin-gonic/gin"
+)
+
+// Liveness returns the handler of /healthz. It always responds 200 with the application data,
+// answering is enough to prove the process is up.
+//
+// Parameters:
+//   - appData: The data of the application.
+//
+// Returns:
+//   - The Gin handler.
+func Liveness(appData wotop.ApplicationData) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		traceID := logger.GetTraceID(c.Request.Context())
+		c.JSON(http.StatusOK, payload.NewSuccessResponse(appData, traceID))
+	}
+}
+
+// Readiness returns the handler of /readyz. It runs the checks of the registry and responds
+// 200 when all of them pass, or 503 with the names of the failing checks otherwise. The Report
+// is the data of the response in both cases.
+//
+// Parameters:
+//   - registry: The registry of the checks.
+//
+// Returns:
+//   - The Gin handler.
+func Readiness(registry *Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		traceID := logger.GetTraceID(ctx)
+
+		report := registry.Check(ctx)
+		if report.Ready() {
+			c.JSON(http.StatusOK, payload.NewSuccessResponse(report, traceID))
+			return
+		}
+
+		res := payload.NewErrorResponse(ErrNotReady.Var(strings.Join(report.Failing, ", ")), traceID).(payload.Response)
+		res.Data = report
+		c.JSON(http.StatusServiceUnavailable, res)
+	}
+}
+
+// RegisterRoutes registers GET /healthz and GET /readyz on the router.
+//
+// Parameters:
+//   - router: The router or group, e.g. the group of the proxy path.
+//   - appData: The data of the application returned by /healthz.
+//
+// Returns:
+//   - The Registry, so calls can be chained.
+func (r *Registry) RegisterRoutes(router gin.IRoutes, appData wotop.ApplicationData) *Registry {
+	router.GET("/healthz", Liveness(appData))
+	router.GET("/readyz", Readiness(r))
+	return r
+}
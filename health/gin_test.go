@@ -0,0 +1,90 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// response mirrors payload.Response with a typed report.
+type response struct {
+	Success      bool   `json:"success"`
+	ErrorCode    string `json:"error_code"`
+	ErrorMessage string `json:"error_message"`
+	Data         Report `json:"data"`
+	TraceID      string `json:"trace_id"`
+}
+
+func newRouter(registry *Registry) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(logger.SetTraceID(c.Request.Context(), "trace-1"))
+	})
+	registry.RegisterRoutes(router, wotop.ApplicationData{AppName: "product"})
+	return router
+}
+
+func serve(router *gin.Engine, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func TestReadinessFailing(t *testing.T) {
+	router := newRouter(NewRegistry().
+		Register("redis", func(context.Context) error { return nil }).
+		Register("postgres", func(context.Context) error { return errors.New("connection refused") }).
+		Register("pubsub", PubSub(connection{closed: true})))
+
+	rec := serve(router, "/readyz")
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	var res response
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, response{
+		Success:      false,
+		ErrorCode:    "ER0401",
+		ErrorMessage: "not ready, failing checks: postgres, pubsub",
+		Data: Report{
+			Status: StatusDown,
+			Checks: []Result{
+				{Name: "postgres", Status: StatusDown, Error: "connection refused"},
+				{Name: "pubsub", Status: StatusDown, Error: "the connection is closed"},
+				{Name: "redis", Status: StatusUp},
+			},
+			Failing: []string{"postgres", "pubsub"},
+		},
+		TraceID: "trace-1",
+	}, res)
+}
+
+func TestReadinessPassing(t *testing.T) {
+	router := newRouter(NewRegistry().Register("redis", func(context.Context) error { return nil }))
+
+	rec := serve(router, "/readyz")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var res response
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.True(t, res.Success)
+	assert.Equal(t, Report{Status: StatusUp, Checks: []Result{{Name: "redis", Status: StatusUp}}}, res.Data)
+	assert.Equal(t, "trace-1", res.TraceID)
+}
+
+func TestLivenessIgnoresChecks(t *testing.T) {
+	router := newRouter(NewRegistry().Register("postgres", func(context.Context) error { return errors.New("down") }))
+
+	rec := serve(router, "/healthz")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"success":true,"error_code":"","error_message":"","data":{"app_name":"product","app_instance_id":"","start_time":""},"trace_id":"trace-1"}`, rec.Body.String())
+}
@@ -0,0 +1,179 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultCheckTimeout is the time a single check may take before it is reported as failing.
+const DefaultCheckTimeout = 2 * time.Second
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Check reports whether a dependency of the application, e.g. the database, is usable.
+type Check func(ctx context.Context) error
+
+// namedCheck is a check registered under a name.
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Result is the outcome of a single check.
+//
+// Fields:
+//   - Name: The name the check is registered with.
+//   - Status: StatusUp or StatusDown.
+//   - Error: The error returned by the check, empty when it passed.
+type Result struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Report is the outcome of all the checks of a Registry.
+//
+// Fields:
+//   - Status: StatusUp when every check passed, StatusDown otherwise.
+//   - Checks: The result of every check, sorted by name.
+//   - Failing: The names of the failing checks, sorted.
+type Report struct {
+	Status  string   `json:"status"`
+	Checks  []Result `json:"checks"`
+	Failing []string `json:"failing,omitempty"`
+}
+
+// Ready reports whether every check passed.
+func (r Report) Ready() bool {
+	return len(r.Failing) == 0
+}
+
+// Registry keeps the named checks the components of the application register, they are
+// run to decide whether the application is ready to serve requests.
+//
+// Fields:
+//   - mu: Guards checks.
+//   - checks: The registered checks.
+//   - timeout: The time a single check may take.
+type Registry struct {
+	mu      sync.RWMutex
+	checks  []namedCheck
+	timeout time.Duration
+}
+
+// NewRegistry creates an empty Registry running every check within DefaultCheckTimeout.
+//
+// Returns:
+//   - A new Registry.
+func NewRegistry() *Registry {
+	return &Registry{timeout: DefaultCheckTimeout}
+}
+
+// WithCheckTimeout sets the time a single check may take.
+//
+// Parameters:
+//   - timeout: The timeout of every check.
+//
+// Returns:
+//   - The Registry, so calls can be chained.
+func (r *Registry) WithCheckTimeout(timeout time.Duration) *Registry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.timeout = timeout
+	return r
+}
+
+// Register adds a named check to the registry. It panics when a check with the same name is
+// registered already, since the report could not tell them apart.
+//
+// Parameters:
+//   - name: The name of the check, e.g. "postgres".
+//   - check: The check to run.
+//
+// Returns:
+//   - The Registry, so calls can be chained.
+func (r *Registry) Register(name string, check Check) *Registry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.checks {
+		if c.name == name {
+			panic(fmt.Sprintf("health: check %q is already registered", name))
+		}
+	}
+	r.checks = append(r.checks, namedCheck{name: name, check: check})
+	return r
+}
+
+// Check runs every registered check concurrently, each one bounded by the check timeout.
+//
+// Parameters:
+//   - ctx: The context of the request, the checks are canceled with it.
+//
+// Returns:
+//   - The Report of the checks.
+func (r *Registry) Check(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := append([]namedCheck(nil), r.checks...)
+	timeout := r.timeout
+	r.mu.RUnlock()
+
+	results := make([]Result, len(checks))
+
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = runCheck(ctx, c, timeout)
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+
+	report := Report{Status: StatusUp, Checks: results}
+	for _, res := range results {
+		if res.Status == StatusDown {
+			report.Status = StatusDown
+			report.Failing = append(report.Failing, res.Name)
+		}
+	}
+
+	return report
+}
+
+// runCheck runs a single check, a check that does not return within the timeout or panics is
+// reported as failing.
+func runCheck(ctx context.Context, c namedCheck, timeout time.Duration) Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// the check runs on its own so a check ignoring its context cannot block the report
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if v := recover(); v != nil {
+				done <- fmt.Errorf("panic: %v", v)
+			}
+		}()
+		done <- c.check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %s: %w", timeout, ctx.Err())
+	}
+
+	if err != nil {
+		return Result{Name: c.name, Status: StatusDown, Error: err.Error()}
+	}
+	return Result{Name: c.name, Status: StatusUp}
+}
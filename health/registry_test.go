@@ -0,0 +1,143 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/a-aslani/wotop/model/apperror"
+	"github.com/a-aslani/wotop/util"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryCheck(t *testing.T) {
+	registry := NewRegistry().
+		WithCheckTimeout(50*time.Millisecond).
+		Register("redis", func(context.Context) error { return nil }).
+		Register("postgres", func(context.Context) error { return errors.New("connection refused") }).
+		Register("pubsub", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}).
+		Register("broken", func(context.Context) error { panic("boom") })
+
+	report := registry.Check(context.Background())
+
+	assert.False(t, report.Ready())
+	assert.Equal(t, StatusDown, report.Status)
+	assert.Equal(t, []string{"broken", "postgres", "pubsub"}, report.Failing)
+	require.Len(t, report.Checks, 4)
+	assert.Equal(t, Result{Name: "broken", Status: StatusDown, Error: "panic: boom"}, report.Checks[0])
+	assert.Equal(t, Result{Name: "postgres", Status: StatusDown, Error: "connection refused"}, report.Checks[1])
+	assert.Equal(t, "pubsub", report.Checks[2].Name)
+	assert.Contains(t, report.Checks[2].Error, "timed out after 50ms")
+	assert.Equal(t, Result{Name: "redis", Status: StatusUp}, report.Checks[3])
+}
+
+func TestRegistryCheckDoesNotWaitForStuckChecks(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	registry := NewRegistry().WithCheckTimeout(10*time.Millisecond).
+		Register("stuck", func(context.Context) error {
+			<-block // ignores its context
+			return nil
+		})
+
+	start := time.Now()
+	report := registry.Check(context.Background())
+
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, []string{"stuck"}, report.Failing)
+}
+
+func TestRegistryRegisterPanicsOnDuplicate(t *testing.T) {
+	registry := NewRegistry().Register("postgres", func(context.Context) error { return nil })
+
+	assert.PanicsWithValue(t, `health: check "postgres" is already registered`, func() {
+		registry.Register("postgres", func(context.Context) error { return nil })
+	})
+}
+
+func TestPostgres(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectPing()
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+
+	check := Postgres(db)
+	assert.NoError(t, check(context.Background()))
+	assert.EqualError(t, check(context.Background()), "connection refused")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRedis(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	check := Redis(client)
+	assert.NoError(t, check(context.Background()))
+
+	server.Close()
+	assert.Error(t, check(context.Background()))
+}
+
+// connection is a fake pubsub connection.
+type connection struct{ closed bool }
+
+func (c connection) IsClosed() bool { return c.closed }
+
+func TestPubSub(t *testing.T) {
+	assert.NoError(t, PubSub(connection{})(context.Background()))
+	assert.ErrorIs(t, PubSub(connection{closed: true})(context.Background()), ErrConnectionClosed)
+}
+
+// queueStats is a QueueStatser returning synthetic stats.
+type queueStats struct {
+	messages int
+	err      error
+}
+
+func (q *queueStats) QueueStats(context.Context) (int, int, error) {
+	return q.messages, 1, q.err
+}
+
+func TestQueueDepth(t *testing.T) {
+	clock := util.NewFrozenClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	queue := &queueStats{messages: 10}
+	check := queueDepth(queue, 100, time.Minute, clock)
+	ctx := context.Background()
+
+	assert.NoError(t, check(ctx))
+
+	// a burst above the threshold does not fail the check
+	queue.messages = 500
+	assert.NoError(t, check(ctx))
+	clock.Advance(30 * time.Second)
+	assert.NoError(t, check(ctx))
+
+	clock.Advance(30 * time.Second)
+	err := check(ctx)
+	var et apperror.ErrorType
+	require.ErrorAs(t, err, &et)
+	assert.Equal(t, ErrQueueBacklog.Code(), et.Code())
+	assert.Contains(t, err.Error(), "500 messages")
+
+	// draining the queue resets the period
+	queue.messages = 50
+	assert.NoError(t, check(ctx))
+	queue.messages = 500
+	clock.Advance(time.Hour)
+	assert.NoError(t, check(ctx))
+
+	queue.err = errors.New("channel closed")
+	assert.EqualError(t, check(ctx), "channel closed")
+}
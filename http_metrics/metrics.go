@@ -0,0 +1,178 @@
+// Package http_metrics records the Prometheus metrics of the HTTP requests handled by a gin
+// engine, the HTTP counterpart of the metrics of grpc_controller.
+package http_metrics
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// UnmatchedRoute is the route label of the requests matching no route, e.g. the 404s, so the
+// raw paths of the scanners do not become series.
+const UnmatchedRoute = "unmatched"
+
+// Options configures the metrics middleware.
+//
+// Fields:
+//   - Registerer: The registerer of the metrics, prometheus.DefaultRegisterer when nil.
+//   - Buckets: The buckets of the duration histogram, in seconds, prometheus.DefBuckets when empty.
+//   - SkipPaths: The paths not recorded, e.g. "/metrics".
+//   - Legacy: Whether the per-service http_request_counter_<service> counter and
+//     http_request_latency_<service> histogram are recorded as well.
+//     Deprecated: only for the dashboards not moved to the labelled metrics yet.
+type Options struct {
+	Registerer prometheus.Registerer
+	Buckets    []float64
+	SkipPaths  []string
+	Legacy     bool
+}
+
+// metrics are the Prometheus collectors of the requests.
+type metrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+
+	legacyRequests prometheus.Counter
+	legacyDuration prometheus.Histogram
+}
+
+// Middleware records every request in http_requests_total and http_request_duration_seconds,
+// labelled by service, method, route and status class, e.g. "GET", "/v1/orders/:id" and "2xx".
+// The route is the template of the matched route, not the path, so the IDs in the paths do not
+// multiply the series. The metrics are registered once per registerer and shared by the
+// middlewares of the services. It must be registered with gin.Engine.Use to record the
+// requests matching no route.
+//
+// Parameters:
+//   - serviceName: The name of the service, the "service" label.
+//   - opts: The registerer, the buckets and the skipped paths.
+//
+// Returns:
+//   - A gin.HandlerFunc to register with gin.Engine.Use.
+func Middleware(serviceName string, opts Options) gin.HandlerFunc {
+
+	reg := opts.Registerer
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	buckets := opts.Buckets
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+
+	m := &metrics{
+		requests: registerCollector(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Number of HTTP requests handled, by route and status class.",
+		}, []string{"service", "method", "route", "status"})),
+		duration: registerCollector(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Duration of the HTTP requests handled, by route and status class.",
+			Buckets: buckets,
+		}, []string{"service", "method", "route", "status"})),
+	}
+
+	if opts.Legacy {
+		m.legacyRequests = registerCollector(reg, prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "http_request_counter",
+			Name:      serviceName,
+			Help:      fmt.Sprintf("Count of request to the %s service", serviceName),
+		}))
+		m.legacyDuration = registerCollector(reg, prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "http_request_latency",
+			Name:      serviceName,
+			Buckets:   []float64{0.1, 0.5, 1.0},
+		}))
+	}
+
+	skip := make(map[string]struct{}, len(opts.SkipPaths))
+	for _, p := range opts.SkipPaths {
+		skip[p] = struct{}{}
+	}
+
+	return func(c *gin.Context) {
+
+		if _, ok := skip[c.Request.URL.Path]; ok {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+
+		c.Next()
+
+		elapsed := time.Since(start).Seconds()
+
+		route := c.FullPath()
+		if route == "" {
+			route = UnmatchedRoute
+		}
+		labels := []string{serviceName, method(c.Request.Method), route, statusClass(c.Writer.Status())}
+
+		m.requests.WithLabelValues(labels...).Inc()
+		m.duration.WithLabelValues(labels...).Observe(elapsed)
+
+		if m.legacyRequests != nil {
+			m.legacyRequests.Inc()
+			m.legacyDuration.Observe(elapsed)
+		}
+	}
+}
+
+// Handler returns the handler serving the metrics of a gatherer to Prometheus, e.g. on GET /metrics.
+//
+// Parameters:
+//   - gatherer: The gatherer of the metrics, prometheus.DefaultGatherer when nil.
+//
+// Returns:
+//   - A gin.HandlerFunc serving the metrics.
+func Handler(gatherer prometheus.Gatherer) gin.HandlerFunc {
+	if gatherer == nil {
+		gatherer = prometheus.DefaultGatherer
+	}
+	h := promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{})
+	return func(c *gin.Context) {
+		h.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// method returns the method label of a request, "other" for the non-standard methods, which
+// the clients choose freely.
+func method(m string) string {
+	switch m {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return m
+	}
+	return "other"
+}
+
+// statusClass returns the class of a status, e.g. "2xx" for 204.
+func statusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "unknown"
+	}
+	return strconv.Itoa(status/100) + "xx"
+}
+
+// registerCollector registers col, or returns the collector registered before with the same
+// descriptors, so several services can share the metrics.
+func registerCollector[C prometheus.Collector](reg prometheus.Registerer, col C) C {
+	if err := reg.Register(col); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(C); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return col
+}
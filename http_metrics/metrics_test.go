@@ -0,0 +1,101 @@
+package http_metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRouter(reg *prometheus.Registry, opts Options) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	opts.Registerer = reg
+
+	r := gin.New()
+	r.Use(Middleware("product", opts))
+	r.GET("/metrics", Handler(reg))
+	r.GET("/v1/products/:id", func(c *gin.Context) {
+		if c.Param("id") == "missing" {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.String(http.StatusOK, c.Param("id"))
+	})
+	return r
+}
+
+func serve(r http.Handler, method, path string) int {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w.Code
+}
+
+func TestMiddlewareLabelsRouteTemplate(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	r := newRouter(reg, Options{})
+
+	serve(r, http.MethodGet, "/v1/products/1")
+	serve(r, http.MethodGet, "/v1/products/2")
+	serve(r, http.MethodGet, "/v1/products/missing")
+
+	// the requests of the route share the series of its template
+	expected := `
+# HELP http_requests_total Number of HTTP requests handled, by route and status class.
+# TYPE http_requests_total counter
+http_requests_total{method="GET",route="/v1/products/:id",service="product",status="2xx"} 2
+http_requests_total{method="GET",route="/v1/products/:id",service="product",status="4xx"} 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "http_requests_total"))
+	assert.Equal(t, 2, testutil.CollectAndCount(reg, "http_request_duration_seconds"))
+}
+
+func TestMiddlewareLabelsUnmatchedRoute(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	r := newRouter(reg, Options{})
+
+	require.Equal(t, http.StatusNotFound, serve(r, http.MethodGet, "/v1/orders/42"))
+	serve(r, "PROPFIND", "/v1/orders/43")
+
+	expected := `
+# HELP http_requests_total Number of HTTP requests handled, by route and status class.
+# TYPE http_requests_total counter
+http_requests_total{method="GET",route="unmatched",service="product",status="4xx"} 1
+http_requests_total{method="other",route="unmatched",service="product",status="4xx"} 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "http_requests_total"))
+}
+
+func TestMiddlewareSkipsPathsAndKeepsLegacyMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	r := newRouter(reg, Options{SkipPaths: []string{"/metrics"}, Legacy: true})
+
+	serve(r, http.MethodGet, "/v1/products/1")
+	serve(r, http.MethodGet, "/metrics")
+
+	// a second middleware of the registry shares the collectors
+	other := newRouter(reg, Options{SkipPaths: []string{"/metrics"}, Legacy: true})
+	serve(other, http.MethodGet, "/v1/products/2")
+
+	expected := `
+# HELP http_request_counter_product Count of request to the product service
+# TYPE http_request_counter_product counter
+http_request_counter_product 2
+# HELP http_requests_total Number of HTTP requests handled, by route and status class.
+# TYPE http_requests_total counter
+http_requests_total{method="GET",route="/v1/products/:id",service="product",status="2xx"} 2
+`
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "http_requests_total", "http_request_counter_product"))
+	assert.Equal(t, 1, testutil.CollectAndCount(reg, "http_request_latency_product"))
+}
+
+func TestStatusClass(t *testing.T) {
+	assert.Equal(t, "2xx", statusClass(http.StatusNoContent))
+	assert.Equal(t, "5xx", statusClass(http.StatusServiceUnavailable))
+	assert.Equal(t, "unknown", statusClass(0))
+}
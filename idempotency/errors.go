@@ -0,0 +1,37 @@
+package idempotency
+
+import (
+	"net/http"
+
+	"github.com/a-aslani/wotop/model/apperror"
+)
+
+const (
+	// ErrKeyReused indicates an idempotency key sent again with another request.
+	ErrKeyReused apperror.ErrorType = "ER0951 the idempotency key %s is already used by another request"
+	// ErrRequestInProgress indicates a retry sent while the first request with the key still runs.
+	ErrRequestInProgress apperror.ErrorType = "ER0952 the request with the idempotency key %s is still in progress"
+	// ErrInvalidKey indicates an idempotency key longer than MaxKeyLength.
+	ErrInvalidKey apperror.ErrorType = "ER0953 %s must be %d characters or fewer"
+	// ErrRequestTooLarge indicates a request with an idempotency key whose body is larger than
+	// MaxRequestSize.
+	ErrRequestTooLarge apperror.ErrorType = "ER0954 the request body must be %d bytes or fewer"
+	// ErrAnonymousKey indicates an idempotency key sent by a caller the authentication middleware
+	// did not identify.
+	ErrAnonymousKey apperror.ErrorType = "ER0955 the idempotency key %s is sent by an unauthenticated caller"
+)
+
+func init() {
+	apperror.Register("idempotency",
+		apperror.Entry{Err: ErrKeyReused, Description: "The Idempotency-Key was already used with a different request body, a new key is needed."},
+		apperror.Entry{Err: ErrRequestInProgress, Description: "The first request with the Idempotency-Key has not completed yet, retry later."},
+		apperror.Entry{Err: ErrInvalidKey, Description: "The Idempotency-Key header is too long."},
+		apperror.Entry{Err: ErrRequestTooLarge, Description: "The body of a request with an Idempotency-Key is too large to be hashed."},
+		apperror.Entry{Err: ErrAnonymousKey, Description: "The keys are scoped to the caller, the request must be authenticated."},
+	)
+	apperror.MapCode(ErrKeyReused.Code(), http.StatusConflict)
+	apperror.MapCode(ErrRequestInProgress.Code(), http.StatusConflict)
+	apperror.MapCode(ErrInvalidKey.Code(), http.StatusBadRequest)
+	apperror.MapCode(ErrRequestTooLarge.Code(), http.StatusRequestEntityTooLarge)
+	apperror.MapCode(ErrAnonymousKey.Code(), http.StatusUnauthorized)
+}
@@ -0,0 +1,262 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/logger"
+	"github.com/a-aslani/wotop/model/payload"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// DefaultHeader is the header carrying the idempotency key.
+	DefaultHeader = "Idempotency-Key"
+	// ReplayedHeader is set to "true" on the responses replayed from the store.
+	ReplayedHeader = "Idempotent-Replayed"
+	// DefaultMaxResponseSize is the largest response body stored, 1 MiB.
+	DefaultMaxResponseSize = 1 << 20
+	// DefaultMaxRequestSize is the largest request body read to be hashed, 1 MiB.
+	DefaultMaxRequestSize = 1 << 20
+	// MaxKeyLength is the longest idempotency key accepted.
+	MaxKeyLength = 255
+)
+
+// Options configures Middleware.
+//
+// Fields:
+//   - Methods: The methods made idempotent, POST and PATCH when empty.
+//   - Paths: The routes made idempotent, as registered, e.g. "/orders/:id/pay", all when empty.
+//   - Header: The header carrying the key, DefaultHeader when empty.
+//   - MaxResponseSize: The largest response body stored, DefaultMaxResponseSize when zero.
+//   - MaxRequestSize: The largest request body read, DefaultMaxRequestSize when zero.
+//   - Log: Warns about the responses not stored, optional.
+type Options struct {
+	Methods         []string
+	Paths           []string
+	Header          string
+	MaxResponseSize int
+	MaxRequestSize  int64
+	Log             logger.Logger
+}
+
+// notReplayedHeaders are the response headers set again by the server on a replay.
+var notReplayedHeaders = []string{"Content-Type", "Content-Length", "Date", "Connection", "Transfer-Encoding", ReplayedHeader}
+
+// Middleware returns a Gin middleware making the mutations idempotent. The first request with
+// an idempotency key runs and its response is stored for ttl, keyed by the key, the user and
+// the path. A retry with the same key and body gets the stored response back, its status, headers
+// and body, with the ReplayedHeader, without running the handler again.
+//
+// The keys are scoped to the caller, so the authentication middleware, e.g.
+// jwt.GinMiddleware.Authentication, must run before Middleware: a request with a key from a
+// caller it did not identify is answered with ErrAnonymousKey, 401. A request body larger than
+// MaxRequestSize is answered with ErrRequestTooLarge, 413.
+//
+// A retry with another body is answered with ErrKeyReused, and one sent while the first request
+// still runs with ErrRequestInProgress, both 409. The responses with a 5xx status are not stored
+// so the request can be retried, nor the streamed or larger than MaxResponseSize ones, which
+// are logged as warnings. The requests without key run as usual.
+//
+// Parameters:
+//   - store: The store of the records, e.g. a RedisStore shared by the replicas.
+//   - ttl: The time a response is replayed.
+//   - opts: The routes made idempotent and the limits.
+//
+// Returns:
+//   - A gin.HandlerFunc to register with gin.Engine.Use or on the routes.
+func Middleware(store Store, ttl time.Duration, opts Options) gin.HandlerFunc {
+
+	if len(opts.Methods) == 0 {
+		opts.Methods = []string{http.MethodPost, http.MethodPatch}
+	}
+	if opts.Header == "" {
+		opts.Header = DefaultHeader
+	}
+	if opts.MaxResponseSize <= 0 {
+		opts.MaxResponseSize = DefaultMaxResponseSize
+	}
+	if opts.MaxRequestSize <= 0 {
+		opts.MaxRequestSize = DefaultMaxRequestSize
+	}
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(opts.Header)
+		if key == "" || !slices.Contains(opts.Methods, c.Request.Method) || (len(opts.Paths) > 0 && !slices.Contains(opts.Paths, c.FullPath())) {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		traceID := logger.GetTraceID(ctx)
+
+		if len(key) > MaxKeyLength {
+			payload.WriteError(c, ErrInvalidKey.Var(opts.Header, MaxKeyLength), traceID)
+			c.Abort()
+			return
+		}
+
+		user := userID(c)
+		if user == "" {
+			payload.WriteError(c, ErrAnonymousKey.Var(key), traceID)
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, opts.MaxRequestSize))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				err = ErrRequestTooLarge.Var(opts.MaxRequestSize)
+			}
+			payload.WriteError(c, err, traceID)
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		path := c.Request.URL.Path
+		requestHash := hash(c.Request.Method, path, string(body))
+		storeKey := hash(key, user, path)
+
+		record, reserved, err := store.Reserve(ctx, storeKey, requestHash, ttl)
+		if err != nil {
+			payload.WriteError(c, err, traceID)
+			c.Abort()
+			return
+		}
+
+		if !reserved {
+			switch {
+			case record.RequestHash != requestHash:
+				payload.WriteError(c, ErrKeyReused.Var(key), traceID)
+			case !record.Done:
+				payload.WriteError(c, ErrRequestInProgress.Var(key), traceID)
+			default:
+				for name, values := range record.Header {
+					c.Writer.Header()[name] = values
+				}
+				c.Header(ReplayedHeader, "true")
+				c.Data(record.Status, record.ContentType, record.Body)
+			}
+			c.Abort()
+			return
+		}
+
+		saved := false
+		defer func() {
+			// a request failing or panicking can be retried with the same key
+			if !saved {
+				if err := store.Release(context.WithoutCancel(ctx), storeKey); err != nil && opts.Log != nil {
+					opts.Log.Error(ctx, "idempotency: cannot release the key %s: %v", key, err)
+				}
+			}
+		}()
+
+		rec := &recorder{ResponseWriter: c.Writer, limit: opts.MaxResponseSize}
+		c.Writer = rec
+		c.Next()
+		c.Writer = rec.ResponseWriter
+
+		switch {
+		case rec.Status() >= http.StatusInternalServerError:
+			return
+		case rec.bypassed:
+			if opts.Log != nil {
+				opts.Log.Warning(ctx, "idempotency: the response of %s %s with the key %s is streamed or larger than %d bytes, it is not stored", c.Request.Method, path, key, opts.MaxResponseSize)
+			}
+			return
+		}
+
+		err = store.Save(context.WithoutCancel(ctx), storeKey, Record{
+			RequestHash: requestHash,
+			Done:        true,
+			Status:      rec.Status(),
+			ContentType: rec.Header().Get("Content-Type"),
+			Header:      replayedHeader(rec.Header()),
+			Body:        rec.body.Bytes(),
+		}, ttl)
+		if err != nil {
+			if opts.Log != nil {
+				opts.Log.Error(ctx, "idempotency: cannot store the response of the key %s: %v", key, err)
+			}
+			return
+		}
+		saved = true
+	}
+}
+
+// hash returns the hex SHA-256 of the parts, separated so they cannot run into each other.
+func hash(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		_, _ = io.WriteString(h, part)
+		_, _ = h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// replayedHeader returns the response headers stored for the replays, nil when there are none.
+func replayedHeader(header http.Header) http.Header {
+	replayed := header.Clone()
+	for _, name := range notReplayedHeaders {
+		replayed.Del(name)
+	}
+	if len(replayed) == 0 {
+		return nil
+	}
+	return replayed
+}
+
+// userID returns the ID of the caller set by the jwt middleware, in the request context or in
+// the Gin keys, empty for the callers it did not identify.
+func userID(c *gin.Context) string {
+	if identity, ok := wotop.IdentityFromContext(c.Request.Context()); ok && identity.ID != "" {
+		return identity.ID
+	}
+	return c.GetString("ID")
+}
+
+// recorder copies the response body written by the handler, up to a limit.
+type recorder struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	limit    int
+	bypassed bool // the response is streamed or too large, it is not stored
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.capture(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *recorder) WriteString(s string) (int, error) {
+	r.capture([]byte(s))
+	return r.ResponseWriter.WriteString(s)
+}
+
+func (r *recorder) Flush() {
+	r.bypassed = true
+	r.body.Reset()
+	r.ResponseWriter.Flush()
+}
+
+func (r *recorder) capture(b []byte) {
+	if r.bypassed {
+		return
+	}
+	if r.body.Len()+len(b) > r.limit {
+		r.bypassed = true
+		r.body.Reset()
+		return
+	}
+	r.body.Write(b)
+}
@@ -0,0 +1,261 @@
+package idempotency
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop/logger"
+	"github.com/a-aslani/wotop/logger/loggertest"
+	"github.com/a-aslani/wotop/util"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var t0 = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// payments is a router whose POST /payments creates a payment per execution.
+type payments struct {
+	router *gin.Engine
+	calls  atomic.Int32
+}
+
+func newPayments(store Store, opts Options) *payments {
+	gin.SetMode(gin.TestMode)
+	p := &payments{router: gin.New()}
+	p.router.Use(func(c *gin.Context) {
+		c.Set("ID", c.GetHeader("X-User"))
+	}, Middleware(store, time.Hour, opts))
+
+	p.router.POST("/payments", func(c *gin.Context) {
+		var req struct {
+			Amount int `json:"amount"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		n := p.calls.Add(1)
+		c.JSON(http.StatusCreated, gin.H{"payment": n, "amount": req.Amount})
+	})
+	p.router.POST("/orders", func(c *gin.Context) {
+		n := p.calls.Add(1)
+		c.Header("Location", fmt.Sprintf("/orders/%d", n))
+		c.Header("X-Request-Cost", "3")
+		c.Status(http.StatusCreated)
+	})
+	p.router.POST("/fail", func(c *gin.Context) {
+		p.calls.Add(1)
+		c.Status(http.StatusServiceUnavailable)
+	})
+	p.router.POST("/report", func(c *gin.Context) {
+		p.calls.Add(1)
+		c.String(http.StatusOK, strings.Repeat("x", 64))
+	})
+	return p
+}
+
+func (p *payments) post(path, key, user, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(DefaultHeader, key)
+	}
+	req.Header.Set("X-User", user)
+	w := httptest.NewRecorder()
+	p.router.ServeHTTP(w, req)
+	return w
+}
+
+func errorCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var res struct {
+		ErrorCode string `json:"error_code"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	return res.ErrorCode
+}
+
+func TestMiddlewareReplaysResponse(t *testing.T) {
+	p := newPayments(NewMemoryStore(util.NewFrozenClock(t0)), Options{})
+
+	first := p.post("/payments", "key-1", "user-1", `{"amount":100}`)
+	require.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get(ReplayedHeader))
+
+	replay := p.post("/payments", "key-1", "user-1", `{"amount":100}`)
+	assert.Equal(t, http.StatusCreated, replay.Code)
+	assert.Equal(t, first.Body.String(), replay.Body.String())
+	assert.Equal(t, first.Header().Get("Content-Type"), replay.Header().Get("Content-Type"))
+	assert.Equal(t, "true", replay.Header().Get(ReplayedHeader))
+	assert.Equal(t, int32(1), p.calls.Load(), "the payment is created once")
+
+	// the keys are scoped to the user
+	other := p.post("/payments", "key-1", "user-2", `{"amount":100}`)
+	assert.Equal(t, http.StatusCreated, other.Code)
+	assert.Empty(t, other.Header().Get(ReplayedHeader))
+
+	// the requests without key are not idempotent
+	p.post("/payments", "", "user-1", `{"amount":100}`)
+	assert.Equal(t, int32(3), p.calls.Load())
+}
+
+func TestMiddlewareReplaysHeaders(t *testing.T) {
+	p := newPayments(NewMemoryStore(util.NewFrozenClock(t0)), Options{})
+
+	first := p.post("/orders", "key-1", "user-1", `{}`)
+	require.Equal(t, http.StatusCreated, first.Code)
+
+	replay := p.post("/orders", "key-1", "user-1", `{}`)
+	assert.Equal(t, http.StatusCreated, replay.Code)
+	assert.Equal(t, "/orders/1", replay.Header().Get("Location"))
+	assert.Equal(t, "3", replay.Header().Get("X-Request-Cost"))
+	assert.Equal(t, "true", replay.Header().Get(ReplayedHeader))
+	assert.Equal(t, int32(1), p.calls.Load())
+}
+
+func TestMiddlewareRejectsAnonymousKeys(t *testing.T) {
+	p := newPayments(NewMemoryStore(util.NewFrozenClock(t0)), Options{})
+
+	anonymous := p.post("/payments", "key-1", "", `{"amount":100}`)
+	assert.Equal(t, http.StatusUnauthorized, anonymous.Code)
+	assert.Equal(t, ErrAnonymousKey.Code(), errorCode(t, anonymous))
+	assert.Equal(t, int32(0), p.calls.Load(), "the keys of the callers are not shared")
+
+	// the requests without key are not idempotent, the route decides
+	assert.Equal(t, http.StatusCreated, p.post("/payments", "", "", `{"amount":100}`).Code)
+}
+
+func TestMiddlewareRejectsLargeRequests(t *testing.T) {
+	p := newPayments(NewMemoryStore(util.NewFrozenClock(t0)), Options{MaxRequestSize: 16})
+
+	large := p.post("/payments", "key-1", "user-1", `{"amount":100000000000}`)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, large.Code)
+	assert.Equal(t, ErrRequestTooLarge.Code(), errorCode(t, large))
+	assert.Equal(t, int32(0), p.calls.Load())
+
+	assert.Equal(t, http.StatusCreated, p.post("/payments", "key-1", "user-1", `{"amount":1}`).Code)
+}
+
+func TestMiddlewareConflictingBody(t *testing.T) {
+	p := newPayments(NewMemoryStore(util.NewFrozenClock(t0)), Options{})
+
+	require.Equal(t, http.StatusCreated, p.post("/payments", "key-1", "user-1", `{"amount":100}`).Code)
+
+	conflict := p.post("/payments", "key-1", "user-1", `{"amount":999}`)
+	assert.Equal(t, http.StatusConflict, conflict.Code)
+	assert.Equal(t, ErrKeyReused.Code(), errorCode(t, conflict))
+	assert.Equal(t, int32(1), p.calls.Load())
+}
+
+func TestMiddlewareRequestInProgress(t *testing.T) {
+	store := NewMemoryStore(util.NewFrozenClock(t0))
+	entered, release := make(chan struct{}), make(chan struct{})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("ID", "user-1")
+	}, Middleware(store, time.Hour, Options{}))
+	router.POST("/payments", func(c *gin.Context) {
+		close(entered)
+		<-release
+		c.Status(http.StatusCreated)
+	})
+
+	post := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader("{}"))
+		req.Header.Set(DefaultHeader, "key-1")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- post() }()
+	<-entered
+
+	retry := post()
+	assert.Equal(t, http.StatusConflict, retry.Code)
+	assert.Equal(t, ErrRequestInProgress.Code(), errorCode(t, retry))
+
+	close(release)
+	assert.Equal(t, http.StatusCreated, (<-done).Code)
+	assert.Equal(t, "true", post().Header().Get(ReplayedHeader))
+}
+
+func TestMiddlewareTTLExpiry(t *testing.T) {
+	clock := util.NewFrozenClock(t0)
+	p := newPayments(NewMemoryStore(clock), Options{})
+
+	p.post("/payments", "key-1", "user-1", `{"amount":100}`)
+	clock.Advance(59 * time.Minute)
+	assert.Equal(t, "true", p.post("/payments", "key-1", "user-1", `{"amount":100}`).Header().Get(ReplayedHeader))
+
+	clock.Advance(time.Minute)
+	again := p.post("/payments", "key-1", "user-1", `{"amount":999}`)
+	assert.Equal(t, http.StatusCreated, again.Code, "an expired key can be reused")
+	assert.Empty(t, again.Header().Get(ReplayedHeader))
+	assert.Equal(t, int32(2), p.calls.Load())
+}
+
+func TestMiddlewareDoesNotStoreServerErrors(t *testing.T) {
+	p := newPayments(NewMemoryStore(util.NewFrozenClock(t0)), Options{})
+
+	assert.Equal(t, http.StatusServiceUnavailable, p.post("/fail", "key-1", "user-1", `{}`).Code)
+	assert.Equal(t, http.StatusServiceUnavailable, p.post("/fail", "key-1", "user-1", `{}`).Code)
+	assert.Equal(t, int32(2), p.calls.Load(), "a failed request can be retried")
+}
+
+func TestMiddlewareBypassesLargeResponses(t *testing.T) {
+	log := &loggertest.Recorder{}
+	p := newPayments(NewMemoryStore(util.NewFrozenClock(t0)), Options{MaxResponseSize: 32, Log: log})
+
+	first := p.post("/report", "key-1", "user-1", `{}`)
+	assert.Equal(t, strings.Repeat("x", 64), first.Body.String(), "the response is still sent")
+	p.post("/report", "key-1", "user-1", `{}`)
+
+	assert.Equal(t, int32(2), p.calls.Load())
+	require.Len(t, log.Messages(logger.LevelWarning), 2)
+	assert.Contains(t, log.Messages(logger.LevelWarning)[0], "larger than 32 bytes")
+}
+
+func TestMiddlewareOptions(t *testing.T) {
+	p := newPayments(NewMemoryStore(util.NewFrozenClock(t0)), Options{Paths: []string{"/report"}})
+
+	p.post("/payments", "key-1", "user-1", `{"amount":100}`)
+	p.post("/payments", "key-1", "user-1", `{"amount":100}`)
+	assert.Equal(t, int32(2), p.calls.Load(), "only the configured routes are idempotent")
+
+	long := p.post("/report", strings.Repeat("k", MaxKeyLength+1), "user-1", `{}`)
+	assert.Equal(t, http.StatusBadRequest, long.Code)
+	assert.Equal(t, ErrInvalidKey.Code(), errorCode(t, long))
+}
+
+func TestRedisStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	p := newPayments(NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()})), Options{})
+
+	first := p.post("/payments", "key-1", "user-1", `{"amount":100}`)
+	replay := p.post("/payments", "key-1", "user-1", `{"amount":100}`)
+	assert.Equal(t, first.Body.String(), replay.Body.String())
+	assert.Equal(t, "true", replay.Header().Get(ReplayedHeader))
+	assert.Equal(t, http.StatusConflict, p.post("/payments", "key-1", "user-1", `{"amount":1}`).Code)
+
+	keys := mr.Keys()
+	require.Len(t, keys, 1)
+	assert.True(t, strings.HasPrefix(keys[0], RedisKeyPrefix))
+	assert.Equal(t, time.Hour, mr.TTL(keys[0]))
+
+	mr.FastForward(time.Hour)
+	assert.Empty(t, p.post("/payments", "key-1", "user-1", `{"amount":1}`).Header().Get(ReplayedHeader))
+	assert.Equal(t, int32(2), p.calls.Load())
+}
@@ -0,0 +1,188 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/a-aslani/wotop/util"
+	"github.com/redis/go-redis/v9"
+)
+
+// Record is the state of an idempotency key: the hash of the request that reserved it and,
+// once it completed, its response.
+//
+// Fields:
+//   - RequestHash: The hash of the method, path and body of the request.
+//   - Done: Whether the response is stored, false while the request runs.
+//   - Status: The HTTP status of the response.
+//   - ContentType: The Content-Type of the response.
+//   - Header: The other headers of the response, e.g. Location.
+//   - Body: The body of the response.
+type Record struct {
+	RequestHash string      `json:"request_hash"`
+	Done        bool        `json:"done"`
+	Status      int         `json:"status,omitempty"`
+	ContentType string      `json:"content_type,omitempty"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+}
+
+// Store keeps the records of the idempotency keys until their TTL expires.
+type Store interface {
+	// Reserve stores a pending record for the key unless it has one already, atomically, so
+	// of concurrent requests one only runs.
+	//
+	// Parameters:
+	//   - ctx: The context of the request.
+	//   - key: The key, scoped to the user and the path.
+	//   - requestHash: The hash of the request.
+	//   - ttl: The time the record is kept.
+	//
+	// Returns:
+	//   - The existing record, nil when the key is reserved.
+	//   - Whether the key is reserved by this call.
+	//   - An error if the store fails.
+	Reserve(ctx context.Context, key, requestHash string, ttl time.Duration) (*Record, bool, error)
+
+	// Save replaces the pending record of the key by the completed one.
+	Save(ctx context.Context, key string, record Record, ttl time.Duration) error
+
+	// Release deletes the record of the key, so the request can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+// MemoryStore is a Store keeping the records in memory, for the tests and the single-replica
+// applications. It is safe for concurrent use.
+type MemoryStore struct {
+	mu      sync.Mutex
+	clock   util.Clock
+	records map[string]memoryRecord
+}
+
+// memoryRecord is a record of MemoryStore.
+type memoryRecord struct {
+	record    Record
+	expiresAt time.Time
+}
+
+// Ensure MemoryStore and RedisStore implement the Store interface.
+var (
+	_ Store = (*MemoryStore)(nil)
+	_ Store = (*RedisStore)(nil)
+)
+
+// NewMemoryStore creates a MemoryStore.
+//
+// Parameters:
+//   - clock: The clock expiring the records, util.SystemClock in production.
+//
+// Returns:
+//   - The empty store.
+func NewMemoryStore(clock util.Clock) *MemoryStore {
+	return &MemoryStore{clock: clock, records: map[string]memoryRecord{}}
+}
+
+// Reserve reserves the key under the lock of the store, the expired records are forgotten.
+func (s *MemoryStore) Reserve(_ context.Context, key, requestHash string, ttl time.Duration) (*Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	for k, r := range s.records {
+		if !r.expiresAt.After(now) {
+			delete(s.records, k)
+		}
+	}
+
+	if r, ok := s.records[key]; ok {
+		record := r.record
+		return &record, false, nil
+	}
+
+	s.records[key] = memoryRecord{record: Record{RequestHash: requestHash}, expiresAt: now.Add(ttl)}
+	return nil, true, nil
+}
+
+func (s *MemoryStore) Save(_ context.Context, key string, record Record, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records[key] = memoryRecord{record: record, expiresAt: s.clock.Now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
+
+// RedisKeyPrefix prefixes the keys of RedisStore.
+const RedisKeyPrefix = "idempotency:"
+
+// RedisStore is a Store keeping the records in Redis, expired by Redis with their TTL.
+type RedisStore struct {
+	rdb *redis.Client
+}
+
+// NewRedisStore creates a RedisStore.
+//
+// Parameters:
+//   - rdb: The Redis client.
+//
+// Returns:
+//   - The store.
+func NewRedisStore(rdb *redis.Client) *RedisStore {
+	return &RedisStore{rdb: rdb}
+}
+
+// Reserve reserves the key with SET NX, or reads the record of the key reserved already.
+func (s *RedisStore) Reserve(ctx context.Context, key, requestHash string, ttl time.Duration) (*Record, bool, error) {
+	pending, err := json.Marshal(Record{RequestHash: requestHash})
+	if err != nil {
+		return nil, false, err
+	}
+
+	for {
+		reserved, err := s.rdb.SetNX(ctx, RedisKeyPrefix+key, pending, ttl).Result()
+		if err != nil {
+			return nil, false, err
+		}
+		if reserved {
+			return nil, true, nil
+		}
+
+		value, err := s.rdb.Get(ctx, RedisKeyPrefix+key).Bytes()
+		if errors.Is(err, redis.Nil) {
+			// the record expired in between, reserve again
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		var record Record
+		if err = json.Unmarshal(value, &record); err != nil {
+			return nil, false, err
+		}
+		return &record, false, nil
+	}
+}
+
+func (s *RedisStore) Save(ctx context.Context, key string, record Record, ttl time.Duration) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.rdb.Set(ctx, RedisKeyPrefix+key, value, ttl).Err()
+}
+
+func (s *RedisStore) Release(ctx context.Context, key string) error {
+	return s.rdb.Del(ctx, RedisKeyPrefix+key).Err()
+}
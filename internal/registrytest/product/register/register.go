@@ -0,0 +1,15 @@
+// Package register is a usecase of the product domain, it shares its name with the register
+// usecase of the other domain to test the usecase registry of the controllers.
+package register
+
+import "context"
+
+type InportRequest struct{}
+
+type InportResponse struct{ Domain string }
+
+type Interactor struct{}
+
+func (Interactor) Execute(context.Context, InportRequest) (*InportResponse, error) {
+	return &InportResponse{Domain: "product"}, nil
+}
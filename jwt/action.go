@@ -0,0 +1,126 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// ActionTokenTableName is the table, or key prefix, of the action tokens.
+const ActionTokenTableName = "action_token"
+
+// ActionClaims are the claims of a single-use action token, see Token.GenerateActionToken.
+type ActionClaims struct {
+	Action  string         `json:"action"`
+	Tenant  string         `json:"tenant,omitempty"`
+	Payload map[string]any `json:"payload,omitempty"`
+	jwt.StandardClaims
+}
+
+// ActionTokenRepository is a Repository storing the IDs of the action tokens not consumed yet.
+// RedisRepository and MemoryRepository implement it.
+type ActionTokenRepository interface {
+	Repository
+
+	// StoreActionToken stores the ID of an action token until it expires.
+	// Parameters:
+	// - ctx: The context for the operation.
+	// - sub: The subject (user identifier) associated with the token.
+	// - jti: The unique identifier for the token.
+	// - expiresAt: The expiration time of the token (in Unix timestamp).
+	// Returns:
+	// - error: An error if the operation fails.
+	StoreActionToken(ctx context.Context, sub, jti string, expiresAt int64) error
+
+	// ConsumeActionToken deletes the ID of an action token, atomically: of concurrent calls for
+	// the same ID, one only succeeds.
+	// Parameters:
+	// - ctx: The context for the operation.
+	// - jti: The unique identifier of the token.
+	// Returns:
+	// - string: The subject associated with the token.
+	// - error: ErrActionTokenUsed if the token is not stored, consumed already or expired.
+	ConsumeActionToken(ctx context.Context, jti string) (sub string, err error)
+}
+
+// GenerateActionToken generates a single-use token for an action, see Token.GenerateActionToken.
+// Parameters:
+// - ctx: The context for the operation.
+// - sub: The subject (user identifier) the action is for.
+// - tenant: The tenant of the subject, which selects the signing key of a multi-tenant Token.
+// - action: The action, e.g. "verify_email".
+// - ttl: The validity duration of the token.
+// - payload: The data of the action, may be nil.
+// Returns:
+// - string: The signed token.
+// - error: ErrActionTokensNotSupported if the repository is not an ActionTokenRepository.
+func (t *token) GenerateActionToken(ctx context.Context, sub, tenant, action string, ttl time.Duration, payload map[string]any) (string, error) {
+	repo, ok := t.repo.(ActionTokenRepository)
+	if !ok {
+		return "", ErrActionTokensNotSupported
+	}
+
+	jti, err := t.generateRandomString(32)
+	if err != nil {
+		return "", err
+	}
+
+	claims := &ActionClaims{
+		Action:         action,
+		Tenant:         tenant,
+		Payload:        payload,
+		StandardClaims: t.standardClaims(sub, ttl),
+	}
+	claims.Id = jti
+
+	signed, err := t.sign(claims)
+	if err != nil {
+		return "", err
+	}
+
+	if err = repo.StoreActionToken(ctx, sub, jti, claims.ExpiresAt); err != nil {
+		return "", err
+	}
+
+	return signed, nil
+}
+
+// ConsumeActionToken verifies an action token and consumes it, see Token.ConsumeActionToken.
+// A token presented for another action is not consumed, so it stays usable for its own action.
+// Parameters:
+// - ctx: The context for the operation.
+// - actionToken: The action token.
+// - expectedAction: The action the token must have been issued for.
+// Returns:
+// - *ActionClaims: The claims of the token.
+// - error: ErrWrongAction, ErrActionTokenExpired, ErrActionTokenUsed, or ErrUnauthorized.
+func (t *token) ConsumeActionToken(ctx context.Context, actionToken, expectedAction string) (*ActionClaims, error) {
+	repo, ok := t.repo.(ActionTokenRepository)
+	if !ok {
+		return nil, ErrActionTokensNotSupported
+	}
+
+	claims := &ActionClaims{}
+	if _, err := t.parseWithClaims(actionToken, claims); err != nil {
+		var ve *jwt.ValidationError
+		if errors.As(err, &ve) && ve.Errors&jwt.ValidationErrorExpired != 0 {
+			return nil, ErrActionTokenExpired
+		}
+		return nil, ErrUnauthorized
+	}
+
+	if claims.Id == "" {
+		return nil, ErrUnauthorized
+	}
+	if claims.Action != expectedAction {
+		return nil, ErrWrongAction.Var(expectedAction)
+	}
+
+	if _, err := repo.ConsumeActionToken(ctx, claims.Id); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
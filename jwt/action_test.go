@@ -0,0 +1,208 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop/model/apperror"
+	"github.com/a-aslani/wotop/util"
+	"github.com/alicebob/miniredis/v2"
+	jwt "github.com/golang-jwt/jwt"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newActionToken creates an HS256 Token on a MemoryRepository, driven by the given clock.
+func newActionToken(t *testing.T, clock util.Clock) Token {
+	t.Helper()
+
+	token, err := NewHS256JWT(context.Background(), "secret", NewMemoryRepository(clock), time.Hour, time.Minute, WithClock(clock))
+	require.NoError(t, err)
+	return token
+}
+
+func TestActionTokenConsumedOnce(t *testing.T) {
+	ctx := context.Background()
+	token := newActionToken(t, util.NewFrozenClock(t0))
+
+	verify, err := token.GenerateActionToken(ctx, "user-1", "", "verify_email", time.Hour, map[string]any{"email": "ali@example.com"})
+	require.NoError(t, err)
+
+	claims, err := token.ConsumeActionToken(ctx, verify, "verify_email")
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.Subject)
+	assert.Equal(t, "verify_email", claims.Action)
+	assert.Equal(t, map[string]any{"email": "ali@example.com"}, claims.Payload)
+	assert.Equal(t, t0.Add(time.Hour).Unix(), claims.ExpiresAt)
+
+	_, err = token.ConsumeActionToken(ctx, verify, "verify_email")
+	assert.ErrorIs(t, err, ErrActionTokenUsed)
+}
+
+func TestActionTokenWrongActionIsNotConsumed(t *testing.T) {
+	ctx := context.Background()
+	token := newActionToken(t, util.NewFrozenClock(t0))
+
+	reset, err := token.GenerateActionToken(ctx, "user-1", "", "reset_password", time.Hour, nil)
+	require.NoError(t, err)
+
+	_, err = token.ConsumeActionToken(ctx, reset, "verify_email")
+	assertCode(t, err, ErrWrongAction)
+
+	_, err = token.ConsumeActionToken(ctx, reset, "reset_password")
+	assert.NoError(t, err, "the token stays usable for its own action")
+}
+
+func TestActionTokenExpired(t *testing.T) {
+	ctx := context.Background()
+	clock := util.NewFrozenClock(t0)
+	token := newActionToken(t, clock)
+
+	verify, err := token.GenerateActionToken(ctx, "user-1", "", "verify_email", 15*time.Minute, nil)
+	require.NoError(t, err)
+
+	clock.Set(t0.Add(16 * time.Minute))
+	_, err = token.ConsumeActionToken(ctx, verify, "verify_email")
+	assert.ErrorIs(t, err, ErrActionTokenExpired)
+}
+
+func TestActionTokenRejectsOtherTokens(t *testing.T) {
+	ctx := context.Background()
+	token := newActionToken(t, util.NewFrozenClock(t0))
+
+	accessToken, _, _, _, err := token.GenerateToken(ctx, "user-1", "admin", "user-1", "")
+	require.NoError(t, err)
+
+	_, err = token.ConsumeActionToken(ctx, accessToken, "verify_email")
+	assert.ErrorIs(t, err, ErrUnauthorized, "an access token has no ID")
+
+	_, err = token.ConsumeActionToken(ctx, "not-a-token", "verify_email")
+	assert.ErrorIs(t, err, ErrUnauthorized)
+
+	_, err = token.ConsumeActionToken(ctx, accessToken, "")
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestActionTokenConcurrentConsumption(t *testing.T) {
+	ctx := context.Background()
+	token := newActionToken(t, util.NewFrozenClock(t0))
+
+	reset, err := token.GenerateActionToken(ctx, "user-1", "", "reset_password", time.Hour, nil)
+	require.NoError(t, err)
+
+	const consumers = 32
+	var wg sync.WaitGroup
+	errs := make(chan error, consumers)
+	for range consumers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := token.ConsumeActionToken(ctx, reset, "reset_password")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	var consumed, used int
+	for err := range errs {
+		switch {
+		case err == nil:
+			consumed++
+		case errors.Is(err, ErrActionTokenUsed):
+			used++
+		default:
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	assert.Equal(t, 1, consumed)
+	assert.Equal(t, consumers-1, used)
+}
+
+func TestActionTokenNeedsActionTokenRepository(t *testing.T) {
+	token, err := NewHS256JWT(context.Background(), "secret", struct{ Repository }{NewMemoryRepository(util.SystemClock)}, time.Hour, time.Minute)
+	require.NoError(t, err)
+
+	_, err = token.GenerateActionToken(context.Background(), "user-1", "", "verify_email", time.Hour, nil)
+	assert.ErrorIs(t, err, ErrActionTokensNotSupported)
+}
+
+func TestActionTokenRedisRepository(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	token, err := NewHS512JWT(ctx, "secret", NewRedisRepository(redis.NewClient(&redis.Options{Addr: mr.Addr()})), time.Hour, time.Minute)
+	require.NoError(t, err)
+
+	verify, err := token.GenerateActionToken(ctx, "user-1", "", "verify_email", time.Hour, nil)
+	require.NoError(t, err)
+
+	keys := mr.Keys()
+	require.Len(t, keys, 1)
+	assert.InDelta(t, time.Hour, mr.TTL(keys[0]), float64(2*time.Second), "the key expires with the token")
+
+	_, err = token.ConsumeActionToken(ctx, verify, "verify_email")
+	require.NoError(t, err)
+	_, err = token.ConsumeActionToken(ctx, verify, "verify_email")
+	assert.ErrorIs(t, err, ErrActionTokenUsed)
+}
+
+func TestActionTokenRS256(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	ctx := context.Background()
+	token, err := NewRS256JWT(ctx, "action", NewMemoryRepository(util.SystemClock), time.Hour, time.Minute)
+	require.NoError(t, err)
+
+	verify, err := token.GenerateActionToken(ctx, "user-1", "", "verify_email", time.Hour, nil)
+	require.NoError(t, err)
+
+	claims, err := token.ConsumeActionToken(ctx, verify, "verify_email")
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.Subject)
+}
+
+func assertCode(t *testing.T, err error, want apperror.ErrorType) {
+	t.Helper()
+
+	var appErr apperror.ErrorType
+	require.ErrorAs(t, err, &appErr)
+	assert.Equal(t, want.Code(), appErr.Code())
+}
+
+func TestMultiTenantActionToken(t *testing.T) {
+	ctx := context.Background()
+	clock := util.NewFrozenClock(t0)
+	keys := NewStaticKeyResolver(map[string][]byte{
+		"acme":   []byte("acme-secret"),
+		"globex": []byte("globex-secret"),
+	})
+	token, err := NewMultiTenantHS256JWT(ctx, keys, NewMemoryRepository(clock), time.Hour, time.Minute, WithClock(clock))
+	require.NoError(t, err)
+
+	verify, err := token.GenerateActionToken(ctx, "user-1", "acme", "verify_email", time.Hour, nil)
+	require.NoError(t, err)
+
+	claims, err := token.ConsumeActionToken(ctx, verify, "verify_email")
+	require.NoError(t, err)
+	assert.Equal(t, "acme", claims.Tenant)
+
+	// an acme user claims to be in globex with the key of acme
+	reset, err := token.GenerateActionToken(ctx, "user-1", "acme", "reset_password", time.Hour, nil)
+	require.NoError(t, err)
+	claims, err = token.ConsumeActionToken(ctx, reset, "reset_password")
+	require.NoError(t, err)
+	claims.Tenant = "globex"
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("acme-secret"))
+	require.NoError(t, err)
+	_, err = token.ConsumeActionToken(ctx, forged, "reset_password")
+	assert.ErrorIs(t, err, ErrUnauthorized)
+
+	_, err = token.GenerateActionToken(ctx, "user-1", "initech", "verify_email", time.Hour, nil)
+	var et apperror.ErrorType
+	require.ErrorAs(t, err, &et)
+	assert.Equal(t, ErrUnknownTenant.Code(), et.Code())
+}
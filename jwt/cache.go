@@ -0,0 +1,152 @@
+package jwt
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+)
+
+const (
+	// DefaultWarmUpPageSize is the number of refresh tokens read per page by the warm-up of the
+	// cache, see WithWarmUp.
+	DefaultWarmUpPageSize = 1000
+	// DefaultLazyCacheSize is the number of refresh tokens kept by a lazy cache, see
+	// WithLazyRefreshTokens.
+	DefaultLazyCacheSize = 10000
+)
+
+// PagedRepository is a Repository listing the refresh tokens page by page, so the cache of a
+// Token is warmed up without loading them all at once. RedisRepository and MemoryRepository
+// implement it.
+type PagedRepository interface {
+	Repository
+
+	// FindRefreshTokensPage retrieves a page of the refresh tokens.
+	// Parameters:
+	// - ctx: The context for the operation.
+	// - cursor: The cursor returned with the previous page, empty for the first page.
+	// - limit: The number of tokens wanted, a page may hold a few more or less.
+	// Returns:
+	// - []RefreshToken: The tokens of the page.
+	// - string: The cursor of the next page, empty after the last page.
+	// - error: An error if the operation fails.
+	FindRefreshTokensPage(ctx context.Context, cursor string, limit int) (tokens []RefreshToken, next string, err error)
+}
+
+// refreshTokenCache maps the JTIs of the refresh tokens not revoked to their subject. A
+// complete cache holds every token, so a token it does not hold is revoked. An incomplete one,
+// lazy or capped, holds the tokens used recently and reads the others from the repository. It
+// is safe for concurrent use.
+type refreshTokenCache struct {
+	mu       sync.Mutex
+	repo     Repository
+	complete bool                     // every refresh token is cached
+	capacity int                      // the number of tokens kept by an incomplete cache
+	tokens   map[string]*list.Element // the elements of order by JTI
+	order    *list.List               // the cachedRefreshToken, the most recently used first
+}
+
+// cachedRefreshToken is an entry of refreshTokenCache.
+type cachedRefreshToken struct {
+	jti string
+	sub string
+}
+
+// newRefreshTokenCache creates an empty cache.
+// Parameters:
+// - repo: The repository the misses of an incomplete cache are read from.
+// - complete: Whether the cache will hold every token.
+// - capacity: The number of tokens kept by an incomplete cache.
+// Returns:
+// - *refreshTokenCache: The cache.
+func newRefreshTokenCache(repo Repository, complete bool, capacity int) *refreshTokenCache {
+	return &refreshTokenCache{
+		repo:     repo,
+		complete: complete,
+		capacity: capacity,
+		tokens:   map[string]*list.Element{},
+		order:    list.New(),
+	}
+}
+
+// put caches a token, evicting the least recently used one when an incomplete cache is full.
+func (c *refreshTokenCache) put(jti, sub string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.tokens[jti]; ok {
+		e.Value.(*cachedRefreshToken).sub = sub
+		c.order.MoveToFront(e)
+		return
+	}
+
+	c.tokens[jti] = c.order.PushFront(&cachedRefreshToken{jti: jti, sub: sub})
+	if !c.complete && c.order.Len() > c.capacity {
+		oldest := c.order.Remove(c.order.Back()).(*cachedRefreshToken)
+		delete(c.tokens, oldest.jti)
+	}
+}
+
+// delete forgets a token.
+func (c *refreshTokenCache) delete(jti string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.tokens[jti]; ok {
+		c.order.Remove(e)
+		delete(c.tokens, jti)
+	}
+}
+
+// contains reports whether a token is cached, without reading the repository.
+func (c *refreshTokenCache) contains(jti string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.tokens[jti]
+	return ok
+}
+
+// len returns the number of tokens cached.
+func (c *refreshTokenCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+// subject returns the subject of a token. The tokens an incomplete cache does not hold are
+// read from the repository and cached.
+// Parameters:
+// - ctx: The context for the operation.
+// - jti: The unique identifier of the token.
+// Returns:
+// - string: The subject, empty when the token is revoked.
+// - error: An error if the repository fails.
+func (c *refreshTokenCache) subject(ctx context.Context, jti string) (string, error) {
+	c.mu.Lock()
+	if e, ok := c.tokens[jti]; ok {
+		c.order.MoveToFront(e)
+		sub := e.Value.(*cachedRefreshToken).sub
+		c.mu.Unlock()
+		return sub, nil
+	}
+	complete := c.complete
+	c.mu.Unlock()
+
+	if complete {
+		return "", nil
+	}
+
+	sub, err := c.repo.FindRefreshToken(ctx, jti)
+	if errors.Is(err, ErrTokenAlreadyRefreshed) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	c.put(jti, sub)
+	return sub, nil
+}
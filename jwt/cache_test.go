@@ -0,0 +1,204 @@
+package jwt
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop/util"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingRepository counts the reads of the refresh tokens of a MemoryRepository.
+type countingRepository struct {
+	*MemoryRepository
+	findAll int
+	pages   int
+	finds   int
+}
+
+func (r *countingRepository) FindAllRefreshTokens(ctx context.Context) ([]RefreshToken, error) {
+	r.findAll++
+	return r.MemoryRepository.FindAllRefreshTokens(ctx)
+}
+
+func (r *countingRepository) FindRefreshTokensPage(ctx context.Context, cursor string, limit int) ([]RefreshToken, string, error) {
+	r.pages++
+	return r.MemoryRepository.FindRefreshTokensPage(ctx, cursor, limit)
+}
+
+func (r *countingRepository) FindRefreshToken(ctx context.Context, jti string) (string, error) {
+	r.finds++
+	return r.MemoryRepository.FindRefreshToken(ctx, jti)
+}
+
+// unpagedRepository hides the PagedRepository methods of a MemoryRepository.
+type unpagedRepository struct {
+	Repository
+}
+
+func seedRefreshTokens(t testing.TB, repo Repository, n int) {
+	t.Helper()
+	for i := range n {
+		require.NoError(t, repo.StoreRefreshToken(context.Background(), fmt.Sprintf("user-%d", i), fmt.Sprintf("jti-%05d", i)))
+	}
+}
+
+func cacheOf(tok Token) *refreshTokenCache {
+	return tok.(*token).refreshTokens
+}
+
+func TestWarmUpPages(t *testing.T) {
+	ctx := context.Background()
+	repo := &countingRepository{MemoryRepository: NewMemoryRepository(util.SystemClock)}
+	seedRefreshTokens(t, repo, 25)
+
+	tok, err := NewHS256JWT(ctx, "secret", repo, time.Hour, time.Minute, WithWarmUp(10, 0), WithLogger(nopLogger{}))
+	require.NoError(t, err)
+	assert.Equal(t, 3, repo.pages)
+	assert.Zero(t, repo.findAll)
+	assert.Equal(t, 25, cacheOf(tok).len())
+
+	// a complete cache does not read the repository
+	ok, err := tok.(*token).checkRefreshToken(ctx, "jti-99999")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Zero(t, repo.finds)
+}
+
+func TestWarmUpLimit(t *testing.T) {
+	ctx := context.Background()
+	repo := &countingRepository{MemoryRepository: NewMemoryRepository(util.SystemClock)}
+	seedRefreshTokens(t, repo, 25)
+
+	tok, err := NewHS256JWT(ctx, "secret", repo, time.Hour, time.Minute, WithWarmUp(10, 15))
+	require.NoError(t, err)
+	assert.Equal(t, 2, repo.pages, "the warm-up stops at the limit")
+	assert.Equal(t, 15, cacheOf(tok).len())
+
+	// the tokens past the limit are read from the repository
+	ok, err := tok.(*token).checkRefreshToken(ctx, "jti-00024")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 1, repo.finds)
+	assert.Equal(t, 15, cacheOf(tok).len(), "the cache keeps its limit")
+}
+
+func TestWarmUpUnpagedRepository(t *testing.T) {
+	repo := NewMemoryRepository(util.SystemClock)
+	seedRefreshTokens(t, repo, 25)
+
+	tok, err := NewHS256JWT(context.Background(), "secret", unpagedRepository{repo}, time.Hour, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 25, cacheOf(tok).len())
+}
+
+func TestLazyRefreshTokens(t *testing.T) {
+	ctx := context.Background()
+	clock := util.NewFrozenClock(t0)
+	repo := &countingRepository{MemoryRepository: NewMemoryRepository(clock)}
+	seedRefreshTokens(t, repo, 25)
+
+	issuer, err := NewHS256JWT(ctx, "secret", repo, time.Hour, time.Minute, WithClock(clock))
+	require.NoError(t, err)
+	accessToken, refreshToken, csrf, _, err := issuer.GenerateToken(ctx, "user-1", "admin", "user-1", "acme")
+	require.NoError(t, err)
+	_, revokedRefresh, _, _, err := issuer.GenerateToken(ctx, "user-2", "admin", "user-2", "acme")
+	require.NoError(t, err)
+
+	repo.findAll, repo.pages = 0, 0
+	lazy, err := NewHS256JWT(ctx, "secret", repo, time.Hour, time.Minute, WithClock(clock), WithLazyRefreshTokens(2))
+	require.NoError(t, err)
+	assert.Zero(t, repo.findAll+repo.pages, "the lazy cache is not warmed up")
+	assert.Zero(t, cacheOf(lazy).len())
+
+	// the expired access token is renewed with the refresh token read from the repository
+	clock.Advance(2 * time.Minute)
+	_, _, _, _, userID, err := lazy.RenewToken(ctx, accessToken, refreshToken, csrf)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", userID)
+
+	// a refresh token deleted from the repository is revoked
+	jti, _ := refreshJTI(revokedRefresh)
+	require.NoError(t, repo.DeleteRefreshToken(ctx, jti))
+	ok, err := lazy.(*token).checkRefreshToken(ctx, jti)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestRefreshTokenCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	repo := &countingRepository{MemoryRepository: NewMemoryRepository(util.SystemClock)}
+	seedRefreshTokens(t, repo, 3)
+
+	cache := newRefreshTokenCache(repo, false, 2)
+	for _, jti := range []string{"jti-00000", "jti-00001", "jti-00000", "jti-00002"} {
+		_, err := cache.subject(ctx, jti)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 3, repo.finds, "jti-00000 is read once")
+	assert.True(t, cache.contains("jti-00000"))
+	assert.False(t, cache.contains("jti-00001"), "the least recently used token is evicted")
+	assert.True(t, cache.contains("jti-00002"))
+}
+
+func TestRedisFindRefreshTokensPage(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRedisRepository(redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()}))
+	seedRefreshTokens(t, repo, 25)
+	require.NoError(t, repo.StoreBoundRefreshToken(ctx, "user-bound", "jti-bound", "fp-1"))
+	require.NoError(t, repo.StoreBlockedToken(ctx, "user-1", "blocked", time.Now().Add(time.Hour).Unix()))
+
+	found := map[string]RefreshToken{}
+	cursor := ""
+	for {
+		tokens, next, err := repo.FindRefreshTokensPage(ctx, cursor, 10)
+		require.NoError(t, err)
+		for _, token := range tokens {
+			found[token.JTI] = token
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	assert.Len(t, found, 26)
+	assert.Equal(t, RefreshToken{Subject: "user-3", JTI: "jti-00003"}, found["jti-00003"])
+	assert.Equal(t, RefreshToken{Subject: "user-bound", JTI: "jti-bound", Fingerprint: "fp-1"}, found["jti-bound"])
+
+	_, _, err := repo.FindRefreshTokensPage(ctx, "not-a-cursor", 10)
+	assert.Error(t, err)
+}
+
+// BenchmarkNewToken compares the creation of a Token on 10k sessions: the warm-up reading
+// every token, the warm-up capped at 1k tokens and the lazy cache, which reads none. The boot
+// time and the memory of the capped and the lazy caches do not grow with the sessions.
+func BenchmarkNewToken(b *testing.B) {
+	repo := NewMemoryRepository(util.SystemClock)
+	seedRefreshTokens(b, repo, 10000)
+
+	benchmarks := map[string]struct {
+		repo Repository
+		opts []Option
+	}{
+		"all at once": {repo: unpagedRepository{repo}},
+		"capped":      {repo: repo, opts: []Option{WithWarmUp(DefaultWarmUpPageSize, 1000)}},
+		"lazy":        {repo: repo, opts: []Option{WithLazyRefreshTokens(0)}},
+	}
+	for name, bm := range benchmarks {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := NewHS256JWT(context.Background(), "secret", bm.repo, time.Hour, time.Minute, bm.opts...); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
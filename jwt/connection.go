@@ -0,0 +1,96 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ConnectionRefreshMargin is the validity an access token must have left for
+// ValidateForConnectionRefresh to keep it, it is reissued otherwise, so a connection refreshed
+// just before its token expires is not refreshed again right away.
+const ConnectionRefreshMargin = 30 * time.Second
+
+// ConnectionRefresh is the outcome of ValidateForConnectionRefresh, the tokens a connection
+// carries on with.
+//
+// Fields:
+//   - AccessToken: The access token, the one given when it is not renewed.
+//   - RefreshToken: The refresh token, the one given when the tokens are not renewed.
+//   - CsrfSecret: The CSRF secret of the access token.
+//   - UserID: The user ID of the access token.
+//   - ExpiresAt: The expiry of the access token, when the connection must be refreshed again.
+//   - Renewed: Whether the tokens were renewed, the old refresh token is revoked then.
+type ConnectionRefresh struct {
+	AccessToken  string
+	RefreshToken string
+	CsrfSecret   string
+	UserID       string
+	ExpiresAt    time.Time
+	Renewed      bool
+}
+
+// ValidateForConnectionRefresh checks the tokens of a long-lived connection, such as a
+// WebSocket, which outlives its access token. The access token is kept while it is valid for
+// ConnectionRefreshMargin more, else the tokens are renewed silently with the refresh token,
+// as RenewToken does for an expired token.
+// Parameters:
+// - ctx: The context for the operation, its fingerprint must match the device of a bound refresh token.
+// - accessToken: The access token of the connection.
+// - refreshToken: The refresh token of the connection, the access token is not renewed when empty.
+// - csrf: The CSRF secret of the access token.
+// Returns:
+//   - *ConnectionRefresh: The tokens of the connection and their expiry.
+//   - error: ErrExpiredToken if the access token is expired and cannot be renewed without refresh
+//     token, ErrUnauthorized or ErrSessionExpired if the connection must be closed.
+func (t *token) ValidateForConnectionRefresh(ctx context.Context, accessToken, refreshToken, csrf string) (*ConnectionRefresh, error) {
+
+	if scheme, token, ok := strings.Cut(accessToken, " "); ok && scheme == preTokenName {
+		accessToken = token
+	}
+
+	now := t.clock.Now()
+	_, claims, err := t.VerifyTokenAt(accessToken, now)
+	switch {
+	case err == nil && t.sessionExpired(claims):
+		return nil, ErrSessionExpired
+	case err == nil && unixTime(claims.ExpiresAt).Sub(now) >= ConnectionRefreshMargin:
+		return &ConnectionRefresh{
+			AccessToken:  accessToken,
+			RefreshToken: refreshToken,
+			CsrfSecret:   csrf,
+			UserID:       claims.ID,
+			ExpiresAt:    unixTime(claims.ExpiresAt),
+		}, nil
+	case err != nil && !errors.Is(err, ErrExpiredToken):
+		return nil, err
+	case refreshToken == "":
+		if err == nil {
+			// kept until it expires, the connection cannot be extended without refresh token
+			return &ConnectionRefresh{AccessToken: accessToken, CsrfSecret: csrf, UserID: claims.ID, ExpiresAt: unixTime(claims.ExpiresAt)}, nil
+		}
+		return nil, ErrExpiredToken
+	}
+
+	// the token is expired or about to, a blocked one is renewed only with a valid refresh token,
+	// which DeleteToken revokes too
+	newAccessToken, newRefreshToken, newCsrf, _, userID, err := t.renewToken(ctx, accessToken, refreshToken, csrf, true)
+	if err != nil {
+		return nil, err
+	}
+
+	_, claims, err = t.VerifyTokenAt(newAccessToken, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ConnectionRefresh{
+		AccessToken:  newAccessToken,
+		RefreshToken: newRefreshToken,
+		CsrfSecret:   newCsrf,
+		UserID:       userID,
+		ExpiresAt:    unixTime(claims.ExpiresAt),
+		Renewed:      true,
+	}, nil
+}
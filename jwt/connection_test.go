@@ -0,0 +1,110 @@
+package jwt
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyTokenAt(t *testing.T) {
+	clock := util.NewFrozenClock(t0)
+	token := newTestToken(t, clock)
+
+	accessToken, _, _, _, err := token.GenerateToken(context.Background(), "user-1", "admin", "user-1", "acme")
+	require.NoError(t, err)
+
+	_, claims, err := token.VerifyTokenAt(accessToken, t0.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.ID)
+
+	_, _, err = token.VerifyTokenAt(accessToken, t0.Add(time.Minute+time.Second))
+	assert.ErrorIs(t, err, ErrExpiredToken, "the token of a connection expires while the clock is still")
+
+	_, _, err = token.VerifyTokenAt(accessToken+"x", t0)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestValidateForConnectionRefresh(t *testing.T) {
+	ctx := context.Background()
+	clock := util.NewFrozenClock(t0)
+	token := newTestToken(t, clock)
+
+	accessToken, refreshToken, csrf, _, err := token.GenerateToken(ctx, "user-1", "admin", "user-1", "acme")
+	require.NoError(t, err)
+
+	// a token valid for more than the margin is kept
+	refresh, err := token.ValidateForConnectionRefresh(ctx, accessToken, refreshToken, csrf)
+	require.NoError(t, err)
+	assert.Equal(t, &ConnectionRefresh{AccessToken: accessToken, RefreshToken: refreshToken, CsrfSecret: csrf, UserID: "user-1", ExpiresAt: refresh.ExpiresAt}, refresh)
+	assert.Equal(t, t0.Add(time.Minute), refresh.ExpiresAt.UTC())
+
+	// a token about to expire is renewed silently
+	clock.Set(t0.Add(45 * time.Second))
+	refresh, err = token.ValidateForConnectionRefresh(ctx, accessToken, refreshToken, csrf)
+	require.NoError(t, err)
+	assert.True(t, refresh.Renewed)
+	assert.NotEqual(t, accessToken, refresh.AccessToken)
+	assert.NotEqual(t, refreshToken, refresh.RefreshToken)
+	assert.Equal(t, "user-1", refresh.UserID)
+	assert.Equal(t, t0.Add(105*time.Second), refresh.ExpiresAt.UTC())
+
+	// the old refresh token is revoked
+	_, err = token.ValidateForConnectionRefresh(ctx, accessToken, refreshToken, csrf)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+
+	// the expired token of a connection is renewed too
+	clock.Set(t0.Add(10 * time.Minute))
+	refresh, err = token.ValidateForConnectionRefresh(ctx, refresh.AccessToken, refresh.RefreshToken, refresh.CsrfSecret)
+	require.NoError(t, err)
+	assert.True(t, refresh.Renewed)
+	assert.Equal(t, t0.Add(11*time.Minute), refresh.ExpiresAt.UTC())
+
+	_, _, err = token.VerifyToken(refresh.AccessToken)
+	assert.NoError(t, err)
+}
+
+func TestValidateForConnectionRefreshWithoutRefreshToken(t *testing.T) {
+	ctx := context.Background()
+	clock := util.NewFrozenClock(t0)
+	token := newTestToken(t, clock)
+
+	accessToken, refreshToken, csrf, _, err := token.GenerateToken(ctx, "user-1", "admin", "user-1", "acme")
+	require.NoError(t, err)
+
+	clock.Set(t0.Add(45 * time.Second))
+	refresh, err := token.ValidateForConnectionRefresh(ctx, accessToken, "", csrf)
+	require.NoError(t, err)
+	assert.False(t, refresh.Renewed, "the token is kept until it expires")
+	assert.Equal(t, t0.Add(time.Minute), refresh.ExpiresAt.UTC())
+
+	clock.Set(t0.Add(2 * time.Minute))
+	_, err = token.ValidateForConnectionRefresh(ctx, accessToken, "", csrf)
+	assert.ErrorIs(t, err, ErrExpiredToken)
+
+	// a logged out session is not renewed
+	accessToken, refreshToken, csrf, _, err = token.GenerateToken(ctx, "user-1", "admin", "user-1", "acme")
+	require.NoError(t, err)
+	require.NoError(t, token.DeleteToken(ctx, accessToken, refreshToken))
+	_, err = token.ValidateForConnectionRefresh(ctx, accessToken, refreshToken, csrf)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	clock.Advance(time.Hour)
+	_, err = token.ValidateForConnectionRefresh(ctx, accessToken, refreshToken, csrf)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+
+	_, err = token.ValidateForConnectionRefresh(ctx, "not-a-token", refreshToken, csrf)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestQueryParam(t *testing.T) {
+	token, err := QueryParam("token")(httptest.NewRequest("GET", "/connect?token=abc", nil))
+	require.NoError(t, err)
+	assert.Equal(t, "abc", token)
+
+	_, err = QueryParam("token")(httptest.NewRequest("GET", "/connect", nil))
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
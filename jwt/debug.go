@@ -0,0 +1,243 @@
+package jwt
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/a-aslani/wotop/util"
+	"github.com/golang-jwt/jwt"
+)
+
+// The kinds of tokens a TokenReport tells apart by their claims.
+const (
+	KindAccess  = "access"
+	KindRefresh = "refresh"
+	KindAction  = "action"
+	KindUnknown = "unknown"
+)
+
+// redacted replaces the value of the claims a TokenReport does not show.
+const redacted = "[redacted]"
+
+// kindClaims are the claims the tokens of each kind are issued with, see GenerateToken and
+// GenerateActionToken. The optional ones, e.g. nbf or auth_time, are not listed.
+var kindClaims = map[string][]string{
+	KindAccess:  {"id", "csrf", "role", "tenant", "sub", "iat", "exp"},
+	KindRefresh: {"csrf", "jti", "sub", "iat", "exp"},
+	KindAction:  {"action", "jti", "sub", "iat", "exp"},
+}
+
+// sensitiveClaims are the claims whose value a TokenReport redacts: the CSRF secret renews
+// the session of the token.
+var sensitiveClaims = []string{"csrf"}
+
+// TokenReport describes a token decoded by Debug, e.g. to troubleshoot a failing request
+// without pasting the token into an online decoder. The signature of the token is never part
+// of it.
+// Fields:
+// - Kind: The kind of the token told by its claims, KindAccess, KindRefresh, KindAction or KindUnknown.
+// - Algorithm: The signing algorithm of the header, e.g. "HS256".
+// - KeyID: The kid of the header, empty when there is none.
+// - Header: The header of the token.
+// - Claims: The claims of the token, the CSRF secret redacted.
+// - Present: The claims of the kind the token carries, sorted.
+// - Missing: The claims of the kind the token lacks, sorted.
+// - IssuedAt, NotBefore, ExpiresAt, AuthTime: The times of the token, the zero time when it has none.
+// - Expired: Whether the token is expired at the time of the report.
+// - NotYetValid: Whether the token is issued or valid after the time of the report.
+// - ExpiresIn: The time left until the expiry, negative once expired, zero without expiry.
+// - Verified: Whether the signature was checked with a key and is valid.
+// - VerifyError: Why the signature is not valid, empty when it is or no key was given.
+type TokenReport struct {
+	Kind        string         `json:"kind"`
+	Algorithm   string         `json:"algorithm"`
+	KeyID       string         `json:"key_id,omitempty"`
+	Header      map[string]any `json:"header"`
+	Claims      map[string]any `json:"claims"`
+	Present     []string       `json:"present"`
+	Missing     []string       `json:"missing,omitempty"`
+	IssuedAt    time.Time      `json:"issued_at,omitzero"`
+	NotBefore   time.Time      `json:"not_before,omitzero"`
+	ExpiresAt   time.Time      `json:"expires_at,omitzero"`
+	AuthTime    time.Time      `json:"auth_time,omitzero"`
+	Expired     bool           `json:"expired"`
+	NotYetValid bool           `json:"not_yet_valid"`
+	ExpiresIn   time.Duration  `json:"expires_in"`
+	Verified    bool           `json:"verified"`
+	VerifyError string         `json:"verify_error,omitempty"`
+}
+
+// Debug decodes a token without its key and reports its header, claims and validity window.
+// The signature is not checked, see DebugWithKey.
+// Parameters:
+// - tokenString: The token, with or without the "Bearer " prefix.
+// Returns:
+// - TokenReport: The report of the token.
+// - error: An error if the token is malformed.
+func Debug(tokenString string) (TokenReport, error) {
+	return inspect(tokenString, nil, util.SystemClock.Now())
+}
+
+// DebugWithKey decodes a token like Debug and checks its signature with a key, whatever the
+// claims say: a token failing the check is reported with VerifyError, not an error.
+// Parameters:
+// - tokenString: The token, with or without the "Bearer " prefix.
+// - key: The HMAC secret for the HS algorithms, the PEM public or private key for RS256.
+// Returns:
+// - TokenReport: The report of the token.
+// - error: An error if the token is malformed.
+func DebugWithKey(tokenString string, key []byte) (TokenReport, error) {
+	return inspect(tokenString, key, util.SystemClock.Now())
+}
+
+// inspect builds the report of a token at a given time.
+// Parameters:
+// - tokenString: The token.
+// - key: The key the signature is checked with, not checked when empty.
+// - now: The time the validity window is checked against.
+// Returns:
+// - TokenReport: The report of the token.
+// - error: An error if the token is malformed.
+func inspect(tokenString string, key []byte, now time.Time) (TokenReport, error) {
+
+	tokenString = strings.TrimSpace(tokenString)
+	if scheme, t, ok := strings.Cut(tokenString, " "); ok && scheme == preTokenName {
+		tokenString = strings.TrimSpace(t)
+	}
+
+	parser := &jwt.Parser{UseJSONNumber: true, SkipClaimsValidation: true}
+	claims := jwt.MapClaims{}
+
+	token, _, err := parser.ParseUnverified(tokenString, claims)
+	if err != nil {
+		// the errors of the parser tell the malformed part, never its content
+		return TokenReport{}, fmt.Errorf("jwt: cannot decode the token: %w", err)
+	}
+
+	r := TokenReport{
+		Header: token.Header,
+		Claims: make(map[string]any, len(claims)),
+	}
+	r.Algorithm, _ = token.Header["alg"].(string)
+	r.KeyID, _ = token.Header["kid"].(string)
+
+	for name, value := range claims {
+		if slices.Contains(sensitiveClaims, name) {
+			value = redacted
+		}
+		r.Claims[name] = value
+	}
+
+	r.Kind = tokenKind(claims)
+	for _, name := range kindClaims[r.Kind] {
+		if _, ok := claims[name]; ok {
+			r.Present = append(r.Present, name)
+		} else {
+			r.Missing = append(r.Missing, name)
+		}
+	}
+	sort.Strings(r.Present)
+	sort.Strings(r.Missing)
+
+	r.IssuedAt = claimTime(claims, "iat")
+	r.NotBefore = claimTime(claims, "nbf")
+	r.ExpiresAt = claimTime(claims, "exp")
+	r.AuthTime = claimTime(claims, "auth_time")
+
+	if !r.ExpiresAt.IsZero() {
+		r.ExpiresIn = r.ExpiresAt.Sub(now)
+		r.Expired = !now.Before(r.ExpiresAt)
+	}
+	r.NotYetValid = r.IssuedAt.After(now) || r.NotBefore.After(now)
+
+	if len(key) > 0 {
+		if err = verifySignature(parser, tokenString, key); err != nil {
+			r.VerifyError = err.Error()
+		} else {
+			r.Verified = true
+		}
+	}
+
+	return r, nil
+}
+
+// tokenKind tells the kind of a token by the claims only its kind has.
+func tokenKind(claims jwt.MapClaims) string {
+	has := func(name string) bool {
+		_, ok := claims[name]
+		return ok
+	}
+
+	switch {
+	case has("action"):
+		return KindAction
+	case has("id") || has("role"):
+		return KindAccess
+	case has("csrf") && has("jti"):
+		return KindRefresh
+	}
+	return KindUnknown
+}
+
+// claimTime returns the time of a numeric date claim, the zero time when the token has none.
+func claimTime(claims jwt.MapClaims, name string) time.Time {
+	var seconds int64
+	switch v := claims[name].(type) {
+	case json.Number:
+		n, err := v.Float64()
+		if err != nil {
+			return time.Time{}
+		}
+		seconds = int64(n)
+	case float64:
+		seconds = int64(v)
+	default:
+		return time.Time{}
+	}
+	return unixTime(seconds)
+}
+
+// verifySignature checks the signature of a token with a key, whatever its claims say.
+// Parameters:
+// - parser: The parser skipping the validation of the claims.
+// - tokenString: The token.
+// - key: The HMAC secret, or the PEM key of the RSA algorithms.
+// Returns:
+// - error: Why the signature is not valid, without the signature.
+func verifySignature(parser *jwt.Parser, tokenString string, key []byte) error {
+
+	_, err := parser.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodHMAC:
+			return key, nil
+		case *jwt.SigningMethodRSA:
+			if public, err := jwt.ParseRSAPublicKeyFromPEM(key); err == nil {
+				return public, nil
+			}
+			private, err := jwt.ParseRSAPrivateKeyFromPEM(key)
+			if err != nil {
+				return nil, errors.New("the key is not a PEM RSA key")
+			}
+			return &private.PublicKey, nil
+		}
+		return nil, fmt.Errorf("the algorithm %v is not supported", token.Header["alg"])
+	})
+	if err == nil {
+		return nil
+	}
+
+	var ve *jwt.ValidationError
+	if errors.As(err, &ve) && ve.Inner != nil {
+		if errors.Is(ve.Inner, jwt.ErrSignatureInvalid) || errors.Is(ve.Inner, rsa.ErrVerification) {
+			return errors.New("the signature does not match the key")
+		}
+		return ve.Inner
+	}
+	return err
+}
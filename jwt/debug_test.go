@@ -0,0 +1,146 @@
+package jwt
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebug(t *testing.T) {
+	t.Chdir(t.TempDir())
+	ctx := context.Background()
+
+	tenants := NewStaticKeyResolver(map[string][]byte{"acme": []byte("acme-secret")})
+
+	hs256, err := NewHS256JWT(ctx, "secret", NewMemoryRepository(util.SystemClock), time.Hour, time.Minute, WithNotBefore(0))
+	require.NoError(t, err)
+	hs512, err := NewHS512JWT(ctx, "secret", NewMemoryRepository(util.SystemClock), time.Hour, time.Minute)
+	require.NoError(t, err)
+	multiTenant, err := NewMultiTenantHS256JWT(ctx, tenants, NewMemoryRepository(util.SystemClock), time.Hour, time.Minute)
+	require.NoError(t, err)
+	rs256, err := NewRS256JWT(ctx, "debug", NewMemoryRepository(util.SystemClock), time.Hour, time.Minute)
+	require.NoError(t, err)
+	publicKey, err := os.ReadFile(filepath.Join("assets", "keys", "debug.rsa.pub"))
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		token     Token
+		algorithm string
+		key       []byte
+	}{
+		"HS256":              {hs256, "HS256", []byte("secret")},
+		"HS512":              {hs512, "HS512", []byte("secret")},
+		"multi-tenant HS256": {multiTenant, "HS256", []byte("acme-secret")},
+		"RS256":              {rs256, "RS256", publicKey},
+	} {
+		t.Run(name, func(t *testing.T) {
+			access, refresh, csrf, expiresAt, err := tc.token.GenerateToken(ctx, "user-1", "admin", "sub-1", "acme")
+			require.NoError(t, err)
+
+			r, err := Debug("Bearer " + access)
+			require.NoError(t, err)
+			assert.Equal(t, KindAccess, r.Kind)
+			assert.Equal(t, tc.algorithm, r.Algorithm)
+			assert.Equal(t, []string{"csrf", "exp", "iat", "id", "role", "sub", "tenant"}, r.Present)
+			assert.Empty(t, r.Missing)
+			assert.Equal(t, "acme", r.Claims["tenant"])
+			assert.Equal(t, redacted, r.Claims["csrf"], "the CSRF secret renews the session")
+			assert.Equal(t, expiresAt, r.ExpiresAt.Unix())
+			assert.False(t, r.Expired)
+			assert.Positive(t, r.ExpiresIn)
+			assert.False(t, r.Verified, "no key, no verification")
+
+			r, err = DebugWithKey(access, tc.key)
+			require.NoError(t, err)
+			assert.True(t, r.Verified)
+			assert.Empty(t, r.VerifyError)
+
+			r, err = DebugWithKey(access, []byte("another secret"))
+			require.NoError(t, err)
+			assert.False(t, r.Verified)
+			assert.NotEmpty(t, r.VerifyError)
+
+			r, err = DebugWithKey(refresh, tc.key)
+			require.NoError(t, err)
+			assert.Equal(t, KindRefresh, r.Kind)
+			assert.Equal(t, []string{"csrf", "exp", "iat", "jti", "sub"}, r.Present)
+			assert.True(t, r.Verified)
+
+			out, err := json.Marshal(r)
+			require.NoError(t, err)
+			assert.NotContains(t, string(out), csrf)
+			assert.NotContains(t, string(out), refresh[strings.LastIndex(refresh, ".")+1:], "the signature is never reported")
+		})
+	}
+
+	t.Run("action token", func(t *testing.T) {
+		action, err := hs256.GenerateActionToken(ctx, "user-1", "", "verify_email", time.Hour, nil)
+		require.NoError(t, err)
+
+		r, err := DebugWithKey(action, []byte("secret"))
+		require.NoError(t, err)
+		assert.Equal(t, KindAction, r.Kind)
+		assert.Equal(t, "verify_email", r.Claims["action"])
+		assert.True(t, r.Verified)
+	})
+
+	t.Run("validity window", func(t *testing.T) {
+		access, _, _, _, err := hs256.GenerateToken(ctx, "user-1", "admin", "sub-1", "")
+		require.NoError(t, err)
+		now := time.Now()
+
+		r, err := inspect(access, []byte("secret"), now.Add(2*time.Minute))
+		require.NoError(t, err)
+		assert.True(t, r.Expired)
+		assert.Negative(t, r.ExpiresIn)
+		assert.True(t, r.Verified, "an expired token still has a valid signature")
+		assert.False(t, r.NotBefore.IsZero())
+
+		r, err = inspect(access, nil, now.Add(-time.Minute))
+		require.NoError(t, err)
+		assert.True(t, r.NotYetValid)
+		assert.False(t, r.Expired)
+	})
+}
+
+func TestDebugTampered(t *testing.T) {
+	token, err := NewHS256JWT(context.Background(), "secret", NewMemoryRepository(util.SystemClock), time.Hour, time.Minute)
+	require.NoError(t, err)
+
+	access, _, _, _, err := token.GenerateToken(context.Background(), "user-1", "user", "sub-1", "acme")
+	require.NoError(t, err)
+
+	// the role is raised to admin, the signature is kept
+	parts := strings.Split(access, ".")
+	claims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(t, err)
+	parts[1] = base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(claims), `"role":"user"`, `"role":"admin"`, 1)))
+	tampered := strings.Join(parts, ".")
+
+	r, err := Debug(tampered)
+	require.NoError(t, err, "the claims are decoded without key")
+	assert.Equal(t, "admin", r.Claims["role"])
+
+	r, err = DebugWithKey(tampered, []byte("secret"))
+	require.NoError(t, err)
+	assert.False(t, r.Verified)
+	assert.Equal(t, "the signature does not match the key", r.VerifyError)
+	assert.NotContains(t, r.VerifyError, parts[2])
+
+	t.Run("malformed", func(t *testing.T) {
+		_, err := Debug("not.a-token")
+		assert.ErrorContains(t, err, "cannot decode the token")
+
+		_, err = Debug(parts[0] + "." + parts[1])
+		assert.Error(t, err)
+	})
+}
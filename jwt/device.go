@@ -0,0 +1,149 @@
+package jwt
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+
+	"github.com/a-aslani/wotop/util"
+)
+
+// DeviceIDHeader is the header carrying the device ID generated by the client, read by
+// FingerprintFromRequest when no other header is given.
+const DeviceIDHeader = "X-Device-ID"
+
+// DeviceBindingRepository is a Repository storing the device fingerprint of the refresh
+// tokens, which binds them to the device that obtained them. RedisRepository implements it.
+type DeviceBindingRepository interface {
+	Repository
+
+	// StoreBoundRefreshToken stores a refresh token bound to a device.
+	// Parameters:
+	// - ctx: The context for the operation.
+	// - sub: The subject (user identifier) associated with the token.
+	// - jti: The unique identifier for the token.
+	// - fingerprint: The fingerprint of the device, an empty one stores an unbound token.
+	// Returns:
+	// - error: An error if the operation fails.
+	StoreBoundRefreshToken(ctx context.Context, sub, jti, fingerprint string) error
+
+	// FindRefreshTokenFingerprint retrieves the device fingerprint of a refresh token.
+	// Parameters:
+	// - ctx: The context for the operation.
+	// - jti: The unique identifier of the token.
+	// Returns:
+	// - string: The fingerprint, empty for the tokens not bound to a device.
+	// - error: An error if the token is not found or the operation fails.
+	FindRefreshTokenFingerprint(ctx context.Context, jti string) (string, error)
+}
+
+type fingerprintKey struct{}
+
+// WithFingerprint returns a copy of the context carrying the device fingerprint of the
+// caller. GenerateToken binds the refresh token to it, and RenewToken requires it to renew a
+// bound token.
+// Parameters:
+// - ctx: The parent context.
+// - fingerprint: The fingerprint, see Fingerprint.
+// Returns:
+// - context.Context: The context carrying the fingerprint.
+func WithFingerprint(ctx context.Context, fingerprint string) context.Context {
+	return context.WithValue(ctx, fingerprintKey{}, fingerprint)
+}
+
+// FingerprintFromContext returns the device fingerprint set by WithFingerprint.
+// Parameters:
+// - ctx: The context of the request.
+// Returns:
+// - string: The fingerprint, empty when the context carries none.
+func FingerprintFromContext(ctx context.Context) string {
+	fingerprint, _ := ctx.Value(fingerprintKey{}).(string)
+	return fingerprint
+}
+
+// Fingerprint hashes the user agent and the device ID of a client into a device fingerprint.
+// Parameters:
+// - userAgent: The User-Agent header of the client.
+// - deviceID: The ID generated by the client on its first start and kept since.
+// Returns:
+// - string: The hex encoded fingerprint, empty without a device ID.
+func Fingerprint(userAgent, deviceID string) string {
+	if deviceID == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(userAgent + "\x00" + deviceID))
+	return hex.EncodeToString(sum[:])
+}
+
+// FingerprintFromRequest returns the device fingerprint of a request, from its User-Agent
+// header and the device ID header.
+// Parameters:
+// - r: The HTTP request.
+// - header: The header carrying the device ID, DeviceIDHeader when empty.
+// Returns:
+// - string: The fingerprint, empty when the request has no device ID.
+func FingerprintFromRequest(r *http.Request, header string) string {
+	if header == "" {
+		header = DeviceIDHeader
+	}
+	return Fingerprint(r.UserAgent(), r.Header.Get(header))
+}
+
+// refreshTokenFingerprint returns the device fingerprint of a refresh token.
+// Parameters:
+// - ctx: The context for the operation.
+// - jti: The unique identifier of the refresh token.
+// Returns:
+// - string: The fingerprint, empty for the unbound tokens and the repositories without binding.
+// - error: An error if the operation fails.
+func (t *token) refreshTokenFingerprint(ctx context.Context, jti string) (string, error) {
+	binding, ok := t.repo.(DeviceBindingRepository)
+	if !ok {
+		return "", nil
+	}
+	return binding.FindRefreshTokenFingerprint(ctx, jti)
+}
+
+// checkDevice checks that a refresh token bound to a device is renewed by that device, the
+// fingerprint of the caller being carried by the context. On a mismatch the refresh token is
+// revoked, which revokes its family as each renewal replaces the refresh token it renews.
+// Tokens issued without a fingerprint are not checked.
+// Parameters:
+// - ctx: The context carrying the fingerprint of the caller.
+// - refreshTokenString: The refresh token string.
+// Returns:
+// - error: ErrDeviceMismatch if the token is bound to another device, or the repository error.
+func (t *token) checkDevice(ctx context.Context, refreshTokenString string) error {
+
+	// an invalid refresh token is rejected by the renewal
+	claims, err := t.verifyRefreshToken(refreshTokenString)
+	if err != nil {
+		return nil
+	}
+
+	// an unknown or already renewed refresh token is rejected by the renewal too, any other
+	// failure of the repository is returned rather than letting another device through
+	bound, err := t.refreshTokenFingerprint(ctx, claims.Id)
+	if errors.Is(err, ErrTokenAlreadyRefreshed) || errors.Is(err, ErrRefreshTokenNotFoundInDatabase) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if bound == "" {
+		return nil
+	}
+
+	if util.SecureCompare(FingerprintFromContext(ctx), bound) {
+		return nil
+	}
+
+	if err = t.deleteRefreshTokenFromDatabase(ctx, claims.Id); err != nil {
+		return err
+	}
+	t.refreshTokens.delete(claims.Id)
+
+	return ErrDeviceMismatch
+}
@@ -0,0 +1,199 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop/util"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newDeviceToken creates an HS256 Token on a fresh redis, returning the repository too.
+func newDeviceToken(t *testing.T, clock util.Clock) (Token, *RedisRepository) {
+	t.Helper()
+
+	repo := NewRedisRepository(redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()}))
+	token, err := NewHS256JWT(context.Background(), "secret", repo, 60*24*time.Hour, time.Minute, WithClock(clock))
+	require.NoError(t, err)
+	return token, repo
+}
+
+func TestRenewTokenBoundToDevice(t *testing.T) {
+	clock := util.NewFrozenClock(t0)
+	token, repo := newDeviceToken(t, clock)
+
+	phone := WithFingerprint(context.Background(), Fingerprint("app/1.0", "device-1"))
+	accessToken, refreshToken, csrf, _, err := token.GenerateToken(phone, "user-1", "admin", "user-1", "acme")
+	require.NoError(t, err)
+
+	jti, _ := refreshJTI(refreshToken)
+	fingerprint, err := repo.FindRefreshTokenFingerprint(phone, jti)
+	require.NoError(t, err)
+	assert.Equal(t, Fingerprint("app/1.0", "device-1"), fingerprint)
+
+	clock.Advance(2 * time.Minute)
+	accessToken, refreshToken, csrf, _, userID, err := token.RenewToken(phone, accessToken, refreshToken, csrf)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", userID)
+
+	// the renewed refresh token stays bound
+	jti, _ = refreshJTI(refreshToken)
+	fingerprint, err = repo.FindRefreshTokenFingerprint(phone, jti)
+	require.NoError(t, err)
+	assert.Equal(t, Fingerprint("app/1.0", "device-1"), fingerprint)
+
+	clock.Advance(2 * time.Minute)
+	_, _, _, _, _, err = token.RenewToken(phone, accessToken, refreshToken, csrf)
+	assert.NoError(t, err)
+}
+
+func TestRenewTokenFromAnotherDevice(t *testing.T) {
+	clock := util.NewFrozenClock(t0)
+	token, repo := newDeviceToken(t, clock)
+
+	phone := WithFingerprint(context.Background(), Fingerprint("app/1.0", "device-1"))
+	accessToken, refreshToken, csrf, _, err := token.GenerateToken(phone, "user-1", "admin", "user-1", "acme")
+	require.NoError(t, err)
+
+	clock.Advance(2 * time.Minute)
+
+	laptop := WithFingerprint(context.Background(), Fingerprint("app/1.0", "device-2"))
+	_, _, _, _, _, err = token.RenewToken(laptop, accessToken, refreshToken, csrf)
+	assert.ErrorIs(t, err, ErrDeviceMismatch)
+
+	// the family is revoked, the device that obtained it must log in again
+	jti, _ := refreshJTI(refreshToken)
+	_, err = repo.FindRefreshToken(phone, jti)
+	assert.ErrorIs(t, err, ErrTokenAlreadyRefreshed)
+
+	_, _, _, _, _, err = token.RenewToken(phone, accessToken, refreshToken, csrf)
+	assert.Error(t, err)
+}
+
+func TestRenewTokenWithoutFingerprint(t *testing.T) {
+	clock := util.NewFrozenClock(t0)
+	token, _ := newDeviceToken(t, clock)
+
+	phone := WithFingerprint(context.Background(), Fingerprint("app/1.0", "device-1"))
+	accessToken, refreshToken, csrf, _, err := token.GenerateToken(phone, "user-1", "admin", "user-1", "acme")
+	require.NoError(t, err)
+
+	clock.Advance(2 * time.Minute)
+	_, _, _, _, _, err = token.RenewToken(context.Background(), accessToken, refreshToken, csrf)
+	assert.ErrorIs(t, err, ErrDeviceMismatch)
+}
+
+func TestRenewLegacyTokenWithoutBinding(t *testing.T) {
+	clock := util.NewFrozenClock(t0)
+	token, repo := newDeviceToken(t, clock)
+	ctx := context.Background()
+
+	accessToken, refreshToken, csrf, _, err := token.GenerateToken(ctx, "user-1", "admin", "user-1", "acme")
+	require.NoError(t, err)
+
+	jti, _ := refreshJTI(refreshToken)
+	fingerprint, err := repo.FindRefreshTokenFingerprint(ctx, jti)
+	require.NoError(t, err)
+	assert.Empty(t, fingerprint)
+
+	// any device renews the tokens issued before the binding
+	clock.Advance(2 * time.Minute)
+	laptop := WithFingerprint(ctx, Fingerprint("app/1.0", "device-2"))
+	_, _, _, _, _, err = token.RenewToken(laptop, accessToken, refreshToken, csrf)
+	assert.NoError(t, err)
+}
+
+// failingBindingRepository is a RedisRepository whose fingerprints cannot be read.
+type failingBindingRepository struct {
+	*RedisRepository
+	err error
+}
+
+func (r failingBindingRepository) FindRefreshTokenFingerprint(context.Context, string) (string, error) {
+	return "", r.err
+}
+
+func TestRenewTokenWhenFingerprintCannotBeRead(t *testing.T) {
+	clock := util.NewFrozenClock(t0)
+	repo := failingBindingRepository{
+		RedisRepository: NewRedisRepository(redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})),
+		err:             errors.New("connection refused"),
+	}
+	token, err := NewHS256JWT(context.Background(), "secret", repo, 60*24*time.Hour, time.Minute, WithClock(clock))
+	require.NoError(t, err)
+
+	phone := WithFingerprint(context.Background(), Fingerprint("app/1.0", "device-1"))
+	accessToken, refreshToken, csrf, _, err := token.GenerateToken(phone, "user-1", "admin", "user-1", "acme")
+	require.NoError(t, err)
+
+	// the device is not known, so the renewal fails closed
+	clock.Advance(2 * time.Minute)
+	laptop := WithFingerprint(context.Background(), Fingerprint("app/1.0", "device-2"))
+	_, _, _, _, _, err = token.RenewToken(laptop, accessToken, refreshToken, csrf)
+	assert.ErrorContains(t, err, "connection refused")
+}
+
+func TestRedisRepositoryRefreshTokenEncoding(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	repo := NewRedisRepository(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+
+	// stored before the binding existed
+	require.NoError(t, mr.Set("refresh_token:legacy", "user-1"))
+	require.NoError(t, repo.StoreBoundRefreshToken(ctx, "user-2", "bound", "fp-2"))
+	require.NoError(t, repo.StoreBoundRefreshToken(ctx, "user-3", "unbound", ""))
+
+	value, err := mr.Get("refresh_token:unbound")
+	require.NoError(t, err)
+	assert.Equal(t, "user-3", value, "unbound tokens keep the legacy encoding")
+
+	sub, err := repo.FindRefreshToken(ctx, "bound")
+	require.NoError(t, err)
+	assert.Equal(t, "user-2", sub)
+
+	sub, err = repo.FindRefreshToken(ctx, "legacy")
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", sub)
+
+	tokens, err := repo.FindAllRefreshTokens(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []RefreshToken{
+		{Subject: "user-1", JTI: "legacy"},
+		{Subject: "user-2", JTI: "bound", Fingerprint: "fp-2"},
+		{Subject: "user-3", JTI: "unbound"},
+	}, tokens)
+
+	_, err = repo.FindRefreshTokenFingerprint(ctx, "missing")
+	assert.ErrorIs(t, err, ErrTokenAlreadyRefreshed)
+}
+
+func TestDeviceFingerprintMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var fingerprint string
+	r := gin.New()
+	r.Use(NewGinMiddleware(nopLogger{}).DeviceFingerprint(""))
+	r.POST("/login", func(c *gin.Context) {
+		fingerprint = FingerprintFromContext(c.Request.Context())
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/login", nil)
+	req.Header.Set("User-Agent", "app/1.0")
+	req.Header.Set(DeviceIDHeader, "device-1")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, Fingerprint("app/1.0", "device-1"), fingerprint)
+	assert.Len(t, fingerprint, 64)
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/login", nil))
+	assert.Empty(t, fingerprint, "no device ID, no binding")
+
+	assert.NotEqual(t, Fingerprint("app/1.0", "device-1"), Fingerprint("app/1.1", "device-1"))
+}
@@ -0,0 +1,42 @@
+package jwt
+
+import (
+	"net/http"
+
+	"github.com/a-aslani/wotop/logger"
+	"github.com/a-aslani/wotop/model/payload"
+	"github.com/labstack/echo/v4"
+)
+
+// EchoMiddleware returns an Echo middleware authenticating the requests like
+// GinMiddleware.Authentication: the claims and the caller are set in the Echo context under
+// the same keys, the identity and the claims are carried by the request context, and
+// requests without a valid token are answered with 401 and a payload.Response.
+//
+// Parameters:
+//   - jwt: An instance of the Token interface for verifying tokens.
+//   - log: An instance of the Logger interface for logging.
+//   - extractors: Where the access token is looked for, BearerHeader by default.
+//
+// Returns:
+//   - An Echo middleware for authentication.
+func EchoMiddleware(jwt Token, log logger.Logger, extractors ...TokenExtractor) echo.MiddlewareFunc {
+	auth := newAuthenticator(jwt, log, extractors)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx, claims, traceID, err := auth.authenticate(c.Request())
+			if err != nil {
+				return c.JSON(http.StatusUnauthorized, payload.NewErrorResponse(err, traceID))
+			}
+
+			c.Set("TokenClaims", claims)
+			c.Set("ID", claims.ID)
+			c.Set("Role", claims.Role)
+			c.Set("Tenant", claims.Tenant)
+			c.SetRequest(c.Request().WithContext(ctx))
+
+			return next(c)
+		}
+	}
+}
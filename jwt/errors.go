@@ -1,14 +1,63 @@
 package jwt
 
-import "github.com/a-aslani/wotop/model/apperror"
+import (
+	"net/http"
+
+	"github.com/a-aslani/wotop/model/apperror"
+)
 
 const (
-	ErrUnauthorized                   apperror.ErrorType = "ER0001 unauthorized"
-	ErrExpiredToken                   apperror.ErrorType = "ER0002 the token is expired"
-	ErrTokenAlreadyRefreshed          apperror.ErrorType = "ER0003 the token is already refreshed"
-	ErrRefreshTokenNotFoundInDatabase apperror.ErrorType = "ER0004 refresh token not found in database"
-	ErrReadingJWTClaims               apperror.ErrorType = "ER0005 error reading jwt claims"
-	ErrFetchingJWTClaims              apperror.ErrorType = "ER0006 error fetching claims"
-	ErrParsingRefreshTokenWithClaims  apperror.ErrorType = "ER0007 could not parse refresh token with claims"
-	ErrReadingRefreshTokenClaims      apperror.ErrorType = "ER0008 could not read refresh token claims"
+	ErrUnauthorized                   apperror.ErrorType = "ER0201 unauthorized"
+	ErrExpiredToken                   apperror.ErrorType = "ER0202 the token is expired"
+	ErrTokenAlreadyRefreshed          apperror.ErrorType = "ER0203 the token is already refreshed"
+	ErrRefreshTokenNotFoundInDatabase apperror.ErrorType = "ER0204 refresh token not found in database"
+	ErrReadingJWTClaims               apperror.ErrorType = "ER0205 error reading jwt claims"
+	ErrFetchingJWTClaims              apperror.ErrorType = "ER0206 error fetching claims"
+	ErrParsingRefreshTokenWithClaims  apperror.ErrorType = "ER0207 could not parse refresh token with claims"
+	ErrReadingRefreshTokenClaims      apperror.ErrorType = "ER0208 could not read refresh token claims"
+	ErrSessionExpired                 apperror.ErrorType = "ER0209 the session is expired"
+	ErrUnknownTenant                  apperror.ErrorType = "ER0210 no signing key for tenant %s"
+	ErrRateLimited                    apperror.ErrorType = "ER0211 too many attempts"
+	ErrForbidden                      apperror.ErrorType = "ER0212 the role %s is not allowed"
+	ErrDeviceMismatch                 apperror.ErrorType = "ER0213 the refresh token is bound to another device"
+	ErrWrongAction                    apperror.ErrorType = "ER0214 the token is not valid for the action %s"
+	ErrActionTokenExpired             apperror.ErrorType = "ER0215 the action token is expired"
+	ErrActionTokenUsed                apperror.ErrorType = "ER0216 the action token is already used"
+	ErrActionTokensNotSupported       apperror.ErrorType = "ER0217 the repository cannot store action tokens"
 )
+
+func init() {
+	apperror.Register("jwt",
+		apperror.Entry{Err: ErrUnauthorized, Description: "The access token is missing, malformed or invalid."},
+		apperror.Entry{Err: ErrExpiredToken, Description: "The token is expired, refresh it."},
+		apperror.Entry{Err: ErrTokenAlreadyRefreshed, Description: "The refresh token has been used already."},
+		apperror.Entry{Err: ErrRefreshTokenNotFoundInDatabase, Description: "The refresh token is unknown or revoked."},
+		apperror.Entry{Err: ErrReadingJWTClaims, Description: "The claims of the access token cannot be read."},
+		apperror.Entry{Err: ErrFetchingJWTClaims, Description: "The claims of the access token cannot be fetched."},
+		apperror.Entry{Err: ErrParsingRefreshTokenWithClaims, Description: "The refresh token cannot be parsed."},
+		apperror.Entry{Err: ErrReadingRefreshTokenClaims, Description: "The claims of the refresh token cannot be read."},
+		apperror.Entry{Err: ErrSessionExpired, Description: "The session is older than its maximum age, log in again."},
+		apperror.Entry{Err: ErrUnknownTenant, Description: "The tenant of the token has no signing key."},
+		apperror.Entry{Err: ErrForbidden, Description: "The role of the caller is not allowed to call the endpoint."},
+		apperror.Entry{Err: ErrRateLimited, Description: "Too many tokens are requested, retry after the Retry-After header."},
+		apperror.Entry{Err: ErrDeviceMismatch, Description: "The refresh token was obtained by another device, it is revoked, log in again."},
+		apperror.Entry{Err: ErrWrongAction, Description: "The action token was issued for another action, e.g. a password reset token used to verify an email."},
+		apperror.Entry{Err: ErrActionTokenExpired, Description: "The action token is expired, request a new one."},
+		apperror.Entry{Err: ErrActionTokenUsed, Description: "The action token can be used once and has been used already."},
+		apperror.Entry{Err: ErrActionTokensNotSupported, Description: "The repository of the tokens is not an ActionTokenRepository."},
+	)
+
+	apperror.MapCode(ErrUnauthorized.Code(), http.StatusUnauthorized)
+	apperror.MapCode(ErrExpiredToken.Code(), http.StatusUnauthorized)
+	apperror.MapCode(ErrTokenAlreadyRefreshed.Code(), http.StatusUnauthorized)
+	apperror.MapCode(ErrRefreshTokenNotFoundInDatabase.Code(), http.StatusUnauthorized)
+	apperror.MapCode(ErrParsingRefreshTokenWithClaims.Code(), http.StatusUnauthorized)
+	apperror.MapCode(ErrReadingRefreshTokenClaims.Code(), http.StatusUnauthorized)
+	apperror.MapCode(ErrSessionExpired.Code(), http.StatusUnauthorized)
+	apperror.MapCode(ErrRateLimited.Code(), http.StatusTooManyRequests)
+	apperror.MapCode(ErrForbidden.Code(), http.StatusForbidden)
+	apperror.MapCode(ErrDeviceMismatch.Code(), http.StatusUnauthorized)
+	apperror.MapCode(ErrWrongAction.Code(), http.StatusBadRequest)
+	apperror.MapCode(ErrActionTokenExpired.Code(), http.StatusGone)
+	apperror.MapCode(ErrActionTokenUsed.Code(), http.StatusGone)
+}
@@ -0,0 +1,28 @@
+package jwt
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/a-aslani/wotop/model/payload"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorStatus(t *testing.T) {
+	for err, status := range map[error]int{
+		ErrUnauthorized:                   http.StatusUnauthorized,
+		ErrExpiredToken:                   http.StatusUnauthorized,
+		ErrTokenAlreadyRefreshed:          http.StatusUnauthorized,
+		ErrRefreshTokenNotFoundInDatabase: http.StatusUnauthorized,
+		ErrSessionExpired:                 http.StatusUnauthorized,
+		ErrDeviceMismatch:                 http.StatusUnauthorized,
+		ErrRateLimited:                    http.StatusTooManyRequests,
+		ErrForbidden.Var("admin"):         http.StatusForbidden,
+		ErrWrongAction.Var("reset"):       http.StatusBadRequest,
+		ErrActionTokenExpired:             http.StatusGone,
+		fmt.Errorf("refresh: %w", ErrTokenAlreadyRefreshed): http.StatusUnauthorized,
+	} {
+		assert.Equal(t, status, payload.ErrorStatus(err), err.Error())
+	}
+}
@@ -1,13 +1,15 @@
 package jwt
 
 import (
-	"context"
+	"errors"
+	"github.com/a-aslani/wotop"
 	"github.com/a-aslani/wotop/logger"
 	"github.com/a-aslani/wotop/model/payload"
-	"github.com/a-aslani/wotop/util"
 	"github.com/gin-gonic/gin"
+	"math"
 	"net/http"
-	"strings"
+	"slices"
+	"strconv"
 )
 
 // GinMiddleware provides middleware functionality for handling Token authentication
@@ -15,19 +17,22 @@ import (
 //
 // Fields:
 //   - log: An instance of the Logger interface for logging messages.
+//   - extractors: Where the access token is looked for, BearerHeader by default.
 type GinMiddleware struct {
-	log logger.Logger
+	log        logger.Logger
+	extractors []TokenExtractor
 }
 
 // NewGinMiddleware creates a new instance of GinMiddleware.
 //
 // Parameters:
 //   - log: An instance of the Logger interface for logging.
+//   - extractors: Where the access token is looked for, e.g. Cookie, BearerHeader by default.
 //
 // Returns:
 //   - A new GinMiddleware instance.
-func NewGinMiddleware(log logger.Logger) GinMiddleware {
-	return GinMiddleware{log: log}
+func NewGinMiddleware(log logger.Logger, extractors ...TokenExtractor) GinMiddleware {
+	return GinMiddleware{log: log, extractors: extractors}
 }
 
 // GetAccessTokenFromHeader extracts the access token from the "Authorization" header.
@@ -42,33 +47,16 @@ func NewGinMiddleware(log logger.Logger) GinMiddleware {
 //   - token: The extracted access token.
 //   - err: An error if the token cannot be extracted.
 func (g GinMiddleware) GetAccessTokenFromHeader(c *gin.Context) (token string, err error) {
-
-	if c.Request.Header["Authorization"] == nil || len(c.Request.Header["Authorization"]) == 0 {
-		err = ErrUnauthorized
-		return
-	}
-
-	authorization := strings.Split(c.Request.Header["Authorization"][0], " ")
-	token = authorization[1]
-
-	if authorization[0] != preTokenName {
-		err = ErrUnauthorized
-		return
-	}
-
-	if token == "" {
-		err = ErrUnauthorized
-		return
-	}
-
-	return
+	return BearerHeader()(c.Request)
 }
 
 // Authentication is a middleware function for authenticating requests using Token.
 //
-// This middleware extracts the access token from the "Authorization" header,
-// verifies the token, and sets the token claims in the Gin context. If the token
-// is invalid or missing, the request is aborted with a 401 Unauthorized response.
+// This middleware extracts the access token with the extractors of the middleware,
+// verifies the token, and sets the token claims in the Gin context, and the identity of
+// the caller and the claims in the request context, see wotop.IdentityFromContext and
+// ClaimsFromContext. If the token is invalid or missing, the request is aborted with a
+// 401 Unauthorized response. HTTPMiddleware and EchoMiddleware share its logic.
 //
 // Parameters:
 //   - jwt: An instance of the Token interface for verifying tokens.
@@ -76,26 +64,12 @@ func (g GinMiddleware) GetAccessTokenFromHeader(c *gin.Context) (token string, e
 // Returns:
 //   - A Gin handler function for authentication.
 func (g GinMiddleware) Authentication(jwt Token) gin.HandlerFunc {
+	auth := newAuthenticator(jwt, g.log, g.extractors)
 
 	return func(c *gin.Context) {
 
-		// Generate a unique trace ID for the request.
-		traceID := util.GenerateID(16)
-		ctx := logger.SetTraceID(context.Background(), traceID)
-
-		// Extract the access token from the header.
-		token, err := g.GetAccessTokenFromHeader(c)
-		if err != nil {
-			g.log.Error(ctx, err.Error())
-			c.JSON(http.StatusUnauthorized, payload.NewErrorResponse(err, traceID))
-			c.Abort()
-			return
-		}
-
-		// Verify the token and extract claims.
-		_, tokenClaims, err := jwt.VerifyToken(token)
+		ctx, tokenClaims, traceID, err := auth.authenticate(c.Request)
 		if err != nil {
-			g.log.Error(ctx, err.Error())
 			c.JSON(http.StatusUnauthorized, payload.NewErrorResponse(err, traceID))
 			c.Abort()
 			return
@@ -105,8 +79,90 @@ func (g GinMiddleware) Authentication(jwt Token) gin.HandlerFunc {
 		c.Set("TokenClaims", tokenClaims)
 		c.Set("ID", tokenClaims.ID)
 		c.Set("Role", tokenClaims.Role)
+		c.Set("Tenant", tokenClaims.Tenant)
+
+		c.Request = c.Request.WithContext(ctx)
 
 		// Proceed to the next middleware or handler.
 		c.Next()
 	}
 }
+
+// RequireRole is a middleware function letting through the requests authenticated with one
+// of the roles, it follows Authentication which sets the role of the caller. The other
+// requests are aborted with a 403 Forbidden response.
+//
+// Parameters:
+//   - roles: The allowed roles, e.g. "admin".
+//
+// Returns:
+//   - A Gin handler function for authorization.
+func (g GinMiddleware) RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		role := c.GetString("Role")
+		if !slices.Contains(roles, role) {
+			traceID, _ := wotop.TraceIDFromContext(c.Request.Context())
+			payload.WriteError(c, ErrForbidden.Var(role), traceID)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// DeviceFingerprint is a middleware function carrying the device fingerprint of the caller,
+// computed by FingerprintFromRequest, in the request context, so the login and refresh
+// handlers bind the refresh tokens to the device with GenerateToken and check it with
+// RenewToken. Requests without a device ID are left unbound.
+//
+// Parameters:
+//   - header: The header carrying the device ID, DeviceIDHeader when empty.
+//
+// Returns:
+//   - A Gin handler function setting the fingerprint.
+func (g GinMiddleware) DeviceFingerprint(header string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		if fingerprint := FingerprintFromRequest(c.Request, header); fingerprint != "" {
+			c.Request = c.Request.WithContext(WithFingerprint(c.Request.Context(), fingerprint))
+		}
+
+		c.Next()
+	}
+}
+
+// RateLimitErrors answers the *RateLimitError reported by the handlers with c.Error, e.g. by
+// the login and refresh handlers calling GenerateToken and RenewToken, with 429 Too Many
+// Requests and a Retry-After header, unless the handler wrote its response already.
+//
+// Returns:
+//   - A Gin handler function answering the throttled requests.
+func (g GinMiddleware) RateLimitErrors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		c.Next()
+
+		if c.Writer.Written() {
+			return
+		}
+
+		for _, e := range c.Errors {
+			var rle *RateLimitError
+			if !errors.As(e.Err, &rle) {
+				continue
+			}
+
+			traceID, _ := wotop.TraceIDFromContext(c.Request.Context())
+			c.Header("Retry-After", retryAfter(rle))
+			payload.WriteError(c, rle, traceID)
+			return
+		}
+	}
+}
+
+// retryAfter returns the Retry-After header of the error, in whole seconds rounded up.
+func retryAfter(err *RateLimitError) string {
+	return strconv.Itoa(int(math.Max(1, math.Ceil(err.RetryAfter.Seconds()))))
+}
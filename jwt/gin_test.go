@@ -0,0 +1,132 @@
+package jwt
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/logger"
+	"github.com/a-aslani/wotop/util"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// nopLogger discards the messages of the middleware.
+type nopLogger struct{}
+
+func (nopLogger) Info(context.Context, string, ...any)    {}
+func (nopLogger) Error(context.Context, string, ...any)   {}
+func (nopLogger) Warning(context.Context, string, ...any) {}
+
+// whoAmI is an interactor reading the caller from its context only.
+type whoAmI struct{}
+
+func (whoAmI) Execute(ctx context.Context, _ struct{}) (*wotop.RequestContext, error) {
+	rc := wotop.RequestContextFrom(ctx)
+	return &rc, nil
+}
+
+func TestAuthenticationPopulatesRequestContext(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ctx := context.Background()
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+
+	token, err := NewHS256JWT(ctx, "secret", NewRedisRepository(rdb), time.Hour, time.Minute)
+	require.NoError(t, err)
+
+	accessToken, _, _, _, err := token.GenerateToken(ctx, "user-1", "admin", "user-1", "acme")
+	require.NoError(t, err)
+
+	var inport wotop.Inport[struct{}, wotop.RequestContext] = whoAmI{}
+
+	var got *wotop.RequestContext
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(logger.SetTraceID(c.Request.Context(), "trace-1"))
+	})
+	router.GET("/me", NewGinMiddleware(nopLogger{}).Authentication(token), func(c *gin.Context) {
+		got, err = inport.Execute(c.Request.Context(), struct{}{})
+		assert.Equal(t, "acme", c.GetString("Tenant"))
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, err)
+	assert.Equal(t, &wotop.RequestContext{
+		Identity:      wotop.Identity{ID: "user-1", Role: "admin", Tenant: "acme"},
+		Authenticated: true,
+		TraceID:       "trace-1",
+	}, got)
+}
+
+func TestAuthenticationRejectsMissingToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/me", NewGinMiddleware(nopLogger{}).Authentication(nil), func(c *gin.Context) {
+		t.Error("handler must not be called")
+	})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/me", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestTokenExpiresOnClock(t *testing.T) {
+	ctx := context.Background()
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	clock := util.NewFrozenClock(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+
+	token, err := NewHS256JWT(ctx, "secret", NewRedisRepository(rdb), time.Hour, time.Minute, WithClock(clock))
+	require.NoError(t, err)
+
+	accessToken, _, _, expiresAt, err := token.GenerateToken(ctx, "user-1", "admin", "user-1", "acme")
+	require.NoError(t, err)
+	assert.Equal(t, clock.Now().Add(time.Minute).Unix(), expiresAt)
+
+	_, claims, err := token.VerifyToken(accessToken)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.ID)
+
+	clock.Advance(2 * time.Minute)
+
+	_, _, err = token.VerifyToken(accessToken)
+	assert.ErrorIs(t, err, ErrExpiredToken)
+}
+
+func TestRequireRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mw := NewGinMiddleware(nopLogger{})
+	router := gin.New()
+	router.GET("/admin/log-level", func(c *gin.Context) {
+		// stands for Authentication
+		c.Set("Role", c.GetHeader("X-Role"))
+	}, mw.RequireRole("admin", "ops"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for role, status := range map[string]int{"admin": http.StatusOK, "ops": http.StatusOK, "customer": http.StatusForbidden, "": http.StatusForbidden} {
+		req := httptest.NewRequest(http.MethodGet, "/admin/log-level", nil)
+		req.Header.Set("X-Role", role)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, status, rec.Code, "role %q", role)
+		if status == http.StatusForbidden {
+			assert.Contains(t, rec.Body.String(), `"error_code":"ER0212"`)
+		}
+	}
+}
@@ -14,6 +14,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/a-aslani/wotop/logger"
+	"github.com/a-aslani/wotop/util"
 	"github.com/golang-jwt/jwt"
 )
 
@@ -25,35 +27,168 @@ const (
 var (
 	verifyKey     *rsa.PublicKey
 	signKey       *rsa.PrivateKey
-	refreshTokens map[string]string
 	blockedTokens []string
 	preTokenName  = "Bearer"
 )
 
 type Claims struct {
-	ID     string `json:"id"`
-	Csrf   string `json:"csrf"`
-	Role   string `json:"role"`
-	Tenant string `json:"tenant"`
+	ID       string `json:"id"`
+	Csrf     string `json:"csrf"`
+	Role     string `json:"role"`
+	Tenant   string `json:"tenant"`
+	AuthTime int64  `json:"auth_time,omitempty"` // when the user logged in, kept when the token is renewed
 	jwt.StandardClaims
 }
 
+// IssuedAtTime returns the time the token was issued.
+// Returns:
+// - time.Time: The iat claim, the zero time when the token has none.
+func (c *Claims) IssuedAtTime() time.Time {
+	return unixTime(c.IssuedAt)
+}
+
+// NotBeforeTime returns the time the token becomes valid.
+// Returns:
+// - time.Time: The nbf claim, the zero time when the token has none.
+func (c *Claims) NotBeforeTime() time.Time {
+	return unixTime(c.NotBefore)
+}
+
+// AuthenticatedAt returns the time the user logged in, which is kept when the token is
+// renewed, so the age of the session can be checked.
+// Returns:
+// - time.Time: The auth_time claim, the zero time for tokens issued without it.
+func (c *Claims) AuthenticatedAt() time.Time {
+	return unixTime(c.AuthTime)
+}
+
 type RefreshTokenClaims struct {
-	Csrf string `json:"csrf"`
+	Csrf   string `json:"csrf"`
+	Tenant string `json:"tenant,omitempty"`
 	jwt.StandardClaims
 }
 
 type RefreshToken struct {
-	Subject string `json:"subject" bson:"subject"`
-	JTI     string `json:"jti" bson:"jti"`
+	Subject     string `json:"subject" bson:"subject"`
+	JTI         string `json:"jti" bson:"jti"`
+	Fingerprint string `json:"fingerprint,omitempty" bson:"fingerprint,omitempty"` // the device the token is bound to, see DeviceBindingRepository
 }
 
 type token struct {
 	algorithm             jwt.SigningMethod
 	secretKey             string
+	keys                  KeyResolver // the keys of the tenants, secretKey is used when it is nil
 	refreshTokenValidTime time.Duration
 	accessTokenValidTime  time.Duration
 	repo                  Repository
+	clock                 util.Clock
+	leeway                time.Duration
+	notBefore             *time.Duration
+	maxSessionAge         time.Duration
+	limiters              map[Operation]RateLimiter // the limiters of the throttled operations, see WithRateLimiter
+	refreshTokens         *refreshTokenCache        // the refresh tokens not revoked, see initCachedRefreshTokens
+	warmUpPageSize        int                       // the page size of the warm-up, see WithWarmUp
+	warmUpLimit           int                       // the number of tokens cached by the warm-up, no limit when zero
+	lazyCacheSize         int                       // the size of the lazy cache, the cache is warmed up when zero
+	log                   logger.Logger             // logs the warm-up progress, optional
+}
+
+// Option configures a Token created by NewHS256JWT, NewHS512JWT or NewRS256JWT.
+type Option func(*token)
+
+// WithClock sets the clock used to stamp and check the expiry of the tokens.
+// Parameters:
+// - clock: The clock, util.SystemClock by default.
+// Returns:
+// - Option: The option setting the clock.
+func WithClock(clock util.Clock) Option {
+	return func(t *token) {
+		t.clock = clock
+	}
+}
+
+// WithLeeway sets the clock skew tolerated when the expiry, issue and not-before times of
+// a token are checked.
+// Parameters:
+// - leeway: The tolerated skew, none by default.
+// Returns:
+// - Option: The option setting the leeway.
+func WithLeeway(leeway time.Duration) Option {
+	return func(t *token) {
+		t.leeway = leeway
+	}
+}
+
+// WithNotBefore stamps the tokens with a not-before (nbf) time, offset from their issue
+// time, which VerifyToken enforces.
+// Parameters:
+// - offset: The offset of nbf from iat, zero makes the tokens valid from their issue.
+// Returns:
+// - Option: The option enabling nbf.
+func WithNotBefore(offset time.Duration) Option {
+	return func(t *token) {
+		t.notBefore = &offset
+	}
+}
+
+// WithMaxSessionAge forces the user to log in again once the session is older than
+// maxAge, however often its tokens are renewed: RenewToken returns ErrSessionExpired.
+// Parameters:
+// - maxAge: The maximum age of a session, no limit by default.
+// Returns:
+// - Option: The option setting the maximum session age.
+func WithMaxSessionAge(maxAge time.Duration) Option {
+	return func(t *token) {
+		t.maxSessionAge = maxAge
+	}
+}
+
+// WithWarmUp sets how the cache of the refresh tokens is loaded when the Token is created:
+// page by page when the repository is a PagedRepository, at once otherwise.
+// Parameters:
+// - pageSize: The number of tokens read per page, DefaultWarmUpPageSize when zero.
+// - limit: The number of tokens cached, no limit when zero, past it the others are read from the repository.
+// Returns:
+// - Option: The option setting the warm-up.
+func WithWarmUp(pageSize, limit int) Option {
+	return func(t *token) {
+		t.warmUpPageSize = pageSize
+		t.warmUpLimit = limit
+	}
+}
+
+// WithLazyRefreshTokens skips the warm-up of the cache of the refresh tokens, so the Token is
+// created at once however many sessions there are. A refresh token is read from the repository
+// when it is first renewed, the most recently used ones are cached.
+// Parameters:
+// - size: The number of tokens cached, DefaultLazyCacheSize when zero.
+// Returns:
+// - Option: The option enabling the lazy cache.
+func WithLazyRefreshTokens(size int) Option {
+	return func(t *token) {
+		if size <= 0 {
+			size = DefaultLazyCacheSize
+		}
+		t.lazyCacheSize = size
+	}
+}
+
+// WithLogger sets the logger reporting the progress of the warm-up of the cache.
+// Parameters:
+// - log: The logger, the warm-up is not logged by default.
+// Returns:
+// - Option: The option setting the logger.
+func WithLogger(log logger.Logger) Option {
+	return func(t *token) {
+		t.log = log
+	}
+}
+
+// timeClaims is implemented by the claims carrying jwt.StandardClaims.
+type timeClaims interface {
+	VerifyExpiresAt(cmp int64, req bool) bool
+	VerifyIssuedAt(cmp int64, req bool) bool
+	VerifyNotBefore(cmp int64, req bool) bool
 }
 
 // Repository defines the interface for interacting with the token storage system.
@@ -127,7 +262,8 @@ type Token interface {
 	// - refreshToken: The generated refresh token.
 	// - csrfSecret: The generated CSRF secret.
 	// - expiresAt: The expiration time of the access token (in Unix timestamp).
-	// - error: An error if the operation fails.
+	// - error: An error if the operation fails, a *RateLimitError if the subject is throttled, see WithRateLimiter.
+	// The refresh token is bound to the device whose fingerprint is carried by ctx, see WithFingerprint.
 	GenerateToken(ctx context.Context, userId string, role string, sub string, tenant string) (accessToken, refreshToken, csrfSecret string, expiresAt int64, err error)
 
 	// GenerateCentrifugoJWT generates a JWT for Centrifugo.
@@ -151,7 +287,8 @@ type Token interface {
 	// - newCsrfSecret: The new CSRF secret.
 	// - expiresAt: The expiration time of the new access token (in Unix timestamp).
 	// - userId: The user ID associated with the token.
-	// - error: An error if the operation fails.
+	// - error: An error if the operation fails, a *RateLimitError if the refresh token is throttled, see WithRateLimiter,
+	//   ErrDeviceMismatch if the refresh token is bound to another device than the one of ctx, see WithFingerprint.
 	RenewToken(ctx context.Context, oldAccessTokenString string, oldRefreshTokenString, oldCsrfSecret string) (newAccessToken, newRefreshToken, newCsrfSecret string, expiresAt int64, userId string, err error)
 
 	// DeleteToken deletes an access token and its associated refresh token.
@@ -171,6 +308,56 @@ type Token interface {
 	// - *Claims: The claims extracted from the token.
 	// - error: An error if the token is invalid or verification fails.
 	VerifyToken(token string) (string, *Claims, error)
+
+	// VerifyTokenAt verifies the validity of an access token at a given time, e.g. to re-check
+	// the token of a long-lived connection without a request.
+	// Parameters:
+	// - token: The access token to be verified.
+	// - at: The time the expiry of the token is checked against.
+	// Returns:
+	// - string: The token string if valid.
+	// - *Claims: The claims extracted from the token.
+	// - error: ErrExpiredToken if the token is expired at that time, ErrUnauthorized if it is invalid.
+	VerifyTokenAt(token string, at time.Time) (string, *Claims, error)
+
+	// ValidateForConnectionRefresh checks the tokens of a long-lived connection, such as a
+	// WebSocket, and renews them silently with the refresh token when the access token expires.
+	// Parameters:
+	// - ctx: The context for the operation.
+	// - accessToken: The access token of the connection.
+	// - refreshToken: The refresh token of the connection, may be empty.
+	// - csrf: The CSRF secret of the access token.
+	// Returns:
+	// - *ConnectionRefresh: The tokens the connection carries on with and their expiry.
+	// - error: An error if the connection must be closed, ErrExpiredToken, ErrUnauthorized or ErrSessionExpired.
+	ValidateForConnectionRefresh(ctx context.Context, accessToken, refreshToken, csrf string) (*ConnectionRefresh, error)
+
+	// GenerateActionToken generates a short-lived token for a single action, e.g. the link of an
+	// email verification or of a password reset. Its ID is stored in the repository until it is
+	// consumed or expires.
+	// Parameters:
+	// - ctx: The context for the operation.
+	// - sub: The subject (user identifier) the action is for.
+	// - tenant: The tenant of the subject, which selects the signing key of a multi-tenant Token.
+	// - action: The action, e.g. "verify_email".
+	// - ttl: The validity duration of the token.
+	// - payload: The data of the action, e.g. the email to verify, may be nil.
+	// Returns:
+	// - string: The signed token.
+	// - error: ErrActionTokensNotSupported if the repository is not an ActionTokenRepository.
+	GenerateActionToken(ctx context.Context, sub, tenant, action string, ttl time.Duration, payload map[string]any) (string, error)
+
+	// ConsumeActionToken verifies an action token and consumes it, atomically, so it is accepted
+	// once even when it is presented concurrently.
+	// Parameters:
+	// - ctx: The context for the operation.
+	// - token: The action token.
+	// - expectedAction: The action the token must have been issued for, it is not consumed otherwise.
+	// Returns:
+	// - *ActionClaims: The claims of the token.
+	// - error: ErrWrongAction, ErrActionTokenExpired or ErrActionTokenUsed, ErrUnauthorized if the
+	//   token is invalid.
+	ConsumeActionToken(ctx context.Context, token, expectedAction string) (*ActionClaims, error)
 }
 
 // NewHS256JWT creates a new JWT token instance using the HS256 signing method.
@@ -180,10 +367,11 @@ type Token interface {
 // - repo: The repository interface for token storage operations.
 // - refreshTokenValidTime: The validity duration for refresh tokens.
 // - accessTokenValidTime: The validity duration for access tokens.
+// - opts: Options such as WithClock.
 // Returns:
 // - Token: The created JWT token instance.
 // - error: An error if the operation fails.
-func NewHS256JWT(ctx context.Context, secretKey string, repo Repository, refreshTokenValidTime time.Duration, accessTokenValidTime time.Duration) (Token, error) {
+func NewHS256JWT(ctx context.Context, secretKey string, repo Repository, refreshTokenValidTime time.Duration, accessTokenValidTime time.Duration, opts ...Option) (Token, error) {
 
 	jwtToken := &token{
 		algorithm:             jwt.SigningMethodHS256,
@@ -191,6 +379,10 @@ func NewHS256JWT(ctx context.Context, secretKey string, repo Repository, refresh
 		refreshTokenValidTime: refreshTokenValidTime,
 		accessTokenValidTime:  accessTokenValidTime,
 		repo:                  repo,
+		clock:                 util.SystemClock,
+	}
+	for _, opt := range opts {
+		opt(jwtToken)
 	}
 
 	err := jwtToken.initCachedRefreshTokens(ctx)
@@ -213,10 +405,11 @@ func NewHS256JWT(ctx context.Context, secretKey string, repo Repository, refresh
 // - repo: The repository interface for token storage operations.
 // - refreshTokenValidTime: The validity duration for refresh tokens.
 // - accessTokenValidTime: The validity duration for access tokens.
+// - opts: Options such as WithClock.
 // Returns:
 // - Token: The created JWT token instance.
 // - error: An error if the operation fails.
-func NewHS512JWT(ctx context.Context, secretKey string, repo Repository, refreshTokenValidTime time.Duration, accessTokenValidTime time.Duration) (Token, error) {
+func NewHS512JWT(ctx context.Context, secretKey string, repo Repository, refreshTokenValidTime time.Duration, accessTokenValidTime time.Duration, opts ...Option) (Token, error) {
 
 	jwtToken := &token{
 		algorithm:             jwt.SigningMethodHS512,
@@ -224,6 +417,51 @@ func NewHS512JWT(ctx context.Context, secretKey string, repo Repository, refresh
 		refreshTokenValidTime: refreshTokenValidTime,
 		accessTokenValidTime:  accessTokenValidTime,
 		repo:                  repo,
+		clock:                 util.SystemClock,
+	}
+	for _, opt := range opts {
+		opt(jwtToken)
+	}
+
+	err := jwtToken.initCachedRefreshTokens(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = jwtToken.initCachedBlockedTokens(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return jwtToken, nil
+}
+
+// NewMultiTenantHS256JWT creates a JWT token instance using the HS256 signing method with
+// a key per tenant. GenerateToken signs the tokens with the key of its tenant argument,
+// which is stamped in the claims, and VerifyToken verifies them with the key of the tenant
+// they claim.
+// Parameters:
+// - ctx: The context for the operation.
+// - keys: The resolver of the keys of the tenants, e.g. NewStaticKeyResolver.
+// - repo: The repository interface for token storage operations.
+// - refreshTokenValidTime: The validity duration for refresh tokens.
+// - accessTokenValidTime: The validity duration for access tokens.
+// - opts: Options such as WithClock.
+// Returns:
+// - Token: The created JWT token instance.
+// - error: An error if the operation fails.
+func NewMultiTenantHS256JWT(ctx context.Context, keys KeyResolver, repo Repository, refreshTokenValidTime time.Duration, accessTokenValidTime time.Duration, opts ...Option) (Token, error) {
+
+	jwtToken := &token{
+		algorithm:             jwt.SigningMethodHS256,
+		keys:                  keys,
+		refreshTokenValidTime: refreshTokenValidTime,
+		accessTokenValidTime:  accessTokenValidTime,
+		repo:                  repo,
+		clock:                 util.SystemClock,
+	}
+	for _, opt := range opts {
+		opt(jwtToken)
 	}
 
 	err := jwtToken.initCachedRefreshTokens(ctx)
@@ -246,10 +484,11 @@ func NewHS512JWT(ctx context.Context, secretKey string, repo Repository, refresh
 // - repo: The repository interface for token storage operations.
 // - refreshTokenValidTime: The validity duration for refresh tokens.
 // - accessTokenValidTime: The validity duration for access tokens.
+// - opts: Options such as WithClock.
 // Returns:
 // - Token: The created JWT token instance.
 // - error: An error if the operation fails.
-func NewRS256JWT(ctx context.Context, fileName string, repo Repository, refreshTokenValidTime time.Duration, accessTokenValidTime time.Duration) (Token, error) {
+func NewRS256JWT(ctx context.Context, fileName string, repo Repository, refreshTokenValidTime time.Duration, accessTokenValidTime time.Duration, opts ...Option) (Token, error) {
 
 	err := initRS256JWT(fileName)
 	if err != nil {
@@ -261,6 +500,10 @@ func NewRS256JWT(ctx context.Context, fileName string, repo Repository, refreshT
 		refreshTokenValidTime: refreshTokenValidTime,
 		accessTokenValidTime:  accessTokenValidTime,
 		repo:                  repo,
+		clock:                 util.SystemClock,
+	}
+	for _, opt := range opts {
+		opt(jwtToken)
 	}
 
 	err = jwtToken.initCachedRefreshTokens(ctx)
@@ -449,24 +692,84 @@ func (t *token) findAllBlockedTokensFromDatabase(ctx context.Context) ([]string,
 }
 
 // initCachedRefreshTokens initializes the cache for refresh tokens by loading them from the database.
+// A PagedRepository is read page by page, the other repositories at once. The cache is not
+// loaded in the lazy mode, see WithLazyRefreshTokens, and is loaded up to the limit of WithWarmUp.
 // Parameters:
 // - ctx: The context for the operation.
 // Returns:
 // - error: An error if the operation fails.
-func (t *token) initCachedRefreshTokens(ctx context.Context) (err error) {
+func (t *token) initCachedRefreshTokens(ctx context.Context) error {
 
-	refreshTokens = make(map[string]string)
+	if t.lazyCacheSize > 0 {
+		t.refreshTokens = newRefreshTokenCache(t.repo, false, t.lazyCacheSize)
+		return nil
+	}
 
-	cachedRefreshTokens, err := t.findAllRefreshTokensFromDatabase(ctx)
-	if err != nil {
-		return
+	t.refreshTokens = newRefreshTokenCache(t.repo, true, 0)
+
+	paged, ok := t.repo.(PagedRepository)
+	if !ok {
+		tokens, err := t.findAllRefreshTokensFromDatabase(ctx)
+		if err != nil {
+			return err
+		}
+		t.warmUp(ctx, tokens)
+		return nil
 	}
 
-	for _, token := range cachedRefreshTokens {
-		refreshTokens[token.JTI] = token.Subject
+	pageSize := t.warmUpPageSize
+	if pageSize <= 0 {
+		pageSize = DefaultWarmUpPageSize
 	}
 
-	return
+	cursor := ""
+	for page := 1; ; page++ {
+		tokens, next, err := paged.FindRefreshTokensPage(ctx, cursor, pageSize)
+		if err != nil {
+			return err
+		}
+		if !t.warmUp(ctx, tokens) {
+			return nil
+		}
+		if next == "" {
+			break
+		}
+		if page%10 == 0 {
+			t.logInfo(ctx, "jwt: %d refresh tokens are cached, the warm-up goes on", t.refreshTokens.len())
+		}
+		cursor = next
+	}
+
+	t.logInfo(ctx, "jwt: the warm-up cached %d refresh tokens", t.refreshTokens.len())
+	return nil
+}
+
+// warmUp caches the tokens read by the warm-up, up to the limit of WithWarmUp. Once the limit
+// is reached the cache is incomplete, the tokens it does not hold are read from the repository.
+// Parameters:
+// - ctx: The context for the operation.
+// - tokens: The tokens read.
+// Returns:
+// - bool: False when the limit is reached and the warm-up stops.
+func (t *token) warmUp(ctx context.Context, tokens []RefreshToken) bool {
+	for _, token := range tokens {
+		if t.warmUpLimit > 0 && t.refreshTokens.len() >= t.warmUpLimit {
+			// the cache is not shared yet, it can be changed without its lock
+			t.refreshTokens.complete = false
+			t.refreshTokens.capacity = t.warmUpLimit
+			t.logInfo(ctx, "jwt: the warm-up stopped at %d refresh tokens, the others are read from the repository", t.warmUpLimit)
+			return false
+		}
+		t.refreshTokens.put(token.JTI, token.Subject)
+	}
+	return true
+}
+
+// logInfo logs the progress of the warm-up when a logger is set, see WithLogger.
+func (t *token) logInfo(ctx context.Context, message string, args ...any) {
+	if t.log != nil {
+		t.log.Info(ctx, message, args...)
+	}
 }
 
 // initCachedBlockedTokens initializes the cache for blocked tokens by loading them from the database.
@@ -494,14 +797,25 @@ func (t *token) initCachedBlockedTokens(ctx context.Context) error {
 // - *Claims: The claims extracted from the token.
 // - error: An error if the token is invalid or verification fails.
 func (t *token) VerifyToken(authToken string) (string, *Claims, error) {
+	return t.VerifyTokenAt(authToken, t.clock.Now())
+}
+
+// VerifyTokenAt verifies the validity of an access token at a given time, give or take the
+// leeway, e.g. to check that the token of a long-lived connection is still valid.
+// Parameters:
+// - authToken: The access token to be verified.
+// - at: The time the expiry, issue and not-before times are checked against.
+// Returns:
+// - string: The token string if valid.
+// - *Claims: The claims extracted from the token.
+// - error: ErrExpiredToken if the token is expired at that time, ErrUnauthorized if it is invalid.
+func (t *token) VerifyTokenAt(authToken string, at time.Time) (string, *Claims, error) {
 
 	if len(strings.Split(authToken, " ")) > 1 {
 		authToken = strings.Split(authToken, " ")[1]
 	}
 
-	token, err := jwt.ParseWithClaims(authToken, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return t.parseToken(token)
-	})
+	token, err := t.parseWithClaimsAt(authToken, &Claims{}, at)
 
 	if err != nil {
 
@@ -517,7 +831,7 @@ func (t *token) VerifyToken(authToken string) (string, *Claims, error) {
 
 	if token.Valid {
 
-		if t.contains(blockedTokens, authToken) {
+		if util.Contains(blockedTokens, authToken) {
 			return authToken, nil, ErrUnauthorized
 		}
 
@@ -527,21 +841,6 @@ func (t *token) VerifyToken(authToken string) (string, *Claims, error) {
 	}
 }
 
-// contains checks if a string exists in a slice of strings.
-// Parameters:
-// - s: The slice of strings to search.
-// - e: The string to find.
-// Returns:
-// - bool: True if the string is found, false otherwise.
-func (t *token) contains(s []string, e string) bool {
-	for _, a := range s {
-		if a == e {
-			return true
-		}
-	}
-	return false
-}
-
 // verifyRefreshToken verifies the validity of a refresh token.
 // Parameters:
 // - refreshToken: The refresh token to be verified.
@@ -549,9 +848,7 @@ func (t *token) contains(s []string, e string) bool {
 // - *RefreshTokenClaims: The claims extracted from the token.
 // - error: An error if the token is invalid or verification fails.
 func (t *token) verifyRefreshToken(refreshToken string) (*RefreshTokenClaims, error) {
-	token, err := jwt.ParseWithClaims(refreshToken, &RefreshTokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return t.parseToken(token)
-	})
+	token, err := t.parseWithClaims(refreshToken, &RefreshTokenClaims{})
 
 	if err != nil {
 
@@ -577,28 +874,38 @@ func (t *token) verifyRefreshToken(refreshToken string) (*RefreshTokenClaims, er
 // Parameters:
 // - ctx: The context for the operation.
 // - sub: The subject (user identifier) associated with the token.
+// - fingerprint: The device the token is bound to, none when empty.
 // Returns:
 // - jti: The unique identifier for the refresh token.
-// - error: An error if the operation fails.
-func (t *token) storeRefreshToken(ctx context.Context, sub string) (jti string, err error) {
+// - error: An error if the operation fails, or if the token is bound and the repository is not a DeviceBindingRepository.
+func (t *token) storeRefreshToken(ctx context.Context, sub, fingerprint string) (jti string, err error) {
 	jti, err = t.generateRandomString(32)
 	if err != nil {
 		return
 	}
 
-	for refreshTokens[jti] != "" {
+	for t.refreshTokens.contains(jti) {
 		jti, err = t.generateRandomString(32)
 		if err != nil {
 			return
 		}
 	}
 
-	err = t.storeRefreshTokenToDatabase(ctx, sub, jti)
+	if fingerprint != "" {
+		binding, ok := t.repo.(DeviceBindingRepository)
+		if !ok {
+			err = errors.New("jwt: the repository cannot bind the refresh tokens to devices")
+			return
+		}
+		err = binding.StoreBoundRefreshToken(ctx, sub, jti, fingerprint)
+	} else {
+		err = t.storeRefreshTokenToDatabase(ctx, sub, jti)
+	}
 	if err != nil {
 		return
 	}
 
-	refreshTokens[jti] = sub
+	t.refreshTokens.put(jti, sub)
 
 	return
 }
@@ -635,7 +942,7 @@ func (t *token) deleteRefreshToken(ctx context.Context, refreshToken string) (er
 			return
 		}
 
-		delete(refreshTokens, token.JTI)
+		t.refreshTokens.delete(token.JTI)
 	}
 
 	return
@@ -674,7 +981,7 @@ func (t *token) DeleteToken(ctx context.Context, accessToken, refreshToken strin
 			return
 		}
 
-		delete(refreshTokens, token.JTI)
+		t.refreshTokens.delete(token.JTI)
 
 		var accessClaims *Claims
 		_, accessClaims, err = t.VerifyToken(accessToken)
@@ -682,7 +989,7 @@ func (t *token) DeleteToken(ctx context.Context, accessToken, refreshToken strin
 			return
 		}
 
-		if accessClaims != nil && accessClaims.ExpiresAt != 0 && accessClaims.ExpiresAt > time.Now().Unix() {
+		if accessClaims != nil && accessClaims.ExpiresAt != 0 && accessClaims.ExpiresAt > t.clock.Now().Unix() {
 			err = t.storeBlockedTokenToDatabase(ctx, token.Subject, accessToken, accessClaims.ExpiresAt)
 			if err != nil {
 				return
@@ -694,13 +1001,17 @@ func (t *token) DeleteToken(ctx context.Context, accessToken, refreshToken strin
 	return
 }
 
-// checkRefreshToken checks if a refresh token with the given JTI exists in the in-memory cache.
+// checkRefreshToken checks if a refresh token with the given JTI exists in the in-memory cache,
+// or in the repository when the cache is incomplete.
 // Parameters:
+// - ctx: The context for the operation.
 // - jti: The unique identifier of the refresh token.
 // Returns:
 // - bool: True if the refresh token exists, false otherwise.
-func (t *token) checkRefreshToken(jti string) bool {
-	return refreshTokens[jti] != ""
+// - error: An error if the repository fails.
+func (t *token) checkRefreshToken(ctx context.Context, jti string) (bool, error) {
+	sub, err := t.refreshTokens.subject(ctx, jti)
+	return sub != "", err
 }
 
 // generateCSRFSecret generates a random CSRF secret string.
@@ -733,6 +1044,7 @@ func (t *token) GenerateCentrifugoJWT(userId string, secretKey string, capsObj m
 // - role: The role of the user.
 // - sub: The subject (user identifier) associated with the token.
 // - tenant: The tenant information for the user.
+// The refresh token is bound to the device whose fingerprint is carried by ctx, see WithFingerprint.
 // Returns:
 // - accessToken: The generated access token.
 // - refreshToken: The generated refresh token.
@@ -741,17 +1053,25 @@ func (t *token) GenerateCentrifugoJWT(userId string, secretKey string, capsObj m
 // - err: An error if the operation fails.
 func (t *token) GenerateToken(ctx context.Context, userID string, role string, sub string, tenant string) (accessToken, refreshToken, csrfSecret string, expiresAt int64, err error) {
 
+	// throttle the logins of the subject
+	if err = t.allow(ctx, OperationGenerate, sub); err != nil {
+		return
+	}
+
 	// generate the csrf secret
 	csrfSecret, err = t.generateCSRFSecret()
 	if err != nil {
 		return
 	}
 
-	// generate the refresh token
-	refreshToken, err = t.createRefreshToken(ctx, sub, csrfSecret)
+	// generate the refresh token, bound to the device of the caller if known
+	refreshToken, err = t.createRefreshToken(ctx, sub, tenant, csrfSecret, FingerprintFromContext(ctx))
+	if err != nil {
+		return
+	}
 
-	// generate the auth token
-	accessToken, expiresAt, err = t.createAccessToken(userID, role, sub, tenant, csrfSecret)
+	// generate the auth token, the session starts now
+	accessToken, expiresAt, err = t.createAccessToken(userID, role, sub, tenant, csrfSecret, t.clock.Now().Unix())
 	if err != nil {
 		return
 	}
@@ -766,23 +1086,22 @@ func (t *token) GenerateToken(ctx context.Context, userID string, role string, s
 // - sub: The subject (user identifier) associated with the token.
 // - tenant: The tenant information for the user.
 // - csrfSecret: The CSRF secret associated with the token.
+// - authTime: The time the user logged in (in Unix timestamp).
 // Returns:
 // - authTokenString: The generated access token string.
 // - authTokenExp: The expiration time of the access token (in Unix timestamp).
 // - err: An error if the operation fails.
-func (t *token) createAccessToken(userID string, role string, sub string, tenant string, csrfSecret string) (authTokenString string, authTokenExp int64, err error) {
+func (t *token) createAccessToken(userID string, role string, sub string, tenant string, csrfSecret string, authTime int64) (authTokenString string, authTokenExp int64, err error) {
 
-	authTokenExp = time.Now().Add(t.accessTokenValidTime).Unix()
 	authClaims := Claims{
-		ID:     userID,
-		Csrf:   csrfSecret,
-		Role:   role,
-		Tenant: tenant,
-		StandardClaims: jwt.StandardClaims{
-			Subject:   sub,
-			ExpiresAt: authTokenExp,
-		},
+		ID:             userID,
+		Csrf:           csrfSecret,
+		Role:           role,
+		Tenant:         tenant,
+		AuthTime:       authTime,
+		StandardClaims: t.standardClaims(sub, t.accessTokenValidTime),
 	}
+	authTokenExp = authClaims.ExpiresAt
 
 	authTokenString, err = t.sign(authClaims)
 
@@ -795,6 +1114,7 @@ func (t *token) createAccessToken(userID string, role string, sub string, tenant
 // - oldAccessTokenString: The expired access token string.
 // - oldRefreshTokenString: The refresh token string.
 // - oldCsrfSecret: The CSRF secret associated with the old tokens.
+// A refresh token bound to a device requires the same fingerprint in ctx, see WithFingerprint.
 // Returns:
 // - newAuthTokenString: The renewed access token string.
 // - newRefreshTokenString: The renewed refresh token string.
@@ -803,11 +1123,25 @@ func (t *token) createAccessToken(userID string, role string, sub string, tenant
 // - userId: The user ID associated with the token.
 // - err: An error if the operation fails.
 func (t *token) RenewToken(ctx context.Context, oldAccessTokenString string, oldRefreshTokenString, oldCsrfSecret string) (newAuthTokenString, newRefreshTokenString, newCsrfSecret string, expiresAt int64, userId string, err error) {
+	return t.renewToken(ctx, oldAccessTokenString, oldRefreshTokenString, oldCsrfSecret, false)
+}
+
+// renewToken renews the tokens, see RenewToken.
+// Parameters:
+// - reissue: Whether a new access token is issued while the old one is still valid.
+func (t *token) renewToken(ctx context.Context, oldAccessTokenString string, oldRefreshTokenString, oldCsrfSecret string, reissue bool) (newAuthTokenString, newRefreshTokenString, newCsrfSecret string, expiresAt int64, userId string, err error) {
 
 	if len(strings.Split(oldAccessTokenString, " ")) > 1 {
 		oldAccessTokenString = strings.Split(oldAccessTokenString, " ")[1]
 	}
 
+	// throttle the renewals of the refresh token, an unreadable one is rejected below
+	if jti, ok := refreshJTI(oldRefreshTokenString); ok {
+		if err = t.allow(ctx, OperationRenew, jti); err != nil {
+			return
+		}
+	}
+
 	// first, check that a csrf token was provided
 	if oldCsrfSecret == "" {
 		fmt.Println("No CSRF token!")
@@ -816,23 +1150,35 @@ func (t *token) RenewToken(ctx context.Context, oldAccessTokenString string, old
 	}
 
 	// now, check that it matches what's in the auth token claims
-	authToken, err := jwt.ParseWithClaims(oldAccessTokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return t.parseToken(token)
-	})
+	authToken, err := t.parseWithClaims(oldAccessTokenString, &Claims{})
 
 	authTokenClaims, ok := authToken.Claims.(*Claims)
 	if !ok {
 		return
 	}
 
-	if oldCsrfSecret != authTokenClaims.Csrf {
+	// compared in constant time so the secret cannot be guessed from the response time
+	if !util.SecureCompare(oldCsrfSecret, authTokenClaims.Csrf) {
 		fmt.Println("CSRF token doesn't match jwt!")
 		err = ErrUnauthorized
 		return
 	}
 
+	// then, check that the session is not too old, whatever the state of the tokens
+	if t.sessionExpired(authTokenClaims) {
+		err = ErrSessionExpired
+		return
+	}
+
+	// and that a refresh token bound to a device is renewed by that device, err still holds
+	// the validation error of the auth token checked below
+	if deviceErr := t.checkDevice(ctx, oldRefreshTokenString); deviceErr != nil {
+		err = deviceErr
+		return
+	}
+
 	// next, check the auth token in a stateless manner
-	if authToken.Valid {
+	if authToken.Valid && !reissue {
 		fmt.Println("Auth token is valid")
 		// auth token has not expired
 		// we need to return the csrf secret bc that's what the function calls for
@@ -844,53 +1190,124 @@ func (t *token) RenewToken(ctx context.Context, oldAccessTokenString string, old
 		newRefreshTokenString, err = t.updateRefreshTokenExp(ctx, oldRefreshTokenString)
 		newAuthTokenString = oldAccessTokenString
 		return
+	} else if authToken.Valid {
+		// the auth token is reissued before it expires, see ValidateForConnectionRefresh
+		return t.reissueTokens(ctx, oldAccessTokenString, oldRefreshTokenString)
 	} else if ve, ok := err.(*jwt.ValidationError); ok {
 		fmt.Println("Auth token is not valid")
 		if ve.Errors&(jwt.ValidationErrorExpired) != 0 {
 			fmt.Println("Auth token is expired")
 			// auth token is expired
-			newAuthTokenString, newCsrfSecret, expiresAt, userId, err = t.updateAccessToken(ctx, oldRefreshTokenString, oldAccessTokenString)
-			if err != nil {
-				return
-			}
-
-			// update the exp of refresh token string
-			newRefreshTokenString, err = t.updateRefreshTokenExp(ctx, oldRefreshTokenString)
-			if err != nil {
-				return
-			}
-
-			// update the csrf string of the refresh token
-			newRefreshTokenString, err = t.updateRefreshTokenCsrf(newRefreshTokenString, newCsrfSecret)
-			if err != nil {
-				return
-			}
-
-			return
+			return t.reissueTokens(ctx, oldAccessTokenString, oldRefreshTokenString)
 		} else {
 			fmt.Println("Error in auth token")
 			err = ErrUnauthorized
 			return
 		}
-	} else {
-		fmt.Println("Error in auth token")
-		err = ErrUnauthorized
-		return
 	}
 
 	// if we get here, there was some error validating the token
+	fmt.Println("Error in auth token")
 	err = ErrUnauthorized
 	return
 }
 
-// parseToken parses a JWT token and validates its signing method.
+// reissueTokens issues a new access token with a new CSRF secret, and renews the refresh
+// token, which must be valid and not revoked.
+// Parameters:
+// - ctx: The context for the operation.
+// - oldAccessTokenString: The access token whose claims are reissued.
+// - oldRefreshTokenString: The refresh token string.
+// Returns:
+// - newAuthTokenString: The new access token string.
+// - newRefreshTokenString: The renewed refresh token string.
+// - newCsrfSecret: The new CSRF secret.
+// - expiresAt: The expiration time of the new access token (in Unix timestamp).
+// - userId: The user ID associated with the token.
+// - err: An error if the operation fails.
+func (t *token) reissueTokens(ctx context.Context, oldAccessTokenString, oldRefreshTokenString string) (newAuthTokenString, newRefreshTokenString, newCsrfSecret string, expiresAt int64, userId string, err error) {
+	newAuthTokenString, newCsrfSecret, expiresAt, userId, err = t.updateAccessToken(ctx, oldRefreshTokenString, oldAccessTokenString)
+	if err != nil {
+		return
+	}
+
+	// update the exp of refresh token string
+	newRefreshTokenString, err = t.updateRefreshTokenExp(ctx, oldRefreshTokenString)
+	if err != nil {
+		return
+	}
+
+	// update the csrf string of the refresh token
+	newRefreshTokenString, err = t.updateRefreshTokenCsrf(newRefreshTokenString, newCsrfSecret)
+	return
+}
+
+// parseWithClaims parses a JWT token into claims, verifying its signature, then its expiry,
+// issue and not-before times against the clock of the token, give or take the leeway.
+// Parameters:
+// - tokenString: The token string to be parsed.
+// - claims: The claims the token is decoded into.
+// Returns:
+// - *jwt.Token: The parsed token, not valid when an error is returned.
+// - error: A *jwt.ValidationError if the token cannot be verified or its times are not valid.
+func (t *token) parseWithClaims(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	return t.parseWithClaimsAt(tokenString, claims, t.clock.Now())
+}
+
+// parseWithClaimsAt parses a JWT token like parseWithClaims, checking its times against a
+// given time instead of the clock.
+// Parameters:
+// - tokenString: The token string to be parsed.
+// - claims: The claims the token is decoded into.
+// - now: The time the expiry, issue and not-before times are checked against.
+// Returns:
+// - *jwt.Token: The parsed token, not valid when an error is returned.
+// - error: A *jwt.ValidationError if the token cannot be verified or its times are not valid.
+func (t *token) parseWithClaimsAt(tokenString string, claims jwt.Claims, now time.Time) (*jwt.Token, error) {
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+
+	token, err := parser.ParseWithClaims(tokenString, claims, t.parseToken)
+	if err != nil {
+		return token, err
+	}
+
+	tc, ok := claims.(timeClaims)
+	if !ok {
+		return token, nil
+	}
+
+	leeway := int64(t.leeway / time.Second)
+	vErr := &jwt.ValidationError{}
+
+	if !tc.VerifyExpiresAt(now.Unix()-leeway, false) {
+		vErr.Inner = errors.New("token is expired")
+		vErr.Errors |= jwt.ValidationErrorExpired
+	}
+	if !tc.VerifyIssuedAt(now.Unix()+leeway, false) {
+		vErr.Inner = errors.New("token used before issued")
+		vErr.Errors |= jwt.ValidationErrorIssuedAt
+	}
+	if !tc.VerifyNotBefore(now.Unix()+leeway, false) {
+		vErr.Inner = errors.New("token is not valid yet")
+		vErr.Errors |= jwt.ValidationErrorNotValidYet
+	}
+
+	if vErr.Errors != 0 {
+		token.Valid = false
+		return token, vErr
+	}
+
+	return token, nil
+}
+
+// parseToken parses a JWT token and validates that it is signed with the method of the Token.
 // Parameters:
 // - token: The JWT token to be parsed.
 // Returns:
 // - interface{}: The key used for signing the token.
 // - error: An error if the token's signing method is invalid.
 func (t *token) parseToken(token *jwt.Token) (interface{}, error) {
-	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+	if token.Method.Alg() != t.algorithm.Alg() {
 		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 	}
 
@@ -900,12 +1317,48 @@ func (t *token) parseToken(token *jwt.Token) (interface{}, error) {
 	case jwt.SigningMethodRS256:
 		key = verifyKey
 	case jwt.SigningMethodHS256, jwt.SigningMethodHS512:
-		key = []byte(t.secretKey)
+		// the claims are decoded but not verified yet, the tenant only selects the key
+		return t.hmacKey(token.Claims, false)
 	}
 
 	return key, nil
 }
 
+// hmacKey returns the HMAC key of the tenant of the claims, or the secret key of a
+// single-tenant Token.
+// Parameters:
+// - claims: The claims of the token.
+// - sign: Whether the key signs the token or verifies it.
+// Returns:
+// - []byte: The key.
+// - error: An error if the key of the tenant cannot be resolved.
+func (t *token) hmacKey(claims jwt.Claims, sign bool) ([]byte, error) {
+	if t.keys == nil {
+		return []byte(t.secretKey), nil
+	}
+
+	var tenant string
+	switch c := claims.(type) {
+	case *Claims:
+		tenant = c.Tenant
+	case Claims:
+		tenant = c.Tenant
+	case *RefreshTokenClaims:
+		tenant = c.Tenant
+	case RefreshTokenClaims:
+		tenant = c.Tenant
+	case *ActionClaims:
+		tenant = c.Tenant
+	case ActionClaims:
+		tenant = c.Tenant
+	}
+
+	if sign {
+		return t.keys.ResolveSignKey(tenant)
+	}
+	return t.keys.ResolveVerifyKey(tenant)
+}
+
 // updateRefreshTokenCsrf updates the CSRF secret of a refresh token.
 // Parameters:
 // - oldRefreshTokenString: The old refresh token string.
@@ -914,9 +1367,7 @@ func (t *token) parseToken(token *jwt.Token) (interface{}, error) {
 // - newRefreshTokenString: The updated refresh token string.
 // - err: An error if the operation fails.
 func (t *token) updateRefreshTokenCsrf(oldRefreshTokenString string, newCsrfString string) (newRefreshTokenString string, err error) {
-	refreshToken, err := jwt.ParseWithClaims(oldRefreshTokenString, &RefreshTokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return t.parseToken(token)
-	})
+	refreshToken, err := t.parseWithClaims(oldRefreshTokenString, &RefreshTokenClaims{})
 	if err != nil {
 		return
 	}
@@ -927,11 +1378,14 @@ func (t *token) updateRefreshTokenCsrf(oldRefreshTokenString string, newCsrfStri
 	}
 
 	refreshClaims := RefreshTokenClaims{
-		Csrf: newCsrfString,
+		Csrf:   newCsrfString,
+		Tenant: oldRefreshTokenClaims.Tenant,
 		StandardClaims: jwt.StandardClaims{
 			Id:        oldRefreshTokenClaims.StandardClaims.Id, // jti
 			Subject:   oldRefreshTokenClaims.StandardClaims.Subject,
 			ExpiresAt: oldRefreshTokenClaims.StandardClaims.ExpiresAt,
+			IssuedAt:  oldRefreshTokenClaims.StandardClaims.IssuedAt,
+			NotBefore: oldRefreshTokenClaims.StandardClaims.NotBefore,
 		},
 	}
 
@@ -951,9 +1405,7 @@ func (t *token) updateRefreshTokenCsrf(oldRefreshTokenString string, newCsrfStri
 // - userId: The user ID associated with the token.
 // - err: An error if the operation fails.
 func (t *token) updateAccessToken(ctx context.Context, refreshTokenString string, oldAccessToken string) (newAccessToken, csrfSecret string, expiresAt int64, userId string, err error) {
-	refreshToken, err := jwt.ParseWithClaims(refreshTokenString, &RefreshTokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return t.parseToken(token)
-	})
+	refreshToken, err := t.parseWithClaims(refreshTokenString, &RefreshTokenClaims{})
 	if err != nil {
 		return
 	}
@@ -965,15 +1417,17 @@ func (t *token) updateAccessToken(ctx context.Context, refreshTokenString string
 	}
 
 	// check if the refresh token has been revoked
-	if t.checkRefreshToken(refreshTokenClaims.StandardClaims.Id) {
+	exists, err := t.checkRefreshToken(ctx, refreshTokenClaims.StandardClaims.Id)
+	if err != nil {
+		return
+	}
+	if exists {
 		// the refresh token has not been revoked
 		// has it expired?
 		if refreshToken.Valid {
 			// nope, the refresh token has not expired
 			// issue a new auth token
-			accessToken, _ := jwt.ParseWithClaims(oldAccessToken, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-				return t.parseToken(token)
-			})
+			accessToken, _ := t.parseWithClaims(oldAccessToken, &Claims{})
 
 			oldAuthTokenClaims, ok := accessToken.Claims.(*Claims)
 			if !ok {
@@ -989,7 +1443,7 @@ func (t *token) updateAccessToken(ctx context.Context, refreshTokenString string
 
 			userId = oldAuthTokenClaims.ID
 
-			newAccessToken, expiresAt, err = t.createAccessToken(oldAuthTokenClaims.ID, oldAuthTokenClaims.Role, oldAuthTokenClaims.StandardClaims.Subject, oldAuthTokenClaims.Tenant, csrfSecret)
+			newAccessToken, expiresAt, err = t.createAccessToken(oldAuthTokenClaims.ID, oldAuthTokenClaims.Role, oldAuthTokenClaims.StandardClaims.Subject, oldAuthTokenClaims.Tenant, csrfSecret, oldAuthTokenClaims.AuthTime)
 
 			return
 		} else {
@@ -1030,7 +1484,12 @@ func (t *token) sign(claims jwt.Claims) (string, error) {
 		tokenString, err = token.SignedString(signKey)
 		break
 	case jwt.SigningMethodHS256, jwt.SigningMethodHS512:
-		tokenString, err = token.SignedString([]byte(t.secretKey))
+		var key []byte
+		key, err = t.hmacKey(claims, true)
+		if err != nil {
+			return "", err
+		}
+		tokenString, err = token.SignedString(key)
 		break
 	}
 
@@ -1045,9 +1504,7 @@ func (t *token) sign(claims jwt.Claims) (string, error) {
 // - newRefreshTokenString: The updated refresh token string.
 // - err: An error if the operation fails.
 func (t *token) updateRefreshTokenExp(ctx context.Context, oldRefreshTokenString string) (newRefreshTokenString string, err error) {
-	refreshToken, err := jwt.ParseWithClaims(oldRefreshTokenString, &RefreshTokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return t.parseToken(token)
-	})
+	refreshToken, err := t.parseWithClaims(oldRefreshTokenString, &RefreshTokenClaims{})
 	if err != nil {
 		return
 	}
@@ -1057,26 +1514,28 @@ func (t *token) updateRefreshTokenExp(ctx context.Context, oldRefreshTokenString
 		return
 	}
 
-	err = t.deleteRefreshToken(ctx, oldRefreshTokenString)
+	// the renewed token stays bound to the device of the old one
+	fingerprint, err := t.refreshTokenFingerprint(ctx, oldRefreshTokenClaims.StandardClaims.Id)
 	if err != nil {
 		return
 	}
 
-	refreshTokenExp := time.Now().Add(t.refreshTokenValidTime).Unix()
+	err = t.deleteRefreshToken(ctx, oldRefreshTokenString)
+	if err != nil {
+		return
+	}
 
-	refreshJti, err := t.storeRefreshToken(ctx, oldRefreshTokenClaims.StandardClaims.Subject)
+	refreshJti, err := t.storeRefreshToken(ctx, oldRefreshTokenClaims.StandardClaims.Subject, fingerprint)
 	if err != nil {
 		return
 	}
 
 	refreshClaims := RefreshTokenClaims{
-		Csrf: oldRefreshTokenClaims.Csrf,
-		StandardClaims: jwt.StandardClaims{
-			Id:        refreshJti, // jti
-			Subject:   oldRefreshTokenClaims.StandardClaims.Subject,
-			ExpiresAt: refreshTokenExp,
-		},
+		Csrf:           oldRefreshTokenClaims.Csrf,
+		Tenant:         oldRefreshTokenClaims.Tenant,
+		StandardClaims: t.standardClaims(oldRefreshTokenClaims.StandardClaims.Subject, t.refreshTokenValidTime),
 	}
+	refreshClaims.Id = refreshJti // jti
 
 	newRefreshTokenString, err = t.sign(refreshClaims)
 
@@ -1087,32 +1546,44 @@ func (t *token) updateRefreshTokenExp(ctx context.Context, oldRefreshTokenString
 // Parameters:
 // - ctx: The context for the operation.
 // - sub: The subject (user identifier) associated with the token.
+// - tenant: The tenant of the user, which selects the signing key of a multi-tenant Token.
 // - csrfString: The CSRF secret associated with the token.
+// - fingerprint: The device the token is bound to, none when empty.
 // Returns:
 // - refreshTokenString: The generated refresh token string.
 // - err: An error if the operation fails.
-func (t *token) createRefreshToken(ctx context.Context, sub string, csrfString string) (refreshTokenString string, err error) {
+func (t *token) createRefreshToken(ctx context.Context, sub string, tenant string, csrfString string, fingerprint string) (refreshTokenString string, err error) {
 
-	refreshTokenExp := time.Now().Add(t.refreshTokenValidTime).Unix()
-
-	refreshJti, err := t.storeRefreshToken(ctx, sub)
+	refreshJti, err := t.storeRefreshToken(ctx, sub, fingerprint)
 	if err != nil {
 		return
 	}
 
 	refreshClaims := &RefreshTokenClaims{
-		Csrf: csrfString,
-		StandardClaims: jwt.StandardClaims{
-			Id:        refreshJti, // jti
-			Subject:   sub,
-			ExpiresAt: refreshTokenExp,
-		},
+		Csrf:           csrfString,
+		Tenant:         tenant,
+		StandardClaims: t.standardClaims(sub, t.refreshTokenValidTime),
 	}
+	refreshClaims.Id = refreshJti // jti
 
 	refreshTokenString, err = t.sign(refreshClaims)
 	return
 }
 
+// refreshJTI reads the jti of a refresh token without verifying it.
+// Parameters:
+// - refreshTokenString: The refresh token string.
+// Returns:
+// - string: The jti of the token.
+// - bool: False if the token cannot be read or has no jti.
+func refreshJTI(refreshTokenString string) (string, bool) {
+	var claims RefreshTokenClaims
+	if _, _, err := new(jwt.Parser).ParseUnverified(refreshTokenString, &claims); err != nil {
+		return "", false
+	}
+	return claims.Id, claims.Id != ""
+}
+
 // grabUUID extracts the UUID (subject) from the provided access token string.
 // Parameters:
 // - authTokenString: The access token string to parse.
@@ -1138,9 +1609,7 @@ func (t *token) grabUUID(authTokenString string) (string, error) {
 // Returns:
 // - error: An error if the operation fails or the token cannot be parsed.
 func (t *token) revokeRefreshToken(ctx context.Context, refreshTokenString string) error {
-	refreshToken, err := jwt.ParseWithClaims(refreshTokenString, &RefreshTokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return t.parseToken(token)
-	})
+	refreshToken, err := t.parseWithClaims(refreshTokenString, &RefreshTokenClaims{})
 	if err != nil {
 		return ErrParsingRefreshTokenWithClaims
 	}
@@ -1184,3 +1653,46 @@ func (t *token) generateRandomString(s int) (string, error) {
 	b, err := t.generateRandomBytes(s)
 	return base64.URLEncoding.EncodeToString(b), err
 }
+
+// standardClaims returns the registered claims of a token issued now for sub: its issue
+// time, its not-before time when enabled, and its expiry.
+// Parameters:
+// - sub: The subject (user identifier) of the token.
+// - validFor: The validity duration of the token.
+// Returns:
+// - jwt.StandardClaims: The registered claims.
+func (t *token) standardClaims(sub string, validFor time.Duration) jwt.StandardClaims {
+	now := t.clock.Now()
+
+	claims := jwt.StandardClaims{
+		Subject:   sub,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(validFor).Unix(),
+	}
+	if t.notBefore != nil {
+		claims.NotBefore = now.Add(*t.notBefore).Unix()
+	}
+
+	return claims
+}
+
+// sessionExpired reports whether the session of an access token is older than the maximum
+// session age. Tokens issued without auth_time are not checked.
+// Parameters:
+// - claims: The claims of the access token.
+// Returns:
+// - bool: True if the user must log in again.
+func (t *token) sessionExpired(claims *Claims) bool {
+	if t.maxSessionAge <= 0 || claims.AuthTime == 0 {
+		return false
+	}
+	return t.clock.Now().Sub(claims.AuthenticatedAt()) > t.maxSessionAge
+}
+
+// unixTime converts a Unix timestamp claim to a time, zero staying the zero time.
+func unixTime(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
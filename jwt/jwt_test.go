@@ -0,0 +1,125 @@
+package jwt
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop/util"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var t0 = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// newTestToken creates an HS256 Token on a fresh redis, driven by the given clock.
+func newTestToken(t *testing.T, clock util.Clock, opts ...Option) Token {
+	t.Helper()
+
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	token, err := NewHS256JWT(context.Background(), "secret", NewRedisRepository(rdb), 60*24*time.Hour, time.Minute, append(opts, WithClock(clock))...)
+	require.NoError(t, err)
+	return token
+}
+
+func TestTokenIssuedAtAndNotBefore(t *testing.T) {
+	clock := util.NewFrozenClock(t0)
+	token := newTestToken(t, clock, WithNotBefore(10*time.Second))
+
+	accessToken, _, _, _, err := token.GenerateToken(context.Background(), "user-1", "admin", "user-1", "acme")
+	require.NoError(t, err)
+
+	// one second before nbf
+	clock.Set(t0.Add(9 * time.Second))
+	_, _, err = token.VerifyToken(accessToken)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+
+	// at nbf
+	clock.Set(t0.Add(10 * time.Second))
+	_, claims, err := token.VerifyToken(accessToken)
+	require.NoError(t, err)
+
+	assert.Equal(t, t0, claims.IssuedAtTime().UTC())
+	assert.Equal(t, t0.Add(10*time.Second), claims.NotBeforeTime().UTC())
+	assert.Equal(t, t0, claims.AuthenticatedAt().UTC())
+}
+
+func TestTokenWithoutNotBefore(t *testing.T) {
+	clock := util.NewFrozenClock(t0)
+	token := newTestToken(t, clock)
+
+	accessToken, _, _, _, err := token.GenerateToken(context.Background(), "user-1", "admin", "user-1", "acme")
+	require.NoError(t, err)
+
+	_, claims, err := token.VerifyToken(accessToken)
+	require.NoError(t, err)
+	assert.Equal(t, t0, claims.IssuedAtTime().UTC())
+	assert.True(t, claims.NotBeforeTime().IsZero())
+}
+
+func TestTokenExpiryBoundaries(t *testing.T) {
+	clock := util.NewFrozenClock(t0)
+	token := newTestToken(t, clock, WithLeeway(5*time.Second))
+
+	accessToken, _, _, expiresAt, err := token.GenerateToken(context.Background(), "user-1", "admin", "user-1", "acme")
+	require.NoError(t, err)
+	assert.Equal(t, t0.Add(time.Minute).Unix(), expiresAt)
+
+	// within the leeway after exp
+	clock.Set(t0.Add(time.Minute + 5*time.Second))
+	_, _, err = token.VerifyToken(accessToken)
+	assert.NoError(t, err)
+
+	// past the leeway
+	clock.Set(t0.Add(time.Minute + 6*time.Second))
+	_, _, err = token.VerifyToken(accessToken)
+	assert.ErrorIs(t, err, ErrExpiredToken)
+}
+
+func TestTokenIssuedInTheFuture(t *testing.T) {
+	// the token is minted by an instance whose clock is a minute ahead
+	fast := util.NewFrozenClock(t0.Add(time.Minute))
+	minting := newTestToken(t, fast, WithNotBefore(0))
+
+	accessToken, _, _, _, err := minting.GenerateToken(context.Background(), "user-1", "admin", "user-1", "acme")
+	require.NoError(t, err)
+
+	clock := util.NewFrozenClock(t0)
+
+	_, _, err = newTestToken(t, clock).VerifyToken(accessToken)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+
+	_, _, err = newTestToken(t, clock, WithLeeway(59*time.Second)).VerifyToken(accessToken)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+
+	_, _, err = newTestToken(t, clock, WithLeeway(time.Minute)).VerifyToken(accessToken)
+	assert.NoError(t, err)
+}
+
+func TestRenewTokenMaxSessionAge(t *testing.T) {
+	ctx := context.Background()
+	maxAge := 30 * 24 * time.Hour
+
+	clock := util.NewFrozenClock(t0)
+	token := newTestToken(t, clock, WithMaxSessionAge(maxAge))
+
+	accessToken, refreshToken, csrf, _, err := token.GenerateToken(ctx, "user-1", "admin", "user-1", "acme")
+	require.NoError(t, err)
+
+	// the expired access token is renewed until the session reaches its maximum age
+	clock.Set(t0.Add(maxAge))
+	accessToken, refreshToken, csrf, _, userID, err := token.RenewToken(ctx, accessToken, refreshToken, csrf)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", userID)
+
+	_, claims, err := token.VerifyToken(accessToken)
+	require.NoError(t, err)
+	assert.Equal(t, t0.Add(maxAge), claims.IssuedAtTime().UTC())
+	assert.Equal(t, t0, claims.AuthenticatedAt().UTC(), "the session start is kept")
+
+	clock.Set(t0.Add(maxAge + time.Second))
+	_, _, _, _, _, err = token.RenewToken(ctx, accessToken, refreshToken, csrf)
+	assert.ErrorIs(t, err, ErrSessionExpired)
+}
@@ -0,0 +1,118 @@
+package jwt
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// KeyResolver resolves the HMAC keys of the tenants of a multi-tenant Token, so a
+// compromised key only lets its own tenant's tokens be forged.
+type KeyResolver interface {
+	// ResolveSignKey returns the key signing the tokens of a tenant.
+	// Parameters:
+	// - tenant: The tenant of the token.
+	// Returns:
+	// - []byte: The signing key.
+	// - error: An error if the tenant has no key.
+	ResolveSignKey(tenant string) ([]byte, error)
+
+	// ResolveVerifyKey returns the key verifying the tokens of a tenant.
+	// Parameters:
+	// - tenant: The tenant claimed by the token, not verified yet.
+	// Returns:
+	// - []byte: The verification key.
+	// - error: An error if the tenant has no key.
+	ResolveVerifyKey(tenant string) ([]byte, error)
+}
+
+// staticKeyResolver is the KeyResolver returned by NewStaticKeyResolver.
+type staticKeyResolver map[string][]byte
+
+// NewStaticKeyResolver creates a KeyResolver signing and verifying the tokens of each
+// tenant with the same key, taken from a map.
+// Parameters:
+// - keys: The keys by tenant, the map is copied.
+// Returns:
+// - KeyResolver: The resolver, failing with ErrUnknownTenant for the tenants not in the map.
+func NewStaticKeyResolver(keys map[string][]byte) KeyResolver {
+	r := make(staticKeyResolver, len(keys))
+	for tenant, key := range keys {
+		r[tenant] = key
+	}
+	return r
+}
+
+func (r staticKeyResolver) ResolveSignKey(tenant string) ([]byte, error) {
+	key, ok := r[tenant]
+	if !ok || len(key) == 0 {
+		return nil, ErrUnknownTenant.Var(tenant)
+	}
+	return key, nil
+}
+
+func (r staticKeyResolver) ResolveVerifyKey(tenant string) ([]byte, error) {
+	return r.ResolveSignKey(tenant)
+}
+
+// cachedKey is a key held by cachingKeyResolver.
+type cachedKey struct {
+	key       []byte
+	expiresAt time.Time
+}
+
+// cachingKeyResolver is the KeyResolver returned by NewCachingKeyResolver.
+type cachingKeyResolver struct {
+	next KeyResolver
+	ttl  time.Duration
+
+	mu     sync.Mutex
+	sign   map[string]cachedKey
+	verify map[string]cachedKey
+}
+
+// NewCachingKeyResolver wraps a KeyResolver reading the keys from a slow store, e.g. a
+// vault, caching the keys it resolves. Failures are not cached.
+// Parameters:
+// - next: The wrapped resolver.
+// - ttl: How long a key is kept.
+// Returns:
+// - KeyResolver: The caching resolver, safe for concurrent use.
+func NewCachingKeyResolver(next KeyResolver, ttl time.Duration) KeyResolver {
+	return &cachingKeyResolver{
+		next:   next,
+		ttl:    ttl,
+		sign:   map[string]cachedKey{},
+		verify: map[string]cachedKey{},
+	}
+}
+
+func (r *cachingKeyResolver) ResolveSignKey(tenant string) ([]byte, error) {
+	return r.resolve(r.sign, tenant, r.next.ResolveSignKey)
+}
+
+func (r *cachingKeyResolver) ResolveVerifyKey(tenant string) ([]byte, error) {
+	return r.resolve(r.verify, tenant, r.next.ResolveVerifyKey)
+}
+
+// resolve returns the key of the tenant from the cache, or resolves it with next.
+func (r *cachingKeyResolver) resolve(cache map[string]cachedKey, tenant string, next func(string) ([]byte, error)) ([]byte, error) {
+	r.mu.Lock()
+	c, ok := cache[tenant]
+	r.mu.Unlock()
+
+	if ok && time.Now().Before(c.expiresAt) {
+		return c.key, nil
+	}
+
+	key, err := next(tenant)
+	if err != nil {
+		return nil, fmt.Errorf("resolve key of tenant %q: %w", tenant, err)
+	}
+
+	r.mu.Lock()
+	cache[tenant] = cachedKey{key: key, expiresAt: time.Now().Add(r.ttl)}
+	r.mu.Unlock()
+
+	return key, nil
+}
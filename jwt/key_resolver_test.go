@@ -0,0 +1,118 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop/model/apperror"
+	"github.com/alicebob/miniredis/v2"
+	jwt "github.com/golang-jwt/jwt"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMultiTenantTestToken(t *testing.T, keys KeyResolver) Token {
+	t.Helper()
+
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	token, err := NewMultiTenantHS256JWT(context.Background(), keys, NewRedisRepository(rdb), 60*24*time.Hour, time.Minute)
+	require.NoError(t, err)
+	return token
+}
+
+func TestMultiTenantTokenRejectsOtherTenant(t *testing.T) {
+	keys := NewStaticKeyResolver(map[string][]byte{
+		"acme":   []byte("acme-secret"),
+		"globex": []byte("globex-secret"),
+	})
+	token := newMultiTenantTestToken(t, keys)
+
+	accessToken, _, _, _, err := token.GenerateToken(context.Background(), "user-1", "admin", "user-1", "acme")
+	require.NoError(t, err)
+
+	_, claims, err := token.VerifyToken(accessToken)
+	require.NoError(t, err)
+	assert.Equal(t, "acme", claims.Tenant)
+
+	// an acme user claims to be in globex with the key of acme
+	claims.Tenant = "globex"
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("acme-secret"))
+	require.NoError(t, err)
+
+	_, _, err = token.VerifyToken(forged)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestMultiTenantTokenUnknownTenant(t *testing.T) {
+	token := newMultiTenantTestToken(t, NewStaticKeyResolver(map[string][]byte{"acme": []byte("acme-secret")}))
+
+	_, _, _, _, err := token.GenerateToken(context.Background(), "user-1", "admin", "user-1", "initech")
+	var et apperror.ErrorType
+	require.ErrorAs(t, err, &et)
+	assert.Equal(t, ErrUnknownTenant.Code(), et.Code())
+
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{
+		Tenant:         "initech",
+		StandardClaims: jwt.StandardClaims{Subject: "user-1", ExpiresAt: time.Now().Add(time.Minute).Unix()},
+	}).SignedString([]byte("acme-secret"))
+	require.NoError(t, err)
+
+	_, _, err = token.VerifyToken(forged)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
+
+// countingResolver counts the resolutions and fails them while err is set.
+type countingResolver struct {
+	calls int
+	err   error
+}
+
+func (r *countingResolver) ResolveSignKey(tenant string) ([]byte, error) {
+	r.calls++
+	if r.err != nil {
+		return nil, r.err
+	}
+	return []byte(tenant + "-secret"), nil
+}
+
+func (r *countingResolver) ResolveVerifyKey(tenant string) ([]byte, error) {
+	return r.ResolveSignKey(tenant)
+}
+
+func TestCachingKeyResolver(t *testing.T) {
+	errVault := errors.New("vault unavailable")
+	next := &countingResolver{err: errVault}
+	keys := NewCachingKeyResolver(next, time.Hour)
+
+	// failures are not cached
+	_, err := keys.ResolveSignKey("acme")
+	assert.ErrorIs(t, err, errVault)
+	next.err = nil
+
+	key, err := keys.ResolveSignKey("acme")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("acme-secret"), key)
+
+	_, err = keys.ResolveSignKey("acme")
+	require.NoError(t, err)
+	assert.Equal(t, 2, next.calls)
+}
+
+func TestMultiTenantTokenResolverFailure(t *testing.T) {
+	next := &countingResolver{}
+	token := newMultiTenantTestToken(t, next)
+
+	accessToken, _, _, _, err := token.GenerateToken(context.Background(), "user-1", "admin", "user-1", "acme")
+	require.NoError(t, err)
+
+	next.err = errors.New("vault unavailable")
+
+	_, _, _, _, err = token.GenerateToken(context.Background(), "user-1", "admin", "user-1", "acme")
+	assert.ErrorIs(t, err, next.err)
+
+	_, _, err = token.VerifyToken(accessToken)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
@@ -0,0 +1,193 @@
+package jwt
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/a-aslani/wotop/util"
+)
+
+// MemoryRepository is a Repository keeping the tokens in memory, for the tests and the
+// single-replica applications. It is safe for concurrent use.
+type MemoryRepository struct {
+	mu            sync.Mutex
+	clock         util.Clock
+	refreshTokens map[string]RefreshToken
+	blockedTokens map[string]blockedToken
+	actionTokens  map[string]actionToken
+}
+
+// blockedToken is a blocked token of MemoryRepository.
+type blockedToken struct {
+	token     string
+	expiresAt int64
+}
+
+// actionToken is an action token of MemoryRepository not consumed yet.
+type actionToken struct {
+	sub       string
+	expiresAt int64
+}
+
+// Ensure MemoryRepository implements the Repository, DeviceBindingRepository,
+// ActionTokenRepository and PagedRepository interfaces.
+var (
+	_ Repository              = (*MemoryRepository)(nil)
+	_ DeviceBindingRepository = (*MemoryRepository)(nil)
+	_ ActionTokenRepository   = (*MemoryRepository)(nil)
+	_ PagedRepository         = (*MemoryRepository)(nil)
+)
+
+// NewMemoryRepository creates a MemoryRepository.
+// Parameters:
+// - clock: The clock expiring the blocked and the action tokens, util.SystemClock in production.
+// Returns:
+// - *MemoryRepository: The empty repository.
+func NewMemoryRepository(clock util.Clock) *MemoryRepository {
+	return &MemoryRepository{
+		clock:         clock,
+		refreshTokens: map[string]RefreshToken{},
+		blockedTokens: map[string]blockedToken{},
+		actionTokens:  map[string]actionToken{},
+	}
+}
+
+func (r *MemoryRepository) StoreRefreshToken(ctx context.Context, sub, jti string) error {
+	return r.StoreBoundRefreshToken(ctx, sub, jti, "")
+}
+
+func (r *MemoryRepository) StoreBoundRefreshToken(_ context.Context, sub, jti, fingerprint string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.refreshTokens[jti] = RefreshToken{Subject: sub, JTI: jti, Fingerprint: fingerprint}
+	return nil
+}
+
+func (r *MemoryRepository) DeleteRefreshToken(_ context.Context, jti string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.refreshTokens, jti)
+	return nil
+}
+
+func (r *MemoryRepository) FindRefreshToken(_ context.Context, jti string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.refreshTokens[jti]
+	if !ok {
+		return "", ErrTokenAlreadyRefreshed
+	}
+	return token.Subject, nil
+}
+
+func (r *MemoryRepository) FindRefreshTokenFingerprint(_ context.Context, jti string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.refreshTokens[jti]
+	if !ok {
+		return "", ErrTokenAlreadyRefreshed
+	}
+	return token.Fingerprint, nil
+}
+
+func (r *MemoryRepository) FindAllRefreshTokens(context.Context) ([]RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tokens := make([]RefreshToken, 0, len(r.refreshTokens))
+	for _, token := range r.refreshTokens {
+		tokens = append(tokens, token)
+	}
+	return tokens, nil
+}
+
+// FindRefreshTokensPage returns the tokens in the order of their JTI, the cursor is the JTI of
+// the last token of the previous page.
+func (r *MemoryRepository) FindRefreshTokensPage(_ context.Context, cursor string, limit int) ([]RefreshToken, string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if limit <= 0 {
+		limit = DefaultWarmUpPageSize
+	}
+
+	jtis := make([]string, 0, len(r.refreshTokens))
+	for jti := range r.refreshTokens {
+		if jti > cursor {
+			jtis = append(jtis, jti)
+		}
+	}
+	slices.Sort(jtis)
+
+	next := ""
+	if len(jtis) > limit {
+		jtis = jtis[:limit]
+		next = jtis[limit-1]
+	}
+
+	tokens := make([]RefreshToken, len(jtis))
+	for i, jti := range jtis {
+		tokens[i] = r.refreshTokens[jti]
+	}
+	return tokens, next, nil
+}
+
+func (r *MemoryRepository) StoreBlockedToken(_ context.Context, sub, token string, expiresAt int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.blockedTokens[fmt.Sprintf("%s:%d", sub, expiresAt)] = blockedToken{token: token, expiresAt: expiresAt}
+	return nil
+}
+
+// FindAllBlockedTokens returns the blocked tokens not expired, the expired ones are forgotten.
+func (r *MemoryRepository) FindAllBlockedTokens(context.Context) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.clock.Now().Unix()
+	tokens := make([]string, 0, len(r.blockedTokens))
+	for key, blocked := range r.blockedTokens {
+		if blocked.expiresAt <= now {
+			delete(r.blockedTokens, key)
+			continue
+		}
+		tokens = append(tokens, blocked.token)
+	}
+	return tokens, nil
+}
+
+// StoreActionToken stores the ID of an action token, the expired ones are forgotten.
+func (r *MemoryRepository) StoreActionToken(_ context.Context, sub, jti string, expiresAt int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.clock.Now().Unix()
+	for id, token := range r.actionTokens {
+		if token.expiresAt <= now {
+			delete(r.actionTokens, id)
+		}
+	}
+
+	r.actionTokens[jti] = actionToken{sub: sub, expiresAt: expiresAt}
+	return nil
+}
+
+// ConsumeActionToken deletes the ID of an action token under the lock of the repository.
+func (r *MemoryRepository) ConsumeActionToken(_ context.Context, jti string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.actionTokens[jti]
+	if !ok || token.expiresAt <= r.clock.Now().Unix() {
+		return "", ErrActionTokenUsed
+	}
+	delete(r.actionTokens, jti)
+	return token.sub, nil
+}
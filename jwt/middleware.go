@@ -0,0 +1,187 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/logger"
+	"github.com/a-aslani/wotop/model/payload"
+	"github.com/a-aslani/wotop/util"
+)
+
+// TokenExtractor extracts the access token of a request.
+// Parameters:
+// - r: The HTTP request.
+// Returns:
+// - string: The access token.
+// - error: ErrUnauthorized if the request carries no token.
+type TokenExtractor func(r *http.Request) (string, error)
+
+// BearerHeader extracts the access token from the "Authorization: Bearer <token>" header,
+// it is the extractor of the middlewares by default.
+// Returns:
+// - TokenExtractor: The header extractor.
+func BearerHeader() TokenExtractor {
+	return func(r *http.Request) (string, error) {
+		scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !ok || scheme != preTokenName || token == "" {
+			return "", ErrUnauthorized
+		}
+		return token, nil
+	}
+}
+
+// Cookie extracts the access token from a cookie, e.g. for browser sessions.
+// Parameters:
+// - name: The name of the cookie.
+// Returns:
+// - TokenExtractor: The cookie extractor.
+func Cookie(name string) TokenExtractor {
+	return func(r *http.Request) (string, error) {
+		c, err := r.Cookie(name)
+		if err != nil || c.Value == "" {
+			return "", ErrUnauthorized
+		}
+		return c.Value, nil
+	}
+}
+
+// QueryParam extracts the access token from a query parameter, e.g. for the WebSocket
+// handshakes, whose browser API cannot set headers. The query strings end up in the access
+// logs, so the tokens passed this way should be short-lived.
+// Parameters:
+// - name: The name of the parameter, e.g. "token".
+// Returns:
+// - TokenExtractor: The query parameter extractor.
+func QueryParam(name string) TokenExtractor {
+	return func(r *http.Request) (string, error) {
+		token := r.URL.Query().Get(name)
+		if token == "" {
+			return "", ErrUnauthorized
+		}
+		return token, nil
+	}
+}
+
+type claimsKey struct{}
+
+// WithClaims returns a copy of the context carrying the claims of the access token.
+// Parameters:
+// - ctx: The parent context.
+// - claims: The verified claims.
+// Returns:
+// - context.Context: The context carrying the claims.
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// ClaimsFromContext returns the claims set by the authentication middlewares.
+// Parameters:
+// - ctx: The context of the request.
+// Returns:
+// - *Claims: The claims of the access token.
+// - bool: False if the request was not authenticated.
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*Claims)
+	return claims, ok
+}
+
+// authenticator verifies the access token of a request, it is shared by the Gin, Echo and
+// net/http middlewares.
+type authenticator struct {
+	token      Token
+	log        logger.Logger
+	extractors []TokenExtractor
+}
+
+// newAuthenticator creates an authenticator extracting the token with BearerHeader when no
+// extractor is given.
+func newAuthenticator(token Token, log logger.Logger, extractors []TokenExtractor) authenticator {
+	if len(extractors) == 0 {
+		extractors = []TokenExtractor{BearerHeader()}
+	}
+	return authenticator{token: token, log: log, extractors: extractors}
+}
+
+// authenticate extracts and verifies the access token of the request with the first
+// extractor finding one.
+// Parameters:
+// - r: The HTTP request.
+// Returns:
+// - context.Context: The request context carrying the trace ID, and the identity and the claims of the caller.
+// - *Claims: The verified claims.
+// - string: The trace ID of the request, reused or generated.
+// - error: An error if the request carries no valid token.
+func (a authenticator) authenticate(r *http.Request) (context.Context, *Claims, string, error) {
+
+	// Reuse the trace ID of the request or generate a unique one.
+	ctx := r.Context()
+	traceID, ok := wotop.TraceIDFromContext(ctx)
+	if !ok {
+		traceID = util.GenerateID(16)
+		ctx = logger.SetTraceID(ctx, traceID)
+	}
+
+	token, err := a.extract(r)
+	if err != nil {
+		a.log.Error(ctx, err.Error())
+		return ctx, nil, traceID, err
+	}
+
+	_, claims, err := a.token.VerifyToken(token)
+	if err != nil {
+		a.log.Error(ctx, err.Error())
+		return ctx, nil, traceID, err
+	}
+
+	// Carry the identity in the request context, so interactors can read it with wotop.IdentityFromContext.
+	ctx = wotop.WithIdentity(ctx, wotop.Identity{
+		ID:     claims.ID,
+		Role:   claims.Role,
+		Tenant: claims.Tenant,
+	})
+	ctx = WithClaims(ctx, claims)
+
+	return ctx, claims, traceID, nil
+}
+
+// extract returns the token found by the first extractor finding one.
+func (a authenticator) extract(r *http.Request) (string, error) {
+	for _, extract := range a.extractors {
+		if token, err := extract(r); err == nil {
+			return token, nil
+		}
+	}
+	return "", ErrUnauthorized
+}
+
+// HTTPMiddleware returns a net/http middleware authenticating the requests like
+// GinMiddleware.Authentication: the identity and the claims of the caller are carried by the
+// request context, see wotop.IdentityFromContext and ClaimsFromContext, and requests without
+// a valid token are answered with 401 and a payload.Response.
+// Parameters:
+// - jwt: The Token verifying the access tokens.
+// - log: The logger of the failures.
+// - extractors: Where the access token is looked for, BearerHeader by default.
+// Returns:
+// - func(http.Handler) http.Handler: The middleware.
+func HTTPMiddleware(jwt Token, log logger.Logger, extractors ...TokenExtractor) func(http.Handler) http.Handler {
+	auth := newAuthenticator(jwt, log, extractors)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, _, traceID, err := auth.authenticate(r)
+			if err != nil {
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				w.WriteHeader(http.StatusUnauthorized)
+				_ = json.NewEncoder(w).Encode(payload.NewErrorResponse(err, traceID))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/model/payload"
+	"github.com/a-aslani/wotop/util"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// authRequests are the requests every middleware is tested with.
+func authRequests(accessToken string) []struct {
+	name       string
+	req        *http.Request
+	wantStatus int
+} {
+	bearer := httptest.NewRequest(http.MethodGet, "/me", nil)
+	bearer.Header.Set("Authorization", "Bearer "+accessToken)
+
+	cookie := httptest.NewRequest(http.MethodGet, "/me", nil)
+	cookie.AddCookie(&http.Cookie{Name: "access_token", Value: accessToken})
+
+	malformed := httptest.NewRequest(http.MethodGet, "/me", nil)
+	malformed.Header.Set("Authorization", "Bearer")
+
+	invalid := httptest.NewRequest(http.MethodGet, "/me", nil)
+	invalid.Header.Set("Authorization", "Bearer not-a-token")
+
+	return []struct {
+		name       string
+		req        *http.Request
+		wantStatus int
+	}{
+		{"bearer header", bearer, http.StatusOK},
+		{"cookie", cookie, http.StatusOK},
+		{"missing token", httptest.NewRequest(http.MethodGet, "/me", nil), http.StatusUnauthorized},
+		{"malformed header", malformed, http.StatusUnauthorized},
+		{"invalid token", invalid, http.StatusUnauthorized},
+	}
+}
+
+// assertAuthenticated checks the context populated by a middleware.
+func assertAuthenticated(t *testing.T, ctx context.Context) {
+	identity, ok := wotop.IdentityFromContext(ctx)
+	require.True(t, ok)
+	assert.Equal(t, wotop.Identity{ID: "user-1", Role: "admin", Tenant: "acme"}, identity)
+
+	claims, ok := ClaimsFromContext(ctx)
+	require.True(t, ok)
+	assert.Equal(t, "user-1", claims.Subject)
+
+	_, ok = wotop.TraceIDFromContext(ctx)
+	assert.True(t, ok)
+}
+
+// assertUnauthorized checks the 401 envelope written by a middleware.
+func assertUnauthorized(t *testing.T, rec *httptest.ResponseRecorder) {
+	var res payload.Response
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.False(t, res.Success)
+	assert.Equal(t, ErrUnauthorized.Code(), res.ErrorCode)
+	assert.NotEmpty(t, res.TraceID)
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	token := newTestToken(t, util.SystemClock)
+	accessToken, _, _, _, err := token.GenerateToken(context.Background(), "user-1", "admin", "user-1", "acme")
+	require.NoError(t, err)
+
+	handler := HTTPMiddleware(token, nopLogger{}, BearerHeader(), Cookie("access_token"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assertAuthenticated(t, r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, tt := range authRequests(accessToken) {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, tt.req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus == http.StatusUnauthorized {
+				assertUnauthorized(t, rec)
+			}
+		})
+	}
+}
+
+func TestHTTPMiddlewareDefaultsToBearerHeader(t *testing.T) {
+	token := newTestToken(t, util.SystemClock)
+	accessToken, _, _, _, err := token.GenerateToken(context.Background(), "user-1", "admin", "user-1", "acme")
+	require.NoError(t, err)
+
+	handler := HTTPMiddleware(token, nopLogger{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.AddCookie(&http.Cookie{Name: "access_token", Value: accessToken})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestEchoMiddleware(t *testing.T) {
+	token := newTestToken(t, util.SystemClock)
+	accessToken, _, _, _, err := token.GenerateToken(context.Background(), "user-1", "admin", "user-1", "acme")
+	require.NoError(t, err)
+
+	e := echo.New()
+	e.GET("/me", func(c echo.Context) error {
+		assertAuthenticated(t, c.Request().Context())
+		assert.Equal(t, "acme", c.Get("Tenant"))
+		return c.NoContent(http.StatusOK)
+	}, EchoMiddleware(token, nopLogger{}, BearerHeader(), Cookie("access_token")))
+
+	for _, tt := range authRequests(accessToken) {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, tt.req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus == http.StatusUnauthorized {
+				assertUnauthorized(t, rec)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package jwt
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/a-aslani/wotop/util"
+	"github.com/redis/go-redis/v9"
+)
+
+// Operation names a Token operation throttled by a RateLimiter.
+type Operation string
+
+const (
+	// OperationGenerate is GenerateToken, limited per subject.
+	OperationGenerate Operation = "generate"
+	// OperationRenew is RenewToken, limited per refresh token (its jti).
+	OperationRenew Operation = "renew"
+)
+
+// RateLimiter throttles the operations of a Token, e.g. against credential stuffing.
+type RateLimiter interface {
+	// Allow consumes an attempt of the key.
+	// Parameters:
+	// - ctx: The context for the operation.
+	// - key: The throttled key, the subject or the jti of the refresh token.
+	// Returns:
+	// - error: A *RateLimitError if the key has no attempt left, or an error if the limiter fails.
+	Allow(ctx context.Context, key string) error
+}
+
+// RateLimit is the number of attempts a key is allowed in a window. A zero or negative Limit
+// or Window disables the limit, e.g. when the section is left out of the configuration.
+type RateLimit struct {
+	Limit  int           `mapstructure:"limit"`
+	Window time.Duration `mapstructure:"window"`
+}
+
+// disabled reports whether the limit allows every attempt.
+func (r RateLimit) disabled() bool {
+	return r.Limit <= 0 || r.Window <= 0
+}
+
+// unlimited is the RateLimiter of a disabled RateLimit, it allows every attempt.
+type unlimited struct{}
+
+func (unlimited) Allow(context.Context, string) error {
+	return nil
+}
+
+// RateLimitError is returned by a RateLimiter refusing an attempt, it wraps ErrRateLimited,
+// which is answered with 429 Too Many Requests.
+type RateLimitError struct {
+	Key        string        // The throttled key.
+	RetryAfter time.Duration // The time until the next attempt is allowed.
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrRateLimited.Error(), e.RetryAfter)
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// WithRateLimiter throttles an operation of the Token with the limiter, before any token is
+// parsed or stored.
+// Parameters:
+// - op: The throttled operation, OperationGenerate or OperationRenew.
+// - limiter: The limiter of the operation, e.g. NewRedisRateLimiter.
+// Returns:
+// - Option: The option setting the limiter of the operation.
+func WithRateLimiter(op Operation, limiter RateLimiter) Option {
+	return func(t *token) {
+		if t.limiters == nil {
+			t.limiters = map[Operation]RateLimiter{}
+		}
+		t.limiters[op] = limiter
+	}
+}
+
+// allow consumes an attempt of the key for the operation, it allows every attempt of an
+// operation without limiter.
+// Parameters:
+// - ctx: The context for the operation.
+// - op: The operation.
+// - key: The throttled key.
+// Returns:
+// - error: The error of the limiter.
+func (t *token) allow(ctx context.Context, op Operation, key string) error {
+	limiter, ok := t.limiters[op]
+	if !ok {
+		return nil
+	}
+	return limiter.Allow(ctx, key)
+}
+
+// slidingWindowScript counts the attempts of the key in the window ending now and records
+// the attempt when the limit is not reached. It returns 0 when the attempt is allowed, or
+// the milliseconds until the oldest attempt leaves the window.
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call("ZREMRANGEBYSCORE", key, "-inf", now - window)
+if redis.call("ZCARD", key) < limit then
+	redis.call("ZADD", key, now, ARGV[4])
+	redis.call("PEXPIRE", key, window)
+	return 0
+end
+
+local oldest = redis.call("ZRANGE", key, 0, 0, "WITHSCORES")
+return math.max(tonumber(oldest[2]) + window - now, 1)
+`)
+
+// redisRateLimiter is the RateLimiter returned by NewRedisRateLimiter.
+type redisRateLimiter struct {
+	rdb   redis.UniversalClient
+	name  string
+	limit RateLimit
+	clock util.Clock
+}
+
+// NewRedisRateLimiter creates a RateLimiter sharing its sliding windows between the replicas
+// in Redis, a key is allowed limit.Limit attempts in any limit.Window. A disabled limit allows
+// every attempt.
+// Parameters:
+// - rdb: The Redis client.
+// - name: The name of the limiter in its Redis keys, e.g. "login".
+// - limit: The allowed attempts.
+// - clock: The clock, util.SystemClock in production.
+// Returns:
+// - RateLimiter: The Redis limiter.
+func NewRedisRateLimiter(rdb redis.UniversalClient, name string, limit RateLimit, clock util.Clock) RateLimiter {
+	if limit.disabled() {
+		return unlimited{}
+	}
+	return &redisRateLimiter{rdb: rdb, name: name, limit: limit, clock: clock}
+}
+
+func (l *redisRateLimiter) Allow(ctx context.Context, key string) error {
+	now := l.clock.Now().UnixMilli()
+	member := strconv.FormatInt(now, 10) + "-" + util.GenerateID(8)
+
+	retryAfter, err := slidingWindowScript.Run(ctx, l.rdb,
+		[]string{"wotop:ratelimit:" + l.name + ":" + key},
+		now, l.limit.Window.Milliseconds(), l.limit.Limit, member,
+	).Int64()
+	if err != nil {
+		return fmt.Errorf("rate limit %s: %w", l.name, err)
+	}
+
+	if retryAfter > 0 {
+		return &RateLimitError{Key: key, RetryAfter: time.Duration(retryAfter) * time.Millisecond}
+	}
+	return nil
+}
+
+// bucket is the token bucket of a key of memoryRateLimiter.
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// memoryRateLimiter is the RateLimiter returned by NewMemoryRateLimiter.
+type memoryRateLimiter struct {
+	mu      sync.Mutex
+	limit   RateLimit
+	clock   util.Clock
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+// NewMemoryRateLimiter creates a RateLimiter keeping a token bucket per key in memory, for a
+// single replica. A bucket holds limit.Limit attempts and is refilled at limit.Limit attempts
+// per limit.Window. A disabled limit allows every attempt.
+// Parameters:
+// - limit: The allowed attempts.
+// - clock: The clock, util.SystemClock in production.
+// Returns:
+// - RateLimiter: The in-memory limiter.
+func NewMemoryRateLimiter(limit RateLimit, clock util.Clock) RateLimiter {
+	if limit.disabled() {
+		return unlimited{}
+	}
+	return &memoryRateLimiter{limit: limit, clock: clock, buckets: map[string]*bucket{}, swept: clock.Now()}
+}
+
+func (l *memoryRateLimiter) Allow(_ context.Context, key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.limit.Limit), updated: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = l.refill(b, now)
+	b.updated = now
+
+	if b.tokens < 1 {
+		wait := (1 - b.tokens) / l.rate()
+		return &RateLimitError{Key: key, RetryAfter: time.Duration(math.Ceil(wait))}
+	}
+
+	b.tokens--
+	return nil
+}
+
+// rate returns the refill rate of the buckets, in attempts per nanosecond.
+func (l *memoryRateLimiter) rate() float64 {
+	return float64(l.limit.Limit) / float64(l.limit.Window)
+}
+
+// refill returns the tokens of the bucket at now.
+func (l *memoryRateLimiter) refill(b *bucket, now time.Time) float64 {
+	return math.Min(float64(l.limit.Limit), b.tokens+float64(now.Sub(b.updated))*l.rate())
+}
+
+// sweep forgets the full buckets once per window, so the idle keys do not pile up.
+func (l *memoryRateLimiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < l.limit.Window {
+		return
+	}
+	l.swept = now
+
+	for key, b := range l.buckets {
+		if l.refill(b, now) >= float64(l.limit.Limit) {
+			delete(l.buckets, key)
+		}
+	}
+}
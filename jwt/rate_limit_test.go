@@ -0,0 +1,155 @@
+package jwt
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop/model/apperror"
+	"github.com/a-aslani/wotop/util"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// exhaust consumes the attempts of the key and returns the error of the first refused one.
+func exhaust(t *testing.T, limiter RateLimiter, key string, allowed int) *RateLimitError {
+	t.Helper()
+
+	for i := range allowed {
+		require.NoError(t, limiter.Allow(context.Background(), key), "attempt %d", i+1)
+	}
+
+	err := limiter.Allow(context.Background(), key)
+	var rle *RateLimitError
+	require.ErrorAs(t, err, &rle)
+	assert.ErrorIs(t, err, ErrRateLimited)
+	return rle
+}
+
+func TestMemoryRateLimiter(t *testing.T) {
+	clock := util.NewFrozenClock(t0)
+	limiter := NewMemoryRateLimiter(RateLimit{Limit: 3, Window: time.Minute}, clock)
+
+	rle := exhaust(t, limiter, "user-1", 3)
+	assert.Equal(t, 20*time.Second, rle.RetryAfter)
+
+	// the other keys have their own bucket
+	require.NoError(t, limiter.Allow(context.Background(), "user-2"))
+
+	// an attempt is refilled every 20 seconds
+	clock.Set(t0.Add(20 * time.Second))
+	exhaust(t, limiter, "user-1", 1)
+
+	// the bucket is full again after the window
+	clock.Set(t0.Add(2 * time.Minute))
+	exhaust(t, limiter, "user-1", 3)
+}
+
+func TestMemoryRateLimiterForgetsIdleKeys(t *testing.T) {
+	clock := util.NewFrozenClock(t0)
+	limiter := NewMemoryRateLimiter(RateLimit{Limit: 3, Window: time.Minute}, clock).(*memoryRateLimiter)
+
+	require.NoError(t, limiter.Allow(context.Background(), "user-1"))
+	require.NoError(t, limiter.Allow(context.Background(), "user-2"))
+	assert.Len(t, limiter.buckets, 2)
+
+	clock.Set(t0.Add(time.Minute))
+	require.NoError(t, limiter.Allow(context.Background(), "user-3"))
+	assert.Len(t, limiter.buckets, 1)
+}
+
+func TestRedisRateLimiter(t *testing.T) {
+	clock := util.NewFrozenClock(t0)
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	limiter := NewRedisRateLimiter(rdb, "login", RateLimit{Limit: 3, Window: time.Minute}, clock)
+
+	clock.Set(t0.Add(10 * time.Second))
+	require.NoError(t, limiter.Allow(context.Background(), "user-1"))
+	clock.Set(t0.Add(30 * time.Second))
+
+	// the window slides from the first attempt
+	rle := exhaust(t, limiter, "user-1", 2)
+	assert.Equal(t, 40*time.Second, rle.RetryAfter)
+
+	// another replica shares the window
+	replica := NewRedisRateLimiter(rdb, "login", RateLimit{Limit: 3, Window: time.Minute}, clock)
+	assert.ErrorIs(t, replica.Allow(context.Background(), "user-1"), ErrRateLimited)
+
+	// the first attempt leaves the window, the refused ones are not counted
+	clock.Set(t0.Add(70 * time.Second))
+	exhaust(t, limiter, "user-1", 1)
+
+	clock.Set(t0.Add(90 * time.Second))
+	exhaust(t, limiter, "user-1", 2)
+
+	clock.Set(t0.Add(150 * time.Second))
+	exhaust(t, limiter, "user-1", 3)
+}
+
+func TestDisabledRateLimit(t *testing.T) {
+	clock := util.NewFrozenClock(t0)
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+
+	for _, limit := range []RateLimit{{}, {Limit: 3}, {Window: time.Minute}, {Limit: -1, Window: time.Minute}} {
+		for _, limiter := range []RateLimiter{NewMemoryRateLimiter(limit, clock), NewRedisRateLimiter(rdb, "login", limit, clock)} {
+			for range 10 {
+				require.NoError(t, limiter.Allow(context.Background(), "user-1"), "%+v", limit)
+			}
+		}
+	}
+}
+
+func TestTokenRateLimiters(t *testing.T) {
+	clock := util.NewFrozenClock(t0)
+	token := newTestToken(t, clock,
+		WithRateLimiter(OperationGenerate, NewMemoryRateLimiter(RateLimit{Limit: 2, Window: time.Minute}, clock)),
+		WithRateLimiter(OperationRenew, NewMemoryRateLimiter(RateLimit{Limit: 1, Window: time.Minute}, clock)),
+	)
+	ctx := context.Background()
+
+	accessToken, refreshToken, csrf, _, err := token.GenerateToken(ctx, "user-1", "admin", "user-1", "acme")
+	require.NoError(t, err)
+	_, _, _, _, err = token.GenerateToken(ctx, "user-1", "admin", "user-1", "acme")
+	require.NoError(t, err)
+	_, _, _, _, err = token.GenerateToken(ctx, "user-1", "admin", "user-1", "acme")
+	assert.ErrorIs(t, err, ErrRateLimited)
+
+	// the other subjects are not throttled
+	_, _, _, _, err = token.GenerateToken(ctx, "user-2", "admin", "user-2", "acme")
+	require.NoError(t, err)
+
+	_, _, _, _, _, err = token.RenewToken(ctx, accessToken, refreshToken, csrf)
+	require.NoError(t, err)
+	_, _, _, _, _, err = token.RenewToken(ctx, accessToken, refreshToken, csrf)
+	assert.ErrorIs(t, err, ErrRateLimited)
+
+	clock.Set(t0.Add(time.Minute))
+	_, _, _, _, err = token.GenerateToken(ctx, "user-1", "admin", "user-1", "acme")
+	require.NoError(t, err)
+}
+
+func TestGinRateLimitErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(NewGinMiddleware(nil).RateLimitErrors())
+	router.POST("/login", func(c *gin.Context) {
+		_ = c.Error(&RateLimitError{Key: "user-1", RetryAfter: 1500 * time.Millisecond})
+	})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/login", nil))
+
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("Retry-After"))
+	assert.Contains(t, rec.Body.String(), `"error_code":"ER0211"`)
+
+	status, ok := apperror.HTTPStatus(ErrRateLimited)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusTooManyRequests, status)
+}
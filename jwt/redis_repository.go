@@ -2,6 +2,7 @@ package jwt
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/redis/go-redis/v9"
@@ -19,8 +20,41 @@ type RedisRepository struct {
 	rdb *redis.Client
 }
 
-// Ensure RedisRepository implements the Repository interface.
-var _ Repository = (*RedisRepository)(nil)
+// Ensure RedisRepository implements the Repository, DeviceBindingRepository,
+// ActionTokenRepository and PagedRepository interfaces.
+var (
+	_ Repository              = (*RedisRepository)(nil)
+	_ DeviceBindingRepository = (*RedisRepository)(nil)
+	_ ActionTokenRepository   = (*RedisRepository)(nil)
+	_ PagedRepository         = (*RedisRepository)(nil)
+)
+
+// scanBatchSize is the COUNT hint of the SCAN steps of FindAllRefreshTokens and
+// FindAllBlockedTokens, each step reads its values with one MGET.
+const scanBatchSize = 1000
+
+// boundRefreshToken is the value of a refresh token bound to a device. The value of an
+// unbound token is its subject, as it was before the binding existed.
+type boundRefreshToken struct {
+	Subject     string `json:"sub"`
+	Fingerprint string `json:"fp"`
+}
+
+// decodeRefreshToken decodes the value of a refresh token key.
+//
+// Parameters:
+//   - value: The JSON of a bound token, or the subject of an unbound one.
+//
+// Returns:
+//   - The subject of the token.
+//   - The fingerprint of its device, empty for an unbound token.
+func decodeRefreshToken(value string) (sub, fingerprint string) {
+	var bound boundRefreshToken
+	if strings.HasPrefix(value, "{") && json.Unmarshal([]byte(value), &bound) == nil && bound.Fingerprint != "" {
+		return bound.Subject, bound.Fingerprint
+	}
+	return value, ""
+}
 
 // NewRedisRepository creates a new instance of RedisRepository.
 //
@@ -68,14 +102,59 @@ func (r RedisRepository) DeleteRefreshToken(ctx context.Context, jti string) err
 //   - The subject (user ID) associated with the token.
 //   - An error if the token is not found or the operation fails.
 func (r RedisRepository) FindRefreshToken(ctx context.Context, jti string) (sub string, err error) {
-	sub, err = r.rdb.Get(ctx, fmt.Sprintf("%s:%s", RefreshTokenTableName, jti)).Result()
+	value, err := r.rdb.Get(ctx, fmt.Sprintf("%s:%s", RefreshTokenTableName, jti)).Result()
 	if errors.Is(err, redis.Nil) {
 		err = ErrTokenAlreadyRefreshed
 		return
 	}
+	sub, _ = decodeRefreshToken(value)
 	return
 }
 
+// StoreBoundRefreshToken stores a refresh token bound to a device in Redis, its value is
+// the JSON of its subject and fingerprint.
+//
+// Parameters:
+//   - ctx: The context for the operation.
+//   - sub: The subject (user ID) associated with the token.
+//   - jti: The unique identifier for the token.
+//   - fingerprint: The fingerprint of the device, an empty one stores an unbound token.
+//
+// Returns:
+//   - An error if the operation fails.
+func (r RedisRepository) StoreBoundRefreshToken(ctx context.Context, sub, jti, fingerprint string) error {
+	if fingerprint == "" {
+		return r.StoreRefreshToken(ctx, sub, jti)
+	}
+
+	value, err := json.Marshal(boundRefreshToken{Subject: sub, Fingerprint: fingerprint})
+	if err != nil {
+		return err
+	}
+	return r.rdb.Set(ctx, fmt.Sprintf("%s:%s", RefreshTokenTableName, jti), value, 0).Err()
+}
+
+// FindRefreshTokenFingerprint retrieves the device fingerprint of a refresh token from Redis.
+//
+// Parameters:
+//   - ctx: The context for the operation.
+//   - jti: The unique identifier for the token.
+//
+// Returns:
+//   - The fingerprint, empty for an unbound token.
+//   - An error if the token is not found or the operation fails.
+func (r RedisRepository) FindRefreshTokenFingerprint(ctx context.Context, jti string) (string, error) {
+	value, err := r.rdb.Get(ctx, fmt.Sprintf("%s:%s", RefreshTokenTableName, jti)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrTokenAlreadyRefreshed
+	}
+	if err != nil {
+		return "", err
+	}
+	_, fingerprint := decodeRefreshToken(value)
+	return fingerprint, nil
+}
+
 // FindAllRefreshTokens retrieves all refresh tokens from Redis.
 //
 // Parameters:
@@ -87,28 +166,153 @@ func (r RedisRepository) FindRefreshToken(ctx context.Context, jti string) (sub
 func (r RedisRepository) FindAllRefreshTokens(ctx context.Context) ([]RefreshToken, error) {
 	tokens := make([]RefreshToken, 0)
 
-	keys, err := r.rdb.Keys(ctx, fmt.Sprintf("%s:*", RefreshTokenTableName)).Result()
+	err := r.scanAll(ctx, fmt.Sprintf("%s:*", RefreshTokenTableName), func(keys, values []string) error {
+		for i, key := range keys {
+			sub, fingerprint := decodeRefreshToken(values[i])
+			tokens = append(tokens, RefreshToken{
+				Subject:     sub,
+				JTI:         strings.TrimPrefix(key, RefreshTokenTableName+":"),
+				Fingerprint: fingerprint,
+			})
+		}
+		return nil
+	})
 	if err != nil {
 		return tokens, err
 	}
 
-	for _, key := range keys {
-		sub, err := r.rdb.Get(ctx, key).Result()
-		if err != nil {
-			return tokens, err
+	return tokens, nil
+}
+
+// FindRefreshTokensPage retrieves a page of the refresh tokens from Redis with SCAN, which
+// does not block Redis as KEYS does, and reads their values with a single MGET. As SCAN does,
+// a token stored or deleted during the iteration may be missed, and a token may be returned
+// twice.
+//
+// Parameters:
+//   - ctx: The context for the operation.
+//   - cursor: The cursor returned with the previous page, empty for the first page.
+//   - limit: The COUNT hint of SCAN.
+//
+// Returns:
+//   - The tokens of the page, possibly none before the last page.
+//   - The cursor of the next page, empty after the last page.
+//   - An error if the cursor is invalid or the operation fails.
+func (r RedisRepository) FindRefreshTokensPage(ctx context.Context, cursor string, limit int) ([]RefreshToken, string, error) {
+	var scanCursor uint64
+	if cursor != "" {
+		var err error
+		if scanCursor, err = strconv.ParseUint(cursor, 10, 64); err != nil {
+			return nil, "", fmt.Errorf("jwt: invalid refresh token cursor %q: %w", cursor, err)
 		}
+	}
 
-		jti := strings.Split(key, ":")[1]
-		tokens = append(tokens, RefreshToken{
-			Subject: sub,
-			JTI:     jti,
-		})
+	keys, values, next, err := r.scanPage(ctx, scanCursor, fmt.Sprintf("%s:*", RefreshTokenTableName), limit)
+	if err != nil {
+		return nil, "", err
 	}
 
-	return tokens, nil
+	nextCursor := ""
+	if next != 0 {
+		nextCursor = strconv.FormatUint(next, 10)
+	}
+
+	tokens := make([]RefreshToken, len(keys))
+	for i, key := range keys {
+		sub, fingerprint := decodeRefreshToken(values[i])
+		tokens[i] = RefreshToken{
+			Subject:     sub,
+			JTI:         strings.TrimPrefix(key, RefreshTokenTableName+":"),
+			Fingerprint: fingerprint,
+		}
+	}
+
+	return tokens, nextCursor, nil
+}
+
+// scanPage runs one SCAN step on the keys matching a pattern and reads their values with a
+// single MGET.
+//
+// Parameters:
+//   - ctx: The context for the operation.
+//   - cursor: The SCAN cursor, 0 for the first step.
+//   - pattern: The MATCH pattern.
+//   - count: The COUNT hint.
+//
+// Returns:
+//   - The keys found, without the ones deleted since the scan.
+//   - Their values.
+//   - The cursor of the next step, 0 after the last one.
+//   - An error if the operation fails.
+func (r RedisRepository) scanPage(ctx context.Context, cursor uint64, pattern string, count int) ([]string, []string, uint64, error) {
+	keys, next, err := r.rdb.Scan(ctx, cursor, pattern, int64(count)).Result()
+	if err != nil || len(keys) == 0 {
+		return nil, nil, next, err
+	}
+
+	found, err := r.rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	values := make([]string, 0, len(keys))
+	kept := keys[:0]
+	for i, key := range keys {
+		value, ok := found[i].(string)
+		if !ok {
+			// deleted since the scan
+			continue
+		}
+		kept = append(kept, key)
+		values = append(values, value)
+	}
+
+	return kept, values, next, nil
 }
 
-// StoreBlockedToken stores a blocked token in Redis.
+// scanAll iterates with SCAN over the keys matching a pattern, in steps of scanBatchSize keys,
+// and calls fn once per key, as SCAN may return a key twice.
+//
+// Parameters:
+//   - ctx: The context for the operation.
+//   - pattern: The MATCH pattern.
+//   - fn: The function called with the keys and their values, it may stop the iteration with an error.
+//
+// Returns:
+//   - An error if the operation or fn fails.
+func (r RedisRepository) scanAll(ctx context.Context, pattern string, fn func(keys, values []string) error) error {
+	seen := map[string]struct{}{}
+	var cursor uint64
+
+	for {
+		keys, values, next, err := r.scanPage(ctx, cursor, pattern, scanBatchSize)
+		if err != nil {
+			return err
+		}
+
+		newKeys, newValues := keys[:0], values[:0]
+		for i, key := range keys {
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			newKeys = append(newKeys, key)
+			newValues = append(newValues, values[i])
+		}
+		if len(newKeys) > 0 {
+			if err = fn(newKeys, newValues); err != nil {
+				return err
+			}
+		}
+
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// StoreBlockedToken stores a blocked token in Redis, the key expires with the token.
 //
 // Parameters:
 //   - ctx: The context for the operation.
@@ -119,10 +323,11 @@ func (r RedisRepository) FindAllRefreshTokens(ctx context.Context) ([]RefreshTok
 // Returns:
 //   - An error if the operation fails.
 func (r RedisRepository) StoreBlockedToken(ctx context.Context, sub, token string, expiresAt int64) error {
-	return r.rdb.Set(ctx, fmt.Sprintf("%s:%s:%d", BlockedTokenTableName, sub, expiresAt), token, 0).Err()
+	return r.rdb.SetArgs(ctx, fmt.Sprintf("%s:%s:%d", BlockedTokenTableName, sub, expiresAt), token, redis.SetArgs{ExpireAt: time.Unix(expiresAt, 0)}).Err()
 }
 
-// FindAllBlockedTokens retrieves all blocked tokens from Redis.
+// FindAllBlockedTokens retrieves all blocked tokens from Redis. The keys expire with their
+// token, the expired keys stored without expiry by the earlier versions are deleted.
 //
 // Parameters:
 //   - ctx: The context for the operation.
@@ -133,34 +338,67 @@ func (r RedisRepository) StoreBlockedToken(ctx context.Context, sub, token strin
 func (r RedisRepository) FindAllBlockedTokens(ctx context.Context) ([]string, error) {
 	tokens := make([]string, 0)
 
-	keys, err := r.rdb.Keys(ctx, fmt.Sprintf("%s:*:*", BlockedTokenTableName)).Result()
-	if err != nil {
-		return tokens, err
-	}
+	err := r.scanAll(ctx, fmt.Sprintf("%s:*:*", BlockedTokenTableName), func(keys, values []string) error {
+		var expired []string
 
-	for _, key := range keys {
-		spKeys := strings.Split(key, ":")
-		expiredAtStr := spKeys[len(spKeys)-1]
+		for i, key := range keys {
+			expiredAtStr := key[strings.LastIndex(key, ":")+1:]
 
-		if expiredAtStr != "" {
-			expiredAt, err := strconv.ParseInt(expiredAtStr, 10, 64)
-			if err != nil {
-				continue
-			}
+			if expiredAtStr != "" {
+				expiredAt, err := strconv.ParseInt(expiredAtStr, 10, 64)
+				if err != nil {
+					continue
+				}
 
-			if expiredAt <= time.Now().Unix() {
-				r.rdb.Del(ctx, key)
-				continue
+				if expiredAt <= time.Now().Unix() {
+					expired = append(expired, key)
+					continue
+				}
 			}
-		}
 
-		token, err := r.rdb.Get(ctx, key).Result()
-		if err != nil {
-			return tokens, err
+			tokens = append(tokens, values[i])
 		}
 
-		tokens = append(tokens, token)
+		if len(expired) > 0 {
+			return r.rdb.Del(ctx, expired...).Err()
+		}
+		return nil
+	})
+	if err != nil {
+		return tokens, err
 	}
 
 	return tokens, nil
 }
+
+// StoreActionToken stores the ID of an action token in Redis, the key expires with the token.
+//
+// Parameters:
+//   - ctx: The context for the operation.
+//   - sub: The subject (user ID) associated with the token.
+//   - jti: The unique identifier for the token.
+//   - expiresAt: The expiration time of the token in Unix timestamp format.
+//
+// Returns:
+//   - An error if the operation fails.
+func (r RedisRepository) StoreActionToken(ctx context.Context, sub, jti string, expiresAt int64) error {
+	return r.rdb.SetArgs(ctx, fmt.Sprintf("%s:%s", ActionTokenTableName, jti), sub, redis.SetArgs{ExpireAt: time.Unix(expiresAt, 0)}).Err()
+}
+
+// ConsumeActionToken deletes the ID of an action token from Redis with GETDEL, so of
+// concurrent consumptions one only finds it.
+//
+// Parameters:
+//   - ctx: The context for the operation.
+//   - jti: The unique identifier for the token.
+//
+// Returns:
+//   - The subject (user ID) associated with the token.
+//   - ErrActionTokenUsed if the token is not found, an error if the operation fails.
+func (r RedisRepository) ConsumeActionToken(ctx context.Context, jti string) (string, error) {
+	sub, err := r.rdb.GetDel(ctx, fmt.Sprintf("%s:%s", ActionTokenTableName, jti)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrActionTokenUsed
+	}
+	return sub, err
+}
@@ -0,0 +1,87 @@
+package jwt
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRedisRepository(t testing.TB) (*RedisRepository, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	return NewRedisRepository(redis.NewClient(&redis.Options{Addr: mr.Addr()})), mr
+}
+
+func TestRedisFindAllRefreshTokens(t *testing.T) {
+	ctx := context.Background()
+	repo, mr := newTestRedisRepository(t)
+
+	// more keys than a SCAN step
+	for i := range 2*scanBatchSize + 500 {
+		require.NoError(t, mr.Set(fmt.Sprintf("%s:jti-%05d", RefreshTokenTableName, i), fmt.Sprintf("user-%d", i)))
+	}
+	require.NoError(t, repo.StoreBoundRefreshToken(ctx, "user-bound", "jti-bound", "fp-1"))
+	require.NoError(t, repo.StoreBlockedToken(ctx, "user-1", "blocked", time.Now().Add(time.Hour).Unix()))
+
+	tokens, err := repo.FindAllRefreshTokens(ctx)
+	require.NoError(t, err)
+	require.Len(t, tokens, 2*scanBatchSize+501)
+
+	found := map[string]RefreshToken{}
+	for _, token := range tokens {
+		found[token.JTI] = token
+	}
+	assert.Len(t, found, len(tokens), "each token is returned once")
+	assert.Equal(t, RefreshToken{Subject: "user-42", JTI: "jti-00042"}, found["jti-00042"])
+	assert.Equal(t, RefreshToken{Subject: "user-bound", JTI: "jti-bound", Fingerprint: "fp-1"}, found["jti-bound"])
+}
+
+func TestRedisBlockedTokensExpire(t *testing.T) {
+	ctx := context.Background()
+	repo, mr := newTestRedisRepository(t)
+	now := time.Now()
+
+	require.NoError(t, repo.StoreBlockedToken(ctx, "user-1", "blocked-1", now.Add(time.Hour).Unix()))
+	key := fmt.Sprintf("%s:user-1:%d", BlockedTokenTableName, now.Add(time.Hour).Unix())
+	assert.InDelta(t, time.Hour.Seconds(), mr.TTL(key).Seconds(), 2, "the key expires with the token")
+
+	// the keys stored without expiry by the earlier versions
+	legacyExpired := fmt.Sprintf("%s:user-2:%d", BlockedTokenTableName, now.Add(-time.Minute).Unix())
+	require.NoError(t, mr.Set(legacyExpired, "blocked-2"))
+	require.NoError(t, mr.Set(fmt.Sprintf("%s:user-3:%d", BlockedTokenTableName, now.Add(time.Hour).Unix()), "blocked-3"))
+
+	tokens, err := repo.FindAllBlockedTokens(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"blocked-1", "blocked-3"}, tokens)
+	assert.False(t, mr.Exists(legacyExpired), "the expired legacy key is deleted")
+
+	mr.FastForward(time.Hour + time.Second)
+	tokens, err = repo.FindAllBlockedTokens(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"blocked-3"}, tokens)
+}
+
+// BenchmarkRedisFindAllRefreshTokens reads 100k refresh tokens with SCAN and MGET, one round
+// trip per 1000 keys instead of one per key.
+func BenchmarkRedisFindAllRefreshTokens(b *testing.B) {
+	repo, mr := newTestRedisRepository(b)
+	for i := range 100000 {
+		if err := mr.Set(fmt.Sprintf("%s:jti-%06d", RefreshTokenTableName, i), fmt.Sprintf("user-%d", i)); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		tokens, err := repo.FindAllRefreshTokens(context.Background())
+		if err != nil || len(tokens) != 100000 {
+			b.Fatal(len(tokens), err)
+		}
+	}
+}
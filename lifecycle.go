@@ -0,0 +1,237 @@
+package wotop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// DefaultShutdownTimeout is the time a Lifecycle gives its components to stop.
+const DefaultShutdownTimeout = 10 * time.Second
+
+// ControllerStopper is implemented by controllers that can be stopped gracefully. A Lifecycle
+// stops the registered controllers implementing it.
+type ControllerStopper interface {
+	// Stop shuts the controller down, giving up when the context is done.
+	//
+	// Parameters:
+	//   - ctx: The context bounding the shutdown.
+	//
+	// Returns:
+	//   - An error if the controller cannot be stopped gracefully.
+	Stop(ctx context.Context) error
+}
+
+// LifecycleHooks are called by a Lifecycle around the run of its components, see WithHooks.
+type LifecycleHooks interface {
+	// OnReady is called once the components are started, e.g. to register the health checks
+	// or to log that the application accepts traffic.
+	//
+	// Parameters:
+	//   - ctx: The context of the application, canceled when the Lifecycle stops.
+	//
+	// Returns:
+	//   - An error stopping the Lifecycle.
+	OnReady(ctx context.Context) error
+
+	// OnStopping is called before the components are stopped, e.g. to fail the readiness probe
+	// or to flush the logs.
+	//
+	// Parameters:
+	//   - ctx: The context bounding the shutdown.
+	//
+	// Returns:
+	//   - An error returned by Run, the components are stopped anyway.
+	OnStopping(ctx context.Context) error
+}
+
+// lifecycleComponent is a component started and stopped by a Lifecycle.
+type lifecycleComponent struct {
+	name  string
+	start func(ctx context.Context) error
+	stop  func(ctx context.Context) error
+}
+
+// Lifecycle runs the controllers, consumers and servers of an application together. Run starts
+// them in registration order, waits for SIGINT, SIGTERM or the first fatal error, then stops
+// them in reverse order.
+type Lifecycle struct {
+	components      []lifecycleComponent
+	hooks           LifecycleHooks
+	shutdownTimeout time.Duration
+	signals         []os.Signal
+}
+
+// NewLifecycle creates an empty Lifecycle stopping its components within DefaultShutdownTimeout.
+//
+// Returns:
+//   - A new Lifecycle.
+func NewLifecycle() *Lifecycle {
+	return &Lifecycle{
+		shutdownTimeout: DefaultShutdownTimeout,
+		signals:         []os.Signal{syscall.SIGINT, syscall.SIGTERM},
+	}
+}
+
+// WithShutdownTimeout sets the time the components have to stop, all together.
+//
+// Parameters:
+//   - d: The shutdown timeout, a non-positive value keeps the current one.
+//
+// Returns:
+//   - The Lifecycle, for chaining.
+func (l *Lifecycle) WithShutdownTimeout(d time.Duration) *Lifecycle {
+	if d > 0 {
+		l.shutdownTimeout = d
+	}
+	return l
+}
+
+// WithHooks sets the hooks called once the components are started and before they are stopped,
+// usually the LifecycleRunner running the Lifecycle.
+//
+// Parameters:
+//   - hooks: The hooks.
+//
+// Returns:
+//   - The Lifecycle, for chaining.
+func (l *Lifecycle) WithHooks(hooks LifecycleHooks) *Lifecycle {
+	l.hooks = hooks
+	return l
+}
+
+// Register adds a controller. Its Start method runs in its own goroutine, and it is stopped
+// with Stop when it implements ControllerStopper.
+//
+// Parameters:
+//   - starter: The controller to run.
+//
+// Returns:
+//   - The Lifecycle, for chaining.
+func (l *Lifecycle) Register(starter ControllerStarter) *Lifecycle {
+	c := lifecycleComponent{
+		name: fmt.Sprintf("%T", starter),
+		start: func(context.Context) error {
+			starter.Start()
+			return nil
+		},
+	}
+	if stopper, ok := starter.(ControllerStopper); ok {
+		c.stop = stopper.Stop
+	}
+	l.components = append(l.components, c)
+	return l
+}
+
+// RegisterFunc adds a component given by its start and stop functions. Start runs in its own
+// goroutine and may block until the component stops. An error returned by start is fatal and
+// stops the whole Lifecycle.
+//
+// Parameters:
+//   - name: The name of the component, used in the errors.
+//   - start: Starts the component, its context is canceled when the Lifecycle stops.
+//   - stop: Stops the component, it may be nil.
+//
+// Returns:
+//   - The Lifecycle, for chaining.
+func (l *Lifecycle) RegisterFunc(name string, start, stop func(ctx context.Context) error) *Lifecycle {
+	l.components = append(l.components, lifecycleComponent{name: name, start: start, stop: stop})
+	return l
+}
+
+// Run starts the components, calls the OnReady hook and blocks until the context is canceled,
+// a termination signal is received, a component fails or the OnReady hook fails. The OnStopping
+// hook is then called and the components are stopped in reverse order, a component that does
+// not stop within the shutdown timeout is abandoned.
+//
+// Parameters:
+//   - ctx: The context of the application.
+//
+// Returns:
+//   - The fatal error and the stop errors joined, or nil on a clean shutdown.
+func (l *Lifecycle) Run(ctx context.Context) error {
+
+	ctx, stopSignals := signal.NotifyContext(ctx, l.signals...)
+	defer stopSignals()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	fatal := make(chan error, len(l.components))
+	for _, c := range l.components {
+		go func() {
+			if err := c.start(ctx); err != nil {
+				fatal <- fmt.Errorf("%s: %w", c.name, err)
+			}
+		}()
+	}
+
+	var errs []error
+	if err := l.ready(ctx); err != nil {
+		errs = append(errs, err)
+	} else {
+		select {
+		case <-ctx.Done():
+		case err := <-fatal:
+			errs = append(errs, err)
+		}
+	}
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), l.shutdownTimeout)
+	defer cancelShutdown()
+
+	if l.hooks != nil {
+		if err := l.hooks.OnStopping(shutdownCtx); err != nil {
+			errs = append(errs, fmt.Errorf("stopping: %w", err))
+		}
+	}
+	cancel()
+
+	for i := len(l.components) - 1; i >= 0; i-- {
+		if err := l.components[i].shutdown(shutdownCtx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ready calls the OnReady hook.
+func (l *Lifecycle) ready(ctx context.Context) error {
+	if l.hooks == nil {
+		return nil
+	}
+	if err := l.hooks.OnReady(ctx); err != nil {
+		return fmt.Errorf("ready: %w", err)
+	}
+	return nil
+}
+
+// shutdown calls stop, returning when it is done or the context expires.
+func (c lifecycleComponent) shutdown(ctx context.Context) error {
+	if c.stop == nil {
+		return nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%s: not stopped: %w", c.name, err)
+	}
+
+	// buffered, so an abandoned stop does not leak its goroutine forever
+	done := make(chan error, 1)
+	go func() { done <- c.stop(ctx) }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("%s: %w", c.name, err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%s: stop timed out: %w", c.name, ctx.Err())
+	}
+}
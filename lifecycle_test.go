@@ -0,0 +1,148 @@
+package wotop
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// events records the calls of the fake components.
+type events struct {
+	mu   sync.Mutex
+	list []string
+}
+
+func (e *events) add(s string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.list = append(e.list, s)
+}
+
+func (e *events) get() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.list...)
+}
+
+// fakeController blocks in Start until it is stopped, like the HTTP controllers.
+type fakeController struct {
+	name    string
+	events  *events
+	stopped chan struct{}
+}
+
+func (f *fakeController) Start() {
+	f.events.add("start " + f.name)
+	<-f.stopped
+}
+
+func (f *fakeController) Stop(context.Context) error {
+	f.events.add("stop " + f.name)
+	close(f.stopped)
+	return nil
+}
+
+func TestLifecycleStopsInReverseOrder(t *testing.T) {
+	ev := &events{}
+	started := make(chan struct{})
+
+	l := NewLifecycle().
+		Register(&fakeController{name: "http", events: ev, stopped: make(chan struct{})}).
+		RegisterFunc("consumer", func(ctx context.Context) error {
+			ev.add("start consumer")
+			close(started)
+			return nil
+		}, func(context.Context) error {
+			ev.add("stop consumer")
+			return nil
+		})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		assert.Eventually(t, func() bool { return len(ev.get()) == 2 }, time.Second, time.Millisecond)
+		cancel()
+	}()
+
+	require.NoError(t, l.Run(ctx))
+	assert.ElementsMatch(t, []string{"start http", "start consumer"}, ev.get()[:2])
+	assert.Equal(t, []string{"stop consumer", "stop http"}, ev.get()[2:])
+}
+
+func TestLifecycleStopsOnFatalError(t *testing.T) {
+	ev := &events{}
+	fail := errors.New("connection refused")
+
+	err := NewLifecycle().
+		RegisterFunc("server", func(ctx context.Context) error {
+			<-ctx.Done()
+			return nil
+		}, func(context.Context) error {
+			ev.add("stop server")
+			return nil
+		}).
+		RegisterFunc("consumer", func(context.Context) error {
+			return fail
+		}, func(context.Context) error {
+			return errors.New("not connected")
+		}).
+		Run(context.Background())
+
+	assert.ErrorIs(t, err, fail)
+	assert.ErrorContains(t, err, "consumer: not connected")
+	assert.Equal(t, []string{"stop server"}, ev.get())
+}
+
+func TestLifecycleShutdownTimeout(t *testing.T) {
+	ev := &events{}
+
+	l := NewLifecycle().WithShutdownTimeout(50*time.Millisecond).
+		RegisterFunc("fast", func(context.Context) error { return nil }, func(context.Context) error {
+			ev.add("stop fast")
+			return nil
+		}).
+		RegisterFunc("stuck", func(context.Context) error { return nil }, func(context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	err := l.Run(ctx)
+
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "stuck: stop timed out")
+	assert.ErrorContains(t, err, "fast: not stopped")
+	assert.Empty(t, ev.get())
+}
+
+func TestLifecycleStopsOnSignal(t *testing.T) {
+	ev := &events{}
+	started := make(chan struct{})
+
+	l := NewLifecycle().RegisterFunc("server", func(context.Context) error {
+		close(started)
+		return nil
+	}, func(context.Context) error {
+		ev.add("stop server")
+		return nil
+	})
+
+	go func() {
+		<-started
+		// the signal handler is installed before the components start
+		assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGTERM))
+	}()
+
+	require.NoError(t, l.Run(context.Background()))
+	assert.Equal(t, []string{"stop server"}, ev.get())
+}
@@ -0,0 +1,22 @@
+package lock
+
+import (
+	"net/http"
+
+	"github.com/a-aslani/wotop/model/apperror"
+)
+
+const (
+	// ErrLockHeld indicates a lock acquired by another owner.
+	ErrLockHeld apperror.ErrorType = "ER0971 the lock is held by another owner"
+	// ErrNotOwner indicates a lock renewed or released by an owner which does not hold it anymore.
+	ErrNotOwner apperror.ErrorType = "ER0972 the lock is not held by this owner, it expired or was released"
+)
+
+func init() {
+	apperror.Register("lock",
+		apperror.Entry{Err: ErrLockHeld, Description: "Another replica holds the lock, the work is done by it."},
+		apperror.Entry{Err: ErrNotOwner, Description: "The lock expired and may be held by another replica, the work must stop."},
+	)
+	apperror.MapCode(ErrLockHeld.Code(), http.StatusConflict)
+}
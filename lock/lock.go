@@ -0,0 +1,244 @@
+// Package lock provides the distributed locks giving a piece of work, e.g. a janitor, an
+// outbox relay or a scheduled job, to one replica of an application at a time.
+//
+// The locks are leases, not mutexes: a lock held in Redis expires after its TTL when it is not
+// renewed, e.g. when the process is paused by a long GC or its network is partitioned, and
+// another replica can then acquire it while the first one still believes it holds it. The
+// auto-renewal and Lock.Lost narrow this window, they cannot close it. The work that must never
+// run twice should pass the fencing token of its lock to the resources it writes, which reject
+// the tokens older than the last one they saw.
+//
+// A Redis lock is as consistent as the Redis deployment: a lock acquired on a primary which
+// fails over before replicating it is lost. A PostgreSQL advisory lock lasts as long as its
+// connection, it is released by the server when the connection breaks.
+package lock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/a-aslani/wotop/logger"
+)
+
+// DefaultTTL is the TTL of the locks acquired by WithLock.
+const DefaultTTL = 30 * time.Second
+
+// Locker acquires the distributed locks.
+type Locker interface {
+	// Acquire acquires the lock of a key without waiting.
+	//
+	// Parameters:
+	//   - ctx: The context of the operation.
+	//   - key: The key of the lock.
+	//   - ttl: The time after which the lock expires when it is not renewed, e.g. when the replica crashed.
+	//
+	// Returns:
+	//   - The lock, renewed automatically every third of its TTL unless WithAutoRenew disables it.
+	//   - ErrLockHeld if another owner holds the lock, or an error if the lock cannot be checked.
+	Acquire(ctx context.Context, key string, ttl time.Duration) (Lock, error)
+}
+
+// Lock is a lock acquired by a Locker.
+type Lock interface {
+	// Key returns the key of the lock.
+	Key() string
+
+	// Token returns the fencing token of the lock, greater than the tokens of the locks of the
+	// same key acquired before.
+	Token() int64
+
+	// Renew extends the lock by a TTL from now.
+	//
+	// Parameters:
+	//   - ctx: The context of the operation.
+	//   - ttl: The new TTL of the lock.
+	//
+	// Returns:
+	//   - ErrNotOwner if the lock expired or was released, or an error if it cannot be renewed.
+	Renew(ctx context.Context, ttl time.Duration) error
+
+	// Release releases the lock and stops its auto-renewal.
+	//
+	// Parameters:
+	//   - ctx: The context of the operation.
+	//
+	// Returns:
+	//   - ErrNotOwner if the lock expired or was released before, or an error if it cannot be released.
+	Release(ctx context.Context) error
+
+	// Lost returns a channel closed when the auto-renewal finds the lock lost, expired or
+	// acquired by another owner.
+	Lost() <-chan struct{}
+}
+
+// WithLock runs fn while holding the lock of a key, acquired with DefaultTTL and renewed until
+// fn returns. The context of fn is cancelled when the lock is lost.
+//
+// Parameters:
+//   - ctx: The context of the operation.
+//   - locker: The locker.
+//   - key: The key of the lock.
+//   - fn: The work done by one owner at a time.
+//
+// Returns:
+//   - ErrLockHeld if another owner holds the lock, fn is not run then, or the error of fn, or an
+//     error if the lock cannot be acquired or released.
+func WithLock(ctx context.Context, locker Locker, key string, fn func(ctx context.Context) error) error {
+
+	l, err := locker.Acquire(ctx, key, DefaultTTL)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-l.Lost():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	err = fn(ctx)
+
+	// the context of the operation may be the one cancelled by the lost lock
+	releaseErr := l.Release(context.WithoutCancel(ctx))
+	if err != nil {
+		return err
+	}
+	if errors.Is(releaseErr, ErrNotOwner) {
+		// fn completed, the lock expired meanwhile
+		return nil
+	}
+	return releaseErr
+}
+
+// options holds the settings of the lockers.
+type options struct {
+	autoRenew bool
+	log       logger.Logger
+}
+
+// Option configures a Locker.
+type Option func(*options)
+
+// WithAutoRenew enables or disables the renewal of the locks every third of their TTL, in a
+// goroutine stopped by Lock.Release.
+//
+// Parameters:
+//   - enabled: Whether the locks are renewed, true by default.
+//
+// Returns:
+//   - The option setting the auto-renewal.
+func WithAutoRenew(enabled bool) Option {
+	return func(o *options) {
+		o.autoRenew = enabled
+	}
+}
+
+// WithLogger sets the logger of the failed renewals.
+//
+// Parameters:
+//   - log: The logger, none by default.
+//
+// Returns:
+//   - The option setting the logger.
+func WithLogger(log logger.Logger) Option {
+	return func(o *options) {
+		o.log = log
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{autoRenew: true}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// keepAlive renews a lock every third of its TTL until ctx is done. The lock is lost when it
+// is not owned anymore, or when it cannot be renewed for a TTL, e.g. while the store is down.
+//
+// Parameters:
+//   - ctx: The context cancelled by Release.
+//   - l: The lock.
+//   - ttl: The TTL the lock is renewed with.
+//   - lost: Marks the lock lost.
+//   - log: The logger of the failed renewals, optional.
+func keepAlive(ctx context.Context, l Lock, ttl time.Duration, lost func(), log logger.Logger) {
+
+	ticker := time.NewTicker(max(ttl/3, time.Millisecond))
+	defer ticker.Stop()
+
+	renewed := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := l.Renew(ctx, ttl)
+		switch {
+		case err == nil:
+			renewed = time.Now()
+			continue
+		case ctx.Err() != nil:
+			return
+		case errors.Is(err, ErrNotOwner), time.Since(renewed) >= ttl:
+			if log != nil {
+				log.Error(ctx, "lock: %s is lost: %v", l.Key(), err)
+			}
+			lost()
+			return
+		}
+
+		if log != nil {
+			log.Warning(ctx, "lock: cannot renew %s, retrying: %v", l.Key(), err)
+		}
+	}
+}
+
+// lease holds the auto-renewal state shared by the locks.
+type lease struct {
+	lost     chan struct{}
+	lostOnce sync.Once
+	stop     context.CancelFunc
+	done     chan struct{}
+}
+
+func newLease() *lease {
+	return &lease{lost: make(chan struct{}), stop: func() {}}
+}
+
+func (l *lease) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// markLost closes the Lost channel.
+func (l *lease) markLost() {
+	l.lostOnce.Do(func() { close(l.lost) })
+}
+
+// start starts the auto-renewal of the lock, see keepAlive.
+func (l *lease) start(lock Lock, ttl time.Duration, log logger.Logger) {
+	ctx, cancel := context.WithCancel(context.Background())
+	l.stop, l.done = cancel, make(chan struct{})
+	go func() {
+		defer close(l.done)
+		keepAlive(ctx, lock, ttl, l.markLost, log)
+	}()
+}
+
+// halt stops the auto-renewal and waits for it.
+func (l *lease) halt() {
+	l.stop()
+	if l.done != nil {
+		<-l.done
+	}
+}
@@ -0,0 +1,100 @@
+package lock
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// PostgresLocker is a Locker using the session advisory locks of PostgreSQL. A lock holds a
+// connection of the pool until it is released, and it is released by PostgreSQL when the
+// connection is lost, so it does not expire: the auto-renewal pings its connection instead,
+// and Lost is closed when the connection is broken.
+//
+// The fencing tokens are transaction IDs, which grow across the database, so they are greater
+// than the tokens of the locks acquired before, though not consecutive.
+type PostgresLocker struct {
+	db   *sql.DB
+	opts options
+}
+
+// NewPostgresLocker creates a PostgresLocker.
+//
+// Parameters:
+//   - db: The database.
+//   - opts: The auto-renewal and the logger.
+//
+// Returns:
+//   - The locker.
+func NewPostgresLocker(db *sql.DB, opts ...Option) *PostgresLocker {
+	return &PostgresLocker{db: db, opts: newOptions(opts)}
+}
+
+func (p *PostgresLocker) Acquire(ctx context.Context, key string, ttl time.Duration) (Lock, error) {
+
+	// the lock belongs to the session, so it is taken and released on the same connection
+	conn, err := p.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("lock %s: %w", key, err)
+	}
+
+	var (
+		acquired bool
+		token    int64
+	)
+	err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(hashtextextended($1, 0)), txid_current()", key).Scan(&acquired, &token)
+	if err != nil || !acquired {
+		_ = conn.Close()
+		if err != nil {
+			return nil, fmt.Errorf("lock %s: %w", key, err)
+		}
+		return nil, ErrLockHeld
+	}
+
+	l := &postgresLock{lease: newLease(), conn: conn, key: key, token: token}
+	if p.opts.autoRenew {
+		l.start(l, ttl, p.opts.log)
+	}
+
+	return l, nil
+}
+
+// postgresLock is a lock acquired by PostgresLocker.
+type postgresLock struct {
+	*lease
+	conn  *sql.Conn
+	key   string
+	token int64
+}
+
+func (l *postgresLock) Key() string {
+	return l.key
+}
+
+func (l *postgresLock) Token() int64 {
+	return l.token
+}
+
+// Renew checks the connection holding the lock, the TTL is not used.
+func (l *postgresLock) Renew(ctx context.Context, _ time.Duration) error {
+	if err := l.conn.PingContext(ctx); err != nil {
+		return fmt.Errorf("lock %s: %w: %v", l.key, ErrNotOwner, err)
+	}
+	return nil
+}
+
+func (l *postgresLock) Release(ctx context.Context) error {
+	l.halt()
+	defer l.conn.Close()
+
+	var released bool
+	err := l.conn.QueryRowContext(ctx, "SELECT pg_advisory_unlock(hashtextextended($1, 0))", l.key).Scan(&released)
+	if err != nil {
+		return fmt.Errorf("lock %s: %w", l.key, err)
+	}
+	if !released {
+		return ErrNotOwner
+	}
+	return nil
+}
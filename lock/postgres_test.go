@@ -0,0 +1,71 @@
+package lock
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresLocker(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	lockQuery := regexp.QuoteMeta("SELECT pg_try_advisory_lock(hashtextextended($1, 0)), txid_current()")
+	unlockQuery := regexp.QuoteMeta("SELECT pg_advisory_unlock(hashtextextended($1, 0))")
+
+	mock.ExpectQuery(lockQuery).WithArgs("job").
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock", "txid_current"}).AddRow(true, 741))
+	mock.ExpectQuery(unlockQuery).WithArgs("job").
+		WillReturnRows(sqlmock.NewRows([]string{"pg_advisory_unlock"}).AddRow(true))
+	mock.ExpectQuery(lockQuery).WithArgs("job").
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock", "txid_current"}).AddRow(false, 742))
+	mock.ExpectQuery(lockQuery).WithArgs("job").
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock", "txid_current"}).AddRow(true, 743))
+	mock.ExpectQuery(unlockQuery).WithArgs("job").
+		WillReturnRows(sqlmock.NewRows([]string{"pg_advisory_unlock"}).AddRow(false))
+
+	locker := NewPostgresLocker(db, WithAutoRenew(false))
+	ctx := context.Background()
+
+	l, err := locker.Acquire(ctx, "job", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(741), l.Token())
+	require.NoError(t, l.Release(ctx))
+
+	_, err = locker.Acquire(ctx, "job", time.Minute)
+	assert.ErrorIs(t, err, ErrLockHeld)
+
+	// the lock was released by the server, e.g. after pg_advisory_unlock_all
+	l, err = locker.Acquire(ctx, "job", time.Minute)
+	require.NoError(t, err)
+	assert.ErrorIs(t, l.Release(ctx), ErrNotOwner)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresLockerLost(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_try_advisory_lock")).WithArgs("job").
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock", "txid_current"}).AddRow(true, 1))
+	mock.ExpectPing()
+	mock.ExpectPing().WillReturnError(assert.AnError)
+
+	l, err := NewPostgresLocker(db).Acquire(context.Background(), "job", 30*time.Millisecond)
+	require.NoError(t, err)
+
+	select {
+	case <-l.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("the broken connection is not reported")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,138 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/a-aslani/wotop/util"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisKeyPrefix prefixes the keys of the locks of RedisLocker, see RedisKey.
+const RedisKeyPrefix = "lock:"
+
+// RedisKey returns the Redis key of the lock of a key, "lock:{k}" for the key "k". Its fencing
+// token is counted in "lock:{k}:fence", the hash tag keeping both in the same slot of a Redis
+// Cluster, as the scripts of RedisLocker access them together.
+//
+// Parameters:
+//   - key: The key of the lock.
+//
+// Returns:
+//   - The Redis key of the lock.
+func RedisKey(key string) string {
+	return RedisKeyPrefix + "{" + key + "}"
+}
+
+// acquireScript sets the lock with SET NX PX and increments the fencing token of the key, in
+// one step so the tokens follow the order of the acquisitions.
+var acquireScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return redis.call("INCR", KEYS[2])
+end
+return 0
+`)
+
+// renewScript extends the lock only when it is still held by the owner.
+var renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseScript deletes the lock only when it is still held by the owner, so a lock that
+// expired and was acquired by another owner is not released.
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// RedisLocker is a Locker storing the locks in Redis with SET NX PX, see the package
+// documentation for their consistency.
+type RedisLocker struct {
+	rdb  redis.UniversalClient
+	opts options
+}
+
+// Ensure RedisLocker and PostgresLocker implement the Locker interface.
+var (
+	_ Locker = (*RedisLocker)(nil)
+	_ Locker = (*PostgresLocker)(nil)
+)
+
+// NewRedisLocker creates a RedisLocker.
+//
+// Parameters:
+//   - rdb: The Redis client.
+//   - opts: The auto-renewal and the logger.
+//
+// Returns:
+//   - The locker.
+func NewRedisLocker(rdb redis.UniversalClient, opts ...Option) *RedisLocker {
+	return &RedisLocker{rdb: rdb, opts: newOptions(opts)}
+}
+
+func (r *RedisLocker) Acquire(ctx context.Context, key string, ttl time.Duration) (Lock, error) {
+
+	l := &redisLock{lease: newLease(), rdb: r.rdb, key: key, owner: util.GenerateID(16)}
+
+	token, err := acquireScript.Run(ctx, r.rdb, []string{RedisKey(key), RedisKey(key) + ":fence"}, l.owner, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return nil, fmt.Errorf("lock %s: %w", key, err)
+	}
+	if token == 0 {
+		return nil, ErrLockHeld
+	}
+	l.token = token
+
+	if r.opts.autoRenew {
+		l.start(l, ttl, r.opts.log)
+	}
+
+	return l, nil
+}
+
+// redisLock is a lock acquired by RedisLocker.
+type redisLock struct {
+	*lease
+	rdb   redis.UniversalClient
+	key   string
+	owner string
+	token int64
+}
+
+func (l *redisLock) Key() string {
+	return l.key
+}
+
+func (l *redisLock) Token() int64 {
+	return l.token
+}
+
+func (l *redisLock) Renew(ctx context.Context, ttl time.Duration) error {
+	renewed, err := renewScript.Run(ctx, l.rdb, []string{RedisKey(l.key)}, l.owner, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return fmt.Errorf("lock %s: %w", l.key, err)
+	}
+	if renewed == 0 {
+		return ErrNotOwner
+	}
+	return nil
+}
+
+func (l *redisLock) Release(ctx context.Context) error {
+	l.halt()
+
+	released, err := releaseScript.Run(ctx, l.rdb, []string{RedisKey(l.key)}, l.owner).Int64()
+	if err != nil {
+		return fmt.Errorf("lock %s: %w", l.key, err)
+	}
+	if released == 0 {
+		return ErrNotOwner
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRedisLocker(t *testing.T, opts ...Option) (*RedisLocker, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	return NewRedisLocker(redis.NewClient(&redis.Options{Addr: mr.Addr()}), opts...), mr
+}
+
+func TestRedisLockerContention(t *testing.T) {
+	locker, mr := newTestRedisLocker(t, WithAutoRenew(false))
+	ctx := context.Background()
+
+	var (
+		wg       sync.WaitGroup
+		acquired atomic.Int32
+		held     atomic.Int32
+	)
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := locker.Acquire(ctx, "janitor", time.Minute)
+			switch {
+			case err == nil:
+				acquired.Add(1)
+			case errors.Is(err, ErrLockHeld):
+				held.Add(1)
+			default:
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), acquired.Load(), "one owner acquires the lock")
+	assert.Equal(t, int32(19), held.Load())
+	assert.Equal(t, time.Minute, mr.TTL(RedisKey("janitor")))
+	// the hash tag keeps the lock and its fencing token in one slot of a Redis Cluster
+	assert.ElementsMatch(t, []string{"lock:{janitor}", "lock:{janitor}:fence"}, mr.Keys())
+}
+
+func TestRedisLockerExpiry(t *testing.T) {
+	locker, mr := newTestRedisLocker(t, WithAutoRenew(false))
+	ctx := context.Background()
+
+	first, err := locker.Acquire(ctx, "relay", time.Minute)
+	require.NoError(t, err)
+
+	// the lock expires and another owner acquires it
+	mr.FastForward(time.Minute)
+	second, err := locker.Acquire(ctx, "relay", time.Minute)
+	require.NoError(t, err)
+	assert.Greater(t, second.Token(), first.Token(), "the fencing tokens grow")
+
+	// the first owner cannot renew nor release the lock of the second one
+	assert.ErrorIs(t, first.Renew(ctx, time.Minute), ErrNotOwner)
+	assert.ErrorIs(t, first.Release(ctx), ErrNotOwner)
+	assert.True(t, mr.Exists(RedisKey("relay")), "the lock of the other owner is kept")
+
+	require.NoError(t, second.Release(ctx))
+	assert.False(t, mr.Exists(RedisKey("relay")))
+	assert.ErrorIs(t, second.Release(ctx), ErrNotOwner, "a lock is released once")
+
+	third, err := locker.Acquire(ctx, "relay", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, second.Token()+1, third.Token())
+}
+
+func TestRedisLockerRenewal(t *testing.T) {
+	locker, mr := newTestRedisLocker(t)
+	ctx := context.Background()
+	key := RedisKey("job")
+
+	l, err := locker.Acquire(ctx, "job", 150*time.Millisecond)
+	require.NoError(t, err)
+
+	// the TTL of miniredis only passes with FastForward, the renewal sets it back
+	mr.FastForward(100 * time.Millisecond)
+	require.Eventually(t, func() bool { return mr.TTL(key) == 150*time.Millisecond }, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, l.Renew(ctx, time.Minute))
+	assert.Equal(t, time.Minute, mr.TTL(key))
+
+	// the lock is deleted by another process, the renewal finds it lost
+	mr.Del(key)
+	select {
+	case <-l.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("the lost lock is not reported")
+	}
+	assert.ErrorIs(t, l.Release(ctx), ErrNotOwner)
+
+	// a released lock is not renewed anymore
+	l, err = locker.Acquire(ctx, "job", 150*time.Millisecond)
+	require.NoError(t, err)
+	require.NoError(t, l.Release(ctx))
+	time.Sleep(100 * time.Millisecond)
+	assert.False(t, mr.Exists(key))
+}
+
+func TestWithLock(t *testing.T) {
+	locker, mr := newTestRedisLocker(t)
+	ctx := context.Background()
+
+	runs := 0
+	err := WithLock(ctx, locker, "job", func(ctx context.Context) error {
+		runs++
+		assert.True(t, mr.Exists(RedisKey("job")))
+		assert.Equal(t, DefaultTTL, mr.TTL(RedisKey("job")))
+
+		// the lock is held while fn runs
+		assert.ErrorIs(t, WithLock(ctx, locker, "job", func(context.Context) error {
+			runs++
+			return nil
+		}), ErrLockHeld)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, runs)
+	assert.False(t, mr.Exists(RedisKey("job")), "the lock is released")
+
+	failed := errors.New("failed")
+	assert.ErrorIs(t, WithLock(ctx, locker, "job", func(context.Context) error { return failed }), failed)
+	assert.False(t, mr.Exists(RedisKey("job")), "the lock is released when fn fails")
+}
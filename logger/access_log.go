@@ -0,0 +1,124 @@
+package logger
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/util"
+	"github.com/gin-gonic/gin"
+)
+
+// AccessLogOptions configures GinAccessLog.
+//
+// Fields:
+//   - SkipPaths: The paths which are never logged, e.g. "/metrics" and "/ping".
+//   - SampleRate: The fraction, between 0 and 1, of the successful requests which are logged.
+//     Failed requests, with a status of 400 or more, are always logged. The zero value logs
+//     every request.
+type AccessLogOptions struct {
+	SkipPaths  []string
+	SampleRate float64
+
+	random func() float64 // replaced by the tests to make the sampling deterministic
+}
+
+// GinAccessLog returns a Gin middleware logging one entry per request with its method, path,
+// status, latency, response size, client IP, user ID and trace ID as structured fields, see
+// FieldLogger. Successful requests are logged at the info level, client errors as warnings
+// and server errors as errors.
+//
+// The trace ID of the request is generated when it has none and set in the request context,
+// so the handlers and the jwt middleware reuse it. The user ID is the one set by the jwt
+// middleware, which must run after this one.
+//
+// Parameters:
+//   - l: The logger writing the entries.
+//   - opts: The paths to skip and the sampling of the successful requests.
+//
+// Returns:
+//   - A gin.HandlerFunc to register with gin.Engine.Use.
+func GinAccessLog(l Logger, opts AccessLogOptions) gin.HandlerFunc {
+
+	skip := make(map[string]struct{}, len(opts.SkipPaths))
+	for _, p := range opts.SkipPaths {
+		skip[p] = struct{}{}
+	}
+
+	random := opts.random
+	if random == nil {
+		random = rand.Float64
+	}
+
+	return func(c *gin.Context) {
+
+		path := c.Request.URL.Path
+		if _, ok := skip[path]; ok {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		traceID, ok := wotop.TraceIDFromContext(ctx)
+		if !ok {
+			traceID = util.GenerateID(16)
+			c.Request = c.Request.WithContext(SetTraceID(ctx, traceID))
+		}
+
+		start := time.Now()
+
+		c.Next()
+
+		status := c.Writer.Status()
+		failed := status >= http.StatusBadRequest || len(c.Errors) > 0
+		if !failed && opts.SampleRate > 0 && opts.SampleRate < 1 && random() >= opts.SampleRate {
+			return
+		}
+
+		// the handlers may have replaced the request context, e.g. with the identity of the caller
+		ctx = c.Request.Context()
+
+		fields := Fields{
+			"method":     c.Request.Method,
+			"path":       path,
+			"status":     status,
+			"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+			"bytes":      max(c.Writer.Size(), 0),
+			"client_ip":  c.ClientIP(),
+			"trace_id":   traceID,
+		}
+		if userID := accessLogUserID(c); userID != "" {
+			fields["user_id"] = userID
+		}
+		if len(c.Errors) > 0 {
+			fields["errors"] = strings.Join(c.Errors.Errors(), "; ")
+		}
+
+		level := LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = LevelError
+		case failed:
+			level = LevelWarning
+		}
+
+		LogFields(l, ctx, level, "access", fields)
+	}
+}
+
+// accessLogUserID returns the ID of the caller set by the jwt middleware, in the request
+// context or in the Gin keys.
+//
+// Parameters:
+//   - c: The Gin context of the request.
+//
+// Returns:
+//   - The user ID, empty for anonymous requests.
+func accessLogUserID(c *gin.Context) string {
+	if identity, ok := wotop.IdentityFromContext(c.Request.Context()); ok && identity.ID != "" {
+		return identity.ID
+	}
+	return c.GetString("ID")
+}
@@ -0,0 +1,149 @@
+package logger
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/a-aslani/wotop"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// accessEntry is an entry written to recordingLogger.
+type accessEntry struct {
+	level  string
+	fields Fields
+}
+
+// recordingLogger is a FieldLogger recording its structured entries.
+type recordingLogger struct {
+	entries []accessEntry
+}
+
+func (l *recordingLogger) Info(context.Context, string, ...any)    {}
+func (l *recordingLogger) Error(context.Context, string, ...any)   {}
+func (l *recordingLogger) Warning(context.Context, string, ...any) {}
+
+func (l *recordingLogger) InfoFields(_ context.Context, _ string, fields Fields) {
+	l.entries = append(l.entries, accessEntry{LevelInfo, fields})
+}
+
+func (l *recordingLogger) WarningFields(_ context.Context, _ string, fields Fields) {
+	l.entries = append(l.entries, accessEntry{LevelWarning, fields})
+}
+
+func (l *recordingLogger) ErrorFields(_ context.Context, _ string, fields Fields) {
+	l.entries = append(l.entries, accessEntry{LevelError, fields})
+}
+
+func newAccessLogRouter(l Logger, opts AccessLogOptions) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(GinAccessLog(l, opts))
+
+	// stands for the jwt middleware
+	authenticate := func(c *gin.Context) {
+		c.Request = c.Request.WithContext(wotop.WithIdentity(c.Request.Context(), wotop.Identity{ID: "user-1"}))
+	}
+
+	r.GET("/orders", authenticate, func(c *gin.Context) { c.String(http.StatusOK, "orders") })
+	r.GET("/missing", func(c *gin.Context) { c.Status(http.StatusNotFound) })
+	r.GET("/broken", func(c *gin.Context) { c.Status(http.StatusInternalServerError) })
+	r.GET("/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+	return r
+}
+
+func serve(r http.Handler, path string) {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	r.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestGinAccessLogFields(t *testing.T) {
+	l := &recordingLogger{}
+	serve(newAccessLogRouter(l, AccessLogOptions{}), "/orders")
+
+	require.Len(t, l.entries, 1)
+	e := l.entries[0]
+	assert.Equal(t, LevelInfo, e.level)
+	assert.Equal(t, http.MethodGet, e.fields["method"])
+	assert.Equal(t, "/orders", e.fields["path"])
+	assert.Equal(t, http.StatusOK, e.fields["status"])
+	assert.Equal(t, len("orders"), e.fields["bytes"])
+	assert.Equal(t, "10.0.0.1", e.fields["client_ip"])
+	assert.Equal(t, "user-1", e.fields["user_id"])
+	assert.Len(t, e.fields["trace_id"], 16)
+	assert.Contains(t, e.fields, "latency_ms")
+}
+
+func TestGinAccessLogLevels(t *testing.T) {
+	l := &recordingLogger{}
+	r := newAccessLogRouter(l, AccessLogOptions{})
+	serve(r, "/missing")
+	serve(r, "/broken")
+
+	require.Len(t, l.entries, 2)
+	assert.Equal(t, LevelWarning, l.entries[0].level)
+	assert.Equal(t, LevelError, l.entries[1].level)
+	assert.NotContains(t, l.entries[0].fields, "user_id")
+}
+
+func TestGinAccessLogSkipPaths(t *testing.T) {
+	l := &recordingLogger{}
+	r := newAccessLogRouter(l, AccessLogOptions{SkipPaths: []string{"/ping", "/metrics"}})
+	serve(r, "/ping")
+	serve(r, "/orders")
+
+	require.Len(t, l.entries, 1)
+	assert.Equal(t, "/orders", l.entries[0].fields["path"])
+}
+
+func TestGinAccessLogSampling(t *testing.T) {
+	l := &recordingLogger{}
+
+	// the draws alternate below and above the rate
+	draws := []float64{0.1, 0.9}
+	n := 0
+	opts := AccessLogOptions{SampleRate: 0.5, random: func() float64 {
+		v := draws[n%len(draws)]
+		n++
+		return v
+	}}
+	r := newAccessLogRouter(l, opts)
+
+	for range 4 {
+		serve(r, "/orders")
+	}
+	// errors are logged without drawing
+	serve(r, "/missing")
+	serve(r, "/broken")
+
+	require.Len(t, l.entries, 4)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, "/missing", l.entries[2].fields["path"])
+	assert.Equal(t, "/broken", l.entries[3].fields["path"])
+}
+
+// plainLogger is a Logger without structured fields.
+type plainLogger struct {
+	messages []string
+}
+
+func (l *plainLogger) Info(_ context.Context, message string, args ...any) {
+	l.messages = append(l.messages, message)
+	for _, a := range args {
+		l.messages = append(l.messages, a.(string))
+	}
+}
+func (l *plainLogger) Error(context.Context, string, ...any)   {}
+func (l *plainLogger) Warning(context.Context, string, ...any) {}
+
+func TestLogFieldsPlainLogger(t *testing.T) {
+	l := &plainLogger{}
+	LogFields(l, context.Background(), LevelInfo, "access", Fields{"status": 200, "path": "/orders"})
+
+	assert.Equal(t, []string{"%s %s", "access", `{"path":"/orders","status":200}`}, l.messages)
+}
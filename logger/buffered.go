@@ -0,0 +1,341 @@
+package logger
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/util"
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultMaxBufferedEntries is the number of entries a request buffers when the options of the
+// BufferedRequestLogger set none.
+const DefaultMaxBufferedEntries = 256
+
+// BufferedOptions configures the BufferedRequestLogger.
+//
+// Fields:
+//   - MaxEntries: The number of entries buffered per request, DefaultMaxBufferedEntries when
+//     zero. The oldest entries are dropped past it, the flush reports how many were.
+//   - FlushStatus: The lowest status whose request is flushed, http.StatusInternalServerError
+//     when zero. The requests with gin errors are flushed too.
+//   - SampleRate: The fraction, between 0 and 1, of the successful requests which are flushed
+//     anyway, e.g. to see what a normal request logs. The zero value drops them all.
+type BufferedOptions struct {
+	MaxEntries  int
+	FlushStatus int
+	SampleRate  float64
+
+	random func() float64 // replaced by the tests to make the sampling deterministic
+}
+
+// bufferedEntry is an entry held by a request buffer until it is flushed or dropped.
+type bufferedEntry struct {
+	ctx     context.Context
+	level   string // LevelInfo, LevelWarning or levelDebug
+	message string
+	args    []any
+	fields  Fields // set for the entries logged with fields, the message is not formatted then
+}
+
+// requestBuffer holds the entries of a request, the latest ones when there are too many.
+type requestBuffer struct {
+	mu      sync.Mutex
+	entries *[]bufferedEntry
+	head    int // the oldest entry once the buffer is full
+	dropped int
+	flushed bool // the entries are written through once the buffer is flushed or dropped
+}
+
+type requestBufferKey struct{}
+
+// BufferedRequestLogger is a Logger holding the info, warning and debug entries of a request in
+// a buffer, which is only written when the request fails: an error entry writes the buffered
+// entries, in order and with their trace ID, before the error, and so does the end of a failed
+// request. The buffers of the successful requests are dropped, so the next logger can run at
+// the debug level without the volume of every request.
+//
+// The buffer is created by Middleware, or Begin for other transports. The entries logged
+// without one, e.g. at startup, are written through. The level is the one of the next logger,
+// which is the one given to LevelHandler.
+type BufferedRequestLogger struct {
+	next   Logger
+	opts   BufferedOptions
+	random func() float64
+	pool   sync.Pool // the entry slices of the ended requests, to keep a request from allocating them
+}
+
+// NewBufferedRequestLogger creates a BufferedRequestLogger.
+//
+// Parameters:
+//   - next: The logger writing the flushed entries, e.g. the JSON logger.
+//   - opts: The size of the buffers and the requests flushed.
+//
+// Returns:
+//   - The buffered logger.
+func NewBufferedRequestLogger(next Logger, opts BufferedOptions) *BufferedRequestLogger {
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = DefaultMaxBufferedEntries
+	}
+	if opts.FlushStatus <= 0 {
+		opts.FlushStatus = http.StatusInternalServerError
+	}
+
+	l := &BufferedRequestLogger{next: next, opts: opts, random: opts.random}
+	if l.random == nil {
+		l.random = rand.Float64
+	}
+	l.pool.New = func() any {
+		entries := make([]bufferedEntry, 0, min(opts.MaxEntries, 16))
+		return &entries
+	}
+	return l
+}
+
+// Begin creates the buffer of a request.
+//
+// Parameters:
+//   - ctx: The context of the request.
+//
+// Returns:
+//   - The context carrying the buffer, to log the entries of the request with.
+func (l *BufferedRequestLogger) Begin(ctx context.Context) context.Context {
+	return context.WithValue(ctx, requestBufferKey{}, &requestBuffer{entries: l.pool.Get().(*[]bufferedEntry)})
+}
+
+// End ends a request, writing its buffered entries when it failed or is sampled and dropping
+// them otherwise. The entries logged with the context afterwards, e.g. by a goroutine of the
+// request, are written through.
+//
+// Parameters:
+//   - ctx: The context returned by Begin.
+//   - failed: Whether the request failed.
+func (l *BufferedRequestLogger) End(ctx context.Context, failed bool) {
+	b, ok := ctx.Value(requestBufferKey{}).(*requestBuffer)
+	if !ok {
+		return
+	}
+
+	if failed || (l.opts.SampleRate > 0 && l.random() < l.opts.SampleRate) {
+		l.flush(ctx, b)
+	}
+
+	b.mu.Lock()
+	entries := b.release()
+	b.mu.Unlock()
+	if entries != nil {
+		l.recycle(entries)
+	}
+}
+
+// Middleware creates the buffer of each request, and flushes it when the status of the request
+// is FlushStatus or more or when a handler added an error. The trace ID of the request is
+// generated when it has none, so its entries share it. It must run before the handlers logging
+// with this logger, and GinAccessLog is given the next logger to log every request.
+//
+// Returns:
+//   - A gin.HandlerFunc to register with gin.Engine.Use.
+func (l *BufferedRequestLogger) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		ctx := c.Request.Context()
+		if _, ok := wotop.TraceIDFromContext(ctx); !ok {
+			ctx = SetTraceID(ctx, util.GenerateID(16))
+		}
+		ctx = l.Begin(ctx)
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		l.End(ctx, c.Writer.Status() >= l.opts.FlushStatus || len(c.Errors) > 0)
+	}
+}
+
+// Info buffers an informational message.
+//
+// Parameters:
+//   - ctx: The context for the log entry.
+//   - message: The informational message to log.
+//   - args: Optional arguments to format the message.
+func (l *BufferedRequestLogger) Info(ctx context.Context, message string, args ...any) {
+	if !l.buffer(ctx, LevelInfo, message, args, nil) {
+		l.next.Info(ctx, message, args...)
+	}
+}
+
+// Warning buffers a warning message.
+//
+// Parameters:
+//   - ctx: The context for the log entry.
+//   - message: The warning message to log.
+//   - args: Optional arguments to format the message.
+func (l *BufferedRequestLogger) Warning(ctx context.Context, message string, args ...any) {
+	if !l.buffer(ctx, LevelWarning, message, args, nil) {
+		l.next.Warning(ctx, message, args...)
+	}
+}
+
+// Debug buffers a debug message, which the next logger writes when it is a DebugLogger at the
+// debug level.
+//
+// Parameters:
+//   - ctx: The context for the log entry.
+//   - message: The debug message to log.
+//   - args: Optional arguments to format the message.
+func (l *BufferedRequestLogger) Debug(ctx context.Context, message string, args ...any) {
+	if !l.buffer(ctx, levelDebug, message, args, nil) {
+		Debug(l.next, ctx, message, args...)
+	}
+}
+
+// Error writes the buffered entries of the request and then the error message.
+//
+// Parameters:
+//   - ctx: The context for the log entry.
+//   - message: The error message to log.
+//   - args: Optional arguments to format the message.
+func (l *BufferedRequestLogger) Error(ctx context.Context, message string, args ...any) {
+	l.flushContext(ctx)
+	l.next.Error(ctx, message, args...)
+}
+
+// InfoFields buffers an informational message with structured fields.
+//
+// Parameters:
+//   - ctx: The context for the log entry.
+//   - message: The informational message to log.
+//   - fields: The structured fields of the entry.
+func (l *BufferedRequestLogger) InfoFields(ctx context.Context, message string, fields Fields) {
+	if !l.buffer(ctx, LevelInfo, message, nil, fields) {
+		LogFields(l.next, ctx, LevelInfo, message, fields)
+	}
+}
+
+// WarningFields buffers a warning message with structured fields.
+//
+// Parameters:
+//   - ctx: The context for the log entry.
+//   - message: The warning message to log.
+//   - fields: The structured fields of the entry.
+func (l *BufferedRequestLogger) WarningFields(ctx context.Context, message string, fields Fields) {
+	if !l.buffer(ctx, LevelWarning, message, nil, fields) {
+		LogFields(l.next, ctx, LevelWarning, message, fields)
+	}
+}
+
+// ErrorFields writes the buffered entries of the request and then the error message with
+// structured fields.
+//
+// Parameters:
+//   - ctx: The context for the log entry.
+//   - message: The error message to log.
+//   - fields: The structured fields of the entry.
+func (l *BufferedRequestLogger) ErrorFields(ctx context.Context, message string, fields Fields) {
+	l.flushContext(ctx)
+	LogFields(l.next, ctx, LevelError, message, fields)
+}
+
+// buffer adds an entry to the buffer of the context, dropping the oldest entry when it is full.
+//
+// Returns:
+//   - False if the context has no buffer or it was flushed, the entry is to be written through.
+func (l *BufferedRequestLogger) buffer(ctx context.Context, level, message string, args []any, fields Fields) bool {
+	b, ok := ctx.Value(requestBufferKey{}).(*requestBuffer)
+	if !ok {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.flushed {
+		return false
+	}
+
+	e := bufferedEntry{ctx: ctx, level: level, message: message, args: args, fields: fields}
+	entries := b.entries
+	if len(*entries) < l.opts.MaxEntries {
+		*entries = append(*entries, e)
+		return true
+	}
+	(*entries)[b.head] = e
+	b.head = (b.head + 1) % len(*entries)
+	b.dropped++
+	return true
+}
+
+// flushContext flushes the buffer of the context, if any.
+func (l *BufferedRequestLogger) flushContext(ctx context.Context) {
+	if b, ok := ctx.Value(requestBufferKey{}).(*requestBuffer); ok {
+		l.flush(ctx, b)
+	}
+}
+
+// flush writes the buffered entries to the next logger, oldest first, after a warning counting
+// the dropped ones. The entries logged afterwards are written through.
+func (l *BufferedRequestLogger) flush(ctx context.Context, b *requestBuffer) {
+	b.mu.Lock()
+	dropped := b.dropped
+	entries := b.release()
+	b.mu.Unlock()
+
+	if entries == nil {
+		return
+	}
+
+	if dropped > 0 {
+		l.next.Warning(ctx, "logger: %d earlier entries of the request were dropped from its buffer", dropped)
+	}
+	for _, e := range *entries {
+		l.write(e)
+	}
+	l.recycle(entries)
+}
+
+// recycle puts the entries of a released buffer back in the pool, without the contexts and
+// arguments they hold.
+func (l *BufferedRequestLogger) recycle(entries *[]bufferedEntry) {
+	clear(*entries)
+	*entries = (*entries)[:0]
+	l.pool.Put(entries)
+}
+
+// release marks the buffer flushed and returns its entries in order, nil when it was already
+// released. The buffer lock must be held.
+func (b *requestBuffer) release() *[]bufferedEntry {
+	if b.flushed {
+		return nil
+	}
+	b.flushed = true
+
+	entries := b.entries
+	b.entries = nil
+	if b.head > 0 {
+		// rotates the ring in place, the oldest entry first
+		slices.Reverse((*entries)[:b.head])
+		slices.Reverse((*entries)[b.head:])
+		slices.Reverse(*entries)
+	}
+	return entries
+}
+
+// write writes a buffered entry to the next logger.
+func (l *BufferedRequestLogger) write(e bufferedEntry) {
+	if e.fields != nil {
+		LogFields(l.next, e.ctx, e.level, e.message, e.fields)
+		return
+	}
+
+	switch e.level {
+	case LevelWarning:
+		l.next.Warning(e.ctx, e.message, e.args...)
+	case levelDebug:
+		Debug(l.next, e.ctx, e.message, e.args...)
+	default:
+		l.next.Info(e.ctx, e.message, e.args...)
+	}
+}
@@ -0,0 +1,182 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bufferedEntryOf is an entry written to traceLogger.
+type bufferedEntryOf struct {
+	level   string
+	message string
+	traceID string
+}
+
+// traceLogger is a DebugLogger recording its entries with their trace ID.
+type traceLogger struct {
+	entries []bufferedEntryOf
+}
+
+func (l *traceLogger) record(ctx context.Context, level, message string, args ...any) {
+	l.entries = append(l.entries, bufferedEntryOf{level, fmt.Sprintf(message, args...), GetTraceID(ctx)})
+}
+
+func (l *traceLogger) Info(ctx context.Context, message string, args ...any) {
+	l.record(ctx, LevelInfo, message, args...)
+}
+
+func (l *traceLogger) Warning(ctx context.Context, message string, args ...any) {
+	l.record(ctx, LevelWarning, message, args...)
+}
+
+func (l *traceLogger) Error(ctx context.Context, message string, args ...any) {
+	l.record(ctx, LevelError, message, args...)
+}
+
+func (l *traceLogger) Debug(ctx context.Context, message string, args ...any) {
+	l.record(ctx, levelDebug, message, args...)
+}
+
+func (l *traceLogger) messages() []string {
+	messages := make([]string, 0, len(l.entries))
+	for _, e := range l.entries {
+		messages = append(messages, e.message)
+	}
+	return messages
+}
+
+func newBufferedRouter(l *BufferedRequestLogger) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(l.Middleware())
+
+	r.GET("/orders", func(c *gin.Context) {
+		l.Info(c.Request.Context(), "listing orders of %s", "user-1")
+		Debug(l, c.Request.Context(), "query took %dms", 3)
+		c.String(http.StatusOK, "orders")
+	})
+	r.GET("/failing", func(c *gin.Context) {
+		ctx := c.Request.Context()
+		l.Info(ctx, "charging order %d", 42)
+		l.Warning(ctx, "retrying the payment")
+		l.Error(ctx, "payment failed")
+		l.Info(ctx, "order %d cancelled", 42)
+		c.Status(http.StatusPaymentRequired)
+	})
+	r.GET("/broken", func(c *gin.Context) {
+		LogFields(l, c.Request.Context(), LevelInfo, "reading stock", Fields{"sku": "A1"})
+		c.Status(http.StatusServiceUnavailable)
+	})
+	return r
+}
+
+func TestBufferedRequestLoggerFlushesOnError(t *testing.T) {
+	next := &traceLogger{}
+	r := newBufferedRouter(NewBufferedRequestLogger(next, BufferedOptions{}))
+
+	serve(r, "/failing")
+
+	assert.Equal(t, []string{"charging order 42", "retrying the payment", "payment failed", "order 42 cancelled"}, next.messages(),
+		"the buffered entries come in order before the error, the later ones are written through")
+	assert.Equal(t, LevelWarning, next.entries[1].level)
+	traceID := next.entries[0].traceID
+	assert.Len(t, traceID, 16)
+	for _, e := range next.entries {
+		assert.Equal(t, traceID, e.traceID)
+	}
+
+	// a server error flushes the request without an error entry
+	next.entries = nil
+	serve(r, "/broken")
+	assert.Equal(t, []string{`reading stock {"sku":"A1"}`}, next.messages())
+}
+
+func TestBufferedRequestLoggerDropsOnSuccess(t *testing.T) {
+	next := &traceLogger{}
+	l := NewBufferedRequestLogger(next, BufferedOptions{})
+	r := newBufferedRouter(l)
+
+	serve(r, "/orders")
+	assert.Empty(t, next.entries)
+
+	// the entries without buffer are written through
+	l.Info(context.Background(), "starting")
+	assert.Equal(t, []string{"starting"}, next.messages())
+}
+
+func TestBufferedRequestLoggerSampling(t *testing.T) {
+	next := &traceLogger{}
+
+	// the draws alternate below and above the rate
+	draws := []float64{0.1, 0.9}
+	n := 0
+	r := newBufferedRouter(NewBufferedRequestLogger(next, BufferedOptions{SampleRate: 0.5, random: func() float64 {
+		v := draws[n%len(draws)]
+		n++
+		return v
+	}}))
+
+	serve(r, "/orders")
+	serve(r, "/orders")
+
+	assert.Equal(t, 2, n)
+	require.Len(t, next.entries, 2, "one request of two is kept")
+	assert.Equal(t, []string{"listing orders of user-1", "query took 3ms"}, next.messages())
+	assert.Equal(t, levelDebug, next.entries[1].level)
+}
+
+func TestBufferedRequestLoggerCap(t *testing.T) {
+	next := &traceLogger{}
+	l := NewBufferedRequestLogger(next, BufferedOptions{MaxEntries: 3})
+
+	ctx := l.Begin(SetTraceID(context.Background(), "trace-1"))
+	for i := range 5 {
+		l.Info(ctx, "step %d", i)
+	}
+	l.Error(ctx, "failed")
+	l.End(ctx, true)
+
+	assert.Equal(t, []string{
+		"logger: 2 earlier entries of the request were dropped from its buffer",
+		"step 2", "step 3", "step 4",
+		"failed",
+	}, next.messages())
+
+	// the recycled buffer starts empty
+	next.entries = nil
+	ctx = l.Begin(context.Background())
+	l.Info(ctx, "fresh")
+	l.End(ctx, true)
+	assert.Equal(t, []string{"fresh"}, next.messages())
+}
+
+func BenchmarkBufferedRequestLogger(b *testing.B) {
+	l := NewBufferedRequestLogger(&plainLogger{}, BufferedOptions{})
+	base := SetTraceID(context.Background(), "trace-1")
+
+	b.ReportAllocs()
+	for b.Loop() {
+		ctx := l.Begin(base)
+		for i := range 10 {
+			l.Info(ctx, "step %d", i)
+		}
+		l.End(ctx, false)
+	}
+}
+
+func BenchmarkBufferedRequestLoggerMiddleware(b *testing.B) {
+	r := newBufferedRouter(NewBufferedRequestLogger(&plainLogger{}, BufferedOptions{}))
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
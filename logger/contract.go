@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/a-aslani/wotop"
 	"runtime"
 	"strings"
 )
@@ -20,11 +21,8 @@ type Logger interface {
 	Warning(ctx context.Context, message string, args ...any)
 }
 
-type traceDataType int
-
-const traceDataKey traceDataType = 1 // Key used to store and retrieve trace ID in the context.
-
-// SetTraceID sets a trace ID in the provided context.
+// SetTraceID sets a trace ID in the provided context. It is stored with wotop.WithTraceID,
+// so interactors can read it with wotop.TraceIDFromContext.
 //
 // Parameters:
 //   - ctx: The context in which the trace ID will be set.
@@ -33,7 +31,7 @@ const traceDataKey traceDataType = 1 // Key used to store and retrieve trace ID
 // Returns:
 //   - A new context containing the trace ID.
 func SetTraceID(ctx context.Context, traceID string) context.Context {
-	return context.WithValue(ctx, traceDataKey, traceID)
+	return wotop.WithTraceID(ctx, traceID)
 }
 
 // GetTraceID retrieves the trace ID from the provided context.
@@ -50,10 +48,8 @@ func GetTraceID(ctx context.Context) string {
 	// default traceID
 	traceID := "0000000000000000"
 
-	if ctx != nil {
-		if v := ctx.Value(traceDataKey); v != nil {
-			traceID = v.(string)
-		}
+	if v, ok := wotop.TraceIDFromContext(ctx); ok {
+		traceID = v
 	}
 
 	return traceID
@@ -95,3 +91,61 @@ func toJsonString(obj any) string {
 	bytes, _ := json.Marshal(obj)
 	return string(bytes)
 }
+
+// Fields are the structured fields of a log entry, e.g. the status and the latency of a request.
+type Fields map[string]any
+
+// FieldLogger is a Logger writing structured fields along the message, so they can be
+// searched by the log backend. The JSON and the Graylog loggers implement it.
+//
+// Methods:
+//   - InfoFields: Logs an informational message with fields.
+//   - WarningFields: Logs a warning message with fields.
+//   - ErrorFields: Logs an error message with fields.
+type FieldLogger interface {
+	Logger
+	InfoFields(ctx context.Context, message string, fields Fields)
+	WarningFields(ctx context.Context, message string, fields Fields)
+	ErrorFields(ctx context.Context, message string, fields Fields)
+}
+
+// Severity levels of LogFields, and LevelFatal of the entries of Fatal, which are written at
+// any level.
+const (
+	LevelInfo    = "INFO"
+	LevelWarning = "WARNING"
+	LevelError   = "ERROR"
+	LevelFatal   = "FATAL"
+)
+
+// LogFields logs a message with structured fields at the given level. Loggers which are not
+// a FieldLogger get the fields appended to the message as JSON.
+//
+// Parameters:
+//   - l: The logger writing the entry.
+//   - ctx: The context for the log entry.
+//   - level: LevelInfo, LevelWarning or LevelError.
+//   - message: The message to log.
+//   - fields: The structured fields of the entry.
+func LogFields(l Logger, ctx context.Context, level string, message string, fields Fields) {
+	if fl, ok := l.(FieldLogger); ok {
+		switch level {
+		case LevelError:
+			fl.ErrorFields(ctx, message, fields)
+		case LevelWarning:
+			fl.WarningFields(ctx, message, fields)
+		default:
+			fl.InfoFields(ctx, message, fields)
+		}
+		return
+	}
+
+	switch level {
+	case LevelError:
+		l.Error(ctx, "%s %s", message, toJsonString(fields))
+	case LevelWarning:
+		l.Warning(ctx, "%s %s", message, toJsonString(fields))
+	default:
+		l.Info(ctx, "%s %s", message, toJsonString(fields))
+	}
+}
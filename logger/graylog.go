@@ -2,24 +2,28 @@ package logger
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/Graylog2/go-gelf/gelf"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"sort"
 )
 
 // graylogModel represents a logger model that integrates with Graylog.
 //
 // Fields:
 //   - logger: The underlying zap.Logger instance used for logging.
-//   - level: The atomic level configuration for the logger.
+//   - level: The level of the logger, debug when it is created, see SetLevel.
 //   - graylogAddress: The address of the Graylog server.
 //   - stage: The application stage (e.g., development, production).
+//   - hooks: The error hooks and the exit function of Fatal.
 type graylogModel struct {
 	logger         *zap.Logger
-	level          zap.AtomicLevel
+	level          AtomicLevel
 	graylogAddress string
 	stage          string
+	hooks          *hooks
 }
 
 var zapConfig zap.Config
@@ -58,31 +62,69 @@ func init() {
 // Parameters:
 //   - graylogAddress: The address of the Graylog server.
 //   - stage: The application stage (e.g., development, production).
+//   - opts: Options such as WithErrorHook and WithExitFunc.
 //
 // Returns:
 //   - A pointer to the graylogModel instance.
 //   - An error if the Graylog writer could not be created.
-func NewGrayLog(graylogAddress string, stage string) (*graylogModel, error) {
+func NewGrayLog(graylogAddress string, stage string, opts ...Option) (*graylogModel, error) {
 	gelfWriter, err := gelf.NewWriter(graylogAddress)
 	if err != nil {
 		return nil, err
 	}
 
+	// every logger has its own level, so changing it does not change the level of the others
+	level := AtomicLevel{zap: zap.NewAtomicLevelAt(zapConfig.Level.Level())}
+
 	core := zapcore.NewCore(
 		zapcore.NewJSONEncoder(zapConfig.EncoderConfig),
 		zapcore.AddSync(gelfWriter),
-		zapConfig.Level,
+		level.zap,
 	)
 
 	l := zap.New(core, zap.AddCaller(), zap.AddCallerSkip(1))
 
 	return &graylogModel{
 		logger:         l,
+		level:          level,
 		graylogAddress: graylogAddress,
+		stage:          stage,
+		hooks:          newHooks(opts),
 	}, nil
 }
 
-// Error logs an error message with optional arguments.
+// Level returns the current level of the logger.
+//
+// Returns:
+//   - One of debug, info, warning and error.
+func (l *graylogModel) Level() string {
+	return l.level.Level()
+}
+
+// SetLevel changes the level of the logger.
+//
+// Parameters:
+//   - level: The new level, one of debug, info, warning and error.
+//
+// Returns:
+//   - ErrInvalidLevel if the level is unknown.
+func (l *graylogModel) SetLevel(level string) error {
+	return l.level.SetLevel(level)
+}
+
+// Debug logs a debug message with optional arguments.
+//
+// Parameters:
+//   - ctx: The context for the log entry.
+//   - message: The debug message to log.
+//   - args: Optional arguments to format the message.
+func (l *graylogModel) Debug(ctx context.Context, message string, args ...any) {
+	messageWithArgs := fmt.Sprintf(message, args...)
+	l.logger.Debug(messageWithArgs)
+}
+
+// Error logs an error message with optional arguments, and calls the error hooks of the
+// logger.
 //
 // Parameters:
 //   - ctx: The context for the log entry.
@@ -91,8 +133,38 @@ func NewGrayLog(graylogAddress string, stage string) (*graylogModel, error) {
 func (l *graylogModel) Error(ctx context.Context, message string, args ...any) {
 	messageWithArgs := fmt.Sprintf(message, args...)
 	l.logger.Error(messageWithArgs)
+	l.hooks.fire(ctx, LevelError, messageWithArgs, nil)
+}
+
+// Fatal logs a fatal message with optional arguments, calls the error hooks of the logger and
+// terminates the process with the exit function of the logger. The buffered entries are
+// flushed before.
+//
+// Parameters:
+//   - ctx: The context for the log entry.
+//   - message: The fatal message to log.
+//   - args: Optional arguments to format the message.
+//
+// Returns:
+//   - The message as an error, returned when the exit function returns, e.g. in tests.
+func (l *graylogModel) Fatal(ctx context.Context, message string, args ...any) error {
+	messageWithArgs := fmt.Sprintf(message, args...)
+	if ce := l.logger.Check(zapcore.FatalLevel, messageWithArgs); ce != nil {
+		ce.After(ce.Entry, skipExit{}).Write()
+	}
+	l.hooks.fire(ctx, LevelFatal, messageWithArgs, nil)
+	_ = l.logger.Sync()
+	l.hooks.exitFunc()(1)
+	return errors.New(messageWithArgs)
 }
 
+// skipExit replaces the exit of the zap fatal entries, Fatal exits once the error hooks ran,
+// with the exit function of the logger.
+type skipExit struct{}
+
+// OnWrite does nothing.
+func (skipExit) OnWrite(*zapcore.CheckedEntry, []zapcore.Field) {}
+
 // Info logs an informational message with optional arguments.
 //
 // Parameters:
@@ -115,6 +187,59 @@ func (l *graylogModel) Warning(ctx context.Context, message string, args ...any)
 	l.logger.Warn(messageWithArgs)
 }
 
+// ErrorFields logs an error message with structured fields, written as GELF fields, and
+// calls the error hooks of the logger.
+//
+// Parameters:
+//   - ctx: The context for the log entry.
+//   - message: The error message to log.
+//   - fields: The structured fields of the entry.
+func (l *graylogModel) ErrorFields(ctx context.Context, message string, fields Fields) {
+	l.logger.Error(message, zapFields(fields)...)
+	l.hooks.fire(ctx, LevelError, message, fields)
+}
+
+// InfoFields logs an informational message with structured fields, written as GELF fields.
+//
+// Parameters:
+//   - ctx: The context for the log entry.
+//   - message: The informational message to log.
+//   - fields: The structured fields of the entry.
+func (l *graylogModel) InfoFields(ctx context.Context, message string, fields Fields) {
+	l.logger.Info(message, zapFields(fields)...)
+}
+
+// WarningFields logs a warning message with structured fields, written as GELF fields.
+//
+// Parameters:
+//   - ctx: The context for the log entry.
+//   - message: The warning message to log.
+//   - fields: The structured fields of the entry.
+func (l *graylogModel) WarningFields(ctx context.Context, message string, fields Fields) {
+	l.logger.Warn(message, zapFields(fields)...)
+}
+
+// zapFields converts the fields of an entry to zap fields, sorted by key.
+//
+// Parameters:
+//   - fields: The structured fields of the entry.
+//
+// Returns:
+//   - The zap fields.
+func zapFields(fields Fields) []zap.Field {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	zf := make([]zap.Field, 0, len(keys))
+	for _, k := range keys {
+		zf = append(zf, zap.Any(k, fields[k]))
+	}
+	return zf
+}
+
 // Sync flushes any buffered log entries.
 //
 // Returns:
@@ -0,0 +1,198 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/a-aslani/wotop/util"
+)
+
+// LogEntry is an entry handed to the ErrorHook of a logger.
+//
+// Fields:
+//   - Level: LevelError or LevelFatal.
+//   - Message: The formatted message.
+//   - Fields: The structured fields of the entry, nil for the entries without fields.
+//   - TraceID: The trace ID of the context of the entry.
+//   - Time: When the entry was logged.
+type LogEntry struct {
+	Level   string
+	Message string
+	Fields  Fields
+	TraceID string
+	Time    time.Time
+}
+
+// ErrorHook is called for every error and fatal entry of a logger, e.g. to report it to
+// Sentry. It is called synchronously, so it should not block.
+type ErrorHook func(ctx context.Context, entry LogEntry)
+
+// Option configures the JSON and the Graylog loggers.
+type Option func(*hooks)
+
+// WithErrorHook adds a hook called for every error and fatal entry of the logger.
+//
+// Parameters:
+//   - hook: The hook, e.g. ErrorRateAlert.Hook.
+//
+// Returns:
+//   - The option.
+func WithErrorHook(hook ErrorHook) Option {
+	return func(h *hooks) {
+		h.errorHooks = append(h.errorHooks, hook)
+	}
+}
+
+// WithExitFunc sets the function Fatal calls once the entry is logged, os.Exit by default.
+// Tests set a function recording the code, so they are not terminated.
+//
+// Parameters:
+//   - exit: Called with the exit code 1.
+//
+// Returns:
+//   - The option.
+func WithExitFunc(exit func(code int)) Option {
+	return func(h *hooks) {
+		h.exit = exit
+	}
+}
+
+// hooks are the error hooks and the exit function of a logger.
+type hooks struct {
+	errorHooks []ErrorHook
+	exit       func(code int)
+}
+
+// newHooks applies the options to the hooks of a logger.
+func newHooks(opts []Option) *hooks {
+	h := &hooks{exit: os.Exit}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// fire calls the error hooks with an entry. The hooks of a logger created without options,
+// e.g. in a test, are nil.
+func (h *hooks) fire(ctx context.Context, level, message string, fields Fields) {
+	if h == nil || len(h.errorHooks) == 0 {
+		return
+	}
+
+	entry := LogEntry{
+		Level:   level,
+		Message: message,
+		Fields:  fields,
+		TraceID: GetTraceID(ctx),
+		Time:    time.Now(),
+	}
+	for _, hook := range h.errorHooks {
+		hook(ctx, entry)
+	}
+}
+
+// exitFunc returns the function terminating the process after a fatal entry.
+func (h *hooks) exitFunc() func(code int) {
+	if h == nil || h.exit == nil {
+		return os.Exit
+	}
+	return h.exit
+}
+
+// FatalLogger is a Logger writing fatal entries, after which it terminates the process. The
+// JSON and the Graylog loggers implement it.
+type FatalLogger interface {
+	Logger
+	Fatal(ctx context.Context, message string, args ...any) error
+}
+
+// exit is the exit function of Fatal for the loggers which are not a FatalLogger.
+var exit = os.Exit
+
+// Fatal logs a fatal message and terminates the process with the exit code 1. Loggers which
+// are not a FatalLogger log it as an error.
+//
+// Parameters:
+//   - l: The logger writing the entry.
+//   - ctx: The context for the log entry.
+//   - message: The fatal message to log.
+//   - args: Optional arguments to format the message.
+//
+// Returns:
+//   - The message as an error, returned when the exit function of the logger returns, e.g. in tests.
+func Fatal(l Logger, ctx context.Context, message string, args ...any) error {
+	if fl, ok := l.(FatalLogger); ok {
+		return fl.Fatal(ctx, message, args...)
+	}
+
+	l.Error(ctx, message, args...)
+	exit(1)
+	return fmt.Errorf(message, args...)
+}
+
+// DefaultErrorRateWindow is the window of NewErrorRateAlert when it is given none.
+const DefaultErrorRateWindow = time.Minute
+
+// ErrorRateAlert counts the error entries of a sliding window and calls a function when they
+// exceed a threshold, e.g. to page the team without an external log pipeline. Register its
+// Hook with WithErrorHook.
+type ErrorRateAlert struct {
+	threshold  int
+	window     time.Duration
+	onExceeded func(ctx context.Context, count int, entry LogEntry)
+	clock      util.Clock
+
+	mu      sync.Mutex
+	times   []time.Time // the times of the entries of the window, oldest first
+	alerted bool
+}
+
+// NewErrorRateAlert creates an ErrorRateAlert. The function is called once when the rate goes
+// above the threshold, and again after the rate went back to the threshold or below.
+//
+// Parameters:
+//   - threshold: The number of entries per window above which the function is called.
+//   - window: The duration of the sliding window, DefaultErrorRateWindow when zero.
+//   - onExceeded: Called with the number of entries of the window and the entry exceeding the threshold.
+//
+// Returns:
+//   - The ErrorRateAlert.
+func NewErrorRateAlert(threshold int, window time.Duration, onExceeded func(ctx context.Context, count int, entry LogEntry)) *ErrorRateAlert {
+	if window <= 0 {
+		window = DefaultErrorRateWindow
+	}
+	return &ErrorRateAlert{threshold: threshold, window: window, onExceeded: onExceeded, clock: util.SystemClock}
+}
+
+// Hook counts an entry, it is the ErrorHook of the alert.
+//
+// Parameters:
+//   - ctx: The context of the entry.
+//   - entry: The error or fatal entry.
+func (a *ErrorRateAlert) Hook(ctx context.Context, entry LogEntry) {
+	now := a.clock.Now()
+
+	a.mu.Lock()
+	cutoff := now.Add(-a.window)
+	expired := 0
+	for expired < len(a.times) && !a.times[expired].After(cutoff) {
+		expired++
+	}
+	a.times = append(a.times[expired:], now)
+	count := len(a.times)
+
+	fire := false
+	if count <= a.threshold {
+		a.alerted = false
+	} else if !a.alerted {
+		a.alerted, fire = true, true
+	}
+	a.mu.Unlock()
+
+	if fire {
+		a.onExceeded(ctx, count, entry)
+	}
+}
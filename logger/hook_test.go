@@ -0,0 +1,131 @@
+package logger
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// countingHook is an ErrorHook recording its entries.
+type countingHook struct {
+	entries []LogEntry
+}
+
+func (h *countingHook) hook(_ context.Context, entry LogEntry) {
+	h.entries = append(h.entries, entry)
+}
+
+func (h *countingHook) levels() []string {
+	var levels []string
+	for _, e := range h.entries {
+		levels = append(levels, e.Level)
+	}
+	return levels
+}
+
+func TestJSONLoggerErrorHookAndFatal(t *testing.T) {
+	hook := &countingHook{}
+	var codes []int
+	l := NewSimpleJSONLogger(wotop.ApplicationData{AppName: "shop"}, "development",
+		WithErrorHook(hook.hook),
+		WithExitFunc(func(code int) { codes = append(codes, code) }),
+	)
+	ctx := SetTraceID(context.Background(), "trace-1")
+
+	var err error
+	out := captureStdout(t, func() {
+		l.Info(ctx, "order placed")
+		l.Warning(ctx, "stock low")
+		l.Error(ctx, "payment %s failed", "p-1")
+		LogFields(l, ctx, LevelError, "refund failed", Fields{"order": "o-1"})
+		err = Fatal(l, ctx, "database %s unreachable", "orders")
+	})
+
+	assert.Contains(t, out, "FATAL trace-1 database orders unreachable")
+	assert.EqualError(t, err, "database orders unreachable")
+	assert.Equal(t, []int{1}, codes)
+
+	assert.Equal(t, []string{LevelError, LevelError, LevelFatal}, hook.levels())
+	assert.Equal(t, "payment p-1 failed", hook.entries[0].Message)
+	assert.Equal(t, "trace-1", hook.entries[0].TraceID)
+	assert.Equal(t, Fields{"order": "o-1"}, hook.entries[1].Fields)
+	assert.Equal(t, "database orders unreachable", hook.entries[2].Message)
+}
+
+func TestGraylogErrorHookAndFatal(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	hook := &countingHook{}
+	var codes []int
+	l := &graylogModel{logger: zap.New(core), hooks: newHooks([]Option{
+		WithErrorHook(hook.hook),
+		WithExitFunc(func(code int) { codes = append(codes, code) }),
+	})}
+	ctx := context.Background()
+
+	l.Info(ctx, "order placed")
+	l.Error(ctx, "payment failed")
+	l.ErrorFields(ctx, "refund failed", Fields{"order": "o-1"})
+	err := l.Fatal(ctx, "database %s unreachable", "orders")
+
+	assert.EqualError(t, err, "database orders unreachable")
+	assert.Equal(t, []int{1}, codes)
+	assert.Equal(t, []string{LevelError, LevelError, LevelFatal}, hook.levels())
+
+	require.Equal(t, 4, logs.Len())
+	assert.Equal(t, zapcore.FatalLevel, logs.All()[3].Level)
+	assert.Equal(t, "database orders unreachable", logs.All()[3].Message)
+}
+
+func TestFatalPlainLogger(t *testing.T) {
+	var codes []int
+	previous := exit
+	exit = func(code int) { codes = append(codes, code) }
+	defer func() { exit = previous }()
+
+	l := &plainLogger{}
+	err := Fatal(l, context.Background(), "config missing")
+	assert.EqualError(t, err, "config missing")
+	assert.Equal(t, []int{1}, codes)
+}
+
+func TestErrorRateAlert(t *testing.T) {
+	clock := util.NewFrozenClock(time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC))
+
+	var alerts []int
+	alert := NewErrorRateAlert(3, time.Minute, func(_ context.Context, count int, _ LogEntry) {
+		alerts = append(alerts, count)
+	})
+	alert.clock = clock
+
+	l := NewSimpleJSONLogger(wotop.ApplicationData{}, "production", WithErrorHook(alert.Hook))
+	ctx := context.Background()
+	burst := func(n int) {
+		captureStdout(t, func() {
+			for range n {
+				l.Error(ctx, "payment failed")
+				clock.Advance(time.Second)
+			}
+		})
+	}
+
+	burst(3)
+	assert.Empty(t, alerts)
+
+	// the fourth error of the minute exceeds the threshold, the alert fires once
+	burst(3)
+	assert.Equal(t, []int{4}, alerts)
+
+	// the window slides past the burst, the alert is armed again
+	clock.Advance(2 * time.Minute)
+	burst(1)
+	burst(4)
+	assert.Equal(t, []int{4, 4}, alerts)
+}
@@ -2,8 +2,12 @@ package logger
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/util"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"strings"
 	"time"
 )
@@ -11,15 +15,22 @@ import (
 // NewSimpleJSONLogger creates a new instance of a simple JSON logger.
 //
 // This logger is used to log messages in JSON format with application data and stage information.
+// Its level is info in the development stage and error in the others, it can be changed with
+// SetLevel, e.g. by LevelHandler.
 //
 // Parameters:
 //   - appData: The application data containing metadata such as app name and instance ID.
 //   - stage: The application stage (e.g., development, production).
+//   - opts: Options such as WithErrorHook and WithExitFunc.
 //
 // Returns:
 //   - A Logger instance that logs messages in JSON format.
-func NewSimpleJSONLogger(appData wotop.ApplicationData, stage string) Logger {
-	return &simpleJSONLoggerImpl{AppData: appData, Stage: stage}
+func NewSimpleJSONLogger(appData wotop.ApplicationData, stage string, opts ...Option) Logger {
+	level := zap.NewAtomicLevelAt(zapcore.ErrorLevel)
+	if strings.TrimSpace(strings.ToLower(stage)) == "development" {
+		level = zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	}
+	return &simpleJSONLoggerImpl{AppData: appData, Stage: stage, level: AtomicLevel{zap: level}, hooks: newHooks(opts)}
 }
 
 // jsonLogModel represents the structure of a JSON log entry.
@@ -31,7 +42,7 @@ func NewSimpleJSONLogger(appData wotop.ApplicationData, stage string) Logger {
 //   - Severity: The severity level of the log (e.g., INFO, WARNING, ERROR).
 //   - Message: The log message.
 //   - Location: The location in the code where the log was generated.
-//   - Time: The timestamp of the log entry.
+//   - Time: The timestamp of the log entry, in RFC 3339 and UTC.
 type jsonLogModel struct {
 	AppName   string `json:"appName"`
 	AppInstID string `json:"appInstID"`
@@ -66,7 +77,7 @@ func newJSONLogModel(lg *simpleJSONLoggerImpl, flag, loc string, msg, trid any)
 			Severity:  flag,
 			Message:   fmt.Sprintf("%v %v %v", trid, loc, msg),
 			Location:  loc,
-			Time:      time.Now().Format("2006-01-02 15:04:05"),
+			Time:      util.FormatRFC3339UTC(time.Now()),
 		})
 	}
 
@@ -77,7 +88,7 @@ func newJSONLogModel(lg *simpleJSONLoggerImpl, flag, loc string, msg, trid any)
 		Severity:  flag,
 		Message:   fmt.Sprintf("%v %v", trid, msg),
 		Location:  loc,
-		Time:      time.Now().Format("2006-01-02 15:04:05"),
+		Time:      util.FormatRFC3339UTC(time.Now()),
 	})
 }
 
@@ -87,21 +98,60 @@ func newJSONLogModel(lg *simpleJSONLoggerImpl, flag, loc string, msg, trid any)
 // Fields:
 //   - AppData: The application data containing metadata such as app name and instance ID.
 //   - Stage: The application stage (e.g., development, production).
+//   - level: The level of the logger, set from the stage.
+//   - hooks: The error hooks and the exit function of Fatal.
 type simpleJSONLoggerImpl struct {
 	AppData wotop.ApplicationData
 	Stage   string
+	level   AtomicLevel
+	hooks   *hooks
+}
+
+// Level returns the current level of the logger.
+//
+// Returns:
+//   - One of debug, info, warning and error.
+func (l simpleJSONLoggerImpl) Level() string {
+	return l.level.Level()
+}
+
+// SetLevel changes the level of the logger.
+//
+// Parameters:
+//   - level: The new level, one of debug, info, warning and error.
+//
+// Returns:
+//   - ErrInvalidLevel if the level is unknown.
+func (l simpleJSONLoggerImpl) SetLevel(level string) error {
+	return l.level.SetLevel(level)
+}
+
+// Debug logs a debug message in JSON format.
+//
+// This function only logs messages when the level of the logger is debug.
+//
+// Parameters:
+//   - ctx: The context for the log entry.
+//   - message: The debug message to log.
+//   - args: Optional arguments to format the message.
+func (l simpleJSONLoggerImpl) Debug(ctx context.Context, message string, args ...any) {
+	if !l.level.enabled(levelDebug) {
+		return
+	}
+	messageWithArgs := fmt.Sprintf(message, args...)
+	l.printLog(ctx, levelDebug, messageWithArgs)
 }
 
 // Warning logs a warning message in JSON format.
 //
-// This function only logs messages if the application stage is "development".
+// This function only logs messages when the level of the logger is warning or lower.
 //
 // Parameters:
 //   - ctx: The context for the log entry.
 //   - message: The warning message to log.
 //   - args: Optional arguments to format the message.
 func (l simpleJSONLoggerImpl) Warning(ctx context.Context, message string, args ...any) {
-	if strings.TrimSpace(strings.ToLower(l.Stage)) != "development" {
+	if !l.level.enabled(LevelWarning) {
 		return
 	}
 	messageWithArgs := fmt.Sprintf(message, args...)
@@ -110,14 +160,14 @@ func (l simpleJSONLoggerImpl) Warning(ctx context.Context, message string, args
 
 // Info logs an informational message in JSON format.
 //
-// This function only logs messages if the application stage is "development".
+// This function only logs messages when the level of the logger is info or lower.
 //
 // Parameters:
 //   - ctx: The context for the log entry.
 //   - message: The informational message to log.
 //   - args: Optional arguments to format the message.
 func (l simpleJSONLoggerImpl) Info(ctx context.Context, message string, args ...any) {
-	if strings.TrimSpace(strings.ToLower(l.Stage)) != "development" {
+	if !l.level.enabled(LevelInfo) {
 		return
 	}
 	messageWithArgs := fmt.Sprintf(message, args...)
@@ -126,7 +176,8 @@ func (l simpleJSONLoggerImpl) Info(ctx context.Context, message string, args ...
 
 // Error logs an error message in JSON format.
 //
-// This function logs error messages regardless of the application stage.
+// This function logs error messages regardless of the application stage, and calls the
+// error hooks of the logger.
 //
 // Parameters:
 //   - ctx: The context for the log entry.
@@ -135,6 +186,69 @@ func (l simpleJSONLoggerImpl) Info(ctx context.Context, message string, args ...
 func (l simpleJSONLoggerImpl) Error(ctx context.Context, message string, args ...any) {
 	messageWithArgs := fmt.Sprintf(message, args...)
 	l.printLog(ctx, "ERROR", messageWithArgs)
+	l.hooks.fire(ctx, LevelError, messageWithArgs, nil)
+}
+
+// Fatal logs a fatal message in JSON format, calls the error hooks of the logger and
+// terminates the process with the exit function of the logger.
+//
+// Parameters:
+//   - ctx: The context for the log entry.
+//   - message: The fatal message to log.
+//   - args: Optional arguments to format the message.
+//
+// Returns:
+//   - The message as an error, returned when the exit function returns, e.g. in tests.
+func (l simpleJSONLoggerImpl) Fatal(ctx context.Context, message string, args ...any) error {
+	messageWithArgs := fmt.Sprintf(message, args...)
+	l.printLog(ctx, LevelFatal, messageWithArgs)
+	l.hooks.fire(ctx, LevelFatal, messageWithArgs, nil)
+	l.hooks.exitFunc()(1)
+	return errors.New(messageWithArgs)
+}
+
+// WarningFields logs a warning message with structured fields, appended to the message as JSON.
+//
+// This function only logs messages when the level of the logger is warning or lower.
+//
+// Parameters:
+//   - ctx: The context for the log entry.
+//   - message: The warning message to log.
+//   - fields: The structured fields of the entry.
+func (l simpleJSONLoggerImpl) WarningFields(ctx context.Context, message string, fields Fields) {
+	if !l.level.enabled(LevelWarning) {
+		return
+	}
+	l.printLog(ctx, LevelWarning, message+" "+toJsonString(fields))
+}
+
+// InfoFields logs an informational message with structured fields, appended to the message as JSON.
+//
+// This function only logs messages when the level of the logger is info or lower.
+//
+// Parameters:
+//   - ctx: The context for the log entry.
+//   - message: The informational message to log.
+//   - fields: The structured fields of the entry.
+func (l simpleJSONLoggerImpl) InfoFields(ctx context.Context, message string, fields Fields) {
+	if !l.level.enabled(LevelInfo) {
+		return
+	}
+	l.printLog(ctx, LevelInfo, message+" "+toJsonString(fields))
+}
+
+// ErrorFields logs an error message with structured fields, appended to the message as JSON.
+//
+// This function logs error messages regardless of the application stage, and calls the
+// error hooks of the logger.
+//
+// Parameters:
+//   - ctx: The context for the log entry.
+//   - message: The error message to log.
+//   - fields: The structured fields of the entry.
+func (l simpleJSONLoggerImpl) ErrorFields(ctx context.Context, message string, fields Fields) {
+	l.printLog(ctx, LevelError, message+" "+toJsonString(fields))
+	l.hooks.fire(ctx, LevelError, message, fields)
 }
 
 // printLog formats and prints a log entry.
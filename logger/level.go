@@ -0,0 +1,277 @@
+package logger
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/model/apperror"
+	"github.com/a-aslani/wotop/model/payload"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	ErrInvalidLevel        apperror.ErrorType = "ER0601 invalid log level %q, expected debug, info, warning or error"
+	ErrInvalidLevelRequest apperror.ErrorType = "ER0602 invalid log level request: %s"
+	ErrLevelNotSupported   apperror.ErrorType = "ER0603 the logger cannot change its level"
+	ErrInternal            apperror.ErrorType = "ER0604 internal server error"
+	ErrInvalidSentryDSN    apperror.ErrorType = "ER0605 invalid Sentry DSN: %s"
+)
+
+func init() {
+	apperror.Register("logger",
+		apperror.Entry{Err: ErrInvalidLevel, Description: "The log level is not one of debug, info, warning and error."},
+		apperror.Entry{Err: ErrInvalidLevelRequest, Description: "The body of the log level request is malformed."},
+		apperror.Entry{Err: ErrLevelNotSupported, Description: "The logger of the application has a fixed level."},
+		apperror.Entry{Err: ErrInternal, Description: "A handler panicked, the details are in the logs under the trace ID."},
+		apperror.Entry{Err: ErrInvalidSentryDSN, Description: "The Sentry DSN is not of the form <scheme>://<public key>@<host>/<project ID>."},
+	)
+
+	apperror.MapCode(ErrInvalidLevel.Code(), http.StatusBadRequest)
+	apperror.MapCode(ErrInvalidLevelRequest.Code(), http.StatusBadRequest)
+	apperror.MapCode(ErrLevelNotSupported.Code(), http.StatusNotImplemented)
+	apperror.MapCode(ErrInternal.Code(), http.StatusInternalServerError)
+}
+
+// Level names accepted by SetLevel, from the most to the least verbose.
+const (
+	LevelNameDebug   = "debug"
+	LevelNameInfo    = "info"
+	LevelNameWarning = "warning"
+	LevelNameError   = "error"
+)
+
+// levelDebug is the severity of the debug entries.
+const levelDebug = "DEBUG"
+
+// LevelSetter is a Logger whose level can be changed at runtime, e.g. by LevelHandler. The
+// JSON and the Graylog loggers implement it.
+//
+// Methods:
+//   - Level: Returns the current level.
+//   - SetLevel: Changes the level, the entries below it are dropped.
+type LevelSetter interface {
+	Level() string
+	SetLevel(level string) error
+}
+
+// DebugLogger is a Logger writing debug entries, which are only written when its level is
+// debug. The JSON and the Graylog loggers implement it.
+type DebugLogger interface {
+	Logger
+	Debug(ctx context.Context, message string, args ...any)
+}
+
+// Debug logs a debug message when the logger is a DebugLogger, and drops it otherwise.
+//
+// Parameters:
+//   - l: The logger writing the entry.
+//   - ctx: The context for the log entry.
+//   - message: The debug message to log.
+//   - args: Optional arguments to format the message.
+func Debug(l Logger, ctx context.Context, message string, args ...any) {
+	if dl, ok := l.(DebugLogger); ok {
+		dl.Debug(ctx, message, args...)
+	}
+}
+
+// AtomicLevel is a log level which can be changed while the logger is used. The copies of an
+// AtomicLevel share its level.
+type AtomicLevel struct {
+	zap zap.AtomicLevel
+}
+
+// NewAtomicLevel creates an AtomicLevel set to the level.
+//
+// Parameters:
+//   - level: The initial level, one of debug, info, warning and error.
+//
+// Returns:
+//   - The AtomicLevel.
+//   - ErrInvalidLevel if the level is unknown.
+func NewAtomicLevel(level string) (AtomicLevel, error) {
+	l, err := parseLevel(level)
+	if err != nil {
+		return AtomicLevel{}, err
+	}
+	return AtomicLevel{zap: zap.NewAtomicLevelAt(l)}, nil
+}
+
+// Level returns the current level.
+//
+// Returns:
+//   - One of debug, info, warning and error.
+func (a AtomicLevel) Level() string {
+	switch a.zap.Level() {
+	case zapcore.DebugLevel:
+		return LevelNameDebug
+	case zapcore.InfoLevel:
+		return LevelNameInfo
+	case zapcore.WarnLevel:
+		return LevelNameWarning
+	default:
+		return LevelNameError
+	}
+}
+
+// SetLevel changes the level.
+//
+// Parameters:
+//   - level: The new level, one of debug, info, warning and error, in any case.
+//
+// Returns:
+//   - ErrInvalidLevel if the level is unknown, the level is unchanged then.
+func (a AtomicLevel) SetLevel(level string) error {
+	l, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+	a.zap.SetLevel(l)
+	return nil
+}
+
+// enabled reports whether the entries of the severity are written, the severity being
+// LevelInfo, LevelWarning, LevelError or levelDebug. The zero AtomicLevel enables them all.
+func (a AtomicLevel) enabled(severity string) bool {
+	l, err := parseLevel(severity)
+	if err != nil || a.zap == (zap.AtomicLevel{}) {
+		return true
+	}
+	return a.zap.Enabled(l)
+}
+
+// parseLevel converts a level name to its zap level.
+func parseLevel(level string) (zapcore.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case LevelNameDebug:
+		return zapcore.DebugLevel, nil
+	case LevelNameInfo:
+		return zapcore.InfoLevel, nil
+	case LevelNameWarning, "warn":
+		return zapcore.WarnLevel, nil
+	case LevelNameError:
+		return zapcore.ErrorLevel, nil
+	}
+	return 0, ErrInvalidLevel.Var(level)
+}
+
+// levelRequest is the body of a PUT to LevelHandler.
+//
+// Fields:
+//   - Level: The new level.
+//   - RevertAfter: The duration, e.g. "15m", after which the previous level is restored, never when empty.
+type levelRequest struct {
+	Level       string `json:"level"`
+	RevertAfter string `json:"revert_after"`
+}
+
+// levelResponse is the data of the responses of LevelHandler.
+//
+// Fields:
+//   - Level: The current level.
+//   - RevertAt: When the previous level is restored, if it is.
+type levelResponse struct {
+	Level    string     `json:"level"`
+	RevertAt *time.Time `json:"revert_at,omitempty"`
+}
+
+// LevelHandler creates a Gin handler reading the level of the logger on GET and changing it on
+// PUT, with a body such as {"level": "debug", "revert_after": "15m"}. The previous level is
+// restored after revert_after, unless the level is changed again before. Mount it behind an
+// authorization middleware, e.g. jwt.GinMiddleware.RequireRole, and share the handler between
+// the GET and the PUT routes, so GET reports the pending revert.
+//
+// Parameters:
+//   - l: The logger, a LevelSetter, the handler answers 501 Not Implemented otherwise.
+//
+// Returns:
+//   - A Gin handler function for GET and PUT.
+func LevelHandler(l Logger) gin.HandlerFunc {
+
+	var mu sync.Mutex
+	var revert *time.Timer
+	var revertAt *time.Time
+
+	return func(c *gin.Context) {
+
+		traceID, ok := wotop.TraceIDFromContext(c.Request.Context())
+		if !ok {
+			traceID = GetTraceID(c.Request.Context())
+		}
+
+		setter, ok := l.(LevelSetter)
+		if !ok {
+			payload.WriteError(c, ErrLevelNotSupported, traceID)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if c.Request.Method != http.MethodPut {
+			c.JSON(http.StatusOK, payload.NewSuccessResponse(levelResponse{Level: setter.Level(), RevertAt: revertAt}, traceID))
+			return
+		}
+
+		var req levelRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			payload.WriteError(c, ErrInvalidLevelRequest.Var(err.Error()), traceID)
+			return
+		}
+
+		var revertAfter time.Duration
+		if req.RevertAfter != "" {
+			d, err := time.ParseDuration(req.RevertAfter)
+			if err != nil || d <= 0 {
+				payload.WriteError(c, ErrInvalidLevelRequest.Var("revert_after must be a positive duration"), traceID)
+				return
+			}
+			revertAfter = d
+		}
+
+		previous := setter.Level()
+		if err := setter.SetLevel(req.Level); err != nil {
+			payload.WriteError(c, err, traceID)
+			return
+		}
+
+		// a new level cancels the pending revert
+		if revert != nil {
+			revert.Stop()
+			revert, revertAt = nil, nil
+		}
+
+		if revertAfter > 0 {
+			at := time.Now().Add(revertAfter)
+			revertAt = &at
+
+			var timer *time.Timer
+			timer = time.AfterFunc(revertAfter, func() {
+				mu.Lock()
+				defer mu.Unlock()
+
+				// the timer was stopped too late, a newer level is set
+				if revert != timer {
+					return
+				}
+				_ = setter.SetLevel(previous)
+				revert, revertAt = nil, nil
+			})
+			revert = timer
+		}
+
+		LogFields(l, c.Request.Context(), LevelWarning, "log level changed", Fields{
+			"level":        setter.Level(),
+			"previous":     previous,
+			"revert_after": req.RevertAfter,
+			"trace_id":     traceID,
+		})
+
+		c.JSON(http.StatusOK, payload.NewSuccessResponse(levelResponse{Level: setter.Level(), RevertAt: revertAt}, traceID))
+	}
+}
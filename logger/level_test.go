@@ -0,0 +1,182 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// captureStdout returns what f prints to the standard output.
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	f()
+	require.NoError(t, w.Close())
+
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(out)
+}
+
+func TestJSONLoggerLevel(t *testing.T) {
+	ctx := context.Background()
+	l := NewSimpleJSONLogger(wotop.ApplicationData{AppName: "shop"}, "production")
+	setter := l.(LevelSetter)
+
+	// the production stage only logs errors
+	assert.Equal(t, LevelNameError, setter.Level())
+	out := captureStdout(t, func() {
+		l.Info(ctx, "order %s placed", "o-1")
+		Debug(l, ctx, "cart %s loaded", "c-1")
+		l.Error(ctx, "payment failed")
+	})
+	assert.NotContains(t, out, "order o-1 placed")
+	assert.NotContains(t, out, "cart c-1 loaded")
+	assert.Contains(t, out, "payment failed")
+
+	require.NoError(t, setter.SetLevel("DEBUG"))
+	out = captureStdout(t, func() {
+		l.Info(ctx, "order %s placed", "o-1")
+		Debug(l, ctx, "cart %s loaded", "c-1")
+	})
+	assert.Contains(t, out, "order o-1 placed")
+	assert.Contains(t, out, "cart c-1 loaded")
+
+	require.NoError(t, setter.SetLevel("warning"))
+	out = captureStdout(t, func() {
+		l.Info(ctx, "order %s placed", "o-1")
+		LogFields(l, ctx, LevelWarning, "stock low", Fields{"sku": "s-1"})
+	})
+	assert.NotContains(t, out, "order o-1 placed")
+	assert.Contains(t, out, `stock low {"sku":"s-1"}`)
+
+	assert.ErrorContains(t, setter.SetLevel("verbose"), `invalid log level "verbose"`)
+	assert.Equal(t, LevelNameWarning, setter.Level())
+
+	development := NewSimpleJSONLogger(wotop.ApplicationData{AppName: "shop"}, "development")
+	assert.Equal(t, LevelNameInfo, development.(LevelSetter).Level())
+}
+
+func TestGraylogLevel(t *testing.T) {
+	level, err := NewAtomicLevel(LevelNameInfo)
+	require.NoError(t, err)
+
+	core, logs := observer.New(level.zap)
+	l := &graylogModel{logger: zap.New(core), level: level}
+	ctx := context.Background()
+
+	Debug(l, ctx, "cart loaded")
+	l.Info(ctx, "order placed")
+	assert.Equal(t, 1, logs.Len())
+
+	require.NoError(t, l.SetLevel(LevelNameDebug))
+	Debug(l, ctx, "cart loaded")
+	assert.Equal(t, 2, logs.Len())
+
+	require.NoError(t, l.SetLevel(LevelNameError))
+	l.Warning(ctx, "stock low")
+	l.Error(ctx, "payment failed")
+	assert.Equal(t, []string{"order placed", "cart loaded", "payment failed"}, messages(logs))
+}
+
+func messages(logs *observer.ObservedLogs) []string {
+	var messages []string
+	for _, e := range logs.All() {
+		messages = append(messages, e.Message)
+	}
+	return messages
+}
+
+func newLevelRouter(l Logger) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	handler := LevelHandler(l)
+	r.GET("/log-level", handler)
+	r.PUT("/log-level", handler)
+	return r
+}
+
+// levelCall sends a request to the level handler and returns the status and the level in the response.
+func levelCall(t *testing.T, r http.Handler, method, body string) (int, map[string]any) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(method, "/log-level", strings.NewReader(body)))
+
+	var res map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	return rec.Code, res
+}
+
+func TestLevelHandler(t *testing.T) {
+	l := NewSimpleJSONLogger(wotop.ApplicationData{AppName: "shop"}, "production")
+	r := newLevelRouter(l)
+
+	status, res := levelCall(t, r, http.MethodGet, "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, map[string]any{"level": "error"}, res["data"])
+
+	status, res = levelCall(t, r, http.MethodPut, `{"level":"debug"}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, map[string]any{"level": "debug"}, res["data"])
+	assert.Contains(t, captureStdout(t, func() { Debug(l, context.Background(), "cart loaded") }), "cart loaded")
+
+	status, res = levelCall(t, r, http.MethodPut, `{"level":"loud"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "ER0601", res["error_code"])
+
+	status, res = levelCall(t, r, http.MethodPut, `{"level":"info","revert_after":"soon"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "ER0602", res["error_code"])
+	assert.Equal(t, LevelNameDebug, l.(LevelSetter).Level())
+}
+
+func TestLevelHandlerRevert(t *testing.T) {
+	l := NewSimpleJSONLogger(wotop.ApplicationData{AppName: "shop"}, "production")
+	setter := l.(LevelSetter)
+	r := newLevelRouter(l)
+
+	status, res := levelCall(t, r, http.MethodPut, `{"level":"debug","revert_after":"50ms"}`)
+	require.Equal(t, http.StatusOK, status)
+	assert.Contains(t, res["data"], "revert_at")
+	assert.Equal(t, LevelNameDebug, setter.Level())
+
+	require.Eventually(t, func() bool {
+		return setter.Level() == LevelNameError
+	}, time.Second, 5*time.Millisecond)
+
+	_, res = levelCall(t, r, http.MethodGet, "")
+	assert.Equal(t, map[string]any{"level": "error"}, res["data"])
+
+	// a new level cancels the pending revert
+	levelCall(t, r, http.MethodPut, `{"level":"debug","revert_after":"50ms"}`)
+	levelCall(t, r, http.MethodPut, `{"level":"info"}`)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, LevelNameInfo, setter.Level())
+}
+
+func TestLevelHandlerNotSupported(t *testing.T) {
+	status, res := levelCall(t, newLevelRouter(&recordingLogger{}), http.MethodGet, "")
+	assert.Equal(t, http.StatusNotImplemented, status)
+	assert.Equal(t, "ER0603", res["error_code"])
+}
@@ -0,0 +1,110 @@
+// Package loggertest provides a logger recording its entries, for the tests of the packages
+// taking a logger.Logger.
+package loggertest
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+
+	"github.com/a-aslani/wotop/logger"
+)
+
+// LevelDebug is the level of the entries written with Debug.
+const LevelDebug = "DEBUG"
+
+// Entry is an entry written to a Recorder.
+//
+// Fields:
+//   - Level: The level of the entry, e.g. logger.LevelError or LevelDebug.
+//   - Message: The message, formatted with its arguments.
+//   - Fields: A copy of the structured fields, nil for the entries written without.
+type Entry struct {
+	Level   string
+	Message string
+	Fields  logger.Fields
+}
+
+// Recorder is a logger.FieldLogger and a logger.DebugLogger recording its entries. Its zero
+// value is ready to use and it is safe for concurrent use.
+type Recorder struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// Info records an informational message.
+func (r *Recorder) Info(_ context.Context, message string, args ...any) {
+	r.record(logger.LevelInfo, fmt.Sprintf(message, args...), nil)
+}
+
+// Warning records a warning message.
+func (r *Recorder) Warning(_ context.Context, message string, args ...any) {
+	r.record(logger.LevelWarning, fmt.Sprintf(message, args...), nil)
+}
+
+// Error records an error message.
+func (r *Recorder) Error(_ context.Context, message string, args ...any) {
+	r.record(logger.LevelError, fmt.Sprintf(message, args...), nil)
+}
+
+// Debug records a debug message.
+func (r *Recorder) Debug(_ context.Context, message string, args ...any) {
+	r.record(LevelDebug, fmt.Sprintf(message, args...), nil)
+}
+
+// InfoFields records an informational message with structured fields.
+func (r *Recorder) InfoFields(_ context.Context, message string, fields logger.Fields) {
+	r.record(logger.LevelInfo, message, fields)
+}
+
+// WarningFields records a warning message with structured fields.
+func (r *Recorder) WarningFields(_ context.Context, message string, fields logger.Fields) {
+	r.record(logger.LevelWarning, message, fields)
+}
+
+// ErrorFields records an error message with structured fields.
+func (r *Recorder) ErrorFields(_ context.Context, message string, fields logger.Fields) {
+	r.record(logger.LevelError, message, fields)
+}
+
+// Entries returns the recorded entries, oldest first.
+func (r *Recorder) Entries() []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Entry(nil), r.entries...)
+}
+
+// Messages returns the messages recorded at a level, oldest first.
+//
+// Parameters:
+//   - level: The level, e.g. logger.LevelWarning.
+//
+// Returns:
+//   - The messages, nil when none is recorded at the level.
+func (r *Recorder) Messages(level string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var messages []string
+	for _, e := range r.entries {
+		if e.Level == level {
+			messages = append(messages, e.Message)
+		}
+	}
+	return messages
+}
+
+// Reset drops the recorded entries.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = nil
+}
+
+// record appends an entry, copying its fields as the caller may reuse them.
+func (r *Recorder) record(level, message string, fields logger.Fields) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, Entry{Level: level, Message: message, Fields: maps.Clone(fields)})
+}
@@ -0,0 +1,32 @@
+package loggertest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/a-aslani/wotop/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorder(t *testing.T) {
+	r := &Recorder{}
+	ctx := context.Background()
+
+	fields := logger.Fields{"order": "o-1"}
+	r.Info(ctx, "order %s placed", "o-1")
+	r.Warning(ctx, "stock low")
+	logger.Debug(r, ctx, "cache %s", "hit")
+	logger.LogFields(r, ctx, logger.LevelError, "payment failed", fields)
+	fields["order"] = "o-2"
+
+	assert.Equal(t, []Entry{
+		{Level: logger.LevelInfo, Message: "order o-1 placed"},
+		{Level: logger.LevelWarning, Message: "stock low"},
+		{Level: LevelDebug, Message: "cache hit"},
+		{Level: logger.LevelError, Message: "payment failed", Fields: logger.Fields{"order": "o-1"}},
+	}, r.Entries())
+	assert.Equal(t, []string{"payment failed"}, r.Messages(logger.LevelError))
+
+	r.Reset()
+	assert.Empty(t, r.Entries())
+}
@@ -0,0 +1,93 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strings"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/model/payload"
+	"github.com/a-aslani/wotop/util"
+	"github.com/gin-gonic/gin"
+)
+
+// RecoveryMiddleware returns a Gin middleware recovering the panics of the handlers, it
+// replaces gin.Recovery. The panic is logged as an error with its stack and the trace ID of
+// the request, and the client is answered with a payload.Response holding ErrInternal, the
+// panic message is never sent to it.
+//
+// Like gin.Recovery, the panics of a broken connection are not logged and nothing is written
+// to the connection, the error is only added to the errors of the context.
+//
+// Parameters:
+//   - log: The logger writing the panics.
+//
+// Returns:
+//   - A gin.HandlerFunc to register with gin.Engine.Use.
+func RecoveryMiddleware(log Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+
+			if err, ok := rec.(error); ok && brokenConnection(err) {
+				_ = c.Error(err)
+				c.Abort()
+				return
+			}
+
+			ctx := c.Request.Context()
+			traceID, ok := wotop.TraceIDFromContext(ctx)
+			if !ok {
+				traceID = util.GenerateID(16)
+				ctx = SetTraceID(ctx, traceID)
+				c.Request = c.Request.WithContext(ctx)
+			}
+
+			LogFields(log, ctx, LevelError, "panic recovered", Fields{
+				"panic":  fmt.Sprint(rec),
+				"stack":  string(debug.Stack()),
+				"method": c.Request.Method,
+				"path":   c.Request.URL.Path,
+			})
+
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+
+			payload.WriteError(c, ErrInternal, traceID)
+			c.Abort()
+		}()
+
+		c.Next()
+	}
+}
+
+// brokenConnection reports whether the panic is caused by a connection closed by the client,
+// which does not deserve a stack trace.
+func brokenConnection(err error) bool {
+	if errors.Is(err, http.ErrAbortHandler) {
+		return true
+	}
+
+	var ne *net.OpError
+	if !errors.As(err, &ne) {
+		return false
+	}
+
+	var se *os.SyscallError
+	if !errors.As(ne, &se) {
+		return false
+	}
+
+	msg := strings.ToLower(se.Error())
+	return strings.Contains(msg, "broken pipe") || strings.Contains(msg, "connection reset by peer")
+}
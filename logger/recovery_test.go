@@ -0,0 +1,100 @@
+package logger
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/a-aslani/wotop"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRecoveryRouter(l Logger) (*gin.Engine, *[]error) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	var errs []error
+	r.Use(func(c *gin.Context) {
+		c.Next()
+		for _, e := range c.Errors {
+			errs = append(errs, e.Err)
+		}
+	})
+	r.Use(RecoveryMiddleware(l))
+
+	r.GET("/orders", func(c *gin.Context) { panic("dial tcp: password=hunter2") })
+	r.GET("/stream", func(c *gin.Context) {
+		panic(&net.OpError{Op: "write", Net: "tcp", Err: os.NewSyscallError("write", syscall.EPIPE)})
+	})
+	r.GET("/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+	return r, &errs
+}
+
+func TestRecoveryMiddleware(t *testing.T) {
+	l := &recordingLogger{}
+	r, _ := newRecoveryRouter(l)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NotContains(t, w.Body.String(), "hunter2")
+
+	var res map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, false, res["success"])
+	assert.Equal(t, "ER0604", res["error_code"])
+	assert.Len(t, res["trace_id"], 16)
+
+	require.Len(t, l.entries, 1)
+	e := l.entries[0]
+	assert.Equal(t, LevelError, e.level)
+	assert.Equal(t, "dial tcp: password=hunter2", e.fields["panic"])
+	assert.Contains(t, e.fields["stack"], "recovery_test.go")
+	assert.Equal(t, "/orders", e.fields["path"])
+}
+
+func TestRecoveryMiddlewareReusesTheTraceID(t *testing.T) {
+	l := &recordingLogger{}
+	r, _ := newRecoveryRouter(l)
+
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req = req.WithContext(wotop.WithTraceID(req.Context(), "4bf92f3577b34da6"))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var res map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, "4bf92f3577b34da6", res["trace_id"])
+}
+
+func TestRecoveryMiddlewareBrokenPipe(t *testing.T) {
+	l := &recordingLogger{}
+	r, errs := newRecoveryRouter(l)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stream", nil))
+
+	assert.Empty(t, w.Body.String())
+	assert.Empty(t, l.entries)
+	require.Len(t, *errs, 1)
+	assert.ErrorIs(t, (*errs)[0], syscall.EPIPE)
+}
+
+func TestRecoveryMiddlewareWithoutPanic(t *testing.T) {
+	l := &recordingLogger{}
+	r, _ := newRecoveryRouter(l)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ping", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "pong", w.Body.String())
+	assert.Empty(t, l.entries)
+}
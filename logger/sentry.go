@@ -0,0 +1,609 @@
+package logger
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultSentryMaxBreadcrumbs is the number of breadcrumbs kept by the Sentry logger when its
+// options set none.
+const DefaultSentryMaxBreadcrumbs = 30
+
+// DefaultSentryMaxTraces is the number of traces whose breadcrumbs are kept by the Sentry logger
+// when its options set none.
+const DefaultSentryMaxTraces = 1000
+
+// DefaultSentryFlushTimeout is the time Fatal waits for the events to be sent to Sentry.
+const DefaultSentryFlushTimeout = 2 * time.Second
+
+// sentryQueueSize is the number of events the HTTP transport buffers, the events are dropped
+// when it is full.
+const sentryQueueSize = 64
+
+// sentryDefaultRetryAfter is the time the HTTP transport stops sending the events when the
+// server answers 429 without a valid Retry-After header.
+const sentryDefaultRetryAfter = time.Minute
+
+// SentryEvent is an event sent to Sentry, a subset of the Sentry event payload.
+//
+// Fields:
+//   - EventID: The ID of the event, 32 hexadecimal characters.
+//   - Timestamp: When the entry was logged.
+//   - Level: "error" or "fatal".
+//   - Platform: Always "go".
+//   - Logger: The name of the logger, "wotop".
+//   - Environment: The stage of the application.
+//   - Release: The release of the application, when set in the options.
+//   - Message: The message of the entry.
+//   - Tags: The tags of the event, the trace ID of the entry under "trace_id".
+//   - Extra: The structured fields of the entry.
+//   - Breadcrumbs: The warnings, and the infos when captured, logged with the same trace ID before.
+type SentryEvent struct {
+	EventID     string             `json:"event_id"`
+	Timestamp   time.Time          `json:"timestamp"`
+	Level       string             `json:"level"`
+	Platform    string             `json:"platform"`
+	Logger      string             `json:"logger"`
+	Environment string             `json:"environment,omitempty"`
+	Release     string             `json:"release,omitempty"`
+	Message     string             `json:"message"`
+	Tags        map[string]string  `json:"tags,omitempty"`
+	Extra       map[string]any     `json:"extra,omitempty"`
+	Breadcrumbs []SentryBreadcrumb `json:"breadcrumbs,omitempty"`
+}
+
+// SentryBreadcrumb is an entry leading to a Sentry event.
+//
+// Fields:
+//   - Timestamp: When the entry was logged.
+//   - Level: "warning" or "info".
+//   - Category: Always "log".
+//   - Message: The message of the entry.
+//   - Data: The structured fields of the entry.
+type SentryBreadcrumb struct {
+	Timestamp time.Time      `json:"timestamp"`
+	Level     string         `json:"level"`
+	Category  string         `json:"category"`
+	Message   string         `json:"message"`
+	Data      map[string]any `json:"data,omitempty"`
+}
+
+// SentryTransport sends the events of the Sentry logger, the tests replace it. A transport
+// having a Close() method is closed by SentryLogger.Close.
+//
+// Methods:
+//   - SendEvent: Sends an event, without blocking the caller.
+//   - Flush: Waits for the events to be sent, it reports false when the timeout expires before.
+type SentryTransport interface {
+	SendEvent(event *SentryEvent)
+	Flush(timeout time.Duration) bool
+}
+
+// SentryOptions configures the Sentry logger.
+//
+// Fields:
+//   - Release: The release of the application, e.g. the git commit.
+//   - CaptureInfo: Whether the info entries become breadcrumbs, they are dropped otherwise.
+//   - MaxBreadcrumbs: The number of breadcrumbs kept per trace, DefaultSentryMaxBreadcrumbs when
+//     zero.
+//   - MaxTraces: The number of traces whose breadcrumbs are kept, DefaultSentryMaxTraces when
+//     zero. The breadcrumbs of the least recently logged trace are dropped beyond.
+//   - Next: A logger the entries are written to as well, e.g. the JSON logger.
+//   - Transport: Sends the events, to the Sentry server of the DSN when nil.
+//   - Options: Options such as WithErrorHook and WithExitFunc.
+type SentryOptions struct {
+	Release        string
+	CaptureInfo    bool
+	MaxBreadcrumbs int
+	MaxTraces      int
+	Next           Logger
+	Transport      SentryTransport
+	Options        []Option
+}
+
+// sentryTrace holds the breadcrumbs of a trace, oldest first.
+type sentryTrace struct {
+	id          string
+	breadcrumbs []SentryBreadcrumb
+}
+
+// SentryLogger is a Logger sending the error entries to Sentry as events, tagged with their
+// trace ID and carrying their structured fields as extra context. The warning entries, and the
+// info entries when captured, become the breadcrumbs of the next event of their trace.
+type SentryLogger struct {
+	stage     string
+	opts      SentryOptions
+	transport SentryTransport
+	hooks     *hooks
+
+	mu     sync.Mutex
+	traces map[string]*list.Element // the elements of order by trace ID
+	order  *list.List               // the *sentryTrace, least recently logged first
+}
+
+// NewSentryLogger creates a SentryLogger.
+//
+// Parameters:
+//   - dsn: The DSN of the Sentry project, e.g. "https://key@o1.ingest.sentry.io/42".
+//   - stage: The application stage, the environment of the events.
+//   - opts: The options of the logger.
+//
+// Returns:
+//   - The Sentry logger.
+//   - ErrInvalidSentryDSN if the DSN is malformed and no transport is given.
+func NewSentryLogger(dsn string, stage string, opts SentryOptions) (*SentryLogger, error) {
+	transport := opts.Transport
+	if transport == nil {
+		t, err := newSentryHTTPTransport(dsn)
+		if err != nil {
+			return nil, err
+		}
+		transport = t
+	}
+
+	if opts.MaxBreadcrumbs <= 0 {
+		opts.MaxBreadcrumbs = DefaultSentryMaxBreadcrumbs
+	}
+	if opts.MaxTraces <= 0 {
+		opts.MaxTraces = DefaultSentryMaxTraces
+	}
+
+	return &SentryLogger{
+		stage:     stage,
+		opts:      opts,
+		transport: transport,
+		hooks:     newHooks(opts.Options),
+		traces:    map[string]*list.Element{},
+		order:     list.New(),
+	}, nil
+}
+
+// Info adds an informational message to the breadcrumbs when the options capture them.
+//
+// Parameters:
+//   - ctx: The context for the log entry.
+//   - message: The informational message to log.
+//   - args: Optional arguments to format the message.
+func (l *SentryLogger) Info(ctx context.Context, message string, args ...any) {
+	if l.opts.Next != nil {
+		l.opts.Next.Info(ctx, message, args...)
+	}
+	if l.opts.CaptureInfo {
+		l.addBreadcrumb(ctx, "info", fmt.Sprintf(message, args...), nil)
+	}
+}
+
+// Warning adds a warning message to the breadcrumbs.
+//
+// Parameters:
+//   - ctx: The context for the log entry.
+//   - message: The warning message to log.
+//   - args: Optional arguments to format the message.
+func (l *SentryLogger) Warning(ctx context.Context, message string, args ...any) {
+	if l.opts.Next != nil {
+		l.opts.Next.Warning(ctx, message, args...)
+	}
+	l.addBreadcrumb(ctx, "warning", fmt.Sprintf(message, args...), nil)
+}
+
+// Error sends an error message to Sentry as an event, and calls the error hooks of the logger.
+//
+// Parameters:
+//   - ctx: The context for the log entry.
+//   - message: The error message to log.
+//   - args: Optional arguments to format the message.
+func (l *SentryLogger) Error(ctx context.Context, message string, args ...any) {
+	if l.opts.Next != nil {
+		l.opts.Next.Error(ctx, message, args...)
+	}
+	messageWithArgs := fmt.Sprintf(message, args...)
+	l.capture(ctx, "error", messageWithArgs, nil)
+	l.hooks.fire(ctx, LevelError, messageWithArgs, nil)
+}
+
+// InfoFields adds an informational message with structured fields to the breadcrumbs when the
+// options capture them.
+//
+// Parameters:
+//   - ctx: The context for the log entry.
+//   - message: The informational message to log.
+//   - fields: The structured fields of the entry.
+func (l *SentryLogger) InfoFields(ctx context.Context, message string, fields Fields) {
+	if l.opts.Next != nil {
+		LogFields(l.opts.Next, ctx, LevelInfo, message, fields)
+	}
+	if l.opts.CaptureInfo {
+		l.addBreadcrumb(ctx, "info", message, fields)
+	}
+}
+
+// WarningFields adds a warning message with structured fields to the breadcrumbs.
+//
+// Parameters:
+//   - ctx: The context for the log entry.
+//   - message: The warning message to log.
+//   - fields: The structured fields of the entry.
+func (l *SentryLogger) WarningFields(ctx context.Context, message string, fields Fields) {
+	if l.opts.Next != nil {
+		LogFields(l.opts.Next, ctx, LevelWarning, message, fields)
+	}
+	l.addBreadcrumb(ctx, "warning", message, fields)
+}
+
+// ErrorFields sends an error message to Sentry as an event with the fields as extra context,
+// and calls the error hooks of the logger.
+//
+// Parameters:
+//   - ctx: The context for the log entry.
+//   - message: The error message to log.
+//   - fields: The structured fields of the entry.
+func (l *SentryLogger) ErrorFields(ctx context.Context, message string, fields Fields) {
+	if l.opts.Next != nil {
+		LogFields(l.opts.Next, ctx, LevelError, message, fields)
+	}
+	l.capture(ctx, "error", message, fields)
+	l.hooks.fire(ctx, LevelError, message, fields)
+}
+
+// Fatal sends a fatal message to Sentry as an event, calls the error hooks of the logger,
+// waits DefaultSentryFlushTimeout for the event to be sent, then hands the message to the
+// wrapped logger, which terminates the process, or terminates it with the exit function of the
+// logger.
+//
+// Parameters:
+//   - ctx: The context for the log entry.
+//   - message: The fatal message to log.
+//   - args: Optional arguments to format the message.
+//
+// Returns:
+//   - The message as an error, returned when the exit function returns, e.g. in tests.
+func (l *SentryLogger) Fatal(ctx context.Context, message string, args ...any) error {
+	messageWithArgs := fmt.Sprintf(message, args...)
+	l.capture(ctx, "fatal", messageWithArgs, nil)
+	l.hooks.fire(ctx, LevelFatal, messageWithArgs, nil)
+	l.Flush(DefaultSentryFlushTimeout)
+
+	if l.opts.Next != nil {
+		return Fatal(l.opts.Next, ctx, message, args...)
+	}
+	l.hooks.exitFunc()(1)
+	return fmt.Errorf("%s", messageWithArgs)
+}
+
+// Flush waits for the events to be sent, it is called when the application shuts down.
+//
+// Parameters:
+//   - timeout: The time to wait at most.
+//
+// Returns:
+//   - False if the timeout expired before every event was sent.
+func (l *SentryLogger) Flush(timeout time.Duration) bool {
+	return l.transport.Flush(timeout)
+}
+
+// Close waits for the events to be sent then stops the transport, the events logged after are
+// dropped. It is called once, when the application shuts down.
+//
+// Parameters:
+//   - timeout: The time to wait at most for the events.
+//
+// Returns:
+//   - False if the timeout expired before every event was sent, the others are dropped.
+func (l *SentryLogger) Close(timeout time.Duration) bool {
+	flushed := l.transport.Flush(timeout)
+	if c, ok := l.transport.(interface{ Close() }); ok {
+		c.Close()
+	}
+	return flushed
+}
+
+// capture sends an entry to Sentry with the breadcrumbs of its trace.
+func (l *SentryLogger) capture(ctx context.Context, level, message string, fields Fields) {
+	traceID := GetTraceID(ctx)
+
+	event := &SentryEvent{
+		EventID:     strings.ReplaceAll(uuid.NewString(), "-", ""),
+		Timestamp:   time.Now().UTC(),
+		Level:       level,
+		Platform:    "go",
+		Logger:      "wotop",
+		Environment: l.stage,
+		Release:     l.opts.Release,
+		Message:     message,
+		Tags:        map[string]string{"trace_id": traceID},
+		Breadcrumbs: l.takeBreadcrumbs(traceID),
+	}
+	if len(fields) > 0 {
+		event.Extra = fields
+	}
+
+	l.transport.SendEvent(event)
+}
+
+// addBreadcrumb keeps an entry for the next event of its trace. The oldest breadcrumb of the
+// trace is dropped when MaxBreadcrumbs are kept, and the least recently logged trace when
+// MaxTraces are kept, so the busy traces do not evict the breadcrumbs of the others.
+func (l *SentryLogger) addBreadcrumb(ctx context.Context, level, message string, fields Fields) {
+	traceID := GetTraceID(ctx)
+	b := SentryBreadcrumb{
+		Timestamp: time.Now().UTC(),
+		Level:     level,
+		Category:  "log",
+		Message:   message,
+	}
+	if len(fields) > 0 {
+		b.Data = fields
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.traces[traceID]
+	if ok {
+		l.order.MoveToBack(e)
+	} else {
+		if l.order.Len() >= l.opts.MaxTraces {
+			oldest := l.order.Remove(l.order.Front()).(*sentryTrace)
+			delete(l.traces, oldest.id)
+		}
+		e = l.order.PushBack(&sentryTrace{id: traceID})
+		l.traces[traceID] = e
+	}
+
+	trace := e.Value.(*sentryTrace)
+	if len(trace.breadcrumbs) >= l.opts.MaxBreadcrumbs {
+		trace.breadcrumbs = trace.breadcrumbs[1:]
+	}
+	trace.breadcrumbs = append(trace.breadcrumbs, b)
+}
+
+// takeBreadcrumbs removes the breadcrumbs of a trace and returns them, oldest first.
+func (l *SentryLogger) takeBreadcrumbs(traceID string) []SentryBreadcrumb {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.traces[traceID]
+	if !ok {
+		return nil
+	}
+	l.order.Remove(e)
+	delete(l.traces, traceID)
+	return e.Value.(*sentryTrace).breadcrumbs
+}
+
+// sentryHTTPTransport sends the events to the envelope endpoint of the Sentry server of a
+// DSN, from a goroutine draining a bounded queue. The events are dropped while the server rate
+// limits the client, as told by its 429 answers and X-Sentry-Rate-Limits headers.
+type sentryHTTPTransport struct {
+	client   *http.Client
+	dsn      string
+	endpoint string
+	auth     string
+	now      func() time.Time
+
+	queue   chan *SentryEvent
+	pending sync.WaitGroup
+	ctx     context.Context // cancelled by Close, aborting the request in flight
+	cancel  context.CancelFunc
+	stopped chan struct{} // closed when the goroutine returns
+
+	mu           sync.Mutex
+	closed       bool
+	limitedUntil time.Time
+}
+
+// newSentryHTTPTransport creates the transport of a DSN and starts its goroutine.
+//
+// Parameters:
+//   - dsn: The DSN, "<scheme>://<public key>@<host>[/<path>]/<project ID>".
+//
+// Returns:
+//   - The transport.
+//   - ErrInvalidSentryDSN if the DSN is malformed.
+func newSentryHTTPTransport(dsn string) (*sentryHTTPTransport, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, ErrInvalidSentryDSN.Var(err.Error())
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, ErrInvalidSentryDSN.Var("the scheme must be http or https")
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return nil, ErrInvalidSentryDSN.Var("the public key is missing")
+	}
+
+	path := strings.TrimSuffix(u.Path, "/")
+	i := strings.LastIndex(path, "/")
+	projectID := path[i+1:]
+	if projectID == "" {
+		return nil, ErrInvalidSentryDSN.Var("the project ID is missing")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &sentryHTTPTransport{
+		client:   &http.Client{Timeout: 10 * time.Second},
+		dsn:      dsn,
+		endpoint: fmt.Sprintf("%s://%s%s/api/%s/envelope/", u.Scheme, u.Host, path[:i], projectID),
+		auth:     fmt.Sprintf("Sentry sentry_version=7, sentry_client=wotop/1.0, sentry_key=%s", u.User.Username()),
+		now:      time.Now,
+		queue:    make(chan *SentryEvent, sentryQueueSize),
+		ctx:      ctx,
+		cancel:   cancel,
+		stopped:  make(chan struct{}),
+	}
+	go t.run()
+	return t, nil
+}
+
+// SendEvent queues an event, it is dropped when the queue is full, the server rate limits the
+// client or the transport is closed.
+func (t *sentryHTTPTransport) SendEvent(event *SentryEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return
+	}
+	if t.now().Before(t.limitedUntil) {
+		fmt.Fprintf(os.Stderr, "sentry: rate limited, event %s dropped\n", event.EventID)
+		return
+	}
+
+	t.pending.Add(1)
+	select {
+	case t.queue <- event:
+	default:
+		t.pending.Done()
+		fmt.Fprintf(os.Stderr, "sentry: queue full, event %s dropped\n", event.EventID)
+	}
+}
+
+// Flush waits for the queued events to be sent.
+func (t *sentryHTTPTransport) Flush(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		t.pending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Close stops the goroutine of the transport, aborting the request in flight and dropping the
+// queued events. It returns once the goroutine returned.
+func (t *sentryHTTPTransport) Close() {
+	t.mu.Lock()
+	if !t.closed {
+		t.closed = true
+		close(t.queue)
+	}
+	t.mu.Unlock()
+
+	t.cancel()
+	<-t.stopped
+}
+
+// run sends the queued events until the transport is closed.
+func (t *sentryHTTPTransport) run() {
+	defer close(t.stopped)
+
+	for event := range t.queue {
+		if t.ctx.Err() == nil && !t.rateLimited() {
+			if err := t.send(event); err != nil {
+				fmt.Fprintf(os.Stderr, "sentry: event %s not sent: %v\n", event.EventID, err)
+			}
+		}
+		t.pending.Done()
+	}
+}
+
+// rateLimited returns true while the server asks not to send the events.
+func (t *sentryHTTPTransport) rateLimited() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.now().Before(t.limitedUntil)
+}
+
+// send posts an event as an envelope.
+func (t *sentryHTTPTransport) send(event *SentryEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	header, _ := json.Marshal(map[string]string{
+		"event_id": event.EventID,
+		"dsn":      t.dsn,
+		"sent_at":  time.Now().UTC().Format(time.RFC3339),
+	})
+	item, _ := json.Marshal(map[string]any{"type": "event", "length": len(payload)})
+
+	var body bytes.Buffer
+	body.Write(header)
+	body.WriteByte('\n')
+	body.Write(item)
+	body.WriteByte('\n')
+	body.Write(payload)
+	body.WriteByte('\n')
+
+	req, err := http.NewRequestWithContext(t.ctx, http.MethodPost, t.endpoint, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", t.auth)
+
+	res, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if until := sentryRateLimit(res, t.now()); !until.IsZero() {
+		t.mu.Lock()
+		t.limitedUntil = until
+		t.mu.Unlock()
+	}
+
+	if res.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// sentryRateLimit returns until when the server asks not to send the events, from the limits
+// of the error category in the X-Sentry-Rate-Limits header, which take precedence, or from the
+// Retry-After header of a 429 answer. It returns the zero time when the client is not limited.
+func sentryRateLimit(res *http.Response, now time.Time) time.Time {
+	if limits := res.Header.Get("X-Sentry-Rate-Limits"); limits != "" {
+		var until time.Time
+
+		// each limit is "<seconds>:<categories separated by ;>:<scope>...", no category
+		// meaning all of them
+		for _, limit := range strings.Split(limits, ",") {
+			parts := strings.Split(strings.TrimSpace(limit), ":")
+			seconds, err := strconv.Atoi(parts[0])
+			if err != nil {
+				continue
+			}
+			if len(parts) > 1 && parts[1] != "" && !slices.Contains(strings.Split(parts[1], ";"), "error") {
+				continue
+			}
+			if u := now.Add(time.Duration(seconds) * time.Second); u.After(until) {
+				until = u
+			}
+		}
+		return until
+	}
+
+	if res.StatusCode != http.StatusTooManyRequests {
+		return time.Time{}
+	}
+
+	retryAfter := res.Header.Get("Retry-After")
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+		return now.Add(time.Duration(seconds) * time.Second)
+	}
+	if date, err := http.ParseTime(retryAfter); err == nil && date.After(now) {
+		return date
+	}
+	return now.Add(sentryDefaultRetryAfter)
+}
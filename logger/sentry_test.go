@@ -0,0 +1,323 @@
+package logger
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop/model/apperror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockSentryTransport is a SentryTransport recording its events.
+type mockSentryTransport struct {
+	events  []*SentryEvent
+	flushes int
+}
+
+func (t *mockSentryTransport) SendEvent(event *SentryEvent) {
+	t.events = append(t.events, event)
+}
+
+func (t *mockSentryTransport) Flush(time.Duration) bool {
+	t.flushes++
+	return true
+}
+
+func newTestSentryLogger(t *testing.T, opts SentryOptions) (*SentryLogger, *mockSentryTransport) {
+	t.Helper()
+
+	transport := &mockSentryTransport{}
+	opts.Transport = transport
+	l, err := NewSentryLogger("", "production", opts)
+	require.NoError(t, err)
+	return l, transport
+}
+
+func TestSentryLoggerErrorEvent(t *testing.T) {
+	l, transport := newTestSentryLogger(t, SentryOptions{Release: "v1.2.0"})
+	ctx := SetTraceID(context.Background(), "trace-1")
+
+	l.ErrorFields(ctx, "payment failed", Fields{"order": "o-1"})
+
+	require.Len(t, transport.events, 1)
+	event := transport.events[0]
+	assert.Len(t, event.EventID, 32)
+	assert.Equal(t, "error", event.Level)
+	assert.Equal(t, "payment failed", event.Message)
+	assert.Equal(t, "production", event.Environment)
+	assert.Equal(t, "v1.2.0", event.Release)
+	assert.Equal(t, map[string]string{"trace_id": "trace-1"}, event.Tags)
+	assert.Equal(t, map[string]any{"order": "o-1"}, event.Extra)
+	assert.Empty(t, event.Breadcrumbs)
+}
+
+func TestSentryLoggerBreadcrumbs(t *testing.T) {
+	l, transport := newTestSentryLogger(t, SentryOptions{})
+	ctx := SetTraceID(context.Background(), "trace-1")
+	other := SetTraceID(context.Background(), "trace-2")
+
+	l.Info(ctx, "order placed")
+	l.Warning(ctx, "stock %s low", "s-1")
+	l.WarningFields(other, "slow query", Fields{"ms": 900})
+	l.WarningFields(ctx, "retrying", Fields{"attempt": 2})
+	l.Error(ctx, "payment %s failed", "p-1")
+
+	require.Len(t, transport.events, 1)
+	event := transport.events[0]
+	assert.Equal(t, "payment p-1 failed", event.Message)
+	require.Len(t, event.Breadcrumbs, 2, "the info entries are dropped and the other traces are not attached")
+	assert.Equal(t, "stock s-1 low", event.Breadcrumbs[0].Message)
+	assert.Equal(t, "warning", event.Breadcrumbs[0].Level)
+	assert.Equal(t, map[string]any{"attempt": 2}, event.Breadcrumbs[1].Data)
+
+	l.Error(other, "query failed")
+	require.Len(t, transport.events, 2)
+	require.Len(t, transport.events[1].Breadcrumbs, 1)
+	assert.Equal(t, "slow query", transport.events[1].Breadcrumbs[0].Message)
+
+	l.Error(ctx, "payment failed again")
+	assert.Empty(t, transport.events[2].Breadcrumbs, "the breadcrumbs are attached once")
+}
+
+func TestSentryLoggerCaptureInfoAndMaxBreadcrumbs(t *testing.T) {
+	l, transport := newTestSentryLogger(t, SentryOptions{CaptureInfo: true, MaxBreadcrumbs: 2})
+	ctx := context.Background()
+
+	l.Info(ctx, "first")
+	l.InfoFields(ctx, "second", Fields{"n": 2})
+	l.Warning(ctx, "third")
+	l.Error(ctx, "failed")
+
+	require.Len(t, transport.events, 1)
+	var messages []string
+	for _, b := range transport.events[0].Breadcrumbs {
+		messages = append(messages, b.Message)
+	}
+	assert.Equal(t, []string{"second", "third"}, messages)
+	assert.Equal(t, "info", transport.events[0].Breadcrumbs[0].Level)
+}
+
+func TestSentryLoggerWrapsNext(t *testing.T) {
+	next := &recordingLogger{}
+	hook := &countingHook{}
+	var codes, nextCodes []int
+	defer func(f func(int)) { exit = f }(exit)
+	exit = func(code int) { nextCodes = append(nextCodes, code) }
+	l, transport := newTestSentryLogger(t, SentryOptions{Next: next, Options: []Option{
+		WithErrorHook(hook.hook),
+		WithExitFunc(func(code int) { codes = append(codes, code) }),
+	}})
+	ctx := context.Background()
+
+	l.InfoFields(ctx, "order placed", Fields{"order": "o-1"})
+	l.ErrorFields(ctx, "payment failed", Fields{"order": "o-1"})
+	err := l.Fatal(ctx, "database %s unreachable", "orders")
+
+	assert.Len(t, next.entries, 2)
+	assert.EqualError(t, err, "database orders unreachable")
+	assert.Empty(t, codes)
+	assert.Equal(t, []int{1}, nextCodes, "the wrapped logger exits")
+
+	require.Len(t, transport.events, 2)
+	assert.Equal(t, "fatal", transport.events[1].Level)
+	assert.Equal(t, 1, transport.flushes)
+	assert.Equal(t, []string{LevelError, LevelFatal}, hook.levels())
+}
+
+func TestSentryLoggerFatalExits(t *testing.T) {
+	var codes []int
+	l, transport := newTestSentryLogger(t, SentryOptions{Options: []Option{
+		WithExitFunc(func(code int) { codes = append(codes, code) }),
+	}})
+
+	err := Fatal(l, context.Background(), "disk full")
+
+	assert.EqualError(t, err, "disk full")
+	assert.Equal(t, []int{1}, codes)
+	require.Len(t, transport.events, 1)
+	assert.Equal(t, "fatal", transport.events[0].Level)
+}
+
+func TestNewSentryLoggerInvalidDSN(t *testing.T) {
+	for _, dsn := range []string{
+		"",
+		"ftp://key@sentry.io/1",
+		"https://sentry.io/1",
+		"https://key@sentry.io/",
+		"https://key@sentry.io/%zz",
+	} {
+		_, err := NewSentryLogger(dsn, "production", SentryOptions{})
+
+		var appErr apperror.ErrorType
+		require.ErrorAs(t, err, &appErr, dsn)
+		assert.Equal(t, ErrInvalidSentryDSN.Code(), appErr.Code(), dsn)
+	}
+}
+
+func TestSentryHTTPTransport(t *testing.T) {
+	var mu sync.Mutex
+	var paths, auths []string
+	var events []SentryEvent
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		paths = append(paths, r.URL.Path)
+		auths = append(auths, r.Header.Get("X-Sentry-Auth"))
+
+		// the envelope is a header, an item header and the event, one per line
+		scanner := bufio.NewScanner(r.Body)
+		var lines []string
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		if len(lines) == 3 {
+			var event SentryEvent
+			if json.Unmarshal([]byte(lines[2]), &event) == nil {
+				events = append(events, event)
+			}
+		}
+	}))
+	defer server.Close()
+
+	dsn := strings.Replace(server.URL, "://", "://public-key@", 1) + "/sentry/42"
+	l, err := NewSentryLogger(dsn, "staging", SentryOptions{})
+	require.NoError(t, err)
+
+	ctx := SetTraceID(context.Background(), "trace-1")
+	l.Warning(ctx, "stock low")
+	l.ErrorFields(ctx, "payment failed", Fields{"order": "o-1"})
+	require.True(t, l.Flush(time.Second))
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	assert.Equal(t, []string{"/sentry/api/42/envelope/"}, paths)
+	require.Len(t, auths, 1)
+	assert.Contains(t, auths[0], "sentry_key=public-key")
+	assert.Contains(t, auths[0], "sentry_version=7")
+
+	require.Len(t, events, 1)
+	assert.Equal(t, "payment failed", events[0].Message)
+	assert.Equal(t, "staging", events[0].Environment)
+	assert.Equal(t, "trace-1", events[0].Tags["trace_id"])
+	assert.Equal(t, map[string]any{"order": "o-1"}, events[0].Extra)
+	require.Len(t, events[0].Breadcrumbs, 1)
+	assert.Equal(t, "stock low", events[0].Breadcrumbs[0].Message)
+}
+
+func TestSentryLoggerBreadcrumbsPerTrace(t *testing.T) {
+	l, transport := newTestSentryLogger(t, SentryOptions{MaxBreadcrumbs: 2, MaxTraces: 2})
+	checkout := SetTraceID(context.Background(), "trace-1")
+	busy := SetTraceID(context.Background(), "trace-2")
+
+	l.Warning(checkout, "stock low")
+	for range 10 {
+		l.Warning(busy, "slow query")
+	}
+	l.Error(checkout, "payment failed")
+
+	require.Len(t, transport.events, 1)
+	require.Len(t, transport.events[0].Breadcrumbs, 1, "the busy trace does not evict the breadcrumbs of the others")
+	assert.Equal(t, "stock low", transport.events[0].Breadcrumbs[0].Message)
+
+	// beyond MaxTraces the least recently logged trace is dropped
+	l.Warning(checkout, "stock low again")
+	l.Warning(busy, "slow query")
+	l.Warning(SetTraceID(context.Background(), "trace-3"), "cache miss")
+	l.Error(checkout, "payment failed again")
+	l.Error(busy, "query failed")
+
+	require.Len(t, transport.events, 3)
+	assert.Empty(t, transport.events[1].Breadcrumbs)
+	assert.Len(t, transport.events[2].Breadcrumbs, 2)
+}
+
+func TestSentryHTTPTransportRateLimit(t *testing.T) {
+	var mu sync.Mutex
+	var requests int
+	var headers []http.Header
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		for k, v := range headers[requests] {
+			w.Header()[k] = v
+		}
+		requests++
+		if w.Header().Get("Retry-After") != "" {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	headers = []http.Header{
+		{"Retry-After": {"60"}},
+		{"X-Sentry-Rate-Limits": {"120:transaction:key, 30:error;default:organization"}},
+		{},
+	}
+
+	transport, err := newSentryHTTPTransport(strings.Replace(server.URL, "://", "://public-key@", 1) + "/42")
+	require.NoError(t, err)
+	defer transport.Close()
+	now := time.Now()
+	transport.now = func() time.Time { return now }
+
+	send := func() int {
+		transport.SendEvent(&SentryEvent{EventID: "e"})
+		require.True(t, transport.Flush(time.Second))
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+
+	assert.Equal(t, 1, send())
+	assert.Equal(t, 1, send(), "the events are dropped during the Retry-After of a 429")
+
+	now = now.Add(61 * time.Second)
+	assert.Equal(t, 2, send())
+	now = now.Add(29 * time.Second)
+	assert.Equal(t, 2, send(), "the events are dropped during the limit of the error category")
+
+	now = now.Add(2 * time.Second)
+	assert.Equal(t, 3, send(), "the limits of the other categories do not apply")
+}
+
+func TestSentryLoggerClose(t *testing.T) {
+	received := make(chan struct{}, 1)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	l, err := NewSentryLogger(strings.Replace(server.URL, "://", "://public-key@", 1)+"/42", "staging", SentryOptions{})
+	require.NoError(t, err)
+
+	l.Error(context.Background(), "payment failed")
+	<-received
+
+	done := make(chan bool)
+	go func() { done <- l.Close(10 * time.Millisecond) }()
+	select {
+	case flushed := <-done:
+		assert.False(t, flushed, "the event in flight is aborted")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not stop the transport")
+	}
+
+	l.Error(context.Background(), "dropped")
+	assert.True(t, l.Flush(time.Second), "the events are dropped once closed")
+}
@@ -0,0 +1,216 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"maps"
+	"sync"
+
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+// DefaultBatchConcurrency is the number of messages of a batch rendered at the same time,
+// unless changed with WithBatchConcurrency.
+const DefaultBatchConcurrency = 4
+
+// Envelope is a rendered message, ready to be delivered by a Transport.
+type Envelope struct {
+	From        string
+	To          string
+	Subject     string
+	HTMLBody    string
+	PlainBody   string
+	Attachments []string
+	Language    string // The Content-Language of the message, none when empty.
+}
+
+// Transport delivers messages over an open connection, e.g. to the SMTP server.
+type Transport interface {
+	Send(envelope Envelope) error
+	Close() error
+}
+
+// Recipient is a recipient of a batch, its DataMap is merged over the DataMap of the base
+// message to personalize the templates.
+type Recipient struct {
+	Address string
+	DataMap map[string]any
+}
+
+// SendResult is the outcome of the delivery to a recipient of a batch, Err is nil when the
+// message was sent.
+type SendResult struct {
+	Recipient Recipient
+	Err       error
+}
+
+type smtpTransport struct {
+	client *mail.SMTPClient
+}
+
+func (t smtpTransport) Send(envelope Envelope) error {
+	email := mail.NewMSG()
+	email.SetFrom(envelope.From).AddTo(envelope.To).SetSubject(envelope.Subject)
+	if envelope.Language != "" {
+		email.AddHeader("Content-Language", envelope.Language)
+	}
+
+	email.SetBody(mail.TextPlain, envelope.PlainBody)
+	email.AddAlternative(mail.TextHTML, envelope.HTMLBody)
+
+	for _, x := range envelope.Attachments {
+		email.AddAttachment(x)
+	}
+
+	return email.Send(t.client)
+}
+
+func (t smtpTransport) Close() error {
+	return t.client.Close()
+}
+
+// WithBatchConcurrency sets how many messages of a batch are rendered at the same time, the
+// messages are still sent one after the other over the connection of the batch.
+func (m *mailer) WithBatchConcurrency(n int) *mailer {
+	if n < 1 {
+		n = 1
+	}
+	m.concurrency = n
+	return m
+}
+
+// SendBatch sends the template to every recipient over a single SMTP connection. The
+// templates are parsed once, then rendered for each recipient with the DataMap of the
+// recipient merged over the one of base.
+//
+// The returned error reports a failure of the whole batch, e.g. a missing template or an
+// unreachable server, while the failure of a recipient is reported by its SendResult, in the
+// order of the recipients. Once ctx is done the remaining recipients are not sent and
+// report the error of the context, which is returned too.
+func (m *mailer) SendBatch(ctx context.Context, templateToRender, templateName string, base Message, recipients []Recipient) ([]SendResult, error) {
+	base = m.prepareMessage(base)
+
+	htmlTemplate, err := template.New("email-html").ParseFiles(fmt.Sprintf("%s.html.gohtml", templateToRender))
+	if err != nil {
+		return nil, err
+	}
+
+	plainTemplate, err := template.New("email-plain").ParseFiles(fmt.Sprintf("%s.plain.gohtml", templateToRender))
+	if err != nil {
+		return nil, err
+	}
+
+	subjectTemplate, err := template.New("inline-string").Parse(base.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	transport, err := m.connect(true)
+	if err != nil {
+		return nil, err
+	}
+	defer transport.Close()
+
+	b := batch{
+		mailer:    m,
+		transport: transport,
+		html:      htmlTemplate,
+		plain:     plainTemplate,
+		subject:   subjectTemplate,
+		name:      templateName,
+		base:      base,
+	}
+
+	results := make([]SendResult, len(recipients))
+	sem := make(chan struct{}, max(m.concurrency, 1))
+	var wg sync.WaitGroup
+
+	for i, recipient := range recipients {
+		results[i].Recipient = recipient
+
+		select {
+		case <-ctx.Done():
+			results[i].Err = ctx.Err()
+			continue
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			results[i].Err = b.send(ctx, recipient)
+		}()
+	}
+
+	wg.Wait()
+
+	return results, ctx.Err()
+}
+
+// batch renders and sends the messages of a SendBatch.
+type batch struct {
+	mailer    *mailer
+	transport Transport
+	mu        sync.Mutex // the connection sends one message at a time
+	html      *template.Template
+	plain     *template.Template
+	subject   *template.Template
+	name      string
+	base      Message
+}
+
+func (b *batch) send(ctx context.Context, recipient Recipient) error {
+	msg := b.base
+	msg.To = recipient.Address
+	msg.DataMap = maps.Clone(b.base.DataMap)
+	maps.Copy(msg.DataMap, recipient.DataMap)
+
+	subject, err := execute(b.subject, "inline-string", msg.DataMap)
+	if err != nil {
+		return err
+	}
+
+	htmlBody, err := execute(b.html, b.name, msg.DataMap)
+	if err != nil {
+		return err
+	}
+
+	htmlBody, err = b.mailer.inlineCSS(htmlBody)
+	if err != nil {
+		return err
+	}
+
+	plainBody, err := execute(b.plain, b.name, msg.DataMap)
+	if err != nil {
+		return err
+	}
+
+	if b.mailer.limiter != nil {
+		if err = b.mailer.limiter.Wait(ctx, msg.To); err != nil {
+			return err
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// the context may be done while waiting for the connection
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+
+	return b.transport.Send(b.mailer.envelope(subject, htmlBody, plainBody, msg))
+}
+
+func execute(t *template.Template, name string, data map[string]any) (string, error) {
+	var tpl bytes.Buffer
+	if err := t.ExecuteTemplate(&tpl, name, data); err != nil {
+		return "", err
+	}
+	return tpl.String(), nil
+}
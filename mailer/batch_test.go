@@ -0,0 +1,140 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingTransport records the envelopes it is given, failing for the addresses of fail.
+type recordingTransport struct {
+	mu        sync.Mutex
+	envelopes []Envelope
+	fail      map[string]error
+	onSend    func(envelope Envelope)
+	dials     []bool
+	closed    bool
+}
+
+func (t *recordingTransport) Send(envelope Envelope) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.onSend != nil {
+		t.onSend(envelope)
+	}
+	if err := t.fail[envelope.To]; err != nil {
+		return err
+	}
+	t.envelopes = append(t.envelopes, envelope)
+	return nil
+}
+
+func (t *recordingTransport) Close() error {
+	t.closed = true
+	return nil
+}
+
+func newBatchMailer(transport *recordingTransport) *mailer {
+	m := NewMail("example.com", "localhost", 1025, "", "", "none", "news@example.com", "Shop")
+	m.dial = func(keepAlive bool) (Transport, error) {
+		transport.dials = append(transport.dials, keepAlive)
+		return transport, nil
+	}
+	return m
+}
+
+func campaign() Message {
+	return Message{
+		Subject: "{{.name}}, meet {{.product}}",
+		DataMap: map[string]any{"name": "customer", "product": "Wotop Pro"},
+	}
+}
+
+func TestSendBatchPersonalizes(t *testing.T) {
+	transport := &recordingTransport{}
+	m := newBatchMailer(transport)
+
+	recipients := []Recipient{
+		{Address: "ann@example.org", DataMap: map[string]any{"name": "Ann"}},
+		{Address: "bob@example.org", DataMap: map[string]any{"name": "Bob", "product": "Wotop Team"}},
+		{Address: "cyd@example.org"},
+	}
+
+	results, err := m.SendBatch(context.Background(), "testdata/campaign", "body", campaign(), recipients)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	for i, r := range results {
+		assert.NoError(t, r.Err)
+		assert.Equal(t, recipients[i], r.Recipient)
+	}
+
+	assert.Equal(t, []bool{true}, transport.dials, "a single kept alive connection")
+	assert.True(t, transport.closed)
+
+	envelopes := transport.envelopes
+	sort.Slice(envelopes, func(i, j int) bool { return envelopes[i].To < envelopes[j].To })
+	require.Len(t, envelopes, 3)
+
+	assert.Equal(t, "Shop <news@example.com>", envelopes[0].From)
+	assert.Equal(t, "Ann, meet Wotop Pro", envelopes[0].Subject)
+	assert.Equal(t, "Hello Ann, Wotop Pro is now available.", envelopes[0].PlainBody)
+	assert.Contains(t, envelopes[0].HTMLBody, `<p style="color:#333">Hello Ann, Wotop Pro is now available.</p>`)
+
+	assert.Equal(t, "Bob, meet Wotop Team", envelopes[1].Subject)
+	assert.Equal(t, "Hello Bob, Wotop Team is now available.", envelopes[1].PlainBody)
+
+	assert.Equal(t, "Hello customer, Wotop Pro is now available.", envelopes[2].PlainBody)
+}
+
+func TestSendBatchReportsPartialFailures(t *testing.T) {
+	refused := errors.New("550 mailbox unavailable")
+	transport := &recordingTransport{fail: map[string]error{"bob@example.org": refused}}
+	m := newBatchMailer(transport).WithBatchConcurrency(2)
+
+	results, err := m.SendBatch(context.Background(), "testdata/campaign", "body", campaign(), []Recipient{
+		{Address: "ann@example.org"},
+		{Address: "bob@example.org"},
+		{Address: "cyd@example.org"},
+	})
+	require.NoError(t, err)
+
+	assert.NoError(t, results[0].Err)
+	assert.ErrorIs(t, results[1].Err, refused)
+	assert.NoError(t, results[2].Err)
+	assert.Len(t, transport.envelopes, 2)
+}
+
+func TestSendBatchStopsWhenTheContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	transport := &recordingTransport{onSend: func(Envelope) { cancel() }}
+	m := newBatchMailer(transport).WithBatchConcurrency(1)
+
+	results, err := m.SendBatch(ctx, "testdata/campaign", "body", campaign(), []Recipient{
+		{Address: "ann@example.org"},
+		{Address: "bob@example.org"},
+		{Address: "cyd@example.org"},
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	assert.NoError(t, results[0].Err)
+	assert.ErrorIs(t, results[1].Err, context.Canceled)
+	assert.ErrorIs(t, results[2].Err, context.Canceled)
+	assert.Len(t, transport.envelopes, 1)
+}
+
+func TestSendBatchMissingTemplate(t *testing.T) {
+	transport := &recordingTransport{}
+	m := newBatchMailer(transport)
+
+	_, err := m.SendBatch(context.Background(), "testdata/missing", "body", campaign(), []Recipient{{Address: "ann@example.org"}})
+	assert.Error(t, err)
+	assert.Empty(t, transport.dials, "no connection is opened")
+}
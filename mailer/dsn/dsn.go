@@ -0,0 +1,179 @@
+package dsn
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// DeliveryStatus is the content of an RFC 3464 delivery status notification.
+//
+// Fields:
+//   - ReportingMTA: The server which attempted the delivery.
+//   - ArrivalDate: When the mail arrived to the reporting server, the zero time when unknown.
+//   - Recipients: The status of each recipient.
+type DeliveryStatus struct {
+	ReportingMTA string
+	ArrivalDate  time.Time
+	Recipients   []RecipientStatus
+}
+
+// RecipientStatus is the delivery status of a recipient of a notification.
+//
+// Fields:
+//   - FinalRecipient: The address the delivery was attempted to.
+//   - OriginalRecipient: The address given by the sender, before any forwarding, when reported.
+//   - Action: "failed", "delayed", "delivered", "relayed" or "expanded".
+//   - Status: The enhanced status code, e.g. "5.1.1".
+//   - Diagnostic: The response of the remote server, e.g. "550 5.1.1 user unknown".
+//   - RemoteMTA: The server which gave the diagnostic, when reported.
+type RecipientStatus struct {
+	FinalRecipient    string
+	OriginalRecipient string
+	Action            string
+	Status            string
+	Diagnostic        string
+	RemoteMTA         string
+}
+
+// Permanent reports whether the failure is permanent, i.e. the status class is 5.
+func (r RecipientStatus) Permanent() bool {
+	return r.Action == "failed" || strings.HasPrefix(r.Status, "5.")
+}
+
+// ParseDSN parses a bounce message, a multipart/report mail whose report type is
+// delivery-status, as defined by RFC 3464.
+//
+// Parameters:
+//   - r: The raw mail, headers included.
+//
+// Returns:
+//   - The delivery status of the message/delivery-status part.
+//   - ErrMalformedReport when the mail is not a delivery status notification.
+func ParseDSN(r io.Reader) (DeliveryStatus, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return DeliveryStatus{}, ErrMalformedReport.Var(err.Error())
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		return DeliveryStatus{}, ErrMalformedReport.Var(err.Error())
+	}
+	if mediaType != "multipart/report" || !strings.EqualFold(params["report-type"], "delivery-status") {
+		return DeliveryStatus{}, ErrMalformedReport.Var("the content type is " + mediaType)
+	}
+	if params["boundary"] == "" {
+		return DeliveryStatus{}, ErrMalformedReport.Var("the boundary is missing")
+	}
+
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := parts.NextPart()
+		if errors.Is(err, io.EOF) {
+			return DeliveryStatus{}, ErrMalformedReport.Var("no message/delivery-status part")
+		}
+		if err != nil {
+			return DeliveryStatus{}, ErrMalformedReport.Var(err.Error())
+		}
+
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if partType == "message/delivery-status" || partType == "message/global-delivery-status" {
+			return parseDeliveryStatus(part)
+		}
+	}
+}
+
+// parseDeliveryStatus parses the fields of a message/delivery-status part: a block of
+// per-message fields followed by a block per recipient, separated by blank lines.
+func parseDeliveryStatus(r io.Reader) (DeliveryStatus, error) {
+	tp := textproto.NewReader(bufio.NewReader(r))
+
+	var blocks []textproto.MIMEHeader
+	for {
+		h, err := tp.ReadMIMEHeader()
+		if len(h) > 0 {
+			blocks = append(blocks, h)
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return DeliveryStatus{}, ErrMalformedReport.Var(err.Error())
+		}
+	}
+
+	if len(blocks) < 2 {
+		return DeliveryStatus{}, ErrMalformedReport.Var("no per-recipient fields")
+	}
+
+	var ds DeliveryStatus
+	ds.ReportingMTA = fieldValue(blocks[0].Get("Reporting-MTA"))
+	if date := blocks[0].Get("Arrival-Date"); date != "" {
+		ds.ArrivalDate, _ = mail.ParseDate(date)
+	}
+
+	for _, h := range blocks[1:] {
+		rs := RecipientStatus{
+			FinalRecipient:    fieldValue(h.Get("Final-Recipient")),
+			OriginalRecipient: fieldValue(h.Get("Original-Recipient")),
+			Action:            strings.ToLower(strings.TrimSpace(h.Get("Action"))),
+			Status:            strings.TrimSpace(h.Get("Status")),
+			Diagnostic:        fieldValue(h.Get("Diagnostic-Code")),
+			RemoteMTA:         fieldValue(h.Get("Remote-MTA")),
+		}
+		if rs.FinalRecipient == "" || rs.Action == "" || rs.Status == "" {
+			return DeliveryStatus{}, ErrMalformedReport.Var(fmt.Sprintf("recipient %d lacks Final-Recipient, Action or Status", len(ds.Recipients)+1))
+		}
+		ds.Recipients = append(ds.Recipients, rs)
+	}
+
+	return ds, nil
+}
+
+// fieldValue strips the type of a typed field, e.g. "rfc822; user@example.com".
+func fieldValue(v string) string {
+	if _, value, ok := strings.Cut(v, ";"); ok {
+		v = value
+	}
+	return strings.TrimSpace(v)
+}
+
+// Events normalizes the status of each recipient into a DeliveryEvent.
+//
+// Returns:
+//   - The events, one per recipient.
+func (ds DeliveryStatus) Events() []DeliveryEvent {
+	events := make([]DeliveryEvent, 0, len(ds.Recipients))
+	for _, r := range ds.Recipients {
+		e := DeliveryEvent{
+			Provider:   "dsn",
+			Recipient:  r.FinalRecipient,
+			Status:     r.Status,
+			Diagnostic: r.Diagnostic,
+			Timestamp:  ds.ArrivalDate,
+		}
+		if r.OriginalRecipient != "" {
+			e.Recipient = r.OriginalRecipient
+		}
+
+		switch r.Action {
+		case "delivered", "relayed", "expanded":
+			e.Type = EventDelivered
+		case "delayed":
+			e.Type = EventDeferred
+		default:
+			e.Type = EventBounced
+			e.Permanent = r.Permanent()
+		}
+		events = append(events, e)
+	}
+	return events
+}
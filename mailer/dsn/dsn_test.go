@@ -0,0 +1,83 @@
+package dsn
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop/model/apperror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// assertCode checks the apperror code of the error.
+func assertCode(t *testing.T, err error, want apperror.ErrorType) {
+	t.Helper()
+	var et apperror.ErrorType
+	require.ErrorAs(t, err, &et)
+	assert.Equal(t, want.Code(), et.Code(), err.Error())
+}
+
+func TestParseDSN(t *testing.T) {
+	f, err := os.Open("testdata/bounce.eml")
+	require.NoError(t, err)
+	defer f.Close()
+
+	ds, err := ParseDSN(f)
+	require.NoError(t, err)
+
+	assert.Equal(t, "relay.example.com", ds.ReportingMTA)
+	assert.Equal(t, time.Date(2026, 10, 13, 9, 14, 58, 0, time.UTC), ds.ArrivalDate.UTC())
+	require.Len(t, ds.Recipients, 2)
+
+	assert.Equal(t, RecipientStatus{
+		FinalRecipient:    "jane@customer.example.org",
+		OriginalRecipient: "Jane.Doe@customer.example.org",
+		Action:            "failed",
+		Status:            "5.1.1",
+		Diagnostic:        "550 5.1.1 <jane@customer.example.org>: Recipient address rejected: User unknown",
+		RemoteMTA:         "mx.customer.example.org",
+	}, ds.Recipients[0])
+	assert.True(t, ds.Recipients[0].Permanent())
+
+	assert.Equal(t, "delayed", ds.Recipients[1].Action)
+	assert.Equal(t, "4.4.1", ds.Recipients[1].Status)
+	assert.False(t, ds.Recipients[1].Permanent())
+
+	events := ds.Events()
+	require.Len(t, events, 2)
+	assert.Equal(t, DeliveryEvent{
+		Provider:   "dsn",
+		Type:       EventBounced,
+		Recipient:  "Jane.Doe@customer.example.org",
+		Permanent:  true,
+		Status:     "5.1.1",
+		Diagnostic: ds.Recipients[0].Diagnostic,
+		Timestamp:  ds.ArrivalDate,
+	}, events[0])
+	assert.Equal(t, EventDeferred, events[1].Type)
+	assert.Equal(t, "bob@slow.example.net", events[1].Recipient)
+}
+
+func TestParseDSNMalformed(t *testing.T) {
+	for name, fixture := range map[string]string{
+		"not a report":         "testdata/not_report.eml",
+		"missing status part":  "testdata/missing_status.eml",
+		"incomplete recipient": "testdata/incomplete_recipient.eml",
+	} {
+		t.Run(name, func(t *testing.T) {
+			f, err := os.Open(fixture)
+			require.NoError(t, err)
+			defer f.Close()
+
+			_, err = ParseDSN(f)
+			assertCode(t, err, ErrMalformedReport)
+		})
+	}
+
+	t.Run("not a mail", func(t *testing.T) {
+		_, err := ParseDSN(strings.NewReader("{\"event\": \"bounce\"}"))
+		assertCode(t, err, ErrMalformedReport)
+	})
+}
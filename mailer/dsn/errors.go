@@ -0,0 +1,24 @@
+package dsn
+
+import (
+	"net/http"
+
+	"github.com/a-aslani/wotop/model/apperror"
+)
+
+const (
+	ErrMalformedReport  apperror.ErrorType = "ER0501 malformed delivery report: %s"
+	ErrMalformedWebhook apperror.ErrorType = "ER0502 malformed %s webhook: %s"
+	ErrInvalidSignature apperror.ErrorType = "ER0503 invalid %s webhook signature"
+)
+
+func init() {
+	apperror.Register("mailer/dsn",
+		apperror.Entry{Err: ErrMalformedReport, Description: "The bounce message is not an RFC 3464 delivery status notification."},
+		apperror.Entry{Err: ErrMalformedWebhook, Description: "The payload of the mail provider webhook cannot be parsed."},
+		apperror.Entry{Err: ErrInvalidSignature, Description: "The signature of the mail provider webhook does not match its payload."},
+	)
+	apperror.MapCode(ErrMalformedReport.Code(), http.StatusBadRequest)
+	apperror.MapCode(ErrMalformedWebhook.Code(), http.StatusBadRequest)
+	apperror.MapCode(ErrInvalidSignature.Code(), http.StatusUnauthorized)
+}
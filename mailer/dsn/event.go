@@ -0,0 +1,45 @@
+// Package dsn parses the delivery status notifications of the mails sent with package mailer:
+// RFC 3464 bounce messages and the webhooks of mail providers, normalized into DeliveryEvent.
+package dsn
+
+import (
+	"context"
+	"time"
+)
+
+// EventType is the outcome of the delivery of a mail to a recipient.
+type EventType string
+
+const (
+	EventDelivered EventType = "delivered" // The mail is accepted by the server of the recipient.
+	EventBounced   EventType = "bounced"   // The mail cannot be delivered, see DeliveryEvent.Permanent.
+	EventDeferred  EventType = "deferred"  // The delivery failed and is retried.
+	EventDropped   EventType = "dropped"   // The provider did not send the mail, e.g. the recipient is suppressed.
+	EventComplaint EventType = "complaint" // The recipient reported the mail as spam.
+)
+
+// DeliveryEvent is a delivery status of a mail, whichever the format it was received in.
+//
+// Fields:
+//   - Provider: The format of the notification, "dsn", "sendgrid" or "ses".
+//   - Type: The outcome of the delivery.
+//   - Recipient: The address of the recipient.
+//   - Permanent: Whether the failure is permanent, the address must not be mailed again.
+//   - Status: The enhanced status code, e.g. "5.1.1", when known.
+//   - Diagnostic: The reason given by the remote server or the provider.
+//   - MessageID: The ID of the mail given by the provider.
+//   - Timestamp: When the event occurred, the zero time when unknown.
+type DeliveryEvent struct {
+	Provider   string    `json:"provider"`
+	Type       EventType `json:"type"`
+	Recipient  string    `json:"recipient"`
+	Permanent  bool      `json:"permanent"`
+	Status     string    `json:"status,omitempty"`
+	Diagnostic string    `json:"diagnostic,omitempty"`
+	MessageID  string    `json:"message_id,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// Callback handles the events of a webhook. An error makes the handler respond 500, so the
+// provider sends the webhook again.
+type Callback func(ctx context.Context, events []DeliveryEvent) error
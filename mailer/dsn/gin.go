@@ -0,0 +1,207 @@
+package dsn
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/a-aslani/wotop/logger"
+	"github.com/a-aslani/wotop/model/payload"
+	"github.com/gin-gonic/gin"
+)
+
+// maxWebhookSize bounds the body of the webhooks, a bounce message quotes the original mail.
+const maxWebhookSize = 10 << 20
+
+// RelaySignatureHeader is the header of the signature of the bounce messages posted by the
+// SMTP relay, the hex encoded HMAC-SHA256 of the body.
+const RelaySignatureHeader = "X-Webhook-Signature"
+
+// Provider verifies the signature of the webhooks of a mail provider and parses their events.
+//
+// Methods:
+//   - Name: The name of the provider, used in the errors and the logs.
+//   - Events: Verifies the webhook and returns its events.
+type Provider interface {
+	Name() string
+	Events(ctx context.Context, header http.Header, body []byte) ([]DeliveryEvent, error)
+}
+
+// sendGridProvider is the Provider returned by SendGrid.
+type sendGridProvider struct {
+	key *ecdsa.PublicKey
+}
+
+// SendGrid returns the Provider of the signed SendGrid event webhook.
+//
+// Parameters:
+//   - key: The verification key, see ParseSendGridPublicKey.
+//
+// Returns:
+//   - The Provider.
+func SendGrid(key *ecdsa.PublicKey) Provider {
+	return sendGridProvider{key: key}
+}
+
+func (p sendGridProvider) Name() string { return "sendgrid" }
+
+func (p sendGridProvider) Events(_ context.Context, header http.Header, body []byte) ([]DeliveryEvent, error) {
+	if err := VerifySendGridSignature(p.key, header, body); err != nil {
+		return nil, err
+	}
+	return ParseSendGrid(body)
+}
+
+// sesProvider is the Provider returned by SES.
+type sesProvider struct {
+	fetch  CertificateFetcher
+	client *http.Client
+}
+
+// SES returns the Provider of the SES notifications delivered by an SNS HTTPS subscription.
+// The subscription is confirmed when SNS sends its confirmation, by visiting its SubscribeURL.
+//
+// Parameters:
+//   - fetch: The fetcher of the signing certificates, NewSNSCertificateFetcher(client) when nil.
+//   - client: The HTTP client confirming the subscriptions, http.DefaultClient when nil.
+//
+// Returns:
+//   - The Provider.
+func SES(fetch CertificateFetcher, client *http.Client) Provider {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if fetch == nil {
+		fetch = NewSNSCertificateFetcher(client)
+	}
+	return sesProvider{fetch: fetch, client: client}
+}
+
+func (p sesProvider) Name() string { return "ses" }
+
+func (p sesProvider) Events(ctx context.Context, _ http.Header, body []byte) ([]DeliveryEvent, error) {
+	m, err := ParseSNSMessage(body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.VerifySignature(ctx, p.fetch); err != nil {
+		return nil, err
+	}
+
+	if m.Type == SNSSubscriptionConfirmation {
+		return nil, p.confirm(ctx, m.SubscribeURL)
+	}
+
+	return ParseSES(m)
+}
+
+// confirm visits the SubscribeURL of a subscription confirmation.
+func (p sesProvider) confirm(ctx context.Context, subscribeURL string) error {
+	if !validSNSURL(subscribeURL) {
+		return ErrMalformedWebhook.Var("ses", "the subscribe URL is not an SNS URL")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, subscribeURL, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("confirm SNS subscription: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("confirm SNS subscription: status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// relayProvider is the Provider returned by Relay.
+type relayProvider struct {
+	secret []byte
+}
+
+// Relay returns the Provider of the RFC 3464 bounce messages posted by the SMTP relay, signed
+// with RelaySignatureHeader.
+//
+// Parameters:
+//   - secret: The secret shared with the relay.
+//
+// Returns:
+//   - The Provider.
+func Relay(secret []byte) Provider {
+	return relayProvider{secret: secret}
+}
+
+func (p relayProvider) Name() string { return "dsn" }
+
+func (p relayProvider) Events(_ context.Context, header http.Header, body []byte) ([]DeliveryEvent, error) {
+	signature, err := hex.DecodeString(header.Get(RelaySignatureHeader))
+	if err != nil || len(signature) == 0 {
+		return nil, ErrInvalidSignature.Var("dsn")
+	}
+
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write(body)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, ErrInvalidSignature.Var("dsn")
+	}
+
+	ds, err := ParseDSN(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	return ds.Events(), nil
+}
+
+// GinHandler returns the handler of the webhook of a provider. It verifies the signature of
+// the webhook and passes its events to the callback, then responds 200. It responds 401 when
+// the signature does not match, 400 when the payload is malformed and 500 when the callback
+// fails, so the provider retries.
+//
+// Parameters:
+//   - p: The provider sending the webhook, see SendGrid, SES and Relay.
+//   - log: The logger of the failures.
+//   - callback: The handler of the events, it is not called when there are none.
+//
+// Returns:
+//   - The Gin handler.
+func GinHandler(p Provider, log logger.Logger, callback Callback) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		traceID := logger.GetTraceID(ctx)
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookSize))
+		if err != nil {
+			log.Error(ctx, "%s webhook: %s", p.Name(), err.Error())
+			payload.WriteError(c, ErrMalformedWebhook.Var(p.Name(), err.Error()), traceID)
+			return
+		}
+
+		events, err := p.Events(ctx, c.Request.Header, body)
+		if err != nil {
+			log.Error(ctx, "%s webhook: %s", p.Name(), err.Error())
+			payload.WriteError(c, err, traceID)
+			return
+		}
+
+		if len(events) > 0 {
+			if err := callback(ctx, events); err != nil {
+				log.Error(ctx, "%s webhook: %s", p.Name(), err.Error())
+				payload.WriteError(c, err, traceID)
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, payload.NewSuccessResponse(gin.H{"events": len(events)}, traceID))
+	}
+}
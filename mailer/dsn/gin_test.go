@@ -0,0 +1,135 @@
+package dsn
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// nopLogger discards the logs.
+type nopLogger struct{}
+
+func (nopLogger) Info(context.Context, string, ...any)    {}
+func (nopLogger) Error(context.Context, string, ...any)   {}
+func (nopLogger) Warning(context.Context, string, ...any) {}
+
+// serveWebhook posts the body to the handler of the provider and returns the recorded
+// response and the events passed to the callback.
+func serveWebhook(p Provider, header http.Header, body []byte, callbackErr error) (*httptest.ResponseRecorder, []DeliveryEvent) {
+	gin.SetMode(gin.TestMode)
+
+	var received []DeliveryEvent
+	r := gin.New()
+	r.POST("/webhooks/mail", GinHandler(p, nopLogger{}, func(_ context.Context, events []DeliveryEvent) error {
+		received = events
+		return callbackErr
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/mail", bytes.NewReader(body))
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec, received
+}
+
+func signRelay(secret, body []byte) http.Header {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return http.Header{RelaySignatureHeader: {hex.EncodeToString(mac.Sum(nil))}}
+}
+
+func TestGinHandlerRelay(t *testing.T) {
+	secret := []byte("relay-secret")
+	body, err := os.ReadFile("testdata/bounce.eml")
+	require.NoError(t, err)
+
+	rec, events := serveWebhook(Relay(secret), signRelay(secret, body), body, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	require.Len(t, events, 2)
+	assert.Equal(t, EventBounced, events[0].Type)
+
+	rec, events = serveWebhook(Relay(secret), signRelay([]byte("other"), body), body, nil)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), ErrInvalidSignature.Code())
+	assert.Nil(t, events)
+
+	malformed := []byte("Subject: hello\r\n\r\nhi")
+	rec, _ = serveWebhook(Relay(secret), signRelay(secret, malformed), malformed, nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), ErrMalformedReport.Code())
+}
+
+func TestGinHandlerSendGrid(t *testing.T) {
+	key, encoded := newSendGridKey(t)
+	pub, err := ParseSendGridPublicKey(encoded)
+	require.NoError(t, err)
+
+	body, err := os.ReadFile("testdata/sendgrid.json")
+	require.NoError(t, err)
+	header := signSendGrid(t, key, "1760346902", body)
+
+	rec, events := serveWebhook(SendGrid(pub), header, body, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, events, 6)
+
+	// the callback fails, SendGrid retries
+	rec, _ = serveWebhook(SendGrid(pub), header, body, errors.New("database is down"))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	rec, events = serveWebhook(SendGrid(pub), http.Header{}, body, nil)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Nil(t, events)
+}
+
+func TestGinHandlerSES(t *testing.T) {
+	signer := newSNSSigner(t)
+
+	rec, events := serveWebhook(SES(signer.fetch, nil), nil, signer.notification(t, "testdata/ses_bounce.json"), nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	require.Len(t, events, 1)
+	assert.True(t, events[0].Permanent)
+
+	rec, _ = serveWebhook(SES(signer.fetch, nil), nil, []byte(`{"Type": "Notification"`), nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestGinHandlerSESSubscriptionConfirmation(t *testing.T) {
+	signer := newSNSSigner(t)
+	subscribeURL := "https://sns.eu-west-1.amazonaws.com/?Action=ConfirmSubscription&Token=2336412f37"
+
+	var confirmed string
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		confirmed = r.URL.String()
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("<ConfirmSubscriptionResponse/>"))}, nil
+	})}
+
+	body := signer.sign(t, SNSMessage{
+		Type:         SNSSubscriptionConfirmation,
+		MessageID:    "165545c9-2a5c-472c-8df2-7ff2be2b3b1b",
+		Token:        "2336412f37",
+		TopicArn:     "arn:aws:sns:eu-west-1:123456789012:ses-notifications",
+		Message:      "You have chosen to subscribe to the topic.",
+		SubscribeURL: subscribeURL,
+		Timestamp:    "2026-10-13T09:00:00.000Z",
+	})
+
+	rec, events := serveWebhook(SES(signer.fetch, client), nil, body, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, events)
+	assert.Equal(t, subscribeURL, confirmed)
+}
@@ -0,0 +1,140 @@
+package dsn
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	// SendGridSignatureHeader is the header of the signature of a signed event webhook.
+	SendGridSignatureHeader = "X-Twilio-Email-Event-Webhook-Signature"
+
+	// SendGridTimestampHeader is the header of the timestamp covered by the signature.
+	SendGridTimestampHeader = "X-Twilio-Email-Event-Webhook-Timestamp"
+)
+
+// sendGridEvent is an event of the SendGrid event webhook.
+type sendGridEvent struct {
+	Email      string `json:"email"`
+	Timestamp  int64  `json:"timestamp"`
+	Event      string `json:"event"`
+	MessageID  string `json:"sg_message_id"`
+	Reason     string `json:"reason"`
+	Status     string `json:"status"`
+	Response   string `json:"response"`
+	BounceType string `json:"type"`
+}
+
+// ParseSendGrid parses the body of the SendGrid event webhook. The engagement events, such as
+// opens and clicks, are left out.
+//
+// Parameters:
+//   - body: The JSON array of events.
+//
+// Returns:
+//   - The delivery events.
+//   - ErrMalformedWebhook when the body is not an array of events.
+func ParseSendGrid(body []byte) ([]DeliveryEvent, error) {
+	var raw []sendGridEvent
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, ErrMalformedWebhook.Var("sendgrid", err.Error())
+	}
+
+	events := make([]DeliveryEvent, 0, len(raw))
+	for i, r := range raw {
+		if r.Email == "" || r.Event == "" {
+			return nil, ErrMalformedWebhook.Var("sendgrid", fmt.Sprintf("event %d lacks email or event", i))
+		}
+
+		e := DeliveryEvent{
+			Provider:   "sendgrid",
+			Recipient:  r.Email,
+			Status:     r.Status,
+			Diagnostic: r.Reason,
+			MessageID:  r.MessageID,
+		}
+		if r.Timestamp > 0 {
+			e.Timestamp = time.Unix(r.Timestamp, 0).UTC()
+		}
+
+		switch r.Event {
+		case "delivered":
+			e.Type = EventDelivered
+			e.Diagnostic = r.Response
+		case "deferred":
+			e.Type = EventDeferred
+			e.Diagnostic = r.Response
+		case "bounce":
+			e.Type = EventBounced
+			// blocked bounces are refusals of the server, e.g. for the reputation of the sender
+			e.Permanent = r.BounceType != "blocked"
+		case "dropped":
+			e.Type = EventDropped
+		case "spamreport":
+			e.Type = EventComplaint
+		default:
+			continue
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// ParseSendGridPublicKey parses the verification key of the signed event webhook, as shown by
+// the SendGrid settings: a base64 encoded ECDSA public key.
+//
+// Parameters:
+//   - key: The base64 encoded key.
+//
+// Returns:
+//   - The public key.
+//   - An error if the key is not a base64 encoded ECDSA public key.
+func ParseSendGridPublicKey(key string) (*ecdsa.PublicKey, error) {
+	der, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("decode sendgrid public key: %w", err)
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("parse sendgrid public key: %w", err)
+	}
+
+	ecdsaKey, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("sendgrid public key is a %T, not an ECDSA key", pub)
+	}
+	return ecdsaKey, nil
+}
+
+// VerifySendGridSignature verifies the signature of a SendGrid event webhook, which signs the
+// timestamp followed by the body.
+//
+// Parameters:
+//   - key: The verification key, see ParseSendGridPublicKey.
+//   - header: The headers of the webhook request.
+//   - body: The raw body of the request.
+//
+// Returns:
+//   - ErrInvalidSignature when the signature is missing or does not match.
+func VerifySendGridSignature(key *ecdsa.PublicKey, header http.Header, body []byte) error {
+	signature, err := base64.StdEncoding.DecodeString(header.Get(SendGridSignatureHeader))
+	if err != nil || len(signature) == 0 {
+		return ErrInvalidSignature.Var("sendgrid")
+	}
+
+	h := sha256.New()
+	h.Write([]byte(header.Get(SendGridTimestampHeader)))
+	h.Write(body)
+
+	if !ecdsa.VerifyASN1(key, h.Sum(nil), signature) {
+		return ErrInvalidSignature.Var("sendgrid")
+	}
+	return nil
+}
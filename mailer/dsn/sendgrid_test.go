@@ -0,0 +1,106 @@
+package dsn
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSendGrid(t *testing.T) {
+	body, err := os.ReadFile("testdata/sendgrid.json")
+	require.NoError(t, err)
+
+	events, err := ParseSendGrid(body)
+	require.NoError(t, err)
+
+	// the open is left out
+	require.Len(t, events, 6)
+
+	assert.Equal(t, DeliveryEvent{
+		Provider:   "sendgrid",
+		Type:       EventBounced,
+		Recipient:  "jane@customer.example.org",
+		Permanent:  true,
+		Status:     "5.1.1",
+		Diagnostic: "550 5.1.1 The email account that you tried to reach does not exist",
+		MessageID:  "14c5d75ce93.dfd.64b469.filter0001.16648.5515E0B88.0",
+		Timestamp:  time.Unix(1760346902, 0).UTC(),
+	}, events[0])
+
+	var types []EventType
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	assert.Equal(t, []EventType{EventBounced, EventBounced, EventDelivered, EventDeferred, EventDropped, EventComplaint}, types)
+	assert.False(t, events[1].Permanent, "blocked bounces are not permanent")
+	assert.Equal(t, "400 try again later", events[3].Diagnostic)
+}
+
+func TestParseSendGridMalformed(t *testing.T) {
+	for name, body := range map[string]string{
+		"not json":      `<xml/>`,
+		"not an array":  `{"email": "jane@example.org", "event": "bounce"}`,
+		"missing email": `[{"event": "bounce"}]`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseSendGrid([]byte(body))
+			assertCode(t, err, ErrMalformedWebhook)
+		})
+	}
+}
+
+// signSendGrid signs the body like SendGrid and returns the headers of the webhook.
+func signSendGrid(t *testing.T, key *ecdsa.PrivateKey, timestamp string, body []byte) http.Header {
+	t.Helper()
+	digest := sha256.Sum256(append([]byte(timestamp), body...))
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	require.NoError(t, err)
+
+	header := http.Header{}
+	header.Set(SendGridSignatureHeader, base64.StdEncoding.EncodeToString(signature))
+	header.Set(SendGridTimestampHeader, timestamp)
+	return header
+}
+
+// newSendGridKey generates a key pair and returns the public key the way SendGrid shows it.
+func newSendGridKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	return key, base64.StdEncoding.EncodeToString(der)
+}
+
+func TestVerifySendGridSignature(t *testing.T) {
+	key, encoded := newSendGridKey(t)
+	pub, err := ParseSendGridPublicKey(encoded)
+	require.NoError(t, err)
+
+	body := []byte(`[{"email":"jane@customer.example.org","event":"bounce"}]`)
+	header := signSendGrid(t, key, "1760346902", body)
+	assert.NoError(t, VerifySendGridSignature(pub, header, body))
+
+	// the body is tampered with
+	assertCode(t, VerifySendGridSignature(pub, header, []byte(`[]`)), ErrInvalidSignature)
+
+	// the timestamp is replayed with another value
+	replayed := header.Clone()
+	replayed.Set(SendGridTimestampHeader, "1760346999")
+	assertCode(t, VerifySendGridSignature(pub, replayed, body), ErrInvalidSignature)
+
+	assertCode(t, VerifySendGridSignature(pub, http.Header{}, body), ErrInvalidSignature)
+
+	_, err = ParseSendGridPublicKey("not base64")
+	assert.Error(t, err)
+}
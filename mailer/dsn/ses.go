@@ -0,0 +1,299 @@
+package dsn
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SNS message types.
+const (
+	SNSNotification             = "Notification"
+	SNSSubscriptionConfirmation = "SubscriptionConfirmation"
+	SNSUnsubscribeConfirmation  = "UnsubscribeConfirmation"
+)
+
+// SNSMessage is the envelope of an Amazon SNS HTTP(S) notification, SES publishes its
+// notifications in Message.
+type SNSMessage struct {
+	Type             string `json:"Type"`
+	MessageID        string `json:"MessageId"`
+	Token            string `json:"Token,omitempty"`
+	TopicArn         string `json:"TopicArn"`
+	Subject          string `json:"Subject,omitempty"`
+	Message          string `json:"Message"`
+	Timestamp        string `json:"Timestamp"`
+	SignatureVersion string `json:"SignatureVersion"`
+	Signature        string `json:"Signature"`
+	SigningCertURL   string `json:"SigningCertURL"`
+	SubscribeURL     string `json:"SubscribeURL,omitempty"`
+	UnsubscribeURL   string `json:"UnsubscribeURL,omitempty"`
+}
+
+// ParseSNSMessage parses the body of an SNS HTTP(S) notification.
+//
+// Parameters:
+//   - body: The JSON body of the request.
+//
+// Returns:
+//   - The SNS message, its signature is not verified.
+//   - ErrMalformedWebhook when the body is not an SNS message.
+func ParseSNSMessage(body []byte) (SNSMessage, error) {
+	var m SNSMessage
+	if err := json.Unmarshal(body, &m); err != nil {
+		return SNSMessage{}, ErrMalformedWebhook.Var("ses", err.Error())
+	}
+	if m.Type == "" || m.MessageID == "" || m.Signature == "" {
+		return SNSMessage{}, ErrMalformedWebhook.Var("ses", "not an SNS message")
+	}
+	return m, nil
+}
+
+// stringToSign builds the string SNS signs, the names and values of the fields of the
+// message type, in alphabetical order.
+func (m SNSMessage) stringToSign() string {
+	fields := [][2]string{{"Message", m.Message}, {"MessageId", m.MessageID}}
+	if m.Type == SNSNotification {
+		if m.Subject != "" {
+			fields = append(fields, [2]string{"Subject", m.Subject})
+		}
+	} else {
+		fields = append(fields, [2]string{"SubscribeURL", m.SubscribeURL})
+	}
+	fields = append(fields, [2]string{"Timestamp", m.Timestamp})
+	if m.Type != SNSNotification {
+		fields = append(fields, [2]string{"Token", m.Token})
+	}
+	fields = append(fields, [2]string{"TopicArn", m.TopicArn}, [2]string{"Type", m.Type})
+
+	var sb strings.Builder
+	for _, f := range fields {
+		sb.WriteString(f[0])
+		sb.WriteByte('\n')
+		sb.WriteString(f[1])
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// CertificateFetcher returns the certificate an SNS message is signed with.
+type CertificateFetcher func(ctx context.Context, certURL string) (*x509.Certificate, error)
+
+// snsHost matches the hosts of the SNS endpoints, the certificates and the subscription URLs
+// are only fetched from them.
+var snsHost = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+// validSNSURL reports whether the URL is an HTTPS URL of an SNS endpoint.
+func validSNSURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && u.Scheme == "https" && snsHost.MatchString(u.Host)
+}
+
+// NewSNSCertificateFetcher creates a CertificateFetcher downloading the certificates from
+// the SNS endpoints, it rejects the URLs of any other host. The certificates are cached.
+//
+// Parameters:
+//   - client: The HTTP client, http.DefaultClient when nil.
+//
+// Returns:
+//   - The CertificateFetcher.
+func NewSNSCertificateFetcher(client *http.Client) CertificateFetcher {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	var cache sync.Map
+
+	return func(ctx context.Context, certURL string) (*x509.Certificate, error) {
+		if cert, ok := cache.Load(certURL); ok {
+			return cert.(*x509.Certificate), nil
+		}
+
+		if !validSNSURL(certURL) || !strings.HasSuffix(certURL, ".pem") {
+			return nil, fmt.Errorf("the signing certificate URL %q is not an SNS URL", certURL)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, certURL, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("fetch signing certificate: %w", err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("fetch signing certificate: status %d", res.StatusCode)
+		}
+
+		data, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+		if err != nil {
+			return nil, fmt.Errorf("fetch signing certificate: %w", err)
+		}
+
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("the signing certificate is not PEM encoded")
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse signing certificate: %w", err)
+		}
+
+		cache.Store(certURL, cert)
+		return cert, nil
+	}
+}
+
+// VerifySignature verifies the signature of the message with the certificate of its
+// SigningCertURL. Both signature versions, SHA1 and SHA256 with RSA, are supported.
+//
+// Parameters:
+//   - ctx: The context of the request.
+//   - fetch: The fetcher of the certificate, see NewSNSCertificateFetcher.
+//
+// Returns:
+//   - ErrInvalidSignature when the signature does not match or the certificate cannot be fetched.
+func (m SNSMessage) VerifySignature(ctx context.Context, fetch CertificateFetcher) error {
+	var alg x509.SignatureAlgorithm
+	switch m.SignatureVersion {
+	case "1":
+		alg = x509.SHA1WithRSA
+	case "2":
+		alg = x509.SHA256WithRSA
+	default:
+		return ErrInvalidSignature.Var("ses")
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(m.Signature)
+	if err != nil {
+		return ErrInvalidSignature.Var("ses")
+	}
+
+	cert, err := fetch(ctx, m.SigningCertURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature.Var("ses"), err)
+	}
+
+	if err := cert.CheckSignature(alg, []byte(m.stringToSign()), signature); err != nil {
+		return ErrInvalidSignature.Var("ses")
+	}
+	return nil
+}
+
+// sesRecipient is a recipient of an SES bounce, complaint or delivery delay.
+type sesRecipient struct {
+	EmailAddress   string `json:"emailAddress"`
+	Status         string `json:"status"`
+	DiagnosticCode string `json:"diagnosticCode"`
+}
+
+// sesNotification is an SES notification, or an SES event for the event publishing.
+type sesNotification struct {
+	NotificationType string `json:"notificationType"`
+	EventType        string `json:"eventType"`
+	Mail             struct {
+		MessageID   string   `json:"messageId"`
+		Destination []string `json:"destination"`
+	} `json:"mail"`
+	Bounce *struct {
+		BounceType        string         `json:"bounceType"`
+		BouncedRecipients []sesRecipient `json:"bouncedRecipients"`
+		Timestamp         time.Time      `json:"timestamp"`
+	} `json:"bounce"`
+	Complaint *struct {
+		ComplainedRecipients []sesRecipient `json:"complainedRecipients"`
+		Timestamp            time.Time      `json:"timestamp"`
+	} `json:"complaint"`
+	Delivery *struct {
+		Recipients   []string  `json:"recipients"`
+		SMTPResponse string    `json:"smtpResponse"`
+		Timestamp    time.Time `json:"timestamp"`
+	} `json:"delivery"`
+	DeliveryDelay *struct {
+		DelayedRecipients []sesRecipient `json:"delayedRecipients"`
+		Timestamp         time.Time      `json:"timestamp"`
+	} `json:"deliveryDelay"`
+}
+
+// ParseSES parses the SES notification published in an SNS notification. The SES
+// notifications and the events of the event publishing are both supported.
+//
+// Parameters:
+//   - m: The SNS message, see ParseSNSMessage.
+//
+// Returns:
+//   - The delivery events, none for the other SNS message types and SES event types.
+//   - ErrMalformedWebhook when the message is not an SES notification.
+func ParseSES(m SNSMessage) ([]DeliveryEvent, error) {
+	if m.Type != SNSNotification {
+		return nil, nil
+	}
+
+	var n sesNotification
+	if err := json.Unmarshal([]byte(m.Message), &n); err != nil {
+		return nil, ErrMalformedWebhook.Var("ses", err.Error())
+	}
+
+	kind := n.NotificationType
+	if kind == "" {
+		kind = n.EventType
+	}
+
+	newEvent := func(t EventType, recipient string, ts time.Time) DeliveryEvent {
+		return DeliveryEvent{Provider: "ses", Type: t, Recipient: recipient, MessageID: n.Mail.MessageID, Timestamp: ts.UTC()}
+	}
+
+	var events []DeliveryEvent
+	switch {
+	case kind == "Bounce" && n.Bounce != nil:
+		for _, r := range n.Bounce.BouncedRecipients {
+			e := newEvent(EventBounced, r.EmailAddress, n.Bounce.Timestamp)
+			e.Permanent = n.Bounce.BounceType == "Permanent"
+			e.Status = r.Status
+			e.Diagnostic = fieldValue(r.DiagnosticCode)
+			events = append(events, e)
+		}
+	case kind == "Complaint" && n.Complaint != nil:
+		for _, r := range n.Complaint.ComplainedRecipients {
+			events = append(events, newEvent(EventComplaint, r.EmailAddress, n.Complaint.Timestamp))
+		}
+	case kind == "Delivery" && n.Delivery != nil:
+		for _, r := range n.Delivery.Recipients {
+			e := newEvent(EventDelivered, r, n.Delivery.Timestamp)
+			e.Diagnostic = n.Delivery.SMTPResponse
+			events = append(events, e)
+		}
+	case kind == "DeliveryDelay" && n.DeliveryDelay != nil:
+		for _, r := range n.DeliveryDelay.DelayedRecipients {
+			e := newEvent(EventDeferred, r.EmailAddress, n.DeliveryDelay.Timestamp)
+			e.Status = r.Status
+			e.Diagnostic = fieldValue(r.DiagnosticCode)
+			events = append(events, e)
+		}
+	case kind == "Bounce" || kind == "Complaint" || kind == "Delivery" || kind == "DeliveryDelay":
+		return nil, ErrMalformedWebhook.Var("ses", "the "+kind+" notification has no "+kind+" object")
+	case kind == "":
+		return nil, ErrMalformedWebhook.Var("ses", "not an SES notification")
+	}
+
+	for _, e := range events {
+		if e.Recipient == "" {
+			return nil, ErrMalformedWebhook.Var("ses", "a recipient has no address")
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,227 @@
+package dsn
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCertURL = "https://sns.eu-west-1.amazonaws.com/SimpleNotificationService-test.pem"
+
+// snsSigner signs SNS messages like SNS, with a self-signed certificate.
+type snsSigner struct {
+	key  *rsa.PrivateKey
+	cert *x509.Certificate
+}
+
+func newSNSSigner(t *testing.T) *snsSigner {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sns.amazonaws.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &snsSigner{key: key, cert: cert}
+}
+
+// fetch is the CertificateFetcher of the signer.
+func (s *snsSigner) fetch(_ context.Context, certURL string) (*x509.Certificate, error) {
+	if certURL != testCertURL {
+		return nil, errors.New("unknown certificate")
+	}
+	return s.cert, nil
+}
+
+// notification wraps the SES message of the fixture in a signed SNS notification.
+func (s *snsSigner) notification(t *testing.T, fixture string) []byte {
+	t.Helper()
+	message, err := os.ReadFile(fixture)
+	require.NoError(t, err)
+
+	return s.sign(t, SNSMessage{
+		Type:      SNSNotification,
+		MessageID: "22b80b92-fdea-4c2c-8f9d-bdfb0c7bf324",
+		TopicArn:  "arn:aws:sns:eu-west-1:123456789012:ses-notifications",
+		Message:   string(message),
+		Timestamp: "2026-10-13T09:15:03.000Z",
+	})
+}
+
+// sign signs the message with the version 2 of the signatures and marshals it.
+func (s *snsSigner) sign(t *testing.T, m SNSMessage) []byte {
+	t.Helper()
+	m.SignatureVersion = "2"
+	m.SigningCertURL = testCertURL
+
+	digest := sha256.Sum256([]byte(m.stringToSign()))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+	m.Signature = base64.StdEncoding.EncodeToString(signature)
+
+	body, err := json.Marshal(m)
+	require.NoError(t, err)
+	return body
+}
+
+func TestParseSES(t *testing.T) {
+	signer := newSNSSigner(t)
+
+	tests := map[string]struct {
+		fixture string
+		want    DeliveryEvent
+	}{
+		"bounce": {
+			fixture: "testdata/ses_bounce.json",
+			want: DeliveryEvent{
+				Provider:   "ses",
+				Type:       EventBounced,
+				Recipient:  "jane@customer.example.org",
+				Permanent:  true,
+				Status:     "5.1.1",
+				Diagnostic: "550 5.1.1 user unknown",
+				MessageID:  "0102017c2b4a8e4f-9a3b-4c1d-8e2f-1a2b3c4d5e6f-000000",
+				Timestamp:  time.Date(2026, 10, 13, 9, 15, 2, 237000000, time.UTC),
+			},
+		},
+		"complaint from the event publishing": {
+			fixture: "testdata/ses_complaint.json",
+			want: DeliveryEvent{
+				Provider:  "ses",
+				Type:      EventComplaint,
+				Recipient: "angry@example.com",
+				MessageID: "0102017c2b4a8e4f-complaint-000000",
+				Timestamp: time.Date(2026, 10, 13, 10, 0, 0, 0, time.UTC),
+			},
+		},
+		"delivery": {
+			fixture: "testdata/ses_delivery.json",
+			want: DeliveryEvent{
+				Provider:   "ses",
+				Type:       EventDelivered,
+				Recipient:  "ann@example.com",
+				Diagnostic: "250 2.6.0 Message received",
+				MessageID:  "0102017c2b4a8e4f-delivery-000000",
+				Timestamp:  time.Date(2026, 10, 13, 9, 15, 0, 100000000, time.UTC),
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			m, err := ParseSNSMessage(signer.notification(t, tt.fixture))
+			require.NoError(t, err)
+			require.NoError(t, m.VerifySignature(context.Background(), signer.fetch))
+
+			events, err := ParseSES(m)
+			require.NoError(t, err)
+			assert.Equal(t, []DeliveryEvent{tt.want}, events)
+		})
+	}
+}
+
+func TestParseSESMalformed(t *testing.T) {
+	signer := newSNSSigner(t)
+
+	_, err := ParseSNSMessage([]byte(`[1, 2]`))
+	assertCode(t, err, ErrMalformedWebhook)
+
+	_, err = ParseSNSMessage([]byte(`{"Type": "Notification"}`))
+	assertCode(t, err, ErrMalformedWebhook)
+
+	for name, message := range map[string]string{
+		"not json":          `bounce`,
+		"not ses":           `{"hello": "world"}`,
+		"bounce without it": `{"notificationType": "Bounce"}`,
+		"no address":        `{"notificationType": "Bounce", "bounce": {"bounceType": "Permanent", "bouncedRecipients": [{"status": "5.1.1"}]}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			m, err := ParseSNSMessage(signer.sign(t, SNSMessage{Type: SNSNotification, MessageID: "1", Message: message}))
+			require.NoError(t, err)
+
+			_, err = ParseSES(m)
+			assertCode(t, err, ErrMalformedWebhook)
+		})
+	}
+}
+
+func TestSNSMessageVerifySignature(t *testing.T) {
+	signer := newSNSSigner(t)
+	body := signer.notification(t, "testdata/ses_bounce.json")
+
+	m, err := ParseSNSMessage(body)
+	require.NoError(t, err)
+	require.NoError(t, m.VerifySignature(context.Background(), signer.fetch))
+
+	tampered := m
+	tampered.Message = strings.Replace(m.Message, "jane@", "mallory@", 1)
+	assertCode(t, tampered.VerifySignature(context.Background(), signer.fetch), ErrInvalidSignature)
+
+	// signed by another key
+	assertCode(t, m.VerifySignature(context.Background(), newSNSSigner(t).fetch), ErrInvalidSignature)
+
+	foreign := m
+	foreign.SigningCertURL = "https://attacker.example.com/cert.pem"
+	assertCode(t, foreign.VerifySignature(context.Background(), signer.fetch), ErrInvalidSignature)
+}
+
+// roundTripFunc is an http.RoundTripper answering with a function.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestSNSCertificateFetcher(t *testing.T) {
+	signer := newSNSSigner(t)
+	pemCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: signer.cert.Raw})
+
+	var fetched []string
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		fetched = append(fetched, r.URL.String())
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(string(pemCert)))}, nil
+	})}
+	fetch := NewSNSCertificateFetcher(client)
+
+	for range 2 {
+		cert, err := fetch(context.Background(), testCertURL)
+		require.NoError(t, err)
+		assert.Equal(t, signer.cert.Raw, cert.Raw)
+	}
+	assert.Equal(t, []string{testCertURL}, fetched, "the certificate is cached")
+
+	for _, certURL := range []string{
+		"http://sns.eu-west-1.amazonaws.com/cert.pem",
+		"https://sns.eu-west-1.amazonaws.com.attacker.example.com/cert.pem",
+		"https://s3.amazonaws.com/cert.pem",
+		"https://sns.eu-west-1.amazonaws.com/cert.txt",
+	} {
+		_, err := fetch(context.Background(), certURL)
+		assert.Error(t, err, certURL)
+	}
+	assert.Len(t, fetched, 1)
+}
@@ -0,0 +1,20 @@
+package mailer
+
+import (
+	"net/http"
+
+	"github.com/a-aslani/wotop/model/apperror"
+)
+
+const (
+	ErrRateLimited        apperror.ErrorType = "ER0504 the mail rate limit is reached, retry later"
+	ErrMissingTranslation apperror.ErrorType = "ER0505 the message %s has no translation for the locale %s"
+)
+
+func init() {
+	apperror.Register("mailer",
+		apperror.Entry{Err: ErrRateLimited, Description: "A non-blocking RateLimiter has no token left for the message, it is not sent."},
+		apperror.Entry{Err: ErrMissingTranslation, Description: "The catalog of the mailer has no message for a key of the template in the locale, and does not fall back to the default locale."},
+	)
+	apperror.MapCode(ErrRateLimited.Code(), http.StatusTooManyRequests)
+}
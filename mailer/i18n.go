@@ -0,0 +1,302 @@
+package mailer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// rtlLanguages are the languages written from right to left.
+var rtlLanguages = []string{"ar", "ckb", "dv", "fa", "he", "ku", "ps", "sd", "ug", "ur", "yi"}
+
+// htmlTag matches the opening html tag of a message.
+var htmlTag = regexp.MustCompile(`(?is)<html\b([^>]*)>`)
+
+// MissingTranslation is what a Catalog does with a key its locale has no message for.
+type MissingTranslation int
+
+const (
+	// FallbackOnMissing renders the message of the default locale of the catalog, else the key.
+	FallbackOnMissing MissingTranslation = iota
+	// ErrorOnMissing fails the rendering with ErrMissingTranslation, e.g. in the tests of the
+	// templates or in staging to catch the untranslated keys.
+	ErrorOnMissing
+)
+
+// Catalog holds the messages of the templates by locale, which they render with the t
+// function, e.g. {{t "welcome.title" .name}}.
+type Catalog struct {
+	defaultLocale string
+	onMissing     MissingTranslation
+	messages      map[string]map[string]string
+}
+
+// NewCatalog creates an empty catalog, see Add and LoadCatalog. The messages of defaultLocale,
+// e.g. "en", stand for the missing ones unless onMissing is ErrorOnMissing.
+func NewCatalog(defaultLocale string, onMissing MissingTranslation) *Catalog {
+	return &Catalog{
+		defaultLocale: normalizeLocale(defaultLocale),
+		onMissing:     onMissing,
+		messages:      map[string]map[string]string{},
+	}
+}
+
+// LoadCatalog creates a catalog from the <locale>.json files at the root of fsys, e.g. fs.Sub
+// of an embed.FS, each holding the messages of its locale by key, e.g.
+// {"welcome.title": "Hello %s"}.
+func LoadCatalog(fsys fs.FS, defaultLocale string, onMissing MissingTranslation) (*Catalog, error) {
+
+	files, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return nil, err
+	}
+
+	c := NewCatalog(defaultLocale, onMissing)
+	for _, file := range files {
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		var messages map[string]string
+		if err = json.Unmarshal(content, &messages); err != nil {
+			return nil, fmt.Errorf("mailer: cannot decode the catalog %s: %w", file, err)
+		}
+		c.Add(strings.TrimSuffix(file, ".json"), messages)
+	}
+
+	return c, nil
+}
+
+// Add adds the messages of a locale, e.g. "fa" or "fa-IR", replacing the ones with the same
+// keys. The messages are fmt formats when t is given arguments.
+func (c *Catalog) Add(locale string, messages map[string]string) {
+	locale = normalizeLocale(locale)
+	if c.messages[locale] == nil {
+		c.messages[locale] = make(map[string]string, len(messages))
+	}
+	for key, message := range messages {
+		c.messages[locale][key] = message
+	}
+}
+
+// Translate returns the message of a key in a locale, looked up in the locale, its language,
+// e.g. "fa" for "fa-IR", and then the default locale when the catalog falls back, in which
+// case a key no locale has is returned as it is. Otherwise the missing message is reported
+// with ErrMissingTranslation. The message is formatted with args when there are some.
+func (c *Catalog) Translate(locale, key string, args ...any) (string, error) {
+
+	candidates := localeCandidates(locale)
+	if c.onMissing == FallbackOnMissing {
+		candidates = append(candidates, localeCandidates(c.defaultLocale)...)
+	}
+
+	message, found := "", false
+	for _, candidate := range candidates {
+		if message, found = c.messages[candidate][key]; found {
+			break
+		}
+	}
+
+	if !found {
+		if c.onMissing == ErrorOnMissing {
+			return "", ErrMissingTranslation.Var(key, normalizeLocale(locale))
+		}
+		message = key
+	}
+
+	if len(args) > 0 {
+		message = fmt.Sprintf(message, args...)
+	}
+	return message, nil
+}
+
+// FuncMap returns the functions the templates of a locale are rendered with: t "key" args...
+// renders the message of the key, see Translate, lang the locale and dir its Direction, e.g.
+// for the attributes of an element. A nil catalog renders the keys.
+func (c *Catalog) FuncMap(locale string) template.FuncMap {
+	locale = normalizeLocale(locale)
+	return template.FuncMap{
+		"t": func(key string, args ...any) (string, error) {
+			if c == nil {
+				return key, nil
+			}
+			return c.Translate(locale, key, args...)
+		},
+		"lang": func() string { return locale },
+		"dir":  func() string { return Direction(locale) },
+	}
+}
+
+// IsRTL reports whether a locale is written from right to left, e.g. "fa" or "ar-EG".
+func IsRTL(locale string) bool {
+	language, _, _ := strings.Cut(normalizeLocale(locale), "-")
+	return slices.Contains(rtlLanguages, language)
+}
+
+// Direction returns the dir attribute of the messages of a locale, "rtl" or "ltr".
+func Direction(locale string) string {
+	if IsRTL(locale) {
+		return "rtl"
+	}
+	return "ltr"
+}
+
+// WithCatalog sets the catalog whose messages the templates of SendLocalized render with t.
+// Without catalog, t renders the keys.
+func (m *mailer) WithCatalog(catalog *Catalog) *mailer {
+	m.catalog = catalog
+	return m
+}
+
+// SendLocalized sends the templates of a locale, resolved by convention: for the locale
+// "fa-IR" and the base "emails/welcome", the first of emails/welcome.fa-IR.html.gohtml,
+// emails/welcome.fa.html.gohtml and emails/welcome.html.gohtml which exists, and likewise for
+// the plain text template. The templates, and the subject of the message, are rendered with
+// the functions of Catalog.FuncMap, and execute their "body" template when they define one.
+//
+// The html tag of the HTML message gets the lang of the locale and, for the right to left
+// locales, dir="rtl" unless it sets them, and the message is sent with the Content-Language
+// header. An empty locale sends the templates without locale. A message missing from a
+// catalog which does not fall back fails the rendering with ErrMissingTranslation.
+func (m *mailer) SendLocalized(ctx context.Context, templateBase, locale string, msg Message) error {
+	msg = m.prepareMessage(msg)
+	locale = normalizeLocale(locale)
+	funcs := m.catalog.FuncMap(locale)
+
+	htmlPath, err := resolveTemplate(templateBase, locale, "html.gohtml")
+	if err != nil {
+		return err
+	}
+	plainPath, err := resolveTemplate(templateBase, locale, "plain.gohtml")
+	if err != nil {
+		return err
+	}
+
+	subject, err := renderLocalized(template.New("inline-string").Funcs(funcs).Parse(msg.Subject))(msg.DataMap)
+	if err != nil {
+		return err
+	}
+
+	htmlBody, err := renderLocalized(template.New(filepath.Base(htmlPath)).Funcs(funcs).ParseFiles(htmlPath))(msg.DataMap)
+	if err != nil {
+		return err
+	}
+	htmlBody, err = m.inlineCSS(setLanguage(htmlBody, locale))
+	if err != nil {
+		return err
+	}
+
+	plainBody, err := renderLocalized(template.New(filepath.Base(plainPath)).Funcs(funcs).ParseFiles(plainPath))(msg.DataMap)
+	if err != nil {
+		return err
+	}
+
+	if m.limiter != nil {
+		if err = m.limiter.Wait(ctx, msg.To); err != nil {
+			return err
+		}
+	}
+
+	transport, err := m.connect(false)
+	if err != nil {
+		return err
+	}
+	defer transport.Close()
+
+	envelope := m.envelope(subject, htmlBody, plainBody, msg)
+	envelope.Language = locale
+
+	return transport.Send(envelope)
+}
+
+// renderLocalized returns the function rendering a parsed template, its "body" template when
+// it defines one.
+func renderLocalized(t *template.Template, err error) func(data map[string]any) (string, error) {
+	return func(data map[string]any) (string, error) {
+		if err != nil {
+			return "", err
+		}
+		name := t.Name()
+		if t.Lookup("body") != nil {
+			name = "body"
+		}
+		return execute(t, name, data)
+	}
+}
+
+// resolveTemplate returns the path of the template of a locale, see SendLocalized.
+func resolveTemplate(templateBase, locale, extension string) (string, error) {
+
+	candidates := make([]string, 0, 3)
+	for _, l := range localeCandidates(locale) {
+		candidates = append(candidates, fmt.Sprintf("%s.%s.%s", templateBase, l, extension))
+	}
+	candidates = append(candidates, fmt.Sprintf("%s.%s", templateBase, extension))
+
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+
+	return "", fmt.Errorf("mailer: no template %s for the locale %q, tried %s", extension, locale, strings.Join(candidates, ", "))
+}
+
+// setLanguage sets the lang of a locale and, for the right to left locales, dir="rtl" on the
+// html tag of a message, unless it sets them.
+func setLanguage(html, locale string) string {
+	if locale == "" {
+		return html
+	}
+
+	replaced := false
+	return htmlTag.ReplaceAllStringFunc(html, func(tag string) string {
+		if replaced {
+			return tag
+		}
+		replaced = true
+
+		attrs := strings.ToLower(htmlTag.FindStringSubmatch(tag)[1])
+		var added string
+		if !strings.Contains(attrs, "lang=") {
+			added += fmt.Sprintf(` lang="%s"`, locale)
+		}
+		if IsRTL(locale) && !strings.Contains(attrs, "dir=") {
+			added += ` dir="rtl"`
+		}
+		return tag[:len(tag)-1] + added + ">"
+	})
+}
+
+// normalizeLocale returns a locale as a BCP 47 tag, e.g. "fa-IR" for "fa_ir".
+func normalizeLocale(locale string) string {
+	language, region, ok := strings.Cut(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"), "-")
+	if !ok {
+		return strings.ToLower(language)
+	}
+	return strings.ToLower(language) + "-" + strings.ToUpper(region)
+}
+
+// localeCandidates returns a locale followed by its language, e.g. "fa-IR" and "fa", none for
+// the empty locale.
+func localeCandidates(locale string) []string {
+	locale = normalizeLocale(locale)
+	if locale == "" {
+		return nil
+	}
+	if language, _, ok := strings.Cut(locale, "-"); ok {
+		return []string{locale, language}
+	}
+	return []string{locale}
+}
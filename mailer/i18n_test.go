@@ -0,0 +1,106 @@
+package mailer
+
+import (
+	"context"
+	"html/template"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/a-aslani/wotop/model/apperror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCatalog(t *testing.T, onMissing MissingTranslation) *Catalog {
+	c, err := LoadCatalog(os.DirFS("testdata/i18n"), "en", onMissing)
+	require.NoError(t, err)
+	return c
+}
+
+func welcome() Message {
+	return Message{
+		To:      "sara@example.com",
+		Subject: `{{t "welcome.subject"}}`,
+		DataMap: map[string]any{"name": "Sara"},
+	}
+}
+
+func TestResolveTemplate(t *testing.T) {
+	for _, tc := range []struct {
+		locale, extension, want string
+	}{
+		{"fa-IR", "html.gohtml", "testdata/welcome.fa.html.gohtml"},
+		{"fa_ir", "html.gohtml", "testdata/welcome.fa.html.gohtml"},
+		{"fa", "plain.gohtml", "testdata/welcome.plain.gohtml"},
+		{"en-US", "html.gohtml", "testdata/welcome.html.gohtml"},
+		{"", "html.gohtml", "testdata/welcome.html.gohtml"},
+	} {
+		got, err := resolveTemplate("testdata/welcome", tc.locale, tc.extension)
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, got, tc.locale)
+	}
+
+	_, err := resolveTemplate("testdata/goodbye", "fa-IR", "html.gohtml")
+	assert.ErrorContains(t, err, "tried testdata/goodbye.fa-IR.html.gohtml, testdata/goodbye.fa.html.gohtml, testdata/goodbye.html.gohtml")
+}
+
+func TestSendLocalized(t *testing.T) {
+	transport := &recordingTransport{}
+	m := newBatchMailer(transport).WithCatalog(newCatalog(t, FallbackOnMissing))
+
+	require.NoError(t, m.SendLocalized(context.Background(), "testdata/welcome", "fa-IR", welcome()))
+	require.NoError(t, m.SendLocalized(context.Background(), "testdata/welcome", "en", welcome()))
+	require.Len(t, transport.envelopes, 2)
+
+	fa := transport.envelopes[0]
+	assert.Equal(t, "به فروشگاه خوش آمدید", fa.Subject)
+	assert.Equal(t, "fa-IR", fa.Language)
+	assert.Regexp(t, `<html lang="fa-IR" dir="rtl">`, fa.HTMLBody)
+	assert.Contains(t, fa.HTMLBody, `<p dir="rtl" style="color:#333">سلام Sara</p>`, "the fa template is resolved for fa-IR")
+	assert.Contains(t, fa.HTMLBody, "See you soon", "the message missing in fa falls back to en")
+	assert.Equal(t, "سلام Sara", fa.PlainBody, "the plain template falls back to the one without locale")
+
+	en := transport.envelopes[1]
+	assert.Equal(t, "Welcome to the shop", en.Subject)
+	assert.Equal(t, "en", en.Language)
+	assert.Regexp(t, `<html lang="en">`, en.HTMLBody)
+	assert.NotContains(t, en.HTMLBody, "rtl")
+	assert.Equal(t, "Hello Sara", en.PlainBody)
+}
+
+func TestSendLocalizedMissingTranslation(t *testing.T) {
+	transport := &recordingTransport{}
+	m := newBatchMailer(transport).WithCatalog(newCatalog(t, ErrorOnMissing))
+
+	err := m.SendLocalized(context.Background(), "testdata/welcome", "fa", welcome())
+	var et apperror.ErrorType
+	require.ErrorAs(t, err, &et)
+	assert.Equal(t, ErrMissingTranslation.Code(), et.Code())
+	assert.ErrorContains(t, err, "the message welcome.footer has no translation for the locale fa")
+	assert.Empty(t, transport.envelopes)
+
+	// without catalog the keys are rendered
+	m = newBatchMailer(transport)
+	require.NoError(t, m.SendLocalized(context.Background(), "testdata/welcome", "en", welcome()))
+	assert.Equal(t, "welcome.subject", transport.envelopes[0].Subject)
+}
+
+func TestCatalogFuncMap(t *testing.T) {
+	c := newCatalog(t, FallbackOnMissing)
+
+	render := func(locale, text string) string {
+		var out strings.Builder
+		require.NoError(t, template.Must(template.New("t").Funcs(c.FuncMap(locale)).Parse(text)).Execute(&out, nil))
+		return out.String()
+	}
+
+	assert.Equal(t, "سلام Ali", render("fa", `{{t "welcome.greeting" "Ali"}}`))
+	assert.Equal(t, "Hello Ali", render("de", `{{t "welcome.greeting" "Ali"}}`), "an unknown locale falls back to the default one")
+	assert.Equal(t, "welcome.unknown", render("fa", `{{t "welcome.unknown"}}`))
+	assert.Equal(t, "ar-EG rtl", render("ar_eg", `{{lang}} {{dir}}`))
+	assert.Equal(t, "en-GB ltr", render("en-gb", `{{lang}} {{dir}}`))
+
+	c.Add("fa-IR", map[string]string{"welcome.greeting": "درود %s"})
+	assert.Equal(t, "درود Ali", render("fa-IR", `{{t "welcome.greeting" "Ali"}}`), "the region comes before the language")
+}
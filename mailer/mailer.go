@@ -2,6 +2,7 @@ package mailer
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"html/template"
 	"time"
@@ -16,6 +17,8 @@ type Mailer interface {
 	ParseString(tplString string, data map[string]any) (string, error)
 	BuildHTMLMessageFromString(htmlContent string, msg Message) (string, error)
 	BuildPlainTextMessageFromString(plainContent string, msg Message) (string, error)
+	SendBatch(ctx context.Context, templateToRender, templateName string, base Message, recipients []Recipient) ([]SendResult, error)
+	SendLocalized(ctx context.Context, templateBase, locale string, msg Message) error
 }
 
 type mailer struct {
@@ -27,6 +30,10 @@ type mailer struct {
 	encryption  string
 	fromAddress string
 	fromName    string
+	concurrency int
+	limiter     *RateLimiter
+	catalog     *Catalog
+	dial        func(keepAlive bool) (Transport, error)
 }
 
 type Message struct {
@@ -51,6 +58,7 @@ func NewMail(domain string, host string, port int, username string, password str
 		encryption:  encryption,
 		fromAddress: fromAddress,
 		fromName:    fromName,
+		concurrency: DefaultBatchConcurrency,
 	}
 }
 
@@ -114,40 +122,58 @@ func (m *mailer) send(htmlBody, plainBody string, msg Message) error {
 		return err
 	}
 
+	if m.limiter != nil {
+		if err = m.limiter.Wait(context.Background(), msg.To); err != nil {
+			return err
+		}
+	}
+
+	transport, err := m.connect(false)
+	if err != nil {
+		return err
+	}
+	defer transport.Close()
+
+	return transport.Send(m.envelope(processedSubject, htmlBody, plainBody, msg))
+}
+
+func (m *mailer) connect(keepAlive bool) (Transport, error) {
+	if m.dial != nil {
+		return m.dial(keepAlive)
+	}
+
 	server := mail.NewSMTPClient()
 	server.Host = m.host
 	server.Port = m.port
 	server.Username = m.username
 	server.Password = m.password
 	server.Encryption = m.getEncryption(m.encryption)
-	server.KeepAlive = false
+	server.KeepAlive = keepAlive
 	server.ConnectTimeout = 10 * time.Second
 	server.SendTimeout = 10 * time.Second
 
 	smtpClient, err := server.Connect()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	email := mail.NewMSG()
+	return smtpTransport{client: smtpClient}, nil
+}
 
+func (m *mailer) envelope(subject, htmlBody, plainBody string, msg Message) Envelope {
 	fromAddress := msg.From
 	if msg.FromName != "" {
 		fromAddress = fmt.Sprintf("%s <%s>", msg.FromName, msg.From)
 	}
 
-	email.SetFrom(fromAddress).AddTo(msg.To).SetSubject(processedSubject)
-
-	email.SetBody(mail.TextPlain, plainBody)
-	email.AddAlternative(mail.TextHTML, htmlBody)
-
-	if len(msg.Attachments) > 0 {
-		for _, x := range msg.Attachments {
-			email.AddAttachment(x)
-		}
+	return Envelope{
+		From:        fromAddress,
+		To:          msg.To,
+		Subject:     subject,
+		HTMLBody:    htmlBody,
+		PlainBody:   plainBody,
+		Attachments: msg.Attachments,
 	}
-
-	return email.Send(smtpClient)
 }
 
 func (m *mailer) ParseString(tplString string, data map[string]any) (string, error) {
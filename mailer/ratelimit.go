@@ -0,0 +1,255 @@
+package mailer
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/a-aslani/wotop/util"
+)
+
+// maxIdleDomains is the number of recipient domains tracked before the buckets full again are
+// forgotten, a forgotten bucket is recreated full.
+const maxIdleDomains = 1024
+
+// Limit is the rate of a token bucket: Rate messages every Per, with bursts of up to Burst
+// messages, Rate when zero.
+type Limit struct {
+	Rate  int
+	Per   time.Duration
+	Burst int
+}
+
+// Clock is the time source of a RateLimiter, tests pace the messages with a fake one.
+type Clock interface {
+	util.Clock
+
+	// After sends the current time on the returned channel once d has elapsed.
+	After(d time.Duration) <-chan time.Time
+}
+
+// systemClock is the Clock of the system.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// RateLimitState is a snapshot of a RateLimiter, e.g. to export it as metrics.
+type RateLimitState struct {
+	Limit       Limit
+	DomainLimit *Limit
+	Available   float64            // The tokens left in the bucket of the provider.
+	Domains     map[string]float64 // The tokens left in the bucket of each recipient domain.
+	Waiting     int                // The messages waiting for a token.
+	Rejected    uint64             // The messages rejected with ErrRateLimited.
+}
+
+// RateLimiter paces the messages sent by a mailer with a token bucket for the provider and,
+// with WithDomainLimit, one more for each recipient domain. By default a message waits for
+// its tokens, with WithNonBlocking it is rejected with ErrRateLimited instead.
+type RateLimiter struct {
+	mu          sync.Mutex
+	clock       Clock
+	provider    *bucket
+	domainLimit *Limit
+	domains     map[string]*bucket
+	nonBlocking bool
+	waiting     int
+	rejected    uint64
+}
+
+// NewRateLimiter creates a RateLimiter allowing limit messages to the provider, e.g.
+// Limit{Rate: 10, Per: time.Second}.
+func NewRateLimiter(limit Limit) *RateLimiter {
+	return &RateLimiter{
+		clock:    systemClock{},
+		provider: newBucket(limit, time.Time{}),
+		domains:  map[string]*bucket{},
+	}
+}
+
+// WithDomainLimit limits the messages to each recipient domain too.
+func (l *RateLimiter) WithDomainLimit(limit Limit) *RateLimiter {
+	l.domainLimit = &limit
+	return l
+}
+
+// WithNonBlocking rejects the messages with ErrRateLimited instead of waiting for a token.
+func (l *RateLimiter) WithNonBlocking() *RateLimiter {
+	l.nonBlocking = true
+	return l
+}
+
+// WithClock sets the time source, the system clock by default.
+func (l *RateLimiter) WithClock(clock Clock) *RateLimiter {
+	l.clock = clock
+	return l
+}
+
+// Wait takes a token for a message to the recipient address, waiting until the buckets of
+// the provider and of the recipient domain have one. It returns the error of ctx when it is
+// done first, the tokens are then given back, or ErrRateLimited without waiting in
+// non-blocking mode.
+func (l *RateLimiter) Wait(ctx context.Context, recipient string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	now := l.clock.Now()
+	buckets := []*bucket{l.provider.at(now)}
+	if l.domainLimit != nil {
+		buckets = append(buckets, l.domain(domainOf(recipient), now))
+	}
+
+	if l.nonBlocking {
+		for _, b := range buckets {
+			if b.tokens < 1 {
+				l.rejected++
+				l.mu.Unlock()
+				return ErrRateLimited
+			}
+		}
+	}
+
+	var wait time.Duration
+	for _, b := range buckets {
+		wait = max(wait, b.take())
+	}
+	if wait <= 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	l.waiting++
+	l.mu.Unlock()
+
+	var err error
+	select {
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-l.clock.After(wait):
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.waiting--
+	if err != nil {
+		for _, b := range buckets {
+			b.giveBack()
+		}
+	}
+	return err
+}
+
+// State returns a snapshot of the buckets and of the messages waiting.
+func (l *RateLimiter) State() RateLimitState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	state := RateLimitState{
+		Limit:     l.provider.limit,
+		Available: max(l.provider.at(now).tokens, 0),
+		Domains:   make(map[string]float64, len(l.domains)),
+		Waiting:   l.waiting,
+		Rejected:  l.rejected,
+	}
+	if l.domainLimit != nil {
+		limit := *l.domainLimit
+		state.DomainLimit = &limit
+	}
+	for domain, b := range l.domains {
+		state.Domains[domain] = max(b.at(now).tokens, 0)
+	}
+	return state
+}
+
+// domain returns the bucket of a recipient domain, forgetting the full ones when there are
+// too many.
+func (l *RateLimiter) domain(domain string, now time.Time) *bucket {
+	if b, ok := l.domains[domain]; ok {
+		return b.at(now)
+	}
+
+	if len(l.domains) >= maxIdleDomains {
+		for d, b := range l.domains {
+			if b.at(now).full() {
+				delete(l.domains, d)
+			}
+		}
+	}
+
+	b := newBucket(*l.domainLimit, now)
+	l.domains[domain] = b
+	return b
+}
+
+// domainOf returns the lowercase domain of an address, e.g. "example.com" for
+// "Ali <ali@Example.com>".
+func domainOf(address string) string {
+	address = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(address), ">"))
+	if i := strings.LastIndex(address, "@"); i >= 0 {
+		address = address[i+1:]
+	}
+	return strings.ToLower(address)
+}
+
+// bucket is a token bucket, its tokens are negative while messages wait for them.
+type bucket struct {
+	limit  Limit
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(limit Limit, now time.Time) *bucket {
+	if limit.Rate < 1 {
+		limit.Rate = 1
+	}
+	if limit.Per <= 0 {
+		limit.Per = time.Second
+	}
+	if limit.Burst < 1 {
+		limit.Burst = limit.Rate
+	}
+	return &bucket{limit: limit, tokens: float64(limit.Burst), last: now}
+}
+
+// at refills the bucket with the tokens earned since it was last used.
+func (b *bucket) at(now time.Time) *bucket {
+	if b.last.IsZero() {
+		b.last = now
+	}
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = min(b.tokens+float64(elapsed)*float64(b.limit.Rate)/float64(b.limit.Per), float64(b.limit.Burst))
+		b.last = now
+	}
+	return b
+}
+
+// take takes a token and returns how long to wait until it is earned.
+func (b *bucket) take() time.Duration {
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(-b.tokens * float64(b.limit.Per) / float64(b.limit.Rate)))
+}
+
+// giveBack gives back a token taken by a message that was not sent.
+func (b *bucket) giveBack() {
+	b.tokens = min(b.tokens+1, float64(b.limit.Burst))
+}
+
+func (b *bucket) full() bool {
+	return b.tokens >= float64(b.limit.Burst)
+}
+
+// WithRateLimiter paces the messages sent by SendSMTPMessage, SendSMTPMessageFromString and
+// SendBatch with the limiter. A message of a batch waits until the context of the batch is
+// done, the others wait as long as needed.
+func (m *mailer) WithRateLimiter(limiter *RateLimiter) *mailer {
+	m.limiter = limiter
+	return m
+}
@@ -0,0 +1,161 @@
+package mailer
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a Clock whose time only moves with Advance.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the time forward, firing the waiters it reaches.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	waiting := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			waiting = append(waiting, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = waiting
+}
+
+// waitForWaiters waits until n messages wait for the clock.
+func (c *fakeClock) waitForWaiters(t *testing.T, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return len(c.waiters) == n
+	}, time.Second, time.Millisecond)
+}
+
+func TestRateLimiterPacesBatch(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	limiter := NewRateLimiter(Limit{Rate: 2, Per: time.Second}).WithClock(clock)
+	transport := &recordingTransport{}
+	m := newBatchMailer(transport).WithBatchConcurrency(1).WithRateLimiter(limiter)
+
+	recipients := []Recipient{{Address: "a@example.com"}, {Address: "b@example.com"}, {Address: "c@example.com"}, {Address: "d@example.com"}}
+	done := make(chan []SendResult)
+	go func() {
+		results, _ := m.SendBatch(context.Background(), "testdata/campaign", "body", campaign(), recipients)
+		done <- results
+	}()
+
+	sent := func() int {
+		transport.mu.Lock()
+		defer transport.mu.Unlock()
+		return len(transport.envelopes)
+	}
+
+	// the burst is sent at once, then a message every 500ms
+	clock.waitForWaiters(t, 1)
+	assert.Equal(t, 2, sent())
+
+	clock.Advance(499 * time.Millisecond)
+	assert.Equal(t, 2, sent())
+
+	clock.Advance(time.Millisecond)
+	require.Eventually(t, func() bool { return sent() == 3 }, time.Second, time.Millisecond)
+	clock.waitForWaiters(t, 1)
+
+	clock.Advance(500 * time.Millisecond)
+	for _, result := range <-done {
+		assert.NoError(t, result.Err)
+	}
+	assert.Equal(t, 4, sent())
+}
+
+func TestRateLimiterDomainLimit(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	limiter := NewRateLimiter(Limit{Rate: 100, Per: time.Second}).
+		WithDomainLimit(Limit{Rate: 1, Per: time.Minute}).
+		WithNonBlocking().
+		WithClock(clock)
+	ctx := context.Background()
+
+	require.NoError(t, limiter.Wait(ctx, "ali@gmail.com"))
+	assert.ErrorIs(t, limiter.Wait(ctx, "Sara <sara@GMAIL.com>"), ErrRateLimited)
+	assert.NoError(t, limiter.Wait(ctx, "reza@yahoo.com"), "the other domains have their own bucket")
+
+	state := limiter.State()
+	assert.Equal(t, uint64(1), state.Rejected)
+	assert.InDelta(t, 98, state.Available, 0.001, "the rejected message took no token")
+	assert.Equal(t, map[string]float64{"gmail.com": 0, "yahoo.com": 0}, state.Domains)
+
+	clock.Advance(time.Minute)
+	assert.NoError(t, limiter.Wait(ctx, "sara@gmail.com"))
+}
+
+func TestRateLimiterNonBlocking(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	limiter := NewRateLimiter(Limit{Rate: 1, Per: time.Second}).WithNonBlocking().WithClock(clock)
+	transport := &recordingTransport{}
+	m := newBatchMailer(transport).WithBatchConcurrency(1).WithRateLimiter(limiter)
+
+	results, err := m.SendBatch(context.Background(), "testdata/campaign", "body", campaign(), []Recipient{{Address: "a@example.com"}, {Address: "b@example.com"}})
+	require.NoError(t, err)
+	assert.NoError(t, results[0].Err)
+	assert.ErrorIs(t, results[1].Err, ErrRateLimited)
+	assert.Len(t, transport.envelopes, 1)
+}
+
+func TestRateLimiterWaitCanceled(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	limiter := NewRateLimiter(Limit{Rate: 1, Per: time.Second}).WithClock(clock)
+	require.NoError(t, limiter.Wait(context.Background(), "a@example.com"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error)
+	go func() { errs <- limiter.Wait(ctx, "b@example.com") }()
+
+	clock.waitForWaiters(t, 1)
+	assert.Equal(t, 1, limiter.State().Waiting)
+	cancel()
+	assert.ErrorIs(t, <-errs, context.Canceled)
+	assert.Equal(t, 0, limiter.State().Waiting)
+
+	// the canceled message gave its token back
+	clock.Advance(time.Second)
+	assert.InDelta(t, 1, limiter.State().Available, 0.001)
+}
+
+func TestDomainOf(t *testing.T) {
+	assert.Equal(t, "example.com", domainOf("Ali <ali@Example.com>"))
+	assert.Equal(t, "example.com", domainOf(" ali@example.com "))
+}
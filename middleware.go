@@ -0,0 +1,237 @@
+package wotop
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/a-aslani/wotop/postgres_db"
+	"runtime/debug"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Middleware decorates an Inport with a cross-cutting concern such as logging, metrics,
+// validation or transactions, leaving the interactor itself untouched.
+//
+// Type Parameters:
+//   - REQ: The type of the request object.
+//   - RES: The type of the response object.
+type Middleware[REQ, RES any] func(next Inport[REQ, RES]) Inport[REQ, RES]
+
+// Chain composes middlewares into one. The first middleware is the outermost, so it sees
+// the request first and the response last.
+//
+// Parameters:
+//   - middlewares: The middlewares to compose.
+//
+// Returns:
+//   - A Middleware applying all of them.
+func Chain[REQ, RES any](middlewares ...Middleware[REQ, RES]) Middleware[REQ, RES] {
+	return func(next Inport[REQ, RES]) Inport[REQ, RES] {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			next = middlewares[i](next)
+		}
+		return next
+	}
+}
+
+// Wrapper is implemented by decorated inports. AddUsecase unwraps them to register the use
+// case under the package of the interactor instead of the package of the middleware.
+type Wrapper interface {
+	// Unwrap returns the decorated inport.
+	Unwrap() any
+}
+
+// wrappedInport is the Inport returned by Wrap.
+type wrappedInport[REQ, RES any] struct {
+	next    Inport[REQ, RES]
+	execute func(ctx context.Context, req REQ) (*RES, error)
+}
+
+func (w *wrappedInport[REQ, RES]) Execute(ctx context.Context, req REQ) (*RES, error) {
+	return w.execute(ctx, req)
+}
+
+func (w *wrappedInport[REQ, RES]) Unwrap() any {
+	return w.next
+}
+
+// Wrap returns an Inport running execute in place of next, which execute is expected to call.
+// Middlewares should build their inport with Wrap, so AddUsecase can still find the interactor.
+//
+// Parameters:
+//   - next: The decorated inport.
+//   - execute: The decorated Execute.
+//
+// Returns:
+//   - An Inport implementing Wrapper.
+func Wrap[REQ, RES any](next Inport[REQ, RES], execute func(ctx context.Context, req REQ) (*RES, error)) Inport[REQ, RES] {
+	return &wrappedInport[REQ, RES]{next: next, execute: execute}
+}
+
+// unwrapInport returns the innermost inport of a chain of Wrapper.
+func unwrapInport(inport any) any {
+	for {
+		w, ok := inport.(Wrapper)
+		if !ok {
+			return inport
+		}
+		inport = w.Unwrap()
+	}
+}
+
+// Logger is the logger used by WithLogging. It is satisfied by logger.Logger, which cannot be
+// referenced here because package logger depends on this package.
+type Logger interface {
+	Info(ctx context.Context, message string, args ...any)
+	Error(ctx context.Context, message string, args ...any)
+}
+
+// WithLogging logs the duration of every execution, and the error when it fails.
+//
+// Parameters:
+//   - log: The logger, e.g. a logger.Logger.
+//
+// Returns:
+//   - A Middleware logging the executions.
+func WithLogging[REQ, RES any](log Logger) Middleware[REQ, RES] {
+	return func(next Inport[REQ, RES]) Inport[REQ, RES] {
+		name := fmt.Sprintf("%T", unwrapInport(next))
+		return Wrap(next, func(ctx context.Context, req REQ) (*RES, error) {
+			start := time.Now()
+			res, err := next.Execute(ctx, req)
+			if err != nil {
+				log.Error(ctx, "usecase %s failed after %s: %v", name, time.Since(start), err)
+				return res, err
+			}
+			log.Info(ctx, "usecase %s succeeded in %s", name, time.Since(start))
+			return res, nil
+		})
+	}
+}
+
+// WithMetrics counts the executions by status and observes their duration, in the
+// wotop_usecase_executions_total and wotop_usecase_duration_seconds metrics labelled
+// with the use case name. The metrics are shared by all the use cases of a registerer.
+//
+// Parameters:
+//   - reg: The registerer of the metrics, e.g. prometheus.DefaultRegisterer.
+//   - name: The name of the use case, used as the "usecase" label.
+//
+// Returns:
+//   - A Middleware recording the metrics.
+func WithMetrics[REQ, RES any](reg prometheus.Registerer, name string) Middleware[REQ, RES] {
+
+	executions := registerCollector(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "wotop_usecase_executions_total",
+		Help: "Number of use case executions by status.",
+	}, []string{"usecase", "status"}))
+
+	duration := registerCollector(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "wotop_usecase_duration_seconds",
+		Help:    "Duration of the use case executions.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"usecase"}))
+
+	return func(next Inport[REQ, RES]) Inport[REQ, RES] {
+		return Wrap(next, func(ctx context.Context, req REQ) (*RES, error) {
+			start := time.Now()
+			res, err := next.Execute(ctx, req)
+
+			status := "success"
+			if err != nil {
+				status = "error"
+			}
+			executions.WithLabelValues(name, status).Inc()
+			duration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+
+			return res, err
+		})
+	}
+}
+
+// registerCollector registers c, or returns the collector registered before with the same
+// descriptors, so several use cases can share the metrics.
+func registerCollector[C prometheus.Collector](reg prometheus.Registerer, c C) C {
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(C); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return c
+}
+
+// WithTimeout bounds every execution by a timeout. The interactor must honour the
+// cancellation of its context, which is passed to the outports.
+//
+// Parameters:
+//   - d: The maximum duration of an execution.
+//
+// Returns:
+//   - A Middleware setting the deadline of the context.
+func WithTimeout[REQ, RES any](d time.Duration) Middleware[REQ, RES] {
+	return func(next Inport[REQ, RES]) Inport[REQ, RES] {
+		return Wrap(next, func(ctx context.Context, req REQ) (*RES, error) {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			return next.Execute(ctx, req)
+		})
+	}
+}
+
+// PanicError is returned by the inports decorated with WithRecover when the execution panics.
+type PanicError struct {
+	Value any    // The value passed to panic.
+	Stack []byte // The stack trace of the panicking goroutine.
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("usecase panicked: %v\n%s", e.Value, e.Stack)
+}
+
+// WithRecover turns a panic of the execution into a *PanicError carrying the stack trace.
+//
+// Returns:
+//   - A Middleware recovering from panics.
+func WithRecover[REQ, RES any]() Middleware[REQ, RES] {
+	return func(next Inport[REQ, RES]) Inport[REQ, RES] {
+		return Wrap(next, func(ctx context.Context, req REQ) (res *RES, err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					res, err = nil, &PanicError{Value: r, Stack: debug.Stack()}
+				}
+			}()
+			return next.Execute(ctx, req)
+		})
+	}
+}
+
+// WithTransaction runs every execution in a database transaction, which the repositories find
+// in the context with postgres_db.TxFromContext or postgres_db.Conn. The transaction is
+// committed when Execute returns nil, and rolled back when it returns an error or panics.
+//
+// Parameters:
+//   - db: The connection pool the transactions are opened on.
+//
+// Returns:
+//   - A Middleware running the executions in transactions.
+func WithTransaction[REQ, RES any](db *sql.DB) Middleware[REQ, RES] {
+	return func(next Inport[REQ, RES]) Inport[REQ, RES] {
+		return Wrap(next, func(ctx context.Context, req REQ) (res *RES, err error) {
+			err = postgres_db.WithTx(ctx, db, func(ctx context.Context) error {
+				res, err = next.Execute(ctx, req)
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+			return res, nil
+		})
+	}
+}
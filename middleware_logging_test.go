@@ -0,0 +1,41 @@
+package wotop_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/logger"
+	"github.com/a-aslani/wotop/logger/loggertest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// greeter is an Inport failing on an empty name.
+type greeter struct{}
+
+func (greeter) Execute(_ context.Context, name string) (*string, error) {
+	if name == "" {
+		return nil, errors.New("boom")
+	}
+	greeting := "hello " + name
+	return &greeting, nil
+}
+
+func TestWithLogging(t *testing.T) {
+	log := &loggertest.Recorder{}
+	inport := wotop.WithLogging[string, string](log)(greeter{})
+
+	_, err := inport.Execute(context.Background(), "ali")
+	require.NoError(t, err)
+	_, err = inport.Execute(context.Background(), "")
+	assert.EqualError(t, err, "boom")
+
+	infos := log.Messages(logger.LevelInfo)
+	require.Len(t, infos, 1)
+	assert.Contains(t, infos[0], "usecase wotop_test.greeter succeeded")
+	errs := log.Messages(logger.LevelError)
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0], "boom")
+}
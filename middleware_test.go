@@ -0,0 +1,194 @@
+package wotop
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/a-aslani/wotop/postgres_db"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tracing returns a middleware recording when it sees the request and the response.
+func tracing(name string, trace *[]string) Middleware[echoRequest, echoResponse] {
+	return func(next Inport[echoRequest, echoResponse]) Inport[echoRequest, echoResponse] {
+		return Wrap(next, func(ctx context.Context, req echoRequest) (*echoResponse, error) {
+			*trace = append(*trace, name+" before")
+			res, err := next.Execute(ctx, req)
+			*trace = append(*trace, name+" after")
+			return res, err
+		})
+	}
+}
+
+// funcInport adapts a function to an Inport.
+type funcInport func(ctx context.Context, req echoRequest) (*echoResponse, error)
+
+func (f funcInport) Execute(ctx context.Context, req echoRequest) (*echoResponse, error) {
+	return f(ctx, req)
+}
+
+func TestChainOrder(t *testing.T) {
+	var trace []string
+
+	inport := Chain(tracing("a", &trace), tracing("b", &trace), tracing("c", &trace))(
+		funcInport(func(ctx context.Context, req echoRequest) (*echoResponse, error) {
+			trace = append(trace, "execute")
+			return &echoResponse{Message: req.Message}, nil
+		}),
+	)
+
+	res, err := inport.Execute(context.Background(), echoRequest{Message: "hi"})
+	require.NoError(t, err)
+	assert.Equal(t, "hi", res.Message)
+	assert.Equal(t, []string{"a before", "b before", "c before", "execute", "c after", "b after", "a after"}, trace)
+}
+
+func TestAddUsecaseUnwrapsMiddlewares(t *testing.T) {
+	ctrl := NewBaseController()
+	ctrl.AddUsecase(Chain(WithRecover[echoRequest, echoResponse](), WithTimeout[echoRequest, echoResponse](time.Second))(&echoInteractor{}))
+
+	inport, err := GetInport[echoRequest, echoResponse](ctrl.GetUsecase(echoRequest{}))
+	require.NoError(t, err)
+	assert.Implements(t, (*Wrapper)(nil), inport)
+	assert.IsType(t, &echoInteractor{}, unwrapInport(inport))
+}
+
+func TestWithMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	ok := WithMetrics[echoRequest, echoResponse](reg, "echo")(&echoInteractor{})
+	failing := WithMetrics[echoRequest, echoResponse](reg, "failing")(funcInport(func(context.Context, echoRequest) (*echoResponse, error) {
+		return nil, errors.New("boom")
+	}))
+
+	_, _ = ok.Execute(context.Background(), echoRequest{})
+	_, _ = ok.Execute(context.Background(), echoRequest{})
+	_, _ = failing.Execute(context.Background(), echoRequest{})
+
+	executions, err := reg.Gather()
+	require.NoError(t, err)
+	assert.Len(t, executions, 2)
+
+	counter := registerCollector(reg, prometheus.NewCounterVec(prometheus.CounterOpts{Name: "wotop_usecase_executions_total", Help: "Number of use case executions by status."}, []string{"usecase", "status"}))
+	assert.Equal(t, 2.0, testutil.ToFloat64(counter.WithLabelValues("echo", "success")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(counter.WithLabelValues("failing", "error")))
+}
+
+func TestWithTimeout(t *testing.T) {
+	inport := WithTimeout[echoRequest, echoResponse](10 * time.Millisecond)(funcInport(func(ctx context.Context, req echoRequest) (*echoResponse, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}))
+
+	_, err := inport.Execute(context.Background(), echoRequest{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestWithRecover(t *testing.T) {
+	inport := WithRecover[echoRequest, echoResponse]()(funcInport(func(context.Context, echoRequest) (*echoResponse, error) {
+		panic("boom")
+	}))
+
+	res, err := inport.Execute(context.Background(), echoRequest{})
+	assert.Nil(t, res)
+
+	var panicErr *PanicError
+	require.ErrorAs(t, err, &panicErr)
+	assert.Equal(t, "boom", panicErr.Value)
+	assert.Contains(t, string(panicErr.Stack), "TestWithRecover")
+}
+
+func TestRunTestcaseScenariosWithMiddlewares(t *testing.T) {
+	var trace []string
+
+	RunTestcaseScenarios(t,
+		func(prefix string) Inport[echoRequest, echoResponse] {
+			return Chain(WithRecover[echoRequest, echoResponse](), tracing("trace", &trace))(
+				funcInport(func(ctx context.Context, req echoRequest) (*echoResponse, error) {
+					return &echoResponse{Message: prefix + req.Message}, nil
+				}),
+			)
+		},
+		TestScenario[echoRequest, echoResponse, string]{
+			Name:           "decorated interactor",
+			InportRequest:  echoRequest{Message: "hi"},
+			InportResponse: &echoResponse{Message: "> hi"},
+			Outport:        "> ",
+		},
+	)
+}
+
+func TestWithTransaction(t *testing.T) {
+
+	// inserting runs a query on the transaction of the context, like the generated repositories
+	inserting := func(fail error, panics bool) Inport[echoRequest, echoResponse] {
+		return funcInport(func(ctx context.Context, req echoRequest) (*echoResponse, error) {
+			tx, ok := postgres_db.TxFromContext(ctx)
+			require.True(t, ok)
+			if _, err := tx.ExecContext(ctx, "INSERT INTO messages VALUES ($1)", req.Message); err != nil {
+				return nil, err
+			}
+			if panics {
+				panic("boom")
+			}
+			if fail != nil {
+				return nil, fail
+			}
+			return &echoResponse{Message: req.Message}, nil
+		})
+	}
+
+	t.Run("commit on success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO messages").WithArgs("hi").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		res, err := WithTransaction[echoRequest, echoResponse](db)(inserting(nil, false)).Execute(context.Background(), echoRequest{Message: "hi"})
+		require.NoError(t, err)
+		assert.Equal(t, "hi", res.Message)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("rollback on error", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO messages").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectRollback()
+
+		fail := errors.New("boom")
+		res, err := WithTransaction[echoRequest, echoResponse](db)(inserting(fail, false)).Execute(context.Background(), echoRequest{Message: "hi"})
+		assert.Nil(t, res)
+		assert.ErrorIs(t, err, fail)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("rollback on panic", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO messages").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectRollback()
+
+		inport := Chain(WithRecover[echoRequest, echoResponse](), WithTransaction[echoRequest, echoResponse](db))(inserting(nil, true))
+
+		_, err = inport.Execute(context.Background(), echoRequest{Message: "hi"})
+		var panicErr *PanicError
+		assert.ErrorAs(t, err, &panicErr)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,48 @@
+package apperror_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/a-aslani/wotop/health"
+	"github.com/a-aslani/wotop/idempotency"
+	"github.com/a-aslani/wotop/jwt"
+	"github.com/a-aslani/wotop/mailer/dsn"
+	"github.com/a-aslani/wotop/model/apperror"
+	"github.com/a-aslani/wotop/model/payload"
+	"github.com/a-aslani/wotop/upload_file"
+	"github.com/a-aslani/wotop/validator"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFrameworkCatalog checks the errors of the framework packages, importing them registers
+// their errors and panics on a collision.
+func TestFrameworkCatalog(t *testing.T) {
+	catalog := apperror.DefaultRegistry.Catalog()
+
+	codes := map[string]string{}
+	for _, e := range catalog {
+		codes[e.Code] = e.Package
+		assert.True(t, e.Code < "ER1000", "%s of %s is in the range of the applications", e.Code, e.Package)
+	}
+
+	for _, err := range []apperror.ErrorType{
+		validator.ErrIsRequired, validator.ErrMinLen, validator.ErrMaxLen,
+		jwt.ErrUnauthorized, jwt.ErrReadingJWTClaims,
+		upload_file.ErrMissingFile,
+		health.ErrNotReady,
+		payload.ErrInvalidOrder,
+		dsn.ErrInvalidSignature,
+		idempotency.ErrKeyReused,
+	} {
+		pkg, ok := codes[err.Code()]
+		assert.True(t, ok, "%s is not registered", err.Code())
+		assert.NotEmpty(t, pkg)
+	}
+
+	assert.NotEqual(t, validator.ErrIsRequired.Code(), validator.ErrMinLen.Code())
+
+	dump, err := apperror.DumpCatalog()
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(string(dump), `"code": "ER0008"`))
+}
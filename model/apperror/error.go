@@ -45,11 +45,58 @@ func (u ErrorType) Code() string {
 // UserNotFoundError ErrorType = "ER1092 User with name %s is not found"
 // Then you can insert the name
 // UserNotFoundError.Var("mirza") --> "User with name mirza is not found"
+// Use With for the errors translated in other locales, so their values are not parsed back
+// from the message
 func (u ErrorType) Var(params ...any) ErrorType {
 	return ErrorType(fmt.Sprintf(u.String(), params...))
 }
 
+// With return the error formatted with the values as Var does, keeping the values so the
+// translations format them as they are, see Translations.TranslateError, for example
+// UserNotFoundError.With("mirza") --> "User with name mirza is not found"
+func (u ErrorType) With(params ...any) *FormattedError {
+	return &FormattedError{Type: u, Args: params}
+}
+
 // String return the error as it is
 func (u ErrorType) String() string {
 	return string(u)
 }
+
+// FormattedError is an ErrorType formatted with its values by With. It is matched by errors.Is
+// with the ErrorType it is declared as, and errors.As set an ErrorType target to the formatted
+// one, the same as Var return
+type FormattedError struct {
+	Type ErrorType
+	Args []any
+}
+
+// Error return the formatted message
+func (e *FormattedError) Error() string {
+	return e.ErrorType().Error()
+}
+
+// Code return the code of the declared error
+func (e *FormattedError) Code() string {
+	return e.Type.Code()
+}
+
+// ErrorType return the error formatted with Var
+func (e *FormattedError) ErrorType() ErrorType {
+	return e.Type.Var(e.Args...)
+}
+
+// Is return true when the target is the declared error
+func (e *FormattedError) Is(target error) bool {
+	t, ok := target.(ErrorType)
+	return ok && t == e.Type
+}
+
+// As set an ErrorType target to the formatted error
+func (e *FormattedError) As(target any) bool {
+	if t, ok := target.(*ErrorType); ok {
+		*t = e.ErrorType()
+		return true
+	}
+	return false
+}
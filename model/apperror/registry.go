@@ -0,0 +1,100 @@
+package apperror
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Entry is an error registered in the catalog with its description
+type Entry struct {
+	Err         ErrorType
+	Description string
+}
+
+// CatalogEntry is a line of the error catalog
+type CatalogEntry struct {
+	Code        string `json:"code"`
+	Message     string `json:"message"`
+	Description string `json:"description,omitempty"`
+	Package     string `json:"package"`
+	Status      int    `json:"status,omitempty"`
+}
+
+// Registry keeps the error codes declared by the packages, so every code has a single meaning.
+// The framework packages use the codes ER0001 to ER0999, applications should use ER1000 and above
+type Registry struct {
+	mu      sync.RWMutex
+	entries map[string]CatalogEntry
+}
+
+// NewRegistry create an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{entries: map[string]CatalogEntry{}}
+}
+
+// DefaultRegistry is the catalog the packages register their errors in at init
+var DefaultRegistry = NewRegistry()
+
+// Register add the errors of the package to the registry. Nothing is registered when an error
+// has no code or its code is registered already, by this call or a previous one
+func (r *Registry) Register(pkg string, entries ...Entry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	added := map[string]CatalogEntry{}
+	for _, e := range entries {
+		code := e.Err.Code()
+		if code == "" {
+			return fmt.Errorf("apperror: %s: error %q has no code", pkg, e.Err.String())
+		}
+		existing, ok := r.entries[code]
+		if !ok {
+			existing, ok = added[code]
+		}
+		if ok {
+			return fmt.Errorf("apperror: %s: code %s of %q is already registered by %s for %q", pkg, code, e.Err.Error(), existing.Package, existing.Message)
+		}
+		added[code] = CatalogEntry{Code: code, Message: e.Err.Error(), Description: e.Description, Package: pkg}
+	}
+
+	for code, e := range added {
+		r.entries[code] = e
+	}
+	return nil
+}
+
+// Catalog return the registered errors sorted by code, with the HTTP status they are mapped to
+func (r *Registry) Catalog() []CatalogEntry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	catalog := make([]CatalogEntry, 0, len(r.entries))
+	for _, e := range r.entries {
+		if status, ok := HTTPStatus(ErrorType(e.Code + " " + e.Message)); ok {
+			e.Status = status
+		}
+		catalog = append(catalog, e)
+	}
+	sort.Slice(catalog, func(i, j int) bool { return catalog[i].Code < catalog[j].Code })
+	return catalog
+}
+
+// Dump return the catalog as indented JSON, for the API documentation
+func (r *Registry) Dump() ([]byte, error) {
+	return json.MarshalIndent(r.Catalog(), "", "  ")
+}
+
+// Register add the errors of the package to the DefaultRegistry, it is meant to be called at
+// init and panics on a code collision so it cannot go unnoticed
+func Register(pkg string, entries ...Entry) {
+	if err := DefaultRegistry.Register(pkg, entries...); err != nil {
+		panic(err)
+	}
+}
+
+// DumpCatalog return the errors of the DefaultRegistry as indented JSON
+func DumpCatalog() ([]byte, error) {
+	return DefaultRegistry.Dump()
+}
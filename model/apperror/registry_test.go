@@ -0,0 +1,67 @@
+package apperror
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	errUserNotFound  ErrorType = "ER1092 user with name %s is not found"
+	errTransition    ErrorType = "ER1043 transition from %s to %s is not allowed"
+	errSameCode      ErrorType = "ER1092 user is blocked"
+	errWithoutCode   ErrorType = "something went wrong"
+	errDuplicateCall ErrorType = "ER1044 first"
+	errDuplicateArg  ErrorType = "ER1044 second"
+)
+
+func TestRegistryCollision(t *testing.T) {
+	r := NewRegistry()
+	require.NoError(t, r.Register("user", Entry{Err: errUserNotFound}))
+
+	err := r.Register("account", Entry{Err: errTransition}, Entry{Err: errSameCode})
+	assert.EqualError(t, err, `apperror: account: code ER1092 of "user is blocked" is already registered by user for "user with name %s is not found"`)
+
+	err = r.Register("order", Entry{Err: errDuplicateCall}, Entry{Err: errDuplicateArg})
+	assert.EqualError(t, err, `apperror: order: code ER1044 of "second" is already registered by order for "first"`)
+
+	err = r.Register("order", Entry{Err: errWithoutCode})
+	assert.EqualError(t, err, `apperror: order: error "something went wrong" has no code`)
+
+	// nothing of a failing call is registered
+	assert.Len(t, r.Catalog(), 1)
+}
+
+func TestRegisterPanicsOnCollision(t *testing.T) {
+	previous := DefaultRegistry
+	DefaultRegistry = NewRegistry()
+	defer func() { DefaultRegistry = previous }()
+
+	Register("user", Entry{Err: errUserNotFound})
+
+	assert.Panics(t, func() { Register("account", Entry{Err: errSameCode}) })
+}
+
+func TestRegistryDump(t *testing.T) {
+	r := NewRegistry()
+	MapCode(errTransition.Code(), http.StatusConflict)
+	t.Cleanup(func() {
+		statuses.Lock()
+		defer statuses.Unlock()
+		delete(statuses.codes, errTransition.Code())
+	})
+
+	require.NoError(t, r.Register("user",
+		Entry{Err: errUserNotFound, Description: "No user has the name."},
+		Entry{Err: errTransition},
+	))
+
+	dump, err := r.Dump()
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"code":"ER1043","message":"transition from %s to %s is not allowed","package":"user","status":409},
+		{"code":"ER1092","message":"user with name %s is not found","description":"No user has the name.","package":"user"}
+	]`, string(dump))
+}
@@ -0,0 +1,92 @@
+package apperror
+
+import (
+	"errors"
+	"sync"
+)
+
+// StatusCoder is implemented by errors carrying the HTTP status they must be answered with
+type StatusCoder interface {
+	HTTPStatus() int
+}
+
+// StatusError is an ErrorType with the HTTP status it must be answered with.
+// errors.As still finds the ErrorType, so the code and the message are reported as usual
+type StatusError struct {
+	ErrorType
+	status int
+}
+
+// WithStatus attach the HTTP status to the error for example
+// return UserNotFoundError.Var("mirza").WithStatus(http.StatusNotFound)
+func (u ErrorType) WithStatus(status int) error {
+	return &StatusError{ErrorType: u, status: status}
+}
+
+// HTTPStatus return the attached HTTP status
+func (e *StatusError) HTTPStatus() int {
+	return e.status
+}
+
+// Unwrap return the ErrorType
+func (e *StatusError) Unwrap() error {
+	return e.ErrorType
+}
+
+// statuses keeps the HTTP status of the errors and codes mapped with MapError and MapCode
+var statuses = struct {
+	sync.RWMutex
+	errors map[ErrorType]int
+	codes  map[string]int
+}{
+	errors: map[ErrorType]int{},
+	codes:  map[string]int{},
+}
+
+// MapError map the error, as it is declared, to the HTTP status. The error must be returned
+// without Var or formatted with With, use MapCode or WithStatus for the errors formatted with Var
+func MapError(err ErrorType, status int) {
+	statuses.Lock()
+	defer statuses.Unlock()
+	statuses.errors[err] = status
+}
+
+// MapCode map every error with the code, for example MapCode("ER1092", http.StatusNotFound).
+// Codes are only unique inside a package, so prefer codes the application owns
+func MapCode(code string, status int) {
+	statuses.Lock()
+	defer statuses.Unlock()
+	statuses.codes[code] = status
+}
+
+// HTTPStatus return the HTTP status of the err, looking for a StatusCoder first, then for the
+// ErrorType mapped with MapError and then for its code mapped with MapCode.
+// The second value is false when the err has no status
+func HTTPStatus(err error) (int, bool) {
+	var sc StatusCoder
+	if errors.As(err, &sc) {
+		return sc.HTTPStatus(), true
+	}
+
+	var et ErrorType
+	if !errors.As(err, &et) {
+		return 0, false
+	}
+
+	statuses.RLock()
+	defer statuses.RUnlock()
+
+	if status, ok := statuses.errors[et]; ok {
+		return status, true
+	}
+	var formatted *FormattedError
+	if errors.As(err, &formatted) {
+		if status, ok := statuses.errors[formatted.Type]; ok {
+			return status, true
+		}
+	}
+	if status, ok := statuses.codes[et.Code()]; ok && et.Code() != "" {
+		return status, true
+	}
+	return 0, false
+}
@@ -0,0 +1,57 @@
+package apperror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	errProductNotFound ErrorType = "ER9404 product %s is not found"
+	errOutOfStock      ErrorType = "ER9409 product is out of stock"
+	errUnmapped        ErrorType = "ER9500 something went wrong"
+)
+
+func init() {
+	MapCode("ER9404", http.StatusNotFound)
+	MapError(errOutOfStock, http.StatusConflict)
+}
+
+func TestHTTPStatus(t *testing.T) {
+	tests := map[string]struct {
+		err    error
+		status int
+		ok     bool
+	}{
+		"mapped code":           {err: errProductNotFound.Var("p-1"), status: http.StatusNotFound, ok: true},
+		"mapped error":          {err: errOutOfStock, status: http.StatusConflict, ok: true},
+		"attached status":       {err: errUnmapped.WithStatus(http.StatusTeapot), status: http.StatusTeapot, ok: true},
+		"attached status first": {err: errOutOfStock.WithStatus(http.StatusGone), status: http.StatusGone, ok: true},
+		"wrapped":               {err: fmt.Errorf("save: %w", errProductNotFound.Var("p-1")), status: http.StatusNotFound, ok: true},
+		"wrapped attached":      {err: fmt.Errorf("save: %w", errUnmapped.WithStatus(http.StatusGone)), status: http.StatusGone, ok: true},
+		"mapped error with":     {err: fmt.Errorf("save: %w", errOutOfStock.With()), status: http.StatusConflict, ok: true},
+		"unmapped":              {err: errUnmapped},
+		"not an ErrorType":      {err: errors.New("boom")},
+		"no code":               {err: ErrorType("no code")},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			status, ok := HTTPStatus(tt.err)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.status, status)
+		})
+	}
+}
+
+func TestWithStatusKeepsErrorType(t *testing.T) {
+	err := errProductNotFound.Var("p-1").WithStatus(http.StatusNotFound)
+
+	var et ErrorType
+	assert.True(t, errors.As(err, &et))
+	assert.Equal(t, "ER9404", et.Code())
+	assert.Equal(t, "product p-1 is not found", err.Error())
+}
@@ -0,0 +1,298 @@
+package apperror
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// verbPattern matches the printf verbs of a message, with their optional argument index
+var verbPattern = regexp.MustCompile(`%(?:\[(\d+)\])?[-+# 0]*\d*(?:\.\d+)?([a-zA-Z%])`)
+
+// Translations keeps the messages of the errors in other locales than the English one they are
+// declared with, for example
+//
+//	AddTranslations("fa", map[ErrorType]string{
+//		ErrMaxLen: "طول %s باید حداکثر %d کاراکتر باشد. شما %d کاراکتر وارد کردید",
+//	})
+//
+// A translation uses the printf verbs of the declared message, the explicit argument indexes
+// such as %[2]d reorder them for the languages which need it
+type Translations struct {
+	mu       sync.RWMutex
+	patterns map[string]*argPattern
+	messages map[string]map[string]string
+}
+
+// NewTranslations create an empty Translations
+func NewTranslations() *Translations {
+	return &Translations{patterns: map[string]*argPattern{}, messages: map[string]map[string]string{}}
+}
+
+// DefaultTranslations is the registry the applications add their translations in at init
+var DefaultTranslations = NewTranslations()
+
+// Add add the translations of the errors in the locale, for example "fa" or "fa-IR", replacing
+// the ones of the same codes. Nothing is added when an error has no code or a translation does
+// not use the verbs of the declared message
+func (t *Translations) Add(locale string, messages map[ErrorType]string) error {
+	locale = normalizeLocale(locale)
+	if locale == "" {
+		return fmt.Errorf("apperror: the locale of the translations is empty")
+	}
+
+	patterns := make(map[string]*argPattern, len(messages))
+	for err, message := range messages {
+		if err.Code() == "" {
+			return fmt.Errorf("apperror: %s: error %q has no code", locale, err.String())
+		}
+		if e := checkVerbs(err.Error(), message); e != nil {
+			return fmt.Errorf("apperror: %s: translation of %s: %w", locale, err.Code(), e)
+		}
+		patterns[err.Code()] = compileArgPattern(err.Error())
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.messages[locale] == nil {
+		t.messages[locale] = make(map[string]string, len(messages))
+	}
+	for err, message := range messages {
+		t.patterns[err.Code()] = patterns[err.Code()]
+		t.messages[locale][err.Code()] = message
+	}
+	return nil
+}
+
+// Has return true when translations are added in the locale
+func (t *Translations) Has(locale string) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	_, ok := t.messages[normalizeLocale(locale)]
+	return ok
+}
+
+// Translate return the message of the error in the locale, or in its language, for example
+// "fa" for "fa-IR". The values the error is formatted with by Var are taken back from its
+// message and formatted in the translation. The English message of the error is returned when
+// the code or the locale has no translation, or the values cannot be told apart in the message,
+// for example when two verbs are adjacent in the declared one, use With and TranslateError then
+func (t *Translations) Translate(err ErrorType, locale string) string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	message, ok := t.message(err.Code(), locale)
+	if !ok {
+		return err.Error()
+	}
+	args, ok := t.patterns[err.Code()].extract(err.Error())
+	if !ok {
+		return err.Error()
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// TranslateError return the message of an error wrapping a FormattedError or an ErrorType in
+// the locale, as Translate does. The values of a FormattedError are formatted as they are in
+// the translation. It return false when the error wraps none of them
+func (t *Translations) TranslateError(err error, locale string) (string, bool) {
+	var formatted *FormattedError
+	if errors.As(err, &formatted) {
+		t.mu.RLock()
+		message, ok := t.message(formatted.Code(), locale)
+		t.mu.RUnlock()
+		if !ok {
+			return formatted.Error(), true
+		}
+		return fmt.Sprintf(message, formatted.Args...), true
+	}
+
+	var et ErrorType
+	if errors.As(err, &et) {
+		return t.Translate(et, locale), true
+	}
+	return "", false
+}
+
+// message return the translation of the code in the locale or in its language, the lock must
+// be held
+func (t *Translations) message(code, locale string) (string, bool) {
+	if code == "" {
+		return "", false
+	}
+	for _, candidate := range localeCandidates(locale) {
+		if message, ok := t.messages[candidate][code]; ok {
+			return message, true
+		}
+	}
+	return "", false
+}
+
+// AddTranslations add the translations of the errors to the DefaultTranslations, it is meant to
+// be called at init and panics on an invalid translation so it cannot go unnoticed
+func AddTranslations(locale string, messages map[ErrorType]string) {
+	if err := DefaultTranslations.Add(locale, messages); err != nil {
+		panic(err)
+	}
+}
+
+// Translate return the message of the error in the locale from the DefaultTranslations
+func Translate(err ErrorType, locale string) string {
+	return DefaultTranslations.Translate(err, locale)
+}
+
+// TranslateError return the message of the error in the locale from the DefaultTranslations
+func TranslateError(err error, locale string) (string, bool) {
+	return DefaultTranslations.TranslateError(err, locale)
+}
+
+// verb is a printf verb of a message with the index of its argument, from 0
+type verb struct {
+	index int
+	kind  byte
+}
+
+// parseVerbs return the verbs of a message, %% excluded
+func parseVerbs(message string) []verb {
+	var verbs []verb
+	next := 0
+	for _, m := range verbPattern.FindAllStringSubmatch(message, -1) {
+		if m[2] == "%" {
+			continue
+		}
+		if m[1] != "" {
+			n, _ := strconv.Atoi(m[1])
+			next = n - 1
+		}
+		verbs = append(verbs, verb{index: next, kind: m[2][0]})
+		next++
+	}
+	return verbs
+}
+
+// checkVerbs check the translation only use the arguments of the source, with the same verbs
+func checkVerbs(source, translation string) error {
+	args := parseVerbs(source)
+	for _, v := range parseVerbs(translation) {
+		if v.index < 0 || v.index >= len(args) {
+			return fmt.Errorf("the verb %%%c has no argument in %q", v.kind, source)
+		}
+		if want := args[v.index].kind; v.kind != want {
+			return fmt.Errorf("the argument %d is %%%c, not %%%c", v.index+1, want, v.kind)
+		}
+	}
+	return nil
+}
+
+// argPattern matches the messages formatted from a declared one, to take their values back
+type argPattern struct {
+	verbs []verb
+	count int            // the number of arguments
+	re    *regexp.Regexp // nil when the values cannot be told apart
+}
+
+// compileArgPattern return the pattern of the messages formatted from the source. It matches
+// none when two verbs are adjacent, for example "%s%d", as the values could be split anywhere
+func compileArgPattern(source string) *argPattern {
+	p := &argPattern{verbs: parseVerbs(source)}
+	for _, v := range p.verbs {
+		p.count = max(p.count, v.index+1)
+	}
+	if len(p.verbs) == 0 {
+		return p
+	}
+
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	last, value := 0, -1 // the end of the last verb, and of the last one taking a value
+	for _, loc := range verbPattern.FindAllStringSubmatchIndex(source, -1) {
+		pattern.WriteString(regexp.QuoteMeta(source[last:loc[0]]))
+		last = loc[1]
+		switch source[loc[4]] {
+		case '%':
+			pattern.WriteString("%")
+			continue
+		case 'd':
+			pattern.WriteString(`([-+]?\d+)`)
+		default:
+			pattern.WriteString(`(.*?)`)
+		}
+		if loc[0] == value {
+			return p
+		}
+		value = loc[1]
+	}
+	pattern.WriteString(regexp.QuoteMeta(source[last:]))
+	pattern.WriteString("$")
+
+	p.re, _ = regexp.Compile(pattern.String())
+	return p
+}
+
+// extract return the values a message is formatted with
+func (p *argPattern) extract(message string) ([]any, bool) {
+	if p == nil {
+		return nil, false
+	}
+	if len(p.verbs) == 0 {
+		return nil, true
+	}
+	if p.re == nil {
+		return nil, false
+	}
+	m := p.re.FindStringSubmatch(message)
+	if m == nil {
+		return nil, false
+	}
+
+	args := make([]any, p.count)
+	for i, v := range p.verbs {
+		args[v.index] = parseArg(v.kind, m[i+1])
+	}
+	return args, true
+}
+
+// parseArg return the value of an argument formatted with the verb, as a string when it cannot
+// be parsed
+func parseArg(kind byte, value string) any {
+	switch kind {
+	case 'd':
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	case 'e', 'f', 'g':
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case 'q':
+		if s, err := strconv.Unquote(value); err == nil {
+			return s
+		}
+	}
+	return value
+}
+
+// normalizeLocale return the locale as a BCP 47 tag, for example "fa-IR" for "fa_ir"
+func normalizeLocale(locale string) string {
+	language, region, ok := strings.Cut(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"), "-")
+	if !ok {
+		return strings.ToLower(language)
+	}
+	return strings.ToLower(language) + "-" + strings.ToUpper(region)
+}
+
+// localeCandidates return the locale followed by its language, for example "fa-IR" and "fa"
+func localeCandidates(locale string) []string {
+	locale = normalizeLocale(locale)
+	if locale == "" {
+		return nil
+	}
+	if language, _, ok := strings.Cut(locale, "-"); ok {
+		return []string{locale, language}
+	}
+	return []string{locale}
+}
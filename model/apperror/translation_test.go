@@ -0,0 +1,131 @@
+package apperror
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	errMaxLen       ErrorType = "ER9005 the length of %s must be %d characters or fewer. You entered %d characters"
+	errRatio        ErrorType = "ER9006 the ratio of %q must be under %g%%"
+	errUntranslated ErrorType = "ER9007 the order %s is closed"
+	errSKU          ErrorType = "ER9008 the SKU %s%d is out of stock"
+)
+
+func newTranslations(t *testing.T) *Translations {
+	tr := NewTranslations()
+	require.NoError(t, tr.Add("fa", map[ErrorType]string{
+		errMaxLen:          "طول %s باید حداکثر %d کاراکتر باشد. شما %d کاراکتر وارد کردید",
+		errProductNotFound: "محصول %s پیدا نشد",
+	}))
+	require.NoError(t, tr.Add("de", map[ErrorType]string{
+		// the arguments are reordered
+		errMaxLen: "Sie haben %[3]d Zeichen eingegeben, %[1]s darf höchstens %[2]d Zeichen lang sein",
+		errRatio:  "der Anteil von %q muss unter %g%% liegen",
+		errSKU:    "die SKU %s%d ist ausverkauft",
+	}))
+	return tr
+}
+
+func TestTranslate(t *testing.T) {
+	tr := newTranslations(t)
+
+	tests := map[string]struct {
+		err    ErrorType
+		locale string
+		want   string
+	}{
+		"fa":               {err: errMaxLen.Var("username", 20, 31), locale: "fa", want: "طول username باید حداکثر 20 کاراکتر باشد. شما 31 کاراکتر وارد کردید"},
+		"region":           {err: errMaxLen.Var("username", 20, 31), locale: "fa_IR", want: "طول username باید حداکثر 20 کاراکتر باشد. شما 31 کاراکتر وارد کردید"},
+		"reordered":        {err: errMaxLen.Var("first name", 20, 31), locale: "de-DE", want: "Sie haben 31 Zeichen eingegeben, first name darf höchstens 20 Zeichen lang sein"},
+		"quoted and float": {err: errRatio.Var(`say "hi"`, 0.5), locale: "de", want: `der Anteil von "say \"hi\"" muss unter 0.5% liegen`},
+		"unknown locale":   {err: errMaxLen.Var("username", 20, 31), locale: "it", want: "the length of username must be 20 characters or fewer. You entered 31 characters"},
+		"empty locale":     {err: errProductNotFound.Var("p-1"), locale: "", want: "product p-1 is not found"},
+		"unknown code":     {err: errUntranslated.Var("o-1"), locale: "fa", want: "the order o-1 is closed"},
+		"missing in de":    {err: errProductNotFound.Var("p-1"), locale: "de", want: "product p-1 is not found"},
+		"not formatted":    {err: errMaxLen, locale: "fa", want: "the length of %s must be %d characters or fewer. You entered %d characters"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tr.Translate(tt.err, tt.locale))
+		})
+	}
+
+	assert.True(t, tr.Has("fa"))
+	assert.True(t, tr.Has("DE"))
+	assert.False(t, tr.Has("fa-IR"))
+}
+
+func TestTranslationsAddInvalid(t *testing.T) {
+	tr := NewTranslations()
+
+	tests := map[string]struct {
+		locale   string
+		messages map[ErrorType]string
+		err      string
+	}{
+		"no code":      {locale: "fa", messages: map[ErrorType]string{"order is closed": "سفارش بسته است"}, err: `error "order is closed" has no code`},
+		"wrong verb":   {locale: "fa", messages: map[ErrorType]string{errMaxLen: "طول %s باید حداکثر %s کاراکتر باشد"}, err: "the argument 2 is %d, not %s"},
+		"extra verb":   {locale: "fa", messages: map[ErrorType]string{errProductNotFound: "محصول %s در %s پیدا نشد"}, err: "the verb %s has no argument"},
+		"empty locale": {locale: " ", messages: map[ErrorType]string{errProductNotFound: "محصول %s پیدا نشد"}, err: "the locale of the translations is empty"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.ErrorContains(t, tr.Add(tt.locale, tt.messages), tt.err)
+		})
+	}
+
+	assert.False(t, tr.Has("fa"), "nothing is added")
+	assert.Panics(t, func() {
+		AddTranslations("fa", map[ErrorType]string{errMaxLen: "%d"})
+	})
+}
+
+func TestTranslateError(t *testing.T) {
+	tr := newTranslations(t)
+
+	tests := map[string]struct {
+		err    error
+		locale string
+		want   string
+	}{
+		"with":              {err: errMaxLen.With("username", 20, 31), locale: "de", want: "Sie haben 31 Zeichen eingegeben, username darf höchstens 20 Zeichen lang sein"},
+		"wrapped":           {err: fmt.Errorf("create user: %w", errMaxLen.With("username", 20, 31)), locale: "fa", want: "طول username باید حداکثر 20 کاراکتر باشد. شما 31 کاراکتر وارد کردید"},
+		"adjacent verbs":    {err: errSKU.With("AB1", 23), locale: "de", want: "die SKU AB123 ist ausverkauft"},
+		"var":               {err: errProductNotFound.Var("p-1"), locale: "fa", want: "محصول p-1 پیدا نشد"},
+		"var ambiguous":     {err: errSKU.Var("AB1", 23), locale: "de", want: "the SKU AB123 is out of stock"},
+		"with no locale":    {err: errSKU.With("AB1", 23), locale: "it", want: "the SKU AB123 is out of stock"},
+		"with no code":      {err: ErrorType("order %s is closed").With("o-1"), locale: "fa", want: "order o-1 is closed"},
+		"with untranslated": {err: errUntranslated.With("o-1"), locale: "fa", want: "the order o-1 is closed"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, ok := tr.TranslateError(tt.err, tt.locale)
+			require.True(t, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, ok := tr.TranslateError(errors.New("boom"), "fa")
+	assert.False(t, ok, "not an apperror")
+}
+
+func TestFormattedError(t *testing.T) {
+	err := fmt.Errorf("create user: %w", errMaxLen.With("username", 20, 31))
+
+	assert.EqualError(t, err, "create user: the length of username must be 20 characters or fewer. You entered 31 characters")
+	assert.ErrorIs(t, err, errMaxLen)
+	assert.NotErrorIs(t, err, errRatio)
+
+	var et ErrorType
+	require.ErrorAs(t, err, &et)
+	assert.Equal(t, errMaxLen.Var("username", 20, 31), et)
+	assert.Equal(t, "ER9005", et.Code())
+}
@@ -0,0 +1,99 @@
+package payload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WriteJSON writes the response as JSON with an ETag, the hash of its body, and answers
+// 304 Not Modified without a body when the If-None-Match header of the request holds the
+// ETag. The trace ID of a Response changes on every request, so it is left out of the hash.
+// Only the 2xx responses are given an ETag.
+//
+// Parameters:
+//   - c: The Gin context of the request.
+//   - status: The HTTP status of the response.
+//   - resp: The response, e.g. built by NewSuccessResponse.
+//   - cacheControl: The value of the Cache-Control header, e.g. "private, max-age=60", not set when empty.
+func WriteJSON(c *gin.Context, status int, resp any, cacheControl string) {
+	body, err := json.Marshal(resp)
+	if err != nil {
+		_ = c.Error(err)
+		WriteError(c, err, traceIDOf(resp))
+		return
+	}
+
+	if cacheControl != "" {
+		c.Header("Cache-Control", cacheControl)
+	}
+
+	if status >= 200 && status < 300 {
+		etag, err := ETag(resp)
+		if err != nil {
+			_ = c.Error(err)
+			WriteError(c, err, traceIDOf(resp))
+			return
+		}
+		c.Header("ETag", etag)
+
+		if etagMatches(c.GetHeader("If-None-Match"), etag) {
+			c.Status(http.StatusNotModified)
+			c.Writer.WriteHeaderNow()
+			return
+		}
+	}
+
+	c.Data(status, "application/json; charset=utf-8", body)
+}
+
+// ETag computes the strong ETag of a response, the SHA-256 of its JSON without the trace ID
+// of a Response.
+//
+// Parameters:
+//   - resp: The response.
+//
+// Returns:
+//   - The quoted ETag.
+//   - An error if the response cannot be marshaled.
+func ETag(resp any) (string, error) {
+	if res, ok := resp.(Response); ok {
+		res.TraceID = ""
+		resp = res
+	}
+
+	body, err := json.Marshal(resp)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`, nil
+}
+
+// etagMatches reports whether an If-None-Match header matches the ETag, with the weak
+// comparison of RFC 9110.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// traceIDOf returns the trace ID of a Response, empty for other responses.
+func traceIDOf(resp any) string {
+	if res, ok := resp.(Response); ok {
+		return res.TraceID
+	}
+	return ""
+}
@@ -0,0 +1,81 @@
+package payload
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveJSON(t *testing.T, status int, resp any, ifNoneMatch string) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodGet, "/products/p-1", nil)
+	if ifNoneMatch != "" {
+		c.Request.Header.Set("If-None-Match", ifNoneMatch)
+	}
+
+	WriteJSON(c, status, resp, "private, max-age=60")
+	return rec
+}
+
+func TestWriteJSONETagRoundTrip(t *testing.T) {
+	product := map[string]any{"id": "p-1", "price": 1200}
+
+	first := serveJSON(t, http.StatusOK, NewSuccessResponse(product, "trace-1"), "")
+	require.Equal(t, http.StatusOK, first.Code)
+	etag := first.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	assert.Equal(t, "private, max-age=60", first.Header().Get("Cache-Control"))
+	assert.JSONEq(t, `{"success":true,"error_code":"","error_message":"","data":{"id":"p-1","price":1200},"trace_id":"trace-1"}`, first.Body.String())
+
+	// the trace ID of the second request differs, the body does not
+	second := serveJSON(t, http.StatusOK, NewSuccessResponse(product, "trace-2"), etag)
+	assert.Equal(t, http.StatusNotModified, second.Code)
+	assert.Empty(t, second.Body.String())
+	assert.Equal(t, etag, second.Header().Get("ETag"))
+	assert.Equal(t, "private, max-age=60", second.Header().Get("Cache-Control"))
+
+	changed := serveJSON(t, http.StatusOK, NewSuccessResponse(map[string]any{"id": "p-1", "price": 1500}, "trace-3"), etag)
+	assert.Equal(t, http.StatusOK, changed.Code)
+	assert.NotEqual(t, etag, changed.Header().Get("ETag"))
+}
+
+func TestWriteJSONIfNoneMatch(t *testing.T) {
+	resp := NewSuccessResponse("ok", "trace-1")
+	etag, err := ETag(resp)
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		ifNoneMatch string
+		status      int
+	}{
+		"none":      {ifNoneMatch: "", status: http.StatusOK},
+		"other":     {ifNoneMatch: `"abc"`, status: http.StatusOK},
+		"same":      {ifNoneMatch: etag, status: http.StatusNotModified},
+		"weak":      {ifNoneMatch: "W/" + etag, status: http.StatusNotModified},
+		"list":      {ifNoneMatch: `"abc", ` + etag, status: http.StatusNotModified},
+		"any":       {ifNoneMatch: "*", status: http.StatusNotModified},
+		"truncated": {ifNoneMatch: etag[:10], status: http.StatusOK},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.status, serveJSON(t, http.StatusOK, resp, tt.ifNoneMatch).Code)
+		})
+	}
+}
+
+func TestWriteJSONWithoutETag(t *testing.T) {
+	rec := serveJSON(t, http.StatusNotFound, NewErrorResponse(errOrderNotFound.Var("o-1"), "trace-1"), "*")
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Empty(t, rec.Header().Get("ETag"))
+	assert.Contains(t, rec.Body.String(), "ER8404")
+}
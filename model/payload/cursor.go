@@ -0,0 +1,278 @@
+package payload
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/a-aslani/wotop/model/apperror"
+)
+
+const (
+	// ErrInvalidCursor indicates a cursor that is malformed, tampered with or signed with another secret.
+	ErrInvalidCursor apperror.ErrorType = "ER0105 %s is not a valid cursor"
+	// ErrForeignCursor indicates a cursor issued for another list or order.
+	ErrForeignCursor apperror.ErrorType = "ER0106 %s was issued for another list"
+)
+
+func init() {
+	apperror.Register("payload",
+		apperror.Entry{Err: ErrInvalidCursor, Description: "The cursor is malformed or was modified by the client."},
+		apperror.Entry{Err: ErrForeignCursor, Description: "The cursor belongs to another list or sort order than the one requested."},
+	)
+	apperror.MapCode(ErrInvalidCursor.Code(), http.StatusBadRequest)
+	apperror.MapCode(ErrForeignCursor.Code(), http.StatusBadRequest)
+}
+
+// CursorDirection tells whether a cursor points to the page after or before its keys.
+type CursorDirection string
+
+const (
+	CursorNext CursorDirection = "next"
+	CursorPrev CursorDirection = "prev"
+)
+
+// Cursor is the position of a page in a list: the key values of the last item of the previous
+// page, or of the first item of the next page when going backward. It is handed to the client
+// as an opaque string by EncodeCursor.
+//
+// Fields:
+//   - Scope: The list and order the cursor was issued for, e.g. "products:created_at:desc".
+//   - Direction: CursorNext or CursorPrev.
+//   - Keys: The JSON of the key values, read with Scan.
+type Cursor struct {
+	Scope     string            `json:"s"`
+	Direction CursorDirection   `json:"d"`
+	Keys      []json.RawMessage `json:"k"`
+}
+
+// NewCursor creates a cursor on the key values of an item.
+//
+// Parameters:
+//   - scope: The list and order the cursor is issued for.
+//   - direction: CursorNext for the page after the item, CursorPrev for the page before it.
+//   - keys: The key values of the item, in the order of the columns of its CursorCondition.
+//
+// Returns:
+//   - The cursor.
+//   - An error if a key cannot be marshalled to JSON.
+func NewCursor(scope string, direction CursorDirection, keys ...any) (Cursor, error) {
+	c := Cursor{Scope: scope, Direction: direction, Keys: make([]json.RawMessage, len(keys))}
+	for i, key := range keys {
+		raw, err := json.Marshal(key)
+		if err != nil {
+			return Cursor{}, fmt.Errorf("cursor key %d: %w", i, err)
+		}
+		c.Keys[i] = raw
+	}
+	return c, nil
+}
+
+// Scan unmarshals the key values into dest, e.g. a *time.Time and an *int64.
+//
+// Parameters:
+//   - dest: Pointers to the key values, as many as the keys.
+//
+// Returns:
+//   - ErrInvalidCursor if the keys do not fit dest.
+func (c Cursor) Scan(dest ...any) error {
+	if len(dest) != len(c.Keys) {
+		return ErrInvalidCursor.Var("cursor")
+	}
+	for i, key := range c.Keys {
+		if err := json.Unmarshal(key, dest[i]); err != nil {
+			return ErrInvalidCursor.Var("cursor")
+		}
+	}
+	return nil
+}
+
+// EncodeCursor signs the cursor with the secret of the application, so the client can neither
+// forge nor modify it. The cursor is the base64 of its JSON followed by the base64 of its
+// HMAC-SHA256, separated by a dot.
+//
+// Parameters:
+//   - secret: The secret signing the cursors, it must not be empty.
+//   - c: The cursor.
+//
+// Returns:
+//   - The opaque cursor, safe in a URL.
+//   - An error if the secret is empty.
+func EncodeCursor(secret []byte, c Cursor) (string, error) {
+	if len(secret) == 0 {
+		return "", errors.New("payload: the cursor secret is empty")
+	}
+
+	blob, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(blob) + "." + enc.EncodeToString(signCursor(secret, blob)), nil
+}
+
+// DecodeCursor checks the signature of a cursor encoded by EncodeCursor and decodes it.
+//
+// Parameters:
+//   - secret: The secret the cursor was signed with.
+//   - token: The cursor sent by the client.
+//   - scope: The list and order requested, the one of the cursor must be the same.
+//
+// Returns:
+//   - The cursor.
+//   - ErrInvalidCursor if the cursor is malformed or its signature does not match.
+//   - ErrForeignCursor if the cursor was issued for another scope.
+func DecodeCursor(secret []byte, token, scope string) (Cursor, error) {
+
+	enc := base64.RawURLEncoding
+	blob64, sig64, ok := strings.Cut(token, ".")
+	if !ok || len(secret) == 0 {
+		return Cursor{}, ErrInvalidCursor.Var("cursor")
+	}
+
+	blob, err := enc.DecodeString(blob64)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor.Var("cursor")
+	}
+	sig, err := enc.DecodeString(sig64)
+	if err != nil || !hmac.Equal(sig, signCursor(secret, blob)) {
+		return Cursor{}, ErrInvalidCursor.Var("cursor")
+	}
+
+	var c Cursor
+	if err = json.Unmarshal(blob, &c); err != nil || (c.Direction != CursorNext && c.Direction != CursorPrev) {
+		return Cursor{}, ErrInvalidCursor.Var("cursor")
+	}
+	if c.Scope != scope {
+		return Cursor{}, ErrForeignCursor.Var("cursor")
+	}
+
+	return c, nil
+}
+
+func signCursor(secret, blob []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(blob)
+	return mac.Sum(nil)
+}
+
+// CursorCondition builds the SQL of the keyset pagination of a list ordered by Columns, e.g.
+// created_at then id so the order is total. The columns are sorted in the same Order, and
+// are put in the query as is, they must not come from the client.
+//
+// Fields:
+//   - Columns: The key columns, the last one unique.
+//   - Order: OrderAsc or OrderDesc, OrderAsc when empty.
+type CursorCondition struct {
+	Columns []string
+	Order   string
+}
+
+// Where returns the condition selecting the page of the cursor, e.g.
+// "(created_at, id) > ($1, $2)" and its arguments, or no condition for the first page.
+//
+// Parameters:
+//   - cursor: The decoded cursor, nil for the first page.
+//   - firstArg: The number of the first placeholder, 1 unless the query has other arguments before.
+//
+// Returns:
+//   - The condition, without WHERE, empty for the first page.
+//   - The arguments of its placeholders.
+//   - ErrInvalidCursor if the cursor does not have a key per column.
+func (cc CursorCondition) Where(cursor *Cursor, firstArg int) (string, []any, error) {
+	if cursor == nil {
+		return "", nil, nil
+	}
+	if len(cursor.Keys) != len(cc.Columns) {
+		return "", nil, ErrInvalidCursor.Var("cursor")
+	}
+
+	placeholders := make([]string, len(cursor.Keys))
+	args := make([]any, len(cursor.Keys))
+	for i, key := range cursor.Keys {
+		// json.Number keeps the large integers exact
+		d := json.NewDecoder(bytes.NewReader(key))
+		d.UseNumber()
+		if err := d.Decode(&args[i]); err != nil {
+			return "", nil, ErrInvalidCursor.Var("cursor")
+		}
+		if n, ok := args[i].(json.Number); ok {
+			args[i] = n.String()
+		}
+		placeholders[i] = fmt.Sprintf("$%d", firstArg+i)
+	}
+
+	operator := ">"
+	if cc.descending() != (cursor.Direction == CursorPrev) {
+		operator = "<"
+	}
+
+	return fmt.Sprintf("(%s) %s (%s)", strings.Join(cc.Columns, ", "), operator, strings.Join(placeholders, ", ")), args, nil
+}
+
+// OrderBy returns the ORDER BY clause of the page of the cursor, e.g. "created_at DESC, id DESC".
+// The order is reversed for a CursorPrev cursor, the items of the page must then be reversed.
+//
+// Parameters:
+//   - cursor: The decoded cursor, nil for the first page.
+//
+// Returns:
+//   - The clause, without ORDER BY.
+func (cc CursorCondition) OrderBy(cursor *Cursor) string {
+	order := "ASC"
+	if cc.descending() != (cursor != nil && cursor.Direction == CursorPrev) {
+		order = "DESC"
+	}
+
+	columns := make([]string, len(cc.Columns))
+	for i, column := range cc.Columns {
+		columns[i] = column + " " + order
+	}
+	return strings.Join(columns, ", ")
+}
+
+func (cc CursorCondition) descending() bool {
+	return strings.EqualFold(cc.Order, OrderDesc)
+}
+
+// CursorMeta is the meta block of a page of a cursor-paginated list.
+//
+// Fields:
+//   - NextCursor: The cursor of the next page, empty on the last page.
+//   - PrevCursor: The cursor of the previous page, empty on the first page.
+//   - HasNext: Whether a page follows this one.
+//   - HasPrev: Whether a page precedes this one.
+type CursorMeta struct {
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+	HasNext    bool   `json:"has_next"`
+	HasPrev    bool   `json:"has_prev"`
+}
+
+// NewCursorPageResponse creates a success response of a page of a cursor-paginated list.
+//
+// Parameters:
+//   - data: The items of the page.
+//   - nextCursor: The cursor of the next page, empty on the last page.
+//   - prevCursor: The cursor of the previous page, empty on the first page.
+//   - traceID: A unique identifier for tracing the request.
+//
+// Returns:
+//   - A Response object with the items as data and the CursorMeta of the page.
+func NewCursorPageResponse(data any, nextCursor, prevCursor string, traceID string) any {
+	res := NewSuccessResponse(data, traceID).(Response)
+	res.Meta = &CursorMeta{
+		NextCursor: nextCursor,
+		PrevCursor: prevCursor,
+		HasNext:    nextCursor != "",
+		HasPrev:    prevCursor != "",
+	}
+	return res
+}
@@ -0,0 +1,167 @@
+package payload
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop/model/apperror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var cursorSecret = []byte("app-secret")
+
+const productsScope = "products:created_at:desc"
+
+func TestCursorRoundTrip(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 10, 30, 0, 123456789, time.UTC)
+	c, err := NewCursor(productsScope, CursorNext, createdAt, int64(9007199254740993))
+	require.NoError(t, err)
+
+	token, err := EncodeCursor(cursorSecret, c)
+	require.NoError(t, err)
+	assert.NotContains(t, token, "=", "the cursor is safe in a URL")
+
+	decoded, err := DecodeCursor(cursorSecret, token, productsScope)
+	require.NoError(t, err)
+	assert.Equal(t, CursorNext, decoded.Direction)
+
+	var gotCreatedAt time.Time
+	var gotID int64
+	require.NoError(t, decoded.Scan(&gotCreatedAt, &gotID))
+	assert.True(t, createdAt.Equal(gotCreatedAt))
+	assert.Equal(t, int64(9007199254740993), gotID)
+
+	assertCursorError(t, decoded.Scan(&gotID), ErrInvalidCursor)
+}
+
+func TestDecodeCursorTampered(t *testing.T) {
+	c, err := NewCursor(productsScope, CursorNext, "2024-05-01T10:30:00Z", 42)
+	require.NoError(t, err)
+	token, err := EncodeCursor(cursorSecret, c)
+	require.NoError(t, err)
+
+	blob64, sig64, _ := strings.Cut(token, ".")
+	blob, err := base64.RawURLEncoding.DecodeString(blob64)
+	require.NoError(t, err)
+	forged := base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(blob), "42", "43", 1)))
+
+	tests := map[string]string{
+		"modified keys":    forged + "." + sig64,
+		"other secret":     mustEncodeCursor(t, []byte("other-secret"), c),
+		"no signature":     blob64,
+		"empty signature":  blob64 + ".",
+		"not base64":       "!!!." + sig64,
+		"empty":            "",
+		"truncated":        token[:len(token)-2],
+		"unsigned garbage": base64.RawURLEncoding.EncodeToString([]byte("{}")) + "." + sig64,
+	}
+	for name, token := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := DecodeCursor(cursorSecret, token, productsScope)
+			assertCursorError(t, err, ErrInvalidCursor)
+		})
+	}
+}
+
+func TestDecodeCursorForeign(t *testing.T) {
+	c, err := NewCursor("orders:created_at:desc", CursorNext, 42)
+	require.NoError(t, err)
+
+	_, err = DecodeCursor(cursorSecret, mustEncodeCursor(t, cursorSecret, c), productsScope)
+	assertCursorError(t, err, ErrForeignCursor)
+	assert.EqualError(t, err, "cursor was issued for another list")
+	assert.Equal(t, 400, ErrorStatus(err))
+}
+
+func TestEncodeCursorNeedsSecret(t *testing.T) {
+	_, err := EncodeCursor(nil, Cursor{Scope: productsScope, Direction: CursorNext})
+	assert.Error(t, err)
+
+	_, err = DecodeCursor(nil, mustEncodeCursor(t, cursorSecret, Cursor{Scope: productsScope, Direction: CursorNext}), productsScope)
+	assertCursorError(t, err, ErrInvalidCursor)
+}
+
+func TestCursorConditionWhere(t *testing.T) {
+	desc := CursorCondition{Columns: []string{"created_at", "id"}, Order: OrderDesc}
+	asc := CursorCondition{Columns: []string{"created_at", "id"}}
+
+	next, err := NewCursor(productsScope, CursorNext, "2024-05-01T10:30:00Z", int64(9007199254740993))
+	require.NoError(t, err)
+	prev := next
+	prev.Direction = CursorPrev
+
+	tests := map[string]struct {
+		cc      CursorCondition
+		cursor  *Cursor
+		where   string
+		orderBy string
+	}{
+		"first page":     {cc: desc, where: "", orderBy: "created_at DESC, id DESC"},
+		"desc next page": {cc: desc, cursor: &next, where: "(created_at, id) < ($3, $4)", orderBy: "created_at DESC, id DESC"},
+		"desc prev page": {cc: desc, cursor: &prev, where: "(created_at, id) > ($3, $4)", orderBy: "created_at ASC, id ASC"},
+		"asc next page":  {cc: asc, cursor: &next, where: "(created_at, id) > ($3, $4)", orderBy: "created_at ASC, id ASC"},
+		"asc prev page":  {cc: asc, cursor: &prev, where: "(created_at, id) < ($3, $4)", orderBy: "created_at DESC, id DESC"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			where, args, err := tt.cc.Where(tt.cursor, 3)
+			require.NoError(t, err)
+			assert.Equal(t, tt.where, where)
+			assert.Equal(t, tt.orderBy, tt.cc.OrderBy(tt.cursor))
+			if tt.cursor == nil {
+				assert.Empty(t, args)
+				return
+			}
+			assert.Equal(t, []any{"2024-05-01T10:30:00Z", "9007199254740993"}, args, "the large integers stay exact")
+		})
+	}
+
+	short, err := NewCursor(productsScope, CursorNext, 42)
+	require.NoError(t, err)
+	_, _, err = desc.Where(&short, 1)
+	assertCursorError(t, err, ErrInvalidCursor)
+}
+
+func TestNewCursorPageResponse(t *testing.T) {
+	tests := map[string]struct {
+		next, prev string
+		meta       string
+	}{
+		"first page":  {next: "n", meta: `{"next_cursor":"n","has_next":true,"has_prev":false}`},
+		"middle page": {next: "n", prev: "p", meta: `{"next_cursor":"n","prev_cursor":"p","has_next":true,"has_prev":true}`},
+		"last page":   {prev: "p", meta: `{"prev_cursor":"p","has_next":false,"has_prev":true}`},
+		"single page": {meta: `{"has_next":false,"has_prev":false}`},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			body, err := json.Marshal(NewCursorPageResponse([]string{"a"}, tt.next, tt.prev, "trace-1"))
+			require.NoError(t, err)
+
+			var res struct {
+				Data []string        `json:"data"`
+				Meta json.RawMessage `json:"meta"`
+			}
+			require.NoError(t, json.Unmarshal(body, &res))
+			assert.Equal(t, []string{"a"}, res.Data)
+			assert.JSONEq(t, tt.meta, string(res.Meta))
+		})
+	}
+}
+
+func mustEncodeCursor(t *testing.T, secret []byte, c Cursor) string {
+	t.Helper()
+	token, err := EncodeCursor(secret, c)
+	require.NoError(t, err)
+	return token
+}
+
+func assertCursorError(t *testing.T, err error, want apperror.ErrorType) {
+	t.Helper()
+	var appErr apperror.ErrorType
+	require.ErrorAs(t, err, &appErr)
+	assert.Equal(t, want.Code(), appErr.Code())
+}
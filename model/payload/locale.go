@@ -0,0 +1,136 @@
+package payload
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/a-aslani/wotop/model/apperror"
+	"github.com/gin-gonic/gin"
+)
+
+// LocaleQueryParam is the query parameter overriding the Accept-Language of a request, e.g.
+// "?lang=fa" for the clients which cannot set the header.
+var LocaleQueryParam = "lang"
+
+// NewErrorResponseLocalized creates a new error response whose error message is translated in
+// a locale, see apperror.AddTranslations. The error code is left unchanged, so the clients keep
+// matching on it. The messages of a validation error are translated too.
+//
+// Parameters:
+//   - err: The error object to include in the response.
+//   - traceID: A unique identifier for tracing the request.
+//   - locale: The locale of the message, e.g. "fa" or "fa-IR", the English message is kept
+//     when it is empty or the error has no translation in it.
+//
+// Returns:
+//   - A Response object as NewErrorResponse creates, with the translated error message.
+func NewErrorResponseLocalized(err error, traceID string, locale string) any {
+	var ve *ValidationError
+	if errors.As(err, &ve) {
+		messages := make([]any, len(ve.Messages))
+		for i, m := range ve.Messages {
+			if m.Code != "" {
+				m.Message = apperror.Translate(apperror.ErrorType(m.Code+" "+m.Message), locale)
+			}
+			messages[i] = m
+		}
+		return NewValidationErrorResponse(messages, traceID)
+	}
+
+	res := NewErrorResponse(err, traceID).(Response)
+
+	if message, ok := apperror.TranslateError(err, locale); ok {
+		res.ErrorMessage = message
+	}
+	return res
+}
+
+// RequestLocale returns the locale of the error messages of a request: the LocaleQueryParam
+// when it is set, else the first language of the Accept-Language header, by quality, having
+// translations, else an empty locale for the English messages.
+//
+// Parameters:
+//   - c: The Gin context of the request.
+//
+// Returns:
+//   - The locale, e.g. "fa-IR" or "fa", empty when the request asks for none with translations.
+func RequestLocale(c *gin.Context) string {
+	if locale := c.Query(LocaleQueryParam); locale != "" {
+		return locale
+	}
+
+	for _, tag := range acceptedLanguages(c.GetHeader("Accept-Language")) {
+		if apperror.DefaultTranslations.Has(tag) {
+			return tag
+		}
+		if language, _, ok := strings.Cut(tag, "-"); ok && apperror.DefaultTranslations.Has(language) {
+			return language
+		}
+	}
+	return ""
+}
+
+// WriteErrorLocalized writes the error response of err as WriteError does, with its message in
+// the locale of the request, see RequestLocale.
+//
+// Parameters:
+//   - c: The Gin context of the request.
+//   - err: The error to answer.
+//   - traceID: A unique identifier for tracing the request.
+func WriteErrorLocalized(c *gin.Context, err error, traceID string) {
+	locale := RequestLocale(c)
+
+	if wantsProblem(c) {
+		p := NewProblemResponse(err, traceID, c.Request.URL.Path).(Problem)
+		if message, ok := apperror.TranslateError(err, locale); ok && p.Code != "" {
+			p.Detail = message
+		}
+		c.Header("Content-Type", ProblemContentType)
+		c.JSON(p.Status, p)
+		return
+	}
+
+	res := NewErrorResponseLocalized(err, traceID, locale).(Response)
+	c.JSON(res.Status, res)
+}
+
+// acceptedLanguages returns the language tags of an Accept-Language header by descending
+// quality, without the wildcard and the refused ones.
+func acceptedLanguages(header string) []string {
+	type language struct {
+		tag     string
+		quality float64
+	}
+
+	var languages []language
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			v, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			quality = v
+		}
+		if quality <= 0 {
+			continue
+		}
+		languages = append(languages, language{tag: tag, quality: quality})
+	}
+
+	sort.SliceStable(languages, func(i, j int) bool { return languages[i].quality > languages[j].quality })
+
+	tags := make([]string, len(languages))
+	for i, l := range languages {
+		tags[i] = l.tag
+	}
+	return tags
+}
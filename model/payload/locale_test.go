@@ -0,0 +1,118 @@
+package payload
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/a-aslani/wotop/model/apperror"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const errNameTooLong apperror.ErrorType = "ER8005 the length of %s must be %d characters or fewer. You entered %d characters"
+
+func init() {
+	apperror.AddTranslations("fa", map[apperror.ErrorType]string{
+		errNameTooLong:   "طول %s باید حداکثر %d کاراکتر باشد. شما %d کاراکتر وارد کردید",
+		errOrderNotFound: "سفارش %s پیدا نشد",
+	})
+	apperror.AddTranslations("de", map[apperror.ErrorType]string{
+		errNameTooLong: "%[1]s darf höchstens %[2]d Zeichen lang sein, Sie haben %[3]d eingegeben",
+	})
+}
+
+func TestNewErrorResponseLocalized(t *testing.T) {
+	err := fmt.Errorf("rename: %w", errNameTooLong.Var("name", 20, 31).WithStatus(http.StatusBadRequest))
+
+	tests := map[string]struct {
+		locale  string
+		message string
+	}{
+		"fa":      {locale: "fa", message: "طول name باید حداکثر 20 کاراکتر باشد. شما 31 کاراکتر وارد کردید"},
+		"de":      {locale: "de-AT", message: "name darf höchstens 20 Zeichen lang sein, Sie haben 31 eingegeben"},
+		"unknown": {locale: "it", message: "the length of name must be 20 characters or fewer. You entered 31 characters"},
+		"empty":   {locale: "", message: "the length of name must be 20 characters or fewer. You entered 31 characters"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			res := NewErrorResponseLocalized(err, "trace-1", tt.locale).(Response)
+			assert.Equal(t, "ER8005", res.ErrorCode, "the code is stable across the locales")
+			assert.Equal(t, tt.message, res.ErrorMessage)
+			assert.Equal(t, http.StatusBadRequest, res.Status)
+		})
+	}
+
+	res := NewErrorResponseLocalized(errNameTooLong.With("name", 20, 31), "trace-1", "de").(Response)
+	assert.Equal(t, "name darf höchstens 20 Zeichen lang sein, Sie haben 31 eingegeben", res.ErrorMessage, "the values of With are not parsed back")
+
+	res = NewErrorResponseLocalized(&ValidationError{Messages: []Message{
+		{FieldName: "name", Code: "ER8005", Message: "the length of name must be 20 characters or fewer. You entered 31 characters", Rule: "max", Param: "20"},
+	}}, "trace-1", "fa").(Response)
+	assert.Equal(t, "BAD_REQUEST", res.ErrorCode)
+	messages := res.Data.(map[string]any)["errors"].([]any)
+	assert.Equal(t, "طول name باید حداکثر 20 کاراکتر باشد. شما 31 کاراکتر وارد کردید", messages[0].(Message).Message)
+	assert.Equal(t, "max", messages[0].(Message).Rule)
+}
+
+func TestRequestLocale(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := map[string]struct {
+		target         string
+		acceptLanguage string
+		want           string
+	}{
+		"header":          {target: "/", acceptLanguage: "fa", want: "fa"},
+		"language of tag": {target: "/", acceptLanguage: "fa-IR,fa;q=0.9", want: "fa"},
+		"by quality":      {target: "/", acceptLanguage: "it;q=0.9, de;q=0.8, fa;q=0.5", want: "de"},
+		"refused":         {target: "/", acceptLanguage: "fa;q=0, en", want: ""},
+		"untranslated":    {target: "/", acceptLanguage: "it, *;q=0.1", want: ""},
+		"query override":  {target: "/?lang=de", acceptLanguage: "fa", want: "de"},
+		"none":            {target: "/", want: ""},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, tt.target, nil)
+			c.Request.Header.Set("Accept-Language", tt.acceptLanguage)
+
+			assert.Equal(t, tt.want, RequestLocale(c))
+		})
+	}
+}
+
+func TestWriteErrorLocalized(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	write := func(mode ErrorMode, target string) map[string]any {
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		c.Request = httptest.NewRequest(http.MethodGet, target, nil)
+		c.Request.Header.Set("Accept-Language", "fa-IR, en;q=0.5")
+		c.Set(errorModeKey, mode)
+
+		WriteErrorLocalized(c, errOrderNotFound.Var("o-1"), "trace-1")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		var body map[string]any
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		return body
+	}
+
+	body := write(LegacyErrors, "/orders/o-1")
+	assert.Equal(t, "ER8404", body["error_code"])
+	assert.Equal(t, "سفارش o-1 پیدا نشد", body["error_message"])
+
+	body = write(ProblemErrors, "/orders/o-1")
+	assert.Equal(t, "ER8404", body["code"])
+	assert.Equal(t, "سفارش o-1 پیدا نشد", body["detail"])
+
+	body = write(LegacyErrors, "/orders/o-1?lang=en")
+	assert.Equal(t, "order o-1 is not found", body["error_message"])
+}
@@ -0,0 +1,244 @@
+package payload
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/a-aslani/wotop/model/apperror"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// ErrInvalidPageNumber indicates a page or page size that is not a positive integer.
+	ErrInvalidPageNumber apperror.ErrorType = "ER0101 %s must be an integer greater than 0"
+	// ErrPerPageTooLarge indicates a page size or a page number above the maximum.
+	ErrPerPageTooLarge apperror.ErrorType = "ER0102 %s must be %d or fewer"
+	// ErrSortNotAllowed indicates a sort field outside of the allowed ones.
+	ErrSortNotAllowed apperror.ErrorType = "ER0103 %s must be one of %s"
+	// ErrInvalidOrder indicates an order other than asc and desc.
+	ErrInvalidOrder apperror.ErrorType = "ER0104 %s must be asc or desc"
+)
+
+func init() {
+	apperror.Register("payload",
+		apperror.Entry{Err: ErrInvalidPageNumber, Description: "The page or the page size is not a positive integer."},
+		apperror.Entry{Err: ErrPerPageTooLarge, Description: "The page size or the page number is larger than allowed."},
+		apperror.Entry{Err: ErrSortNotAllowed, Description: "The list cannot be sorted by the field."},
+		apperror.Entry{Err: ErrInvalidOrder, Description: "The order is neither asc nor desc."},
+	)
+}
+
+const (
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+// PaginationDefaults configures the parsing of the pagination of a list endpoint.
+//
+// Fields:
+//   - PerPage: The page size when per_page is not given, 20 when zero.
+//   - MaxPerPage: The largest page size accepted, 100 when zero.
+//   - MaxPage: The largest page number accepted, e.g. to stop crawlers from asking for deep
+//     offsets the database scans slowly, unbounded when zero.
+//   - Sort: The sort field when sort is not given.
+//   - Order: The order when order is not given, asc when empty.
+//   - SortFields: The fields the client may sort by, Sort is always allowed.
+type PaginationDefaults struct {
+	PerPage    int
+	MaxPerPage int
+	MaxPage    int
+	Sort       string
+	Order      string
+	SortFields []string
+}
+
+// Pagination is the page of a list requested by the client.
+//
+// Fields:
+//   - Page: The page number, starting at 1.
+//   - PerPage: The page size.
+//   - Sort: The field to sort by, one of the allowed fields.
+//   - Order: OrderAsc or OrderDesc.
+type Pagination struct {
+	Page    int    `json:"page"`
+	PerPage int    `json:"per_page"`
+	Sort    string `json:"sort"`
+	Order   string `json:"order"`
+}
+
+// PageMeta is the meta block of a paginated response.
+//
+// Fields:
+//   - Page: The page number.
+//   - PerPage: The page size.
+//   - Total: The number of items of the whole list.
+//   - TotalPages: The number of pages.
+//   - HasNext: Whether a page follows this one.
+type PageMeta struct {
+	Page       int   `json:"page"`
+	PerPage    int   `json:"per_page"`
+	Total      int64 `json:"total"`
+	TotalPages int64 `json:"total_pages"`
+	HasNext    bool  `json:"has_next"`
+}
+
+// ValidationError lists the invalid fields of a request, it is answered with a validation
+// error response by NewErrorResponse and WriteError.
+type ValidationError struct {
+	Messages []Message
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Messages))
+	for i, m := range e.Messages {
+		messages[i] = m.Message
+	}
+	return "validation failed: " + strings.Join(messages, ", ")
+}
+
+// HTTPStatus implements apperror.StatusCoder.
+func (e *ValidationError) HTTPStatus() int {
+	return http.StatusBadRequest
+}
+
+// FromGinQuery parses the page, per_page, sort and order query parameters of the request.
+//
+// Parameters:
+//   - c: The Gin context of the request.
+//   - defaults: The defaults and bounds of the endpoint.
+//
+// Returns:
+//   - The requested Pagination.
+//   - A *ValidationError listing the invalid parameters.
+func FromGinQuery(c *gin.Context, defaults PaginationDefaults) (Pagination, error) {
+
+	if defaults.PerPage <= 0 {
+		defaults.PerPage = 20
+	}
+	if defaults.MaxPerPage <= 0 {
+		defaults.MaxPerPage = 100
+	}
+	if defaults.Order == "" {
+		defaults.Order = OrderAsc
+	}
+
+	p := Pagination{Page: 1, PerPage: defaults.PerPage, Sort: defaults.Sort, Order: defaults.Order}
+
+	var messages []Message
+	invalid := func(field string, e apperror.ErrorType) {
+		messages = append(messages, Message{FieldName: field, Code: e.Code(), Message: e.Error()})
+	}
+
+	if v, ok := c.GetQuery("page"); ok {
+		page, err := strconv.Atoi(v)
+		switch {
+		case err != nil || page < 1:
+			invalid("page", ErrInvalidPageNumber.Var("page"))
+		case defaults.MaxPage > 0 && page > defaults.MaxPage:
+			invalid("page", ErrPerPageTooLarge.Var("page", defaults.MaxPage))
+		default:
+			p.Page = page
+		}
+	}
+
+	if v, ok := c.GetQuery("per_page"); ok {
+		perPage, err := strconv.Atoi(v)
+		switch {
+		case err != nil || perPage < 1:
+			invalid("per_page", ErrInvalidPageNumber.Var("per_page"))
+		case perPage > defaults.MaxPerPage:
+			invalid("per_page", ErrPerPageTooLarge.Var("per_page", defaults.MaxPerPage))
+		default:
+			p.PerPage = perPage
+		}
+	}
+
+	if v, ok := c.GetQuery("sort"); ok && v != defaults.Sort {
+		if slices.Contains(defaults.SortFields, v) {
+			p.Sort = v
+		} else {
+			invalid("sort", ErrSortNotAllowed.Var("sort", strings.Join(allowedSortFields(defaults), ", ")))
+		}
+	}
+
+	if v, ok := c.GetQuery("order"); ok {
+		switch order := strings.ToLower(v); order {
+		case OrderAsc, OrderDesc:
+			p.Order = order
+		default:
+			invalid("order", ErrInvalidOrder.Var("order"))
+		}
+	}
+
+	if len(messages) > 0 {
+		return p, &ValidationError{Messages: messages}
+	}
+
+	return p, nil
+}
+
+// allowedSortFields returns the default sort field followed by the other allowed fields.
+func allowedSortFields(defaults PaginationDefaults) []string {
+	fields := slices.Clone(defaults.SortFields)
+	if defaults.Sort != "" && !slices.Contains(fields, defaults.Sort) {
+		fields = append([]string{defaults.Sort}, fields...)
+	}
+	return fields
+}
+
+// Offset returns the number of items before the page, for SQL OFFSET. It saturates at
+// math.MaxInt rather than overflowing for the huge page numbers of the clients.
+func (p Pagination) Offset() int {
+	if p.Page <= 1 || p.PerPage <= 0 {
+		return 0
+	}
+	if p.Page-1 > math.MaxInt/p.PerPage {
+		return math.MaxInt
+	}
+	return (p.Page - 1) * p.PerPage
+}
+
+// Limit returns the page size, for SQL LIMIT.
+func (p Pagination) Limit() int {
+	return p.PerPage
+}
+
+// OrderBy returns the ORDER BY clause of the page, e.g. "created_at DESC", or "" without sort
+// field. Sort is one of the allowed fields, so it is safe to put in the query.
+func (p Pagination) OrderBy() string {
+	if p.Sort == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s %s", p.Sort, strings.ToUpper(p.Order))
+}
+
+// NewPaginatedResponse creates a success response of a page of a list.
+//
+// Parameters:
+//   - data: The items of the page.
+//   - p: The requested page.
+//   - total: The number of items of the whole list.
+//   - traceID: A unique identifier for tracing the request.
+//
+// Returns:
+//   - A Response object with the items as data and the meta block of the page.
+func NewPaginatedResponse(data any, p Pagination, total int64, traceID string) any {
+	var totalPages int64
+	if p.PerPage > 0 {
+		totalPages = (total + int64(p.PerPage) - 1) / int64(p.PerPage)
+	}
+
+	res := NewSuccessResponse(data, traceID).(Response)
+	res.Meta = &PageMeta{
+		Page:       p.Page,
+		PerPage:    p.PerPage,
+		Total:      total,
+		TotalPages: totalPages,
+		HasNext:    int64(p.Page) < totalPages,
+	}
+	return res
+}
@@ -0,0 +1,141 @@
+package payload
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var productDefaults = PaginationDefaults{
+	PerPage:    10,
+	MaxPerPage: 50,
+	Sort:       "created_at",
+	Order:      OrderDesc,
+	SortFields: []string{"name", "price"},
+}
+
+func queryContext(query string) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/products?"+query, nil)
+	return c
+}
+
+func TestFromGinQuery(t *testing.T) {
+	tests := map[string]struct {
+		query string
+		want  Pagination
+	}{
+		"defaults":        {query: "", want: Pagination{Page: 1, PerPage: 10, Sort: "created_at", Order: OrderDesc}},
+		"all parameters":  {query: "page=3&per_page=50&sort=price&order=ASC", want: Pagination{Page: 3, PerPage: 50, Sort: "price", Order: OrderAsc}},
+		"default sort":    {query: "sort=created_at", want: Pagination{Page: 1, PerPage: 10, Sort: "created_at", Order: OrderDesc}},
+		"other parameter": {query: "q=phone", want: Pagination{Page: 1, PerPage: 10, Sort: "created_at", Order: OrderDesc}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			p, err := FromGinQuery(queryContext(tt.query), productDefaults)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, p)
+		})
+	}
+}
+
+func TestFromGinQueryInvalid(t *testing.T) {
+	tests := map[string]struct {
+		query    string
+		messages []Message
+	}{
+		"zero page":      {query: "page=0", messages: []Message{{FieldName: "page", Code: "ER0101", Message: "page must be an integer greater than 0"}}},
+		"negative page":  {query: "page=-2", messages: []Message{{FieldName: "page", Code: "ER0101", Message: "page must be an integer greater than 0"}}},
+		"not a number":   {query: "per_page=ten", messages: []Message{{FieldName: "per_page", Code: "ER0101", Message: "per_page must be an integer greater than 0"}}},
+		"too large page": {query: "per_page=51", messages: []Message{{FieldName: "per_page", Code: "ER0102", Message: "per_page must be 50 or fewer"}}},
+		"unknown sort":   {query: "sort=password", messages: []Message{{FieldName: "sort", Code: "ER0103", Message: "sort must be one of created_at, name, price"}}},
+		"several": {query: "page=a&order=up", messages: []Message{
+			{FieldName: "page", Code: "ER0101", Message: "page must be an integer greater than 0"},
+			{FieldName: "order", Code: "ER0104", Message: "order must be asc or desc"},
+		}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := FromGinQuery(queryContext(tt.query), productDefaults)
+
+			var ve *ValidationError
+			require.ErrorAs(t, err, &ve)
+			assert.Equal(t, tt.messages, ve.Messages)
+			assert.Equal(t, http.StatusBadRequest, ErrorStatus(err))
+		})
+	}
+}
+
+func TestPaginationSQL(t *testing.T) {
+	p := Pagination{Page: 3, PerPage: 20, Sort: "price", Order: OrderDesc}
+	assert.Equal(t, 40, p.Offset())
+	assert.Equal(t, 20, p.Limit())
+	assert.Equal(t, "price DESC", p.OrderBy())
+	assert.Equal(t, "", Pagination{Page: 1, PerPage: 20}.OrderBy())
+}
+
+func TestPaginationHugePage(t *testing.T) {
+	p, err := FromGinQuery(queryContext(fmt.Sprintf("page=%d&per_page=50", math.MaxInt)), productDefaults)
+	require.NoError(t, err)
+	assert.Equal(t, math.MaxInt, p.Page)
+	assert.Equal(t, math.MaxInt, p.Offset(), "the offset saturates instead of overflowing")
+	assert.Equal(t, math.MaxInt, Pagination{Page: math.MaxInt/20 + 2, PerPage: 20}.Offset())
+	assert.Equal(t, math.MaxInt/20*20, Pagination{Page: math.MaxInt/20 + 1, PerPage: 20}.Offset())
+
+	_, err = FromGinQuery(queryContext("page=99999999999999999999"), productDefaults)
+	assert.Error(t, err, "a page larger than an int is rejected")
+
+	defaults := productDefaults
+	defaults.MaxPage = 1000
+	_, err = FromGinQuery(queryContext("page=1001"), defaults)
+	var ve *ValidationError
+	require.ErrorAs(t, err, &ve)
+	assert.Equal(t, []Message{{FieldName: "page", Code: "ER0102", Message: "page must be 1000 or fewer"}}, ve.Messages)
+}
+
+func TestNewPaginatedResponse(t *testing.T) {
+	tests := map[string]struct {
+		page  int
+		total int64
+		meta  string
+	}{
+		"first page":  {page: 1, total: 25, meta: `{"page":1,"per_page":10,"total":25,"total_pages":3,"has_next":true}`},
+		"last page":   {page: 3, total: 25, meta: `{"page":3,"per_page":10,"total":25,"total_pages":3,"has_next":false}`},
+		"exact pages": {page: 2, total: 20, meta: `{"page":2,"per_page":10,"total":20,"total_pages":2,"has_next":false}`},
+		"empty":       {page: 1, total: 0, meta: `{"page":1,"per_page":10,"total":0,"total_pages":0,"has_next":false}`},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			res := NewPaginatedResponse([]string{"a"}, Pagination{Page: tt.page, PerPage: 10}, tt.total, "trace-1")
+
+			body, err := json.Marshal(res)
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"success":true,"error_code":"","error_message":"","data":["a"],"trace_id":"trace-1","meta":`+tt.meta+`}`, string(body))
+		})
+	}
+}
+
+func TestWriteValidationError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodGet, "/products?page=0", nil)
+
+	_, err := FromGinQuery(c, productDefaults)
+	WriteError(c, err, "trace-1")
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.JSONEq(t, `{"success":false,"error_code":"BAD_REQUEST","error_message":"validation failed","trace_id":"trace-1",
+		"data":{"errors":[{"field_name":"page","code":"ER0101","message":"page must be an integer greater than 0"}]}}`, rec.Body.String())
+}
@@ -0,0 +1,159 @@
+package payload
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/a-aslani/wotop/model/apperror"
+	"github.com/gin-gonic/gin"
+)
+
+// ProblemContentType is the media type of the RFC 7807 problem details.
+const ProblemContentType = "application/problem+json"
+
+// ProblemTypeBaseURI prefixes the apperror code to build the type of a problem, e.g.
+// "urn:problem-type:ER1092". Applications documenting their errors set it to their docs URL.
+var ProblemTypeBaseURI = "urn:problem-type:"
+
+// errorModeKey is the key of the ErrorMode in the Gin context.
+const errorModeKey = "payload.ErrorMode"
+
+// ErrorMode selects the format of the error responses written by WriteError.
+type ErrorMode int
+
+const (
+	// LegacyErrors writes the Response envelope, it is the default.
+	LegacyErrors ErrorMode = iota
+	// ProblemErrors writes RFC 7807 problem details.
+	ProblemErrors
+	// NegotiatedErrors writes problem details to the clients accepting application/problem+json
+	// and the Response envelope to the others.
+	NegotiatedErrors
+)
+
+// Problem is an RFC 7807 problem details object.
+//
+// Fields:
+//   - Type: A URI identifying the problem type, built from the apperror code, "about:blank" without code.
+//   - Title: The summary of the problem, the text of the HTTP status.
+//   - Status: The HTTP status.
+//   - Detail: The message of the error.
+//   - Instance: A URI identifying the occurrence, usually the request path.
+//   - Code: The apperror code, an extension member.
+//   - TraceID: A unique identifier for tracing the request, an extension member.
+//   - InvalidParams: The invalid fields of a validation error, an extension member.
+type Problem struct {
+	Type          string         `json:"type"`
+	Title         string         `json:"title"`
+	Status        int            `json:"status"`
+	Detail        string         `json:"detail,omitempty"`
+	Instance      string         `json:"instance,omitempty"`
+	Code          string         `json:"code,omitempty"`
+	TraceID       string         `json:"trace_id,omitempty"`
+	InvalidParams []InvalidParam `json:"invalid-params,omitempty"`
+}
+
+// InvalidParam is an invalid field of a validation problem.
+//
+// Fields:
+//   - Name: The name of the field.
+//   - Reason: The message of the validation error.
+//   - Code: The apperror code of the validation error.
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+	Code   string `json:"code,omitempty"`
+}
+
+// NewProblemResponse creates the RFC 7807 problem details of an error.
+//
+// Parameters:
+//   - err: The error to answer.
+//   - traceID: A unique identifier for tracing the request.
+//   - instance: The URI of the occurrence, e.g. the request path.
+//
+// Returns:
+//   - A Problem with the status resolved by ErrorStatus.
+func NewProblemResponse(err error, traceID string, instance string) any {
+	status := ErrorStatus(err)
+
+	p := Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   err.Error(),
+		Instance: instance,
+		TraceID:  traceID,
+	}
+
+	var ve *ValidationError
+	if errors.As(err, &ve) {
+		p.Detail = "validation failed"
+		for _, m := range ve.Messages {
+			p.InvalidParams = append(p.InvalidParams, InvalidParam{Name: m.FieldName, Reason: m.Message, Code: m.Code})
+		}
+		return p
+	}
+
+	var et apperror.ErrorType
+	if errors.As(err, &et) && et.Code() != "" {
+		p.Type = ProblemTypeBaseURI + et.Code()
+		p.Code = et.Code()
+		p.Detail = et.Error()
+	}
+
+	return p
+}
+
+// UseErrorMode returns a middleware selecting the format of the errors written by WriteError
+// for the routes it is used on, e.g. router.Use(payload.UseErrorMode(payload.NegotiatedErrors)).
+//
+// Parameters:
+//   - mode: The format of the error responses.
+//
+// Returns:
+//   - A Gin handler function.
+func UseErrorMode(mode ErrorMode) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(errorModeKey, mode)
+		c.Next()
+	}
+}
+
+// WriteProblem writes the problem details of err with the application/problem+json content type.
+//
+// Parameters:
+//   - c: The Gin context of the request.
+//   - err: The error to answer.
+//   - traceID: A unique identifier for tracing the request.
+func WriteProblem(c *gin.Context, err error, traceID string) {
+	p := NewProblemResponse(err, traceID, c.Request.URL.Path).(Problem)
+	c.Header("Content-Type", ProblemContentType)
+	c.JSON(p.Status, p)
+}
+
+// wantsProblem reports whether the error of the request must be written as problem details.
+func wantsProblem(c *gin.Context) bool {
+	v, _ := c.Get(errorModeKey)
+	mode, _ := v.(ErrorMode)
+	switch mode {
+	case ProblemErrors:
+		return true
+	case NegotiatedErrors:
+		return acceptsProblem(c.GetHeader("Accept"))
+	default:
+		return false
+	}
+}
+
+// acceptsProblem reports whether the Accept header lists application/problem+json.
+func acceptsProblem(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, _ := strings.Cut(part, ";")
+		if strings.EqualFold(strings.TrimSpace(mediaType), ProblemContentType) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package payload
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// serveError answers GET /orders/o-1 with err in the given mode.
+func serveError(mode ErrorMode, accept string, err error) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(UseErrorMode(mode))
+	router.GET("/orders/:id", func(c *gin.Context) {
+		WriteError(c, err, "trace-1")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/orders/o-1", nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestWriteErrorProblemMode(t *testing.T) {
+	tests := map[string]struct {
+		err  error
+		body string
+	}{
+		"mapped": {
+			err: errOrderNotFound.Var("o-1"),
+			body: `{"type":"urn:problem-type:ER8404","title":"Not Found","status":404,"detail":"order o-1 is not found",
+				"instance":"/orders/o-1","code":"ER8404","trace_id":"trace-1"}`,
+		},
+		"unmapped": {
+			err: errors.New("connection refused"),
+			body: `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"connection refused",
+				"instance":"/orders/o-1","trace_id":"trace-1"}`,
+		},
+		"validation": {
+			err: &ValidationError{Messages: []Message{
+				{FieldName: "page", Code: "ER0101", Message: "page must be an integer greater than 0"},
+				{FieldName: "order", Code: "ER0104", Message: "order must be asc or desc"},
+			}},
+			body: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"validation failed",
+				"instance":"/orders/o-1","trace_id":"trace-1","invalid-params":[
+				{"name":"page","reason":"page must be an integer greater than 0","code":"ER0101"},
+				{"name":"order","reason":"order must be asc or desc","code":"ER0104"}]}`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rec := serveError(ProblemErrors, "", tt.err)
+
+			assert.Equal(t, ProblemContentType, rec.Header().Get("Content-Type"))
+			assert.JSONEq(t, tt.body, rec.Body.String())
+		})
+	}
+}
+
+func TestWriteErrorLegacyMode(t *testing.T) {
+	rec := serveError(LegacyErrors, ProblemContentType, errOrderNotFound.Var("o-1"))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "application/json; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"success":false,"error_code":"ER8404","error_message":"order o-1 is not found","data":null,"trace_id":"trace-1"}`, rec.Body.String())
+}
+
+func TestWriteErrorNegotiatedMode(t *testing.T) {
+	tests := map[string]struct {
+		accept      string
+		contentType string
+	}{
+		"problem":             {accept: "application/problem+json", contentType: ProblemContentType},
+		"problem with params": {accept: "application/json;q=0.9, application/problem+json;q=1", contentType: ProblemContentType},
+		"json":                {accept: "application/json", contentType: "application/json; charset=utf-8"},
+		"anything":            {accept: "*/*", contentType: "application/json; charset=utf-8"},
+		"no accept header":    {accept: "", contentType: "application/json; charset=utf-8"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rec := serveError(NegotiatedErrors, tt.accept, errOrderNotFound.Var("o-1"))
+
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+			assert.Equal(t, tt.contentType, rec.Header().Get("Content-Type"))
+		})
+	}
+}
@@ -3,6 +3,8 @@ package payload
 import (
 	"errors"
 	"github.com/a-aslani/wotop/model/apperror"
+	"github.com/gin-gonic/gin"
+	"net/http"
 )
 
 // Response represents the structure of a standard API response.
@@ -13,12 +15,30 @@ import (
 //   - ErrorMessage: A message describing the error (if any).
 //   - Data: The data payload of the response.
 //   - TraceID: A unique identifier for tracing the request.
+//   - Meta: The meta block, the PageMeta of a list response, see NewPaginatedResponse, or the
+//     extras of NewSuccessResponseWithMeta.
+//   - Warnings: The warnings of a successful operation, e.g. deprecation notices.
+//   - Status: The HTTP status of an error response, it is not part of the body.
 type Response struct {
-	Success      bool   `json:"success"`
-	ErrorCode    string `json:"error_code"`
-	ErrorMessage string `json:"error_message"`
-	Data         any    `json:"data"`
-	TraceID      string `json:"trace_id"`
+	Success      bool     `json:"success"`
+	ErrorCode    string   `json:"error_code"`
+	ErrorMessage string   `json:"error_message"`
+	Data         any      `json:"data"`
+	TraceID      string   `json:"trace_id"`
+	Meta         any      `json:"meta,omitempty"`
+	Warnings     []string `json:"warnings,omitempty"`
+	Status       int      `json:"-"`
+}
+
+// Message represents a validation error message. Rule, Param and Value let the clients
+// build their own message, e.g. in the language of the user, they are omitted when empty.
+type Message struct {
+	FieldName string `json:"field_name"`      // The name of the field that caused the error.
+	Code      string `json:"code"`            // The error code.
+	Message   string `json:"message"`         // The error message.
+	Rule      string `json:"rule,omitempty"`  // The name of the failed rule, e.g. "min".
+	Param     string `json:"param,omitempty"` // The parameter of the rule, e.g. "8".
+	Value     string `json:"value,omitempty"` // The rejected value, never set for the sensitive fields.
 }
 
 // NewSuccessResponse creates a new success response.
@@ -37,6 +57,25 @@ func NewSuccessResponse(data any, traceID string) any {
 	return res
 }
 
+// NewSuccessResponseWithMeta creates a new success response with a meta block, for the extras
+// of a response that are not part of its data, such as the remaining rate limit or the cursor
+// of the next page.
+//
+// Parameters:
+//   - data: The data payload to include in the response.
+//   - meta: The meta block of the response, left out of the body when empty.
+//   - traceID: A unique identifier for tracing the request.
+//
+// Returns:
+//   - A Response object with success set to true and the provided data, meta and trace ID.
+func NewSuccessResponseWithMeta(data any, meta map[string]any, traceID string) any {
+	res := NewSuccessResponse(data, traceID).(Response)
+	if len(meta) > 0 {
+		res.Meta = meta
+	}
+	return res
+}
+
 // NewErrorResponse creates a new error response.
 //
 // Parameters:
@@ -44,11 +83,22 @@ func NewSuccessResponse(data any, traceID string) any {
 //   - traceID: A unique identifier for tracing the request.
 //
 // Returns:
-//   - A Response object with success set to false, the error code, error message, and trace ID.
+//   - A Response object with success set to false, the error code, error message, trace ID
+//     and the HTTP status resolved by ErrorStatus.
 func NewErrorResponse(err error, traceID string) any {
 	var res Response
 	res.Success = false
 	res.TraceID = traceID
+	res.Status = ErrorStatus(err)
+
+	var ve *ValidationError
+	if errors.As(err, &ve) {
+		messages := make([]any, len(ve.Messages))
+		for i, m := range ve.Messages {
+			messages[i] = m
+		}
+		return NewValidationErrorResponse(messages, traceID)
+	}
 
 	var et apperror.ErrorType
 	ok := errors.As(err, &et)
@@ -77,6 +127,7 @@ func NewValidationErrorResponse(messages []any, traceID string) any {
 	res.Success = false
 	res.TraceID = traceID
 
+	res.Status = http.StatusBadRequest
 	res.ErrorCode = "BAD_REQUEST"
 	res.ErrorMessage = "validation failed"
 
@@ -86,3 +137,35 @@ func NewValidationErrorResponse(messages []any, traceID string) any {
 
 	return res
 }
+
+// ErrorStatus resolves the HTTP status of an error from its category, see apperror.HTTPStatus.
+//
+// Parameters:
+//   - err: The error to answer.
+//
+// Returns:
+//   - The status attached to or mapped for the error, or 500 when it has none.
+func ErrorStatus(err error) int {
+	if status, ok := apperror.HTTPStatus(err); ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// WriteError writes the error response of err with the status resolved by ErrorStatus.
+// The response is the Response envelope, or problem details when the ErrorMode of the route,
+// see UseErrorMode, asks for them.
+//
+// Parameters:
+//   - c: The Gin context of the request.
+//   - err: The error to answer.
+//   - traceID: A unique identifier for tracing the request.
+func WriteError(c *gin.Context, err error, traceID string) {
+	if wantsProblem(c) {
+		WriteProblem(c, err, traceID)
+		return
+	}
+
+	res := NewErrorResponse(err, traceID).(Response)
+	c.JSON(res.Status, res)
+}
@@ -0,0 +1,110 @@
+package payload
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/a-aslani/wotop/model/apperror"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	errOrderNotFound apperror.ErrorType = "ER8404 order %s is not found"
+	errInvalidState  apperror.ErrorType = "ER8400 order is not in a valid state"
+)
+
+func init() {
+	apperror.MapCode("ER8404", http.StatusNotFound)
+}
+
+func TestWriteError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := map[string]struct {
+		err     error
+		status  int
+		code    string
+		message string
+	}{
+		"mapped":   {err: errOrderNotFound.Var("o-1"), status: http.StatusNotFound, code: "ER8404", message: "order o-1 is not found"},
+		"attached": {err: errInvalidState.WithStatus(http.StatusBadRequest), status: http.StatusBadRequest, code: "ER8400", message: "order is not in a valid state"},
+		"wrapped":  {err: fmt.Errorf("cancel: %w", errOrderNotFound.Var("o-1")), status: http.StatusNotFound, code: "ER8404", message: "order o-1 is not found"},
+		"unmapped": {err: errors.New("connection refused"), status: http.StatusInternalServerError, code: "UNDEFINED", message: "connection refused"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(rec)
+
+			WriteError(c, tt.err, "trace-1")
+
+			assert.Equal(t, tt.status, rec.Code)
+
+			var body map[string]any
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, map[string]any{
+				"success":       false,
+				"error_code":    tt.code,
+				"error_message": tt.message,
+				"data":          nil,
+				"trace_id":      "trace-1",
+			}, body)
+		})
+	}
+}
+
+func TestNewErrorResponseStatus(t *testing.T) {
+	res := NewErrorResponse(errOrderNotFound.Var("o-1"), "trace-1").(Response)
+	assert.Equal(t, http.StatusNotFound, res.Status)
+
+	res = NewValidationErrorResponse(nil, "trace-1").(Response)
+	assert.Equal(t, http.StatusBadRequest, res.Status)
+}
+
+func TestNewValidationErrorResponseMessages(t *testing.T) {
+	body, err := json.Marshal(NewValidationErrorResponse([]any{
+		Message{FieldName: "password", Code: "ER0008", Message: "the length of password must be 8 characters or longer. You entered 3 characters", Rule: "min", Param: "8"},
+		Message{FieldName: "page", Code: "ER0101", Message: "page must be an integer greater than 0"},
+	}, "trace-1"))
+	require.NoError(t, err)
+
+	// the fields of the rule are added to the message, they are omitted when empty
+	assert.JSONEq(t, `{
+		"success": false,
+		"error_code": "BAD_REQUEST",
+		"error_message": "validation failed",
+		"trace_id": "trace-1",
+		"data": {"errors": [
+			{"field_name": "password", "code": "ER0008", "message": "the length of password must be 8 characters or longer. You entered 3 characters", "rule": "min", "param": "8"},
+			{"field_name": "page", "code": "ER0101", "message": "page must be an integer greater than 0"}
+		]}
+	}`, string(body))
+}
+
+func TestNewSuccessResponseWithMeta(t *testing.T) {
+	res := NewSuccessResponseWithMeta([]string{"p-1"}, map[string]any{"next_cursor": "c-2", "rate_limit_remaining": 42}, "trace-1").(Response)
+	res.Warnings = []string{"the category filter is deprecated, use categories"}
+
+	body, err := json.Marshal(res)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"success": true,
+		"error_code": "",
+		"error_message": "",
+		"data": ["p-1"],
+		"trace_id": "trace-1",
+		"meta": {"next_cursor": "c-2", "rate_limit_remaining": 42},
+		"warnings": ["the category filter is deprecated, use categories"]
+	}`, string(body))
+
+	body, err = json.Marshal(NewSuccessResponseWithMeta("ok", nil, "trace-1"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"success":true,"error_code":"","error_message":"","data":"ok","trace_id":"trace-1"}`, string(body))
+}
@@ -0,0 +1,130 @@
+package openapi
+
+// Version is the version of the OpenAPI specification the documents follow.
+const Version = "3.0.3"
+
+// Document is an OpenAPI document, the subset of the specification the routes are described with.
+//
+// Fields:
+//   - OpenAPI: The version of the specification, Version.
+//   - Info: The title and the version of the API.
+//   - Paths: The operations keyed by path, e.g. "/v1/orders/{id}".
+//   - Components: The schemas of the named request and response types.
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components *Components         `json:"components,omitempty"`
+}
+
+// Info describes the API.
+//
+// Fields:
+//   - Title: The name of the API, usually the name of the application.
+//   - Version: The version of the API.
+//   - Description: What the API does, CommonMark is allowed.
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem is the operations of a path keyed by lowercase method, e.g. "get".
+type PathItem map[string]*Operation
+
+// Operation describes a route.
+//
+// Fields:
+//   - OperationID: A unique name of the operation, e.g. "putV1Orders".
+//   - Summary: A short summary of what the operation does.
+//   - Description: A longer explanation of the operation.
+//   - Tags: The groups the operation is listed under in the Swagger UI.
+//   - Parameters: The path and query parameters.
+//   - RequestBody: The JSON body, nil for the operations reading the query.
+//   - Responses: The responses keyed by HTTP status.
+type Operation struct {
+	OperationID string               `json:"operationId,omitempty"`
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Parameters  []Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter is a path or query parameter.
+//
+// Fields:
+//   - Name: The name of the parameter.
+//   - In: "path" or "query".
+//   - Required: Whether the parameter must be given, always true for the path parameters.
+//   - Schema: The schema of the value.
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody is the body of an operation.
+//
+// Fields:
+//   - Required: Whether the body must be given.
+//   - Content: The schemas keyed by media type.
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// MediaType is the schema of a body of a media type.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Response is a response of an operation.
+//
+// Fields:
+//   - Description: What the response means, the errors it carries for the error responses.
+//   - Content: The schemas keyed by media type.
+//   - ErrorCodes: The apperror codes the response may carry, an extension.
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+	ErrorCodes  []string             `json:"x-error-codes,omitempty"`
+}
+
+// Components holds the reusable schemas.
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+// Schema is a JSON schema, the subset of the OpenAPI schema object generated from Go types.
+//
+// Fields:
+//   - Ref: A reference to a schema of the components, the other fields are empty then.
+//   - Type: "object", "array", "string", "integer", "number" or "boolean", empty for any value.
+//   - Format: The format of the value, e.g. "date-time", "email" or "int64".
+//   - Properties: The properties of an object.
+//   - Required: The required properties of an object, from the required rule of the validate tag.
+//   - Items: The schema of the items of an array.
+//   - AdditionalProperties: The schema of the values of a map.
+//   - MinLength: The minimum length of a string, from the min and len rules.
+//   - MaxLength: The maximum length of a string, from the max and len rules.
+//   - Pattern: The regular expression a string matches, from the digits and numeric rules.
+//   - PasswordStrength: The minimum strength of a password, from the password_strength rule, an extension.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	Default              any                `json:"default,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	PasswordStrength     *int               `json:"x-password-strength,omitempty"`
+}
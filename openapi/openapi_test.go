@@ -0,0 +1,212 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop/model/apperror"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	errOutOfStock    apperror.ErrorType = "ER1901 product %s is out of stock"
+	errOrderNotFound apperror.ErrorType = "ER1902 order %s not found"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+
+	apperror.Register("openapi_test",
+		apperror.Entry{Err: errOutOfStock, Description: "The product cannot be ordered."},
+		apperror.Entry{Err: errOrderNotFound, Description: "No order has the ID."},
+	)
+	apperror.MapCode(errOutOfStock.Code(), http.StatusConflict)
+	apperror.MapCode(errOrderNotFound.Code(), http.StatusNotFound)
+}
+
+type Address struct {
+	City    string `json:"city" validate:"required,max:64"`
+	ZipCode string `json:"zip_code" validate:"digits,len:5"`
+}
+
+type Audit struct {
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type PlaceOrderRequest struct {
+	Audit
+	Email    string            `json:"email" validate:"required,email"`
+	Password string            `json:"password" validate:"password_strength:3"`
+	Quantity int               `json:"quantity" validate:"required"`
+	Price    string            `json:"price" validate:"numeric:decimal"`
+	Note     string            `json:"note,omitempty" validate:"min:3,max:280"`
+	Items    []string          `json:"items"`
+	Address  *Address          `json:"address"`
+	Labels   map[string]string `json:"labels"`
+	Secret   string            `json:"-"`
+	internal string
+}
+
+type PlaceOrderResponse struct {
+	OrderID string `json:"order_id"`
+}
+
+type GetOrderRequest struct {
+	ID     string `uri:"id" validate:"len:26"`
+	Expand bool   `form:"expand"`
+	Fields string `form:"fields" validate:"required"`
+	Limit  int    `form:"limit" default:"20" validate:"min:1,max:100"`
+}
+
+func intPtr(n int) *int { return &n }
+
+func floatPtr(n float64) *float64 { return &n }
+
+func TestSchemaOfRequestWithValidateTags(t *testing.T) {
+	schema, components := Of(PlaceOrderRequest{})
+
+	assert.Equal(t, &Schema{Ref: "#/components/schemas/PlaceOrderRequest"}, schema)
+	require.Contains(t, components, "PlaceOrderRequest")
+	require.Contains(t, components, "Address")
+
+	request := components["PlaceOrderRequest"]
+	assert.Equal(t, "object", request.Type)
+	assert.Equal(t, []string{"email", "quantity"}, request.Required)
+	assert.ElementsMatch(t, []string{"created_at", "email", "password", "quantity", "price", "note", "items", "address", "labels"}, keys(request.Properties))
+
+	assert.Equal(t, &Schema{Type: "string", Format: "date-time"}, request.Properties["created_at"])
+	assert.Equal(t, &Schema{Type: "string", Format: "email"}, request.Properties["email"])
+	assert.Equal(t, &Schema{Type: "string", Format: "password", PasswordStrength: intPtr(3)}, request.Properties["password"])
+	assert.Equal(t, &Schema{Type: "integer", Format: "int64"}, request.Properties["quantity"])
+	assert.Equal(t, &Schema{Type: "string", Pattern: `^[0-9]+(\.[0-9]+)?$`}, request.Properties["price"])
+	assert.Equal(t, &Schema{Type: "string", MinLength: intPtr(3), MaxLength: intPtr(280)}, request.Properties["note"])
+	assert.Equal(t, &Schema{Type: "array", Items: &Schema{Type: "string"}}, request.Properties["items"])
+	assert.Equal(t, &Schema{Ref: "#/components/schemas/Address"}, request.Properties["address"])
+	assert.Equal(t, &Schema{Type: "object", AdditionalProperties: &Schema{Type: "string"}}, request.Properties["labels"])
+
+	address := components["Address"]
+	assert.Equal(t, []string{"city"}, address.Required)
+	assert.Equal(t, &Schema{Type: "string", MaxLength: intPtr(64)}, address.Properties["city"])
+	assert.Equal(t, &Schema{Type: "string", Pattern: "^[0-9]*$", MinLength: intPtr(5), MaxLength: intPtr(5)}, address.Properties["zip_code"])
+}
+
+func keys(m map[string]*Schema) []string {
+	var ks []string
+	for k := range m {
+		ks = append(ks, k)
+	}
+	return ks
+}
+
+// node is a recursive type, its schema references itself.
+type node struct {
+	Children []node `json:"children"`
+}
+
+func TestSchemaOfRecursiveType(t *testing.T) {
+	_, components := Of(node{})
+
+	require.Contains(t, components, "node")
+	assert.Equal(t, &Schema{Ref: "#/components/schemas/node"}, components["node"].Properties["children"].Items)
+}
+
+func newTestAPI() (*gin.Engine, *API) {
+	engine := gin.New()
+	api := New(Info{Title: "Shop <API>", Version: "v1"})
+	v1 := api.Router(engine).Group("/v1")
+
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+
+	v1.Route(http.MethodPost, "/orders").
+		Summary("Place an order").
+		Tags("orders").
+		Request(PlaceOrderRequest{}).
+		Response(PlaceOrderResponse{}).
+		Errors(errOutOfStock).
+		Handle(ok)
+
+	v1.Route(http.MethodGet, "/orders/:id").
+		Request(GetOrderRequest{}).
+		Response(PlaceOrderResponse{}).
+		Errors(errOrderNotFound).
+		Handle(ok)
+
+	api.Serve(engine)
+	return engine, api
+}
+
+func TestRouteRegistersHandlerAndOperation(t *testing.T) {
+	engine, api := newTestAPI()
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/orders/42", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	doc := api.Document()
+	assert.Equal(t, Version, doc.OpenAPI)
+
+	place := doc.Paths["/v1/orders"]["post"]
+	require.NotNil(t, place)
+	assert.Equal(t, "postV1Orders", place.OperationID)
+	assert.Equal(t, "Place an order", place.Summary)
+	assert.Equal(t, []string{"orders"}, place.Tags)
+	assert.Equal(t, &Schema{Ref: "#/components/schemas/PlaceOrderRequest"}, place.RequestBody.Content["application/json"].Schema)
+	assert.Equal(t, &Schema{Ref: "#/components/schemas/PlaceOrderResponse"}, place.Responses["200"].Content["application/json"].Schema.Properties["data"])
+	assert.Contains(t, place.Responses, "400")
+	assert.Equal(t, []string{"ER1901"}, place.Responses["409"].ErrorCodes)
+	assert.Contains(t, place.Responses["409"].Description, "The product cannot be ordered.")
+
+	get := doc.Paths["/v1/orders/{id}"]["get"]
+	require.NotNil(t, get)
+	assert.Equal(t, "getV1OrdersById", get.OperationID)
+	assert.Nil(t, get.RequestBody)
+	assert.Equal(t, []Parameter{
+		{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "string", MinLength: intPtr(26), MaxLength: intPtr(26)}},
+		{Name: "expand", In: "query", Schema: &Schema{Type: "boolean"}},
+		{Name: "fields", In: "query", Required: true, Schema: &Schema{Type: "string"}},
+		{Name: "limit", In: "query", Schema: &Schema{Type: "integer", Format: "int64", Minimum: floatPtr(1), Maximum: floatPtr(100), Default: float64(20)}},
+	}, get.Parameters)
+	assert.Equal(t, []string{"ER1902"}, get.Responses["404"].ErrorCodes)
+}
+
+func TestServeDocumentAndSwaggerUI(t *testing.T) {
+	engine, _ := newTestAPI()
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, "3.0.3", doc["openapi"])
+	assert.Contains(t, doc["paths"], "/v1/orders")
+	assert.Contains(t, doc["components"].(map[string]any)["schemas"], "PlaceOrderRequest")
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "<title>Shop &lt;API&gt;</title>")
+	assert.Contains(t, w.Body.String(), `url: "openapi.json"`)
+}
+
+func TestConvertPath(t *testing.T) {
+	for ginPath, want := range map[string]string{
+		"/":                       "/",
+		"/v1/orders":              "/v1/orders",
+		"/v1/orders/:id/items/":   "/v1/orders/{id}/items/",
+		"/files/*path":            "/files/{path}",
+		"/users/:user_id/profile": "/users/{user_id}/profile",
+	} {
+		got, _ := convertPath(ginPath)
+		assert.Equal(t, want, got, ginPath)
+	}
+
+	assert.Equal(t, "/v1/orders", joinPaths("/v1", "/orders"))
+	assert.Equal(t, "/orders/", joinPaths("/", "/orders/"))
+	assert.Equal(t, "/", joinPaths("/", ""))
+}
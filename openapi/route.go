@@ -0,0 +1,494 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/a-aslani/wotop/model/apperror"
+	"github.com/gin-gonic/gin"
+)
+
+// Routes is the Gin router the routes are registered on, *gin.Engine and *gin.RouterGroup
+// implement it.
+type Routes interface {
+	gin.IRouter
+	BasePath() string
+}
+
+// API is the OpenAPI document of the routes registered with its routers.
+type API struct {
+	mu      sync.RWMutex
+	doc     Document
+	schemas *schemas
+}
+
+// New creates an API without routes.
+//
+// Parameters:
+//   - info: The title and the version of the API.
+//
+// Returns:
+//   - The API.
+func New(info Info) *API {
+	return &API{
+		doc:     Document{OpenAPI: Version, Info: info, Paths: map[string]PathItem{}},
+		schemas: newSchemas(),
+	}
+}
+
+// Router returns a router registering its routes on a Gin router and documenting them in the API.
+//
+// Parameters:
+//   - routes: The Gin engine or group.
+//
+// Returns:
+//   - The router.
+func (a *API) Router(routes Routes) *Router {
+	return &Router{api: a, routes: routes}
+}
+
+// NewRouter creates an API and returns its router on a Gin router, see API.Router.
+//
+// Parameters:
+//   - routes: The Gin engine or group.
+//   - info: The title and the version of the API.
+//
+// Returns:
+//   - The router.
+func NewRouter(routes Routes, info Info) *Router {
+	return New(info).Router(routes)
+}
+
+// Document returns a copy of the document, safe to marshal while routes are registered.
+//
+// Returns:
+//   - The document.
+func (a *API) Document() Document {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	doc := a.doc
+	doc.Paths = make(map[string]PathItem, len(a.doc.Paths))
+	for p, item := range a.doc.Paths {
+		doc.Paths[p] = make(PathItem, len(item))
+		for method, op := range item {
+			doc.Paths[p][method] = op
+		}
+	}
+	if len(a.schemas.components) > 0 {
+		doc.Components = &Components{Schemas: make(map[string]*Schema, len(a.schemas.components))}
+		for name, s := range a.schemas.components {
+			doc.Components.Schemas[name] = s
+		}
+	}
+	return doc
+}
+
+// Handler serves the document as JSON.
+//
+// Returns:
+//   - The Gin handler.
+func (a *API) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, a.Document())
+	}
+}
+
+// SwaggerUI serves a Swagger UI page rendering the document served at a URL. The page loads
+// the Swagger UI assets from a CDN.
+//
+// Parameters:
+//   - specURL: The URL of the document, relative to the page or absolute, e.g. "openapi.json".
+//
+// Returns:
+//   - The Gin handler.
+func (a *API) SwaggerUI(specURL string) gin.HandlerFunc {
+	// the URL is written as a JSON string, which escapes the characters closing the script
+	url, _ := json.Marshal(specURL)
+	page := fmt.Sprintf(swaggerUIPage, html.EscapeString(a.doc.Info.Title), url)
+
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(page))
+	}
+}
+
+// Serve registers GET /openapi.json serving the document and GET /docs serving the Swagger UI.
+//
+// Parameters:
+//   - routes: The Gin engine or group the routes are registered on, e.g. the group of the proxy path.
+func (a *API) Serve(routes gin.IRoutes) {
+	routes.GET("/openapi.json", a.Handler())
+	routes.GET("/docs", a.SwaggerUI("openapi.json"))
+}
+
+// swaggerUIPage is the page of SwaggerUI, formatted with the title and the URL of the document.
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>%s</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>window.ui = SwaggerUIBundle({url: %s, dom_id: "#swagger-ui"});</script>
+</body>
+</html>
+`
+
+// Router registers the routes of a Gin router and documents them in its API.
+type Router struct {
+	api    *API
+	routes Routes
+}
+
+// API returns the API the routes are documented in.
+//
+// Returns:
+//   - The API.
+func (r *Router) API() *API {
+	return r.api
+}
+
+// Group creates a router on a Gin group of the router, documenting its routes in the same API.
+//
+// Parameters:
+//   - relativePath: The path of the group, relative to the router.
+//   - handlers: The middlewares of the group.
+//
+// Returns:
+//   - The router of the group.
+func (r *Router) Group(relativePath string, handlers ...gin.HandlerFunc) *Router {
+	return &Router{api: r.api, routes: r.routes.Group(relativePath, handlers...)}
+}
+
+// Route starts the description of a route, it is registered by Route.Handle, e.g.
+//
+//	r.Route(http.MethodPost, "/orders").
+//		Summary("Place an order").
+//		Request(place_order.InportRequest{}).
+//		Response(place_order.InportResponse{}).
+//		Errors(ErrOutOfStock).
+//		Handle(r.placeOrderHandler())
+//
+// Parameters:
+//   - method: The HTTP method, e.g. http.MethodPost.
+//   - path: The Gin path of the route, relative to the router, e.g. "/orders/:id".
+//
+// Returns:
+//   - The route.
+func (r *Router) Route(method, path string) *Route {
+	return &Route{router: r, method: strings.ToUpper(method), path: path}
+}
+
+// Route is a route being described, see Router.Route.
+type Route struct {
+	router      *Router
+	method      string
+	path        string
+	operationID string
+	summary     string
+	description string
+	tags        []string
+	request     reflect.Type
+	response    reflect.Type
+	errors      []apperror.ErrorType
+}
+
+// OperationID sets the ID of the operation, derived from the method and the path by default.
+func (r *Route) OperationID(id string) *Route {
+	r.operationID = id
+	return r
+}
+
+// Summary sets the short summary of the route.
+func (r *Route) Summary(summary string) *Route {
+	r.summary = summary
+	return r
+}
+
+// Description sets the explanation of the route.
+func (r *Route) Description(description string) *Route {
+	r.description = description
+	return r
+}
+
+// Tags sets the groups the route is listed under.
+func (r *Route) Tags(tags ...string) *Route {
+	r.tags = append(r.tags, tags...)
+	return r
+}
+
+// Request sets the type the request is bound to. The fields are read from the query for GET
+// and DELETE, with their form tag, and from the JSON body otherwise. The fields with a uri tag
+// are the path parameters. The validate tags become the constraints of the schema.
+//
+// Parameters:
+//   - v: A value of the request type, e.g. place_order.InportRequest{}.
+//
+// Returns:
+//   - The route.
+func (r *Route) Request(v any) *Route {
+	r.request = reflect.TypeOf(v)
+	return r
+}
+
+// Response sets the type of the data of the success response, which is wrapped in the
+// payload.Response envelope.
+//
+// Parameters:
+//   - v: A value of the response type, e.g. place_order.InportResponse{}.
+//
+// Returns:
+//   - The route.
+func (r *Route) Response(v any) *Route {
+	r.response = reflect.TypeOf(v)
+	return r
+}
+
+// Errors documents the errors the route answers with. They are grouped by the HTTP status
+// they are mapped to, see apperror.MapCode, 500 for the unmapped ones, and described with
+// their entry of the apperror catalog.
+//
+// Parameters:
+//   - errs: The errors, as declared.
+//
+// Returns:
+//   - The route.
+func (r *Route) Errors(errs ...apperror.ErrorType) *Route {
+	r.errors = append(r.errors, errs...)
+	return r
+}
+
+// Handle registers the handlers of the route on the Gin router and adds its operation to the
+// document of the API.
+//
+// Parameters:
+//   - handlers: The handlers of the route, the last one answering the request.
+func (r *Route) Handle(handlers ...gin.HandlerFunc) {
+	r.router.routes.Handle(r.method, r.path, handlers...)
+
+	fullPath := joinPaths(r.router.routes.BasePath(), r.path)
+	specPath, pathParams := convertPath(fullPath)
+
+	api := r.router.api
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	op := r.operation(api.schemas, specPath, pathParams)
+	if api.doc.Paths[specPath] == nil {
+		api.doc.Paths[specPath] = PathItem{}
+	}
+	api.doc.Paths[specPath][strings.ToLower(r.method)] = op
+}
+
+// operation builds the operation of the route, the schemas of its types are added to s.
+func (r *Route) operation(s *schemas, specPath string, pathParams []string) *Operation {
+	op := &Operation{
+		OperationID: r.operationID,
+		Summary:     r.summary,
+		Description: r.description,
+		Tags:        r.tags,
+		Responses:   map[string]*Response{},
+	}
+	if op.OperationID == "" {
+		op.OperationID = operationID(r.method, specPath)
+	}
+
+	op.Parameters = r.parameters(s, pathParams)
+
+	if r.request != nil && r.method != http.MethodGet && r.method != http.MethodDelete {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{"application/json": {Schema: s.schema(r.request)}},
+		}
+	}
+
+	success := &Response{Description: "OK", Content: map[string]MediaType{
+		"application/json": {Schema: envelope(s.schema(r.response))},
+	}}
+	op.Responses[strconv.Itoa(http.StatusOK)] = success
+
+	if r.request != nil {
+		op.Responses[strconv.Itoa(http.StatusBadRequest)] = &Response{
+			Description: "The request is malformed or has invalid fields.",
+			Content:     map[string]MediaType{"application/json": {Schema: envelope(nil)}},
+		}
+	}
+	r.errorResponses(op.Responses)
+
+	return op
+}
+
+// parameters returns the path parameters, typed with the fields of the request with a uri
+// tag, and the query parameters of the GET and DELETE requests.
+func (r *Route) parameters(s *schemas, pathParams []string) []Parameter {
+	var params []Parameter
+
+	fields := map[string]reflect.StructField{}
+	var query []reflect.StructField
+	if r.request != nil && indirect(r.request).Kind() == reflect.Struct {
+		for _, f := range reflect.VisibleFields(indirect(r.request)) {
+			if !f.IsExported() || f.Anonymous {
+				continue
+			}
+			if name := tagName(f, "uri"); name != "" {
+				fields[name] = f
+			} else if name := tagName(f, "form"); name != "-" {
+				query = append(query, f)
+			}
+		}
+	}
+
+	for _, name := range pathParams {
+		schema := &Schema{Type: "string"}
+		if f, ok := fields[name]; ok {
+			schema = s.schema(f.Type)
+			applyRules(schema, f.Tag.Get("validate"))
+		}
+		params = append(params, Parameter{Name: name, In: "path", Required: true, Schema: schema})
+	}
+
+	if r.method != http.MethodGet && r.method != http.MethodDelete {
+		return params
+	}
+	for _, f := range query {
+		name := tagName(f, "form")
+		if name == "" {
+			name = f.Name
+		}
+		schema := s.schema(f.Type)
+		required := applyRules(schema, f.Tag.Get("validate"))
+		if def, ok := f.Tag.Lookup("default"); ok {
+			schema.Default = defaultValue(schema, def)
+		}
+		params = append(params, Parameter{Name: name, In: "query", Required: required, Schema: schema})
+	}
+	return params
+}
+
+// errorResponses adds the responses of the errors of the route, grouped by status.
+func (r *Route) errorResponses(responses map[string]*Response) {
+	if len(r.errors) == 0 {
+		return
+	}
+
+	catalog := map[string]apperror.CatalogEntry{}
+	for _, e := range apperror.DefaultRegistry.Catalog() {
+		catalog[e.Code] = e
+	}
+
+	byStatus := map[int][]apperror.ErrorType{}
+	for _, err := range r.errors {
+		status, ok := apperror.HTTPStatus(err)
+		if !ok {
+			status = http.StatusInternalServerError
+		}
+		byStatus[status] = append(byStatus[status], err)
+	}
+
+	for status, errs := range byStatus {
+		key := strconv.Itoa(status)
+		res := responses[key]
+		if res == nil {
+			res = &Response{Content: map[string]MediaType{"application/json": {Schema: envelope(nil)}}}
+			responses[key] = res
+		}
+
+		var lines []string
+		if res.Description != "" {
+			lines = append(lines, res.Description)
+		}
+		for _, err := range errs {
+			line := err.Code() + ": " + err.Error()
+			if e, ok := catalog[err.Code()]; ok && e.Description != "" {
+				line += " " + e.Description
+			}
+			lines = append(lines, line)
+			res.ErrorCodes = append(res.ErrorCodes, err.Code())
+		}
+		sort.Strings(res.ErrorCodes)
+		res.Description = strings.Join(lines, "\n\n")
+	}
+}
+
+// envelope returns the schema of the payload.Response envelope carrying the data of a schema,
+// or an error when it is nil.
+func envelope(data *Schema) *Schema {
+	env := &Schema{Type: "object", Properties: map[string]*Schema{
+		"success":       {Type: "boolean"},
+		"error_code":    {Type: "string"},
+		"error_message": {Type: "string"},
+		"trace_id":      {Type: "string"},
+	}}
+	if data != nil {
+		env.Properties["data"] = data
+	} else {
+		env.Properties["data"] = &Schema{}
+	}
+	return env
+}
+
+// tagName returns the name of a field in a tag, e.g. form or uri.
+func tagName(f reflect.StructField, key string) string {
+	name, _, _ := strings.Cut(f.Tag.Get(key), ",")
+	return name
+}
+
+// joinPaths joins the base path of a group with a relative path, keeping the trailing slash
+// of the relative path as Gin does.
+func joinPaths(base, relative string) string {
+	if relative == "" {
+		return base
+	}
+	joined := strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(relative, "/")
+	if !strings.HasSuffix(relative, "/") && len(joined) > 1 {
+		joined = strings.TrimSuffix(joined, "/")
+	}
+	return joined
+}
+
+// convertPath converts a Gin path to an OpenAPI path, ":id" and "*file" become "{id}" and
+// "{file}".
+//
+// Returns:
+//   - The OpenAPI path.
+//   - The names of the path parameters, in order.
+func convertPath(ginPath string) (string, []string) {
+	var params []string
+
+	segments := strings.Split(ginPath, "/")
+	for i, seg := range segments {
+		if len(seg) > 1 && (seg[0] == ':' || seg[0] == '*') {
+			params = append(params, seg[1:])
+			segments[i] = "{" + seg[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// operationID derives the ID of an operation from its method and its path, e.g.
+// "getV1OrdersById" for GET /v1/orders/{id}.
+func operationID(method, specPath string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+
+	for _, seg := range strings.Split(specPath, "/") {
+		if strings.HasPrefix(seg, "{") {
+			b.WriteString("By")
+			seg = strings.Trim(seg, "{}")
+		}
+		for _, word := range strings.FieldsFunc(seg, func(r rune) bool { return r == '-' || r == '_' || r == '.' }) {
+			b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return b.String()
+}
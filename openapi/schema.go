@@ -0,0 +1,305 @@
+package openapi
+
+import (
+	"encoding/json"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+
+	// unsafeName matches the characters a component name cannot hold, e.g. the brackets of a
+	// generic type.
+	unsafeName = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+)
+
+// schemas generates the schemas of Go types, the named struct types are added to the
+// components and referenced.
+type schemas struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+// newSchemas creates an empty schema generator.
+func newSchemas() *schemas {
+	return &schemas{components: map[string]*Schema{}, names: map[reflect.Type]string{}}
+}
+
+// Of returns the schema of the type of v, for the tests and the documents built by hand.
+//
+// Parameters:
+//   - v: A value of the type, e.g. CreateOrderRequest{}.
+//
+// Returns:
+//   - The schema of the type, a reference for the named struct types.
+//   - The schemas of the named struct types it references, keyed by name.
+func Of(v any) (*Schema, map[string]*Schema) {
+	s := newSchemas()
+	return s.schema(reflect.TypeOf(v)), s.components
+}
+
+// schema returns the schema of a type.
+func (s *schemas) schema(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + s.component(t)}
+	default:
+		// interfaces, e.g. any, hold any value
+		return &Schema{}
+	}
+}
+
+// component adds the schema of a named struct type to the components, once, and returns its
+// name. The types of different packages sharing a name are prefixed with their package.
+func (s *schemas) component(t reflect.Type) string {
+	if name, ok := s.names[t]; ok {
+		return name
+	}
+
+	name := unsafeName.ReplaceAllString(t.Name(), "_")
+	if _, taken := s.components[name]; taken {
+		name = path.Base(t.PkgPath()) + "." + name
+	}
+	for i := 2; ; i++ {
+		if _, taken := s.components[name]; !taken {
+			break
+		}
+		name = unsafeName.ReplaceAllString(t.Name(), "_") + strconv.Itoa(i)
+	}
+
+	// the name is reserved before the fields are generated, so recursive types reference it
+	s.names[t] = name
+	s.components[name] = &Schema{}
+	*s.components[name] = *s.object(t)
+	return name
+}
+
+// object returns the schema of the exported fields of a struct type. The fields of the
+// embedded structs without a json name are promoted, as encoding/json does.
+func (s *schemas) object(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || len(f.Index) > 1 && !promoted(t, f.Index) {
+			continue
+		}
+
+		name, ok := jsonName(f)
+		if !ok {
+			continue
+		}
+		if f.Anonymous && name == "" && indirect(f.Type).Kind() == reflect.Struct {
+			continue // its fields are visited on their own
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		property := s.schema(f.Type)
+		if applyRules(property, f.Tag.Get("validate")) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = property
+	}
+
+	return schema
+}
+
+// promoted reports whether the field at the index path is promoted through embedded structs
+// without a json name.
+func promoted(t reflect.Type, index []int) bool {
+	for i := 1; i < len(index); i++ {
+		f := t.FieldByIndex(index[:i])
+		if name, ok := jsonName(f); !ok || name != "" || !f.Anonymous {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonName returns the name of a field in the json tag, empty when the tag has none. The
+// second value is false for the fields encoding/json skips.
+func jsonName(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	return name, true
+}
+
+// indirect returns the type a pointer type points to.
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// applyRules sets the constraints of the rules of a validate tag on the schema of a field, the
+// rules validator.Validate checks on strings are only applied to the string schemas, and min and
+// max bound the value of the number schemas.
+//
+// Parameters:
+//   - schema: The schema of the field.
+//   - validateTag: The validate tag of the field, e.g. "required,max:64".
+//
+// Returns:
+//   - Whether the field is required.
+func applyRules(schema *Schema, validateTag string) bool {
+	required := false
+
+	for _, tagRule := range strings.Split(strings.TrimSpace(validateTag), ",") {
+		r := strings.Split(strings.TrimSpace(tagRule), ":")
+		param := ""
+		if len(r) > 1 {
+			param = strings.TrimSpace(r[1])
+		}
+
+		rule := strings.TrimSpace(r[0])
+		if rule == "required" {
+			required = true
+			continue
+		}
+		if schema.Type == "integer" || schema.Type == "number" {
+			applyBound(schema, rule, param)
+			continue
+		}
+		if schema.Type != "string" {
+			continue
+		}
+
+		switch rule {
+		case "email":
+			schema.Format = "email"
+		case "min":
+			if n, ok := lengthRuleParam(param, 1); ok {
+				schema.MinLength = &n
+			}
+		case "max":
+			if n, ok := lengthRuleParam(param, 1); ok {
+				schema.MaxLength = &n
+			}
+		case "len":
+			if n, err := strconv.Atoi(param); err == nil && n >= 0 {
+				schema.MinLength, schema.MaxLength = &n, &n
+			}
+		case "digits":
+			schema.Pattern = "^[0-9]*$"
+		case "numeric":
+			schema.Pattern = numericPattern(r[1:])
+		case "password_strength":
+			schema.Format = "password"
+			if n, err := strconv.Atoi(param); err == nil {
+				schema.PasswordStrength = &n
+			}
+		}
+	}
+
+	return required
+}
+
+// applyBound sets the bound of a min or max rule on the schema of a number, validator.Validate
+// checks the value of the numbers rather than their length.
+func applyBound(schema *Schema, rule, param string) {
+	if param == "" {
+		param = "1"
+	}
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+
+	switch rule {
+	case "min":
+		schema.Minimum = &n
+	case "max":
+		schema.Maximum = &n
+	}
+}
+
+// defaultValue converts the default tag of a parameter to the type of its schema, the default
+// is left out when it cannot be converted.
+func defaultValue(schema *Schema, def string) any {
+	switch schema.Type {
+	case "integer", "number":
+		if n, err := strconv.ParseFloat(def, 64); err == nil {
+			return n
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(def); err == nil {
+			return b
+		}
+	case "string":
+		return def
+	case "array":
+		return strings.Split(def, ",")
+	}
+	return nil
+}
+
+// lengthRuleParam parses the length of a min or max rule, def when it is empty.
+func lengthRuleParam(param string, def int) (int, bool) {
+	if param == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(param)
+	return n, err == nil
+}
+
+// numericPattern returns the pattern of the numbers accepted by a numeric rule with the
+// parameters "signed" and "decimal".
+func numericPattern(params []string) string {
+	sign, fraction := "", ""
+	for _, p := range params {
+		switch strings.TrimSpace(p) {
+		case "signed":
+			sign = "[+-]?"
+		case "decimal":
+			fraction = `(\.[0-9]+)?`
+		}
+	}
+	return "^" + sign + "[0-9]+" + fraction + "$"
+}
@@ -0,0 +1,244 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+var (
+	// ErrInvalidHash is returned when a hash is not in the encoded Argon2id format.
+	ErrInvalidHash = errors.New("the encoded hash is not in the correct format")
+	// ErrIncompatibleVersion is returned when a hash was produced by an unsupported Argon2 version.
+	ErrIncompatibleVersion = errors.New("incompatible version of argon2")
+)
+
+// maxArgon2Memory caps the memory parameter accepted from an encoded hash (1 GiB in KiB),
+// so a crafted hash cannot make CheckPasswordHash allocate an arbitrary amount of memory.
+const maxArgon2Memory = 1024 * 1024
+
+// Argon2Hashing implements the Hasher interface using Argon2id for password hashing and verification.
+// Hashes are encoded in the standard $argon2id$v=19$m=...,t=...,p=...$salt$hash format,
+// so every hash carries the parameters it was produced with.
+// Zero fields fall back to the values of NewArgon2Hashing, so the zero value is ready to use.
+type Argon2Hashing struct {
+	// Memory defines the amount of memory used by the algorithm in KiB.
+	Memory uint32
+	// Iterations defines the number of passes over the memory.
+	Iterations uint32
+	// Parallelism defines the number of threads used by the algorithm.
+	Parallelism uint8
+	// SaltLen defines the length of the random salt in bytes.
+	SaltLen uint32
+	// KeyLen defines the length of the generated key in bytes.
+	KeyLen uint32
+
+	peppers pepperSet
+}
+
+// NewArgon2Hashing creates an Argon2Hashing with sane default parameters.
+// Returns:
+// - Argon2Hashing: A hasher using 64 MiB of memory, 3 iterations, 2 threads, a 16 byte salt and a 32 byte key.
+func NewArgon2Hashing() Argon2Hashing {
+	return Argon2Hashing{
+		Memory:      64 * 1024,
+		Iterations:  3,
+		Parallelism: 2,
+		SaltLen:     16,
+		KeyLen:      32,
+	}
+}
+
+// WithPepper returns a copy of the hasher mixing the given secret into every password before hashing.
+// The password is replaced by its HMAC-SHA256 digest keyed by the secret. Peppers are versioned in
+// the order they are added, starting at 1, and the last one added is used for new hashes while the
+// previous ones still verify old hashes.
+// Parameters:
+// - secret: The server-side secret, which must not be stored next to the hashes.
+// Returns:
+// - Argon2Hashing: The peppered hasher.
+func (a Argon2Hashing) WithPepper(secret []byte) Argon2Hashing {
+	return a.WithPepperVersion(a.peppers.nextVersion(), secret)
+}
+
+// WithPepperVersion is like WithPepper but stores the hash under an explicit pepper version.
+// Parameters:
+// - version: The identifier stored in front of the hash, it must not contain "$".
+// - secret: The server-side secret, which must not be stored next to the hashes.
+// Returns:
+// - Argon2Hashing: The peppered hasher.
+func (a Argon2Hashing) WithPepperVersion(version string, secret []byte) Argon2Hashing {
+	a.peppers = a.peppers.with(version, secret)
+	return a
+}
+
+// withDefaults returns a copy of a where every zero field is replaced by its default value.
+func (a Argon2Hashing) withDefaults() Argon2Hashing {
+	d := NewArgon2Hashing()
+	if a.Memory == 0 {
+		a.Memory = d.Memory
+	}
+	if a.Iterations == 0 {
+		a.Iterations = d.Iterations
+	}
+	if a.Parallelism == 0 {
+		a.Parallelism = d.Parallelism
+	}
+	if a.SaltLen == 0 {
+		a.SaltLen = d.SaltLen
+	}
+	if a.KeyLen == 0 {
+		a.KeyLen = d.KeyLen
+	}
+	return a
+}
+
+// argon2Params holds the parameters decoded from an encoded Argon2id hash.
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+// HashPassword hashes the given plain text password using Argon2id.
+// Parameters:
+// - password: The plain text password to hash.
+// Returns:
+// - string: The encoded hash including the algorithm parameters and salt.
+// - error: An error if the salt could not be generated.
+func (a Argon2Hashing) HashPassword(password string) (string, error) {
+	a = a.withDefaults()
+
+	password, prefix := a.peppers.apply(password)
+
+	salt := make([]byte, a.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, a.Iterations, a.Memory, a.Parallelism, a.KeyLen)
+
+	return prefix + encodeArgon2Hash(argon2Params{
+		memory:      a.Memory,
+		iterations:  a.Iterations,
+		parallelism: a.Parallelism,
+		salt:        salt,
+		key:         key,
+	}), nil
+}
+
+// CheckPasswordHash verifies if the given plain text password matches the provided Argon2id hash.
+// The parameters stored in the hash are used, so hashes created with other settings still verify.
+// Parameters:
+// - password: The plain text password to verify.
+// - hash: The encoded Argon2id hash to compare against.
+// Returns:
+// - bool: True if the password matches the hash, false otherwise.
+func (a Argon2Hashing) CheckPasswordHash(password, hash string) bool {
+	password, hash, _, ok := a.peppers.resolve(password, hash)
+	if !ok {
+		return false
+	}
+
+	p, err := decodeArgon2Hash(hash)
+	if err != nil {
+		return false
+	}
+
+	key := argon2.IDKey([]byte(password), p.salt, p.iterations, p.memory, p.parallelism, uint32(len(p.key)))
+
+	return subtle.ConstantTimeCompare(p.key, key) == 1
+}
+
+// NeedsRehash reports whether the given hash is not an Argon2id hash or was produced with
+// weaker parameters, a shorter salt or a shorter key than configured, or was not peppered with
+// the current pepper version.
+// Parameters:
+// - hash: The encoded hash to inspect.
+// Returns:
+// - bool: True if the password should be hashed again, false otherwise.
+func (a Argon2Hashing) NeedsRehash(hash string) bool {
+	_, hash, version, ok := a.peppers.resolve("", hash)
+	if !ok || version != a.peppers.current {
+		return true
+	}
+
+	p, err := decodeArgon2Hash(hash)
+	if err != nil {
+		return true
+	}
+
+	a = a.withDefaults()
+
+	return p.memory < a.Memory ||
+		p.iterations < a.Iterations ||
+		p.parallelism < a.Parallelism ||
+		uint32(len(p.salt)) < a.SaltLen ||
+		uint32(len(p.key)) < a.KeyLen
+}
+
+// encodeArgon2Hash encodes the parameters, salt and key into the standard Argon2id string format.
+func encodeArgon2Hash(p argon2Params) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		p.memory,
+		p.iterations,
+		p.parallelism,
+		base64.RawStdEncoding.EncodeToString(p.salt),
+		base64.RawStdEncoding.EncodeToString(p.key),
+	)
+}
+
+// decodeArgon2Hash parses an encoded Argon2id hash.
+// Parameters:
+// - hash: The encoded hash.
+// Returns:
+// - argon2Params: The decoded parameters, salt and key.
+// - error: ErrInvalidHash or ErrIncompatibleVersion if the hash cannot be used.
+func decodeArgon2Hash(hash string) (argon2Params, error) {
+	var p argon2Params
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return p, ErrInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || parts[2] != fmt.Sprintf("v=%d", version) {
+		return p, ErrInvalidHash
+	}
+	if version != argon2.Version {
+		return p, ErrIncompatibleVersion
+	}
+
+	// Sscanf stops at the last verb, so the segment is formatted again to reject trailing input
+	_, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.iterations, &p.parallelism)
+	if err != nil || parts[3] != fmt.Sprintf("m=%d,t=%d,p=%d", p.memory, p.iterations, p.parallelism) {
+		return p, ErrInvalidHash
+	}
+
+	// argon2.IDKey panics on zero iterations or parallelism
+	if p.iterations < 1 || p.parallelism < 1 || p.memory > maxArgon2Memory {
+		return p, ErrInvalidHash
+	}
+
+	// other implementations may emit padded base64, so the padding is stripped before decoding
+	p.salt, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(parts[4], "="))
+	if err != nil {
+		return p, ErrInvalidHash
+	}
+
+	p.key, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(parts[5], "="))
+	if err != nil || len(p.key) == 0 {
+		return p, ErrInvalidHash
+	}
+
+	return p, nil
+}
@@ -0,0 +1,81 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testArgon2Hashing() Argon2Hashing {
+	return Argon2Hashing{Memory: 8 * 1024, Iterations: 1, Parallelism: 1, SaltLen: 16, KeyLen: 32}
+}
+
+func TestArgon2Hashing(t *testing.T) {
+
+	h := testArgon2Hashing()
+
+	t.Run("round trip", func(t *testing.T) {
+		hash, err := h.HashPassword("s3cr3t")
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=8192,t=1,p=1$"))
+		assert.True(t, h.CheckPasswordHash("s3cr3t", hash))
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		hash, err := h.HashPassword("s3cr3t")
+		require.NoError(t, err)
+		assert.False(t, h.CheckPasswordHash("S3cr3t", hash))
+	})
+
+	t.Run("malformed hash", func(t *testing.T) {
+		assert.False(t, h.CheckPasswordHash("s3cr3t", "$argon2id$v=19$m=8192"))
+		assert.False(t, h.CheckPasswordHash("s3cr3t", "$2a$10$abcdefghijklmnopqrstuu"))
+	})
+
+	t.Run("reference implementation hash", func(t *testing.T) {
+		// known answer from the phc-winner-argon2 reference test suite (src/test.c):
+		// password "password", salt "somesalt", t=2, m=2^16, p=1
+		hash := "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"
+
+		assert.True(t, h.CheckPasswordHash("password", hash))
+		assert.False(t, h.CheckPasswordHash("passw0rd", hash))
+	})
+
+	t.Run("padded base64", func(t *testing.T) {
+		hash := "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ=$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc="
+		assert.True(t, h.CheckPasswordHash("password", hash))
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		for name, params := range map[string]string{
+			"zero iterations":  "m=8192,t=0,p=1",
+			"zero parallelism": "m=8192,t=1,p=0",
+			"absurd memory":    "m=4294967295,t=1,p=1",
+			"trailing garbage": "m=8192,t=1,p=1garbage",
+			"missing field":    "m=8192,t=1",
+		} {
+			_, err := decodeArgon2Hash("$argon2id$v=19$" + params + "$c29tZXNhbHQ$c29tZWtleQ")
+			assert.ErrorIs(t, err, ErrInvalidHash, name)
+			assert.False(t, h.CheckPasswordHash("s3cr3t", "$argon2id$v=19$"+params+"$c29tZXNhbHQ$c29tZWtleQ"), name)
+		}
+
+		_, err := decodeArgon2Hash("$argon2id$v=19x$m=8192,t=1,p=1$c29tZXNhbHQ$c29tZWtleQ")
+		assert.ErrorIs(t, err, ErrInvalidHash)
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		_, err := decodeArgon2Hash("$argon2id$v=16$m=8192,t=1,p=1$c29tZXNhbHQ$c29tZWtleQ")
+		assert.ErrorIs(t, err, ErrIncompatibleVersion)
+	})
+}
+
+func TestArgon2HashingZeroValue(t *testing.T) {
+	var h Argon2Hashing
+
+	hash, err := h.HashPassword("s3cr3t")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=65536,t=3,p=2$"))
+	assert.True(t, h.CheckPasswordHash("s3cr3t", hash))
+}
@@ -0,0 +1,103 @@
+package password
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+)
+
+const (
+	lowerChars  = "abcdefghijklmnopqrstuvwxyz"
+	upperChars  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digitChars  = "0123456789"
+	symbolChars = "!@#$%^&*()-_=+[]{}<>?"
+)
+
+// ErrPasswordTooShort is returned by Generate when the length cannot hold one character of every required class.
+var ErrPasswordTooShort = errors.New("password length is too short for the required character classes")
+
+// GenerateOptions defines the character classes a generated password must contain.
+// When no class is selected, all of them are required.
+type GenerateOptions struct {
+	// Lower requires at least one lower case letter.
+	Lower bool
+	// Upper requires at least one upper case letter.
+	Upper bool
+	// Digits requires at least one digit.
+	Digits bool
+	// Symbols requires at least one symbol.
+	Symbols bool
+}
+
+// Generate creates a random password using crypto/rand.
+// Every required character class appears at least once, the remaining characters are drawn
+// from the union of the required classes.
+// Parameters:
+// - n: The length of the password.
+// - opts: The character classes the password must contain.
+// Returns:
+// - string: The generated password.
+// - error: ErrPasswordTooShort if n is smaller than the number of required classes, or a random source error.
+func Generate(n int, opts GenerateOptions) (string, error) {
+	if !opts.Lower && !opts.Upper && !opts.Digits && !opts.Symbols {
+		opts = GenerateOptions{Lower: true, Upper: true, Digits: true, Symbols: true}
+	}
+
+	var classes []string
+	for _, c := range []struct {
+		required bool
+		chars    string
+	}{
+		{opts.Lower, lowerChars},
+		{opts.Upper, upperChars},
+		{opts.Digits, digitChars},
+		{opts.Symbols, symbolChars},
+	} {
+		if c.required {
+			classes = append(classes, c.chars)
+		}
+	}
+
+	if n < len(classes) {
+		return "", ErrPasswordTooShort
+	}
+
+	var all string
+	for _, c := range classes {
+		all += c
+	}
+
+	buf := make([]byte, n)
+	for i := range buf {
+		// the first characters cover every required class, the shuffle below hides their position
+		chars := all
+		if i < len(classes) {
+			chars = classes[i]
+		}
+
+		c, err := randomIndex(len(chars))
+		if err != nil {
+			return "", err
+		}
+		buf[i] = chars[c]
+	}
+
+	for i := len(buf) - 1; i > 0; i-- {
+		j, err := randomIndex(i + 1)
+		if err != nil {
+			return "", err
+		}
+		buf[i], buf[j] = buf[j], buf[i]
+	}
+
+	return string(buf), nil
+}
+
+// randomIndex returns a uniformly distributed random number in [0, n).
+func randomIndex(n int) (int, error) {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(v.Int64()), nil
+}
@@ -0,0 +1,86 @@
+package password
+
+// MultiHasher implements the Hasher interface on top of a primary hasher and a list of legacy ones.
+// New hashes are always produced by the primary hasher, while existing hashes are verified against
+// the primary hasher first and the legacy hashers afterwards. This allows migrating users to a new
+// algorithm at login time.
+type MultiHasher struct {
+	primary Hasher
+	legacy  []Hasher
+}
+
+// NewMultiHasher creates a MultiHasher.
+// Parameters:
+// - primary: The hasher used to create new hashes, e.g. Argon2Hashing.
+// - legacy: The hashers still accepted for verification, e.g. BcryptHashing.
+// Returns:
+// - *MultiHasher: The hasher combining all the given hashers.
+func NewMultiHasher(primary Hasher, legacy ...Hasher) *MultiHasher {
+	return &MultiHasher{
+		primary: primary,
+		legacy:  legacy,
+	}
+}
+
+// HashPassword hashes the given plain text password with the primary hasher.
+// Parameters:
+// - password: The plain text password to hash.
+// Returns:
+// - string: The hashed password.
+// - error: An error if the hashing operation fails.
+func (m *MultiHasher) HashPassword(password string) (string, error) {
+	return m.primary.HashPassword(password)
+}
+
+// CheckPasswordHash verifies the password against the primary hasher and then every legacy hasher.
+// Parameters:
+// - password: The plain text password to verify.
+// - hash: The hashed password to compare against.
+// Returns:
+// - bool: True if any of the hashers accepts the password, false otherwise.
+func (m *MultiHasher) CheckPasswordHash(password, hash string) bool {
+	if m.primary.CheckPasswordHash(password, hash) {
+		return true
+	}
+	for _, h := range m.legacy {
+		if h.CheckPasswordHash(password, hash) {
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsRehash reports whether the hash does not match the configuration of the primary hasher.
+// Parameters:
+// - hash: The hashed password to inspect.
+// Returns:
+// - bool: True if the password should be hashed again, false otherwise.
+func (m *MultiHasher) NeedsRehash(hash string) bool {
+	return m.primary.NeedsRehash(hash)
+}
+
+// VerifyAndUpgrade verifies the password and, on success, rehashes it with the primary hasher
+// when the stored hash is outdated. The caller is expected to persist a non-empty newHash.
+// Parameters:
+// - password: The plain text password to verify.
+// - hash: The stored hash to compare against.
+// Returns:
+// - newHash: The upgraded hash, or an empty string if the stored hash is still up to date.
+// - ok: True if the password matches the hash, false otherwise.
+// - err: An error if the upgraded hash could not be created.
+func (m *MultiHasher) VerifyAndUpgrade(password, hash string) (newHash string, ok bool, err error) {
+	if !m.CheckPasswordHash(password, hash) {
+		return "", false, nil
+	}
+
+	if !m.primary.NeedsRehash(hash) {
+		return "", true, nil
+	}
+
+	newHash, err = m.primary.HashPassword(password)
+	if err != nil {
+		return "", true, err
+	}
+
+	return newHash, true, nil
+}
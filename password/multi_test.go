@@ -0,0 +1,55 @@
+package password
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestMultiHasherVerifyAndUpgrade(t *testing.T) {
+
+	legacy := BcryptHashing{Const: bcrypt.MinCost}
+	h := NewMultiHasher(testArgon2Hashing(), legacy)
+
+	bcryptHash, err := legacy.HashPassword("s3cr3t")
+	require.NoError(t, err)
+
+	t.Run("bcrypt hash is upgraded to argon2id on successful login", func(t *testing.T) {
+		newHash, ok, err := h.VerifyAndUpgrade("s3cr3t", bcryptHash)
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.NotEmpty(t, newHash)
+		assert.False(t, h.NeedsRehash(newHash))
+		assert.True(t, h.CheckPasswordHash("s3cr3t", newHash))
+	})
+
+	t.Run("wrong password fails closed", func(t *testing.T) {
+		newHash, ok, err := h.VerifyAndUpgrade("wrong", bcryptHash)
+		require.NoError(t, err)
+		assert.False(t, ok)
+		assert.Empty(t, newHash)
+	})
+
+	t.Run("up to date hash is not upgraded", func(t *testing.T) {
+		hash, err := h.HashPassword("s3cr3t")
+		require.NoError(t, err)
+
+		newHash, ok, err := h.VerifyAndUpgrade("s3cr3t", hash)
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Empty(t, newHash)
+	})
+
+	t.Run("weaker argon2id parameters need rehash", func(t *testing.T) {
+		hash, err := testArgon2Hashing().HashPassword("s3cr3t")
+		require.NoError(t, err)
+		assert.True(t, NewArgon2Hashing().NeedsRehash(hash))
+	})
+
+	t.Run("lower bcrypt cost needs rehash", func(t *testing.T) {
+		assert.True(t, BcryptHashing{Const: bcrypt.MinCost + 1}.NeedsRehash(bcryptHash))
+		assert.False(t, legacy.NeedsRehash(bcryptHash))
+	})
+}
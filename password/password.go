@@ -8,6 +8,7 @@ import "golang.org/x/crypto/bcrypt"
 // Methods:
 // - HashPassword: Hashes a plain text password and returns the hashed string.
 // - CheckPasswordHash: Verifies if a plain text password matches a given hash.
+// - NeedsRehash: Reports whether a hash should be replaced by a fresh one.
 type Hasher interface {
 	// HashPassword hashes the given plain text password.
 	// Parameters:
@@ -24,6 +25,14 @@ type Hasher interface {
 	// Returns:
 	// - bool: True if the password matches the hash, false otherwise.
 	CheckPasswordHash(password, hash string) bool
+
+	// NeedsRehash reports whether the hash was produced by another algorithm or with
+	// weaker parameters than the hasher is configured with.
+	// Parameters:
+	// - hash: The hashed password to inspect.
+	// Returns:
+	// - bool: True if the password should be hashed again, false otherwise.
+	NeedsRehash(hash string) bool
 }
 
 // BcryptHashing implements the Hasher interface using bcrypt for password hashing and verification.
@@ -31,6 +40,31 @@ type BcryptHashing struct {
 	// Const defines the cost parameter for bcrypt hashing.
 	// Higher values increase the computation time for hashing.
 	Const int
+
+	peppers pepperSet
+}
+
+// WithPepper returns a copy of the hasher mixing the given secret into every password before hashing.
+// The password is replaced by its HMAC-SHA256 digest keyed by the secret, so the input of bcrypt
+// stays below its 72 byte limit. Peppers are versioned in the order they are added, starting at 1,
+// and the last one added is used for new hashes while the previous ones still verify old hashes.
+// Parameters:
+// - secret: The server-side secret, which must not be stored next to the hashes.
+// Returns:
+// - BcryptHashing: The peppered hasher.
+func (b BcryptHashing) WithPepper(secret []byte) BcryptHashing {
+	return b.WithPepperVersion(b.peppers.nextVersion(), secret)
+}
+
+// WithPepperVersion is like WithPepper but stores the hash under an explicit pepper version.
+// Parameters:
+// - version: The identifier stored in front of the hash, it must not contain "$".
+// - secret: The server-side secret, which must not be stored next to the hashes.
+// Returns:
+// - BcryptHashing: The peppered hasher.
+func (b BcryptHashing) WithPepperVersion(version string, secret []byte) BcryptHashing {
+	b.peppers = b.peppers.with(version, secret)
+	return b
 }
 
 // HashPassword hashes the given plain text password using bcrypt.
@@ -40,8 +74,12 @@ type BcryptHashing struct {
 // - string: The hashed password.
 // - error: An error if the hashing operation fails.
 func (b BcryptHashing) HashPassword(password string) (string, error) {
+	password, prefix := b.peppers.apply(password)
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), b.Const)
-	return string(bytes), err
+	if err != nil {
+		return "", err
+	}
+	return prefix + string(bytes), nil
 }
 
 // CheckPasswordHash verifies if the given plain text password matches the provided bcrypt hash.
@@ -51,6 +89,36 @@ func (b BcryptHashing) HashPassword(password string) (string, error) {
 // Returns:
 // - bool: True if the password matches the hash, false otherwise.
 func (b BcryptHashing) CheckPasswordHash(password, hash string) bool {
+	password, hash, _, ok := b.peppers.resolve(password, hash)
+	if !ok {
+		return false
+	}
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+// NeedsRehash reports whether the given hash is not a bcrypt hash, uses a lower cost than configured
+// or was not peppered with the current pepper version.
+// Parameters:
+// - hash: The hashed password to inspect.
+// Returns:
+// - bool: True if the password should be hashed again, false otherwise.
+func (b BcryptHashing) NeedsRehash(hash string) bool {
+	_, hash, version, ok := b.peppers.resolve("", hash)
+	if !ok || version != b.peppers.current {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return true
+	}
+	return cost < b.cost()
+}
+
+// cost returns the configured cost, falling back to bcrypt.DefaultCost like GenerateFromPassword does.
+func (b BcryptHashing) cost() int {
+	if b.Const < bcrypt.MinCost {
+		return bcrypt.DefaultCost
+	}
+	return b.Const
+}
@@ -0,0 +1,58 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPepperRotation(t *testing.T) {
+
+	oldPepper, newPepper := []byte("old-pepper"), []byte("new-pepper")
+
+	for name, hashers := range map[string]struct{ before, after Hasher }{
+		"bcrypt": {
+			before: BcryptHashing{Const: bcrypt.MinCost}.WithPepper(oldPepper),
+			after:  BcryptHashing{Const: bcrypt.MinCost}.WithPepper(oldPepper).WithPepper(newPepper),
+		},
+		"argon2id": {
+			before: testArgon2Hashing().WithPepper(oldPepper),
+			after:  testArgon2Hashing().WithPepper(oldPepper).WithPepper(newPepper),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			oldHash, err := hashers.before.HashPassword("s3cr3t")
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(oldHash, "$pepper$v=1$"))
+
+			// hashes created with the old pepper keep verifying after the rotation
+			assert.True(t, hashers.after.CheckPasswordHash("s3cr3t", oldHash))
+			assert.False(t, hashers.after.CheckPasswordHash("wrong", oldHash))
+			assert.True(t, hashers.after.NeedsRehash(oldHash))
+
+			newHash, err := hashers.after.HashPassword("s3cr3t")
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(newHash, "$pepper$v=2$"))
+			assert.True(t, hashers.after.CheckPasswordHash("s3cr3t", newHash))
+			assert.False(t, hashers.after.NeedsRehash(newHash))
+
+			// a hasher that does not know the new pepper fails closed
+			assert.False(t, hashers.before.CheckPasswordHash("s3cr3t", newHash))
+		})
+	}
+}
+
+func TestPepperLongPasswordWithBcrypt(t *testing.T) {
+	h := BcryptHashing{Const: bcrypt.MinCost}.WithPepper([]byte("pepper"))
+
+	long := strings.Repeat("a", 100)
+	hash, err := h.HashPassword(long)
+	require.NoError(t, err)
+
+	// without the HMAC the two passwords would collide after bcrypt truncates them at 72 bytes
+	assert.True(t, h.CheckPasswordHash(long, hash))
+	assert.False(t, h.CheckPasswordHash(long[:99]+"b", hash))
+}
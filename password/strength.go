@@ -0,0 +1,200 @@
+package password
+
+import (
+	"bufio"
+	_ "embed"
+	"io"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Score rates the strength of a password from VeryWeak to VeryStrong.
+type Score int
+
+const (
+	VeryWeak Score = iota
+	Weak
+	Fair
+	Strong
+	VeryStrong
+)
+
+// Result describes the outcome of a strength estimation.
+type Result struct {
+	// Score is the overall strength of the password.
+	Score Score
+	// Length is the number of characters in the password.
+	Length int
+	// Classes is the number of character classes used (lower case, upper case, digits and symbols).
+	Classes int
+	// Common reports whether the password is in the list of commonly used passwords.
+	Common bool
+	// Repetitive reports whether the password contains repeated characters or sequences like "aaa" or "123".
+	Repetitive bool
+	// Suggestions holds human readable hints to improve the password.
+	Suggestions []string
+}
+
+// common_passwords.txt holds about 300 of the most common passwords of the public breach
+// corpora, in lower case. It is deliberately short to keep the binaries small, applications
+// wanting a larger list, such as the top 10k, load it with AddCommonPasswords.
+//
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+var (
+	commonPasswordsOnce sync.Once
+	commonPasswordsMu   sync.RWMutex
+	commonPasswords     map[string]struct{}
+)
+
+// loadCommonPasswords fills the list with the embedded passwords on first use.
+func loadCommonPasswords() {
+	commonPasswordsOnce.Do(func() {
+		commonPasswords = make(map[string]struct{})
+		addCommonPasswords(strings.Split(commonPasswordsFile, "\n"))
+	})
+}
+
+// addCommonPasswords adds the non-empty lines to the list, in lower case.
+func addCommonPasswords(lines []string) {
+	commonPasswordsMu.Lock()
+	defer commonPasswordsMu.Unlock()
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			commonPasswords[strings.ToLower(line)] = struct{}{}
+		}
+	}
+}
+
+// AddCommonPasswords adds the passwords read from r, one per line, to the embedded list
+// Strength checks, e.g. a top 10k list shipped with the application.
+func AddCommonPasswords(r io.Reader) error {
+	loadCommonPasswords()
+
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	addCommonPasswords(lines)
+	return nil
+}
+
+// isCommonPassword reports whether the password, ignoring case, is in the common password list.
+func isCommonPassword(password string) bool {
+	loadCommonPasswords()
+	commonPasswordsMu.RLock()
+	defer commonPasswordsMu.RUnlock()
+	_, ok := commonPasswords[strings.ToLower(password)]
+	return ok
+}
+
+// Strength estimates how hard the given password is to guess.
+// The score is based on the length, the number of character classes, repeated characters or
+// sequences and the membership in a list of commonly used passwords.
+// Parameters:
+// - password: The plain text password to rate.
+// Returns:
+// - Result: The score along with the details it was computed from.
+func Strength(password string) Result {
+	r := Result{
+		Length:     len([]rune(password)),
+		Classes:    characterClasses(password),
+		Common:     isCommonPassword(password),
+		Repetitive: hasRepetition(password, 3),
+	}
+
+	if r.Common {
+		r.Suggestions = append(r.Suggestions, "avoid commonly used passwords")
+		return r
+	}
+
+	score := 0
+	switch {
+	case r.Length >= 16:
+		score += 3
+	case r.Length >= 12:
+		score += 2
+	case r.Length >= 8:
+		score++
+	default:
+		r.Suggestions = append(r.Suggestions, "use at least 8 characters")
+	}
+
+	switch {
+	case r.Classes == 4:
+		score += 2
+	case r.Classes == 3:
+		score++
+	default:
+		r.Suggestions = append(r.Suggestions, "mix upper case, lower case, digits and symbols")
+	}
+
+	if r.Repetitive {
+		score--
+		r.Suggestions = append(r.Suggestions, "avoid repeated characters and sequences")
+	}
+
+	// a short password stays weak no matter how many classes it uses
+	if r.Length < 8 && score > int(Weak) {
+		score = int(Weak)
+	}
+
+	r.Score = Score(min(max(score, int(VeryWeak)), int(VeryStrong)))
+
+	return r
+}
+
+// characterClasses counts the character classes used in the password.
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsLower(c):
+			lower = true
+		case unicode.IsUpper(c):
+			upper = true
+		case unicode.IsDigit(c):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	classes := 0
+	for _, ok := range []bool{lower, upper, digit, symbol} {
+		if ok {
+			classes++
+		}
+	}
+	return classes
+}
+
+// hasRepetition reports whether the password contains n or more equal, ascending or
+// descending consecutive characters, e.g. "aaa", "abc" or "321".
+func hasRepetition(password string, n int) bool {
+	runes := []rune(strings.ToLower(password))
+
+	same, asc, desc := 1, 1, 1
+	for i := 1; i < len(runes); i++ {
+		same, asc, desc = step(same, runes[i] == runes[i-1]), step(asc, runes[i] == runes[i-1]+1), step(desc, runes[i] == runes[i-1]-1)
+		if same >= n || asc >= n || desc >= n {
+			return true
+		}
+	}
+	return false
+}
+
+// step extends a run when cont is true and restarts it otherwise.
+func step(run int, cont bool) int {
+	if cont {
+		return run + 1
+	}
+	return 1
+}
@@ -0,0 +1,64 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrength(t *testing.T) {
+
+	for _, weak := range []string{"password", "Password1", "123456", "qwerty", "abc", "aaaaaaaa"} {
+		assert.LessOrEqual(t, Strength(weak).Score, Weak, weak)
+	}
+
+	r := Strength("P@ssw0rd")
+	assert.True(t, r.Common)
+	assert.Equal(t, VeryWeak, r.Score)
+
+	assert.True(t, Strength("xyz12345Qw!").Repetitive)
+	assert.Equal(t, VeryStrong, Strength("c0rrect-Horse-b4ttery").Score)
+}
+
+func TestCommonPasswords(t *testing.T) {
+	lines := strings.Fields(commonPasswordsFile)
+	assert.GreaterOrEqual(t, len(lines), 300, "the embedded list holds about 300 passwords")
+
+	seen := map[string]bool{}
+	for _, line := range lines {
+		assert.Equal(t, strings.ToLower(line), line, "the list is in lower case")
+		assert.False(t, seen[line], "%s is listed twice", line)
+		seen[line] = true
+	}
+
+	assert.False(t, Strength("Tr0ub4dor&3x").Common)
+	require.NoError(t, AddCommonPasswords(strings.NewReader("tr0ub4dor&3x\n\n  Zxcvbnm1!  \n")))
+	assert.True(t, Strength("Tr0ub4dor&3x").Common, "a loaded password is matched ignoring case")
+	assert.True(t, Strength("zxcvbnm1!").Common)
+	assert.True(t, Strength("password").Common, "the embedded list is kept")
+}
+
+func TestGenerate(t *testing.T) {
+
+	t.Run("default policy uses every class", func(t *testing.T) {
+		for i := 0; i < 50; i++ {
+			pw, err := Generate(12, GenerateOptions{})
+			require.NoError(t, err)
+			assert.Len(t, pw, 12)
+			assert.Equal(t, 4, characterClasses(pw), pw)
+		}
+	})
+
+	t.Run("only required classes are used", func(t *testing.T) {
+		pw, err := Generate(32, GenerateOptions{Digits: true})
+		require.NoError(t, err)
+		assert.Empty(t, strings.Trim(pw, digitChars))
+	})
+
+	t.Run("too short for the required classes", func(t *testing.T) {
+		_, err := Generate(3, GenerateOptions{})
+		assert.ErrorIs(t, err, ErrPasswordTooShort)
+	})
+}
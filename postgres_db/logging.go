@@ -0,0 +1,237 @@
+package postgres_db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QueryLogger writes the statements logged by LoggingDB. It is the part of logger.Logger used
+// here, which cannot be imported by this package; the trace ID of the entries is the one of
+// the context of the query.
+type QueryLogger interface {
+	Info(ctx context.Context, message string, args ...any)
+	Warning(ctx context.Context, message string, args ...any)
+	Error(ctx context.Context, message string, args ...any)
+}
+
+// LoggingDB is a *sql.DB logging the statements run by its Exec, Query and QueryRow methods,
+// with their duration, affected rows and error. The statements slower than the slow query
+// threshold are logged as warnings, the failed ones as errors. Bind parameters looking like
+// secrets, such as password hashes and tokens, are truncated.
+//
+// The other methods are the ones of the embedded *sql.DB, which is also the one to give to
+// WithTx; the queries of the transaction are logged when they run on Conn.
+type LoggingDB struct {
+	*sql.DB
+	log  QueryLogger
+	slow time.Duration
+	now  func() time.Time
+}
+
+var _ Executor = (*LoggingDB)(nil)
+
+// WrapWithLogging returns db logging its statements.
+// Parameters:
+// - db: The connection pool, e.g. opened by New.
+// - log: The logger of the statements, a logger.Logger.
+// - slowThreshold: The duration above which a statement is logged as a warning, none when 0.
+// Returns:
+// - *LoggingDB: The logging connection pool.
+func WrapWithLogging(db *sql.DB, log QueryLogger, slowThreshold time.Duration) *LoggingDB {
+	return &LoggingDB{DB: db, log: log, slow: slowThreshold, now: time.Now}
+}
+
+// Conn returns the transaction stored in ctx, or the connection pool when there is none, see
+// Conn, both logging their statements.
+// Parameters:
+// - ctx: The context of the query.
+// Returns:
+// - Executor: The logging transaction or connection pool.
+func (l *LoggingDB) Conn(ctx context.Context) Executor {
+	if tx, ok := TxFromContext(ctx); ok {
+		return loggingExecutor{next: tx, db: l}
+	}
+	return l
+}
+
+func (l *LoggingDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return loggingExecutor{next: l.DB, db: l}.ExecContext(ctx, query, args...)
+}
+
+func (l *LoggingDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return loggingExecutor{next: l.DB, db: l}.QueryContext(ctx, query, args...)
+}
+
+func (l *LoggingDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return loggingExecutor{next: l.DB, db: l}.QueryRowContext(ctx, query, args...)
+}
+
+func (l *LoggingDB) Exec(query string, args ...any) (sql.Result, error) {
+	return l.ExecContext(context.Background(), query, args...)
+}
+
+func (l *LoggingDB) Query(query string, args ...any) (*sql.Rows, error) {
+	return l.QueryContext(context.Background(), query, args...)
+}
+
+func (l *LoggingDB) QueryRow(query string, args ...any) *sql.Row {
+	return l.QueryRowContext(context.Background(), query, args...)
+}
+
+// loggingExecutor logs the statements run on an Executor.
+type loggingExecutor struct {
+	next Executor
+	db   *LoggingDB
+}
+
+func (e loggingExecutor) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	start := e.db.now()
+	res, err := e.next.ExecContext(ctx, query, args...)
+
+	rows := int64(-1)
+	if err == nil {
+		if n, rErr := res.RowsAffected(); rErr == nil {
+			rows = n
+		}
+	}
+
+	e.db.logStatement(ctx, query, args, start, rows, err)
+	return res, err
+}
+
+func (e loggingExecutor) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	start := e.db.now()
+	rows, err := e.next.QueryContext(ctx, query, args...)
+
+	// the rows are only known once they are read
+	e.db.logStatement(ctx, query, args, start, -1, err)
+	return rows, err
+}
+
+func (e loggingExecutor) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	start := e.db.now()
+	row := e.next.QueryRowContext(ctx, query, args...)
+
+	err := row.Err()
+	rows := int64(-1)
+	if err == nil {
+		rows = 1
+	}
+
+	e.db.logStatement(ctx, query, args, start, rows, err)
+	return row
+}
+
+// logStatement logs a statement at the info level, as a warning when it is slow and as an
+// error when it failed.
+// Parameters:
+// - ctx: The context of the statement, carrying its trace ID.
+// - query: The statement.
+// - args: The bind parameters of the statement.
+// - start: When the statement started.
+// - rows: The affected or returned rows, -1 when unknown.
+// - err: The error of the statement.
+func (l *LoggingDB) logStatement(ctx context.Context, query string, args []any, start time.Time, rows int64, err error) {
+	duration := l.now().Sub(start)
+
+	const format = "sql %s args=%s duration=%s rows=%d error=%v"
+	params := []any{compactQuery(query), formatArgs(args), duration, rows, err}
+
+	switch {
+	case err != nil && err != sql.ErrNoRows:
+		l.log.Error(ctx, format, params...)
+	case l.slow > 0 && duration > l.slow:
+		l.log.Warning(ctx, "slow "+format, params...)
+	default:
+		l.log.Info(ctx, format, params...)
+	}
+}
+
+// compactQuery collapses the whitespace of a statement, so it is logged on one line.
+func compactQuery(query string) string {
+	if !strings.ContainsAny(query, "\n\r\t") && !strings.Contains(query, "  ") {
+		return query
+	}
+	return strings.Join(strings.Fields(query), " ")
+}
+
+var (
+	// hashRegex matches the password hashes of bcrypt, scrypt and argon2.
+	hashRegex = regexp.MustCompile(`^\$(2[abxy]?|s0|scrypt|argon2(id|i|d))\$`)
+	// jwtRegex matches the JSON web tokens.
+	jwtRegex = regexp.MustCompile(`^eyJ[\w-]*\.[\w-]*\.[\w-]*$`)
+	// tokenRegex matches the long random strings of API keys, session tokens and secrets.
+	tokenRegex = regexp.MustCompile(`^[A-Za-z0-9+/=_-]{32,}$`)
+	// uuidRegex matches the UUIDs, which are IDs and not secrets.
+	uuidRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+// maxArgLen is the length above which the bind parameters are truncated.
+const maxArgLen = 64
+
+// formatArgs formats the bind parameters of a statement, truncating the secrets and the long
+// values.
+func formatArgs(args []any) string {
+	if len(args) == 0 {
+		return "[]"
+	}
+
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, arg := range args {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(formatArg(arg))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+// formatArg formats a bind parameter.
+func formatArg(arg any) string {
+	switch v := arg.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return formatString(v)
+	case []byte:
+		return fmt.Sprintf("<%d bytes>", len(v))
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case fmt.Stringer:
+		return formatString(v.String())
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// formatString quotes a string parameter, truncated when it looks like a secret or is long.
+func formatString(s string) string {
+	if looksSecret(s) {
+		return strconv.Quote(s[:4] + "…")
+	}
+	if len(s) > maxArgLen {
+		if runes := []rune(s); len(runes) > maxArgLen {
+			return strconv.Quote(string(runes[:maxArgLen]) + "…")
+		}
+	}
+	return strconv.Quote(s)
+}
+
+// looksSecret reports whether a string parameter looks like a password hash, a token or a key.
+func looksSecret(s string) bool {
+	if len(s) < 8 || uuidRegex.MatchString(s) {
+		return false
+	}
+	return hashRegex.MatchString(s) || jwtRegex.MatchString(s) || tokenRegex.MatchString(s)
+}
@@ -0,0 +1,219 @@
+package postgres_db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// MigrationsTable is the table keeping the versions of the applied migrations.
+const MigrationsTable = "schema_migrations"
+
+// MigrationVersionLayout is the time layout of migration versions, e.g. 20250102150405.
+const MigrationVersionLayout = "20060102150405"
+
+// migrationFileRegex matches migration files named <version>_<name>.<up|down>.sql.
+var migrationFileRegex = regexp.MustCompile(`^(\d{14})_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is a pair of up and down SQL files sharing the same version.
+type Migration struct {
+	Version  string
+	Name     string
+	UpFile   string
+	DownFile string
+}
+
+// MigrationStatus tells whether a migration was applied to the database.
+type MigrationStatus struct {
+	Migration
+	Applied bool
+}
+
+// LoadMigrations reads the migrations of a directory sorted by version.
+// Parameters:
+// - dir: The directory containing the <version>_<name>.<up|down>.sql files.
+// Returns:
+// - []Migration: The migrations sorted by version.
+// - error: An error if the directory cannot be read, two migrations share a version or an up file is missing.
+func LoadMigrations(dir string) ([]Migration, error) {
+
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("migrations directory %q does not exist", dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[string]*Migration{}
+
+	for _, entry := range entries {
+		m := migrationFileRegex.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || m == nil {
+			continue
+		}
+
+		version, name, direction := m[1], m[2], m[3]
+		path := filepath.Join(dir, entry.Name())
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		}
+		if migration.Name != name {
+			return nil, fmt.Errorf("duplicate migration version %s used by %q and %q", version, migration.Name, name)
+		}
+
+		if direction == "up" {
+			migration.UpFile = path
+		} else {
+			migration.DownFile = path
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.UpFile == "" {
+			return nil, fmt.Errorf("migration %s_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// AppliedMigrationVersions returns the versions recorded in the migrations table.
+// A database without the migrations table has no applied migrations.
+// Parameters:
+// - ctx: The context of the query.
+// - db: The database connection.
+// Returns:
+// - map[string]bool: The applied versions.
+// - error: An error if the query fails.
+func AppliedMigrationVersions(ctx context.Context, db *sql.DB) (map[string]bool, error) {
+
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT version FROM %s", MigrationsTable))
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "42P01" { // undefined_table
+			return map[string]bool{}, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[string]bool{}
+	for rows.Next() {
+		var version string
+		if err = rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+
+	return applied, rows.Err()
+}
+
+// MigrationsStatus reports which migrations of the directory are applied and which are pending.
+// Parameters:
+// - ctx: The context of the query.
+// - db: The database connection.
+// - dir: The directory containing the migrations.
+// Returns:
+// - []MigrationStatus: The status of every migration sorted by version.
+// - error: An error if the migrations cannot be loaded or the query fails.
+func MigrationsStatus(ctx context.Context, db *sql.DB, dir string) ([]MigrationStatus, error) {
+
+	migrations, err := LoadMigrations(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := AppliedMigrationVersions(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status = append(status, MigrationStatus{Migration: m, Applied: applied[m.Version]})
+	}
+
+	return status, nil
+}
+
+// Migrate applies the pending migrations of the directory in version order.
+// Every migration runs in its own transaction together with the insert of its version.
+// Parameters:
+// - ctx: The context of the queries.
+// - db: The database connection.
+// - dir: The directory containing the migrations.
+// Returns:
+// - []Migration: The migrations applied by this call.
+// - error: An error if a migration fails, the migrations applied before it stay applied.
+func Migrate(ctx context.Context, db *sql.DB, dir string) ([]Migration, error) {
+
+	createTable := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (version VARCHAR(14) PRIMARY KEY, applied_at TIMESTAMPTZ NOT NULL DEFAULT now())", MigrationsTable)
+	if _, err := db.ExecContext(ctx, createTable); err != nil {
+		return nil, err
+	}
+
+	status, err := MigrationsStatus(ctx, db, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, s := range status {
+		if s.Applied {
+			continue
+		}
+
+		if err = applyMigration(ctx, db, s.Migration); err != nil {
+			return done, fmt.Errorf("migration %s_%s failed: %w", s.Version, s.Name, err)
+		}
+		done = append(done, s.Migration)
+	}
+
+	return done, nil
+}
+
+// applyMigration runs the up file of the migration and records its version in one transaction.
+func applyMigration(ctx context.Context, db *sql.DB, m Migration) error {
+
+	query, err := os.ReadFile(m.UpFile)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(string(query)) != "" {
+		if _, err = tx.ExecContext(ctx, string(query)); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	if _, err = tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (version) VALUES ($1)", MigrationsTable), m.Version); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
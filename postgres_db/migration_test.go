@@ -0,0 +1,126 @@
+package postgres_db
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeMigrations creates the given files with an empty body in a temp dir.
+func writeMigrations(t *testing.T, names ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range names {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("SELECT 1;\n"), 0644))
+	}
+	return dir
+}
+
+func TestLoadMigrations(t *testing.T) {
+
+	t.Run("sorted by version", func(t *testing.T) {
+		dir := writeMigrations(t,
+			"20250102000000_add_email.up.sql",
+			"20250102000000_add_email.down.sql",
+			"20250101000000_create_users.up.sql",
+			"20250101000000_create_users.down.sql",
+			"README.md",
+		)
+
+		migrations, err := LoadMigrations(dir)
+		require.NoError(t, err)
+		require.Len(t, migrations, 2)
+		assert.Equal(t, "create_users", migrations[0].Name)
+		assert.Equal(t, "add_email", migrations[1].Name)
+		assert.Equal(t, filepath.Join(dir, "20250101000000_create_users.down.sql"), migrations[0].DownFile)
+	})
+
+	t.Run("duplicate version", func(t *testing.T) {
+		dir := writeMigrations(t,
+			"20250101000000_create_users.up.sql",
+			"20250101000000_create_orders.up.sql",
+		)
+
+		_, err := LoadMigrations(dir)
+		assert.ErrorContains(t, err, "duplicate migration version 20250101000000")
+	})
+
+	t.Run("missing up file", func(t *testing.T) {
+		dir := writeMigrations(t, "20250101000000_create_users.down.sql")
+
+		_, err := LoadMigrations(dir)
+		assert.ErrorContains(t, err, "has no up file")
+	})
+
+	t.Run("missing directory", func(t *testing.T) {
+		_, err := LoadMigrations(filepath.Join(t.TempDir(), "missing"))
+		assert.ErrorContains(t, err, "does not exist")
+	})
+}
+
+func TestMigrationsStatus(t *testing.T) {
+	dir := writeMigrations(t,
+		"20250101000000_create_users.up.sql",
+		"20250102000000_add_email.up.sql",
+	)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT version FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow("20250101000000"))
+
+	status, err := MigrationsStatus(context.Background(), db, dir)
+	require.NoError(t, err)
+	require.Len(t, status, 2)
+	assert.True(t, status[0].Applied)
+	assert.False(t, status[1].Applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrationsStatusWithoutTable(t *testing.T) {
+	dir := writeMigrations(t, "20250101000000_create_users.up.sql")
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT version FROM schema_migrations").WillReturnError(&pq.Error{Code: "42P01"})
+
+	status, err := MigrationsStatus(context.Background(), db, dir)
+	require.NoError(t, err)
+	require.Len(t, status, 1)
+	assert.False(t, status[0].Applied)
+}
+
+func TestMigrate(t *testing.T) {
+	dir := writeMigrations(t,
+		"20250101000000_create_users.up.sql",
+		"20250102000000_add_email.up.sql",
+	)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow("20250101000000"))
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT 1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO schema_migrations").WithArgs("20250102000000").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	done, err := Migrate(context.Background(), db, dir)
+	require.NoError(t, err)
+	require.Len(t, done, 1)
+	assert.Equal(t, "add_email", done[0].Name)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,99 @@
+package postgres_db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// txKey is the context key of the transaction stored by ContextWithTx.
+type txKey struct{}
+
+// Executor runs queries. It is implemented by both *sql.DB and *sql.Tx, so repositories can
+// run the same code inside and outside a transaction.
+type Executor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+var (
+	_ Executor = (*sql.DB)(nil)
+	_ Executor = (*sql.Tx)(nil)
+)
+
+// ContextWithTx returns a copy of ctx carrying the transaction.
+// Parameters:
+// - ctx: The parent context.
+// - tx: The transaction the repositories called with the returned context should use.
+// Returns:
+// - context.Context: The context carrying the transaction.
+func ContextWithTx(ctx context.Context, tx *sql.Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// TxFromContext returns the transaction stored in ctx by ContextWithTx.
+// Parameters:
+// - ctx: The context to inspect.
+// Returns:
+// - *sql.Tx: The transaction, nil when there is none.
+// - bool: True if ctx carries a transaction.
+func TxFromContext(ctx context.Context) (*sql.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(*sql.Tx)
+	return tx, ok && tx != nil
+}
+
+// Conn returns the transaction stored in ctx, or db when there is none. Repositories should run
+// their queries on it, so they take part in the transaction of the use case when there is one.
+// Parameters:
+// - ctx: The context of the query.
+// - db: The connection pool used outside transactions.
+// Returns:
+// - Executor: The transaction or the connection pool.
+func Conn(ctx context.Context, db *sql.DB) Executor {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx
+	}
+	return db
+}
+
+// WithTx runs fn in a transaction stored in its context. The transaction is committed when fn
+// returns nil and rolled back when it returns an error or panics, the panic is propagated.
+// When ctx already carries a transaction, fn joins it and the outer call decides the outcome.
+// Parameters:
+// - ctx: The context of the transaction.
+// - db: The connection pool the transaction is opened on.
+// - fn: The function to run in the transaction.
+// Returns:
+// - error: The error of fn, or an error if the transaction cannot be opened or committed.
+func WithTx(ctx context.Context, db *sql.DB, fn func(ctx context.Context) error) (err error) {
+
+	if _, ok := TxFromContext(ctx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("cannot begin transaction: %w", err)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			_ = tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	if err = fn(ContextWithTx(ctx, tx)); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("cannot commit transaction: %w", err)
+	}
+
+	return nil
+}
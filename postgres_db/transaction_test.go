@@ -0,0 +1,91 @@
+package postgres_db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithTx(t *testing.T) {
+
+	t.Run("commit", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO users").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err = WithTx(context.Background(), db, func(ctx context.Context) error {
+			_, ok := TxFromContext(ctx)
+			assert.True(t, ok)
+			_, err := Conn(ctx, db).ExecContext(ctx, "INSERT INTO users VALUES ($1)", "a")
+			return err
+		})
+		require.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("rollback on error", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		fail := errors.New("boom")
+		err = WithTx(context.Background(), db, func(ctx context.Context) error { return fail })
+		assert.ErrorIs(t, err, fail)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("rollback on panic", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		assert.PanicsWithValue(t, "boom", func() {
+			_ = WithTx(context.Background(), db, func(ctx context.Context) error { panic("boom") })
+		})
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("nested calls join the outer transaction", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectCommit()
+
+		err = WithTx(context.Background(), db, func(ctx context.Context) error {
+			outer, _ := TxFromContext(ctx)
+			return WithTx(ctx, db, func(ctx context.Context) error {
+				inner, _ := TxFromContext(ctx)
+				assert.Same(t, outer, inner)
+				return nil
+			})
+		})
+		require.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestConnWithoutTx(t *testing.T) {
+	db, _, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, ok := TxFromContext(context.Background())
+	assert.False(t, ok)
+	assert.Same(t, db, Conn(context.Background(), db))
+}
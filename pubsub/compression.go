@@ -0,0 +1,180 @@
+package pubsub
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// The content encodings of the compressed messages, set in their ContentEncoding property.
+const (
+	EncodingGzip = "gzip"
+	EncodingZstd = "zstd"
+)
+
+// DefaultCompressionThreshold is the size in bytes from which the bodies are compressed when
+// the CompressionOptions set none.
+const DefaultCompressionThreshold = 32 << 10
+
+// maxDecompressedSize bounds the body of a decompressed message, so a small message cannot
+// expand into gigabytes in the memory of the consumer.
+var maxDecompressedSize int64 = 64 << 20
+
+// CompressionOptions configures the compression of the published messages, see SetCompression.
+//
+// Fields:
+//   - Algorithm: EncodingGzip or EncodingZstd, EncodingGzip when empty.
+//   - Threshold: The size in bytes from which the bodies are compressed,
+//     DefaultCompressionThreshold when zero.
+//   - Events: The names of the events whose messages are compressed, all of them when empty.
+type CompressionOptions struct {
+	Algorithm string   `mapstructure:"algorithm"`
+	Threshold int      `mapstructure:"threshold"`
+	Events    []string `mapstructure:"events"`
+}
+
+// compresses reports whether the body of a message of an event is compressed.
+func (o *CompressionOptions) compresses(eventName string, size int) bool {
+	return size >= o.Threshold && (len(o.Events) == 0 || slices.Contains(o.Events, eventName))
+}
+
+// SetCompression compresses the bodies of the messages the event publishes from a size on,
+// e.g. the events carrying large JSON snapshots, to lower the memory the broker holds them
+// with. The algorithm is set as the ContentEncoding of the message, which Consume reads to
+// decompress the body before the handler gets it. A body the compression does not shrink is
+// published as it is.
+//
+// The consumers must run a version decompressing the messages before the publishers enable
+// it: the other consumers would get a body they cannot decode.
+//
+// Parameters:
+//   - opts: The algorithm, the threshold and the events compressed.
+//
+// Returns:
+//   - An error if the algorithm is not supported, the compression is left unchanged then.
+func (e *Event) SetCompression(opts CompressionOptions) error {
+	if opts.Algorithm == "" {
+		opts.Algorithm = EncodingGzip
+	}
+	if opts.Algorithm != EncodingGzip && opts.Algorithm != EncodingZstd {
+		return fmt.Errorf("pubsub: the compression algorithm %q is not supported, use %q or %q", opts.Algorithm, EncodingGzip, EncodingZstd)
+	}
+	if opts.Threshold <= 0 {
+		opts.Threshold = DefaultCompressionThreshold
+	}
+
+	e.compression = &opts
+	return nil
+}
+
+// compress compresses the body of a message of an event when the compression is enabled for
+// it, see SetCompression.
+func (e *Event) compress(msg *amqp.Publishing, eventName string) error {
+
+	o := e.compression
+	if o == nil || !o.compresses(eventName, len(msg.Body)) {
+		return nil
+	}
+
+	var body []byte
+	switch o.Algorithm {
+	case EncodingZstd:
+		encoder, err := zstdEncoder()
+		if err != nil {
+			return err
+		}
+		body = encoder.EncodeAll(msg.Body, make([]byte, 0, len(msg.Body)/2))
+	default:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(msg.Body); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		body = buf.Bytes()
+	}
+
+	if len(body) >= len(msg.Body) {
+		return nil
+	}
+
+	msg.Body = body
+	msg.ContentEncoding = o.Algorithm
+	return nil
+}
+
+// Decompress replaces the body of a message compressed by an Event with SetCompression by the
+// decompressed one, and clears its ContentEncoding. Consume calls it before the handler, the
+// consumers reading the deliveries of a Consumer themselves call it too. The messages without
+// ContentEncoding are left unchanged.
+//
+// Parameters:
+//   - msg: The message.
+//
+// Returns:
+//   - An error if the encoding is not supported or the body cannot be decompressed, the
+//     message is left unchanged then.
+func Decompress(msg *amqp.Delivery) error {
+
+	var body []byte
+	var err error
+
+	switch msg.ContentEncoding {
+	case "", "identity":
+		return nil
+	case EncodingGzip:
+		body, err = gunzip(msg.Body)
+	case EncodingZstd:
+		var decoder *zstd.Decoder
+		if decoder, err = zstdDecoder(); err == nil {
+			body, err = decoder.DecodeAll(msg.Body, nil)
+		}
+	default:
+		return fmt.Errorf("pubsub: the content encoding %q is not supported", msg.ContentEncoding)
+	}
+
+	if err != nil {
+		return fmt.Errorf("pubsub: cannot decompress the %s body: %w", msg.ContentEncoding, err)
+	}
+
+	msg.Body = body
+	msg.ContentEncoding = ""
+	return nil
+}
+
+// gunzip decompresses a gzip body, up to maxDecompressedSize.
+func gunzip(compressed []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	body, err := io.ReadAll(io.LimitReader(r, maxDecompressedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > maxDecompressedSize {
+		return nil, fmt.Errorf("the body is larger than %d bytes", maxDecompressedSize)
+	}
+	return body, nil
+}
+
+// zstdEncoder and zstdDecoder return the zstd encoder and decoder shared by the events, their
+// EncodeAll and DecodeAll are safe for concurrent use.
+var (
+	zstdEncoder = sync.OnceValues(func() (*zstd.Encoder, error) {
+		return zstd.NewWriter(nil)
+	})
+	zstdDecoder = sync.OnceValues(func() (*zstd.Decoder, error) {
+		return zstd.NewReader(nil, zstd.WithDecoderMaxMemory(uint64(maxDecompressedSize)), zstd.WithDecoderConcurrency(0))
+	})
+)
@@ -0,0 +1,140 @@
+package pubsub
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// snapshot is a large JSON payload, e.g. the snapshot of an aggregate.
+func snapshot(items int) map[string]any {
+	lines := make([]map[string]any, 0, items)
+	for i := range items {
+		lines = append(lines, map[string]any{"sku": "SKU-" + strings.Repeat("0", 8), "quantity": i, "description": "a product of the catalog"})
+	}
+	return map[string]any{"order": "o-1", "lines": lines}
+}
+
+// deliver returns the delivery of a published message.
+func deliver(msg amqp.Publishing) *amqp.Delivery {
+	return &amqp.Delivery{ContentType: msg.ContentType, ContentEncoding: msg.ContentEncoding, Body: msg.Body}
+}
+
+func TestCompressionRoundTrip(t *testing.T) {
+	data := newEventData("order.snapshot", snapshot(5000))
+
+	for _, algorithm := range []string{EncodingGzip, EncodingZstd} {
+		t.Run(algorithm, func(t *testing.T) {
+			e := &Event{appName: "shop"}
+			require.NoError(t, e.SetCompression(CompressionOptions{Algorithm: algorithm}))
+
+			msg, err := newPublishing(data)
+			require.NoError(t, err)
+			original := msg.Body
+			require.Greater(t, len(original), 256<<10)
+
+			require.NoError(t, e.compress(&msg, data.Name))
+			assert.Equal(t, algorithm, msg.ContentEncoding)
+			assert.Equal(t, "application/json", msg.ContentType)
+			assert.Less(t, len(msg.Body), len(original)/10)
+
+			d := deliver(msg)
+			require.NoError(t, Decompress(d))
+			assert.Empty(t, d.ContentEncoding)
+			assert.Equal(t, original, d.Body)
+
+			var got EventData
+			require.NoError(t, json.Unmarshal(d.Body, &got))
+			assert.Equal(t, data.ID, got.ID)
+		})
+	}
+}
+
+func TestCompressionThreshold(t *testing.T) {
+	e := &Event{appName: "shop"}
+	require.NoError(t, e.SetCompression(CompressionOptions{Threshold: 1024, Events: []string{"order.snapshot"}}))
+
+	publish := func(name string, payload any) amqp.Publishing {
+		msg, err := newPublishing(newEventData(name, payload))
+		require.NoError(t, err)
+		require.NoError(t, e.compress(&msg, name))
+		return msg
+	}
+
+	small := publish("order.snapshot", snapshot(1))
+	assert.Empty(t, small.ContentEncoding, "the bodies under the threshold are not compressed")
+
+	large := publish("order.snapshot", snapshot(100))
+	assert.Equal(t, EncodingGzip, large.ContentEncoding, "gzip is the default algorithm")
+
+	other := publish("order.created", snapshot(100))
+	assert.Empty(t, other.ContentEncoding, "the events out of the allow-list are not compressed")
+
+	// a body the compression does not shrink is published as it is
+	random := make([]byte, 4096)
+	_, _ = rand.Read(random)
+	msg := amqp.Publishing{Body: random}
+	require.NoError(t, e.compress(&msg, "order.snapshot"))
+	assert.Empty(t, msg.ContentEncoding)
+	assert.Equal(t, random, msg.Body)
+
+	assert.ErrorContains(t, e.SetCompression(CompressionOptions{Algorithm: "brotli"}), `"brotli" is not supported`)
+	assert.Equal(t, EncodingGzip, e.compression.Algorithm, "the compression is left unchanged")
+}
+
+func TestDecompressInterop(t *testing.T) {
+	// the messages of the publishers without compression are left unchanged
+	d := &amqp.Delivery{ContentType: "application/json", Body: []byte(`{"id":"e-1"}`)}
+	require.NoError(t, Decompress(d))
+	assert.Equal(t, `{"id":"e-1"}`, string(d.Body))
+
+	d = &amqp.Delivery{ContentEncoding: "deflate", Body: []byte("...")}
+	assert.ErrorContains(t, Decompress(d), `"deflate" is not supported`)
+	assert.Equal(t, "deflate", d.ContentEncoding)
+
+	d = &amqp.Delivery{ContentEncoding: EncodingZstd, Body: []byte("not zstd")}
+	assert.ErrorContains(t, Decompress(d), "cannot decompress the zstd body")
+}
+
+func TestConsumeDecompresses(t *testing.T) {
+	logs := recordLogs(t)
+
+	publisher := &Event{appName: "shop"}
+	require.NoError(t, publisher.SetCompression(CompressionOptions{Algorithm: EncodingZstd, Threshold: 64}))
+
+	msg, err := newPublishing(newEventData("order.snapshot", snapshot(50)))
+	require.NoError(t, err)
+	require.NoError(t, publisher.compress(&msg, "order.snapshot"))
+	require.Equal(t, EncodingZstd, msg.ContentEncoding)
+
+	plain, err := newPublishing(newEventData("order.created", map[string]any{"order": "o-1"}))
+	require.NoError(t, err)
+
+	broken := &recordingAcknowledger{}
+	deliveries := make(chan *amqp.Delivery, 3)
+	deliveries <- deliver(msg)
+	deliveries <- &amqp.Delivery{ContentEncoding: EncodingGzip, Body: []byte("truncated"), Acknowledger: broken}
+	deliveries <- deliver(plain)
+	close(deliveries)
+
+	e := newTestEvent(EventConsumerOptions{})
+	e.consumer = &Consumer{delivery: deliveries}
+
+	var names []string
+	e.Consume(func(_ int64, d *amqp.Delivery) {
+		assert.Empty(t, d.ContentEncoding)
+		var data EventData
+		require.NoError(t, json.Unmarshal(d.Body, &data))
+		names = append(names, data.Name)
+	})
+
+	assert.Equal(t, []string{"order.snapshot", "order.created"}, names)
+	assert.Equal(t, 1, broken.nacks, "the message which cannot be decompressed is dead-lettered")
+	assert.Zero(t, broken.requeues)
+	assert.Equal(t, []string{"Could not decompress the message"}, *logs)
+}
@@ -4,8 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
 	amqp "github.com/rabbitmq/amqp091-go"
-	"github.com/samber/lo"
 	"github.com/samber/mo"
 	"time"
 )
@@ -23,6 +23,13 @@ type Event struct {
 	producer *producer
 	consumer *Consumer
 	appName  string
+
+	consumerOptions EventConsumerOptions
+	poisonMessages  prometheus.Counter
+	maxPriority     uint8               // the x-max-priority of the queues, 0 when the publish options are ignored
+	compression     *CompressionOptions // the compression of the published bodies, none when nil
+
+	openInspector func() (queueInspector, error) // opens the channel of QueueStats, openChannel by default
 }
 
 func newConnection(appName, username, password, host, vhost string) (*Connection, error) {
@@ -49,7 +56,7 @@ func NewEvent(appName, username, password, host, vhost string) (*Event, error) {
 
 	event.conn = conn
 
-	producer := NewProducer(event.conn, fmt.Sprintf("%s-producer", appName), ProducerOptions{
+	producer := NewProducer(event.conn, event.producerName(), ProducerOptions{
 		Exchange: ProducerOptionsExchange{
 			Name: mo.Some(fmt.Sprintf("%s.event", appName)),
 			Kind: mo.Some(ExchangeKindTopic),
@@ -62,31 +69,78 @@ func NewEvent(appName, username, password, host, vhost string) (*Event, error) {
 }
 
 func (e *Event) SetConsumer(queueName string, bindings []ConsumerOptionsBinding) {
-	e.consumer = NewConsumer(e.conn, fmt.Sprintf("%s-consumer", e.appName), ConsumerOptions{
-		Queue: ConsumerOptionsQueue{
-			Name: queueName,
-		},
+	e.SetConsumerWithOptions(queueName, bindings, EventConsumerOptions{})
+}
+
+// SetConsumerWithOptions sets the consumer like SetConsumer, with a retry budget for the
+// failing messages, the handling of the poison messages, whose handler panics on every
+// attempt, and the priority support of the queue. The arguments of an existing queue cannot
+// change: a queue declared without x-max-priority must be deleted to add it.
+func (e *Event) SetConsumerWithOptions(queueName string, bindings []ConsumerOptionsBinding, opts EventConsumerOptions) {
+	e.consumerOptions = opts
+	e.poisonMessages = newPoisonMessagesCounter(queueName, opts.Registerer)
+
+	queue := ConsumerOptionsQueue{
+		Name: queueName,
+	}
+	if maxPriority, ok := opts.MaxPriority.Get(); ok && maxPriority > 0 {
+		e.SetMaxPriority(maxPriority)
+		queue.Args = mo.Some(amqp.Table{"x-max-priority": int32(e.maxPriority)})
+	}
+
+	e.consumer = NewConsumer(e.conn, e.consumerName(), ConsumerOptions{
+		Queue:    queue,
 		Bindings: bindings,
 		Message: ConsumerOptionsMessage{
 			PrefetchCount: mo.Some(1000),
 		},
 		EnableDeadLetter: mo.Some(true),
+		RetryStrategy:    opts.RetryStrategy,
 	})
 }
 
 func (e *Event) Publish(eventName string, payload Payload) error {
+	return e.PublishData(newEventData(eventName, payload))
+}
+
+// PublishData publishes an envelope built by the caller, routed by its name, e.g. to keep the
+// ID of an event dispatched in process too.
+func (e *Event) PublishData(data EventData) error {
+
+	msg, err := newPublishing(data)
+	if err != nil {
+		return err
+	}
+
+	if err = e.compress(&msg, data.Name); err != nil {
+		return err
+	}
 
-	body, _ := json.Marshal(EventData{
+	return e.producer.Publish(data.Name, false, false, msg)
+}
+
+// newEventData returns the envelope of an event, with a new ID.
+func newEventData(eventName string, payload Payload) EventData {
+	return EventData{
 		ID:      uuid.NewString(),
 		Name:    eventName,
 		Payload: payload,
-	})
+	}
+}
+
+// newPublishing returns the persistent JSON message of an envelope.
+func newPublishing(data EventData) (amqp.Publishing, error) {
 
-	return e.producer.Publish(eventName, false, false, amqp.Publishing{
+	body, err := json.Marshal(data)
+	if err != nil {
+		return amqp.Publishing{}, err
+	}
+
+	return amqp.Publishing{
 		ContentType:  "application/json",
 		DeliveryMode: amqp.Persistent,
 		Body:         body,
-	})
+	}, nil
 }
 
 func (e *Event) Consume(msg func(int64, *amqp.Delivery)) {
@@ -95,9 +149,31 @@ func (e *Event) Consume(msg func(int64, *amqp.Delivery)) {
 
 	var i int64 = 0
 	for m := range channel {
-		lo.Try0(func() { // handle exceptions
-			msg(i, m)
-		})
+		if err := Decompress(m); err != nil {
+			// the body stays unreadable on every attempt, the message is dead-lettered at once
+			logger(ScopeConsumer, e.consumerName(), "Could not decompress the message", map[string]any{"error": err.Error(), "messageId": m.MessageId})
+			if err = m.Reject(false); err != nil {
+				logger(ScopeConsumer, e.consumerName(), "Could not reject the message", map[string]any{"error": err.Error()})
+			}
+			i++
+			continue
+		}
+
+		e.handle(i, m, msg) // a panic nacks the message instead of leaving it unacked
 		i++
 	}
 }
+
+// CloseConsumer stops consuming: the channel of Consume is closed, so Consume returns once the
+// message being handled is done. The unacknowledged messages are requeued by the broker.
+func (e *Event) CloseConsumer() error {
+	if e.consumer == nil {
+		return nil
+	}
+	return e.consumer.Close()
+}
+
+// IsClosed reports whether the connection of the event is down, e.g. for readiness checks.
+func (e *Event) IsClosed() bool {
+	return e.conn.IsClosed()
+}
@@ -0,0 +1,149 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	applogger "github.com/a-aslani/wotop/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/samber/mo"
+)
+
+// PoisonHandler is called with a message whose handler panicked on its last attempt, before it
+// is dead-lettered, so the application can persist the offending payload.
+type PoisonHandler func(ctx context.Context, msg *amqp.Delivery, recovered any)
+
+// EventConsumerOptions are the options of the consumer of an Event, see SetConsumerWithOptions.
+type EventConsumerOptions struct {
+	// optional arguments
+	RetryStrategy mo.Option[RetryStrategy]         // default no retry, a nacked message is dead-lettered at once
+	Logger        mo.Option[applogger.Logger]      // default the logger of the package, see SetLogger
+	PoisonHandler mo.Option[PoisonHandler]         // default none
+	Registerer    mo.Option[prometheus.Registerer] // default no registration of the poison-message counter
+	MaxPriority   mo.Option[uint8]                 // default no priority, the x-max-priority of the queue, up to MaxPriority
+}
+
+// settlingAcknowledger records whether the handler acked, nacked or rejected the message.
+type settlingAcknowledger struct {
+	parent amqp.Acknowledger
+
+	mu      sync.Mutex
+	settled bool
+}
+
+func (a *settlingAcknowledger) Ack(tag uint64, multiple bool) error {
+	a.settle()
+	return a.parent.Ack(tag, multiple)
+}
+
+func (a *settlingAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	a.settle()
+	return a.parent.Nack(tag, multiple, requeue)
+}
+
+func (a *settlingAcknowledger) Reject(tag uint64, requeue bool) error {
+	a.settle()
+	return a.parent.Reject(tag, requeue)
+}
+
+func (a *settlingAcknowledger) settle() {
+	a.mu.Lock()
+	a.settled = true
+	a.mu.Unlock()
+}
+
+func (a *settlingAcknowledger) isSettled() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.settled
+}
+
+// handle runs the handler of a message and isolates its panics: the panic is logged with its
+// stack and the message is nacked without requeue, so it goes through the retry queue while the
+// retry budget lasts and to the dead-letter queue after. A message left unsettled by a panic
+// would otherwise be redelivered to the consumer in a loop.
+func (e *Event) handle(index int64, msg *amqp.Delivery, handler func(int64, *amqp.Delivery)) {
+	if msg.Acknowledger == nil {
+		handler(index, msg)
+		return
+	}
+
+	ack := &settlingAcknowledger{parent: msg.Acknowledger}
+	msg.Acknowledger = ack
+
+	defer func() {
+		recovered := recover()
+		msg.Acknowledger = ack.parent
+		if recovered == nil {
+			return
+		}
+
+		poison := e.lastAttempt(msg)
+		e.logPanic(msg, recovered, poison)
+
+		if poison {
+			e.poisonMessages.Inc()
+			e.consumerOptions.PoisonHandler.ForEach(func(h PoisonHandler) {
+				h(context.Background(), msg, recovered)
+			})
+		}
+
+		if ack.isSettled() {
+			return
+		}
+
+		if err := msg.Nack(false, false); err != nil {
+			logger(ScopeConsumer, e.consumerName(), "Could not nack the message of a panicking handler", map[string]any{"error": err.Error()})
+		}
+	}()
+
+	handler(index, msg)
+}
+
+// lastAttempt reports whether a nacked message is dead-lettered rather than retried.
+func (e *Event) lastAttempt(msg *amqp.Delivery) bool {
+	strategy, ok := e.consumerOptions.RetryStrategy.Get()
+	if !ok {
+		return true
+	}
+	_, retry := strategy.NextBackOff(msg, GetAttempts(msg))
+	return !retry
+}
+
+// logPanic logs the panic of a handler with its stack.
+func (e *Event) logPanic(msg *amqp.Delivery, recovered any, poison bool) {
+	outcome := "retried"
+	if poison {
+		outcome = "dead-lettered"
+	}
+
+	message := fmt.Sprintf("handler of message %q (%s) panicked after %d retries, it is %s: %v\n%s",
+		msg.MessageId, msg.RoutingKey, GetAttempts(msg), outcome, recovered, debug.Stack())
+
+	if l, ok := e.consumerOptions.Logger.Get(); ok {
+		l.Error(context.Background(), "%s", message)
+		return
+	}
+	logger(ScopeConsumer, e.consumerName(), message, map[string]any{"panic": recovered})
+}
+
+func (e *Event) consumerName() string {
+	return fmt.Sprintf("%s-consumer", e.appName)
+}
+
+// newPoisonMessagesCounter creates the counter of the dead-lettered messages whose handler
+// panicked, registered when a registerer is given.
+func newPoisonMessagesCounter(queueName string, reg mo.Option[prometheus.Registerer]) prometheus.Counter {
+	counter := prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        "pubsub_poison_messages_total",
+		Help:        "Messages dead-lettered because their handler panicked on every attempt.",
+		ConstLabels: prometheus.Labels{"queue": queueName},
+	})
+	reg.ForEach(func(r prometheus.Registerer) {
+		r.MustRegister(counter)
+	})
+	return counter
+}
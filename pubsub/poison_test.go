@@ -0,0 +1,158 @@
+package pubsub
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	applogger "github.com/a-aslani/wotop/logger"
+	"github.com/a-aslani/wotop/logger/loggertest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/samber/mo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingAcknowledger records how the messages are settled.
+type recordingAcknowledger struct {
+	acks     int
+	nacks    int
+	requeues int
+}
+
+func (a *recordingAcknowledger) Ack(uint64, bool) error {
+	a.acks++
+	return nil
+}
+
+func (a *recordingAcknowledger) Nack(_ uint64, _ bool, requeue bool) error {
+	a.nacks++
+	if requeue {
+		a.requeues++
+	}
+	return nil
+}
+
+func (a *recordingAcknowledger) Reject(_ uint64, requeue bool) error {
+	return a.Nack(0, false, requeue)
+}
+
+func newTestEvent(opts EventConsumerOptions) *Event {
+	return &Event{
+		appName:         "shop",
+		consumerOptions: opts,
+		poisonMessages:  newPoisonMessagesCounter("orders", opts.Registerer),
+	}
+}
+
+// deliverUntilSettled delivers the message like the broker and the retry queue: a nacked
+// message comes back with one more attempt while the retry strategy allows it, and goes to the
+// dead-letter queue otherwise.
+func deliverUntilSettled(t *testing.T, e *Event, strategy RetryStrategy, handler func(int64, *amqp.Delivery)) (deliveries int, deadLettered bool) {
+	t.Helper()
+
+	for attempts := 0; deliveries < 100; attempts++ {
+		ack := &recordingAcknowledger{}
+		msg := &amqp.Delivery{
+			Acknowledger: ack,
+			MessageId:    "m-1",
+			RoutingKey:   "order.created",
+			Headers:      amqp.Table{"x-retry-attempts": strconv.Itoa(attempts)},
+			Body:         []byte(`{"id":"o-1"}`),
+		}
+
+		e.handle(int64(deliveries), msg, handler)
+		deliveries++
+
+		require.Zero(t, ack.requeues, "a panicking message must not be requeued")
+		if ack.acks > 0 {
+			return deliveries, false
+		}
+		require.Equal(t, 1, ack.nacks, "the message must be settled once")
+
+		if strategy == nil {
+			return deliveries, true
+		}
+		if _, retry := strategy.NextBackOff(msg, attempts); !retry {
+			return deliveries, true
+		}
+	}
+	return deliveries, false
+}
+
+func TestEventPanickingHandlerIsDeadLettered(t *testing.T) {
+	log := &loggertest.Recorder{}
+	strategy := NewConstantRetryStrategy(3, time.Second)
+	reg := prometheus.NewRegistry()
+
+	var poisoned []string
+	e := newTestEvent(EventConsumerOptions{
+		RetryStrategy: mo.Some(strategy),
+		Logger:        mo.Some[applogger.Logger](log),
+		Registerer:    mo.Some[prometheus.Registerer](reg),
+		PoisonHandler: mo.Some[PoisonHandler](func(_ context.Context, msg *amqp.Delivery, recovered any) {
+			poisoned = append(poisoned, string(msg.Body)+" "+recovered.(string))
+		}),
+	})
+
+	calls := 0
+	deliveries, deadLettered := deliverUntilSettled(t, e, strategy, func(int64, *amqp.Delivery) {
+		calls++
+		panic("nil order")
+	})
+
+	assert.True(t, deadLettered)
+	assert.Equal(t, 4, deliveries, "the first delivery and 3 retries")
+	assert.Equal(t, 4, calls)
+	assert.Equal(t, []string{`{"id":"o-1"} nil order`}, poisoned)
+	assert.Equal(t, 1.0, testutil.ToFloat64(e.poisonMessages))
+	assert.Equal(t, 1, testutil.CollectAndCount(reg, "pubsub_poison_messages_total"))
+
+	require.Len(t, log.Messages(applogger.LevelError), 4)
+	assert.Contains(t, log.Messages(applogger.LevelError)[0], "it is retried: nil order")
+	assert.Contains(t, log.Messages(applogger.LevelError)[3], "it is dead-lettered: nil order")
+	assert.Contains(t, log.Messages(applogger.LevelError)[3], "poison.go", "the stack is logged")
+}
+
+func TestEventPanickingHandlerWithoutRetry(t *testing.T) {
+	e := newTestEvent(EventConsumerOptions{Logger: mo.Some[applogger.Logger](&loggertest.Recorder{})})
+
+	deliveries, deadLettered := deliverUntilSettled(t, e, nil, func(int64, *amqp.Delivery) {
+		panic("nil order")
+	})
+
+	assert.True(t, deadLettered)
+	assert.Equal(t, 1, deliveries)
+	assert.Equal(t, 1.0, testutil.ToFloat64(e.poisonMessages))
+}
+
+func TestEventHandlerSettlingBeforePanic(t *testing.T) {
+	e := newTestEvent(EventConsumerOptions{Logger: mo.Some[applogger.Logger](&loggertest.Recorder{})})
+
+	// the handler acked the message, it must not be nacked on top
+	deliveries, deadLettered := deliverUntilSettled(t, e, nil, func(_ int64, msg *amqp.Delivery) {
+		_ = msg.Ack(false)
+		panic("after ack")
+	})
+
+	assert.False(t, deadLettered)
+	assert.Equal(t, 1, deliveries)
+}
+
+func TestEventHandlerWithoutPanic(t *testing.T) {
+	e := newTestEvent(EventConsumerOptions{})
+
+	ack := &recordingAcknowledger{}
+	msg := &amqp.Delivery{Acknowledger: ack}
+	e.handle(0, msg, func(_ int64, msg *amqp.Delivery) {
+		_ = msg.Ack(false)
+	})
+
+	assert.Equal(t, 1, ack.acks)
+	assert.Zero(t, ack.nacks)
+	assert.Same(t, ack, msg.Acknowledger, "the acknowledger is restored")
+	assert.Zero(t, testutil.ToFloat64(e.poisonMessages))
+}
@@ -0,0 +1,134 @@
+package pubsub
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// MaxPriority is the highest priority of the messages, RabbitMQ advises against more levels.
+const MaxPriority uint8 = 9
+
+// PublishOption sets a property of a message published with PublishWithOptions.
+type PublishOption func(*publishOptions)
+
+// publishOptions holds the properties set by the PublishOption.
+type publishOptions struct {
+	priority   *uint8
+	expiration *time.Duration
+}
+
+// WithPriority publishes the message with a priority, from 0 to MaxPriority, so the consumers
+// of a priority queue get it before the messages of lower priority waiting in the queue, e.g.
+// a password-reset email before the bulk notifications. The priorities above MaxPriority are
+// lowered to it.
+//
+// Parameters:
+//   - priority: The priority, 0 being the lowest.
+//
+// Returns:
+//   - The option setting the priority.
+func WithPriority(priority uint8) PublishOption {
+	return func(o *publishOptions) {
+		p := min(priority, MaxPriority)
+		o.priority = &p
+	}
+}
+
+// WithExpiration publishes the message with a time to live: the broker drops it when it is
+// still queued after ttl, e.g. a presence ping which is worthless once stale.
+//
+// Parameters:
+//   - ttl: The time to live, rounded down to the millisecond.
+//
+// Returns:
+//   - The option setting the expiration.
+func WithExpiration(ttl time.Duration) PublishOption {
+	return func(o *publishOptions) {
+		o.expiration = &ttl
+	}
+}
+
+// SetMaxPriority declares that the queues the events are routed to support priorities up to
+// max, so the options of PublishWithOptions are applied. SetConsumerWithOptions sets it for the
+// queue it declares with EventConsumerOptions.MaxPriority, a service only publishing calls it
+// with the max priority of the queues of its consumers.
+//
+// Parameters:
+//   - max: The x-max-priority of the queues, from 1 to MaxPriority.
+func (e *Event) SetMaxPriority(max uint8) {
+	e.maxPriority = min(max, MaxPriority)
+}
+
+// PublishWithOptions publishes an event like Publish, with the priority or the expiration of
+// the options. The options are ignored with a warning when the priority support of the queues
+// is not declared, see SetMaxPriority: the broker would queue the message as any other.
+//
+// Parameters:
+//   - eventName: The name of the event, its routing key.
+//   - payload: The payload of the event.
+//   - opts: The options of the message, see WithPriority and WithExpiration.
+//
+// Returns:
+//   - An error if the message cannot be published.
+func (e *Event) PublishWithOptions(eventName string, payload Payload, opts ...PublishOption) error {
+	return e.PublishDataWithOptions(newEventData(eventName, payload), opts...)
+}
+
+// PublishDataWithOptions publishes an envelope like PublishData, with the options of
+// PublishWithOptions.
+//
+// Parameters:
+//   - data: The envelope, routed by its name.
+//   - opts: The options of the message, see WithPriority and WithExpiration.
+//
+// Returns:
+//   - An error if the message cannot be published.
+func (e *Event) PublishDataWithOptions(data EventData, opts ...PublishOption) error {
+
+	msg, err := newPublishing(data)
+	if err != nil {
+		return err
+	}
+
+	e.applyOptions(&msg, data.Name, opts)
+
+	if err = e.compress(&msg, data.Name); err != nil {
+		return err
+	}
+
+	return e.producer.Publish(data.Name, false, false, msg)
+}
+
+// applyOptions sets the properties of the options on a message, unless the queues have no
+// priority support.
+func (e *Event) applyOptions(msg *amqp.Publishing, eventName string, opts []PublishOption) {
+
+	if len(opts) == 0 {
+		return
+	}
+
+	if e.maxPriority == 0 {
+		logger(ScopeProducer, e.producerName(), fmt.Sprintf("Publish options of %s ignored, the queues are not declared with priority support", eventName), nil)
+		return
+	}
+
+	var o publishOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.priority != nil {
+		msg.Priority = min(*o.priority, e.maxPriority)
+	}
+	if o.expiration != nil {
+		msg.Expiration = strconv.FormatInt(max(o.expiration.Milliseconds(), 0), 10)
+	}
+}
+
+// producerName returns the name of the producer of the event.
+func (e *Event) producerName() string {
+	return fmt.Sprintf("%s-producer", e.appName)
+}
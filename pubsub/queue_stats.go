@@ -0,0 +1,147 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// queueInspector is the part of *amqp.Channel inspecting a queue, stubbed by the tests.
+type queueInspector interface {
+	QueueDeclarePassive(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	Close() error
+}
+
+// QueueStats returns the number of messages ready in the queue of the consumer, see
+// SetConsumer, and the number of its consumers. A passive declaration fails on a missing
+// queue, so it is done on a channel of its own.
+func (e *Event) QueueStats(ctx context.Context) (messages, consumers int, err error) {
+	if e.consumer == nil {
+		return 0, 0, errors.New("pubsub: the event has no consumer")
+	}
+	queueName := e.consumer.options.Queue.Name
+
+	open := e.openInspector
+	if open == nil {
+		open = e.openChannel
+	}
+
+	type stats struct {
+		queue amqp.Queue
+		err   error
+	}
+	done := make(chan stats, 1)
+
+	go func() {
+		ch, err := open()
+		if err != nil {
+			done <- stats{err: err}
+			return
+		}
+
+		queue, err := ch.QueueDeclarePassive(queueName, true, false, false, false, nil)
+		_ = ch.Close()
+		done <- stats{queue: queue, err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return 0, 0, ctx.Err()
+	case s := <-done:
+		if s.err != nil {
+			return 0, 0, fmt.Errorf("inspect queue %s: %w", queueName, s.err)
+		}
+		return s.queue.Messages, s.queue.Consumers, nil
+	}
+}
+
+// openChannel opens a channel on the connection of the event.
+func (e *Event) openChannel() (queueInspector, error) {
+	e.conn.channelsMutex.Lock()
+	conn := e.conn.conn
+	e.conn.channelsMutex.Unlock()
+
+	if conn == nil || conn.IsClosed() {
+		return nil, amqp.ErrClosed
+	}
+	return conn.Channel()
+}
+
+// QueueStatser reports the stats of a queue, *Event implements it.
+type QueueStatser interface {
+	QueueStats(ctx context.Context) (messages, consumers int, err error)
+}
+
+// QueueStatsReporter pushes the depth and the consumer count of a queue into the Prometheus
+// gauges pubsub_queue_depth and pubsub_consumer_count, labeled by queue.
+type QueueStatsReporter struct {
+	source    QueueStatser
+	depth     prometheus.Gauge
+	consumers prometheus.Gauge
+	onError   func(err error)
+}
+
+// NewQueueStatsReporter creates the reporter of the stats of a queue, its gauges are
+// registered with reg unless it is nil.
+func NewQueueStatsReporter(source QueueStatser, queueName string, reg prometheus.Registerer) (*QueueStatsReporter, error) {
+	r := &QueueStatsReporter{
+		source: source,
+		depth: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name:        "pubsub_queue_depth",
+			Help:        "Messages ready in the queue, waiting for a consumer.",
+			ConstLabels: prometheus.Labels{"queue": queueName},
+		}),
+		consumers: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name:        "pubsub_consumer_count",
+			Help:        "Consumers of the queue.",
+			ConstLabels: prometheus.Labels{"queue": queueName},
+		}),
+		onError: func(err error) {
+			logger(ScopeQueue, queueName, "Queue inspection failure", map[string]any{"error": err.Error()})
+		},
+	}
+
+	if reg != nil {
+		for _, c := range []prometheus.Collector{r.depth, r.consumers} {
+			if err := reg.Register(c); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return r, nil
+}
+
+// Report reads the stats of the queue once and sets the gauges.
+func (r *QueueStatsReporter) Report(ctx context.Context) error {
+	messages, consumers, err := r.source.QueueStats(ctx)
+	if err != nil {
+		return err
+	}
+	r.depth.Set(float64(messages))
+	r.consumers.Set(float64(consumers))
+	return nil
+}
+
+// Run reports the stats every interval until the context is done, the failures are logged
+// and the gauges keep their last values.
+func (r *QueueStatsReporter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.Report(ctx); err != nil && ctx.Err() == nil {
+			r.onError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubInspector is a queueInspector returning synthetic stats.
+type stubInspector struct {
+	mu       sync.Mutex
+	queue    amqp.Queue
+	err      error
+	declared []string
+	closed   int
+}
+
+func (s *stubInspector) QueueDeclarePassive(name string, _, _, _, _ bool, _ amqp.Table) (amqp.Queue, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.declared = append(s.declared, name)
+	q := s.queue
+	q.Name = name
+	return q, s.err
+}
+
+func (s *stubInspector) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed++
+	return nil
+}
+
+func (s *stubInspector) set(messages, consumers int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queue = amqp.Queue{Messages: messages, Consumers: consumers}
+}
+
+func newStatsEvent(inspector queueInspector) *Event {
+	return &Event{
+		appName:       "shop",
+		consumer:      &Consumer{options: ConsumerOptions{Queue: ConsumerOptionsQueue{Name: "orders"}}},
+		openInspector: func() (queueInspector, error) { return inspector, nil },
+	}
+}
+
+func TestEventQueueStats(t *testing.T) {
+	inspector := &stubInspector{}
+	inspector.set(42, 3)
+
+	messages, consumers, err := newStatsEvent(inspector).QueueStats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 42, messages)
+	assert.Equal(t, 3, consumers)
+	assert.Equal(t, []string{"orders"}, inspector.declared)
+	assert.Equal(t, 1, inspector.closed, "the channel of the inspection is closed")
+
+	inspector.err = &amqp.Error{Code: amqp.NotFound, Reason: "NOT_FOUND - no queue 'orders'"}
+	_, _, err = newStatsEvent(inspector).QueueStats(context.Background())
+	assert.ErrorContains(t, err, "inspect queue orders")
+	assert.ErrorIs(t, err, inspector.err)
+
+	_, _, err = (&Event{}).QueueStats(context.Background())
+	assert.Error(t, err)
+
+	failing := &Event{
+		consumer:      &Consumer{options: ConsumerOptions{Queue: ConsumerOptionsQueue{Name: "orders"}}},
+		openInspector: func() (queueInspector, error) { return nil, amqp.ErrClosed },
+	}
+	_, _, err = failing.QueueStats(context.Background())
+	assert.ErrorIs(t, err, amqp.ErrClosed)
+}
+
+func TestQueueStatsReporter(t *testing.T) {
+	inspector := &stubInspector{}
+	inspector.set(7, 2)
+
+	reg := prometheus.NewRegistry()
+	reporter, err := NewQueueStatsReporter(newStatsEvent(inspector), "orders", reg)
+	require.NoError(t, err)
+
+	require.NoError(t, reporter.Report(context.Background()))
+	assert.Equal(t, 7.0, testutil.ToFloat64(reporter.depth))
+	assert.Equal(t, 2.0, testutil.ToFloat64(reporter.consumers))
+
+	count, err := testutil.GatherAndCount(reg, "pubsub_queue_depth", "pubsub_consumer_count")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	// a failed inspection keeps the last values
+	inspector.err = errors.New("channel closed")
+	assert.Error(t, reporter.Report(context.Background()))
+	assert.Equal(t, 7.0, testutil.ToFloat64(reporter.depth))
+
+	// the reporter of another queue registers its own gauges
+	_, err = NewQueueStatsReporter(newStatsEvent(inspector), "payments", reg)
+	require.NoError(t, err)
+}
+
+func TestQueueStatsReporterRun(t *testing.T) {
+	inspector := &stubInspector{}
+	inspector.set(1, 1)
+
+	reporter, err := NewQueueStatsReporter(newStatsEvent(inspector), "orders", nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		reporter.Run(ctx, time.Millisecond)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(reporter.depth) == 1
+	}, time.Second, time.Millisecond)
+
+	inspector.set(250, 1)
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(reporter.depth) == 250
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,300 @@
+package rabbitmq_controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/logger"
+	"github.com/a-aslani/wotop/pubsub"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/samber/mo"
+)
+
+// DefaultShutdownTimeout is the time the message being handled gets to finish when the
+// consumer is shut down.
+const DefaultShutdownTimeout = 5 * time.Second
+
+// Event is the part of *pubsub.Event the controller consumes with, tests stub it.
+type Event interface {
+	SetConsumerWithOptions(queueName string, bindings []pubsub.ConsumerOptionsBinding, opts pubsub.EventConsumerOptions)
+	Consume(msg func(int64, *amqp.Delivery))
+	CloseConsumer() error
+}
+
+// Binding binds the queue of the consumer to the messages of an exchange.
+//
+// Fields:
+//   - Exchange: The exchange, "<app name>.event" when empty, the exchange of pubsub.NewEvent.
+//   - RoutingKey: The routing key, the name of the event for the exchanges of pubsub.Event.
+type Binding struct {
+	Exchange   string `mapstructure:"exchange,omitempty"`
+	RoutingKey string `mapstructure:"routing_key"`
+}
+
+// Config configures the consumer controller.
+//
+// Fields:
+//   - Queue: The name of the queue, "<app name>.consumer" when empty.
+//   - Bindings: The bindings of the queue, the events with a registered consumer when empty.
+//   - Options: The retry budget and the handling of the poison messages.
+//   - MaxPriority: The x-max-priority of the queue, up to pubsub.MaxPriority, no priority when
+//     zero. It sets Options.MaxPriority when that is empty.
+//   - ShutdownTimeout: The time the message being handled gets to finish, DefaultShutdownTimeout when zero.
+type Config struct {
+	Queue           string                      `mapstructure:"queue"`
+	Bindings        []Binding                   `mapstructure:"bindings"`
+	Options         pubsub.EventConsumerOptions `mapstructure:"-"`
+	MaxPriority     uint8                       `mapstructure:"max_priority"`
+	ShutdownTimeout time.Duration               `mapstructure:"shutdown_timeout"`
+}
+
+// MessageConsumer consumes the messages of an event, see Controller.Handle.
+type MessageConsumer interface {
+	// ConsumeMessage handles the message and acks, nacks or rejects it.
+	//
+	// Parameters:
+	//   - index: The index of the message since the consumer started.
+	//   - msg: The message to be consumed.
+	ConsumeMessage(index int, msg *amqp.Delivery)
+}
+
+// MessageConsumerFunc adapts a function to a MessageConsumer.
+type MessageConsumerFunc func(index int, msg *amqp.Delivery)
+
+// ConsumeMessage calls f.
+func (f MessageConsumerFunc) ConsumeMessage(index int, msg *amqp.Delivery) {
+	f(index, msg)
+}
+
+// Controller consumes the events of the application from RabbitMQ, it implements
+// wotop.RabbitmqConsumerRegisterer. The message of each event is dispatched to the
+// MessageConsumer registered for its name with Handle, usually an adapter executing the
+// inport of a usecase registered with AddUsecase, see Inport.
+//
+// Start blocks until SIGINT or SIGTERM is received or Stop is called, so the controller can
+// run alone or be registered on a wotop.Lifecycle.
+type Controller struct {
+	wotop.UsecaseRegisterer
+	event   Event
+	log     logger.Logger
+	cfg     Config
+	appData wotop.ApplicationData
+
+	consumers map[string]MessageConsumer
+
+	mu       sync.Mutex
+	stopping bool
+	inFlight sync.WaitGroup
+
+	consumed chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+var _ wotop.RabbitmqConsumerRegisterer = (*Controller)(nil)
+
+// NewConsumerController creates the consumer controller of the application.
+//
+// Parameters:
+//   - appData: The data of the application.
+//   - log: The logger of the controller.
+//   - cfg: The configuration of the controller.
+//   - event: The event connection, e.g. the *pubsub.Event of pubsub.NewEvent.
+//
+// Returns:
+//   - A new Controller.
+func NewConsumerController(appData wotop.ApplicationData, log logger.Logger, cfg Config, event Event) *Controller {
+
+	if cfg.Queue == "" {
+		cfg.Queue = fmt.Sprintf("%s.consumer", appData.AppName)
+	}
+	if cfg.ShutdownTimeout == 0 {
+		cfg.ShutdownTimeout = DefaultShutdownTimeout
+	}
+
+	return &Controller{
+		UsecaseRegisterer: wotop.NewBaseConsumer(),
+		event:             event,
+		log:               log,
+		cfg:               cfg,
+		appData:           appData,
+		consumers:         map[string]MessageConsumer{},
+		consumed:          make(chan struct{}),
+		stopped:           make(chan struct{}),
+	}
+}
+
+// Handle registers the consumer of the messages of an event. It must be called before Start,
+// and panics when the event has a consumer already.
+//
+// Parameters:
+//   - eventName: The name of the event, as published by pubsub.Event.Publish.
+//   - consumer: The consumer of its messages.
+func (c *Controller) Handle(eventName string, consumer MessageConsumer) {
+	if _, ok := c.consumers[eventName]; ok {
+		panic(fmt.Sprintf("rabbitmq_controller: event %q has a consumer already", eventName))
+	}
+	c.consumers[eventName] = consumer
+}
+
+// bindings returns the configured bindings, or the bindings of the events with a consumer.
+func (c *Controller) bindings() []pubsub.ConsumerOptionsBinding {
+	exchange := fmt.Sprintf("%s.event", c.appData.AppName)
+
+	bindings := c.cfg.Bindings
+	if len(bindings) == 0 {
+		for name := range c.consumers {
+			bindings = append(bindings, Binding{RoutingKey: name})
+		}
+	}
+
+	result := make([]pubsub.ConsumerOptionsBinding, 0, len(bindings))
+	for _, b := range bindings {
+		if b.Exchange == "" {
+			b.Exchange = exchange
+		}
+		result = append(result, pubsub.ConsumerOptionsBinding{ExchangeName: b.Exchange, RoutingKey: b.RoutingKey})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].RoutingKey < result[j].RoutingKey })
+	return result
+}
+
+// Start sets up the consumer of the queue with its bindings and dispatches the messages to
+// ConsumeMessage. It blocks until SIGINT or SIGTERM is received, then stops the consumer
+// gracefully, or until Stop is called.
+func (c *Controller) Start() {
+
+	ctx := context.Background()
+
+	select {
+	case <-c.stopped:
+		return
+	default:
+	}
+
+	opts := c.cfg.Options
+	if opts.MaxPriority.IsAbsent() && c.cfg.MaxPriority > 0 {
+		opts.MaxPriority = mo.Some(c.cfg.MaxPriority)
+	}
+	c.event.SetConsumerWithOptions(c.cfg.Queue, c.bindings(), opts)
+
+	go func() {
+		defer close(c.consumed)
+		c.log.Info(ctx, "consuming queue %s", c.cfg.Queue)
+		c.event.Consume(func(index int64, msg *amqp.Delivery) {
+			c.ConsumeMessage(int(index), msg)
+		})
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(quit)
+
+	select {
+	case <-quit:
+	case <-c.stopped:
+		<-c.consumed
+		return
+	}
+
+	c.log.Info(ctx, "Shutting down consumer...")
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, c.cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := c.Stop(shutdownCtx); err != nil {
+		c.log.Error(ctx, "consumer forced to shutdown: %v", err)
+	}
+
+	c.log.Info(ctx, "Consumer stopped.")
+}
+
+// Stop stops dispatching the messages, waits for the message being handled and closes the
+// consumer, it is called by wotop.Lifecycle. The messages received after Stop are left
+// unacknowledged, so the broker requeues them.
+//
+// Parameters:
+//   - ctx: The context bounding the shutdown.
+//
+// Returns:
+//   - The error of the context when the message being handled did not finish in time, or the
+//     error closing the consumer.
+func (c *Controller) Stop(ctx context.Context) error {
+	c.mu.Lock()
+	c.stopping = true
+	c.mu.Unlock()
+
+	var err error
+	c.stopOnce.Do(func() {
+		defer close(c.stopped)
+
+		handled := make(chan struct{})
+		go func() {
+			c.inFlight.Wait()
+			close(handled)
+		}()
+
+		select {
+		case <-handled:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+
+		if closeErr := c.event.CloseConsumer(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	})
+	return err
+}
+
+// ConsumeMessage dispatches the message to the consumer of its event. The name of the event
+// is read from the body published by pubsub.Event.Publish, the routing key is used for other
+// bodies; it is not reliable after a retry, which routes the message through the retry queue.
+// The messages without a consumer are rejected, so they go to the dead-letter queue.
+//
+// Parameters:
+//   - index: The index of the message since the consumer started.
+//   - msg: The message to be consumed.
+func (c *Controller) ConsumeMessage(index int, msg *amqp.Delivery) {
+
+	c.mu.Lock()
+	if c.stopping {
+		c.mu.Unlock()
+		return
+	}
+	c.inFlight.Add(1)
+	c.mu.Unlock()
+	defer c.inFlight.Done()
+
+	name := eventName(msg)
+
+	consumer, ok := c.consumers[name]
+	if !ok {
+		c.log.Error(context.Background(), "no consumer for event %q of message %q, it is rejected", name, msg.MessageId)
+		if err := msg.Reject(false); err != nil {
+			c.log.Error(context.Background(), "reject message: %v", err)
+		}
+		return
+	}
+
+	consumer.ConsumeMessage(index, msg)
+}
+
+// eventName returns the name of the event of the message.
+func eventName(msg *amqp.Delivery) string {
+	var data struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(msg.Body, &data); err == nil && data.Name != "" {
+		return data.Name
+	}
+	return msg.RoutingKey
+}
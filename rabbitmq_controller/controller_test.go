@@ -0,0 +1,325 @@
+package rabbitmq_controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/pubsub"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubEvent is an Event delivering the messages sent on its channel.
+type stubEvent struct {
+	queue    string
+	bindings []pubsub.ConsumerOptionsBinding
+	set      chan struct{}
+
+	deliveries chan *amqp.Delivery
+	closeOnce  sync.Once
+}
+
+func newStubEvent() *stubEvent {
+	return &stubEvent{set: make(chan struct{}), deliveries: make(chan *amqp.Delivery)}
+}
+
+func (e *stubEvent) SetConsumerWithOptions(queueName string, bindings []pubsub.ConsumerOptionsBinding, _ pubsub.EventConsumerOptions) {
+	e.queue = queueName
+	e.bindings = bindings
+	close(e.set)
+}
+
+func (e *stubEvent) Consume(msg func(int64, *amqp.Delivery)) {
+	var i int64
+	for m := range e.deliveries {
+		msg(i, m)
+		i++
+	}
+}
+
+func (e *stubEvent) CloseConsumer() error {
+	e.closeOnce.Do(func() { close(e.deliveries) })
+	return nil
+}
+
+// settlement records how a message is settled.
+type settlement struct {
+	mu      sync.Mutex
+	outcome string
+}
+
+func (s *settlement) Ack(uint64, bool) error        { return s.settle("ack") }
+func (s *settlement) Nack(uint64, bool, bool) error { return s.settle("nack") }
+func (s *settlement) Reject(uint64, bool) error     { return s.settle("reject") }
+
+func (s *settlement) settle(outcome string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.outcome = outcome
+	return nil
+}
+
+func (s *settlement) get() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.outcome
+}
+
+// nopLogger discards the logs.
+type nopLogger struct{}
+
+func (nopLogger) Info(context.Context, string, ...any)    {}
+func (nopLogger) Error(context.Context, string, ...any)   {}
+func (nopLogger) Warning(context.Context, string, ...any) {}
+
+// newMessage returns a message published by pubsub.Event.Publish and its settlement.
+func newMessage(t *testing.T, name string, payload any) (*amqp.Delivery, *settlement) {
+	t.Helper()
+	body, err := json.Marshal(pubsub.EventData{ID: "evt-1", Name: name, Payload: payload})
+	require.NoError(t, err)
+
+	s := &settlement{}
+	return &amqp.Delivery{Acknowledger: s, RoutingKey: name, Body: body}, s
+}
+
+func TestControllerBindings(t *testing.T) {
+	appData := wotop.ApplicationData{AppName: "shop"}
+	noop := MessageConsumerFunc(func(int, *amqp.Delivery) {})
+
+	c := NewConsumerController(appData, nopLogger{}, Config{}, newStubEvent())
+	c.Handle("product.created", noop)
+	c.Handle("order.paid", noop)
+
+	assert.Equal(t, "shop.consumer", c.cfg.Queue)
+	assert.Equal(t, []pubsub.ConsumerOptionsBinding{
+		{ExchangeName: "shop.event", RoutingKey: "order.paid"},
+		{ExchangeName: "shop.event", RoutingKey: "product.created"},
+	}, c.bindings())
+
+	c = NewConsumerController(appData, nopLogger{}, Config{
+		Queue:    "shop.billing",
+		Bindings: []Binding{{RoutingKey: "order.paid"}, {Exchange: "payment.event", RoutingKey: "payment.refunded"}},
+	}, newStubEvent())
+	assert.Equal(t, "shop.billing", c.cfg.Queue)
+	assert.Equal(t, []pubsub.ConsumerOptionsBinding{
+		{ExchangeName: "shop.event", RoutingKey: "order.paid"},
+		{ExchangeName: "payment.event", RoutingKey: "payment.refunded"},
+	}, c.bindings())
+
+	assert.Panics(t, func() { c.Handle("order.paid", noop); c.Handle("order.paid", noop) })
+}
+
+func TestControllerDispatch(t *testing.T) {
+	c := NewConsumerController(wotop.ApplicationData{AppName: "shop"}, nopLogger{}, Config{}, newStubEvent())
+
+	var consumed []string
+	c.Handle("order.paid", MessageConsumerFunc(func(index int, msg *amqp.Delivery) {
+		consumed = append(consumed, msg.RoutingKey)
+		_ = msg.Ack(false)
+	}))
+
+	msg, s := newMessage(t, "order.paid", nil)
+	c.ConsumeMessage(0, msg)
+	assert.Equal(t, "ack", s.get())
+
+	// after a retry the routing key is the name of the queue, the name is read from the body
+	msg, s = newMessage(t, "order.paid", nil)
+	msg.RoutingKey = "shop.consumer"
+	c.ConsumeMessage(1, msg)
+	assert.Equal(t, "ack", s.get())
+	assert.Equal(t, []string{"order.paid", "shop.consumer"}, consumed)
+
+	msg, s = newMessage(t, "order.refunded", nil)
+	c.ConsumeMessage(2, msg)
+	assert.Equal(t, "reject", s.get())
+}
+
+func TestControllerLifecycle(t *testing.T) {
+	event := newStubEvent()
+	c := NewConsumerController(wotop.ApplicationData{AppName: "shop"}, nopLogger{}, Config{}, event)
+
+	handling := make(chan struct{})
+	release := make(chan struct{})
+	c.Handle("order.paid", MessageConsumerFunc(func(index int, msg *amqp.Delivery) {
+		close(handling)
+		<-release
+		_ = msg.Ack(false)
+	}))
+
+	returned := make(chan struct{})
+	go func() {
+		c.Start()
+		close(returned)
+	}()
+
+	<-event.set
+	assert.Equal(t, "shop.consumer", event.queue)
+	assert.Len(t, event.bindings, 1)
+
+	msg, s := newMessage(t, "order.paid", nil)
+	event.deliveries <- msg
+	<-handling
+
+	stopped := make(chan error)
+	go func() { stopped <- c.Stop(context.Background()) }()
+
+	// Stop waits for the message being handled
+	select {
+	case <-stopped:
+		t.Fatal("Stop returned while a message is handled")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	require.NoError(t, <-stopped)
+	assert.Equal(t, "ack", s.get())
+
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Fatal("Start did not return")
+	}
+
+	// a message received while stopping is left to the broker
+	late, ls := newMessage(t, "order.paid", nil)
+	c.ConsumeMessage(1, late)
+	assert.Empty(t, ls.get())
+}
+
+func TestControllerStopTimeout(t *testing.T) {
+	event := newStubEvent()
+	c := NewConsumerController(wotop.ApplicationData{AppName: "shop"}, nopLogger{}, Config{}, event)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	c.Handle("order.paid", MessageConsumerFunc(func(int, *amqp.Delivery) {
+		close(started)
+		<-release
+	}))
+
+	go c.Start()
+	<-event.set
+
+	msg, _ := newMessage(t, "order.paid", nil)
+	event.deliveries <- msg
+
+	// the message is in flight once its handler runs
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, c.Stop(ctx), context.DeadlineExceeded)
+}
+
+// placeOrderRequest is the request of the placeOrder usecase.
+type placeOrderRequest struct {
+	OrderID string `json:"order_id"`
+}
+
+type placeOrderResponse struct{}
+
+// placeOrder is an interactor recording the requests, it fails for order "o-fail".
+type placeOrder struct {
+	mu       sync.Mutex
+	requests []placeOrderRequest
+	traceIDs []string
+}
+
+func (p *placeOrder) Execute(ctx context.Context, req placeOrderRequest) (*placeOrderResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests = append(p.requests, req)
+	traceID, _ := wotop.TraceIDFromContext(ctx)
+	p.traceIDs = append(p.traceIDs, traceID)
+	if req.OrderID == "o-fail" {
+		return nil, errors.New("out of stock")
+	}
+	return &placeOrderResponse{}, nil
+}
+
+func TestInport(t *testing.T) {
+	c := NewConsumerController(wotop.ApplicationData{AppName: "shop"}, nopLogger{}, Config{}, newStubEvent())
+
+	interactor := &placeOrder{}
+	c.AddUsecase(wotop.Inport[placeOrderRequest, placeOrderResponse](interactor))
+	c.Handle("order.placed", Inport[placeOrderRequest, placeOrderResponse](c))
+
+	msg, s := newMessage(t, "order.placed", placeOrderRequest{OrderID: "o-1"})
+	c.ConsumeMessage(0, msg)
+	assert.Equal(t, "ack", s.get())
+	assert.Equal(t, []placeOrderRequest{{OrderID: "o-1"}}, interactor.requests)
+	assert.Equal(t, []string{"evt-1"}, interactor.traceIDs)
+
+	msg, s = newMessage(t, "order.placed", placeOrderRequest{OrderID: "o-fail"})
+	c.ConsumeMessage(1, msg)
+	assert.Equal(t, "nack", s.get())
+
+	msg, s = newMessage(t, "order.placed", "not an order")
+	c.ConsumeMessage(2, msg)
+	assert.Equal(t, "reject", s.get())
+	assert.Len(t, interactor.requests, 2)
+}
+
+// cartCheckedOut is the payload of an event mapped to a placeOrderRequest.
+type cartCheckedOut struct {
+	CartID string `json:"cart_id"`
+}
+
+func TestMapInport(t *testing.T) {
+	c := NewConsumerController(wotop.ApplicationData{AppName: "shop"}, nopLogger{}, Config{}, newStubEvent())
+
+	interactor := &placeOrder{}
+	c.AddUsecase(wotop.Inport[placeOrderRequest, placeOrderResponse](interactor))
+	c.Handle("cart.checked_out", MapInport[placeOrderRequest, placeOrderResponse](c, func(ctx context.Context, payload cartCheckedOut) (placeOrderRequest, error) {
+		if payload.CartID == "" {
+			return placeOrderRequest{}, errors.New("cart ID is missing")
+		}
+		return placeOrderRequest{OrderID: "o-" + payload.CartID}, nil
+	}))
+
+	msg, s := newMessage(t, "cart.checked_out", cartCheckedOut{CartID: "c1"})
+	c.ConsumeMessage(0, msg)
+	assert.Equal(t, "ack", s.get())
+	assert.Equal(t, []placeOrderRequest{{OrderID: "o-c1"}}, interactor.requests)
+	assert.Equal(t, []string{"evt-1"}, interactor.traceIDs)
+
+	// a payload the mapper refuses would be refused on every retry
+	msg, s = newMessage(t, "cart.checked_out", cartCheckedOut{})
+	c.ConsumeMessage(1, msg)
+	assert.Equal(t, "reject", s.get())
+	assert.Len(t, interactor.requests, 1)
+}
+
+func TestHandler(t *testing.T) {
+	c := NewConsumerController(wotop.ApplicationData{AppName: "shop"}, nopLogger{}, Config{}, newStubEvent())
+
+	var carts []string
+	c.Handle("cart.checked_out", Handler(c, func(ctx context.Context, payload cartCheckedOut) error {
+		traceID, _ := wotop.TraceIDFromContext(ctx)
+		carts = append(carts, payload.CartID+"@"+traceID)
+		if payload.CartID == "c-fail" {
+			return errors.New("cart is locked")
+		}
+		return nil
+	}))
+
+	msg, s := newMessage(t, "cart.checked_out", cartCheckedOut{CartID: "c1"})
+	c.ConsumeMessage(0, msg)
+	assert.Equal(t, "ack", s.get())
+
+	msg, s = newMessage(t, "cart.checked_out", cartCheckedOut{CartID: "c-fail"})
+	c.ConsumeMessage(1, msg)
+	assert.Equal(t, "nack", s.get())
+
+	msg, s = newMessage(t, "cart.checked_out", []string{"not a cart"})
+	c.ConsumeMessage(2, msg)
+	assert.Equal(t, "reject", s.get())
+	assert.Equal(t, []string{"c1@evt-1", "c-fail@evt-1"}, carts)
+}
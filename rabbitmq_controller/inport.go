@@ -0,0 +1,138 @@
+package rabbitmq_controller
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/logger"
+	"github.com/a-aslani/wotop/util"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Inport returns a MessageConsumer executing the inport of the usecase of REQUEST, which must
+// be registered with AddUsecase before. The payload of the event is decoded into REQUEST and
+// the trace ID of the execution is the ID of the event. The message is acked when the inport
+// succeeds and nacked otherwise, so it is retried within the retry budget of Config.Options and
+// then dead-lettered. A payload that cannot be decoded is rejected at once.
+//
+// Type Parameters:
+//   - REQUEST: The type of the request of the inport.
+//   - RESPONSE: The type of the response of the inport, it is discarded.
+//
+// Parameters:
+//   - c: The controller the usecase is registered on.
+//
+// Returns:
+//   - The MessageConsumer, to register with Handle.
+func Inport[REQUEST, RESPONSE any](c *Controller) MessageConsumer {
+	return MapInport[REQUEST, RESPONSE](c, func(_ context.Context, req REQUEST) (REQUEST, error) {
+		return req, nil
+	})
+}
+
+// MapInport is like Inport for the events whose payload is not the request of the inport, e.g.
+// the payload type generated by `wotop event`: the payload is decoded into EVENT and mapped to
+// the request by mapper, see `wotop event --usecase`. The message is rejected at once when the
+// mapper fails, as its retries would fail too.
+//
+// Type Parameters:
+//   - REQUEST: The type of the request of the inport.
+//   - RESPONSE: The type of the response of the inport, it is discarded.
+//   - EVENT: The type of the payload of the event, inferred from mapper.
+//
+// Parameters:
+//   - c: The controller the usecase is registered on.
+//   - mapper: Builds the request from the payload, with the context of the execution.
+//
+// Returns:
+//   - The MessageConsumer, to register with Handle.
+func MapInport[REQUEST, RESPONSE, EVENT any](c *Controller, mapper func(ctx context.Context, payload EVENT) (REQUEST, error)) MessageConsumer {
+
+	var zero REQUEST
+	inport := wotop.MustGetInport[REQUEST, RESPONSE](c.GetUsecase(zero))
+
+	return consume(c, func(ctx context.Context, msg *amqp.Delivery, payload EVENT) {
+
+		req, err := mapper(ctx, payload)
+		if err != nil {
+			c.log.Error(ctx, "map message %q: %v", msg.MessageId, err)
+			settle(ctx, c.log, msg.Reject(false))
+			return
+		}
+
+		if _, err := inport.Execute(ctx, req); err != nil {
+			c.log.Error(ctx, err.Error())
+			settle(ctx, c.log, msg.Nack(false, false))
+			return
+		}
+
+		settle(ctx, c.log, msg.Ack(false))
+	})
+}
+
+// Handler returns a MessageConsumer calling a handler with the payload of the event decoded
+// into EVENT, e.g. the handlers bound by the consumer generated by `wotop event`. It settles the
+// messages as Inport does: acked when the handler succeeds, nacked when it fails and rejected
+// when the payload cannot be decoded.
+//
+// Type Parameters:
+//   - EVENT: The type of the payload of the event, json.RawMessage to decode it in the handler.
+//
+// Parameters:
+//   - c: The controller the handler is registered on.
+//   - h: The handler, called with the ID of the event as trace ID.
+//
+// Returns:
+//   - The MessageConsumer, to register with Handle.
+func Handler[EVENT any](c *Controller, h func(ctx context.Context, payload EVENT) error) MessageConsumer {
+	return consume(c, func(ctx context.Context, msg *amqp.Delivery, payload EVENT) {
+		if err := h(ctx, payload); err != nil {
+			c.log.Error(ctx, "handle message %q: %v", msg.MessageId, err)
+			settle(ctx, c.log, msg.Nack(false, false))
+			return
+		}
+		settle(ctx, c.log, msg.Ack(false))
+	})
+}
+
+// consume returns a MessageConsumer decoding the payload of the message published by
+// pubsub.Event.Publish into EVENT, and calling handle with a context carrying the ID of the
+// event as trace ID. The messages whose payload cannot be decoded are rejected.
+func consume[EVENT any](c *Controller, handle func(ctx context.Context, msg *amqp.Delivery, payload EVENT)) MessageConsumer {
+
+	return MessageConsumerFunc(func(_ int, msg *amqp.Delivery) {
+
+		var data struct {
+			ID      string          `json:"id"`
+			Payload json.RawMessage `json:"payload"`
+		}
+
+		var payload EVENT
+		err := json.Unmarshal(msg.Body, &data)
+		if err == nil {
+			err = json.Unmarshal(data.Payload, &payload)
+		}
+
+		traceID := data.ID
+		if traceID == "" {
+			traceID = util.GenerateID(16)
+		}
+		ctx := logger.SetTraceID(context.Background(), traceID)
+
+		if err != nil {
+			c.log.Error(ctx, "decode message %q: %v", msg.MessageId, err)
+			settle(ctx, c.log, msg.Reject(false))
+			return
+		}
+
+		handle(ctx, msg, payload)
+	})
+}
+
+// settle logs the error acking, nacking or rejecting a message.
+func settle(ctx context.Context, log logger.Logger, err error) {
+	if err != nil {
+		log.Error(ctx, "settle message: %v", err)
+	}
+}
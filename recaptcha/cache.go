@@ -0,0 +1,247 @@
+package recaptcha
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+// DefaultCacheTTL is used by NewCachedRecaptcha when a non-positive ttl is given.
+// It matches the two minutes a reCAPTCHA token stays valid at Google.
+const DefaultCacheTTL = 2 * time.Minute
+
+// DefaultVerifyTimeout bounds a verification shared by concurrent callers, it
+// matches the timeout of the Google client.
+const DefaultVerifyTimeout = 10 * time.Second
+
+// ErrInvalidCacheTTL is returned by the caches for a non-positive ttl, which
+// would otherwise store the token without any expiry in Redis.
+var ErrInvalidCacheTTL = errors.New("recaptcha: cache ttl must be positive")
+
+// VerificationCache remembers tokens that were verified successfully so that
+// client retries carrying the same token are not rejected by Google as
+// timeout-or-duplicate.
+type VerificationCache interface {
+	// Has reports whether key was stored and has not expired yet.
+	Has(ctx context.Context, key string) (bool, error)
+	// Store remembers key for the given ttl.
+	Store(ctx context.Context, key string, ttl time.Duration) error
+}
+
+// CachedRecaptcha decorates a Recaptcha and answers replays of a successfully
+// verified token from the cache for a configurable window. Failed
+// verifications are never cached, so a token that was rejected once keeps
+// being rejected. Concurrent verifications of the same token are merged into
+// a single call to Google, which runs detached from the context of the caller
+// who started it, so a caller giving up does not fail the others.
+//
+// Storing a result is best-effort: when the cache fails the verification
+// still succeeds and the error is reported to the handler set with
+// WithErrorHandler, if any.
+type CachedRecaptcha struct {
+	next    Recaptcha
+	cache   VerificationCache
+	ttl     time.Duration
+	timeout time.Duration
+	group   singleflight.Group
+	onError func(ctx context.Context, err error)
+}
+
+var _ Recaptcha = (*CachedRecaptcha)(nil)
+
+// NewCachedRecaptcha wraps next with a verification cache keeping successful
+// results for ttl. A non-positive ttl falls back to DefaultCacheTTL.
+func NewCachedRecaptcha(next Recaptcha, cache VerificationCache, ttl time.Duration) *CachedRecaptcha {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	return &CachedRecaptcha{
+		next:    next,
+		cache:   cache,
+		ttl:     ttl,
+		timeout: DefaultVerifyTimeout,
+	}
+}
+
+// WithTimeout sets the timeout of a verification shared by concurrent callers,
+// a non-positive timeout falls back to DefaultVerifyTimeout.
+func (r *CachedRecaptcha) WithTimeout(timeout time.Duration) *CachedRecaptcha {
+	if timeout <= 0 {
+		timeout = DefaultVerifyTimeout
+	}
+	r.timeout = timeout
+	return r
+}
+
+// WithErrorHandler sets a callback invoked whenever the cache fails to read
+// or store a result, so an unavailable cache does not go unnoticed.
+func (r *CachedRecaptcha) WithErrorHandler(fn func(ctx context.Context, err error)) *CachedRecaptcha {
+	r.onError = fn
+	return r
+}
+
+func (r *CachedRecaptcha) SiteVerify(ctx context.Context, secret, token string) error {
+
+	key := tokenKey(secret, token)
+
+	ch := r.group.DoChan(key, func() (any, error) {
+
+		// the call is shared, the first caller cancelling must not fail the others
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), r.timeout)
+		defer cancel()
+
+		// a broken cache must never block verification, we just fall back to Google
+		ok, err := r.cache.Has(ctx, key)
+		if err != nil {
+			r.reportError(ctx, err)
+		} else if ok {
+			return nil, nil
+		}
+
+		if err = r.next.SiteVerify(ctx, secret, token); err != nil {
+			return nil, err
+		}
+
+		if err = r.cache.Store(ctx, key, r.ttl); err != nil {
+			r.reportError(ctx, err)
+		}
+
+		return nil, nil
+	})
+
+	select {
+	case res := <-ch:
+		return res.Err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *CachedRecaptcha) reportError(ctx context.Context, err error) {
+	if r.onError != nil {
+		r.onError(ctx, err)
+	}
+}
+
+// tokenKey hashes the secret and token so raw captcha tokens never end up in the cache.
+func tokenKey(secret, token string) string {
+	sum := sha256.Sum256([]byte(secret + "\x00" + token))
+	return hex.EncodeToString(sum[:])
+}
+
+// MemoryCache is an in-memory LRU VerificationCache with per-entry expiry.
+type MemoryCache struct {
+	mu       sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List
+	now      func() time.Time
+}
+
+type memoryCacheEntry struct {
+	key       string
+	expiresAt time.Time
+}
+
+var _ VerificationCache = (*MemoryCache)(nil)
+
+// NewMemoryCache creates a MemoryCache holding at most capacity tokens, the
+// least recently used token is evicted first when the cache is full.
+func NewMemoryCache(capacity int) *MemoryCache {
+	if capacity <= 0 {
+		capacity = 10000
+	}
+	return &MemoryCache{
+		capacity: capacity,
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+		now:      time.Now,
+	}
+}
+
+func (m *MemoryCache) Has(_ context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.items[key]
+	if !ok {
+		return false, nil
+	}
+
+	entry := el.Value.(*memoryCacheEntry)
+	if !m.now().Before(entry.expiresAt) {
+		m.order.Remove(el)
+		delete(m.items, key)
+		return false, nil
+	}
+
+	m.order.MoveToFront(el)
+	return true, nil
+}
+
+func (m *MemoryCache) Store(_ context.Context, key string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return ErrInvalidCacheTTL
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	expiresAt := m.now().Add(ttl)
+
+	if el, ok := m.items[key]; ok {
+		el.Value.(*memoryCacheEntry).expiresAt = expiresAt
+		m.order.MoveToFront(el)
+		return nil
+	}
+
+	m.items[key] = m.order.PushFront(&memoryCacheEntry{key: key, expiresAt: expiresAt})
+
+	for m.order.Len() > m.capacity {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.items, oldest.Value.(*memoryCacheEntry).key)
+	}
+
+	return nil
+}
+
+// RedisCache is a VerificationCache backed by Redis, useful when several
+// replicas serve the same clients.
+type RedisCache struct {
+	rdb    *redis.Client
+	prefix string
+}
+
+var _ VerificationCache = (*RedisCache)(nil)
+
+// NewRedisCache creates a RedisCache storing keys under the "recaptcha:" prefix.
+func NewRedisCache(rdb *redis.Client) *RedisCache {
+	return &RedisCache{rdb: rdb, prefix: "recaptcha"}
+}
+
+func (r *RedisCache) Has(ctx context.Context, key string) (bool, error) {
+	err := r.rdb.Get(ctx, fmt.Sprintf("%s:%s", r.prefix, key)).Err()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *RedisCache) Store(ctx context.Context, key string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return ErrInvalidCacheTTL
+	}
+	return r.rdb.Set(ctx, fmt.Sprintf("%s:%s", r.prefix, key), 1, ttl).Err()
+}
@@ -0,0 +1,214 @@
+package recaptcha
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	mockrecaptcha "github.com/a-aslani/wotop/recaptcha/mocks"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// blockingRecaptcha lets a test hold verifications open until release is closed.
+type blockingRecaptcha struct {
+	mu      sync.Mutex
+	calls   int
+	started chan struct{}
+	release chan struct{}
+	ctxErr  error
+}
+
+func (b *blockingRecaptcha) SiteVerify(ctx context.Context, secret, token string) error {
+	b.mu.Lock()
+	b.calls++
+	first := b.calls == 1
+	b.mu.Unlock()
+
+	if !first {
+		return errors.New(TimeoutOrDuplicate)
+	}
+
+	close(b.started)
+	<-b.release
+
+	b.mu.Lock()
+	b.ctxErr = ctx.Err()
+	b.mu.Unlock()
+	return nil
+}
+
+// failingCache is a VerificationCache whose backend is unavailable.
+type failingCache struct{}
+
+func (failingCache) Has(context.Context, string) (bool, error) {
+	return false, errors.New("cache down")
+}
+
+func (failingCache) Store(context.Context, string, time.Duration) error {
+	return errors.New("cache down")
+}
+
+func TestCachedRecaptcha(t *testing.T) {
+
+	ctx := context.Background()
+
+	t.Run("replay within ttl is answered from cache", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		next := mockrecaptcha.NewMockRecaptcha(ctrl)
+		next.EXPECT().SiteVerify(gomock.Any(), "secret", "token").Return(nil).Times(1)
+
+		r := NewCachedRecaptcha(next, NewMemoryCache(10), time.Minute)
+
+		assert.NoError(t, r.SiteVerify(ctx, "secret", "token"))
+		assert.NoError(t, r.SiteVerify(ctx, "secret", "token"))
+	})
+
+	t.Run("replay after expiry is verified again", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		next := mockrecaptcha.NewMockRecaptcha(ctrl)
+		gomock.InOrder(
+			next.EXPECT().SiteVerify(gomock.Any(), "secret", "token").Return(nil),
+			next.EXPECT().SiteVerify(gomock.Any(), "secret", "token").Return(errors.New(TimeoutOrDuplicate)),
+		)
+
+		now := time.Now()
+		cache := NewMemoryCache(10)
+		cache.now = func() time.Time { return now }
+
+		r := NewCachedRecaptcha(next, cache, time.Minute)
+
+		assert.NoError(t, r.SiteVerify(ctx, "secret", "token"))
+
+		now = now.Add(time.Minute)
+		assert.EqualError(t, r.SiteVerify(ctx, "secret", "token"), TimeoutOrDuplicate)
+	})
+
+	t.Run("failed token is still rejected on replay", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		next := mockrecaptcha.NewMockRecaptcha(ctrl)
+		next.EXPECT().SiteVerify(gomock.Any(), "secret", "bad").Return(errors.New(InvalidInputResponse)).Times(2)
+
+		r := NewCachedRecaptcha(next, NewMemoryCache(10), time.Minute)
+
+		assert.Error(t, r.SiteVerify(ctx, "secret", "bad"))
+		assert.Error(t, r.SiteVerify(ctx, "secret", "bad"))
+	})
+}
+
+func TestCachedRecaptchaConcurrentRetries(t *testing.T) {
+	next := &blockingRecaptcha{started: make(chan struct{}), release: make(chan struct{})}
+	r := NewCachedRecaptcha(next, NewMemoryCache(10), time.Minute)
+
+	const n = 5
+	errs := make(chan error, n)
+
+	go func() { errs <- r.SiteVerify(context.Background(), "secret", "token") }()
+	<-next.started
+
+	for i := 1; i < n; i++ {
+		go func() { errs <- r.SiteVerify(context.Background(), "secret", "token") }()
+	}
+
+	// give the retries time to join the in-flight verification
+	time.Sleep(50 * time.Millisecond)
+	close(next.release)
+
+	for i := 0; i < n; i++ {
+		assert.NoError(t, <-errs)
+	}
+	assert.Equal(t, 1, next.calls)
+}
+
+func TestCachedRecaptchaFirstCallerCancels(t *testing.T) {
+	next := &blockingRecaptcha{started: make(chan struct{}), release: make(chan struct{})}
+	r := NewCachedRecaptcha(next, NewMemoryCache(10), time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() { first <- r.SiteVerify(ctx, "secret", "token") }()
+	<-next.started
+
+	second := make(chan error, 1)
+	go func() { second <- r.SiteVerify(context.Background(), "secret", "token") }()
+
+	// give the retry time to join the in-flight verification
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-first, context.Canceled, "the caller who gave up returns at once")
+
+	close(next.release)
+	assert.NoError(t, <-second, "the waiting caller gets the shared result")
+	assert.NoError(t, next.ctxErr, "the shared call is not cancelled with the first caller")
+	assert.Equal(t, 1, next.calls)
+}
+
+func TestCachedRecaptchaReportsCacheErrors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	next := mockrecaptcha.NewMockRecaptcha(ctrl)
+	next.EXPECT().SiteVerify(gomock.Any(), "secret", "token").Return(nil)
+
+	var reported []error
+	r := NewCachedRecaptcha(next, failingCache{}, time.Minute).
+		WithErrorHandler(func(_ context.Context, err error) { reported = append(reported, err) })
+
+	assert.NoError(t, r.SiteVerify(context.Background(), "secret", "token"))
+	assert.Len(t, reported, 2)
+}
+
+func TestNewCachedRecaptchaDefaultTTL(t *testing.T) {
+	r := NewCachedRecaptcha(nil, NewMemoryCache(1), 0)
+	assert.Equal(t, DefaultCacheTTL, r.ttl)
+
+	r = NewCachedRecaptcha(nil, NewMemoryCache(1), -time.Second)
+	assert.Equal(t, DefaultCacheTTL, r.ttl)
+}
+
+func TestRedisCache(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	cache := NewRedisCache(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+
+	ok, err := cache.Has(ctx, "key")
+	require.NoError(t, err)
+	assert.False(t, ok, "missing key must map redis.Nil to false")
+
+	require.NoError(t, cache.Store(ctx, "key", time.Minute))
+	ok, err = cache.Has(ctx, "key")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, mr.TTL("recaptcha:key"))
+
+	mr.FastForward(time.Minute)
+	ok, err = cache.Has(ctx, "key")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.ErrorIs(t, cache.Store(ctx, "key", 0), ErrInvalidCacheTTL)
+	assert.False(t, mr.Exists("recaptcha:key"))
+
+	mr.Close()
+	_, err = cache.Has(ctx, "key")
+	assert.Error(t, err)
+}
+
+func TestMemoryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	cache := NewMemoryCache(2)
+
+	_ = cache.Store(ctx, "a", time.Minute)
+	_ = cache.Store(ctx, "b", time.Minute)
+	_, _ = cache.Has(ctx, "a")
+	_ = cache.Store(ctx, "c", time.Minute)
+
+	ok, _ := cache.Has(ctx, "b")
+	assert.False(t, ok)
+
+	ok, _ = cache.Has(ctx, "a")
+	assert.True(t, ok)
+}
@@ -0,0 +1,59 @@
+package recaptcha
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// ErrStaticVerifierInProduction is returned by NewStaticVerifier in the production stage,
+// where the captcha must be verified by Google.
+var ErrStaticVerifierInProduction = errors.New("recaptcha: the static verifier cannot be used in production")
+
+// Logger is the part of logger.Logger used by StaticVerifier to report the bypasses.
+type Logger interface {
+	Warning(ctx context.Context, message string, args ...any)
+}
+
+// StaticVerifier is a Recaptcha for local development and CI, which cannot reach Google.
+// It accepts a fixed list of test tokens and rejects every other token, as Google rejects
+// an invalid response. Every accepted token is logged as a warning, so a bypass does not
+// go unnoticed.
+type StaticVerifier struct {
+	tokens map[string]struct{}
+	log    Logger
+	stage  string
+}
+
+var _ Recaptcha = (*StaticVerifier)(nil)
+
+// NewStaticVerifier creates a StaticVerifier accepting the given test tokens. It refuses
+// the "production" stage with ErrStaticVerifierInProduction.
+func NewStaticVerifier(stage string, log Logger, tokens ...string) (*StaticVerifier, error) {
+	if strings.TrimSpace(strings.ToLower(stage)) == "production" {
+		return nil, ErrStaticVerifierInProduction
+	}
+
+	allowed := make(map[string]struct{}, len(tokens))
+	for _, token := range tokens {
+		if token != "" {
+			allowed[token] = struct{}{}
+		}
+	}
+
+	return &StaticVerifier{tokens: allowed, log: log, stage: stage}, nil
+}
+
+// SiteVerify accepts the test tokens of the verifier, the secret is ignored.
+func (v *StaticVerifier) SiteVerify(ctx context.Context, _, token string) error {
+	if token == "" {
+		return errors.New(MissingInputResponse)
+	}
+
+	if _, ok := v.tokens[token]; !ok {
+		return errors.New(InvalidInputResponse)
+	}
+
+	v.log.Warning(ctx, "RECAPTCHA BYPASSED: the test token %q was accepted without Google by the static verifier in the %s stage", token, v.stage)
+	return nil
+}
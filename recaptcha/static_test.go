@@ -0,0 +1,54 @@
+package recaptcha
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop/logger"
+	"github.com/a-aslani/wotop/logger/loggertest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticVerifier(t *testing.T) {
+	ctx := context.Background()
+	log := &loggertest.Recorder{}
+
+	v, err := NewStaticVerifier("development", log, "ci-token", "dev-token")
+	require.NoError(t, err)
+
+	assert.NoError(t, v.SiteVerify(ctx, "secret", "ci-token"))
+	assert.NoError(t, v.SiteVerify(ctx, "", "dev-token"))
+	require.Len(t, log.Messages(logger.LevelWarning), 2)
+	assert.Contains(t, log.Messages(logger.LevelWarning)[0], `"ci-token"`)
+	assert.Contains(t, log.Messages(logger.LevelWarning)[0], "development")
+
+	assert.EqualError(t, v.SiteVerify(ctx, "secret", "forged"), InvalidInputResponse)
+	assert.EqualError(t, v.SiteVerify(ctx, "secret", ""), MissingInputResponse)
+	assert.Len(t, log.Messages(logger.LevelWarning), 2, "rejected tokens are not bypasses")
+}
+
+func TestStaticVerifierRejectsEverythingWithoutTokens(t *testing.T) {
+	v, err := NewStaticVerifier("test", &loggertest.Recorder{}, "")
+	require.NoError(t, err)
+
+	assert.EqualError(t, v.SiteVerify(context.Background(), "secret", "anything"), InvalidInputResponse)
+}
+
+func TestStaticVerifierProductionGuard(t *testing.T) {
+	for _, stage := range []string{"production", "Production", " PRODUCTION "} {
+		v, err := NewStaticVerifier(stage, &loggertest.Recorder{}, "ci-token")
+		assert.ErrorIs(t, err, ErrStaticVerifierInProduction, stage)
+		assert.Nil(t, v)
+	}
+}
+
+func TestStaticVerifierIsADropIn(t *testing.T) {
+	v, err := NewStaticVerifier("development", &loggertest.Recorder{}, "ci-token")
+	require.NoError(t, err)
+
+	var r Recaptcha = NewCachedRecaptcha(v, NewMemoryCache(10), time.Minute)
+	assert.NoError(t, r.SiteVerify(context.Background(), "secret", "ci-token"))
+	assert.Error(t, r.SiteVerify(context.Background(), "secret", "forged"))
+}
@@ -8,7 +8,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/a-aslani/wotop/logger"
@@ -16,10 +21,12 @@ import (
 )
 
 type Client struct {
-	log        logger.Logger
-	baseURL    string
-	httpClient *http.Client
-	cb         *gobreaker.CircuitBreaker
+	log          logger.Logger
+	baseURL      string
+	allowedHosts []string
+	httpClient   *http.Client
+	cb           *gobreaker.CircuitBreaker
+	middlewares  []Middleware
 }
 
 type Authentication struct {
@@ -27,11 +34,12 @@ type Authentication struct {
 }
 
 type ClientConfig struct {
-	BaseURL          string
+	BaseURL          string // the paths of the requests are appended to it, empty for the raw URL mode where they are absolute URLs
 	Timeout          time.Duration
 	MaxFailures      uint32
 	IntervalDuration time.Duration
 	TimeoutDuration  time.Duration
+	AllowedHosts     []string // the hosts the raw URL mode may call, e.g. "api.partner.com" or "*.partner.com", any when empty
 }
 
 type Response[T any] struct {
@@ -57,18 +65,21 @@ func NewClient(name string, log logger.Logger, cfg ClientConfig) *Client {
 		},
 	}
 
-	return &Client{
-		log:     log,
-		baseURL: cfg.BaseURL,
-		httpClient: &http.Client{
-			Timeout: cfg.Timeout,
-		},
-		cb: gobreaker.NewCircuitBreaker(cbSettings),
+	c := &Client{
+		log:          log,
+		baseURL:      cfg.BaseURL,
+		allowedHosts: cfg.AllowedHosts,
+		cb:           gobreaker.NewCircuitBreaker(cbSettings),
+	}
+	c.httpClient = &http.Client{
+		Timeout:       cfg.Timeout,
+		CheckRedirect: c.checkRedirect,
 	}
+	return c
 }
 
 func (c *Client) Execute(ctx context.Context, auth Authentication, method, path string, body interface{}) ([]byte, error) {
-	result, err := c.cb.Execute(func() (interface{}, error) {
+	return c.execute(ctx, auth, method, path, func() (io.Reader, string, error) {
 		var reqBody []byte
 		var err error
 
@@ -76,19 +87,120 @@ func (c *Client) Execute(ctx context.Context, auth Authentication, method, path
 			reqBody, err = json.Marshal(body)
 			if err != nil {
 				c.log.Error(ctx, "failed to marshal request body: %s", err.Error())
-				return nil, err
+				return nil, "", err
+			}
+		}
+
+		return bytes.NewBuffer(reqBody), "application/json", nil
+	})
+}
+
+// ExecuteForm sends the form URL-encoded, as application/x-www-form-urlencoded, through the
+// circuit breaker and the middlewares of the client, as Execute does.
+func (c *Client) ExecuteForm(ctx context.Context, auth Authentication, method, path string, form url.Values) ([]byte, error) {
+	return c.execute(ctx, auth, method, path, func() (io.Reader, string, error) {
+		return strings.NewReader(form.Encode()), "application/x-www-form-urlencoded", nil
+	})
+}
+
+// FilePart is a file of a multipart request. Content is read once, while the request is sent.
+type FilePart struct {
+	FieldName   string
+	FileName    string
+	ContentType string // application/octet-stream when empty
+	Content     io.Reader
+}
+
+// ExecuteMultipart sends the fields and the files as multipart/form-data through the circuit
+// breaker and the middlewares of the client, as Execute does. The body is streamed while it is
+// written, the files are not buffered, so a middleware cannot send the request twice. The
+// fields are written in the order of their names, before the files.
+func (c *Client) ExecuteMultipart(ctx context.Context, auth Authentication, method, path string, fields map[string]string, files []FilePart) ([]byte, error) {
+	return c.execute(ctx, auth, method, path, func() (io.Reader, string, error) {
+		pr, pw := io.Pipe()
+		mw := multipart.NewWriter(pw)
+
+		go func() {
+			err := writeMultipart(mw, fields, files)
+			if err == nil {
+				err = mw.Close()
 			}
+			pw.CloseWithError(err)
+		}()
+
+		return pr, mw.FormDataContentType(), nil
+	})
+}
+
+// writeMultipart writes the fields, sorted by name, then the files.
+func writeMultipart(mw *multipart.Writer, fields map[string]string, files []FilePart) error {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := mw.WriteField(name, fields[name]); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range files {
+		contentType := f.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, quoteEscaper.Replace(f.FieldName), quoteEscaper.Replace(f.FileName)))
+		header.Set("Content-Type", contentType)
+
+		part, err := mw.CreatePart(header)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(part, f.Content); err != nil {
+			return fmt.Errorf("failed to write the file %s: %w", f.FileName, err)
+		}
+	}
+
+	return nil
+}
+
+// quoteEscaper escapes the quotes of the names of a Content-Disposition, as multipart.Writer does.
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// execute sends a request with the body built by newBody through the circuit breaker and the
+// middlewares, and returns the body of the response. The responses with a 4xx or 5xx status
+// are returned as errors, and the paths leaving the base URL as a ForbiddenTargetError, which
+// does not count as a failure of the circuit breaker.
+func (c *Client) execute(ctx context.Context, auth Authentication, method, path string, newBody func() (io.Reader, string, error)) ([]byte, error) {
+	target, err := c.target(path)
+	if err != nil {
+		c.log.Error(ctx, "failed to create request: %s", err.Error())
+		return nil, err
+	}
+
+	result, err := c.cb.Execute(func() (interface{}, error) {
+		reqBody, contentType, err := newBody()
+		if err != nil {
+			return nil, err
+		}
+		// a streamed body is closed if the request is not sent, so its writer returns
+		if closer, ok := reqBody.(io.Closer); ok {
+			defer closer.Close()
 		}
 
-		req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewBuffer(reqBody))
+		req, err := http.NewRequestWithContext(ctx, method, target, reqBody)
 		if err != nil {
 			c.log.Error(ctx, "failed to create request: %s", err.Error())
 			return nil, err
 		}
 
-		c.setHeaders(req, auth.ApiKey, auth.SecretKey)
+		c.setHeaders(req, auth.ApiKey, auth.SecretKey, contentType)
 
-		resp, err := c.httpClient.Do(req)
+		resp, err := c.doer().Do(req)
 		if err != nil {
 			c.log.Error(ctx, "failed to execute request: %s", err.Error())
 			return nil, err
@@ -141,7 +253,7 @@ func (c *Client) basicAuth(username, password string) string {
 	return base64.StdEncoding.EncodeToString([]byte(auth))
 }
 
-func (c *Client) setHeaders(req *http.Request, apiKey, secretKey string) {
-	req.Header.Set("Content-Type", "application/json")
+func (c *Client) setHeaders(req *http.Request, apiKey, secretKey, contentType string) {
+	req.Header.Set("Content-Type", contentType)
 	req.Header.Add("Authorization", "Basic "+c.basicAuth(apiKey, secretKey))
 }
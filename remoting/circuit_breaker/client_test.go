@@ -0,0 +1,135 @@
+package circuit_breaker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop/logger/loggertest"
+	"github.com/sony/gobreaker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFormTestClient creates a client of a server handing the requests to handle.
+func newFormTestClient(t *testing.T, handle func(r *http.Request)) *Client {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handle(r)
+		_, _ = w.Write([]byte(`{"success":true,"data":"ok"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	return NewClient("partner", &loggertest.Recorder{}, ClientConfig{
+		BaseURL:          server.URL,
+		Timeout:          time.Second,
+		MaxFailures:      2,
+		IntervalDuration: time.Minute,
+		TimeoutDuration:  time.Minute,
+	})
+}
+
+func TestExecuteForm(t *testing.T) {
+	var contentType string
+	var form url.Values
+	client := newFormTestClient(t, func(r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		require.NoError(t, r.ParseForm())
+		form = r.PostForm
+	})
+
+	var calls int
+	client.Use(func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			calls++
+			return next.Do(req)
+		})
+	})
+
+	body, err := client.ExecuteForm(context.Background(), Authentication{ApiKey: "key"}, http.MethodPost, "/orders",
+		url.Values{"sku": {"A-1"}, "tags": {"new", "sale"}})
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{"success":true,"data":"ok"}`, string(body))
+	assert.Equal(t, "application/x-www-form-urlencoded", contentType)
+	assert.Equal(t, url.Values{"sku": {"A-1"}, "tags": {"new", "sale"}}, form)
+	assert.Equal(t, 1, calls, "the request goes through the middlewares")
+}
+
+// multipartPart is a part received by the test server.
+type multipartPart struct {
+	name, fileName, contentType, content string
+}
+
+func TestExecuteMultipart(t *testing.T) {
+	var parts []multipartPart
+	client := newFormTestClient(t, func(r *http.Request) {
+		mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		require.NoError(t, err)
+		require.Equal(t, "multipart/form-data", mediaType)
+		assert.Equal(t, int64(-1), r.ContentLength, "the body is streamed")
+
+		reader := multipart.NewReader(r.Body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			require.NoError(t, err)
+
+			content, err := io.ReadAll(part)
+			require.NoError(t, err)
+			parts = append(parts, multipartPart{part.FormName(), part.FileName(), part.Header.Get("Content-Type"), string(content)})
+		}
+	})
+
+	_, err := client.ExecuteMultipart(context.Background(), Authentication{}, http.MethodPost, "/documents",
+		map[string]string{"title": "Invoice", "customer": "c-1"},
+		[]FilePart{
+			{FieldName: "document", FileName: "invoice.pdf", ContentType: "application/pdf", Content: strings.NewReader("%PDF-1.7")},
+			{FieldName: "attachment", FileName: `notes "v2".txt`, Content: strings.NewReader("hello")},
+		})
+	require.NoError(t, err)
+
+	assert.Equal(t, []multipartPart{
+		{"customer", "", "", "c-1"},
+		{"title", "", "", "Invoice"},
+		{"document", "invoice.pdf", "application/pdf", "%PDF-1.7"},
+		{"attachment", `notes "v2".txt`, "application/octet-stream", "hello"},
+	}, parts)
+}
+
+// failingReader fails to read a file.
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errors.New("disk error") }
+
+func TestExecuteMultipartFailures(t *testing.T) {
+	client := newFormTestClient(t, func(r *http.Request) { _, _ = io.ReadAll(r.Body) })
+
+	// a file which cannot be read fails the request and counts as a failure of the breaker
+	_, err := client.ExecuteMultipart(context.Background(), Authentication{}, http.MethodPost, "/documents", nil,
+		[]FilePart{{FieldName: "document", FileName: "broken.pdf", Content: failingReader{}}})
+	assert.ErrorContains(t, err, "disk error")
+	assert.Equal(t, uint32(1), client.cb.Counts().TotalFailures)
+
+	// a middleware failing before the body is read does not leave the writer blocked
+	client.Use(func(Doer) Doer {
+		return DoerFunc(func(*http.Request) (*http.Response, error) { return nil, errors.New("refused") })
+	})
+	_, err = client.ExecuteMultipart(context.Background(), Authentication{}, http.MethodPost, "/documents",
+		map[string]string{"title": "Invoice"}, nil)
+	assert.EqualError(t, err, "refused")
+
+	_, err = client.ExecuteMultipart(context.Background(), Authentication{}, http.MethodPost, "/documents", nil, nil)
+	assert.ErrorIs(t, err, gobreaker.ErrOpenState)
+}
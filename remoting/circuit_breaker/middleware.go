@@ -0,0 +1,136 @@
+package circuit_breaker
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/logger"
+)
+
+// Doer sends an HTTP request, *http.Client is the innermost Doer of a Client.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// DoerFunc adapts a function to a Doer.
+type DoerFunc func(req *http.Request) (*http.Response, error)
+
+func (f DoerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware wraps the Doer sending the requests of a Client, e.g. to set headers or to log
+// the calls.
+type Middleware func(next Doer) Doer
+
+// Use adds middlewares to the client, the first one added sees the request first. They run
+// inside the circuit breaker, so their errors count as failures. Use must not be called
+// while requests are executed.
+func (c *Client) Use(middlewares ...Middleware) {
+	c.middlewares = append(c.middlewares, middlewares...)
+}
+
+// doer chains the middlewares of the client around its HTTP client.
+func (c *Client) doer() Doer {
+	var doer Doer = c.httpClient
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		doer = c.middlewares[i](doer)
+	}
+	return doer
+}
+
+// traceIDPattern matches the trace IDs of the W3C trace context, 32 lowercase hex digits.
+var traceIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// TraceHeaders propagates the trace ID of the request context, see wotop.TraceIDFromContext,
+// in the X-Trace-ID header, and as a W3C traceparent header with a new span. The trace IDs of
+// the framework are not W3C trace IDs, the traceparent carries a hash of them. Requests whose
+// context has no trace ID, or which have a traceparent already, are left as they are.
+func TraceHeaders() Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			traceID, ok := wotop.TraceIDFromContext(req.Context())
+			if !ok {
+				return next.Do(req)
+			}
+
+			req = req.Clone(req.Context())
+			req.Header.Set("X-Trace-ID", traceID)
+			if req.Header.Get("traceparent") == "" {
+				req.Header.Set("traceparent", traceparent(traceID))
+			}
+
+			return next.Do(req)
+		})
+	}
+}
+
+// traceparent builds the W3C traceparent header of a trace with a new span, sampled.
+func traceparent(traceID string) string {
+	w3cTraceID := traceID
+	if !traceIDPattern.MatchString(traceID) {
+		sum := sha256.Sum256([]byte(traceID))
+		w3cTraceID = hex.EncodeToString(sum[:16])
+	}
+
+	var spanID [8]byte
+	_, _ = rand.Read(spanID[:])
+
+	return "00-" + w3cTraceID + "-" + hex.EncodeToString(spanID[:]) + "-01"
+}
+
+// RequestLogging logs a summary of every call: the method, the path, the status, the
+// latency and the error. The failed calls are logged as errors, the 4xx responses as
+// warnings.
+func RequestLogging(log logger.Logger) Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.Do(req)
+
+			fields := logger.Fields{
+				"method":     req.Method,
+				"host":       req.URL.Host,
+				"path":       req.URL.Path,
+				"latency_ms": time.Since(start).Milliseconds(),
+				"trace_id":   logger.GetTraceID(req.Context()),
+			}
+
+			level := logger.LevelInfo
+			switch {
+			case err != nil:
+				fields["error"] = err.Error()
+				level = logger.LevelError
+			case resp.StatusCode >= http.StatusInternalServerError:
+				fields["status"] = resp.StatusCode
+				level = logger.LevelError
+			case resp.StatusCode >= http.StatusBadRequest:
+				fields["status"] = resp.StatusCode
+				level = logger.LevelWarning
+			default:
+				fields["status"] = resp.StatusCode
+			}
+
+			logger.LogFields(log, req.Context(), level, "outbound request", fields)
+			return resp, err
+		})
+	}
+}
+
+// UserAgent sets the User-Agent header of the requests which have none.
+func UserAgent(userAgent string) Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get("User-Agent") == "" {
+				req = req.Clone(req.Context())
+				req.Header.Set("User-Agent", userAgent)
+			}
+			return next.Do(req)
+		})
+	}
+}
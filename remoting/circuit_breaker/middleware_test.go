@@ -0,0 +1,136 @@
+package circuit_breaker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/logger"
+	"github.com/a-aslani/wotop/logger/loggertest"
+	"github.com/sony/gobreaker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestClient creates a client of a server answering the status and recording the headers
+// of the requests.
+func newTestClient(t *testing.T, status int, log logger.Logger) (*Client, *http.Header) {
+	t.Helper()
+
+	var headers http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"success":true,"data":"ok"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewClient("catalog", log, ClientConfig{
+		BaseURL:          server.URL,
+		Timeout:          time.Second,
+		MaxFailures:      2,
+		IntervalDuration: time.Minute,
+		TimeoutDuration:  time.Minute,
+	})
+	return client, &headers
+}
+
+func TestTraceHeadersAndUserAgent(t *testing.T) {
+	client, headers := newTestClient(t, http.StatusOK, &loggertest.Recorder{})
+	client.Use(TraceHeaders(), UserAgent("wotop-catalog/1.0"))
+
+	ctx := wotop.WithTraceID(context.Background(), "A1b2C3d4E5f6G7h8")
+	_, err := client.Execute(ctx, Authentication{ApiKey: "key", SecretKey: "secret"}, http.MethodGet, "/products", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, "A1b2C3d4E5f6G7h8", headers.Get("X-Trace-ID"))
+	assert.Regexp(t, regexp.MustCompile(`^00-[0-9a-f]{32}-[0-9a-f]{16}-01$`), headers.Get("traceparent"))
+	assert.Equal(t, "wotop-catalog/1.0", headers.Get("User-Agent"))
+	assert.Equal(t, "application/json", headers.Get("Content-Type"))
+
+	// a W3C trace ID is kept as it is
+	ctx = wotop.WithTraceID(context.Background(), "4bf92f3577b34da6a3ce929d0e0e4736")
+	_, err = client.Execute(ctx, Authentication{}, http.MethodGet, "/products", nil)
+	require.NoError(t, err)
+	assert.Contains(t, headers.Get("traceparent"), "-4bf92f3577b34da6a3ce929d0e0e4736-")
+
+	// no trace, no trace headers
+	_, err = client.Execute(context.Background(), Authentication{}, http.MethodGet, "/products", nil)
+	require.NoError(t, err)
+	assert.Empty(t, headers.Get("X-Trace-ID"))
+	assert.Empty(t, headers.Get("traceparent"))
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	client, _ := newTestClient(t, http.StatusOK, &loggertest.Recorder{})
+
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next Doer) Doer {
+			return DoerFunc(func(req *http.Request) (*http.Response, error) {
+				calls = append(calls, name+" before")
+				resp, err := next.Do(req)
+				calls = append(calls, name+" after")
+				return resp, err
+			})
+		}
+	}
+	client.Use(trace("first"), trace("second"))
+	client.Use(trace("third"))
+
+	_, err := client.Execute(context.Background(), Authentication{}, http.MethodGet, "/products", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first before", "second before", "third before", "third after", "second after", "first after"}, calls)
+}
+
+func TestRequestLogging(t *testing.T) {
+	log := &loggertest.Recorder{}
+	client, _ := newTestClient(t, http.StatusOK, log)
+	client.Use(RequestLogging(log))
+
+	ctx := wotop.WithTraceID(context.Background(), "trace-1")
+	_, err := client.Execute(ctx, Authentication{}, http.MethodPost, "/orders", map[string]string{"sku": "s-1"})
+	require.NoError(t, err)
+
+	require.Len(t, log.Entries(), 1)
+	e := log.Entries()[0]
+	assert.Equal(t, logger.LevelInfo, e.Level)
+	assert.Equal(t, http.MethodPost, e.Fields["method"])
+	assert.Equal(t, "/orders", e.Fields["path"])
+	assert.Equal(t, http.StatusOK, e.Fields["status"])
+	assert.Equal(t, "trace-1", e.Fields["trace_id"])
+	assert.Contains(t, e.Fields, "latency_ms")
+
+	failing, _ := newTestClient(t, http.StatusServiceUnavailable, log)
+	failing.Use(RequestLogging(log))
+	_, err = failing.Execute(ctx, Authentication{}, http.MethodGet, "/orders", nil)
+	require.Error(t, err)
+	assert.Equal(t, logger.LevelError, log.Entries()[1].Level)
+	assert.Equal(t, http.StatusServiceUnavailable, log.Entries()[1].Fields["status"])
+}
+
+func TestMiddlewareFailuresTripTheBreaker(t *testing.T) {
+	client, _ := newTestClient(t, http.StatusOK, &loggertest.Recorder{})
+
+	calls := 0
+	client.Use(func(Doer) Doer {
+		return DoerFunc(func(*http.Request) (*http.Response, error) {
+			calls++
+			return nil, errors.New("signing failed")
+		})
+	})
+
+	for range 2 {
+		_, err := client.Execute(context.Background(), Authentication{}, http.MethodGet, "/products", nil)
+		assert.EqualError(t, err, "signing failed")
+	}
+
+	_, err := client.Execute(context.Background(), Authentication{}, http.MethodGet, "/products", nil)
+	assert.ErrorIs(t, err, gobreaker.ErrOpenState)
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,150 @@
+package circuit_breaker
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrForbiddenTarget is matched, with errors.Is, by the errors of the requests whose URL is
+// not allowed, see ForbiddenTargetError.
+var ErrForbiddenTarget = errors.New("forbidden target")
+
+// ForbiddenTargetError is returned, before any network I/O, for a request whose path would
+// leave the base URL of the client, e.g. a user-provided identifier "../admin" interpolated in
+// the path, or whose host is not allowed in the raw URL mode.
+type ForbiddenTargetError struct {
+	Target string // the path or URL of the request
+	Reason string
+}
+
+func (e *ForbiddenTargetError) Error() string {
+	return fmt.Sprintf("circuit_breaker: %s %q: %s", ErrForbiddenTarget, e.Target, e.Reason)
+}
+
+// Is reports whether target is ErrForbiddenTarget.
+func (e *ForbiddenTargetError) Is(target error) bool {
+	return target == ErrForbiddenTarget
+}
+
+// target returns the URL of a request: the path appended to the base URL, which the URL must
+// stay within, or in the raw URL mode the path itself, an absolute URL of an allowed host. The
+// paths with ".." segments are rejected in both modes, encoded or not.
+func (c *Client) target(path string) (string, error) {
+
+	forbidden := func(reason string) (string, error) {
+		return "", &ForbiddenTargetError{Target: path, Reason: reason}
+	}
+
+	if hasDotDotSegment(path) {
+		return forbidden(`the path has a ".." segment`)
+	}
+
+	if c.baseURL == "" {
+		u, err := url.Parse(path)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return forbidden("not an absolute http or https URL")
+		}
+		if u.User != nil {
+			return forbidden("the URL has credentials")
+		}
+		if !c.allowedHost(u) {
+			return forbidden("the host is not allowed")
+		}
+		return path, nil
+	}
+
+	base, err := url.Parse(c.baseURL)
+	if err != nil {
+		return forbidden("the base URL is invalid")
+	}
+
+	full := c.baseURL + path
+	u, err := url.Parse(full)
+	if err != nil {
+		return forbidden("not a valid URL")
+	}
+	if u.Scheme != base.Scheme || u.Host != base.Host || u.User.String() != base.User.String() {
+		return forbidden("the URL leaves the host of the base URL")
+	}
+
+	prefix := strings.TrimSuffix(base.EscapedPath(), "/")
+	if escaped := u.EscapedPath(); escaped != prefix && !strings.HasPrefix(escaped, prefix+"/") {
+		return forbidden("the URL leaves the path of the base URL")
+	}
+
+	return full, nil
+}
+
+// maxRedirects is the number of redirects followed by a request, as http.Client does by default.
+const maxRedirects = 10
+
+// checkRedirect is the CheckRedirect of the HTTP client: a redirect is followed only to a URL
+// target accepts, within the base URL or of an allowed host in the raw URL mode, so a
+// redirecting server cannot send the request elsewhere.
+func (c *Client) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("circuit_breaker: stopped after %d redirects", maxRedirects)
+	}
+
+	location := req.URL.String()
+	path := location
+	if c.baseURL != "" {
+		var ok bool
+		if path, ok = strings.CutPrefix(location, c.baseURL); !ok {
+			return &ForbiddenTargetError{Target: location, Reason: "the redirect leaves the base URL"}
+		}
+	}
+
+	if _, err := c.target(path); err != nil {
+		var forbidden *ForbiddenTargetError
+		if errors.As(err, &forbidden) {
+			forbidden.Target = location
+		}
+		return err
+	}
+	return nil
+}
+
+// allowedHost reports whether the host of a URL is one of AllowedHosts, any host when there
+// are none. An allowed host matches the hostname, or the host with its port when it has one,
+// and "*.example.com" matches the subdomains of example.com.
+func (c *Client) allowedHost(u *url.URL) bool {
+	if len(c.allowedHosts) == 0 {
+		return true
+	}
+
+	hostname := strings.ToLower(u.Hostname())
+	host := strings.ToLower(u.Host)
+	for _, allowed := range c.allowedHosts {
+		allowed = strings.ToLower(allowed)
+		switch {
+		case allowed == hostname, allowed == host:
+			return true
+		case strings.HasPrefix(allowed, "*.") && strings.HasSuffix(hostname, allowed[1:]):
+			return true
+		}
+	}
+	return false
+}
+
+// hasDotDotSegment reports whether a path, or the path of a URL, has a ".." segment, as it is
+// or percent-decoded, e.g. "%2e%2e" or "..%2f".
+func hasDotDotSegment(path string) bool {
+	if decoded, err := url.PathUnescape(path); err == nil && decoded != path && hasDotDotSegment(decoded) {
+		return true
+	}
+
+	// the query and the fragment are not part of the path
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+	for _, segment := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if segment == ".." {
+			return true
+		}
+	}
+	return false
+}
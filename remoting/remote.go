@@ -1,12 +1,15 @@
 package remoting
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"net/rpc"
 	"reflect"
+	"strconv"
+	"sync"
 )
 
 // RemoteListener represents a server that listens for remote procedure calls (RPC).
@@ -22,9 +25,15 @@ type RemoteListener struct {
 // RemoteCaller represents a client that makes remote procedure calls (RPC).
 //
 // Fields:
-//   - port: The port number of the server to connect to.
+//   - service: The name of the service, resolved with the resolver.
+//   - resolver: Finds the address of the service, nil for a server on localhost.
+//   - addr: The address of the server, the last one resolved.
 type RemoteCaller struct {
-	port int
+	service  string
+	resolver Resolver
+
+	mu   sync.Mutex
+	addr string
 }
 
 // NewRemoteListener creates a new instance of RemoteListener.
@@ -40,15 +49,29 @@ func NewRemoteListener(port int) *RemoteListener {
 	}
 }
 
-// NewRemoteCaller creates a new instance of RemoteCaller.
+// NewRemoteCaller creates a new instance of RemoteCaller calling a service found by a resolver.
+// The address is resolved on the first call, and again when the server cannot be dialed, so
+// an instance moved to another host, e.g. a rescheduled pod, is found.
+//
+// Parameters:
+//   - service: The name of the service, e.g. "billing".
+//   - resolver: Finds the address of the service, e.g. a StaticResolver or a DNSSRVResolver.
+//
+// Returns:
+//   - A pointer to a new RemoteCaller instance.
+func NewRemoteCaller(service string, resolver Resolver) *RemoteCaller {
+	return &RemoteCaller{service: service, resolver: resolver}
+}
+
+// NewLocalRemoteCaller creates a new instance of RemoteCaller calling a server on localhost.
 //
 // Parameters:
 //   - port: The port number of the server to connect to.
 //
 // Returns:
 //   - A pointer to a new RemoteCaller instance.
-func NewRemoteCaller(port int) *RemoteCaller {
-	return &RemoteCaller{port: port}
+func NewLocalRemoteCaller(port int) *RemoteCaller {
+	return &RemoteCaller{addr: fmt.Sprintf(":%d", port)}
 }
 
 // SetHandler sets the handler object for the RemoteListener.
@@ -105,11 +128,11 @@ func (r *RemoteListener) Run() {
 //   - reply: A pointer to the variable where the method's response will be stored.
 //
 // Returns:
-//   - An error if the call fails or the server is unreachable.
+//   - An error if the call fails, the service cannot be resolved or the server is unreachable.
 //
 // Example:
 //
-//	caller := NewRemoteCaller(8080)
+//	caller := NewRemoteCaller("billing", resolver)
 //	var reply string
 //	err := caller.Call("MyHandler.MethodName", "argument", &reply)
 //	if err != nil {
@@ -119,7 +142,7 @@ func (r *RemoteCaller) Call(methodName string, args any, reply any) error {
 
 	var err error
 
-	client, err := rpc.DialHTTP("tcp", fmt.Sprintf(":%d", r.port))
+	client, err := r.dial()
 	if err != nil {
 		return err
 	}
@@ -131,7 +154,7 @@ func (r *RemoteCaller) Call(methodName string, args any, reply any) error {
 		}
 	}(client)
 
-	err = client.Call(methodName, args, &reply)
+	err = client.Call(methodName, args, reply)
 	if err != nil {
 		return err
 	}
@@ -139,3 +162,46 @@ func (r *RemoteCaller) Call(methodName string, args any, reply any) error {
 	return nil
 
 }
+
+// dial connects to the server. When it cannot be dialed, the service is resolved again and
+// dialed once more.
+//
+// Returns:
+//   - The RPC client.
+//   - An error if the service cannot be resolved or the server is unreachable.
+func (r *RemoteCaller) dial() (*rpc.Client, error) {
+	addr, err := r.address(false)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := rpc.DialHTTP("tcp", addr)
+	if err == nil || r.resolver == nil {
+		return client, err
+	}
+
+	addr, resolveErr := r.address(true)
+	if resolveErr != nil {
+		return nil, errors.Join(err, resolveErr)
+	}
+	return rpc.DialHTTP("tcp", addr)
+}
+
+// address returns the address of the server, resolving the service on the first call or when
+// refresh is set.
+func (r *RemoteCaller) address(refresh bool) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.addr != "" && (!refresh || r.resolver == nil) {
+		return r.addr, nil
+	}
+
+	host, port, err := r.resolver.Resolve(r.service)
+	if err != nil {
+		return "", err
+	}
+
+	r.addr = net.JoinHostPort(host, strconv.Itoa(port))
+	return r.addr, nil
+}
@@ -0,0 +1,182 @@
+package remoting
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"net/rpc"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/a-aslani/wotop/model/apperror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Greeter is the RPC handler of the tests.
+type Greeter struct{}
+
+func (Greeter) Hello(name string, reply *string) error {
+	*reply = "hello " + name
+	return nil
+}
+
+// newRPCServer starts an RPC server of the Greeter and returns its host and port.
+func newRPCServer(t *testing.T) (string, int) {
+	t.Helper()
+
+	server := rpc.NewServer()
+	require.NoError(t, server.Register(Greeter{}))
+
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+
+	return splitAddr(t, ts.Listener.Addr().String())
+}
+
+// closedAddr returns the address of a port nothing listens on anymore.
+func closedAddr(t *testing.T) (string, int) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	return splitAddr(t, addr)
+}
+
+func splitAddr(t *testing.T, addr string) (string, int) {
+	t.Helper()
+
+	host, portStr, err := net.SplitHostPort(addr)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(portStr)
+	require.NoError(t, err)
+	return host, port
+}
+
+// fakeResolver returns its addresses in turn, the last one once they are exhausted.
+type fakeResolver struct {
+	mu       sync.Mutex
+	addrs    [][2]any
+	services []string
+}
+
+func (r *fakeResolver) Resolve(service string) (string, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.services = append(r.services, service)
+	addr := r.addrs[0]
+	if len(r.addrs) > 1 {
+		r.addrs = r.addrs[1:]
+	}
+	return addr[0].(string), addr[1].(int), nil
+}
+
+func TestRemoteCallerResolvesService(t *testing.T) {
+	host, port := newRPCServer(t)
+	resolver := &fakeResolver{addrs: [][2]any{{host, port}}}
+	caller := NewRemoteCaller("greeter", resolver)
+
+	for range 2 {
+		var reply string
+		require.NoError(t, caller.Call("Greeter.Hello", "mirza", &reply))
+		assert.Equal(t, "hello mirza", reply)
+	}
+
+	assert.Equal(t, []string{"greeter"}, resolver.services, "the address is resolved once")
+}
+
+func TestRemoteCallerReResolvesOnDialFailure(t *testing.T) {
+	oldHost, oldPort := closedAddr(t)
+	host, port := newRPCServer(t)
+	resolver := &fakeResolver{addrs: [][2]any{{oldHost, oldPort}, {host, port}}}
+	caller := NewRemoteCaller("greeter", resolver)
+
+	var reply string
+	require.NoError(t, caller.Call("Greeter.Hello", "mirza", &reply))
+	assert.Equal(t, "hello mirza", reply)
+	assert.Len(t, resolver.services, 2, "the rescheduled server is resolved again")
+
+	require.NoError(t, caller.Call("Greeter.Hello", "again", &reply))
+	assert.Len(t, resolver.services, 2, "the new address is kept")
+}
+
+func TestRemoteCallerResolveFailure(t *testing.T) {
+	caller := NewRemoteCaller("billing", ResolverFunc(func(service string) (string, int, error) {
+		return "", 0, ErrServiceNotFound.Var(service)
+	}))
+
+	var reply string
+	assertCode(t, caller.Call("Greeter.Hello", "mirza", &reply), ErrServiceNotFound)
+}
+
+func assertCode(t *testing.T, err error, want apperror.ErrorType) {
+	t.Helper()
+
+	var appErr apperror.ErrorType
+	require.ErrorAs(t, err, &appErr)
+	assert.Equal(t, want.Code(), appErr.Code())
+}
+
+func TestLocalRemoteCaller(t *testing.T) {
+	_, port := newRPCServer(t)
+	caller := NewLocalRemoteCaller(port)
+
+	var reply string
+	require.NoError(t, caller.Call("Greeter.Hello", "mirza", &reply))
+	assert.Equal(t, "hello mirza", reply)
+}
+
+func TestStaticResolver(t *testing.T) {
+	r, err := NewStaticResolver(map[string]string{"billing": "billing.internal:9000", "local": "[::1]:9001"})
+	require.NoError(t, err)
+
+	host, port, err := r.Resolve("billing")
+	require.NoError(t, err)
+	assert.Equal(t, "billing.internal", host)
+	assert.Equal(t, 9000, port)
+
+	host, _, err = r.Resolve("local")
+	require.NoError(t, err)
+	assert.Equal(t, "::1", host)
+
+	_, _, err = r.Resolve("shipping")
+	assertCode(t, err, ErrServiceNotFound)
+
+	for _, addr := range []string{"billing.internal", "billing.internal:http", "billing.internal:70000"} {
+		_, err = NewStaticResolver(map[string]string{"billing": addr})
+		assertCode(t, err, ErrInvalidServiceAddr)
+	}
+}
+
+func TestDNSSRVResolver(t *testing.T) {
+	var lookups [][3]string
+	r := NewDNSSRVResolver("shop.svc.cluster.local")
+	r.lookupSRV = func(_ context.Context, service, proto, name string) (string, []*net.SRV, error) {
+		lookups = append(lookups, [3]string{service, proto, name})
+		if service == "missing" {
+			return "", nil, errors.New("no such host")
+		}
+		return "", []*net.SRV{{Target: "billing-0.billing.shop.svc.cluster.local.", Port: 9000}}, nil
+	}
+
+	host, port, err := r.Resolve("billing")
+	require.NoError(t, err)
+	assert.Equal(t, "billing-0.billing.shop.svc.cluster.local", host)
+	assert.Equal(t, 9000, port)
+	assert.Equal(t, [3]string{"billing", "tcp", "shop.svc.cluster.local"}, lookups[0])
+
+	_, _, err = r.Resolve("missing")
+	assertCode(t, err, ErrServiceNotFound)
+	assert.ErrorContains(t, err, "no such host")
+
+	r.domain = ""
+	_, _, err = r.Resolve("_billing._tcp.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, [3]string{"", "", "_billing._tcp.example.com"}, lookups[2])
+}
@@ -0,0 +1,158 @@
+package remoting
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a-aslani/wotop/model/apperror"
+)
+
+const (
+	ErrServiceNotFound    apperror.ErrorType = "ER0901 service %s not found"
+	ErrInvalidServiceAddr apperror.ErrorType = "ER0902 invalid address %q of the service %s, expected host:port"
+)
+
+func init() {
+	apperror.Register("remoting",
+		apperror.Entry{Err: ErrServiceNotFound, Description: "The resolver has no address for the service."},
+		apperror.Entry{Err: ErrInvalidServiceAddr, Description: "The address of a service is not of the form host:port."},
+	)
+
+	apperror.MapCode(ErrServiceNotFound.Code(), http.StatusServiceUnavailable)
+}
+
+// DefaultDNSTimeout is the timeout of the DNS lookups of DNSSRVResolver.
+const DefaultDNSTimeout = 5 * time.Second
+
+// Resolver finds the address of a service, e.g. from the configuration or the DNS.
+//
+// Methods:
+//   - Resolve: Returns the host and the port of an instance of the service.
+type Resolver interface {
+	Resolve(service string) (host string, port int, err error)
+}
+
+// ResolverFunc adapts a function to a Resolver.
+type ResolverFunc func(service string) (string, int, error)
+
+// Resolve calls the function.
+func (f ResolverFunc) Resolve(service string) (string, int, error) {
+	return f(service)
+}
+
+// StaticResolver resolves the services from a fixed map, e.g. read from the configuration.
+type StaticResolver struct {
+	addrs map[string]hostPort
+}
+
+// hostPort is a resolved address.
+type hostPort struct {
+	host string
+	port int
+}
+
+// NewStaticResolver creates a StaticResolver.
+//
+// Parameters:
+//   - addrs: The addresses keyed by service, e.g. {"billing": "billing.internal:9000"}.
+//
+// Returns:
+//   - The resolver.
+//   - ErrInvalidServiceAddr if an address is not of the form host:port.
+func NewStaticResolver(addrs map[string]string) (*StaticResolver, error) {
+	r := &StaticResolver{addrs: make(map[string]hostPort, len(addrs))}
+
+	for service, addr := range addrs {
+		host, portStr, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, ErrInvalidServiceAddr.Var(addr, service)
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil || port <= 0 || port > 65535 {
+			return nil, ErrInvalidServiceAddr.Var(addr, service)
+		}
+		r.addrs[service] = hostPort{host: host, port: port}
+	}
+
+	return r, nil
+}
+
+// Resolve returns the address of the service.
+//
+// Parameters:
+//   - service: The name of the service.
+//
+// Returns:
+//   - The host and the port of the service.
+//   - ErrServiceNotFound if the map has no address for the service.
+func (r *StaticResolver) Resolve(service string) (string, int, error) {
+	addr, ok := r.addrs[service]
+	if !ok {
+		return "", 0, ErrServiceNotFound.Var(service)
+	}
+	return addr.host, addr.port, nil
+}
+
+// DNSSRVResolver resolves the services from the DNS SRV records, e.g. of a Kubernetes headless
+// service or Consul. The targets are ordered by priority and shuffled by weight, as
+// net.LookupSRV does, so the calls are spread over the instances.
+type DNSSRVResolver struct {
+	proto   string
+	domain  string
+	timeout time.Duration
+
+	// lookupSRV is net.DefaultResolver.LookupSRV, the tests replace it.
+	lookupSRV func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// NewDNSSRVResolver creates a DNSSRVResolver looking up the TCP records of the services.
+//
+// Parameters:
+//   - domain: The domain of the records, e.g. "shop.svc.cluster.local" to look up
+//     "_billing._tcp.shop.svc.cluster.local" for the service "billing". When empty, the
+//     service is the full name of the record.
+//
+// Returns:
+//   - The resolver.
+func NewDNSSRVResolver(domain string) *DNSSRVResolver {
+	return &DNSSRVResolver{
+		proto:     "tcp",
+		domain:    domain,
+		timeout:   DefaultDNSTimeout,
+		lookupSRV: net.DefaultResolver.LookupSRV,
+	}
+}
+
+// Resolve looks up the SRV records of the service and returns the first target.
+//
+// Parameters:
+//   - service: The name of the service, the full name of the record when the resolver has no domain.
+//
+// Returns:
+//   - The host and the port of the target.
+//   - ErrServiceNotFound if the lookup fails or returns no record.
+func (r *DNSSRVResolver) Resolve(service string) (string, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	var records []*net.SRV
+	var err error
+	if r.domain == "" {
+		_, records, err = r.lookupSRV(ctx, "", "", service)
+	} else {
+		_, records, err = r.lookupSRV(ctx, service, r.proto, r.domain)
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("%w: %v", ErrServiceNotFound.Var(service), err)
+	}
+	if len(records) == 0 {
+		return "", 0, ErrServiceNotFound.Var(service)
+	}
+
+	return strings.TrimSuffix(records[0].Target, "."), int(records[0].Port), nil
+}
@@ -0,0 +1,554 @@
+// Package saga orchestrates the workflows spanning several domains, e.g. order, payment and
+// shipping. A Saga runs ordered steps, each an inport with a compensating inport, keeps the
+// state of its instances in a StateStore and undoes the completed steps in reverse order when a
+// step fails permanently. A saga is driven synchronously with Run, or step by step by pubsub
+// events with Start and ConsumeMessage.
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/logger"
+	"github.com/a-aslani/wotop/model/apperror"
+	"github.com/a-aslani/wotop/pubsub"
+	"github.com/a-aslani/wotop/util"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	ErrInstanceNotFound apperror.ErrorType = "ER0801 saga instance %s not found"
+	ErrInstanceExists   apperror.ErrorType = "ER0802 saga instance %s exists already"
+	ErrSagaMismatch     apperror.ErrorType = "ER0803 saga instance %s belongs to the saga %s"
+)
+
+func init() {
+	apperror.Register("saga",
+		apperror.Entry{Err: ErrInstanceNotFound, Description: "No instance of the saga has the ID."},
+		apperror.Entry{Err: ErrInstanceExists, Description: "An instance of the saga was started with the ID already."},
+		apperror.Entry{Err: ErrSagaMismatch, Description: "The instance was started by another saga."},
+	)
+
+	apperror.MapCode(ErrInstanceNotFound.Code(), http.StatusNotFound)
+	apperror.MapCode(ErrInstanceExists.Code(), http.StatusConflict)
+}
+
+// Status is the status of a saga instance.
+type Status string
+
+const (
+	// StatusRunning is the status of an instance executing its steps.
+	StatusRunning Status = "running"
+
+	// StatusCompleted is the status of an instance whose steps all succeeded.
+	StatusCompleted Status = "completed"
+
+	// StatusCompensating is the status of an instance undoing its completed steps after a
+	// step failed.
+	StatusCompensating Status = "compensating"
+
+	// StatusCompensated is the status of an instance whose completed steps were all undone.
+	StatusCompensated Status = "compensated"
+
+	// StatusFailed is the status of an instance whose compensation failed, it needs a manual
+	// intervention.
+	StatusFailed Status = "failed"
+)
+
+// Done reports whether an instance with the status has nothing left to run.
+func (s Status) Done() bool {
+	return s == StatusCompleted || s == StatusCompensated || s == StatusFailed
+}
+
+// Instance is the persisted state of a run of a saga.
+//
+// Fields:
+//   - ID: The ID of the instance, e.g. the ID of the order.
+//   - Saga: The name of the saga.
+//   - Status: The status of the instance.
+//   - Step: The index of the next step to execute, or to compensate while compensating.
+//   - Payload: The payload passed from step to step, JSON encoded.
+//   - Error: The error of the failed step, and of the failed compensation if any.
+//   - CreatedAt: When the instance was started.
+//   - UpdatedAt: When the instance was last changed.
+type Instance struct {
+	ID        string          `json:"id"`
+	Saga      string          `json:"saga"`
+	Status    Status          `json:"status"`
+	Step      int             `json:"step"`
+	Payload   json.RawMessage `json:"payload"`
+	Error     string          `json:"error,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// Step is a step of a saga.
+//
+// Fields:
+//   - Name: The name of the step, for the logs and the errors.
+//   - Execute: Executes the step with the payload, it returns the payload of the next steps, nil to keep it.
+//   - Compensate: Undoes the step once executed, nil when there is nothing to undo.
+//   - Timeout: The time an attempt to execute or compensate the step may take, no limit when zero.
+//   - Retries: The retries of a failing attempt before the step fails permanently.
+type Step[P any] struct {
+	Name       string
+	Execute    wotop.Inport[P, P]
+	Compensate wotop.Inport[P, P]
+	Timeout    time.Duration
+	Retries    int
+}
+
+// permanentError is an error Permanent marked as not worth retrying.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error of a step as permanent, e.g. a declined payment, so the step is
+// not retried.
+//
+// Parameters:
+//   - err: The error of the step.
+//
+// Returns:
+//   - The error, unwrapping to err.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+// Publisher publishes the events driving the sagas started with Start. It is implemented by
+// *pubsub.Event.
+type Publisher interface {
+	Publish(eventName string, payload pubsub.Payload) error
+}
+
+var _ Publisher = (*pubsub.Event)(nil)
+
+// DefaultRetryBackoff is the delay before the first retry of a failing step, doubled at each
+// retry.
+const DefaultRetryBackoff = 100 * time.Millisecond
+
+// Saga runs the instances of a workflow made of ordered steps.
+//
+// Type Parameters:
+//   - P: The payload passed from step to step, it must be JSON encodable.
+type Saga[P any] struct {
+	name      string
+	steps     []Step[P]
+	store     StateStore
+	log       logger.Logger
+	publisher Publisher
+	onFailed  func(ctx context.Context, instance Instance, err error)
+	backoff   time.Duration
+	clock     util.Clock
+}
+
+// New creates a saga.
+//
+// Parameters:
+//   - name: The name of the saga, e.g. "place_order".
+//   - store: The store of the state of the instances.
+//   - log: The logger of the failures.
+//   - steps: The steps, executed in order.
+//
+// Returns:
+//   - The saga.
+func New[P any](name string, store StateStore, log logger.Logger, steps ...Step[P]) *Saga[P] {
+	return &Saga[P]{
+		name:     name,
+		steps:    steps,
+		store:    store,
+		log:      log,
+		onFailed: func(context.Context, Instance, error) {},
+		backoff:  DefaultRetryBackoff,
+		clock:    util.SystemClock,
+	}
+}
+
+// WithPublisher sets the publisher of the events driving the instances started with Start.
+//
+// Parameters:
+//   - publisher: The publisher, usually the *pubsub.Event of the application.
+//
+// Returns:
+//   - The saga, for chaining.
+func (s *Saga[P]) WithPublisher(publisher Publisher) *Saga[P] {
+	s.publisher = publisher
+	return s
+}
+
+// WithRetryBackoff sets the delay before the first retry of a failing step.
+//
+// Parameters:
+//   - backoff: The delay, doubled at each retry.
+//
+// Returns:
+//   - The saga, for chaining.
+func (s *Saga[P]) WithRetryBackoff(backoff time.Duration) *Saga[P] {
+	s.backoff = backoff
+	return s
+}
+
+// OnSagaFailed sets the function called once an instance failed, after its compensation.
+//
+// Parameters:
+//   - fn: Called with the instance, StatusCompensated or StatusFailed, and the error of the failed step.
+//
+// Returns:
+//   - The saga, for chaining.
+func (s *Saga[P]) OnSagaFailed(fn func(ctx context.Context, instance Instance, err error)) *Saga[P] {
+	s.onFailed = fn
+	return s
+}
+
+// Name returns the name of the saga.
+func (s *Saga[P]) Name() string {
+	return s.name
+}
+
+// StepEvent returns the name of the event driving the instances started with Start, the
+// queue consuming it must hand its messages to ConsumeMessage.
+func (s *Saga[P]) StepEvent() string {
+	return "saga." + s.name + ".step"
+}
+
+// Run starts an instance and runs its steps until it completes or is compensated.
+//
+// Parameters:
+//   - ctx: The context of the run.
+//   - id: The ID of the instance.
+//   - payload: The payload of the first step.
+//
+// Returns:
+//   - The instance once done, StatusCompleted when every step succeeded.
+//   - ErrInstanceExists, or an error if the state cannot be stored. A failed step is reported by the status.
+func (s *Saga[P]) Run(ctx context.Context, id string, payload P) (Instance, error) {
+	instance, err := s.create(ctx, id, payload)
+	if err != nil {
+		return Instance{}, err
+	}
+	return s.drive(ctx, instance)
+}
+
+// Resume runs the remaining steps of an instance, e.g. after the process running it stopped.
+//
+// Parameters:
+//   - ctx: The context of the run.
+//   - id: The ID of the instance.
+//
+// Returns:
+//   - The instance once done.
+//   - ErrInstanceNotFound, or an error if the state cannot be loaded or stored.
+func (s *Saga[P]) Resume(ctx context.Context, id string) (Instance, error) {
+	instance, err := s.load(ctx, id)
+	if err != nil {
+		return Instance{}, err
+	}
+	return s.drive(ctx, instance)
+}
+
+// Start starts an instance driven by events: each step runs when ConsumeMessage receives the
+// event published by the previous one, so the steps of an instance may run on different
+// processes and survive restarts.
+//
+// Parameters:
+//   - ctx: The context of the operation.
+//   - id: The ID of the instance.
+//   - payload: The payload of the first step.
+//
+// Returns:
+//   - The started instance.
+//   - ErrInstanceExists, or an error if the saga has no publisher, the state cannot be stored or the event cannot be published.
+func (s *Saga[P]) Start(ctx context.Context, id string, payload P) (Instance, error) {
+	if s.publisher == nil {
+		return Instance{}, fmt.Errorf("saga %s has no publisher", s.name)
+	}
+
+	instance, err := s.create(ctx, id, payload)
+	if err != nil {
+		return Instance{}, err
+	}
+	return instance, s.publish(instance)
+}
+
+// stepMessage is the payload of the event driving an instance, the status and the step tell
+// apart the redelivered events of the steps already run.
+type stepMessage struct {
+	ID     string `json:"id"`
+	Status Status `json:"status"`
+	Step   int    `json:"step"`
+}
+
+// ConsumeMessage runs the next step of the instance of a StepEvent message and publishes the
+// event of the following one, it implements rabbitmq_controller.MessageConsumer. The message
+// is acked once the step ran, and nacked when the state cannot be loaded or stored or the
+// next event cannot be published, so it is retried.
+//
+// Parameters:
+//   - index: The index of the message since the consumer started.
+//   - msg: The message of the StepEvent.
+func (s *Saga[P]) ConsumeMessage(_ int, msg *amqp.Delivery) {
+	var data struct {
+		ID      string      `json:"id"`
+		Payload stepMessage `json:"payload"`
+	}
+
+	err := json.Unmarshal(msg.Body, &data)
+
+	traceID := data.ID
+	if traceID == "" {
+		traceID = util.GenerateID(16)
+	}
+	ctx := logger.SetTraceID(context.Background(), traceID)
+
+	if err != nil {
+		s.log.Error(ctx, "saga %s: decode message %q: %v", s.name, msg.MessageId, err)
+		s.settle(ctx, msg.Reject(false))
+		return
+	}
+
+	if err := s.consume(ctx, data.Payload); err != nil {
+		s.log.Error(ctx, "saga %s: instance %s: %v", s.name, data.Payload.ID, err)
+		s.settle(ctx, msg.Nack(false, false))
+		return
+	}
+
+	s.settle(ctx, msg.Ack(false))
+}
+
+// consume runs the step of a StepEvent message and publishes the event of the next one.
+func (s *Saga[P]) consume(ctx context.Context, m stepMessage) error {
+	instance, err := s.load(ctx, m.ID)
+	if err != nil {
+		return err
+	}
+
+	// a redelivered event whose step already ran
+	if instance.Status != m.Status || instance.Step != m.Step {
+		if instance.Status.Done() {
+			return nil
+		}
+		return s.publish(instance)
+	}
+
+	if instance, err = s.advance(ctx, instance); err != nil {
+		return err
+	}
+	if instance.Status.Done() {
+		return nil
+	}
+	return s.publish(instance)
+}
+
+// publish publishes the event running the next step of an instance.
+func (s *Saga[P]) publish(instance Instance) error {
+	return s.publisher.Publish(s.StepEvent(), stepMessage{ID: instance.ID, Status: instance.Status, Step: instance.Step})
+}
+
+// settle logs the error acking, nacking or rejecting a message.
+func (s *Saga[P]) settle(ctx context.Context, err error) {
+	if err != nil {
+		s.log.Error(ctx, "saga %s: settle message: %v", s.name, err)
+	}
+}
+
+// create stores a new instance.
+func (s *Saga[P]) create(ctx context.Context, id string, payload P) (Instance, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return Instance{}, fmt.Errorf("saga %s: cannot encode the payload: %w", s.name, err)
+	}
+
+	now := s.clock.Now().UTC()
+	instance := Instance{
+		ID:        id,
+		Saga:      s.name,
+		Status:    StatusRunning,
+		Payload:   raw,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if len(s.steps) == 0 {
+		instance.Status = StatusCompleted
+	}
+
+	if err := s.store.CreateInstance(ctx, instance); err != nil {
+		return Instance{}, err
+	}
+	return instance, nil
+}
+
+// load loads an instance of the saga.
+func (s *Saga[P]) load(ctx context.Context, id string) (Instance, error) {
+	instance, err := s.store.LoadInstance(ctx, id)
+	if err != nil {
+		return Instance{}, err
+	}
+	if instance.Saga != s.name {
+		return Instance{}, ErrSagaMismatch.Var(id, instance.Saga)
+	}
+	return instance, nil
+}
+
+// drive advances an instance until it is done.
+func (s *Saga[P]) drive(ctx context.Context, instance Instance) (Instance, error) {
+	var err error
+	for !instance.Status.Done() {
+		if instance, err = s.advance(ctx, instance); err != nil {
+			return instance, err
+		}
+	}
+	return instance, nil
+}
+
+// advance executes or compensates the current step of an instance and stores its new state.
+//
+// Parameters:
+//   - ctx: The context of the operation.
+//   - instance: The instance, running or compensating.
+//
+// Returns:
+//   - The instance at its next step.
+//   - An error if the payload cannot be decoded or encoded or the state cannot be stored.
+func (s *Saga[P]) advance(ctx context.Context, instance Instance) (Instance, error) {
+	var payload P
+	if err := json.Unmarshal(instance.Payload, &payload); err != nil {
+		return instance, fmt.Errorf("saga %s: cannot decode the payload of %s: %w", s.name, instance.ID, err)
+	}
+
+	step := s.steps[instance.Step]
+	var stepErr error
+
+	switch instance.Status {
+	case StatusRunning:
+		var result *P
+		result, stepErr = s.attempt(ctx, step, step.Execute, payload)
+		if stepErr == nil {
+			if result != nil {
+				payload = *result
+			}
+			instance.Step++
+			if instance.Step == len(s.steps) {
+				instance.Status = StatusCompleted
+			}
+			break
+		}
+
+		s.log.Error(ctx, "saga %s: instance %s: step %s failed: %v", s.name, instance.ID, step.Name, stepErr)
+		instance.Status = StatusCompensating
+		instance.Error = fmt.Sprintf("step %s: %v", step.Name, stepErr)
+		instance.Step--
+
+	case StatusCompensating:
+		var result *P
+		if step.Compensate != nil {
+			result, stepErr = s.attempt(ctx, step, step.Compensate, payload)
+		}
+		if stepErr != nil {
+			s.log.Error(ctx, "saga %s: instance %s: compensation of step %s failed: %v", s.name, instance.ID, step.Name, stepErr)
+			instance.Status = StatusFailed
+			instance.Error = fmt.Sprintf("%s; compensation of step %s: %v", instance.Error, step.Name, stepErr)
+			break
+		}
+		if result != nil {
+			payload = *result
+		}
+		instance.Step--
+	}
+
+	if instance.Status == StatusCompensating && instance.Step < 0 {
+		instance.Status = StatusCompensated
+		instance.Step = 0
+	}
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return instance, fmt.Errorf("saga %s: cannot encode the payload of %s: %w", s.name, instance.ID, err)
+	}
+	instance.Payload = raw
+	instance.UpdatedAt = s.clock.Now().UTC()
+
+	if err := s.store.UpdateInstance(ctx, instance); err != nil {
+		return instance, err
+	}
+
+	if instance.Status == StatusCompensated || instance.Status == StatusFailed {
+		s.onFailed(ctx, instance, errors.New(instance.Error))
+	}
+	return instance, nil
+}
+
+// attempt runs an inport of a step, retrying the failures with an exponential backoff until
+// they are permanent or the retries of the step are exhausted.
+//
+// Parameters:
+//   - ctx: The context of the operation.
+//   - step: The step.
+//   - inport: The Execute or Compensate inport of the step.
+//   - payload: The payload.
+//
+// Returns:
+//   - The payload returned by the inport.
+//   - The last error of the inport.
+func (s *Saga[P]) attempt(ctx context.Context, step Step[P], inport wotop.Inport[P, P], payload P) (*P, error) {
+	backoff := s.backoff
+
+	for attempt := 0; ; attempt++ {
+		result, err := s.call(ctx, step, inport, payload)
+		if err == nil {
+			return result, nil
+		}
+
+		var permanent permanentError
+		if errors.As(err, &permanent) || attempt >= step.Retries || ctx.Err() != nil {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// call runs an inport of a step within the timeout of the step. An inport ignoring its context
+// is left running in the background once the timeout expired.
+func (s *Saga[P]) call(ctx context.Context, step Step[P], inport wotop.Inport[P, P], payload P) (*P, error) {
+	if step.Timeout <= 0 {
+		return inport.Execute(ctx, payload)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, step.Timeout)
+	defer cancel()
+
+	type outcome struct {
+		result *P
+		err    error
+	}
+	done := make(chan outcome, 1)
+
+	go func() {
+		result, err := inport.Execute(ctx, payload)
+		done <- outcome{result, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("step %s timed out after %s: %w", step.Name, step.Timeout, ctx.Err())
+	case o := <-done:
+		return o.result, o.err
+	}
+}
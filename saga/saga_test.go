@@ -0,0 +1,320 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop/model/apperror"
+	"github.com/a-aslani/wotop/pubsub"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// assertCode checks the apperror code of the error.
+func assertCode(t *testing.T, err error, want apperror.ErrorType) {
+	t.Helper()
+	var et apperror.ErrorType
+	require.ErrorAs(t, err, &et)
+	assert.Equal(t, want.Code(), et.Code(), err.Error())
+}
+
+// order is the payload of the test saga.
+type order struct {
+	ID      string   `json:"id"`
+	Applied []string `json:"applied"`
+}
+
+// inportFunc adapts a function to a wotop.Inport.
+type inportFunc func(ctx context.Context, req order) (*order, error)
+
+func (f inportFunc) Execute(ctx context.Context, req order) (*order, error) { return f(ctx, req) }
+
+// nopLogger is a logger.Logger discarding the messages.
+type nopLogger struct{}
+
+func (nopLogger) Info(context.Context, string, ...any)    {}
+func (nopLogger) Error(context.Context, string, ...any)   {}
+func (nopLogger) Warning(context.Context, string, ...any) {}
+
+// journal records the calls of the steps.
+type journal struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (j *journal) record(call string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.calls = append(j.calls, call)
+}
+
+func (j *journal) get() []string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]string(nil), j.calls...)
+}
+
+// step returns a step recording its calls, failing with fail when it is not nil.
+func (j *journal) step(name string, fail error) Step[order] {
+	return Step[order]{
+		Name: name,
+		Execute: inportFunc(func(_ context.Context, o order) (*order, error) {
+			j.record("execute " + name)
+			if fail != nil {
+				return nil, fail
+			}
+			o.Applied = append(o.Applied, name)
+			return &o, nil
+		}),
+		Compensate: inportFunc(func(_ context.Context, o order) (*order, error) {
+			j.record("compensate " + name)
+			o.Applied = o.Applied[:len(o.Applied)-1]
+			return &o, nil
+		}),
+	}
+}
+
+// placeOrder returns the steps of a saga of four steps, the third one failing with fail.
+func (j *journal) placeOrder(fail error) []Step[order] {
+	return []Step[order]{
+		j.step("reserve", nil),
+		j.step("charge", nil),
+		j.step("ship", fail),
+		j.step("notify", nil),
+	}
+}
+
+func TestSagaRunCompleted(t *testing.T) {
+	j := &journal{}
+	store := NewMemoryStateStore()
+	s := New("place_order", store, nopLogger{}, j.placeOrder(nil)...)
+
+	instance, err := s.Run(context.Background(), "order-1", order{ID: "order-1"})
+	require.NoError(t, err)
+	assert.Equal(t, StatusCompleted, instance.Status)
+	assert.Equal(t, 4, instance.Step)
+	assert.JSONEq(t, `{"id":"order-1","applied":["reserve","charge","ship","notify"]}`, string(instance.Payload))
+
+	stored, err := store.LoadInstance(context.Background(), "order-1")
+	require.NoError(t, err)
+	assert.Equal(t, instance, stored)
+
+	_, err = s.Run(context.Background(), "order-1", order{ID: "order-1"})
+	assertCode(t, err, ErrInstanceExists)
+}
+
+func TestSagaRunCompensatesInReverseOrder(t *testing.T) {
+	j := &journal{}
+	var failed []Instance
+
+	s := New("place_order", NewMemoryStateStore(), nopLogger{}, j.placeOrder(Permanent(errors.New("no carrier available")))...).
+		OnSagaFailed(func(_ context.Context, instance Instance, err error) {
+			failed = append(failed, instance)
+			assert.EqualError(t, err, "step ship: no carrier available")
+		})
+
+	instance, err := s.Run(context.Background(), "order-1", order{ID: "order-1"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"execute reserve", "execute charge", "execute ship",
+		"compensate charge", "compensate reserve",
+	}, j.get())
+	assert.Equal(t, StatusCompensated, instance.Status)
+	assert.Equal(t, "step ship: no carrier available", instance.Error)
+	assert.JSONEq(t, `{"id":"order-1","applied":[]}`, string(instance.Payload))
+	require.Len(t, failed, 1)
+	assert.Equal(t, StatusCompensated, failed[0].Status)
+}
+
+func TestSagaRetriesFailingStep(t *testing.T) {
+	attempts := 0
+	flaky := Step[order]{
+		Name:    "charge",
+		Retries: 2,
+		Execute: inportFunc(func(_ context.Context, o order) (*order, error) {
+			attempts++
+			if attempts < 3 {
+				return nil, errors.New("payment gateway unavailable")
+			}
+			return nil, nil
+		}),
+	}
+
+	s := New("place_order", NewMemoryStateStore(), nopLogger{}, flaky).WithRetryBackoff(time.Millisecond)
+	instance, err := s.Run(context.Background(), "order-1", order{ID: "order-1"})
+	require.NoError(t, err)
+	assert.Equal(t, StatusCompleted, instance.Status)
+	assert.Equal(t, 3, attempts)
+
+	// a permanent error is not retried
+	attempts = -10
+	flaky.Execute = inportFunc(func(context.Context, order) (*order, error) {
+		attempts++
+		return nil, Permanent(errors.New("card declined"))
+	})
+	s = New("place_order", NewMemoryStateStore(), nopLogger{}, flaky).WithRetryBackoff(time.Millisecond)
+	instance, err = s.Run(context.Background(), "order-1", order{ID: "order-1"})
+	require.NoError(t, err)
+	assert.Equal(t, StatusCompensated, instance.Status)
+	assert.Equal(t, -9, attempts)
+}
+
+func TestSagaStepTimeout(t *testing.T) {
+	j := &journal{}
+	steps := j.placeOrder(nil)
+	steps[2].Timeout = 10 * time.Millisecond
+	steps[2].Execute = inportFunc(func(context.Context, order) (*order, error) {
+		time.Sleep(200 * time.Millisecond) // ignores its context
+		return nil, nil
+	})
+
+	s := New("place_order", NewMemoryStateStore(), nopLogger{}, steps...)
+	start := time.Now()
+	instance, err := s.Run(context.Background(), "order-1", order{ID: "order-1"})
+	require.NoError(t, err)
+
+	assert.Less(t, time.Since(start), 150*time.Millisecond)
+	assert.Equal(t, StatusCompensated, instance.Status)
+	assert.Contains(t, instance.Error, "step ship timed out after 10ms")
+	assert.Equal(t, []string{"execute reserve", "execute charge", "compensate charge", "compensate reserve"}, j.get())
+}
+
+func TestSagaCompensationFailure(t *testing.T) {
+	j := &journal{}
+	steps := j.placeOrder(Permanent(errors.New("no carrier available")))
+	steps[1].Compensate = inportFunc(func(context.Context, order) (*order, error) {
+		j.record("compensate charge")
+		return nil, errors.New("refund rejected")
+	})
+
+	var failed []Status
+	s := New("place_order", NewMemoryStateStore(), nopLogger{}, steps...).
+		OnSagaFailed(func(_ context.Context, instance Instance, _ error) { failed = append(failed, instance.Status) })
+
+	instance, err := s.Run(context.Background(), "order-1", order{ID: "order-1"})
+	require.NoError(t, err)
+	assert.Equal(t, StatusFailed, instance.Status)
+	assert.Equal(t, 1, instance.Step)
+	assert.Equal(t, "step ship: no carrier available; compensation of step charge: refund rejected", instance.Error)
+	assert.Equal(t, []string{"execute reserve", "execute charge", "execute ship", "compensate charge"}, j.get())
+	assert.Equal(t, []Status{StatusFailed}, failed)
+}
+
+func TestSagaResume(t *testing.T) {
+	j := &journal{}
+	store := NewMemoryStateStore()
+	require.NoError(t, store.CreateInstance(context.Background(), Instance{
+		ID:      "order-1",
+		Saga:    "place_order",
+		Status:  StatusRunning,
+		Step:    2,
+		Payload: json.RawMessage(`{"id":"order-1","applied":["reserve","charge"]}`),
+	}))
+
+	s := New("place_order", store, nopLogger{}, j.placeOrder(nil)...)
+	instance, err := s.Resume(context.Background(), "order-1")
+	require.NoError(t, err)
+	assert.Equal(t, StatusCompleted, instance.Status)
+	assert.Equal(t, []string{"execute ship", "execute notify"}, j.get())
+
+	_, err = s.Resume(context.Background(), "order-2")
+	assertCode(t, err, ErrInstanceNotFound)
+
+	other := New("refund", store, nopLogger{}, j.placeOrder(nil)...)
+	_, err = other.Resume(context.Background(), "order-1")
+	assertCode(t, err, ErrSagaMismatch)
+}
+
+// queuePublisher is a Publisher queuing the messages as pubsub.Event would publish them.
+type queuePublisher struct {
+	messages []*amqp.Delivery
+}
+
+func (p *queuePublisher) Publish(eventName string, payload pubsub.Payload) error {
+	body, err := json.Marshal(pubsub.EventData{ID: fmt.Sprintf("event-%d", len(p.messages)), Name: eventName, Payload: payload})
+	if err != nil {
+		return err
+	}
+	p.messages = append(p.messages, &amqp.Delivery{RoutingKey: eventName, Body: body})
+	return nil
+}
+
+// recordingAcknowledger records how the messages are settled.
+type recordingAcknowledger struct {
+	settled []string
+}
+
+func (a *recordingAcknowledger) Ack(uint64, bool) error {
+	a.settled = append(a.settled, "ack")
+	return nil
+}
+
+func (a *recordingAcknowledger) Nack(uint64, bool, bool) error {
+	a.settled = append(a.settled, "nack")
+	return nil
+}
+
+func (a *recordingAcknowledger) Reject(uint64, bool) error {
+	a.settled = append(a.settled, "reject")
+	return nil
+}
+
+func TestSagaDrivenByEvents(t *testing.T) {
+	j := &journal{}
+	store := NewMemoryStateStore()
+	publisher := &queuePublisher{}
+	ack := &recordingAcknowledger{}
+
+	var failed int
+	s := New("place_order", store, nopLogger{}, j.placeOrder(Permanent(errors.New("no carrier available")))...).
+		WithPublisher(publisher).
+		OnSagaFailed(func(context.Context, Instance, error) { failed++ })
+
+	instance, err := s.Start(context.Background(), "order-1", order{ID: "order-1"})
+	require.NoError(t, err)
+	assert.Equal(t, StatusRunning, instance.Status)
+	assert.Empty(t, j.get())
+
+	// each message runs a single step and publishes the next one
+	for i := 0; i < len(publisher.messages); i++ {
+		msg := publisher.messages[i]
+		assert.Equal(t, "saga.place_order.step", msg.RoutingKey)
+		msg.Acknowledger = ack
+		s.ConsumeMessage(i, msg)
+		assert.Len(t, j.get(), i+1)
+	}
+
+	assert.Equal(t, []string{
+		"execute reserve", "execute charge", "execute ship",
+		"compensate charge", "compensate reserve",
+	}, j.get())
+	assert.Equal(t, []string{"ack", "ack", "ack", "ack", "ack"}, ack.settled)
+	assert.Equal(t, 1, failed)
+
+	instance, err = store.LoadInstance(context.Background(), "order-1")
+	require.NoError(t, err)
+	assert.Equal(t, StatusCompensated, instance.Status)
+
+	// a redelivered message does not run its step again
+	s.ConsumeMessage(5, publisher.messages[1])
+	assert.Len(t, j.get(), 5)
+	assert.Len(t, publisher.messages, 5)
+	assert.Equal(t, "ack", ack.settled[len(ack.settled)-1])
+
+	// a message which is not a step event is rejected
+	s.ConsumeMessage(6, &amqp.Delivery{Acknowledger: ack, Body: []byte("{")})
+	assert.Equal(t, "reject", ack.settled[len(ack.settled)-1])
+}
+
+func TestSagaStartWithoutPublisher(t *testing.T) {
+	s := New[order]("place_order", NewMemoryStateStore(), nopLogger{})
+	_, err := s.Start(context.Background(), "order-1", order{})
+	assert.EqualError(t, err, "saga place_order has no publisher")
+}
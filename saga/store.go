@@ -0,0 +1,214 @@
+package saga
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/a-aslani/wotop/postgres_db"
+	"github.com/lib/pq"
+)
+
+// StateStore stores the state of the saga instances.
+type StateStore interface {
+	// CreateInstance stores a new instance.
+	//
+	// Parameters:
+	//   - ctx: The context of the operation.
+	//   - instance: The instance.
+	//
+	// Returns:
+	//   - ErrInstanceExists if an instance has the ID already, or an error if it cannot be stored.
+	CreateInstance(ctx context.Context, instance Instance) error
+
+	// UpdateInstance stores the new state of an instance.
+	//
+	// Parameters:
+	//   - ctx: The context of the operation.
+	//   - instance: The instance.
+	//
+	// Returns:
+	//   - ErrInstanceNotFound if no instance has the ID, or an error if it cannot be stored.
+	UpdateInstance(ctx context.Context, instance Instance) error
+
+	// LoadInstance loads an instance.
+	//
+	// Parameters:
+	//   - ctx: The context of the operation.
+	//   - id: The ID of the instance.
+	//
+	// Returns:
+	//   - The instance.
+	//   - ErrInstanceNotFound if no instance has the ID, or an error if it cannot be loaded.
+	LoadInstance(ctx context.Context, id string) (Instance, error)
+}
+
+// MemoryStateStore is a StateStore keeping the instances in memory, e.g. for tests. It is safe
+// for concurrent use.
+type MemoryStateStore struct {
+	mu        sync.RWMutex
+	instances map[string]Instance
+}
+
+var _ StateStore = (*MemoryStateStore)(nil)
+
+// NewMemoryStateStore creates an empty MemoryStateStore.
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{instances: map[string]Instance{}}
+}
+
+func (s *MemoryStateStore) CreateInstance(_ context.Context, instance Instance) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.instances[instance.ID]; ok {
+		return ErrInstanceExists.Var(instance.ID)
+	}
+	s.instances[instance.ID] = instance
+	return nil
+}
+
+func (s *MemoryStateStore) UpdateInstance(_ context.Context, instance Instance) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.instances[instance.ID]; !ok {
+		return ErrInstanceNotFound.Var(instance.ID)
+	}
+	s.instances[instance.ID] = instance
+	return nil
+}
+
+func (s *MemoryStateStore) LoadInstance(_ context.Context, id string) (Instance, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	instance, ok := s.instances[id]
+	if !ok {
+		return Instance{}, ErrInstanceNotFound.Var(id)
+	}
+	return instance, nil
+}
+
+// DefaultTable is the table of the instances when NewPostgresStateStore is given none.
+const DefaultTable = "saga_instances"
+
+// uniqueViolation is the Postgres error code of a unique constraint violation.
+const uniqueViolation = "23505"
+
+// PostgresStateStore is a StateStore keeping the instances in a Postgres table created by
+// Schema. The instances are stored in the transaction of the context, see postgres_db.WithTx,
+// when there is one.
+type PostgresStateStore struct {
+	db    *sql.DB
+	table string
+}
+
+var _ StateStore = (*PostgresStateStore)(nil)
+
+// NewPostgresStateStore creates a PostgresStateStore.
+//
+// Parameters:
+//   - db: The connection pool.
+//   - table: The table of the instances, DefaultTable when empty.
+//
+// Returns:
+//   - The state store.
+func NewPostgresStateStore(db *sql.DB, table string) *PostgresStateStore {
+	if table == "" {
+		table = DefaultTable
+	}
+	return &PostgresStateStore{db: db, table: table}
+}
+
+// Schema returns the statement creating the table of the instances, e.g. for a migration.
+//
+// Parameters:
+//   - table: The table of the instances, DefaultTable when empty.
+//
+// Returns:
+//   - The CREATE TABLE statement.
+func Schema(table string) string {
+	if table == "" {
+		table = DefaultTable
+	}
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id TEXT PRIMARY KEY,
+	saga TEXT NOT NULL,
+	status TEXT NOT NULL,
+	step INTEGER NOT NULL,
+	payload JSONB NOT NULL,
+	error TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+)`, pq.QuoteIdentifier(table))
+}
+
+// CreateSchema creates the table of the instances unless it exists.
+//
+// Parameters:
+//   - ctx: The context of the operation.
+//
+// Returns:
+//   - An error if the table cannot be created.
+func (s *PostgresStateStore) CreateSchema(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, Schema(s.table)); err != nil {
+		return fmt.Errorf("cannot create the table %s: %w", s.table, err)
+	}
+	return nil
+}
+
+func (s *PostgresStateStore) CreateInstance(ctx context.Context, instance Instance) error {
+	_, err := postgres_db.Conn(ctx, s.db).ExecContext(ctx,
+		fmt.Sprintf("INSERT INTO %s (id, saga, status, step, payload, error, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)", pq.QuoteIdentifier(s.table)),
+		instance.ID, instance.Saga, string(instance.Status), instance.Step, []byte(instance.Payload), instance.Error, instance.CreatedAt, instance.UpdatedAt,
+	)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return ErrInstanceExists.Var(instance.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot create the saga instance %s: %w", instance.ID, err)
+	}
+	return nil
+}
+
+func (s *PostgresStateStore) UpdateInstance(ctx context.Context, instance Instance) error {
+	result, err := postgres_db.Conn(ctx, s.db).ExecContext(ctx,
+		fmt.Sprintf("UPDATE %s SET status = $2, step = $3, payload = $4, error = $5, updated_at = $6 WHERE id = $1", pq.QuoteIdentifier(s.table)),
+		instance.ID, string(instance.Status), instance.Step, []byte(instance.Payload), instance.Error, instance.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("cannot update the saga instance %s: %w", instance.ID, err)
+	}
+
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrInstanceNotFound.Var(instance.ID)
+	}
+	return nil
+}
+
+func (s *PostgresStateStore) LoadInstance(ctx context.Context, id string) (Instance, error) {
+	var (
+		instance Instance
+		status   string
+		payload  []byte
+	)
+	err := postgres_db.Conn(ctx, s.db).QueryRowContext(ctx,
+		fmt.Sprintf("SELECT id, saga, status, step, payload, error, created_at, updated_at FROM %s WHERE id = $1", pq.QuoteIdentifier(s.table)),
+		id,
+	).Scan(&instance.ID, &instance.Saga, &status, &instance.Step, &payload, &instance.Error, &instance.CreatedAt, &instance.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Instance{}, ErrInstanceNotFound.Var(id)
+	}
+	if err != nil {
+		return Instance{}, fmt.Errorf("cannot load the saga instance %s: %w", id, err)
+	}
+
+	instance.Status = Status(status)
+	instance.Payload = payload
+	return instance, nil
+}
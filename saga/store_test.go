@@ -0,0 +1,68 @@
+package saga
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresStateStore(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	s := NewPostgresStateStore(db, "")
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	instance := Instance{
+		ID:        "order-1",
+		Saga:      "place_order",
+		Status:    StatusRunning,
+		Payload:   json.RawMessage(`{"id":"order-1"}`),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	insert := regexp.QuoteMeta(`INSERT INTO "saga_instances" (id, saga, status, step, payload, error, created_at, updated_at)`)
+	mock.ExpectExec(insert).
+		WithArgs("order-1", "place_order", "running", 0, []byte(`{"id":"order-1"}`), "", now, now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, s.CreateInstance(ctx, instance))
+
+	mock.ExpectExec(insert).WillReturnError(&pq.Error{Code: uniqueViolation})
+	assertCode(t, s.CreateInstance(ctx, instance), ErrInstanceExists)
+
+	update := regexp.QuoteMeta(`UPDATE "saga_instances" SET status = $2, step = $3, payload = $4, error = $5, updated_at = $6 WHERE id = $1`)
+	instance.Step = 1
+	mock.ExpectExec(update).
+		WithArgs("order-1", "running", 1, []byte(`{"id":"order-1"}`), "", now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, s.UpdateInstance(ctx, instance))
+
+	mock.ExpectExec(update).WillReturnResult(sqlmock.NewResult(0, 0))
+	assertCode(t, s.UpdateInstance(ctx, Instance{ID: "order-2"}), ErrInstanceNotFound)
+
+	load := regexp.QuoteMeta(`SELECT id, saga, status, step, payload, error, created_at, updated_at FROM "saga_instances" WHERE id = $1`)
+	mock.ExpectQuery(load).WithArgs("order-1").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "saga", "status", "step", "payload", "error", "created_at", "updated_at"}).
+			AddRow("order-1", "place_order", "running", 1, []byte(`{"id":"order-1"}`), "", now, now),
+	)
+	loaded, err := s.LoadInstance(ctx, "order-1")
+	require.NoError(t, err)
+	assert.Equal(t, instance, loaded)
+
+	mock.ExpectQuery(load).WithArgs("order-2").WillReturnError(sql.ErrNoRows)
+	_, err = s.LoadInstance(ctx, "order-2")
+	assertCode(t, err, ErrInstanceNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Contains(t, Schema(""), `CREATE TABLE IF NOT EXISTS "saga_instances"`)
+}
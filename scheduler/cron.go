@@ -0,0 +1,258 @@
+package scheduler
+
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule computes the run times of a job.
+type Schedule interface {
+	// Next returns the first run time strictly after t.
+	//
+	// Parameters:
+	//   - t: The time to search from.
+	//
+	// Returns:
+	//   - The next run time, the zero time when there is none within five years.
+	Next(t time.Time) time.Time
+}
+
+// field is the range and the names of a field of a cron expression.
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowField = field{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// descriptors are the predefined schedules.
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronSchedule is a Schedule given by the bits of the allowed values of each field.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+	loc                           *time.Location
+}
+
+// everySchedule runs a job at a fixed interval.
+type everySchedule struct {
+	interval time.Duration
+}
+
+// ParseCron parses a standard cron expression of five fields: minute, hour, day of month,
+// month and day of week. The fields accept "*", values, ranges "1-5", lists "1,15", steps
+// "*/10" or "0-30/5", and the names of the months and the days, e.g. "jan" and "mon".
+// When the day of month and the day of week are both restricted, a day matching either runs
+// the job, like cron does. The descriptors @yearly, @monthly, @weekly, @daily and @hourly
+// are accepted, as well as "@every <duration>", e.g. "@every 90s".
+//
+// The times are computed in the location of the time given to Next, use ParseCronIn to fix it.
+//
+// Parameters:
+//   - spec: The cron expression.
+//
+// Returns:
+//   - The Schedule.
+//   - An error describing the invalid field.
+func ParseCron(spec string) (Schedule, error) {
+	return ParseCronIn(spec, nil)
+}
+
+// ParseCronIn parses a cron expression like ParseCron, computing its times in a location.
+//
+// Parameters:
+//   - spec: The cron expression.
+//   - loc: The location of the times, e.g. time.UTC, nil for the location of the time given to Next.
+//
+// Returns:
+//   - The Schedule.
+//   - An error describing the invalid field.
+func ParseCronIn(spec string, loc *time.Location) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+
+	if after, ok := strings.CutPrefix(spec, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(after))
+		if err != nil {
+			return nil, fmt.Errorf("cron %q: %w", spec, err)
+		}
+		if d < time.Second {
+			return nil, fmt.Errorf("cron %q: the interval must be at least one second", spec)
+		}
+		return everySchedule{interval: d}, nil
+	}
+
+	if expanded, ok := descriptors[strings.ToLower(spec)]; ok {
+		spec = expanded
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron %q: expected 5 fields, got %d", spec, len(fields))
+	}
+
+	s := &cronSchedule{loc: loc}
+	var err error
+	for i, f := range []struct {
+		bits *uint64
+		def  field
+	}{
+		{&s.minute, minuteField},
+		{&s.hour, hourField},
+		{&s.dom, domField},
+		{&s.month, monthField},
+		{&s.dow, dowField},
+	} {
+		*f.bits, err = parseField(fields[i], f.def)
+		if err != nil {
+			return nil, fmt.Errorf("cron %q: %w", spec, err)
+		}
+	}
+
+	// 7 is sunday too
+	if s.dow&(1<<7) != 0 {
+		s.dow = s.dow&^(1<<7) | 1
+	}
+	s.domAny = strings.HasPrefix(fields[2], "*")
+	s.dowAny = strings.HasPrefix(fields[4], "*")
+
+	return s, nil
+}
+
+// parseField returns the bits of the values allowed by a field.
+func parseField(expr string, f field) (uint64, error) {
+	var result uint64
+
+	for _, part := range strings.Split(expr, ",") {
+		rng, stepExpr, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepExpr)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q of the %s", stepExpr, f.name)
+			}
+		}
+
+		lo, hi := f.min, f.max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			from, to, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = f.value(from); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(to); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range %q of the %s", rng, f.name)
+			}
+		default:
+			var err error
+			if lo, err = f.value(rng); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if hasStep {
+				hi = f.max
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			result |= 1 << v
+		}
+	}
+
+	return result, nil
+}
+
+// value parses a number or a name of the field.
+func (f field) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid %s %q, expected %d-%d", f.name, s, f.min, f.max)
+	}
+	return v, nil
+}
+
+func (s everySchedule) Next(t time.Time) time.Time {
+	return t.Add(s.interval).Truncate(time.Second)
+}
+
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	if s.loc != nil {
+		t = t.In(s.loc)
+	}
+
+	// the first whole minute after t
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			// jump to the next allowed minute of the hour, or to the next hour
+			next := s.minute >> uint(t.Minute()+1)
+			if next == 0 {
+				t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			} else {
+				t = t.Add(time.Duration(bits.TrailingZeros64(next)+1) * time.Minute)
+			}
+			continue
+		}
+		return t.In(loc)
+	}
+
+	return time.Time{}
+}
+
+// dayMatches reports whether the day of t is allowed by the day of month and the day of week.
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
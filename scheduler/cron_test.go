@@ -0,0 +1,114 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCronNext(t *testing.T) {
+	// a wednesday
+	from := time.Date(2026, 10, 14, 10, 2, 30, 0, time.UTC)
+
+	tests := map[string]struct {
+		spec string
+		want []time.Time
+	}{
+		"every minute": {
+			spec: "* * * * *",
+			want: []time.Time{time.Date(2026, 10, 14, 10, 3, 0, 0, time.UTC), time.Date(2026, 10, 14, 10, 4, 0, 0, time.UTC)},
+		},
+		"step": {
+			spec: "*/15 * * * *",
+			want: []time.Time{time.Date(2026, 10, 14, 10, 15, 0, 0, time.UTC), time.Date(2026, 10, 14, 10, 30, 0, 0, time.UTC)},
+		},
+		"list and range": {
+			spec: "0,30 9-10 * * *",
+			want: []time.Time{time.Date(2026, 10, 14, 10, 30, 0, 0, time.UTC), time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)},
+		},
+		"range with step": {
+			spec: "10-40/15 * * * *",
+			want: []time.Time{time.Date(2026, 10, 14, 10, 10, 0, 0, time.UTC), time.Date(2026, 10, 14, 10, 25, 0, 0, time.UTC), time.Date(2026, 10, 14, 10, 40, 0, 0, time.UTC), time.Date(2026, 10, 14, 11, 10, 0, 0, time.UTC)},
+		},
+		"week days by name": {
+			spec: "0 8 * * mon-fri",
+			want: []time.Time{time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC), time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC), time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)},
+		},
+		"sunday as 7": {
+			spec: "0 0 * * 7",
+			want: []time.Time{time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		},
+		"day of month or day of week": {
+			spec: "0 0 1 * fri",
+			want: []time.Time{time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 23, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 30, 0, 0, 0, 0, time.UTC), time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		"month by name": {
+			spec: "0 0 29 feb *",
+			want: []time.Time{time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		},
+		"daily": {
+			spec: "@daily",
+			want: []time.Time{time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)},
+		},
+		"every": {
+			spec: "@every 90s",
+			want: []time.Time{time.Date(2026, 10, 14, 10, 4, 0, 0, time.UTC), time.Date(2026, 10, 14, 10, 5, 30, 0, time.UTC)},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, err := ParseCron(tt.spec)
+			require.NoError(t, err)
+
+			next := from
+			for _, want := range tt.want {
+				next = s.Next(next)
+				assert.Equal(t, want, next)
+			}
+		})
+	}
+}
+
+func TestParseCronIn(t *testing.T) {
+	tehran, err := time.LoadLocation("Asia/Tehran")
+	if err != nil {
+		t.Skip("no time zone database")
+	}
+
+	s, err := ParseCronIn("30 8 * * *", tehran)
+	require.NoError(t, err)
+
+	next := s.Next(time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2026, 10, 14, 5, 0, 0, 0, time.UTC), next, "08:30 in Tehran is 05:00 UTC")
+	assert.Equal(t, time.UTC, next.Location(), "the time is in the location of the argument")
+}
+
+func TestParseCronNever(t *testing.T) {
+	s, err := ParseCron("0 0 31 feb *")
+	require.NoError(t, err)
+	assert.True(t, s.Next(time.Now()).IsZero())
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"30-10 * * * *",
+		"* * * foo *",
+		"@every 10ms",
+		"@every soon",
+	} {
+		_, err := ParseCron(spec)
+		assert.Error(t, err, spec)
+	}
+}
@@ -0,0 +1,440 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/lock"
+	"github.com/a-aslani/wotop/logger"
+	"github.com/a-aslani/wotop/util"
+)
+
+const (
+	// DefaultJobTimeout is the time a run of a job gets when neither the job nor the Config sets one.
+	DefaultJobTimeout = 5 * time.Minute
+
+	// DefaultClockSkew is the difference tolerated between the clocks of the replicas.
+	DefaultClockSkew = 5 * time.Second
+
+	// DefaultShutdownTimeout is the time the running jobs get to finish when the scheduler is shut down.
+	DefaultShutdownTimeout = 30 * time.Second
+)
+
+// JobRequest is the request of the inport of a job.
+//
+// Fields:
+//   - Name: The name of the job.
+//   - ScheduledAt: The time the run is scheduled at, e.g. the end of the period to report on.
+type JobRequest struct {
+	Name        string
+	ScheduledAt time.Time
+}
+
+// JobResponse is the response of the inport of a job.
+//
+// Fields:
+//   - Message: A summary of the run, e.g. "42 expired tokens deleted", logged when the job finishes.
+type JobResponse struct {
+	Message string
+}
+
+// Clock is the time source of the Scheduler, tests drive the schedule with a fake one.
+type Clock interface {
+	util.Clock
+
+	// After waits for the duration to elapse and then sends the current time on the channel.
+	After(d time.Duration) <-chan time.Time
+}
+
+// systemClock is the Clock of the system.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Config configures the scheduler.
+//
+// Fields:
+//   - Timeout: The time a run of a job gets, DefaultJobTimeout when zero. WithTimeout overrides it per job.
+//   - Locker: The distributed locks, e.g. a lock.RedisLocker, nil when a single replica runs the jobs.
+//   - ClockSkew: The difference tolerated between the clocks of the replicas, DefaultClockSkew when zero.
+//   - ShutdownTimeout: The time the running jobs get to finish, DefaultShutdownTimeout when zero.
+//   - Clock: The time source, the system clock when nil.
+type Config struct {
+	Timeout         time.Duration
+	Locker          lock.Locker
+	ClockSkew       time.Duration
+	ShutdownTimeout time.Duration
+	Clock           Clock
+}
+
+// JobOption configures a job, see RegisterJob.
+type JobOption func(*job)
+
+// WithTimeout sets the time a run of the job gets, its context is canceled after.
+//
+// Parameters:
+//   - d: The timeout of the job.
+//
+// Returns:
+//   - The JobOption.
+func WithTimeout(d time.Duration) JobOption {
+	return func(j *job) {
+		j.timeout = d
+	}
+}
+
+// WithoutLock runs the job on every replica, e.g. a job cleaning a local cache.
+//
+// Returns:
+//   - The JobOption.
+func WithoutLock() JobOption {
+	return func(j *job) {
+		j.lock = false
+	}
+}
+
+// job is a job registered on the Scheduler.
+type job struct {
+	name     string
+	schedule Schedule
+	inport   wotop.Inport[JobRequest, JobResponse]
+	timeout  time.Duration
+	lock     bool
+	running  atomic.Bool
+}
+
+// Scheduler runs the periodic jobs of the application, e.g. the cleaning of the expired tokens
+// or the relay of an outbox, it implements wotop.ServiceRegisterer.
+//
+// A run of a job is skipped while the previous one is still running. With a Locker, a run is
+// also skipped when another replica holds the lock of the job: the lock is held during the run
+// and at least until ClockSkew after its scheduled time, so a replica whose clock is late does
+// not run it again. Each run gets a trace ID in its context and its start, end and error are
+// logged with structured fields, see logger.FieldLogger. A panic of a job is recovered and
+// logged as its error.
+//
+// Start blocks until SIGINT or SIGTERM is received or Stop is called, so the scheduler can
+// run alone or be registered on a wotop.Lifecycle.
+type Scheduler struct {
+	wotop.UsecaseRegisterer
+	log     logger.Logger
+	cfg     Config
+	appData wotop.ApplicationData
+	clock   Clock
+
+	jobs  []*job
+	names map[string]struct{}
+
+	// ctx is the parent of the contexts of the runs, canceled when the shutdown times out
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	stopping bool
+	runs     sync.WaitGroup
+	loops    sync.WaitGroup
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+var _ wotop.ServiceRegisterer = (*Scheduler)(nil)
+
+// NewScheduler creates the scheduler of the application.
+//
+// Parameters:
+//   - appData: The data of the application, its name prefixes the keys of the locks.
+//   - log: The logger of the runs.
+//   - cfg: The configuration of the scheduler.
+//
+// Returns:
+//   - A new Scheduler.
+func NewScheduler(appData wotop.ApplicationData, log logger.Logger, cfg Config) *Scheduler {
+
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultJobTimeout
+	}
+	if cfg.ClockSkew == 0 {
+		cfg.ClockSkew = DefaultClockSkew
+	}
+	if cfg.ShutdownTimeout == 0 {
+		cfg.ShutdownTimeout = DefaultShutdownTimeout
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = systemClock{}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Scheduler{
+		UsecaseRegisterer: wotop.NewBaseService(),
+		log:               log,
+		cfg:               cfg,
+		appData:           appData,
+		clock:             cfg.Clock,
+		names:             map[string]struct{}{},
+		ctx:               ctx,
+		cancel:            cancel,
+		stopped:           make(chan struct{}),
+	}
+}
+
+// RegisterJob registers a job, it must be called before Start.
+//
+// Parameters:
+//   - name: The unique name of the job, used in the logs and the key of its lock.
+//   - cronSpec: The schedule of the job, see ParseCron.
+//   - inport: The inport executed by the job.
+//   - opts: The options of the job, e.g. WithTimeout.
+//
+// Returns:
+//   - An error if the name is taken or the cron expression is invalid.
+func (s *Scheduler) RegisterJob(name, cronSpec string, inport wotop.Inport[JobRequest, JobResponse], opts ...JobOption) error {
+	if name == "" {
+		return errors.New("scheduler: the job has no name")
+	}
+	if _, ok := s.names[name]; ok {
+		return fmt.Errorf("scheduler: job %q is registered already", name)
+	}
+
+	schedule, err := ParseCron(cronSpec)
+	if err != nil {
+		return fmt.Errorf("scheduler: job %q: %w", name, err)
+	}
+
+	j := &job{
+		name:     name,
+		schedule: schedule,
+		inport:   inport,
+		timeout:  s.cfg.Timeout,
+		lock:     s.cfg.Locker != nil,
+	}
+	for _, opt := range opts {
+		opt(j)
+	}
+
+	s.names[name] = struct{}{}
+	s.jobs = append(s.jobs, j)
+	return nil
+}
+
+// Start schedules the registered jobs. It blocks until SIGINT or SIGTERM is received, then
+// stops the scheduler gracefully, or until Stop is called.
+func (s *Scheduler) Start() {
+
+	ctx := context.Background()
+
+	for _, j := range s.jobs {
+		s.loops.Add(1)
+		go func() {
+			defer s.loops.Done()
+			s.loop(j)
+		}()
+	}
+
+	s.log.Info(ctx, "scheduler started with %d jobs", len(s.jobs))
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(quit)
+
+	select {
+	case <-quit:
+	case <-s.stopped:
+		s.loops.Wait()
+		return
+	}
+
+	s.log.Info(ctx, "Shutting down scheduler...")
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, s.cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := s.Stop(shutdownCtx); err != nil {
+		s.log.Error(ctx, "scheduler forced to shutdown: %v", err)
+	}
+	s.loops.Wait()
+
+	s.log.Info(ctx, "Scheduler stopped.")
+}
+
+// Stop stops scheduling the jobs and waits for the running ones, it is called by
+// wotop.Lifecycle. The contexts of the runs still going when the context is done are canceled.
+//
+// Parameters:
+//   - ctx: The context bounding the shutdown.
+//
+// Returns:
+//   - The error of the context when the running jobs did not finish in time.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	s.stopping = true
+	s.mu.Unlock()
+	s.stopOnce.Do(func() { close(s.stopped) })
+
+	done := make(chan struct{})
+	go func() {
+		s.runs.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		return ctx.Err()
+	}
+}
+
+// loop triggers the runs of the job at its scheduled times until the scheduler stops.
+func (s *Scheduler) loop(j *job) {
+	for {
+		now := s.clock.Now()
+		next := j.schedule.Next(now)
+		if next.IsZero() {
+			s.log.Warning(context.Background(), "job %s is never scheduled again", j.name)
+			return
+		}
+
+		select {
+		case <-s.clock.After(next.Sub(now)):
+		case <-s.stopped:
+			return
+		}
+
+		s.trigger(j, next)
+	}
+}
+
+// trigger starts a run of the job unless the previous one is still running.
+func (s *Scheduler) trigger(j *job, scheduledAt time.Time) {
+	if !j.running.CompareAndSwap(false, true) {
+		logger.LogFields(s.log, context.Background(), logger.LevelWarning, "job skipped, the previous run is still running", logger.Fields{
+			"job":          j.name,
+			"scheduled_at": util.FormatRFC3339UTC(scheduledAt),
+		})
+		return
+	}
+
+	s.mu.Lock()
+	if s.stopping {
+		s.mu.Unlock()
+		j.running.Store(false)
+		return
+	}
+	s.runs.Add(1)
+	s.mu.Unlock()
+
+	go func() {
+		defer s.runs.Done()
+		defer j.running.Store(false)
+		s.run(j, scheduledAt)
+	}()
+}
+
+// run runs the job once, holding its lock when it has one.
+func (s *Scheduler) run(j *job, scheduledAt time.Time) {
+
+	traceID := util.GenerateID(16)
+	ctx := logger.SetTraceID(s.ctx, traceID)
+
+	fields := logger.Fields{
+		"job":          j.name,
+		"scheduled_at": util.FormatRFC3339UTC(scheduledAt),
+		"trace_id":     traceID,
+	}
+
+	if j.lock {
+		l, err := s.cfg.Locker.Acquire(ctx, s.lockKey(j), j.timeout+s.cfg.ClockSkew)
+		if errors.Is(err, lock.ErrLockHeld) {
+			logger.LogFields(s.log, ctx, logger.LevelInfo, "job run by another replica", fields)
+			return
+		}
+		if err != nil {
+			fields["error"] = err.Error()
+			logger.LogFields(s.log, ctx, logger.LevelError, "job not run, its lock cannot be acquired", fields)
+			return
+		}
+
+		// the run is cancelled when the lock is lost, another replica may run the job then
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			select {
+			case <-l.Lost():
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		defer func() {
+			// the run is still counted, so Stop waits for the release too
+			s.runs.Add(1)
+			go func() {
+				defer s.runs.Done()
+				s.release(j, scheduledAt, l)
+			}()
+		}()
+	}
+
+	logger.LogFields(s.log, ctx, logger.LevelInfo, "job started", fields)
+
+	start := s.clock.Now()
+	res, err := s.execute(ctx, j, scheduledAt)
+	fields["duration_ms"] = s.clock.Now().Sub(start).Milliseconds()
+
+	if err != nil {
+		fields["error"] = err.Error()
+		logger.LogFields(s.log, ctx, logger.LevelError, "job failed", fields)
+		return
+	}
+
+	if res != nil && res.Message != "" {
+		fields["result"] = res.Message
+	}
+	logger.LogFields(s.log, ctx, logger.LevelInfo, "job finished", fields)
+}
+
+// execute executes the inport of the job within its timeout, recovering its panic.
+func (s *Scheduler) execute(ctx context.Context, j *job, scheduledAt time.Time) (res *JobResponse, err error) {
+	ctx, cancel := context.WithTimeout(ctx, j.timeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return j.inport.Execute(ctx, JobRequest{Name: j.name, ScheduledAt: scheduledAt})
+}
+
+// release releases the lock of the job, not before ClockSkew after the scheduled time.
+func (s *Scheduler) release(j *job, scheduledAt time.Time, l lock.Lock) {
+	if wait := scheduledAt.Add(s.cfg.ClockSkew).Sub(s.clock.Now()); wait > 0 {
+		select {
+		case <-s.clock.After(wait):
+		case <-s.stopped:
+		}
+	}
+
+	// a lock which expired meanwhile is not an error, the run completed
+	if err := l.Release(context.Background()); err != nil && !errors.Is(err, lock.ErrNotOwner) {
+		s.log.Error(context.Background(), "release the lock of job %s: %v", j.name, err)
+	}
+}
+
+// lockKey returns the key of the lock of the job.
+func (s *Scheduler) lockKey(j *job) string {
+	return fmt.Sprintf("wotop:scheduler:%s:%s", s.appData.AppName, j.name)
+}
@@ -0,0 +1,339 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/lock"
+	"github.com/a-aslani/wotop/logger"
+	"github.com/a-aslani/wotop/logger/loggertest"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var t0 = time.Date(2026, 10, 14, 10, 2, 0, 0, time.UTC)
+
+// fakeClock is a Clock whose time only moves with Advance.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the time and wakes the waiters whose time has come.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	waiting := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			waiting = append(waiting, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = waiting
+}
+
+// waitForWaiters waits until n goroutines wait on the clock.
+func (c *fakeClock) waitForWaiters(t *testing.T, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return len(c.waiters) >= n
+	}, time.Second, time.Millisecond)
+}
+
+// jobMessages returns the messages logged for the job.
+func jobMessages(log *loggertest.Recorder, job string) []string {
+	var messages []string
+	for _, e := range log.Entries() {
+		if e.Fields["job"] == job {
+			messages = append(messages, e.Message)
+		}
+	}
+	return messages
+}
+
+// findEntry returns the first entry with the message.
+func findEntry(log *loggertest.Recorder, message string) (loggertest.Entry, bool) {
+	for _, e := range log.Entries() {
+		if e.Message == message {
+			return e, true
+		}
+	}
+	return loggertest.Entry{}, false
+}
+
+// jobFunc adapts a function to the inport of a job.
+type jobFunc func(ctx context.Context, req JobRequest) (*JobResponse, error)
+
+func (f jobFunc) Execute(ctx context.Context, req JobRequest) (*JobResponse, error) {
+	return f(ctx, req)
+}
+
+// startScheduler starts the scheduler and stops it at the end of the test.
+func startScheduler(t *testing.T, s *Scheduler) {
+	t.Helper()
+	returned := make(chan struct{})
+	go func() {
+		s.Start()
+		close(returned)
+	}()
+	t.Cleanup(func() {
+		require.NoError(t, s.Stop(context.Background()))
+		<-returned
+	})
+}
+
+func TestSchedulerRunsJobOnSchedule(t *testing.T) {
+	clock := newFakeClock(t0)
+	log := &loggertest.Recorder{}
+	s := NewScheduler(wotop.ApplicationData{AppName: "shop"}, log, Config{Clock: clock})
+
+	runs := make(chan JobRequest, 10)
+	traceIDs := make(chan string, 10)
+	require.NoError(t, s.RegisterJob("token-janitor", "*/5 * * * *", jobFunc(func(ctx context.Context, req JobRequest) (*JobResponse, error) {
+		traceID, _ := wotop.TraceIDFromContext(ctx)
+		traceIDs <- traceID
+		runs <- req
+		return &JobResponse{Message: "3 tokens deleted"}, nil
+	})))
+
+	startScheduler(t, s)
+
+	clock.waitForWaiters(t, 1)
+	clock.Advance(2 * time.Minute)
+	assert.Empty(t, runs, "10:04 is not scheduled")
+
+	clock.Advance(time.Minute)
+	req := <-runs
+	assert.Equal(t, JobRequest{Name: "token-janitor", ScheduledAt: t0.Add(3 * time.Minute)}, req)
+	assert.Len(t, <-traceIDs, 16)
+
+	clock.waitForWaiters(t, 1)
+	clock.Advance(5 * time.Minute)
+	assert.Equal(t, t0.Add(8*time.Minute), (<-runs).ScheduledAt)
+
+	require.Eventually(t, func() bool {
+		return len(jobMessages(log, "token-janitor")) == 4
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"job started", "job finished", "job started", "job finished"}, jobMessages(log, "token-janitor"))
+
+	finished, _ := findEntry(log, "job finished")
+	assert.Equal(t, "3 tokens deleted", finished.Fields["result"])
+	assert.Equal(t, "2026-10-14T10:05:00Z", finished.Fields["scheduled_at"])
+}
+
+func TestSchedulerSkipsOverlappingRuns(t *testing.T) {
+	clock := newFakeClock(t0)
+	log := &loggertest.Recorder{}
+	s := NewScheduler(wotop.ApplicationData{AppName: "shop"}, log, Config{Clock: clock})
+
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	require.NoError(t, s.RegisterJob("report", "* * * * *", jobFunc(func(ctx context.Context, req JobRequest) (*JobResponse, error) {
+		started <- struct{}{}
+		<-release
+		return nil, nil
+	})))
+
+	startScheduler(t, s)
+
+	clock.waitForWaiters(t, 1)
+	clock.Advance(time.Minute)
+	<-started
+
+	// the run of 10:04 comes while the run of 10:03 is still going
+	clock.waitForWaiters(t, 1)
+	clock.Advance(time.Minute)
+	require.Eventually(t, func() bool {
+		_, ok := findEntry(log, "job skipped, the previous run is still running")
+		return ok
+	}, time.Second, time.Millisecond)
+
+	close(release)
+	assert.Len(t, started, 0)
+}
+
+func TestSchedulerRecoversPanicsAndTimeouts(t *testing.T) {
+	clock := newFakeClock(t0)
+	log := &loggertest.Recorder{}
+	s := NewScheduler(wotop.ApplicationData{AppName: "shop"}, log, Config{Clock: clock})
+
+	require.NoError(t, s.RegisterJob("outbox-relay", "* * * * *", jobFunc(func(context.Context, JobRequest) (*JobResponse, error) {
+		panic("nil outbox")
+	})))
+	require.NoError(t, s.RegisterJob("slow-report", "* * * * *", jobFunc(func(ctx context.Context, _ JobRequest) (*JobResponse, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}), WithTimeout(10*time.Millisecond)))
+
+	startScheduler(t, s)
+
+	clock.waitForWaiters(t, 2)
+	clock.Advance(time.Minute)
+
+	require.Eventually(t, func() bool {
+		return len(jobMessages(log, "outbox-relay")) == 2 && len(jobMessages(log, "slow-report")) == 2
+	}, time.Second, time.Millisecond)
+
+	var failures []string
+	for _, e := range log.Entries() {
+		if e.Message == "job failed" {
+			assert.Equal(t, logger.LevelError, e.Level)
+			failures = append(failures, e.Fields["job"].(string)+": "+e.Fields["error"].(string))
+		}
+	}
+	assert.ElementsMatch(t, []string{"outbox-relay: panic: nil outbox", "slow-report: context deadline exceeded"}, failures)
+
+	// the scheduler goes on after the panic
+	clock.waitForWaiters(t, 2)
+	clock.Advance(time.Minute)
+	require.Eventually(t, func() bool {
+		return len(jobMessages(log, "outbox-relay")) == 4
+	}, time.Second, time.Millisecond)
+}
+
+func TestSchedulerLockContention(t *testing.T) {
+	clock := newFakeClock(t0)
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	locker := lock.NewRedisLocker(rdb)
+
+	var mu sync.Mutex
+	var runs []string
+
+	// two replicas of the same application
+	logs := []*loggertest.Recorder{{}, {}}
+	for i, log := range logs {
+		s := NewScheduler(wotop.ApplicationData{AppName: "shop"}, log, Config{Clock: clock, Locker: locker})
+		require.NoError(t, s.RegisterJob("report", "0 * * * *", jobFunc(func(context.Context, JobRequest) (*JobResponse, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			runs = append(runs, []string{"replica-1", "replica-2"}[i])
+			return nil, nil
+		})))
+		startScheduler(t, s)
+	}
+
+	clock.waitForWaiters(t, 2)
+	clock.Advance(58 * time.Minute)
+
+	require.Eventually(t, func() bool {
+		_, ok0 := findEntry(logs[0], "job run by another replica")
+		_, ok1 := findEntry(logs[1], "job run by another replica")
+		return ok0 || ok1
+	}, time.Second, time.Millisecond)
+
+	mu.Lock()
+	assert.Len(t, runs, 1, "only one replica runs the job")
+	mu.Unlock()
+
+	// the lock is released ClockSkew after the scheduled time
+	assert.True(t, rdbExists(t, rdb, lock.RedisKey("wotop:scheduler:shop:report")))
+	clock.Advance(DefaultClockSkew)
+	require.Eventually(t, func() bool {
+		return !rdbExists(t, rdb, lock.RedisKey("wotop:scheduler:shop:report"))
+	}, time.Second, time.Millisecond)
+}
+
+func rdbExists(t *testing.T, rdb *redis.Client, key string) bool {
+	n, err := rdb.Exists(context.Background(), key).Result()
+	require.NoError(t, err)
+	return n == 1
+}
+
+func TestSchedulerWithoutLock(t *testing.T) {
+	clock := newFakeClock(t0)
+	s := NewScheduler(wotop.ApplicationData{AppName: "shop"}, &loggertest.Recorder{}, Config{Clock: clock, Locker: failingLocker{}})
+
+	runs := make(chan struct{}, 1)
+	require.NoError(t, s.RegisterJob("cache-cleaner", "* * * * *", jobFunc(func(context.Context, JobRequest) (*JobResponse, error) {
+		runs <- struct{}{}
+		return nil, nil
+	}), WithoutLock()))
+
+	startScheduler(t, s)
+	clock.waitForWaiters(t, 1)
+	clock.Advance(time.Minute)
+	<-runs
+}
+
+// failingLocker is a lock.Locker failing every lock.
+type failingLocker struct{}
+
+func (failingLocker) Acquire(context.Context, string, time.Duration) (lock.Lock, error) {
+	panic("the lock must not be taken")
+}
+
+func TestSchedulerRegisterJob(t *testing.T) {
+	s := NewScheduler(wotop.ApplicationData{AppName: "shop"}, &loggertest.Recorder{}, Config{})
+	noop := jobFunc(func(context.Context, JobRequest) (*JobResponse, error) { return nil, nil })
+
+	require.NoError(t, s.RegisterJob("report", "@hourly", noop))
+	assert.ErrorContains(t, s.RegisterJob("report", "@daily", noop), "registered already")
+	assert.ErrorContains(t, s.RegisterJob("other", "61 * * * *", noop), "invalid minute")
+	assert.Error(t, s.RegisterJob("", "@daily", noop))
+}
+
+func TestSchedulerStopTimeout(t *testing.T) {
+	clock := newFakeClock(t0)
+	s := NewScheduler(wotop.ApplicationData{AppName: "shop"}, &loggertest.Recorder{}, Config{Clock: clock})
+
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	require.NoError(t, s.RegisterJob("report", "* * * * *", jobFunc(func(ctx context.Context, _ JobRequest) (*JobResponse, error) {
+		close(started)
+		<-ctx.Done()
+		close(canceled)
+		return nil, ctx.Err()
+	})))
+
+	go s.Start()
+	clock.waitForWaiters(t, 1)
+	clock.Advance(time.Minute)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, s.Stop(ctx), context.DeadlineExceeded)
+
+	// the run is canceled when the shutdown times out
+	<-canceled
+}
@@ -0,0 +1,19 @@
+package tenancy
+
+import (
+	"net/http"
+
+	"github.com/a-aslani/wotop/model/apperror"
+)
+
+const (
+	// ErrTenantRequired indicates a request or a transaction without a resolvable tenant.
+	ErrTenantRequired apperror.ErrorType = "ER0991 the tenant of the request is missing"
+)
+
+func init() {
+	apperror.Register("tenancy",
+		apperror.Entry{Err: ErrTenantRequired, Description: "No tenant could be resolved from the token, the headers or the host of the request, the route needs one."},
+	)
+	apperror.MapCode(ErrTenantRequired.Code(), http.StatusBadRequest)
+}
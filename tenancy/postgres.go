@@ -0,0 +1,47 @@
+package tenancy
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/a-aslani/wotop/postgres_db"
+)
+
+// Setting is the Postgres setting WithTx sets to the tenant, which the row level security
+// policies compare the tenant column of the rows with, e.g.
+//
+//	CREATE POLICY tenant_isolation ON orders
+//	    USING (tenant_id = current_setting('app.tenant_id'));
+const Setting = "app.tenant_id"
+
+// WithTx runs fn in a transaction, see postgres_db.WithTx, scoped to the tenant of the
+// context: the Setting is set for the transaction only, as SET LOCAL does, so the pooled
+// connection does not keep it. When ctx already carries a transaction, fn joins it and the
+// setting is set on it.
+//
+// Parameters:
+//   - ctx: The context of the transaction, carrying the tenant, see FromContext.
+//   - db: The connection pool the transaction is opened on.
+//   - fn: The function to run in the transaction, its repositories use postgres_db.Conn.
+//
+// Returns:
+//   - ErrTenantRequired if the context carries no tenant, fn is not run then.
+//   - The error of fn, or an error if the transaction cannot be opened, scoped or committed.
+func WithTx(ctx context.Context, db *sql.DB, fn func(ctx context.Context) error) error {
+
+	tenant, ok := FromContext(ctx)
+	if !ok {
+		return ErrTenantRequired
+	}
+
+	return postgres_db.WithTx(ctx, db, func(ctx context.Context) error {
+
+		// SET LOCAL takes no parameters, set_config with is_local does the same with a bound value
+		if _, err := postgres_db.Conn(ctx, db).ExecContext(ctx, "SELECT set_config($1, $2, true)", Setting, tenant); err != nil {
+			return fmt.Errorf("cannot set the tenant of the transaction: %w", err)
+		}
+
+		return fn(ctx)
+	})
+}
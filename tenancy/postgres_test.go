@@ -0,0 +1,67 @@
+package tenancy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/a-aslani/wotop/postgres_db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithTx(t *testing.T) {
+
+	t.Run("sets the tenant within the transaction", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`SELECT set_config\(\$1, \$2, true\)`).WithArgs(Setting, "acme").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO orders").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err = WithTx(WithTenant(context.Background(), "acme"), db, func(ctx context.Context) error {
+			_, err := postgres_db.Conn(ctx, db).ExecContext(ctx, "INSERT INTO orders (id) VALUES ($1)", "o-1")
+			return err
+		})
+		require.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("joins the outer transaction", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`SELECT set_config`).WithArgs(Setting, "acme").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		ctx := WithTenant(context.Background(), "acme")
+		err = postgres_db.WithTx(ctx, db, func(ctx context.Context) error {
+			outer, _ := postgres_db.TxFromContext(ctx)
+			return WithTx(ctx, db, func(ctx context.Context) error {
+				inner, _ := postgres_db.TxFromContext(ctx)
+				assert.Same(t, outer, inner)
+				return nil
+			})
+		})
+		require.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("requires a tenant", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		err = WithTx(context.Background(), db, func(ctx context.Context) error {
+			t.Fatal("fn runs without tenant")
+			return nil
+		})
+		assert.ErrorIs(t, err, ErrTenantRequired)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,159 @@
+package tenancy
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/logger"
+	"github.com/a-aslani/wotop/model/payload"
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultHeader is the header FromHeader reads when it is given no name.
+const DefaultHeader = "X-Tenant-ID"
+
+type tenantKey struct{}
+
+// WithTenant returns a copy of the context carrying the tenant.
+//
+// Parameters:
+//   - ctx: The parent context.
+//   - tenant: The ID of the tenant.
+//
+// Returns:
+//   - The context carrying the tenant.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// FromContext retrieves the tenant stored by WithTenant or Middleware.
+//
+// Parameters:
+//   - ctx: The context from which the tenant will be retrieved.
+//
+// Returns:
+//   - The ID of the tenant, empty when there is none.
+//   - A boolean indicating whether the context carries a tenant.
+func FromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok && tenant != ""
+}
+
+// Strategy resolves the tenant of a request.
+//
+// Parameters:
+//   - c: The Gin context of the request.
+//
+// Returns:
+//   - The ID of the tenant.
+//   - False if the strategy finds no tenant in the request, the next strategy is tried then.
+type Strategy func(c *gin.Context) (string, bool)
+
+// FromClaim resolves the tenant from the Tenant claim of the access token, carried by the
+// identity of the caller, see wotop.IdentityFromContext. The authentication middleware, e.g.
+// jwt.GinMiddleware.Authentication, must run before Middleware.
+//
+// Returns:
+//   - The claim strategy.
+func FromClaim() Strategy {
+	return func(c *gin.Context) (string, bool) {
+		identity, ok := wotop.IdentityFromContext(c.Request.Context())
+		return identity.Tenant, ok && identity.Tenant != ""
+	}
+}
+
+// FromHeader resolves the tenant from a request header. The header is set by the caller, so
+// it should come after FromClaim when the routes are authenticated, to keep a caller from
+// reaching the data of another tenant.
+//
+// Parameters:
+//   - name: The name of the header, DefaultHeader when empty.
+//
+// Returns:
+//   - The header strategy.
+func FromHeader(name string) Strategy {
+	if name == "" {
+		name = DefaultHeader
+	}
+	return func(c *gin.Context) (string, bool) {
+		tenant := strings.TrimSpace(c.GetHeader(name))
+		return tenant, tenant != ""
+	}
+}
+
+// FromSubdomain resolves the tenant from the subdomain of the host of the request, e.g.
+// "acme" for "acme.shop.example.com" with the domain "shop.example.com". The domain itself
+// and the hosts with more than one label before it resolve no tenant.
+//
+// Parameters:
+//   - domain: The domain the tenants are subdomains of.
+//
+// Returns:
+//   - The subdomain strategy.
+func FromSubdomain(domain string) Strategy {
+	suffix := "." + strings.ToLower(strings.Trim(domain, "."))
+	return func(c *gin.Context) (string, bool) {
+		host := c.Request.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+
+		sub, ok := strings.CutSuffix(strings.ToLower(host), suffix)
+		if !ok || sub == "" || strings.Contains(sub, ".") {
+			return "", false
+		}
+		return sub, true
+	}
+}
+
+// Middleware resolves the tenant of each request with the first strategy finding one, and
+// sets it in the request context, see FromContext, and in the Gin context as "Tenant". The
+// requests without tenant go through, Require rejects them on the routes needing one.
+//
+// Parameters:
+//   - strategies: The strategies in priority order, FromClaim then FromHeader(DefaultHeader)
+//     when none is given.
+//
+// Returns:
+//   - A gin.HandlerFunc to register with gin.Engine.Use.
+func Middleware(strategies ...Strategy) gin.HandlerFunc {
+	if len(strategies) == 0 {
+		strategies = []Strategy{FromClaim(), FromHeader(DefaultHeader)}
+	}
+
+	return func(c *gin.Context) {
+
+		for _, resolve := range strategies {
+			if tenant, ok := resolve(c); ok {
+				c.Request = c.Request.WithContext(WithTenant(c.Request.Context(), tenant))
+				c.Set("Tenant", tenant)
+				break
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// Require is a middleware aborting the requests whose tenant Middleware could not resolve
+// with ErrTenantRequired, a 400. It follows Middleware on the routes scoped to a tenant.
+//
+// Returns:
+//   - A gin.HandlerFunc to register on the routes or groups.
+func Require() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		if _, ok := FromContext(c.Request.Context()); !ok {
+			payload.WriteError(c, ErrTenantRequired, logger.GetTraceID(c.Request.Context()))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,113 @@
+package tenancy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/a-aslani/wotop"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRouter returns a router whose GET /tenant answers the tenant of the request, and whose
+// GET /orders requires one. The X-Claim-Tenant header stands for the Tenant claim of the
+// authentication middleware.
+func newRouter(strategies ...Strategy) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if tenant := c.GetHeader("X-Claim-Tenant"); tenant != "" {
+			c.Request = c.Request.WithContext(wotop.WithIdentity(c.Request.Context(), wotop.Identity{ID: "user-1", Tenant: tenant}))
+		}
+	}, Middleware(strategies...))
+
+	r.GET("/tenant", func(c *gin.Context) {
+		tenant, _ := FromContext(c.Request.Context())
+		c.String(http.StatusOK, tenant+"|"+c.GetString("Tenant"))
+	})
+	r.GET("/orders", Require(), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	return r
+}
+
+func get(r *gin.Engine, path, host string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if host != "" {
+		req.Host = host
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestStrategies(t *testing.T) {
+
+	t.Run("claim", func(t *testing.T) {
+		r := newRouter(FromClaim())
+		assert.Equal(t, "acme|acme", get(r, "/tenant", "", map[string]string{"X-Claim-Tenant": "acme"}).Body.String())
+		assert.Equal(t, "|", get(r, "/tenant", "", nil).Body.String())
+	})
+
+	t.Run("header", func(t *testing.T) {
+		r := newRouter(FromHeader(""))
+		assert.Equal(t, "acme|acme", get(r, "/tenant", "", map[string]string{DefaultHeader: " acme "}).Body.String())
+
+		r = newRouter(FromHeader("X-Org"))
+		assert.Equal(t, "globex|globex", get(r, "/tenant", "", map[string]string{"X-Org": "globex"}).Body.String())
+		assert.Equal(t, "|", get(r, "/tenant", "", map[string]string{DefaultHeader: "acme"}).Body.String())
+	})
+
+	t.Run("subdomain", func(t *testing.T) {
+		r := newRouter(FromSubdomain("shop.example.com"))
+
+		for host, want := range map[string]string{
+			"acme.shop.example.com":      "acme",
+			"Acme.Shop.Example.com:8080": "acme",
+			"shop.example.com":           "",
+			"a.b.shop.example.com":       "",
+			"acme.other.example.com":     "",
+			"acmeshop.example.com":       "",
+		} {
+			assert.Equal(t, want+"|"+want, get(r, "/tenant", host, nil).Body.String(), host)
+		}
+	})
+}
+
+func TestMiddlewarePrecedence(t *testing.T) {
+	r := newRouter(FromClaim(), FromHeader(""), FromSubdomain("example.com"))
+	claim, header := map[string]string{"X-Claim-Tenant": "acme"}, map[string]string{DefaultHeader: "globex"}
+
+	assert.Equal(t, "acme|acme", get(r, "/tenant", "initech.example.com", map[string]string{"X-Claim-Tenant": "acme", DefaultHeader: "globex"}).Body.String(),
+		"the claim comes first, a header cannot switch the tenant of the token")
+	assert.Equal(t, "acme|acme", get(r, "/tenant", "initech.example.com", claim).Body.String())
+	assert.Equal(t, "globex|globex", get(r, "/tenant", "initech.example.com", header).Body.String())
+	assert.Equal(t, "initech|initech", get(r, "/tenant", "initech.example.com", nil).Body.String())
+
+	// the default strategies are the claim then the header
+	r = newRouter()
+	assert.Equal(t, "acme|acme", get(r, "/tenant", "initech.example.com", map[string]string{"X-Claim-Tenant": "acme", DefaultHeader: "globex"}).Body.String())
+	assert.Equal(t, "globex|globex", get(r, "/tenant", "initech.example.com", header).Body.String())
+	assert.Equal(t, "|", get(r, "/tenant", "initech.example.com", nil).Body.String())
+}
+
+func TestRequire(t *testing.T) {
+	r := newRouter()
+
+	assert.Equal(t, http.StatusNoContent, get(r, "/orders", "", map[string]string{DefaultHeader: "acme"}).Code)
+
+	w := get(r, "/orders", "", nil)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	var res struct {
+		ErrorCode string `json:"error_code"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, string(ErrTenantRequired.Code()), res.ErrorCode)
+}
@@ -2,6 +2,7 @@ package wotop
 
 import (
 	"context"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -13,19 +14,43 @@ import (
 //   - REQUEST: The type of the request object.
 //   - RESPONSE: The type of the response object.
 //   - OUTPORT: The type of the outport dependency.
+//
+// The outport is either given by Outport, or built inside the subtest by BuildOutport, which
+// is needed by gomock mocks as they depend on the *gomock.Controller of the subtest. Arrange
+// then sets the expectations on it.
+//
+// The error is checked with the first of ExpectedErrorIs, ExpectedErrorContains and
+// ExpectedError that is set, and must be nil when none is. The response is checked with
+// AssertResponse when it is set, then with Golden, and compared to InportResponse otherwise;
+// it is not checked when the scenario expects an error and sets none of them.
+//
+// Golden compares the response, marshaled to indented JSON with sorted keys, to the file
+// testdata/<Golden> of the test package. Running the tests with -update rewrites the files,
+// the flag is registered by this package in test binaries. GoldenIgnore lists the fields
+// whose values change on every run, such as IDs and times, as dot separated JSON paths,
+// e.g. "id" or "items.created_at".
 type TestScenario[REQUEST, RESPONSE, OUTPORT any] struct {
-	Name           string    // The name of the test case.
-	InportRequest  REQUEST   // The input request to be passed to the Inport.
-	InportResponse *RESPONSE // The expected response from the Inport.
-	Outport        OUTPORT   // The outport dependency to be used in the test case.
-	ExpectedError  error     // The expected error, if any, from the Inport execution.
+	Name                  string                              // The name of the test case.
+	InportRequest         REQUEST                             // The input request to be passed to the Inport.
+	InportResponse        *RESPONSE                           // The expected response from the Inport.
+	Outport               OUTPORT                             // The outport dependency to be used in the test case.
+	ExpectedError         error                               // The expected error, if any, from the Inport execution, compared with assert.Equal.
+	ExpectedErrorIs       error                               // The error expected in the chain of the returned error, checked with errors.Is.
+	ExpectedErrorContains string                              // A substring of the message of the expected error.
+	AssertResponse        func(t *testing.T, res *RESPONSE)   // Asserts the response in place of InportResponse, e.g. when it holds generated IDs.
+	Golden                string                              // The name of the golden file the response is compared to, in the testdata directory.
+	GoldenIgnore          []string                            // The JSON paths of the fields left out of the golden comparison.
+	BuildOutport          func(t *testing.T) OUTPORT          // Builds the outport inside the subtest, it replaces Outport when set.
+	Arrange               func(t *testing.T, outport OUTPORT) // Prepares the outport, e.g. sets mock expectations, before the execution.
+	Ctx                   func() context.Context              // Builds the context of the execution, e.g. with a trace ID or a deadline. context.Background() by default.
 }
 
 // RunTestcaseScenarios runs a list of test scenarios for an Inport.
 //
 // This function executes each test scenario in parallel, invoking the provided
-// Inport function with the given outport and request. It then asserts the
-// response and error against the expected values.
+// Inport function with the outport and request of the scenario. The outport is built
+// and arranged, and the context created, inside the subtest of the scenario. It then
+// asserts the response and error against the expected values.
 //
 // Type Parameters:
 //   - REQUEST: The type of the request object.
@@ -44,20 +69,73 @@ func RunTestcaseScenarios[REQUEST, RESPONSE, OUTPORT any](t *testing.T, f func(o
 
 		t.Run(tt.Name, func(t *testing.T) {
 
+			outport := tt.Outport
+			if tt.BuildOutport != nil {
+				outport = tt.BuildOutport(t)
+			}
+
+			if tt.Arrange != nil {
+				tt.Arrange(t, outport)
+			}
+
+			ctx := context.Background()
+			if tt.Ctx != nil {
+				ctx = tt.Ctx()
+			}
+
 			// Execute the Inport with the provided request and outport.
-			res, err := f(tt.Outport).Execute(context.Background(), tt.InportRequest)
+			res, err := f(outport).Execute(ctx, tt.InportRequest)
+
+			// Assert the error, then the response, which may be partial when an error is returned.
+			tt.assertError(t, err)
+
+			if tt.AssertResponse != nil {
+				tt.AssertResponse(t, res)
+				return
+			}
 
-			// Assert the error if one is expected.
-			if err != nil {
-				assert.Equal(t, tt.ExpectedError, err, "Testcase name %s", tt.Name)
+			if tt.Golden != "" {
+				compareGolden(t, filepath.Join("testdata", tt.Golden), res, tt.GoldenIgnore, updatingGolden())
 				return
 			}
 
-			// Assert the response if no error occurred.
-			assert.Equal(t, tt.InportResponse, res, "Testcase name %s", tt.Name)
+			tt.assertResponse(t, res)
 
 		})
 
 	}
 
 }
+
+// expectsError reports whether the scenario expects the Inport to fail.
+func (tt TestScenario[REQUEST, RESPONSE, OUTPORT]) expectsError() bool {
+	return tt.ExpectedErrorIs != nil || tt.ExpectedErrorContains != "" || tt.ExpectedError != nil
+}
+
+// assertError checks the error returned by the Inport against the expected one.
+//
+// Parameters:
+//   - t: The test reporting the failures.
+//   - err: The error returned by the Inport.
+func (tt TestScenario[REQUEST, RESPONSE, OUTPORT]) assertError(t assert.TestingT, err error) {
+	switch {
+	case tt.ExpectedErrorIs != nil:
+		assert.ErrorIs(t, err, tt.ExpectedErrorIs, "scenario %q", tt.Name)
+	case tt.ExpectedErrorContains != "":
+		assert.ErrorContains(t, err, tt.ExpectedErrorContains, "scenario %q", tt.Name)
+	default:
+		assert.Equal(t, tt.ExpectedError, err, "scenario %q", tt.Name)
+	}
+}
+
+// assertResponse compares the response returned by the Inport to InportResponse.
+//
+// Parameters:
+//   - t: The test reporting the failures.
+//   - res: The response returned by the Inport.
+func (tt TestScenario[REQUEST, RESPONSE, OUTPORT]) assertResponse(t assert.TestingT, res *RESPONSE) {
+	if tt.InportResponse == nil && tt.expectsError() {
+		return
+	}
+	assert.Equal(t, tt.InportResponse, res, "scenario %q", tt.Name)
+}
@@ -0,0 +1,146 @@
+package wotop
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// goldenIgnored replaces the values of the fields ignored by a golden comparison.
+const goldenIgnored = "<ignored>"
+
+func init() {
+	// the flag is only registered in test binaries, where the scenarios run
+	if testing.Testing() && flag.Lookup("update") == nil {
+		flag.Bool("update", false, "regenerate the golden files of the test scenarios")
+	}
+}
+
+// updatingGolden reports whether the test binary was run with -update.
+func updatingGolden() bool {
+	f := flag.Lookup("update")
+	return f != nil && f.Value.String() == "true"
+}
+
+// goldenT is the part of *testing.T used by compareGolden.
+type goldenT interface {
+	Helper()
+	Errorf(format string, args ...any)
+	Logf(format string, args ...any)
+}
+
+// compareGolden compares a response to the golden file at path, or writes the file when
+// update is set.
+//
+// Parameters:
+//   - t: The test reporting the failures.
+//   - path: The path of the golden file.
+//   - res: The response, marshaled to canonical JSON.
+//   - ignore: The dot separated paths of the fields whose values are not compared.
+//   - update: Whether the golden file is regenerated instead of compared.
+func compareGolden(t goldenT, path string, res any, ignore []string, update bool) {
+	t.Helper()
+
+	got, err := canonicalJSON(res, ignore)
+	if err != nil {
+		t.Errorf("golden file %s: cannot marshal the response: %v", path, err)
+		return
+	}
+
+	if update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Errorf("golden file %s: %v", path, err)
+			return
+		}
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Errorf("golden file %s: %v", path, err)
+			return
+		}
+		t.Logf("golden file %s updated", path)
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("golden file %s does not exist, run the test with -update to create it", path)
+		return
+	}
+	if err != nil {
+		t.Errorf("golden file %s: %v", path, err)
+		return
+	}
+
+	if bytes.Equal(want, got) {
+		return
+	}
+
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(want)),
+		B:        difflib.SplitLines(string(got)),
+		FromFile: path,
+		ToFile:   "response",
+		Context:  3,
+	})
+	t.Errorf("response differs from golden file %s, run the test with -update to accept it:\n%s", path, diff)
+}
+
+// canonicalJSON marshals v to indented JSON with sorted keys, the values of the ignored
+// fields being replaced by goldenIgnored.
+func canonicalJSON(v any, ignore []string) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	// decoded as maps so the keys are sorted when marshaled again, numbers are kept as written
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	var tree any
+	if err := dec.Decode(&tree); err != nil {
+		return nil, err
+	}
+
+	for _, path := range ignore {
+		tree = ignoreField(tree, strings.Split(path, "."))
+	}
+
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(tree); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// ignoreField replaces the value at path in a decoded JSON tree, the arrays met on the path
+// are traversed so "items.id" ignores the id of every item.
+func ignoreField(tree any, path []string) any {
+	switch node := tree.(type) {
+	case map[string]any:
+		v, ok := node[path[0]]
+		if !ok {
+			return node
+		}
+		if len(path) == 1 {
+			node[path[0]] = goldenIgnored
+		} else {
+			node[path[0]] = ignoreField(v, path[1:])
+		}
+	case []any:
+		for i, v := range node {
+			node[i] = ignoreField(v, path)
+		}
+	}
+	return tree
+}
@@ -0,0 +1,142 @@
+package wotop
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type reportLine struct {
+	SKU       string    `json:"sku"`
+	Quantity  int       `json:"quantity"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type report struct {
+	ID    string         `json:"id"`
+	Lines []reportLine   `json:"lines"`
+	Meta  map[string]any `json:"meta"`
+}
+
+func newReport(id string, at time.Time, quantity int) *report {
+	return &report{
+		ID: id,
+		Lines: []reportLine{
+			{SKU: "A-1", Quantity: quantity, CreatedAt: at},
+			{SKU: "B-2", Quantity: 1, CreatedAt: at},
+		},
+		Meta: map[string]any{"zone": "eu", "total": 12345678901234567, "currency": "EUR"},
+	}
+}
+
+func TestCompareGolden(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "testdata", "report.golden")
+	ignore := []string{"id", "lines.created_at"}
+
+	t.Run("missing file", func(t *testing.T) {
+		rec := &recordingT{}
+		compareGolden(rec, path, newReport("r-1", time.Now(), 2), ignore, false)
+
+		require.Len(t, rec.failures, 1)
+		assert.Contains(t, rec.failures[0], "does not exist, run the test with -update")
+	})
+
+	t.Run("creation", func(t *testing.T) {
+		rec := &recordingT{}
+		compareGolden(rec, path, newReport("r-1", time.Now(), 2), ignore, true)
+		require.Empty(t, rec.failures)
+
+		got, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, `{
+  "id": "<ignored>",
+  "lines": [
+    {
+      "created_at": "<ignored>",
+      "quantity": 2,
+      "sku": "A-1"
+    },
+    {
+      "created_at": "<ignored>",
+      "quantity": 1,
+      "sku": "B-2"
+    }
+  ],
+  "meta": {
+    "currency": "EUR",
+    "total": 12345678901234567,
+    "zone": "eu"
+  }
+}
+`, string(got))
+	})
+
+	t.Run("match ignores volatile fields", func(t *testing.T) {
+		rec := &recordingT{}
+		compareGolden(rec, path, newReport("r-2", time.Now().Add(time.Hour), 2), ignore, false)
+		assert.Empty(t, rec.failures)
+	})
+
+	t.Run("mismatch shows a unified diff", func(t *testing.T) {
+		rec := &recordingT{}
+		compareGolden(rec, path, newReport("r-3", time.Now(), 5), ignore, false)
+
+		require.Len(t, rec.failures, 1)
+		assert.Contains(t, rec.failures[0], "--- "+path)
+		assert.Contains(t, rec.failures[0], "+++ response")
+		assert.Contains(t, rec.failures[0], `-      "quantity": 2,`)
+		assert.Contains(t, rec.failures[0], `+      "quantity": 5,`)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		rec := &recordingT{}
+		compareGolden(rec, path, newReport("r-4", time.Now(), 5), ignore, true)
+		require.Empty(t, rec.failures)
+		assert.Len(t, rec.logs, 1)
+
+		compareGolden(rec, path, newReport("r-5", time.Now(), 5), ignore, false)
+		assert.Empty(t, rec.failures)
+	})
+}
+
+func TestUpdateGoldenFlag(t *testing.T) {
+	f := flag.Lookup("update")
+	require.NotNil(t, f)
+
+	defer f.Value.Set(f.Value.String())
+
+	require.NoError(t, f.Value.Set("true"))
+	assert.True(t, updatingGolden())
+
+	require.NoError(t, f.Value.Set("false"))
+	assert.False(t, updatingGolden())
+}
+
+// reporting builds the report of its outport date.
+type reporting struct {
+	at time.Time
+}
+
+func (r reporting) Execute(context.Context, struct{}) (*report, error) {
+	return newReport("generated", r.at, 3), nil
+}
+
+func TestRunTestcaseScenariosGolden(t *testing.T) {
+	RunTestcaseScenarios(t,
+		func(at time.Time) Inport[struct{}, report] {
+			return reporting{at: at}
+		},
+		TestScenario[struct{}, report, time.Time]{
+			Name:         "report",
+			Outport:      time.Now(),
+			Golden:       "scenario_report.golden",
+			GoldenIgnore: []string{"id", "lines.created_at"},
+		},
+	)
+}
@@ -0,0 +1,180 @@
+package wotop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop/recaptcha"
+	mockrecaptcha "github.com/a-aslani/wotop/recaptcha/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+type signUpRequest struct {
+	Email   string
+	Captcha string
+}
+
+type signUpResponse struct {
+	TraceID string
+}
+
+// signUpInteractor verifies the captcha of the request through its outport.
+type signUpInteractor struct {
+	outport recaptcha.Recaptcha
+}
+
+func (r signUpInteractor) Execute(ctx context.Context, req signUpRequest) (*signUpResponse, error) {
+	if err := r.outport.SiteVerify(ctx, "secret", req.Captcha); err != nil {
+		return nil, err
+	}
+	traceID, _ := TraceIDFromContext(ctx)
+	return &signUpResponse{TraceID: traceID}, nil
+}
+
+func TestRunTestcaseScenariosWithMocks(t *testing.T) {
+	errInvalidCaptcha := errors.New("invalid captcha")
+
+	buildOutport := func(t *testing.T) *mockrecaptcha.MockRecaptcha {
+		return mockrecaptcha.NewMockRecaptcha(gomock.NewController(t))
+	}
+
+	withTraceID := func() context.Context {
+		return WithTraceID(context.Background(), "trace-1")
+	}
+
+	RunTestcaseScenarios(t,
+		func(o *mockrecaptcha.MockRecaptcha) Inport[signUpRequest, signUpResponse] {
+			return signUpInteractor{outport: o}
+		},
+		TestScenario[signUpRequest, signUpResponse, *mockrecaptcha.MockRecaptcha]{
+			Name:          "valid captcha",
+			InportRequest: signUpRequest{Email: "a@example.com", Captcha: "ok"},
+			BuildOutport:  buildOutport,
+			Arrange: func(t *testing.T, o *mockrecaptcha.MockRecaptcha) {
+				o.EXPECT().SiteVerify(gomock.Any(), "secret", "ok").Return(nil)
+			},
+			Ctx:            withTraceID,
+			InportResponse: &signUpResponse{TraceID: "trace-1"},
+		},
+		TestScenario[signUpRequest, signUpResponse, *mockrecaptcha.MockRecaptcha]{
+			Name:          "invalid captcha",
+			InportRequest: signUpRequest{Email: "a@example.com", Captcha: "bot"},
+			BuildOutport:  buildOutport,
+			Arrange: func(t *testing.T, o *mockrecaptcha.MockRecaptcha) {
+				o.EXPECT().SiteVerify(gomock.Any(), "secret", "bot").Return(errInvalidCaptcha)
+			},
+			ExpectedError: errInvalidCaptcha,
+		},
+	)
+}
+
+// recordingT records the failures reported by the assertions of a scenario.
+type recordingT struct {
+	failures []string
+	logs     []string
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Errorf(format string, args ...any) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingT) Logf(format string, args ...any) {
+	r.logs = append(r.logs, fmt.Sprintf(format, args...))
+}
+
+func TestScenarioAssertions(t *testing.T) {
+	errNotFound := errors.New("not found")
+	wrapped := fmt.Errorf("find product: %w", errNotFound)
+
+	type scenario = TestScenario[signUpRequest, signUpResponse, any]
+
+	tests := []struct {
+		name     string
+		scenario scenario
+		res      *signUpResponse
+		err      error
+		wantFail bool
+	}{
+		{"response matches", scenario{InportResponse: &signUpResponse{TraceID: "a"}}, &signUpResponse{TraceID: "a"}, nil, false},
+		{"response differs", scenario{InportResponse: &signUpResponse{TraceID: "a"}}, &signUpResponse{TraceID: "b"}, nil, true},
+		{"unexpected error", scenario{InportResponse: &signUpResponse{}}, nil, errNotFound, true},
+		{"missing error", scenario{ExpectedError: errNotFound}, &signUpResponse{}, nil, true},
+		{"error equal", scenario{ExpectedError: errNotFound}, nil, errNotFound, false},
+		{"wrapped error is not equal", scenario{ExpectedError: errNotFound}, nil, wrapped, true},
+		{"wrapped error is", scenario{ExpectedErrorIs: errNotFound}, nil, wrapped, false},
+		{"error is not", scenario{ExpectedErrorIs: errNotFound}, nil, errors.New("other"), true},
+		{"error contains", scenario{ExpectedErrorContains: "find product"}, nil, wrapped, false},
+		{"error does not contain", scenario{ExpectedErrorContains: "find order"}, nil, wrapped, true},
+		{"error is takes precedence", scenario{ExpectedErrorIs: errNotFound, ExpectedError: errors.New("ignored")}, nil, wrapped, false},
+		{"partial response is ignored", scenario{ExpectedErrorIs: errNotFound}, &signUpResponse{TraceID: "partial"}, wrapped, false},
+		{"partial response matches", scenario{ExpectedErrorIs: errNotFound, InportResponse: &signUpResponse{TraceID: "partial"}}, &signUpResponse{TraceID: "partial"}, wrapped, false},
+		{"partial response differs", scenario{ExpectedErrorIs: errNotFound, InportResponse: &signUpResponse{TraceID: "partial"}}, nil, wrapped, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recordingT{}
+			tt.scenario.Name = tt.name
+			tt.scenario.assertError(rec, tt.err)
+			tt.scenario.assertResponse(rec, tt.res)
+
+			assert.Equal(t, tt.wantFail, len(rec.failures) > 0, "%v", rec.failures)
+			for _, f := range rec.failures {
+				assert.Contains(t, f, fmt.Sprintf("scenario %q", tt.name))
+			}
+		})
+	}
+}
+
+// registering returns a new account ID, and fails after registering when fail is set.
+type registering struct {
+	fail bool
+}
+
+var errRateLimited = errors.New("rate limited")
+
+func (r registering) Execute(_ context.Context, req signUpRequest) (*signUpResponse, error) {
+	res := &signUpResponse{TraceID: fmt.Sprintf("acc-%d", time.Now().UnixNano())}
+	if r.fail {
+		return res, fmt.Errorf("sign up %s: %w", req.Email, errRateLimited)
+	}
+	return res, nil
+}
+
+func TestRunTestcaseScenariosAssertResponse(t *testing.T) {
+	assertGenerated := func(t *testing.T, res *signUpResponse) {
+		require.NotNil(t, res)
+		assert.True(t, strings.HasPrefix(res.TraceID, "acc-"), res.TraceID)
+	}
+
+	RunTestcaseScenarios(t,
+		func(fail bool) Inport[signUpRequest, signUpResponse] {
+			return registering{fail: fail}
+		},
+		TestScenario[signUpRequest, signUpResponse, bool]{
+			Name:           "generated ID",
+			AssertResponse: assertGenerated,
+		},
+		TestScenario[signUpRequest, signUpResponse, bool]{
+			Name:            "error with a partial response",
+			InportRequest:   signUpRequest{Email: "a@example.com"},
+			Outport:         true,
+			ExpectedErrorIs: errRateLimited,
+			AssertResponse:  assertGenerated,
+		},
+		TestScenario[signUpRequest, signUpResponse, bool]{
+			Name:                  "error message",
+			InportRequest:         signUpRequest{Email: "b@example.com"},
+			Outport:               true,
+			ExpectedErrorContains: "sign up b@example.com",
+		},
+	)
+}
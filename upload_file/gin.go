@@ -1,24 +1,50 @@
 package upload_file
 
 import (
-	"errors"
-	"fmt"
 	"github.com/a-aslani/wotop/model/apperror"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
-	"io"
 	"net/http"
 	"os"
-	"path/filepath"
-	"strings"
 )
 
 const (
-	ErrInvalidFileType apperror.ErrorType = "ER0001 invalid file type %s"
-	ErrFileSizeExceeds apperror.ErrorType = "ER0002 file size exceeds the maximum limit of %d bytes"
-	ErrMissingFile     apperror.ErrorType = "ER0003 missing file"
+	ErrInvalidFileType apperror.ErrorType = "ER0301 invalid file type %s"
+	ErrFileSizeExceeds apperror.ErrorType = "ER0302 file size exceeds the maximum limit of %d bytes"
+	ErrMissingFile     apperror.ErrorType = "ER0303 missing file"
+	ErrMaliciousFile   apperror.ErrorType = "ER0304 the file is infected with %s"
+	ErrScanFailed      apperror.ErrorType = "ER0305 the file cannot be scanned for malware"
+
+	ErrUploadNotFound   apperror.ErrorType = "ER0306 the upload %s is not found or expired"
+	ErrOffsetMismatch   apperror.ErrorType = "ER0307 the upload is at offset %d"
+	ErrChecksumMismatch apperror.ErrorType = "ER0308 the checksum of the chunk does not match"
+	ErrInvalidUpload    apperror.ErrorType = "ER0309 invalid upload request: %s"
 )
 
+// StatusChecksumMismatch is the status of ErrChecksumMismatch, the one of the tus protocol.
+const StatusChecksumMismatch = 460
+
+func init() {
+	apperror.Register("upload_file",
+		apperror.Entry{Err: ErrInvalidFileType, Description: "The type of the uploaded file is not accepted."},
+		apperror.Entry{Err: ErrFileSizeExceeds, Description: "The uploaded file is too large."},
+		apperror.Entry{Err: ErrMissingFile, Description: "A required file is not uploaded."},
+		apperror.Entry{Err: ErrMaliciousFile, Description: "The malware scan found a virus in the uploaded file, it is not saved."},
+		apperror.Entry{Err: ErrScanFailed, Description: "The malware scanner is unavailable, retry later."},
+		apperror.Entry{Err: ErrUploadNotFound, Description: "The resumable upload does not exist or expired, a new one must be created."},
+		apperror.Entry{Err: ErrOffsetMismatch, Description: "The chunk does not start at the offset of the upload, resume from the offset returned by HEAD."},
+		apperror.Entry{Err: ErrChecksumMismatch, Description: "The chunk was corrupted in transit, it is discarded and must be sent again."},
+		apperror.Entry{Err: ErrInvalidUpload, Description: "A header of the resumable upload request is missing or malformed."},
+	)
+
+	apperror.MapCode(ErrMaliciousFile.Code(), http.StatusUnprocessableEntity)
+	apperror.MapCode(ErrScanFailed.Code(), http.StatusServiceUnavailable)
+	apperror.MapCode(ErrFileSizeExceeds.Code(), http.StatusRequestEntityTooLarge)
+	apperror.MapCode(ErrUploadNotFound.Code(), http.StatusNotFound)
+	apperror.MapCode(ErrOffsetMismatch.Code(), http.StatusConflict)
+	apperror.MapCode(ErrChecksumMismatch.Code(), StatusChecksumMismatch)
+	apperror.MapCode(ErrInvalidUpload.Code(), http.StatusBadRequest)
+}
+
 type Params struct {
 	FieldName     string
 	IsRequired    bool
@@ -28,6 +54,7 @@ type Params struct {
 	TempPattern   *string
 	TempDir       *string
 	SaveFileInDir bool
+	Scanner       Scanner // Scans the file while it is uploaded, NoopScanner when nil.
 }
 
 type fileUploader struct {
@@ -64,136 +91,38 @@ func (f *fileUploader) Close() {
 
 func (f *fileUploader) Upload(c *gin.Context, params Params) error {
 
-	fileHeader, err := c.FormFile(params.FieldName)
-	if err != nil {
-		if errors.Is(err, http.ErrMissingFile) {
-			if params.IsRequired {
-				return ErrMissingFile
-			}
-			return nil
-		}
+	file, err := UploadFromRequest(c.Request, params)
+	if err != nil || file == nil {
 		return err
 	}
 
-	if fileHeader.Size > params.MaxSize {
-		return ErrFileSizeExceeds.Var(params.MaxSize)
-	}
-
-	ext := filepath.Ext(fileHeader.Filename)
-	f.Ext = strings.ToLower(ext)
-
-	mimeType := fileHeader.Header.Get("Content-Type")
-
-	isAccept := false
-
-	for _, a := range params.Accept {
-		if a == mimeType {
-			isAccept = true
-			break
-		}
-	}
-
-	if !isAccept {
-		return ErrInvalidFileType.Var(mimeType)
-	}
-
-	var tmpFile *os.File
-
-	if params.TempDir != nil && params.TempPattern != nil {
-
-		if *params.TempDir != "" {
-			if err = os.MkdirAll(*params.TempDir, 0755); err != nil {
-				return err
-			}
-		}
-
-		tmpFile, err = os.CreateTemp(*params.TempDir, *params.TempPattern)
-		if err != nil {
-			return err
-		}
-
-		src, err := fileHeader.Open()
-		if err != nil {
-			return err
-		}
-		defer src.Close()
-
-		_, err = io.Copy(tmpFile, src)
-		if err != nil {
-			return err
-		}
-
-		if _, err = tmpFile.Seek(0, io.SeekStart); err != nil {
-			return err
-		}
-	}
-
-	var filePath *string
-
-	if params.SaveFileInDir {
-
-		finalDir := params.Path
-		if err := os.MkdirAll(finalDir, 0755); err != nil {
-			return err
-		}
-
-		filename := fmt.Sprintf("%s/%s.%s", params.Path, uuid.NewString(), ext)
-		finalPath := filepath.Join(finalDir, filename)
-		if err := c.SaveUploadedFile(fileHeader, finalPath); err != nil {
-			return err
-		}
+	if file.Path != "" {
+		f.FilePath = &file.Path
 	}
-
-	f.FilePath = filePath
-	f.FileSize = fileHeader.Size
-	f.Temp = tmpFile
+	f.FileSize = file.Size
+	f.Temp = file.Temp
+	f.Ext = file.Ext
 
 	return nil
 }
 
 func Upload(c *gin.Context, params Params) (string, error) {
 
-	fileHeader, err := c.FormFile(params.FieldName)
-	if err != nil {
-		if errors.Is(err, http.ErrMissingFile) {
-			if params.IsRequired {
-				return "", ErrMissingFile
-			}
-			return "", nil
-		}
-		return "", err
-	}
+	params.SaveFileInDir = true
+	params.TempDir = nil
 
-	if fileHeader.Size > params.MaxSize {
-		return "", ErrFileSizeExceeds.Var(params.MaxSize)
-	}
-
-	mimeType := fileHeader.Header.Get("Content-Type")
-
-	isAccept := false
-
-	for _, a := range params.Accept {
-		if a == mimeType {
-			isAccept = true
-			break
+	file, err := upload(c.Request, params, func(mimeType, _ string) (string, error) {
+		ext, err := getExt(mimeType)
+		if err != nil {
+			return "", err
 		}
-	}
-
-	if !isAccept {
-		return "", ErrInvalidFileType.Var(mimeType)
-	}
-
-	ext, err := getExt(mimeType)
-	if err != nil {
-		return "", err
-	}
-
-	filePath := fmt.Sprintf("%s/%s.%s", params.Path, uuid.NewString(), ext)
-	if err = c.SaveUploadedFile(fileHeader, filePath); err != nil {
+		return "." + ext, nil
+	})
+	if err != nil || file == nil {
 		return "", err
 	}
 
-	return filePath, nil
+	return file.Path, nil
 }
 
 func getExt(mimeType string) (string, error) {
@@ -0,0 +1,564 @@
+package upload_file
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/a-aslani/wotop/logger"
+	"github.com/a-aslani/wotop/model/apperror"
+	"github.com/a-aslani/wotop/model/payload"
+	"github.com/a-aslani/wotop/util"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// The headers of the tus protocol, https://tus.io/protocols/resumable-upload, used by Resumable.
+const (
+	HeaderTusResumable   = "Tus-Resumable"
+	HeaderUploadLength   = "Upload-Length"
+	HeaderUploadOffset   = "Upload-Offset"
+	HeaderUploadMetadata = "Upload-Metadata"
+	HeaderUploadChecksum = "Upload-Checksum"
+
+	// TusVersion is the version of the tus protocol implemented by Resumable.
+	TusVersion = "1.0.0"
+	// ChunkContentType is the Content-Type of the PATCH requests.
+	ChunkContentType = "application/offset+octet-stream"
+)
+
+const (
+	// DefaultUploadTTL is the time a resumable upload is kept after its last chunk.
+	DefaultUploadTTL = 24 * time.Hour
+	// DefaultMaxChunkSize is the largest chunk accepted by a PATCH request, 16 MiB.
+	DefaultMaxChunkSize = 16 << 20
+)
+
+// Storage saves the files of the completed resumable uploads, e.g. to a directory or to an
+// object storage.
+type Storage interface {
+	// Save saves a file and returns its location, e.g. its path or its URL.
+	Save(ctx context.Context, name string, r io.Reader) (string, error)
+}
+
+// DirStorage is a Storage saving the files in a directory.
+type DirStorage struct {
+	dir string
+}
+
+// NewDirStorage creates a DirStorage.
+//
+// Parameters:
+//   - dir: The directory, created when missing.
+//
+// Returns:
+//   - The storage.
+func NewDirStorage(dir string) *DirStorage {
+	return &DirStorage{dir: dir}
+}
+
+// Save writes the file in the directory and returns its path.
+func (s *DirStorage) Save(_ context.Context, name string, r io.Reader) (string, error) {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(s.dir, filepath.Base(name))
+	dst, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err = io.Copy(dst, r); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(path)
+		return "", err
+	}
+	return path, dst.Close()
+}
+
+// ResumableOptions configures Resumable.
+type ResumableOptions struct {
+	BasePath     string                                           // The route of the uploads, e.g. "/uploads", the sessions are at BasePath/:id.
+	PartDir      string                                           // The directory of the partial files, "wotop-uploads" in the temp directory when empty.
+	MaxSize      int64                                            // The largest file accepted.
+	MaxChunkSize int64                                            // The largest chunk accepted, DefaultMaxChunkSize when zero.
+	Accept       []string                                         // The accepted filetype of the Upload-Metadata, all when empty.
+	TTL          time.Duration                                    // The time a session is kept after its last chunk, DefaultUploadTTL when zero.
+	Scanner      Scanner                                          // Scans the completed files before they are saved, NoopScanner when nil.
+	OnComplete   func(ctx context.Context, session Session) error // Called once the file is saved, optional.
+	Log          logger.Logger                                    // Logs the failures of Collect, optional.
+	Clock        util.Clock                                       // Expires the sessions, util.SystemClock when nil.
+}
+
+// Resumable serves the resumable uploads of the tus protocol, for the clients uploading large
+// files over flaky networks:
+//
+//   - POST BasePath creates a session for the Upload-Length bytes of a file, described by the
+//     Upload-Metadata, and answers 201 with its Location.
+//   - HEAD BasePath/:id answers the Upload-Offset of the session, where the client resumes.
+//   - PATCH BasePath/:id appends the chunk of the body at its Upload-Offset, verified against
+//     its Upload-Checksum, e.g. "sha256 <base64>", and answers 204 with the new Upload-Offset.
+//     A chunk sent again after it was received is acknowledged again, a chunk which does not
+//     start at the offset is answered 409 with the offset. Once the file is received, it is
+//     scanned and saved to the Storage, and the session keeps its Location.
+//
+// The partial files are written to PartDir, so the requests of an upload must reach the same
+// replica, or the replicas must share PartDir. The expired sessions and their partial files
+// are deleted by Collect.
+type Resumable struct {
+	store   SessionStore
+	storage Storage
+	opts    ResumableOptions
+	locks   [64]sync.Mutex // serializes the chunks of a session, by the hash of its ID
+}
+
+// NewResumable creates a Resumable.
+//
+// Parameters:
+//   - store: The store of the sessions, e.g. a RedisSessionStore.
+//   - storage: The storage of the completed files.
+//   - opts: The routes, the limits and the hooks.
+//
+// Returns:
+//   - The handlers of the resumable uploads.
+func NewResumable(store SessionStore, storage Storage, opts ResumableOptions) *Resumable {
+	if opts.PartDir == "" {
+		opts.PartDir = filepath.Join(os.TempDir(), "wotop-uploads")
+	}
+	if opts.MaxChunkSize <= 0 {
+		opts.MaxChunkSize = DefaultMaxChunkSize
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultUploadTTL
+	}
+	if opts.Scanner == nil {
+		opts.Scanner = NoopScanner{}
+	}
+	if opts.Clock == nil {
+		opts.Clock = util.SystemClock
+	}
+	opts.BasePath = strings.TrimSuffix(opts.BasePath, "/")
+	return &Resumable{store: store, storage: storage, opts: opts}
+}
+
+// Register registers the handlers at BasePath.
+//
+// Parameters:
+//   - router: The router, e.g. a gin.Engine or a group with the authentication middleware.
+func (u *Resumable) Register(router gin.IRoutes) {
+	router.POST(u.opts.BasePath, u.Create)
+	router.HEAD(u.opts.BasePath+"/:id", u.Head)
+	router.PATCH(u.opts.BasePath+"/:id", u.Patch)
+}
+
+// Create creates a session, see Resumable.
+func (u *Resumable) Create(c *gin.Context) {
+	c.Header(HeaderTusResumable, TusVersion)
+	ctx := c.Request.Context()
+
+	size, err := strconv.ParseInt(c.GetHeader(HeaderUploadLength), 10, 64)
+	if err != nil || size < 0 {
+		u.writeError(c, ErrInvalidUpload.Var(HeaderUploadLength+" must be a positive number"))
+		return
+	}
+	if size > u.opts.MaxSize {
+		u.writeError(c, ErrFileSizeExceeds.Var(u.opts.MaxSize))
+		return
+	}
+
+	metadata, err := parseUploadMetadata(c.GetHeader(HeaderUploadMetadata))
+	if err != nil {
+		u.writeError(c, err)
+		return
+	}
+	if len(u.opts.Accept) > 0 && !util.Contains(u.opts.Accept, metadata["filetype"]) {
+		u.writeError(c, ErrInvalidFileType.Var(metadata["filetype"]))
+		return
+	}
+
+	session := Session{
+		ID:        uuid.NewString(),
+		Size:      size,
+		Metadata:  metadata,
+		ExpiresAt: u.opts.Clock.Now().Add(u.opts.TTL),
+	}
+
+	if size == 0 {
+		err = u.complete(ctx, &session, bytes.NewReader(nil))
+	} else {
+		err = u.store.Save(ctx, session)
+	}
+	if err != nil {
+		u.writeError(c, err)
+		return
+	}
+
+	c.Header("Location", u.opts.BasePath+"/"+session.ID)
+	c.Header(HeaderUploadOffset, strconv.FormatInt(session.Offset, 10))
+	c.Status(http.StatusCreated)
+}
+
+// Head answers the offset of a session, see Resumable.
+func (u *Resumable) Head(c *gin.Context) {
+	c.Header(HeaderTusResumable, TusVersion)
+	c.Header("Cache-Control", "no-store")
+
+	session, err := u.store.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.Status(payload.ErrorStatus(err))
+		return
+	}
+
+	c.Header(HeaderUploadOffset, strconv.FormatInt(session.Offset, 10))
+	c.Header(HeaderUploadLength, strconv.FormatInt(session.Size, 10))
+	c.Status(http.StatusOK)
+}
+
+// Patch appends a chunk to a session, see Resumable.
+func (u *Resumable) Patch(c *gin.Context) {
+	c.Header(HeaderTusResumable, TusVersion)
+	ctx := c.Request.Context()
+	id := c.Param("id")
+
+	if c.ContentType() != ChunkContentType {
+		u.writeError(c, ErrInvalidUpload.Var("the Content-Type must be "+ChunkContentType))
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader(HeaderUploadOffset), 10, 64)
+	if err != nil || offset < 0 {
+		u.writeError(c, ErrInvalidUpload.Var(HeaderUploadOffset+" must be a positive number"))
+		return
+	}
+	checksum, err := parseUploadChecksum(c.GetHeader(HeaderUploadChecksum))
+	if err != nil {
+		u.writeError(c, err)
+		return
+	}
+
+	lock := &u.locks[lockIndex(id, len(u.locks))]
+	lock.Lock()
+	defer lock.Unlock()
+
+	session, err := u.store.Get(ctx, id)
+	if err != nil {
+		u.writeError(c, err)
+		return
+	}
+
+	if offset != session.Offset || session.Done() {
+		err = u.checkRetry(session, offset, c.Request.Body)
+		if err != nil {
+			c.Header(HeaderUploadOffset, strconv.FormatInt(session.Offset, 10))
+			u.writeError(c, err)
+			return
+		}
+		// the chunk was received already, its response was lost
+		c.Header(HeaderUploadOffset, strconv.FormatInt(session.Offset, 10))
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	if err = u.write(ctx, session, c.Request.Body, checksum); err != nil {
+		c.Header(HeaderUploadOffset, strconv.FormatInt(session.Offset, 10))
+		u.writeError(c, err)
+		return
+	}
+
+	c.Header(HeaderUploadOffset, strconv.FormatInt(session.Offset, 10))
+	c.Status(http.StatusNoContent)
+}
+
+// checkRetry accepts a chunk sent again, whose bytes were all received before, and rejects the
+// other chunks not starting at the offset of the session with ErrOffsetMismatch.
+func (u *Resumable) checkRetry(session *Session, offset int64, body io.Reader) error {
+
+	mismatch := ErrOffsetMismatch.Var(session.Offset)
+	if offset > session.Offset {
+		return mismatch
+	}
+
+	// one byte more than the received ones is read to detect the chunks going past them
+	chunk, err := io.ReadAll(io.LimitReader(body, min(session.Offset-offset, u.opts.MaxChunkSize)+1))
+	if err != nil {
+		return err
+	}
+	if offset+int64(len(chunk)) > session.Offset {
+		return mismatch
+	}
+	if session.Done() {
+		// the partial file is gone, the retry of the last chunks is acknowledged
+		return nil
+	}
+
+	received := make([]byte, len(chunk))
+	part, err := os.Open(u.partPath(session.ID))
+	if err != nil {
+		return err
+	}
+	defer part.Close()
+	if _, err = part.ReadAt(received, offset); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(chunk, received) {
+		return mismatch
+	}
+	return nil
+}
+
+// write appends a chunk at the offset of the session and completes the upload once the file
+// is received. Without checksum, the bytes received before a read error are kept.
+func (u *Resumable) write(ctx context.Context, session *Session, body io.Reader, checksum *uploadChecksum) error {
+
+	if err := os.MkdirAll(u.opts.PartDir, 0755); err != nil {
+		return err
+	}
+	part, err := os.OpenFile(u.partPath(session.ID), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer part.Close()
+
+	// the bytes past the offset were written by a chunk whose session was not saved
+	if err = part.Truncate(session.Offset); err != nil {
+		return err
+	}
+
+	limit := min(session.Size-session.Offset, u.opts.MaxChunkSize)
+	writer := io.NewOffsetWriter(part, session.Offset)
+
+	var w io.Writer = writer
+	if checksum != nil {
+		w = io.MultiWriter(writer, checksum.hash)
+	}
+
+	// one byte more than the limit is read to detect the larger chunks
+	n, copyErr := io.Copy(w, io.LimitReader(body, limit+1))
+	switch {
+	case n > limit:
+		copyErr = ErrFileSizeExceeds.Var(limit)
+		n = 0
+	case checksum != nil && copyErr == nil && !bytes.Equal(checksum.hash.Sum(nil), checksum.sum):
+		copyErr = ErrChecksumMismatch
+		n = 0
+	case checksum != nil && copyErr != nil:
+		n = 0
+	}
+	if n == 0 {
+		if err = part.Truncate(session.Offset); err != nil {
+			return err
+		}
+		if copyErr != nil {
+			return copyErr
+		}
+	}
+
+	session.Offset += n
+	session.ExpiresAt = u.opts.Clock.Now().Add(u.opts.TTL)
+
+	if session.Offset == session.Size {
+		if err = u.complete(ctx, session, io.NewSectionReader(part, 0, session.Size)); err != nil {
+			return err
+		}
+		_ = part.Close()
+		_ = os.Remove(u.partPath(session.ID))
+		return copyErr
+	}
+
+	if err = u.store.Save(ctx, *session); err != nil {
+		return err
+	}
+	return copyErr
+}
+
+// complete scans the received file, saves it to the storage and calls OnComplete. The file is
+// scanned before it is saved, so an infected file never reaches the storage, and it is deleted
+// with its session.
+func (u *Resumable) complete(ctx context.Context, session *Session, file io.ReadSeeker) error {
+
+	meta := FileMeta{FileName: session.Metadata["filename"], MimeType: session.Metadata["filetype"]}
+	if err := u.opts.Scanner.Scan(ctx, file, meta); err != nil {
+		// the upload of an infected file cannot be resumed, the other failures can be retried
+		if hasCode(err, ErrMaliciousFile) {
+			_ = u.store.Delete(ctx, session.ID)
+			_ = os.Remove(u.partPath(session.ID))
+		}
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	location, err := u.storage.Save(ctx, session.ID+uploadExt(meta.FileName), file)
+	if err != nil {
+		return err
+	}
+
+	session.Location = location
+	if err = u.store.Save(ctx, *session); err != nil {
+		return err
+	}
+
+	if u.opts.OnComplete != nil {
+		return u.opts.OnComplete(ctx, *session)
+	}
+	return nil
+}
+
+// Collect deletes the partial files of the expired sessions and, when the store does not
+// expire them itself, the expired sessions, e.g. from a scheduled job.
+//
+// Parameters:
+//   - ctx: The context of the operation.
+//
+// Returns:
+//   - The number of partial files deleted.
+//   - An error if the partial files or the sessions cannot be listed.
+func (u *Resumable) Collect(ctx context.Context) (int, error) {
+
+	entries, err := os.ReadDir(u.opts.PartDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		_, err = u.store.Get(ctx, entry.Name())
+		if err == nil {
+			continue
+		}
+		if !hasCode(err, ErrUploadNotFound) {
+			if u.opts.Log != nil {
+				u.opts.Log.Error(ctx, "upload_file: cannot read the upload %s: %v", entry.Name(), err)
+			}
+			continue
+		}
+
+		if err = os.Remove(filepath.Join(u.opts.PartDir, entry.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			if u.opts.Log != nil {
+				u.opts.Log.Error(ctx, "upload_file: cannot delete the partial file of %s: %v", entry.Name(), err)
+			}
+			continue
+		}
+		deleted++
+	}
+
+	if d, ok := u.store.(ExpiredSessionsDeleter); ok {
+		if _, err = d.DeleteExpired(ctx); err != nil {
+			return deleted, err
+		}
+	}
+
+	return deleted, nil
+}
+
+func (u *Resumable) partPath(id string) string {
+	return filepath.Join(u.opts.PartDir, id)
+}
+
+func (u *Resumable) writeError(c *gin.Context, err error) {
+	payload.WriteError(c, err, logger.GetTraceID(c.Request.Context()))
+}
+
+// hasCode reports whether err is an apperror.ErrorType with the code of e, whatever its
+// parameters.
+func hasCode(err error, e apperror.ErrorType) bool {
+	var et apperror.ErrorType
+	return errors.As(err, &et) && et.Code() == e.Code()
+}
+
+// lockIndex returns the lock of a session.
+func lockIndex(id string, n int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(id))
+	return int(h.Sum32() % uint32(n))
+}
+
+// extension matches the extensions kept in the names of the saved files.
+var extension = regexp.MustCompile(`^\.[a-z0-9]{1,10}$`)
+
+// uploadExt returns the lowercased extension of the file name of the client, empty when it is
+// not made of letters and digits.
+func uploadExt(fileName string) string {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	if !extension.MatchString(ext) {
+		return ""
+	}
+	return ext
+}
+
+// parseUploadMetadata parses the Upload-Metadata header, comma separated pairs of a key and its
+// base64 value, e.g. "filename d29ybGQ=,filetype dmlkZW8vbXA0".
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, ErrInvalidUpload.Var(HeaderUploadMetadata + " has an empty key")
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, ErrInvalidUpload.Var(HeaderUploadMetadata + " has a value which is not base64")
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
+
+// uploadChecksum is the checksum of a chunk sent with the Upload-Checksum header.
+type uploadChecksum struct {
+	hash hash.Hash
+	sum  []byte
+}
+
+// parseUploadChecksum parses the Upload-Checksum header, an algorithm and the base64 checksum,
+// e.g. "sha256 47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=", nil when it is empty.
+func parseUploadChecksum(header string) (*uploadChecksum, error) {
+	if header == "" {
+		return nil, nil
+	}
+
+	algorithm, encoded, _ := strings.Cut(header, " ")
+	sum, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidUpload.Var(HeaderUploadChecksum + " is not base64")
+	}
+
+	var h hash.Hash
+	switch algorithm {
+	case "sha256":
+		h = sha256.New()
+	case "sha1":
+		h = sha1.New()
+	case "md5":
+		h = md5.New()
+	default:
+		return nil, ErrInvalidUpload.Var("the checksum algorithm " + algorithm + " is not supported, expected sha256, sha1 or md5")
+	}
+	return &uploadChecksum{hash: h, sum: sum}, nil
+}
@@ -0,0 +1,297 @@
+package upload_file
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop/util"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var t0 = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// resumableServer serves a Resumable at /uploads.
+type resumableServer struct {
+	*Resumable
+	engine    *gin.Engine
+	store     *MemorySessionStore
+	clock     *util.FrozenClock
+	dir       string
+	completed []Session
+}
+
+func newResumableServer(t *testing.T, opts ResumableOptions) *resumableServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	s := &resumableServer{engine: gin.New(), clock: util.NewFrozenClock(t0), dir: t.TempDir()}
+	s.store = NewMemorySessionStore(s.clock)
+
+	opts.BasePath = "/uploads"
+	opts.PartDir = filepath.Join(s.dir, "parts")
+	opts.Clock = s.clock
+	if opts.MaxSize == 0 {
+		opts.MaxSize = 1 << 20
+	}
+	opts.OnComplete = func(_ context.Context, session Session) error {
+		s.completed = append(s.completed, session)
+		return nil
+	}
+
+	s.Resumable = NewResumable(s.store, NewDirStorage(filepath.Join(s.dir, "files")), opts)
+	s.Register(s.engine)
+	return s
+}
+
+func (s *resumableServer) create(t *testing.T, size int, metadata string) string {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/uploads", nil)
+	r.Header.Set(HeaderUploadLength, strconv.Itoa(size))
+	if metadata != "" {
+		r.Header.Set(HeaderUploadMetadata, metadata)
+	}
+	w := httptest.NewRecorder()
+	s.engine.ServeHTTP(w, r)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, TusVersion, w.Header().Get(HeaderTusResumable))
+	return w.Header().Get("Location")
+}
+
+func (s *resumableServer) patch(location string, offset int, chunk []byte, checksum string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPatch, location, bytes.NewReader(chunk))
+	r.Header.Set("Content-Type", ChunkContentType)
+	r.Header.Set(HeaderUploadOffset, strconv.Itoa(offset))
+	if checksum != "" {
+		r.Header.Set(HeaderUploadChecksum, checksum)
+	}
+	w := httptest.NewRecorder()
+	s.engine.ServeHTTP(w, r)
+	return w
+}
+
+func (s *resumableServer) offset(t *testing.T, location string) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	s.engine.ServeHTTP(w, httptest.NewRequest(http.MethodHead, location, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	return w.Header().Get(HeaderUploadOffset)
+}
+
+func sha256Checksum(chunk []byte) string {
+	sum := sha256.Sum256(chunk)
+	return "sha256 " + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func metadata(pairs ...string) string {
+	var b bytes.Buffer
+	for i := 0; i < len(pairs); i += 2 {
+		if b.Len() > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(pairs[i] + " " + base64.StdEncoding.EncodeToString([]byte(pairs[i+1])))
+	}
+	return b.String()
+}
+
+func TestResumableUpload(t *testing.T) {
+	s := newResumableServer(t, ResumableOptions{})
+	content := []byte("0123456789abcdefghij")
+
+	location := s.create(t, len(content), metadata("filename", "Clip.MP4", "filetype", "video/mp4"))
+	assert.Regexp(t, `^/uploads/[0-9a-f-]{36}$`, location)
+	assert.Equal(t, "0", s.offset(t, location))
+
+	w := s.patch(location, 0, content[:8], sha256Checksum(content[:8]))
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	assert.Equal(t, "8", w.Header().Get(HeaderUploadOffset))
+
+	w = s.patch(location, 8, content[8:14], "")
+	require.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "14", s.offset(t, location))
+
+	w = s.patch(location, 14, content[14:], sha256Checksum(content[14:]))
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	assert.Equal(t, "20", w.Header().Get(HeaderUploadOffset))
+
+	require.Len(t, s.completed, 1)
+	session := s.completed[0]
+	assert.Equal(t, map[string]string{"filename": "Clip.MP4", "filetype": "video/mp4"}, session.Metadata)
+	assert.Equal(t, filepath.Join(s.dir, "files", session.ID+".mp4"), session.Location)
+	saved, err := os.ReadFile(session.Location)
+	require.NoError(t, err)
+	assert.Equal(t, content, saved)
+
+	_, err = os.Stat(filepath.Join(s.dir, "parts", session.ID))
+	assert.ErrorIs(t, err, os.ErrNotExist, "the partial file is deleted")
+
+	// the retry of the last chunk, whose response was lost, is acknowledged
+	w = s.patch(location, 14, content[14:], "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "20", w.Header().Get(HeaderUploadOffset))
+	assert.Len(t, s.completed, 1, "the file is saved once")
+}
+
+func TestResumableOutOfOrderAndDuplicateChunks(t *testing.T) {
+	s := newResumableServer(t, ResumableOptions{})
+	content := []byte("0123456789abcdefghij")
+	location := s.create(t, len(content), "")
+
+	require.Equal(t, http.StatusNoContent, s.patch(location, 0, content[:5], "").Code)
+
+	// a chunk after a lost one is rejected with the offset to resume from
+	w := s.patch(location, 10, content[10:15], "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "5", w.Header().Get(HeaderUploadOffset))
+	assert.Contains(t, w.Body.String(), "ER0307")
+
+	// a duplicate of a received chunk is acknowledged without being written again
+	w = s.patch(location, 0, content[:5], "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "5", w.Header().Get(HeaderUploadOffset))
+	w = s.patch(location, 2, content[2:4], "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	// a chunk overlapping the offset or differing from the received bytes is rejected
+	assert.Equal(t, http.StatusConflict, s.patch(location, 3, content[3:8], "").Code)
+	assert.Equal(t, http.StatusConflict, s.patch(location, 0, []byte("XXXXX"), "").Code)
+	assert.Equal(t, "5", s.offset(t, location))
+
+	// a corrupted chunk is discarded
+	w = s.patch(location, 5, []byte("corrupted"), sha256Checksum(content[5:14]))
+	assert.Equal(t, StatusChecksumMismatch, w.Code)
+	assert.Equal(t, "5", s.offset(t, location))
+
+	require.Equal(t, http.StatusNoContent, s.patch(location, 5, content[5:], sha256Checksum(content[5:])).Code)
+	require.Len(t, s.completed, 1)
+	saved, err := os.ReadFile(s.completed[0].Location)
+	require.NoError(t, err)
+	assert.Equal(t, content, saved)
+}
+
+func TestResumableRejectsRequests(t *testing.T) {
+	s := newResumableServer(t, ResumableOptions{MaxSize: 10, MaxChunkSize: 4, Accept: []string{"video/mp4"}})
+	location := s.create(t, 10, metadata("filetype", "video/mp4"))
+
+	tests := map[string]struct {
+		request func() *http.Request
+		status  int
+	}{
+		"missing length": {status: http.StatusBadRequest, request: func() *http.Request {
+			return httptest.NewRequest(http.MethodPost, "/uploads", nil)
+		}},
+		"too large": {status: http.StatusRequestEntityTooLarge, request: func() *http.Request {
+			r := httptest.NewRequest(http.MethodPost, "/uploads", nil)
+			r.Header.Set(HeaderUploadLength, "11")
+			r.Header.Set(HeaderUploadMetadata, metadata("filetype", "video/mp4"))
+			return r
+		}},
+		"filetype": {status: http.StatusInternalServerError, request: func() *http.Request {
+			r := httptest.NewRequest(http.MethodPost, "/uploads", nil)
+			r.Header.Set(HeaderUploadLength, "1")
+			r.Header.Set(HeaderUploadMetadata, metadata("filetype", "image/gif"))
+			return r
+		}},
+		"metadata": {status: http.StatusBadRequest, request: func() *http.Request {
+			r := httptest.NewRequest(http.MethodPost, "/uploads", nil)
+			r.Header.Set(HeaderUploadLength, "1")
+			r.Header.Set(HeaderUploadMetadata, "filename not-base64!")
+			return r
+		}},
+		"content type": {status: http.StatusBadRequest, request: func() *http.Request {
+			r := httptest.NewRequest(http.MethodPatch, location, bytes.NewReader([]byte("0123")))
+			r.Header.Set("Content-Type", "application/octet-stream")
+			r.Header.Set(HeaderUploadOffset, "0")
+			return r
+		}},
+		"checksum algorithm": {status: http.StatusBadRequest, request: func() *http.Request {
+			r := httptest.NewRequest(http.MethodPatch, location, bytes.NewReader([]byte("0123")))
+			r.Header.Set("Content-Type", ChunkContentType)
+			r.Header.Set(HeaderUploadOffset, "0")
+			r.Header.Set(HeaderUploadChecksum, "crc32 AAAA")
+			return r
+		}},
+		"chunk too large": {status: http.StatusRequestEntityTooLarge, request: func() *http.Request {
+			r := httptest.NewRequest(http.MethodPatch, location, bytes.NewReader([]byte("01234")))
+			r.Header.Set("Content-Type", ChunkContentType)
+			r.Header.Set(HeaderUploadOffset, "0")
+			return r
+		}},
+		"unknown upload": {status: http.StatusNotFound, request: func() *http.Request {
+			r := httptest.NewRequest(http.MethodPatch, "/uploads/unknown", bytes.NewReader([]byte("0")))
+			r.Header.Set("Content-Type", ChunkContentType)
+			r.Header.Set(HeaderUploadOffset, "0")
+			return r
+		}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.engine.ServeHTTP(w, tt.request())
+			assert.Equal(t, tt.status, w.Code, w.Body.String())
+		})
+	}
+
+	assert.Equal(t, "0", s.offset(t, location), "the rejected chunks are not written")
+}
+
+func TestResumableInfectedFile(t *testing.T) {
+	s := newResumableServer(t, ResumableOptions{Scanner: rejectingScanner{}})
+	location := s.create(t, 4, "")
+
+	w := s.patch(location, 0, []byte("eicar"[:4]), "")
+	assert.Contains(t, w.Body.String(), "ER0304")
+	assert.Empty(t, s.completed)
+
+	w = httptest.NewRecorder()
+	s.engine.ServeHTTP(w, httptest.NewRequest(http.MethodHead, location, nil))
+	assert.Equal(t, http.StatusNotFound, w.Code, "the upload is deleted")
+	_, err := os.Stat(filepath.Join(s.dir, "files"))
+	assert.ErrorIs(t, err, os.ErrNotExist, "the file is not saved")
+}
+
+func TestResumableCollect(t *testing.T) {
+	s := newResumableServer(t, ResumableOptions{TTL: time.Hour})
+
+	expired := s.create(t, 10, "")
+	require.Equal(t, http.StatusNoContent, s.patch(expired, 0, []byte("01234"), "").Code)
+
+	s.clock.Advance(30 * time.Minute)
+	active := s.create(t, 10, "")
+	require.Equal(t, http.StatusNoContent, s.patch(active, 0, []byte("01234"), "").Code)
+
+	s.clock.Advance(31 * time.Minute)
+	deleted, err := s.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+
+	entries, err := os.ReadDir(filepath.Join(s.dir, "parts"))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, filepath.Base(active), entries[0].Name())
+	assert.Len(t, s.store.sessions, 1, "the expired session is deleted")
+
+	// the active upload is completed
+	require.Equal(t, http.StatusNoContent, s.patch(active, 5, []byte("56789"), "").Code)
+	assert.Len(t, s.completed, 1)
+}
+
+func TestResumableEmptyFile(t *testing.T) {
+	s := newResumableServer(t, ResumableOptions{})
+	location := s.create(t, 0, metadata("filename", "empty.txt"))
+
+	assert.Equal(t, "0", s.offset(t, location))
+	require.Len(t, s.completed, 1)
+	assert.Equal(t, ".txt", filepath.Ext(s.completed[0].Location))
+}
@@ -0,0 +1,185 @@
+package upload_file
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// FileMeta describes the file handed to a Scanner.
+type FileMeta struct {
+	FieldName string // The form field of the file.
+	FileName  string // The name of the file on the client.
+	MimeType  string // The Content-Type of the file part.
+}
+
+// Scanner scans the content of the uploaded files for malware, see Params.Scanner.
+type Scanner interface {
+	// Scan reads the content of a file while it is uploaded. It returns ErrMaliciousFile when
+	// the file is infected, the upload is then rejected and the written files are removed.
+	Scan(ctx context.Context, r io.Reader, meta FileMeta) error
+}
+
+// NoopScanner accepts every file, it is the Scanner of the Params without one.
+type NoopScanner struct{}
+
+// Scan accepts the file without reading it.
+func (NoopScanner) Scan(context.Context, io.Reader, FileMeta) error {
+	return nil
+}
+
+const (
+	// DefaultClamAVTimeout is the timeout of a scan of ClamAVScanner.
+	DefaultClamAVTimeout = 30 * time.Second
+	// clamAVChunkSize is the size of the chunks streamed to clamd.
+	clamAVChunkSize = 32 << 10
+)
+
+// ClamAVScanner scans the files with clamd, the ClamAV daemon, streaming them with the
+// INSTREAM command over TCP. The file must be smaller than the StreamMaxLength of clamd.conf.
+type ClamAVScanner struct {
+	addr      string
+	timeout   time.Duration
+	chunkSize int
+}
+
+// NewClamAVScanner creates a ClamAVScanner.
+//
+// Parameters:
+//   - addr: The TCP address of clamd, e.g. "clamav:3310".
+//
+// Returns:
+//   - The scanner.
+func NewClamAVScanner(addr string) *ClamAVScanner {
+	return &ClamAVScanner{addr: addr, timeout: DefaultClamAVTimeout, chunkSize: clamAVChunkSize}
+}
+
+// WithTimeout sets the timeout of a scan, DefaultClamAVTimeout by default.
+func (s *ClamAVScanner) WithTimeout(timeout time.Duration) *ClamAVScanner {
+	s.timeout = timeout
+	return s
+}
+
+// Scan streams the file to clamd in chunks and reads its verdict.
+//
+// Parameters:
+//   - ctx: The context of the upload, it aborts the scan when canceled.
+//   - r: The content of the file.
+//   - meta: The file, not sent to clamd.
+//
+// Returns:
+//   - ErrMaliciousFile if clamd finds a signature.
+//   - ErrScanFailed if clamd cannot be reached or answers with an error.
+func (s *ClamAVScanner) Scan(ctx context.Context, r io.Reader, _ FileMeta) error {
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrScanFailed, err)
+	}
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	if err = s.stream(conn, r); err != nil {
+		// clamd may have answered before the end of the stream, e.g. when it is too large, a
+		// failed read of the file is returned as is
+		if !errors.Is(err, ErrScanFailed) {
+			return err
+		}
+		if verdictErr := readVerdict(conn); verdictErr != nil && !errors.Is(verdictErr, errNoVerdict) {
+			return verdictErr
+		}
+		return err
+	}
+
+	return readVerdict(conn)
+}
+
+// stream sends the INSTREAM command and the file, each chunk prefixed by its length and the end
+// of the file marked by an empty chunk.
+func (s *ClamAVScanner) stream(conn net.Conn, r io.Reader) error {
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return fmt.Errorf("%w: %v", ErrScanFailed, err)
+	}
+
+	buf := make([]byte, 4+s.chunkSize)
+	for {
+		n, err := r.Read(buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, werr := conn.Write(buf[:4+n]); werr != nil {
+				return fmt.Errorf("%w: %v", ErrScanFailed, werr)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return fmt.Errorf("%w: %v", ErrScanFailed, err)
+	}
+	return nil
+}
+
+// errNoVerdict is returned by readVerdict when clamd closes the connection without answering.
+var errNoVerdict = errors.New("clamd sent no verdict")
+
+// readVerdict reads the answer of clamd, e.g. "stream: OK" or "stream: Eicar-Signature FOUND".
+func readVerdict(conn net.Conn) error {
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		if errors.Is(err, io.EOF) {
+			return errNoVerdict
+		}
+		return fmt.Errorf("%w: %v", ErrScanFailed, err)
+	}
+
+	reply = strings.TrimSpace(strings.TrimSuffix(reply, "\x00"))
+	result := strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+
+	switch {
+	case result == "OK":
+		return nil
+	case strings.HasSuffix(result, " FOUND"):
+		return ErrMaliciousFile.Var(strings.TrimSuffix(result, " FOUND"))
+	default:
+		return fmt.Errorf("%w: %s", ErrScanFailed, reply)
+	}
+}
+
+// scan runs the scanner in its own goroutine, reading the content written to the returned
+// pipe, and sends its verdict on the returned channel once the pipe is closed.
+func scan(ctx context.Context, scanner Scanner, meta FileMeta) (*io.PipeWriter, <-chan error) {
+
+	pr, pw := io.Pipe()
+	verdict := make(chan error, 1)
+
+	go func() {
+		err := scanner.Scan(ctx, pr, meta)
+		if err != nil {
+			_ = pr.CloseWithError(err)
+		} else {
+			// a scanner may accept the file without reading it all, the upload must not block
+			_, _ = io.Copy(io.Discard, pr)
+		}
+		verdict <- err
+	}()
+
+	return pw, verdict
+}
@@ -0,0 +1,219 @@
+package upload_file
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// eicar marks the files the fake clamd reports as infected.
+const eicar = "EICAR-TEST"
+
+// fakeClamd is a clamd server answering the INSTREAM command, it records the size of the chunks.
+type fakeClamd struct {
+	addr string
+
+	mu     sync.Mutex
+	chunks []int
+}
+
+func newFakeClamd(t *testing.T) *fakeClamd {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+
+	d := &fakeClamd{addr: l.Addr().String()}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go d.serve(conn)
+		}
+	}()
+	return d
+}
+
+func (d *fakeClamd) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	command, err := r.ReadString(0)
+	if err != nil || command != "zINSTREAM\x00" {
+		_, _ = conn.Write([]byte("UNKNOWN COMMAND\x00"))
+		return
+	}
+
+	var content bytes.Buffer
+	for {
+		var size uint32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return
+		}
+		if size == 0 {
+			break
+		}
+		d.mu.Lock()
+		d.chunks = append(d.chunks, int(size))
+		d.mu.Unlock()
+		if _, err := io.CopyN(&content, r, int64(size)); err != nil {
+			return
+		}
+	}
+
+	if bytes.Contains(content.Bytes(), []byte(eicar)) {
+		_, _ = conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+		return
+	}
+	_, _ = conn.Write([]byte("stream: OK\x00"))
+}
+
+func scannedParams(dir string, scanner Scanner) Params {
+	params := avatarParams(dir)
+	params.MaxSize = 1 << 20
+	tempDir := filepath.Join(dir, "tmp")
+	pattern := "avatar-*"
+	params.TempDir, params.TempPattern = &tempDir, &pattern
+	params.Scanner = scanner
+	return params
+}
+
+func TestClamAVScannerCleanFile(t *testing.T) {
+	clamd := newFakeClamd(t)
+	scanner := NewClamAVScanner(clamd.addr)
+	scanner.chunkSize = 1024
+	dir := t.TempDir()
+
+	content := bytes.Repeat([]byte("png"), 1000)
+	file, err := UploadFromRequest(newUploadRequest(t, "me.png", "image/png", content), scannedParams(dir, scanner))
+	require.NoError(t, err)
+	defer file.Close()
+
+	saved, err := os.ReadFile(file.Path)
+	require.NoError(t, err)
+	assert.Equal(t, content, saved)
+
+	clamd.mu.Lock()
+	defer clamd.mu.Unlock()
+	assert.Greater(t, len(clamd.chunks), 2, "the file is streamed in chunks")
+	for _, size := range clamd.chunks {
+		assert.LessOrEqual(t, size, 1024)
+	}
+}
+
+func TestClamAVScannerInfectedFile(t *testing.T) {
+	clamd := newFakeClamd(t)
+	dir := t.TempDir()
+	params := scannedParams(dir, NewClamAVScanner(clamd.addr))
+
+	content := append(bytes.Repeat([]byte("x"), 100<<10), eicar...)
+	_, err := UploadFromRequest(newUploadRequest(t, "me.png", "image/png", content), params)
+	assertErrorCode(t, err, "ER0304")
+	assert.EqualError(t, err, "the file is infected with Eicar-Test-Signature")
+
+	// no partial file remains
+	for _, d := range []string{*params.TempDir, params.Path} {
+		entries, err := os.ReadDir(d)
+		require.NoError(t, err)
+		assert.Empty(t, entries, d)
+	}
+}
+
+func TestClamAVScannerUnavailable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	dir := t.TempDir()
+	params := scannedParams(dir, NewClamAVScanner(addr).WithTimeout(time.Second))
+
+	_, err = UploadFromRequest(newUploadRequest(t, "me.png", "image/png", []byte("png")), params)
+	assertErrorCode(t, err, "ER0305")
+
+	entries, err := os.ReadDir(params.Path)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestClamAVScannerErrorReply(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = bufio.NewReader(conn).ReadString(0)
+		_, _ = conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+		_, _ = io.Copy(io.Discard, conn)
+	}()
+
+	err = NewClamAVScanner(l.Addr().String()).Scan(context.Background(), bytes.NewReader(make([]byte, 1<<20)), FileMeta{})
+	assertErrorCode(t, err, "ER0305")
+	assert.ErrorContains(t, err, "INSTREAM size limit exceeded")
+}
+
+// rejectingScanner rejects the files without reading them.
+type rejectingScanner struct{}
+
+func (rejectingScanner) Scan(context.Context, io.Reader, FileMeta) error {
+	return ErrMaliciousFile.Var("a test signature")
+}
+
+func TestUploadScannerRejectsBeforeReading(t *testing.T) {
+	dir := t.TempDir()
+	params := scannedParams(dir, rejectingScanner{})
+
+	_, err := UploadFromRequest(newUploadRequest(t, "me.png", "image/png", bytes.Repeat([]byte("x"), 100<<10)), params)
+	assertErrorCode(t, err, "ER0304")
+
+	entries, err := os.ReadDir(params.Path)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+// recordingScanner reads the files and records their meta.
+type recordingScanner struct {
+	meta    FileMeta
+	content []byte
+}
+
+func (s *recordingScanner) Scan(_ context.Context, r io.Reader, meta FileMeta) error {
+	s.meta = meta
+	var err error
+	s.content, err = io.ReadAll(r)
+	return err
+}
+
+func TestUploadScannerSkipsOversizedFile(t *testing.T) {
+	dir := t.TempDir()
+	scanner := &recordingScanner{}
+	params := scannedParams(dir, scanner)
+	params.MaxSize = 16
+
+	_, err := UploadFromRequest(newUploadRequest(t, "me.png", "image/png", bytes.Repeat([]byte("x"), 17)), params)
+	assertErrorCode(t, err, "ER0302")
+
+	file, err := UploadFromRequest(newUploadRequest(t, "me.png", "image/png", []byte("png")), params)
+	require.NoError(t, err)
+	defer file.Close()
+	assert.Equal(t, FileMeta{FieldName: "avatar", FileName: "me.png", MimeType: "image/png"}, scanner.meta)
+	assert.Equal(t, "png", string(scanner.content))
+}
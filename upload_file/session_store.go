@@ -0,0 +1,249 @@
+package upload_file
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/a-aslani/wotop/util"
+	"github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+)
+
+// Session is the state of a resumable upload, see Resumable.
+type Session struct {
+	ID        string            `json:"id"`
+	Size      int64             `json:"size"`               // The size of the file, from the Upload-Length header.
+	Offset    int64             `json:"offset"`             // The number of bytes received.
+	Metadata  map[string]string `json:"metadata,omitempty"` // The Upload-Metadata of the client, e.g. filename and filetype.
+	Location  string            `json:"location,omitempty"` // Where Storage saved the file, empty until the upload completes.
+	ExpiresAt time.Time         `json:"expires_at"`         // The session and its partial file are collected after it.
+}
+
+// Done reports whether the file is received and saved.
+func (s Session) Done() bool {
+	return s.Location != ""
+}
+
+// SessionStore keeps the sessions of the resumable uploads until they expire.
+type SessionStore interface {
+	// Save creates or replaces a session, kept until its ExpiresAt.
+	Save(ctx context.Context, session Session) error
+
+	// Get returns a session, ErrUploadNotFound when it does not exist or expired.
+	Get(ctx context.Context, id string) (*Session, error)
+
+	// Delete deletes a session.
+	Delete(ctx context.Context, id string) error
+}
+
+// ExpiredSessionsDeleter is implemented by the SessionStore whose expired sessions are not
+// deleted by the store itself, Resumable.Collect deletes them.
+type ExpiredSessionsDeleter interface {
+	// DeleteExpired deletes the expired sessions and returns their number.
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// Ensure the stores implement the SessionStore interface.
+var (
+	_ SessionStore           = (*MemorySessionStore)(nil)
+	_ SessionStore           = (*RedisSessionStore)(nil)
+	_ SessionStore           = (*PostgresSessionStore)(nil)
+	_ ExpiredSessionsDeleter = (*MemorySessionStore)(nil)
+	_ ExpiredSessionsDeleter = (*PostgresSessionStore)(nil)
+)
+
+// MemorySessionStore is a SessionStore keeping the sessions in memory, for the tests and the
+// single-replica applications. It is safe for concurrent use.
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	clock    util.Clock
+	sessions map[string]Session
+}
+
+// NewMemorySessionStore creates a MemorySessionStore.
+//
+// Parameters:
+//   - clock: The clock expiring the sessions, util.SystemClock in production.
+//
+// Returns:
+//   - The store.
+func NewMemorySessionStore(clock util.Clock) *MemorySessionStore {
+	return &MemorySessionStore{clock: clock, sessions: map[string]Session{}}
+}
+
+func (s *MemorySessionStore) Save(_ context.Context, session Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[session.ID] = session
+	return nil
+}
+
+func (s *MemorySessionStore) Get(_ context.Context, id string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[id]
+	if !ok || !s.clock.Now().Before(session.ExpiresAt) {
+		return nil, ErrUploadNotFound.Var(id)
+	}
+	return &session, nil
+}
+
+func (s *MemorySessionStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+	return nil
+}
+
+func (s *MemorySessionStore) DeleteExpired(context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for id, session := range s.sessions {
+		if !s.clock.Now().Before(session.ExpiresAt) {
+			delete(s.sessions, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// UploadSessionKeyPrefix prefixes the keys of RedisSessionStore.
+const UploadSessionKeyPrefix = "upload:"
+
+// RedisSessionStore is a SessionStore keeping the sessions in Redis, expired by Redis.
+type RedisSessionStore struct {
+	rdb *redis.Client
+}
+
+// NewRedisSessionStore creates a RedisSessionStore.
+//
+// Parameters:
+//   - rdb: The Redis client.
+//
+// Returns:
+//   - The store.
+func NewRedisSessionStore(rdb *redis.Client) *RedisSessionStore {
+	return &RedisSessionStore{rdb: rdb}
+}
+
+func (s *RedisSessionStore) Save(ctx context.Context, session Session) error {
+	value, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	return s.rdb.SetArgs(ctx, UploadSessionKeyPrefix+session.ID, value, redis.SetArgs{ExpireAt: session.ExpiresAt}).Err()
+}
+
+func (s *RedisSessionStore) Get(ctx context.Context, id string) (*Session, error) {
+	value, err := s.rdb.Get(ctx, UploadSessionKeyPrefix+id).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrUploadNotFound.Var(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var session Session
+	if err = json.Unmarshal(value, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (s *RedisSessionStore) Delete(ctx context.Context, id string) error {
+	return s.rdb.Del(ctx, UploadSessionKeyPrefix+id).Err()
+}
+
+// DefaultUploadSessionsTable is the table of PostgresSessionStore when it is given none.
+const DefaultUploadSessionsTable = "upload_sessions"
+
+// PostgresSessionStore is a SessionStore keeping the sessions in a Postgres table created by
+// UploadSessionsSchema. The expired sessions are ignored, and deleted by DeleteExpired.
+type PostgresSessionStore struct {
+	db    *sql.DB
+	table string
+	clock util.Clock
+}
+
+// NewPostgresSessionStore creates a PostgresSessionStore.
+//
+// Parameters:
+//   - db: The connection pool.
+//   - table: The table of the sessions, DefaultUploadSessionsTable when empty.
+//
+// Returns:
+//   - The store.
+func NewPostgresSessionStore(db *sql.DB, table string) *PostgresSessionStore {
+	if table == "" {
+		table = DefaultUploadSessionsTable
+	}
+	return &PostgresSessionStore{db: db, table: table, clock: util.SystemClock}
+}
+
+// UploadSessionsSchema returns the statement creating the table of the sessions, e.g. for a
+// migration.
+//
+// Parameters:
+//   - table: The table of the sessions, DefaultUploadSessionsTable when empty.
+//
+// Returns:
+//   - The CREATE TABLE statement.
+func UploadSessionsSchema(table string) string {
+	if table == "" {
+		table = DefaultUploadSessionsTable
+	}
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id TEXT PRIMARY KEY,
+	data JSONB NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL
+)`, pq.QuoteIdentifier(table))
+}
+
+func (s *PostgresSessionStore) Save(ctx context.Context, session Session) error {
+	value, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, fmt.Sprintf(
+		`INSERT INTO %s (id, data, expires_at) VALUES ($1, $2, $3)
+ON CONFLICT (id) DO UPDATE SET data = EXCLUDED.data, expires_at = EXCLUDED.expires_at`, pq.QuoteIdentifier(s.table)),
+		session.ID, value, session.ExpiresAt)
+	return err
+}
+
+func (s *PostgresSessionStore) Get(ctx context.Context, id string) (*Session, error) {
+	var value []byte
+	err := s.db.QueryRowContext(ctx, fmt.Sprintf("SELECT data FROM %s WHERE id = $1 AND expires_at > $2", pq.QuoteIdentifier(s.table)),
+		id, s.clock.Now()).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUploadNotFound.Var(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var session Session
+	if err = json.Unmarshal(value, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (s *PostgresSessionStore) Delete(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = $1", pq.QuoteIdentifier(s.table)), id)
+	return err
+}
+
+func (s *PostgresSessionStore) DeleteExpired(ctx context.Context) (int64, error) {
+	res, err := s.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE expires_at <= $1", pq.QuoteIdentifier(s.table)), s.clock.Now())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,90 @@
+package upload_file
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/a-aslani/wotop/util"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisSessionStore(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	mr.SetTime(t0)
+	store := NewRedisSessionStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+
+	session := Session{ID: "upload-1", Size: 10, Offset: 4, Metadata: map[string]string{"filename": "a.txt"}, ExpiresAt: t0.Add(time.Hour)}
+	require.NoError(t, store.Save(ctx, session))
+	assert.Equal(t, time.Hour, mr.TTL(UploadSessionKeyPrefix+"upload-1"), "the key expires with the session")
+
+	got, err := store.Get(ctx, "upload-1")
+	require.NoError(t, err)
+	assert.True(t, session.ExpiresAt.Equal(got.ExpiresAt))
+	got.ExpiresAt = session.ExpiresAt
+	assert.Equal(t, session, *got)
+
+	require.NoError(t, store.Delete(ctx, "upload-1"))
+	_, err = store.Get(ctx, "upload-1")
+	assertErrorCode(t, err, "ER0306")
+
+	require.NoError(t, store.Save(ctx, session))
+	mr.FastForward(time.Hour)
+	_, err = store.Get(ctx, "upload-1")
+	assertErrorCode(t, err, "ER0306")
+}
+
+func TestPostgresSessionStore(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	store := NewPostgresSessionStore(db, "")
+	store.clock = util.NewFrozenClock(t0)
+
+	session := Session{ID: "upload-1", Size: 10, Offset: 4, ExpiresAt: t0.Add(time.Hour)}
+	data, err := json.Marshal(session)
+	require.NoError(t, err)
+
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "upload_sessions" (id, data, expires_at) VALUES ($1, $2, $3)`)).
+		WithArgs("upload-1", data, session.ExpiresAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT data FROM "upload_sessions" WHERE id = $1 AND expires_at > $2`)).
+		WithArgs("upload-1", t0).
+		WillReturnRows(sqlmock.NewRows([]string{"data"}).AddRow(data))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT data FROM "upload_sessions"`)).
+		WithArgs("upload-2", t0).
+		WillReturnRows(sqlmock.NewRows([]string{"data"}))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "upload_sessions" WHERE id = $1`)).
+		WithArgs("upload-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "upload_sessions" WHERE expires_at <= $1`)).
+		WithArgs(t0).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	require.NoError(t, store.Save(ctx, session))
+
+	got, err := store.Get(ctx, "upload-1")
+	require.NoError(t, err)
+	assert.Equal(t, session, *got)
+
+	_, err = store.Get(ctx, "upload-2")
+	assertErrorCode(t, err, "ER0306")
+
+	require.NoError(t, store.Delete(ctx, "upload-1"))
+
+	deleted, err := store.DeleteExpired(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Contains(t, UploadSessionsSchema(""), `CREATE TABLE IF NOT EXISTS "upload_sessions"`)
+}
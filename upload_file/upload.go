@@ -0,0 +1,196 @@
+package upload_file
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/a-aslani/wotop/util"
+	"github.com/google/uuid"
+)
+
+// UploadedFile is a file uploaded by UploadFromRequest.
+type UploadedFile struct {
+	FileName string   // The name of the file on the client.
+	Ext      string   // The lowercased extension of the file name, with its dot.
+	MimeType string   // The Content-Type of the file part.
+	Size     int64    // The size of the file in bytes.
+	Path     string   // The path the file is saved at, empty when Params.SaveFileInDir is false.
+	Temp     *os.File // The temp file holding the content, positioned at its start, nil without Params.TempDir.
+}
+
+// Close closes the temp file of the upload.
+func (f *UploadedFile) Close() error {
+	if f.Temp == nil {
+		return nil
+	}
+	return f.Temp.Close()
+}
+
+// UploadFromRequest reads the file of params.FieldName from a multipart request. The body is
+// streamed, the file is written to the temp file and to Params.Path and scanned by
+// Params.Scanner while it is read, and the written files are removed when it is larger than
+// Params.MaxSize or the scanner rejects it.
+//
+// It returns nil and no error when the file is missing and not required.
+func UploadFromRequest(r *http.Request, params Params) (*UploadedFile, error) {
+	return upload(r, params, func(_, fileName string) (string, error) {
+		return strings.ToLower(filepath.Ext(fileName)), nil
+	})
+}
+
+// upload is UploadFromRequest with the extension of the saved file given by ext.
+func upload(r *http.Request, params Params, ext func(mimeType, fileName string) (string, error)) (*UploadedFile, error) {
+
+	fileName, mimeType, src, err := openFile(r, params.FieldName)
+	if err != nil {
+		if errors.Is(err, http.ErrMissingFile) {
+			if params.IsRequired {
+				return nil, ErrMissingFile
+			}
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer src.Close()
+
+	if !util.Contains(params.Accept, mimeType) {
+		return nil, ErrInvalidFileType.Var(mimeType)
+	}
+
+	savedExt, err := ext(mimeType, fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &UploadedFile{
+		FileName: fileName,
+		Ext:      strings.ToLower(filepath.Ext(fileName)),
+		MimeType: mimeType,
+	}
+
+	scanner := params.Scanner
+	if scanner == nil {
+		scanner = NoopScanner{}
+	}
+
+	var writers []io.Writer
+	var created []*os.File
+
+	cleanup := func() {
+		for _, f := range created {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}
+
+	if params.TempDir != nil && params.TempPattern != nil {
+
+		if *params.TempDir != "" {
+			if err = os.MkdirAll(*params.TempDir, 0755); err != nil {
+				return nil, err
+			}
+		}
+
+		file.Temp, err = os.CreateTemp(*params.TempDir, *params.TempPattern)
+		if err != nil {
+			return nil, err
+		}
+		created = append(created, file.Temp)
+		writers = append(writers, file.Temp)
+	}
+
+	if params.SaveFileInDir {
+
+		if err = os.MkdirAll(params.Path, 0755); err != nil {
+			cleanup()
+			return nil, err
+		}
+
+		file.Path = filepath.Join(params.Path, uuid.NewString()+savedExt)
+		dst, err := os.Create(file.Path)
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+		defer dst.Close()
+		created = append(created, dst)
+		writers = append(writers, dst)
+	}
+
+	scanned, verdict := scan(r.Context(), scanner, FileMeta{FieldName: params.FieldName, FileName: fileName, MimeType: mimeType})
+	writers = append(writers, scanned)
+
+	// one byte more than the maximum is read to detect the larger files
+	file.Size, err = io.Copy(io.MultiWriter(writers...), io.LimitReader(src, params.MaxSize+1))
+	if err != nil {
+		_ = scanned.CloseWithError(err)
+		if scanErr := <-verdict; scanErr != nil {
+			err = scanErr
+		}
+		cleanup()
+		return nil, err
+	}
+
+	if file.Size > params.MaxSize {
+		_ = scanned.CloseWithError(ErrFileSizeExceeds)
+		<-verdict
+		cleanup()
+		return nil, ErrFileSizeExceeds.Var(params.MaxSize)
+	}
+
+	_ = scanned.Close()
+	if err = <-verdict; err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	if file.Temp != nil {
+		if _, err = file.Temp.Seek(0, io.SeekStart); err != nil {
+			cleanup()
+			return nil, err
+		}
+	}
+
+	return file, nil
+}
+
+// openFile finds the file part of the field in the multipart body of the request. A form
+// already parsed, e.g. by another middleware, is read from r.MultipartForm.
+func openFile(r *http.Request, fieldName string) (string, string, io.ReadCloser, error) {
+
+	if r.MultipartForm != nil {
+		headers := r.MultipartForm.File[fieldName]
+		if len(headers) == 0 {
+			return "", "", nil, http.ErrMissingFile
+		}
+		src, err := headers[0].Open()
+		if err != nil {
+			return "", "", nil, err
+		}
+		return headers[0].Filename, headers[0].Header.Get("Content-Type"), src, nil
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return "", "", nil, http.ErrMissingFile
+		}
+		if err != nil {
+			return "", "", nil, err
+		}
+
+		if part.FormName() == fieldName && part.FileName() != "" {
+			return part.FileName(), part.Header.Get("Content-Type"), part, nil
+		}
+		_ = part.Close()
+	}
+}
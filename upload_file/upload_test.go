@@ -0,0 +1,150 @@
+package upload_file
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/a-aslani/wotop/model/apperror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newUploadRequest builds a multipart request with a title field followed by the file of the
+// avatar field, the file is left out when content is nil.
+func newUploadRequest(t *testing.T, fileName, mimeType string, content []byte) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	require.NoError(t, w.WriteField("title", "profile"))
+
+	if content != nil {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", `form-data; name="avatar"; filename="`+fileName+`"`)
+		header.Set("Content-Type", mimeType)
+		part, err := w.CreatePart(header)
+		require.NoError(t, err)
+		_, err = part.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	r := httptest.NewRequest(http.MethodPost, "/avatars", &body)
+	r.Header.Set("Content-Type", w.FormDataContentType())
+	return r
+}
+
+func avatarParams(dir string) Params {
+	return Params{
+		FieldName:     "avatar",
+		IsRequired:    true,
+		Path:          filepath.Join(dir, "avatars"),
+		MaxSize:       16,
+		Accept:        []string{"image/png", "image/jpeg"},
+		SaveFileInDir: true,
+	}
+}
+
+func assertErrorCode(t *testing.T, err error, code string) {
+	t.Helper()
+	var et apperror.ErrorType
+	require.True(t, errors.As(err, &et), "unexpected error %v", err)
+	assert.Equal(t, code, et.Code())
+}
+
+func TestUploadFromRequestSavesFile(t *testing.T) {
+	dir := t.TempDir()
+
+	file, err := UploadFromRequest(newUploadRequest(t, "Me.PNG", "image/png", []byte("png content")), avatarParams(dir))
+	require.NoError(t, err)
+	require.NotNil(t, file)
+
+	assert.Equal(t, "Me.PNG", file.FileName)
+	assert.Equal(t, ".png", file.Ext)
+	assert.Equal(t, "image/png", file.MimeType)
+	assert.Equal(t, int64(11), file.Size)
+	assert.Nil(t, file.Temp)
+
+	assert.Equal(t, filepath.Join(dir, "avatars"), filepath.Dir(file.Path))
+	assert.Equal(t, ".png", filepath.Ext(file.Path))
+	saved, err := os.ReadFile(file.Path)
+	require.NoError(t, err)
+	assert.Equal(t, "png content", string(saved))
+}
+
+func TestUploadFromRequestTempFile(t *testing.T) {
+	dir := t.TempDir()
+	params := avatarParams(dir)
+	params.SaveFileInDir = false
+	tempDir := filepath.Join(dir, "tmp")
+	pattern := "avatar-*"
+	params.TempDir, params.TempPattern = &tempDir, &pattern
+
+	file, err := UploadFromRequest(newUploadRequest(t, "me.jpg", "image/jpeg", []byte("jpeg content")), params)
+	require.NoError(t, err)
+	defer file.Close()
+
+	assert.Empty(t, file.Path)
+	require.NotNil(t, file.Temp)
+	assert.Equal(t, tempDir, filepath.Dir(file.Temp.Name()))
+
+	content, err := io.ReadAll(file.Temp)
+	require.NoError(t, err)
+	assert.Equal(t, "jpeg content", string(content))
+}
+
+func TestUploadFromRequestRejectsFiles(t *testing.T) {
+	dir := t.TempDir()
+	params := avatarParams(dir)
+	tempDir := filepath.Join(dir, "tmp")
+	pattern := "avatar-*"
+	params.TempDir, params.TempPattern = &tempDir, &pattern
+
+	_, err := UploadFromRequest(newUploadRequest(t, "me.png", "image/png", bytes.Repeat([]byte("x"), 17)), params)
+	assertErrorCode(t, err, "ER0302")
+
+	// the files written while the body was streamed are removed
+	for _, d := range []string{tempDir, params.Path} {
+		entries, err := os.ReadDir(d)
+		require.NoError(t, err)
+		assert.Empty(t, entries, d)
+	}
+
+	_, err = UploadFromRequest(newUploadRequest(t, "me.gif", "image/gif", []byte("gif")), params)
+	assertErrorCode(t, err, "ER0301")
+	assert.EqualError(t, err, "invalid file type image/gif")
+}
+
+func TestUploadFromRequestMissingFile(t *testing.T) {
+	params := avatarParams(t.TempDir())
+
+	_, err := UploadFromRequest(newUploadRequest(t, "", "", nil), params)
+	assertErrorCode(t, err, "ER0303")
+
+	params.IsRequired = false
+	file, err := UploadFromRequest(newUploadRequest(t, "", "", nil), params)
+	require.NoError(t, err)
+	assert.Nil(t, file)
+
+	r := httptest.NewRequest(http.MethodPost, "/avatars", bytes.NewBufferString(`{}`))
+	r.Header.Set("Content-Type", "application/json")
+	_, err = UploadFromRequest(r, params)
+	assert.ErrorIs(t, err, http.ErrNotMultipart)
+}
+
+func TestUploadFromRequestParsedForm(t *testing.T) {
+	r := newUploadRequest(t, "me.png", "image/png", []byte("png content"))
+	require.NoError(t, r.ParseMultipartForm(1<<20))
+
+	file, err := UploadFromRequest(r, avatarParams(t.TempDir()))
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), file.Size)
+}
@@ -26,8 +26,8 @@ type Inport[REQUEST, RESPONSE any] interface {
 // GetInport retrieves and validates an Inport instance from a use case.
 //
 // This function ensures that the provided use case can be cast to the Inport interface
-// with the specified request and response types. If the use case is invalid or cannot
-// be cast, the function logs an error message and terminates the program.
+// with the specified request and response types. It is meant to be called with the
+// result of UsecaseRegisterer.GetUsecase, whose error is returned as it is.
 //
 // Type Parameters:
 //   - Req: The type of the request object.
@@ -35,23 +35,69 @@ type Inport[REQUEST, RESPONSE any] interface {
 //
 // Parameters:
 //   - usecase: The use case to be cast to the Inport interface.
-//   - err: An error object that, if non-nil, will cause the program to terminate.
+//   - err: An error object that, if non-nil, is returned instead of the Inport.
 //
 // Returns:
 //   - An Inport instance with the specified request and response types.
-func GetInport[Req, Res any](usecase any, err error) Inport[Req, Res] {
+//   - An error if err is non-nil or the use case does not implement the Inport.
+func GetInport[Req, Res any](usecase any, err error) (Inport[Req, Res], error) {
 
-	// Check if an error was provided and terminate the program if so.
 	if err != nil {
-		fmt.Printf("\n\n%s...\n\n", err.Error())
-		os.Exit(0)
+		return nil, err
 	}
 
 	// Attempt to cast the use case to the Inport interface.
 	inport, ok := usecase.(Inport[Req, Res])
 	if !ok {
-		// Log an error message and terminate the program if the cast fails.
-		fmt.Printf("unable to cast to Inport\n")
+		var req Req
+		var res Res
+		return nil, fmt.Errorf("usecase %T does not implement Inport[%T, %T]", usecase, req, res)
+	}
+
+	return inport, nil
+}
+
+// MustGetInport is like GetInport but panics when the Inport cannot be retrieved.
+// It suits handlers built while the application starts, where a missing use case is a wiring mistake.
+//
+// Type Parameters:
+//   - Req: The type of the request object.
+//   - Res: The type of the response object.
+//
+// Parameters:
+//   - usecase: The use case to be cast to the Inport interface.
+//   - err: An error object that, if non-nil, causes a panic.
+//
+// Returns:
+//   - An Inport instance with the specified request and response types.
+func MustGetInport[Req, Res any](usecase any, err error) Inport[Req, Res] {
+	inport, err := GetInport[Req, Res](usecase, err)
+	if err != nil {
+		panic(fmt.Errorf("wotop: cannot get inport: %w", err))
+	}
+	return inport
+}
+
+// GetInportOrExit is like GetInport but prints the error and terminates the program
+// with exit code 0 when the Inport cannot be retrieved.
+//
+// Deprecated: the exit code hides wiring mistakes and makes controllers untestable,
+// use GetInport or MustGetInport instead.
+//
+// Type Parameters:
+//   - Req: The type of the request object.
+//   - Res: The type of the response object.
+//
+// Parameters:
+//   - usecase: The use case to be cast to the Inport interface.
+//   - err: An error object that, if non-nil, will cause the program to terminate.
+//
+// Returns:
+//   - An Inport instance with the specified request and response types.
+func GetInportOrExit[Req, Res any](usecase any, err error) Inport[Req, Res] {
+	inport, err := GetInport[Req, Res](usecase, err)
+	if err != nil {
+		fmt.Printf("\n\n%s...\n\n", err.Error())
 		os.Exit(0)
 	}
 	return inport
@@ -0,0 +1,120 @@
+package wotop
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	orderregister "github.com/a-aslani/wotop/internal/registrytest/order/register"
+	productregister "github.com/a-aslani/wotop/internal/registrytest/product/register"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type echoRequest struct{ Message string }
+
+type echoResponse struct{ Message string }
+
+type echoInteractor struct{}
+
+func (echoInteractor) Execute(_ context.Context, req echoRequest) (*echoResponse, error) {
+	return &echoResponse{Message: req.Message}, nil
+}
+
+func TestGetInport(t *testing.T) {
+
+	t.Run("registered usecase", func(t *testing.T) {
+		ctrl := NewBaseController()
+		ctrl.AddUsecase(&echoInteractor{})
+
+		inport, err := GetInport[echoRequest, echoResponse](ctrl.GetUsecase(echoRequest{}))
+		require.NoError(t, err)
+
+		res, err := inport.Execute(context.Background(), echoRequest{Message: "hi"})
+		require.NoError(t, err)
+		assert.Equal(t, "hi", res.Message)
+	})
+
+	t.Run("lookup error is returned", func(t *testing.T) {
+		lookupErr := errors.New("not registered")
+		_, err := GetInport[echoRequest, echoResponse](nil, lookupErr)
+		assert.ErrorIs(t, err, lookupErr)
+	})
+
+	t.Run("wrong inport types", func(t *testing.T) {
+		_, err := GetInport[echoRequest, string](&echoInteractor{}, nil)
+		assert.EqualError(t, err, "usecase *wotop.echoInteractor does not implement Inport[wotop.echoRequest, string]")
+	})
+}
+
+func TestMustGetInport(t *testing.T) {
+	assert.NotPanics(t, func() { MustGetInport[echoRequest, echoResponse](&echoInteractor{}, nil) })
+
+	assert.PanicsWithError(t, "wotop: cannot get inport: usecase <nil> does not implement Inport[wotop.echoRequest, wotop.echoResponse]", func() {
+		MustGetInport[echoRequest, echoResponse](nil, nil)
+	})
+}
+
+func TestGetUsecaseNotRegistered(t *testing.T) {
+	for name, ctrl := range map[string]UsecaseRegisterer{
+		"controller": NewBaseController(),
+		"consumer":   NewBaseConsumer(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ctrl.GetUsecase(echoRequest{})
+			assert.EqualError(t, err, `usecase with package "github.com/a-aslani/wotop" is not registered yet in application, registered packages: []`)
+
+			ctrl.AddUsecase(&echoInteractor{})
+			_, err = ctrl.GetUsecase(struct{}{})
+			assert.EqualError(t, err, "type struct {} is not declared in a usecase package")
+
+			_, err = ctrl.GetUsecase(errors.New(""))
+			assert.EqualError(t, err, `usecase with package "errors" is not registered yet in application, registered packages: ["github.com/a-aslani/wotop"]`)
+
+			uc, err := ctrl.GetUsecase(&echoRequest{})
+			require.NoError(t, err)
+			assert.IsType(t, &echoInteractor{}, uc)
+		})
+	}
+}
+
+func TestUsecaseRegistryKeysByPackagePath(t *testing.T) {
+	for name, ctrl := range map[string]UsecaseRegisterer{
+		"controller": NewBaseController(),
+		"consumer":   NewBaseConsumer(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			// both packages are named register
+			ctrl.AddUsecase(&productregister.Interactor{}, &orderregister.Interactor{})
+
+			product, err := GetInport[productregister.InportRequest, productregister.InportResponse](ctrl.GetUsecase(productregister.InportRequest{}))
+			require.NoError(t, err)
+			res, err := product.Execute(context.Background(), productregister.InportRequest{})
+			require.NoError(t, err)
+			assert.Equal(t, "product", res.Domain)
+
+			order, err := GetInport[orderregister.InportRequest, orderregister.InportResponse](ctrl.GetUsecase(orderregister.InportRequest{}))
+			require.NoError(t, err)
+			res2, err := order.Execute(context.Background(), orderregister.InportRequest{})
+			require.NoError(t, err)
+			assert.Equal(t, "order", res2.Domain)
+
+			_, err = ctrl.GetUsecase(echoRequest{})
+			assert.EqualError(t, err, `usecase with package "github.com/a-aslani/wotop" is not registered yet in application, registered packages: [`+
+				`"github.com/a-aslani/wotop/internal/registrytest/order/register", "github.com/a-aslani/wotop/internal/registrytest/product/register"]`)
+		})
+	}
+}
+
+func TestAddUsecasePanicsOnDuplicatePackage(t *testing.T) {
+	ctrl := NewBaseController()
+	ctrl.AddUsecase(&productregister.Interactor{})
+
+	assert.PanicsWithValue(t, `wotop: usecase register.Interactor cannot be registered, package "github.com/a-aslani/wotop/internal/registrytest/product/register" is already registered by *register.Interactor`, func() {
+		ctrl.AddUsecase(productregister.Interactor{})
+	})
+
+	assert.PanicsWithValue(t, "wotop: usecase <nil> is not declared in a usecase package", func() {
+		ctrl.AddUsecase(nil)
+	})
+}
@@ -0,0 +1,71 @@
+package util
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the current time. Components that depend on the time accept a Clock
+// instead of calling time.Now, so tests can freeze it.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+}
+
+// SystemClock is the Clock reading the system time.
+var SystemClock Clock = systemClock{}
+
+// systemClock is the Clock returned by SystemClock.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// FrozenClock is a Clock that only moves when told to, meant for tests. It is safe
+// for concurrent use.
+type FrozenClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFrozenClock creates a FrozenClock stopped at the given time.
+//
+// Parameters:
+//   - t: The time returned by Now.
+//
+// Returns:
+//   - A new FrozenClock.
+func NewFrozenClock(t time.Time) *FrozenClock {
+	return &FrozenClock{now: t}
+}
+
+// Now returns the time the clock is stopped at.
+//
+// Returns:
+//   - The frozen time.
+func (c *FrozenClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set stops the clock at another time.
+//
+// Parameters:
+//   - t: The new time returned by Now.
+func (c *FrozenClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
+
+// Advance moves the clock forward.
+//
+// Parameters:
+//   - d: The duration to add to the frozen time.
+func (c *FrozenClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
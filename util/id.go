@@ -1,26 +1,100 @@
 package util
 
-import gonanoid "github.com/matoous/go-nanoid"
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"time"
+
+	"github.com/google/uuid"
+)
 
 // alphabet defines the set of characters used to generate IDs.
 const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ1234567890"
 
+// crockford is the Crockford base32 alphabet of the ULIDs, sorted so the encoded IDs sort like their bytes.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
 // GenerateID generates a unique ID of the specified length.
 //
-// This function uses the `gonanoid` library to create a random string
-// consisting of characters from the predefined `alphabet`.
+// The characters are drawn from the predefined `alphabet` with crypto/rand, so the IDs
+// cannot be predicted and IDs generated at the same time do not collide.
 //
 // Parameters:
 //   - n: The length of the ID to be generated.
 //
 // Returns:
-//   - A string representing the generated ID. If an error occurs during
-//     generation, an empty string is returned.
+//   - A string representing the generated ID, empty when n is not positive.
 func GenerateID(n int) string {
-	ID, err := gonanoid.Generate(alphabet, n)
-	if err != nil {
+	return randomString(alphabet, n)
+}
+
+// GenerateULID generates a ULID: 48 bits of millisecond timestamp followed by 80 random bits,
+// encoded as 26 Crockford base32 characters.
+//
+// The IDs sort by their creation time, to the millisecond, which keeps database indexes compact.
+//
+// Returns:
+//   - A string representing the generated ULID, e.g. "01J9Z3Q8V8G6X0Q7W4ZK2N5D3M".
+func GenerateULID() string {
+	var id [16]byte
+
+	ms := uint64(time.Now().UnixMilli())
+	id[0] = byte(ms >> 40)
+	id[1] = byte(ms >> 32)
+	id[2] = byte(ms >> 24)
+	id[3] = byte(ms >> 16)
+	id[4] = byte(ms >> 8)
+	id[5] = byte(ms)
+
+	_, _ = rand.Read(id[6:]) // never returns an error
+
+	// the 128 bits are encoded 5 by 5 from the end, the first character carries the 3 top bits
+	hi := binary.BigEndian.Uint64(id[:8])
+	lo := binary.BigEndian.Uint64(id[8:])
+
+	var out [26]byte
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+
+	return string(out[:])
+}
+
+// GenerateUUIDv7 generates a version 7 UUID, whose first 48 bits are the millisecond timestamp,
+// so the IDs sort by their creation time.
+//
+// Returns:
+//   - A string representing the generated UUID, e.g. "0192a7d2-5c4b-7e3a-9f1d-3c2b1a0e9d8c".
+func GenerateUUIDv7() string {
+	return uuid.Must(uuid.NewV7()).String()
+}
+
+// randomString returns n characters drawn uniformly from chars with crypto/rand.
+func randomString(chars string, n int) string {
+	if n <= 0 {
 		return ""
 	}
 
-	return ID
+	// bytes from limit on are rejected, so every character is equally likely
+	limit := 256 - 256%len(chars)
+
+	b := make([]byte, n)
+	buf := make([]byte, n+n/4+8)
+	for i := 0; i < n; {
+		_, _ = rand.Read(buf) // never returns an error
+		for _, r := range buf {
+			if int(r) >= limit {
+				continue
+			}
+			b[i] = chars[int(r)%len(chars)]
+			i++
+			if i == n {
+				break
+			}
+		}
+	}
+
+	return string(b)
 }
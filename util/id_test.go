@@ -0,0 +1,121 @@
+package util
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const collisionRounds = 1_000_000
+
+// assertNoCollision generates collisionRounds IDs and fails on the first duplicate.
+func assertNoCollision(t *testing.T, generate func() string) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("short mode")
+	}
+
+	seen := make(map[string]struct{}, collisionRounds)
+	for i := 0; i < collisionRounds; i++ {
+		id := generate()
+		if _, ok := seen[id]; ok {
+			t.Fatalf("collision after %d IDs: %s", i, id)
+		}
+		seen[id] = struct{}{}
+	}
+}
+
+func TestGenerateIDNoCollision(t *testing.T) {
+	assertNoCollision(t, func() string { return GenerateID(16) })
+}
+
+func TestGenerateKeyNoCollision(t *testing.T) {
+	assertNoCollision(t, func() string { return GenerateKey(16) })
+}
+
+func TestGenerateULIDNoCollision(t *testing.T) {
+	assertNoCollision(t, GenerateULID)
+}
+
+func TestGenerateUUIDv7NoCollision(t *testing.T) {
+	assertNoCollision(t, GenerateUUIDv7)
+}
+
+func TestGenerateIDFormat(t *testing.T) {
+	assert.Regexp(t, regexp.MustCompile(`^[A-Z0-9]{16}$`), GenerateID(16))
+	assert.Regexp(t, regexp.MustCompile(`^[a-zA-Z0-9]{32}$`), GenerateKey(32))
+	assert.Regexp(t, regexp.MustCompile(`^[0-9]{6}$`), GenerateRandomNumber(6))
+	assert.Equal(t, "", GenerateID(0))
+}
+
+func TestGenerateULIDSortsByTime(t *testing.T) {
+	var ids []string
+	for i := 0; i < 3; i++ {
+		ids = append(ids, GenerateULID())
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	assert.True(t, sort.StringsAreSorted(ids), "%v", ids)
+	for _, id := range ids {
+		assert.Regexp(t, regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`), id)
+	}
+}
+
+func TestGenerateULIDTimestamp(t *testing.T) {
+	before := time.Now().UnixMilli()
+	id := GenerateULID()
+
+	var ms int64
+	for _, c := range id[:10] {
+		ms = ms<<5 | int64(strings.IndexRune(crockford, c))
+	}
+
+	require.GreaterOrEqual(t, ms, before)
+	assert.LessOrEqual(t, ms, time.Now().UnixMilli())
+}
+
+func TestGenerateUUIDv7(t *testing.T) {
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), GenerateUUIDv7())
+}
+
+func TestRandomStringIsUniform(t *testing.T) {
+	counts := map[rune]int{}
+	for _, c := range randomString(alphabet, 360_000) {
+		counts[c]++
+	}
+
+	require.Len(t, counts, len(alphabet))
+	for c, n := range counts {
+		// 10000 expected per character
+		assert.InDelta(t, 10_000, n, 600, "character %c", c)
+	}
+}
+
+func BenchmarkGenerateID(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		GenerateID(16)
+	}
+}
+
+func BenchmarkGenerateKey(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		GenerateKey(16)
+	}
+}
+
+func BenchmarkGenerateULID(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		GenerateULID()
+	}
+}
+
+func BenchmarkGenerateUUIDv7(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		GenerateUUIDv7()
+	}
+}
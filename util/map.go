@@ -0,0 +1,43 @@
+package util
+
+// Keys returns the keys of a map.
+//
+// The order of the keys is unspecified, sort them when it matters.
+//
+// Type Parameters:
+//   - K: The type of the keys.
+//   - V: The type of the values.
+//
+// Parameters:
+//   - m: The map, it may be nil.
+//
+// Returns:
+//   - A slice holding the keys, empty but not nil for a nil or empty map.
+func Keys[K comparable, V any](m map[K]V) []K {
+	res := make([]K, 0, len(m))
+	for k := range m {
+		res = append(res, k)
+	}
+	return res
+}
+
+// Values returns the values of a map.
+//
+// The order of the values is unspecified, sort them when it matters.
+//
+// Type Parameters:
+//   - K: The type of the keys.
+//   - V: The type of the values.
+//
+// Parameters:
+//   - m: The map, it may be nil.
+//
+// Returns:
+//   - A slice holding the values, empty but not nil for a nil or empty map.
+func Values[K comparable, V any](m map[K]V) []V {
+	res := make([]V, 0, len(m))
+	for _, v := range m {
+		res = append(res, v)
+	}
+	return res
+}
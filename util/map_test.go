@@ -0,0 +1,25 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeys(t *testing.T) {
+	assert.ElementsMatch(t, []string{"a", "b"}, Keys(map[string]int{"a": 1, "b": 2}))
+
+	var nilMap map[string]int
+	keys := Keys(nilMap)
+	assert.NotNil(t, keys)
+	assert.Empty(t, keys)
+}
+
+func TestValues(t *testing.T) {
+	assert.ElementsMatch(t, []int{1, 2}, Values(map[string]int{"a": 1, "b": 2}))
+
+	var nilMap map[string]int
+	values := Values(nilMap)
+	assert.NotNil(t, values)
+	assert.Empty(t, values)
+}
@@ -0,0 +1,168 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidParts is returned by SplitAmount when the number of parts is not positive.
+var ErrInvalidParts = errors.New("the number of parts must be positive")
+
+// ErrAmountOutOfRange is returned by ToMinorUnits when the amount is NaN, infinite or does not
+// fit in an int64 of minor units.
+var ErrAmountOutOfRange = errors.New("amount out of range")
+
+// currencyExponents holds the ISO 4217 minor unit exponents that differ from 2.
+var currencyExponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// RoundHalfUp rounds a floating-point number to a number of decimal places, halves
+// being rounded away from zero, e.g. 0.145 becomes 0.15 and -0.145 becomes -0.15.
+//
+// Unlike ToFixed, the rounding is done on the shortest decimal representation of the
+// value with math/big, so it is not affected by binary float artifacts.
+//
+// Parameters:
+//   - value: The number to be rounded, NaN and infinities are returned unchanged.
+//   - precision: The number of decimal places to round to, a negative value rounds to tens, hundreds...
+//
+// Returns:
+//   - The nearest float64 to the rounded decimal value.
+func RoundHalfUp(value float64, precision int) float64 {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return value
+	}
+
+	scaled := roundDecimal(value, precision)
+
+	res := new(big.Rat).SetInt(scaled)
+	if precision >= 0 {
+		res.Quo(res, new(big.Rat).SetInt(pow10(precision)))
+	} else {
+		res.Mul(res, new(big.Rat).SetInt(pow10(-precision)))
+	}
+
+	f, _ := res.Float64()
+	return f
+}
+
+// SplitAmount splits an amount in minor units into parts that differ by at most one
+// unit and add up to the amount, e.g. 100 cents in 3 parts gives 34, 33 and 33.
+//
+// The first parts receive the remaining units.
+//
+// Parameters:
+//   - total: The amount in minor units, it may be negative.
+//   - parts: The number of parts.
+//
+// Returns:
+//   - A slice of the parts.
+//   - An error wrapping ErrInvalidParts if parts is not positive.
+func SplitAmount(total int64, parts int) ([]int64, error) {
+	if parts <= 0 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidParts, parts)
+	}
+
+	share := total / int64(parts)
+	rem := total % int64(parts)
+
+	res := make([]int64, parts)
+	for i := range res {
+		res[i] = share
+		switch {
+		case int64(i) < rem:
+			res[i]++
+		case int64(i) < -rem:
+			res[i]--
+		}
+	}
+	return res, nil
+}
+
+// CurrencyExponent returns the number of decimal places of the minor unit of a currency,
+// e.g. 2 for USD (cents), 0 for JPY and 3 for KWD.
+//
+// Parameters:
+//   - currency: The ISO 4217 code of the currency, case-insensitive.
+//
+// Returns:
+//   - The exponent of the currency, 2 for unknown currencies.
+func CurrencyExponent(currency string) int {
+	if exp, ok := currencyExponents[strings.ToUpper(currency)]; ok {
+		return exp
+	}
+	return 2
+}
+
+// ToMinorUnits converts an amount to the minor units of its currency, rounding halves
+// away from zero, e.g. 19.99 USD becomes 1999 and 1500.5 JPY becomes 1501.
+//
+// Parameters:
+//   - amount: The amount in major units.
+//   - currency: The ISO 4217 code of the currency, see CurrencyExponent.
+//
+// Returns:
+//   - The amount in minor units.
+//   - An error wrapping ErrAmountOutOfRange if the amount is NaN, infinite or its minor units
+//     overflow an int64, e.g. 1e17 USD.
+func ToMinorUnits(amount float64, currency string) (int64, error) {
+	if math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return 0, fmt.Errorf("%w: %v %s", ErrAmountOutOfRange, amount, currency)
+	}
+
+	minor := roundDecimal(amount, CurrencyExponent(currency))
+	if !minor.IsInt64() {
+		return 0, fmt.Errorf("%w: %v %s", ErrAmountOutOfRange, amount, currency)
+	}
+	return minor.Int64(), nil
+}
+
+// FromMinorUnits converts an amount in the minor units of its currency to major units,
+// e.g. 1999 USD cents becomes 19.99.
+//
+// Parameters:
+//   - minor: The amount in minor units.
+//   - currency: The ISO 4217 code of the currency, see CurrencyExponent.
+//
+// Returns:
+//   - The amount in major units.
+func FromMinorUnits(minor int64, currency string) float64 {
+	f, _ := new(big.Rat).SetFrac(big.NewInt(minor), pow10(CurrencyExponent(currency))).Float64()
+	return f
+}
+
+// roundDecimal returns value × 10^precision rounded half away from zero, value being
+// read as its shortest decimal representation.
+func roundDecimal(value float64, precision int) *big.Int {
+	r, _ := new(big.Rat).SetString(strconv.FormatFloat(value, 'f', -1, 64))
+
+	num := new(big.Int).Abs(r.Num())
+	den := new(big.Int).Set(r.Denom())
+	if precision >= 0 {
+		num.Mul(num, pow10(precision))
+	} else {
+		den.Mul(den, pow10(-precision))
+	}
+
+	q, m := num.QuoRem(num, den, new(big.Int))
+	if m.Lsh(m, 1).Cmp(den) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if r.Sign() < 0 {
+		q.Neg(q)
+	}
+	return q
+}
+
+// pow10 returns 10^n.
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,154 @@
+package util
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand/v2"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundHalfUp(t *testing.T) {
+	tests := []struct {
+		value     float64
+		precision int
+		want      float64
+	}{
+		{0.145, 2, 0.15},
+		{1.005, 2, 1.01},
+		{2.675, 2, 2.68},
+		{-0.145, 2, -0.15},
+		{0.144, 2, 0.14},
+		{12.5, 0, 13},
+		{1250, -2, 1300},
+		{0.1 + 0.2, 1, 0.3},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v/%d", tt.value, tt.precision), func(t *testing.T) {
+			assert.Equal(t, tt.want, RoundHalfUp(tt.value, tt.precision))
+		})
+	}
+
+	// ToFixed is kept for compatibility, it is still wrong for these
+	assert.Equal(t, 0.14, ToFixed(0.145, 2))
+}
+
+// roundRat is the reference rounding, done on big.Rat only.
+func roundRat(r *big.Rat, precision int) *big.Rat {
+	scale := new(big.Rat).SetInt(pow10(precision))
+	scaled := new(big.Rat).Mul(new(big.Rat).Abs(r), scale)
+
+	floor := new(big.Rat).SetInt(new(big.Int).Quo(scaled.Num(), scaled.Denom()))
+	if new(big.Rat).Sub(scaled, floor).Cmp(big.NewRat(1, 2)) >= 0 {
+		floor.Add(floor, big.NewRat(1, 1))
+	}
+	if r.Sign() < 0 {
+		floor.Neg(floor)
+	}
+	return floor.Quo(floor, scale)
+}
+
+func TestRoundHalfUpMatchesBigRat(t *testing.T) {
+	rnd := rand.New(rand.NewPCG(1, 2))
+
+	for i := 0; i < 100_000; i++ {
+		// a decimal amount with up to 6 places, as typed by a user
+		places := rnd.IntN(7)
+		s := strconv.FormatInt(rnd.Int64N(2_000_000_000)-1_000_000_000, 10)
+		if places > 0 {
+			s = fmt.Sprintf("%s.%0*d", s, places, rnd.IntN(int(pow10(places).Int64())))
+		}
+		precision := rnd.IntN(5)
+
+		value, err := strconv.ParseFloat(s, 64)
+		assert.NoError(t, err)
+
+		r, _ := new(big.Rat).SetString(s)
+		want, _ := roundRat(r, precision).Float64()
+
+		if got := RoundHalfUp(value, precision); got != want {
+			t.Fatalf("RoundHalfUp(%s, %d) = %v, want %v", s, precision, got, want)
+		}
+	}
+}
+
+func TestSplitAmount(t *testing.T) {
+	split := func(total int64, parts int) []int64 {
+		res, err := SplitAmount(total, parts)
+		require.NoError(t, err)
+		return res
+	}
+
+	assert.Equal(t, []int64{34, 33, 33}, split(100, 3))
+	assert.Equal(t, []int64{-34, -33, -33}, split(-100, 3))
+	assert.Equal(t, []int64{1, 1, 0, 0}, split(2, 4))
+	assert.Equal(t, []int64{5}, split(5, 1))
+
+	for _, parts := range []int{0, -3} {
+		res, err := SplitAmount(5, parts)
+		assert.ErrorIs(t, err, ErrInvalidParts)
+		assert.Nil(t, res)
+	}
+}
+
+func TestSplitAmountProperties(t *testing.T) {
+	rnd := rand.New(rand.NewPCG(3, 4))
+
+	for i := 0; i < 10_000; i++ {
+		total := rnd.Int64N(2_000_000) - 1_000_000
+		parts := rnd.IntN(50) + 1
+
+		split, err := SplitAmount(total, parts)
+		require.NoError(t, err)
+
+		var sum int64
+		lo, hi := split[0], split[0]
+		for _, p := range split {
+			sum += p
+			lo, hi = min(lo, p), max(hi, p)
+		}
+		if sum != total || hi-lo > 1 {
+			t.Fatalf("SplitAmount(%d, %d) = %v", total, parts, split)
+		}
+	}
+}
+
+func TestMinorUnits(t *testing.T) {
+	tests := []struct {
+		amount   float64
+		currency string
+		minor    int64
+	}{
+		{19.99, "USD", 1999},
+		{0.145, "eur", 15},
+		{1500.5, "JPY", 1501},
+		{1.2345, "KWD", 1235},
+		{-4.35, "USD", -435},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.currency, func(t *testing.T) {
+			minor, err := ToMinorUnits(tt.amount, tt.currency)
+			require.NoError(t, err)
+			assert.Equal(t, tt.minor, minor)
+		})
+	}
+
+	for _, amount := range []float64{1e17, -1e17, math.MaxFloat64, math.Inf(1), math.NaN()} {
+		_, err := ToMinorUnits(amount, "USD")
+		assert.ErrorIs(t, err, ErrAmountOutOfRange, amount)
+	}
+	minor, err := ToMinorUnits(9e15, "JPY")
+	require.NoError(t, err)
+	assert.Equal(t, int64(9e15), minor)
+
+	assert.Equal(t, 19.99, FromMinorUnits(1999, "USD"))
+	assert.Equal(t, 1500.0, FromMinorUnits(1500, "JPY"))
+	assert.Equal(t, 1.235, FromMinorUnits(1235, "KWD"))
+	assert.Equal(t, 2, CurrencyExponent("XYZ"))
+}
@@ -22,6 +22,10 @@ func Round(num float64) int {
 // specified precision, rounds it to the nearest integer, and then divides it
 // back to achieve the desired precision.
 //
+// The multiplication is done on binary floats, so values such as 0.145 are rounded
+// down (to 0.14). Do not use ToFixed for money, use RoundHalfUp or amounts in minor
+// units, see ToMinorUnits.
+//
 // Parameters:
 //   - num: The floating-point number to be rounded.
 //   - precision: The number of decimal places to round to.
@@ -0,0 +1,103 @@
+package util
+
+import (
+	"math/rand/v2"
+	"sync"
+)
+
+// Random is a source of random numbers for RandomItem, Shuffle and Sample. A nil *Random
+// draws from the automatically seeded math/rand/v2 generator, SeededRandom returns one
+// repeating its draws, meant for tests. It is safe for concurrent use; it is not meant for
+// secrets.
+type Random struct {
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// SeededRandom creates a Random drawing the same numbers for the same seed.
+//
+// Parameters:
+//   - seed: The seed of the draws.
+//
+// Returns:
+//   - A new Random.
+func SeededRandom(seed int64) *Random {
+	return &Random{rand: rand.New(rand.NewPCG(uint64(seed), 0))}
+}
+
+// IntN draws an int in [0, n).
+//
+// Parameters:
+//   - n: The upper bound, it must be positive.
+//
+// Returns:
+//   - The drawn int.
+func (r *Random) IntN(n int) int {
+	if r == nil {
+		return rand.IntN(n)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rand.IntN(n)
+}
+
+// RandomItem selects a random item from a slice.
+//
+// Type Parameters:
+//   - T: The type of elements in the input slice.
+//
+// Parameters:
+//   - r: The source of the draw, nil for the automatically seeded one.
+//   - s: The slice from which an item is selected.
+//
+// Returns:
+//   - An element randomly selected from the slice.
+//   - False if the slice is empty, the element is the zero value then.
+func RandomItem[T any](r *Random, s []T) (T, bool) {
+	if len(s) == 0 {
+		var zero T
+		return zero, false
+	}
+	return s[r.IntN(len(s))], true
+}
+
+// Shuffle returns the elements of a slice in a random order, the input is left untouched.
+//
+// Type Parameters:
+//   - T: The type of elements in the input slice.
+//
+// Parameters:
+//   - r: The source of the draws, nil for the automatically seeded one.
+//   - s: The slice to shuffle.
+//
+// Returns:
+//   - A new slice holding the shuffled elements, empty but not nil for an empty input.
+func Shuffle[T any](r *Random, s []T) []T {
+	return Sample(r, s, len(s))
+}
+
+// Sample selects n distinct elements of a slice at random, e.g. a random page of items.
+//
+// Type Parameters:
+//   - T: The type of elements in the input slice.
+//
+// Parameters:
+//   - r: The source of the draws, nil for the automatically seeded one.
+//   - s: The slice to draw from, its elements are drawn once at most.
+//   - n: The number of elements, every element is returned when it exceeds the length.
+//
+// Returns:
+//   - A new slice holding the selected elements in the order they were drawn, empty but not
+//     nil when n is not positive.
+func Sample[T any](r *Random, s []T, n int) []T {
+	n = max(min(n, len(s)), 0)
+
+	// a partial Fisher-Yates shuffle of a copy, the first n elements are the sample
+	res := make([]T, len(s))
+	copy(res, s)
+	for i := range n {
+		j := i + r.IntN(len(res)-i)
+		res[i], res[j] = res[j], res[i]
+	}
+	return res[:n:n]
+}
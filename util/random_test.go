@@ -0,0 +1,103 @@
+package util
+
+import (
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRandomEmptyInput(t *testing.T) {
+	item, ok := GetRandomItem([]string{})
+	assert.False(t, ok)
+	assert.Empty(t, item)
+
+	item, ok = RandomItem(SeededRandom(1), []string(nil))
+	assert.False(t, ok)
+	assert.Empty(t, item)
+
+	for _, res := range [][]int{Shuffle[int](nil, nil), Sample[int](nil, nil, 3), Sample(nil, []int{1, 2}, 0), Sample(nil, []int{1, 2}, -1)} {
+		assert.NotNil(t, res)
+		assert.Empty(t, res)
+	}
+}
+
+func TestSeededRandomIsDeterministic(t *testing.T) {
+	items := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+
+	draw := func(r *Random) []string {
+		var res []string
+		for range 5 {
+			item, _ := RandomItem(r, items)
+			res = append(res, item)
+		}
+		res = append(res, Shuffle(r, items)...)
+		return append(res, Sample(r, items, 3)...)
+	}
+
+	first := draw(SeededRandom(42))
+	assert.Equal(t, first, draw(SeededRandom(42)))
+	assert.NotEqual(t, first, draw(SeededRandom(43)))
+}
+
+func TestShuffleAndSample(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5, 6}
+	r := SeededRandom(7)
+
+	shuffled := Shuffle(r, items)
+	assert.ElementsMatch(t, items, shuffled)
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6}, items, "the input is untouched")
+
+	sample := Sample(r, items, 4)
+	require.Len(t, sample, 4)
+	assert.Len(t, Unique(sample), 4, "the elements are drawn once")
+	for _, v := range sample {
+		assert.Contains(t, items, v)
+	}
+
+	assert.ElementsMatch(t, items, Sample(r, items, 10))
+}
+
+// TestRandomUniformity checks that every item is drawn about as often as the others.
+func TestRandomUniformity(t *testing.T) {
+	items := []int{0, 1, 2, 3}
+	const draws = 40000
+
+	for name, r := range map[string]*Random{"global": nil, "seeded": SeededRandom(3)} {
+		t.Run(name, func(t *testing.T) {
+			counts := make([]int, len(items))
+			firsts := make([]int, len(items))
+			for range draws {
+				item, _ := RandomItem(r, items)
+				counts[item]++
+				firsts[Sample(r, items, 2)[0]]++
+			}
+			for i := range items {
+				assert.InDelta(t, draws/len(items), counts[i], draws/50, "item %d", i)
+				assert.InDelta(t, draws/len(items), firsts[i], draws/50, "first sampled %d", i)
+			}
+		})
+	}
+}
+
+func TestRandomConcurrently(t *testing.T) {
+	r := SeededRandom(5)
+	items := []int{1, 2, 3}
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				_, _ = RandomItem(r, items)
+				_ = Shuffle(r, items)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.True(t, slices.Equal(items, []int{1, 2, 3}))
+}
@@ -1,17 +1,17 @@
 package util
 
 import (
-	"math/rand"
-	"time"
+	"crypto/sha256"
+	"crypto/subtle"
+	"strings"
 )
 
-var letterRunes = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789" // Characters used for generating random keys.
-var numberRunes = "0123456789"                                                     // Characters used for generating random numbers.
+const letterRunes = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789" // Characters used for generating random keys.
+const numberRunes = "0123456789"                                                     // Characters used for generating random numbers.
 
 // GenerateKey generates a random alphanumeric string of the specified length.
 //
-// This function uses a seeded random number generator to create a string
-// consisting of characters from the `letterRunes` set.
+// The characters are drawn from the `letterRunes` set with crypto/rand.
 //
 // Parameters:
 //   - n: The length of the key to be generated.
@@ -19,18 +19,13 @@ var numberRunes = "0123456789"
 // Returns:
 //   - A string containing the randomly generated key.
 func GenerateKey(n int) string {
-	var seededRand *rand.Rand = rand.New(rand.NewSource(time.Now().UnixNano()))
-	b := make([]byte, n)
-	for i := range b {
-		b[i] = letterRunes[seededRand.Intn(len(letterRunes))]
-	}
-	return string(b)
+	return randomString(letterRunes, n)
 }
 
 // GenerateRandomNumber generates a random numeric string of the specified length.
 //
-// This function uses a seeded random number generator to create a string
-// consisting of characters from the `numberRunes` set.
+// The digits are drawn from the `numberRunes` set with crypto/rand, so the value
+// can be used as a one time code.
 //
 // Parameters:
 //   - n: The length of the numeric string to be generated.
@@ -38,10 +33,40 @@ func GenerateKey(n int) string {
 // Returns:
 //   - A string containing the randomly generated numeric value.
 func GenerateRandomNumber(n int) string {
-	var seededRand *rand.Rand = rand.New(rand.NewSource(time.Now().UnixNano()))
-	b := make([]byte, n)
-	for i := range b {
-		b[i] = numberRunes[seededRand.Intn(len(numberRunes))]
-	}
-	return string(b)
+	return randomString(numberRunes, n)
+}
+
+// SecureCompare reports whether a and b are equal in constant time.
+//
+// Use it instead of == for CSRF secrets, API keys and other secrets, the time it
+// takes depends neither on where the strings differ nor on their lengths, as both
+// are hashed before being compared.
+//
+// Parameters:
+//   - a: The first string.
+//   - b: The second string.
+//
+// Returns:
+//   - A boolean value indicating whether the strings are equal.
+func SecureCompare(a, b string) bool {
+	ha := sha256.Sum256([]byte(a))
+	hb := sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
+
+// MaskSecret masks a secret so it can be logged, keeping only its last characters.
+//
+// At most half of the secret is kept, so a short secret is never revealed by a
+// large `visible` value.
+//
+// Parameters:
+//   - s: The secret to mask.
+//   - visible: The number of trailing characters to keep.
+//
+// Returns:
+//   - A string of the length of the secret, with the masked characters replaced by '*'.
+func MaskSecret(s string, visible int) string {
+	runes := []rune(s)
+	visible = max(0, min(visible, len(runes)/2))
+	return strings.Repeat("*", len(runes)-visible) + string(runes[len(runes)-visible:])
 }
@@ -0,0 +1,49 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecureCompare(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want bool
+	}{
+		{"equal", "s3cr3t-csrf", "s3cr3t-csrf", true},
+		{"empty", "", "", true},
+		{"unequal", "s3cr3t-csrf", "s3cr3t-csrg", false},
+		{"different lengths", "s3cr3t", "s3cr3t-csrf", false},
+		{"one empty", "", "s3cr3t", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, SecureCompare(tt.a, tt.b))
+		})
+	}
+}
+
+func TestMaskSecret(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		visible int
+		want    string
+	}{
+		{"keeps the last characters", "sk_live_1234567890", 4, "**************7890"},
+		{"masks everything", "sk_live_1234567890", 0, "******************"},
+		{"negative visible", "secret", -2, "******"},
+		{"keeps at most half", "secret", 10, "***ret"},
+		{"multibyte", "رمزعبور", 2, "*****ور"},
+		{"empty", "", 4, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, MaskSecret(tt.s, tt.visible))
+		})
+	}
+}
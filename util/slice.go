@@ -1,10 +1,5 @@
 package util
 
-import (
-	"math/rand"
-	"time"
-)
-
 // ToSliceAny converts a slice of any type to a slice of empty interface values.
 //
 // This function iterates over the input slice and appends each element
@@ -28,8 +23,9 @@ func ToSliceAny[T any](objs []T) []any {
 
 // GetRandomItem selects a random item from a slice.
 //
-// This function seeds the random number generator with the current time
-// and generates a random index to pick an element from the input slice.
+// The index is drawn from the automatically seeded math/rand/v2 generator, which is
+// safe for concurrent use; it is not meant for secrets. Tests wanting a repeatable pick
+// use RandomItem with a SeededRandom.
 //
 // Type Parameters:
 //   - T: The type of elements in the input slice.
@@ -39,9 +35,121 @@ func ToSliceAny[T any](objs []T) []any {
 //
 // Returns:
 //   - An element of type `T` randomly selected from the input slice.
-func GetRandomItem[T any](slice []T) T {
-	rand.Seed(time.Now().UnixNano())     // seed or it will be set to 1
-	randomIndex := rand.Intn(len(slice)) // generate a random int in the range 0 to len(slice)-1
-	pick := slice[randomIndex]           // get the value from the slice
-	return pick
+//   - False if the slice is empty, the element is the zero value then.
+func GetRandomItem[T any](slice []T) (T, bool) {
+	return RandomItem(nil, slice)
+}
+
+// Contains checks if a slice contains a specific value.
+//
+// Type Parameters:
+//   - T: The type of elements in the input slice.
+//
+// Parameters:
+//   - s: The slice to search.
+//   - e: The value to look for.
+//
+// Returns:
+//   - A boolean value indicating whether the value is found in the slice.
+func Contains[T comparable](s []T, e T) bool {
+	for _, a := range s {
+		if a == e {
+			return true
+		}
+	}
+	return false
+}
+
+// Map applies a function to every element of a slice.
+//
+// Type Parameters:
+//   - T: The type of elements in the input slice.
+//   - R: The type of elements in the returned slice.
+//
+// Parameters:
+//   - s: The slice to transform.
+//   - fn: The function applied to each element.
+//
+// Returns:
+//   - A slice holding the results in the order of the input, empty but not nil for an empty input.
+func Map[T, R any](s []T, fn func(T) R) []R {
+	res := make([]R, len(s))
+	for i, a := range s {
+		res[i] = fn(a)
+	}
+	return res
+}
+
+// Filter keeps the elements of a slice for which a predicate returns true.
+//
+// Type Parameters:
+//   - T: The type of elements in the input slice.
+//
+// Parameters:
+//   - s: The slice to filter.
+//   - keep: The predicate deciding whether an element is kept.
+//
+// Returns:
+//   - A new slice holding the kept elements in their original order, empty but not nil when none is kept.
+func Filter[T any](s []T, keep func(T) bool) []T {
+	res := make([]T, 0)
+	for _, a := range s {
+		if keep(a) {
+			res = append(res, a)
+		}
+	}
+	return res
+}
+
+// Unique removes the duplicated elements of a slice.
+//
+// Type Parameters:
+//   - T: The type of elements in the input slice.
+//
+// Parameters:
+//   - s: The slice to deduplicate.
+//
+// Returns:
+//   - A new slice holding the first occurrence of each element in its original order.
+func Unique[T comparable](s []T) []T {
+	seen := make(map[T]struct{}, len(s))
+	res := make([]T, 0, len(s))
+	for _, a := range s {
+		if _, ok := seen[a]; ok {
+			continue
+		}
+		seen[a] = struct{}{}
+		res = append(res, a)
+	}
+	return res
+}
+
+// Chunk splits a slice into consecutive chunks of the given size, e.g. to insert rows in batches.
+//
+// The chunks share the backing array of the input slice, the last one may be shorter.
+// Chunk panics when size is not positive.
+//
+// Type Parameters:
+//   - T: The type of elements in the input slice.
+//
+// Parameters:
+//   - s: The slice to split.
+//   - size: The maximum number of elements of a chunk.
+//
+// Returns:
+//   - The chunks, empty but not nil for an empty input.
+func Chunk[T any](s []T, size int) [][]T {
+	if size <= 0 {
+		panic("util: chunk size must be positive")
+	}
+
+	res := make([][]T, 0, (len(s)+size-1)/size)
+	for size < len(s) {
+		res = append(res, s[:size:size])
+		s = s[size:]
+	}
+	if len(s) > 0 {
+		res = append(res, s)
+	}
+	return res
 }
@@ -0,0 +1,60 @@
+package util
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContains(t *testing.T) {
+	assert.True(t, Contains([]string{"image/png", "image/jpeg"}, "image/jpeg"))
+	assert.False(t, Contains([]string{"image/png"}, "image/gif"))
+	assert.False(t, Contains([]int(nil), 1))
+
+	assert.True(t, ContainsStr([]string{"a"}, "a"))
+	assert.True(t, ContainsInt([]int{1, 2}, 2))
+}
+
+func TestMap(t *testing.T) {
+	assert.Equal(t, []string{"1", "2", "3"}, Map([]int{1, 2, 3}, strconv.Itoa))
+
+	res := Map([]int(nil), strconv.Itoa)
+	assert.NotNil(t, res)
+	assert.Empty(t, res)
+}
+
+func TestFilter(t *testing.T) {
+	even := func(i int) bool { return i%2 == 0 }
+
+	assert.Equal(t, []int{2, 4}, Filter([]int{1, 2, 3, 4}, even))
+
+	res := Filter([]int{1, 3}, even)
+	assert.NotNil(t, res)
+	assert.Empty(t, res)
+	assert.Empty(t, Filter([]int(nil), even))
+}
+
+func TestUnique(t *testing.T) {
+	assert.Equal(t, []string{"b", "a", "c"}, Unique([]string{"b", "a", "b", "c", "a"}))
+
+	res := Unique([]string(nil))
+	assert.NotNil(t, res)
+	assert.Empty(t, res)
+}
+
+func TestChunk(t *testing.T) {
+	assert.Equal(t, [][]int{{1, 2}, {3, 4}, {5}}, Chunk([]int{1, 2, 3, 4, 5}, 2))
+	assert.Equal(t, [][]int{{1, 2}}, Chunk([]int{1, 2}, 5))
+
+	res := Chunk([]int(nil), 3)
+	assert.NotNil(t, res)
+	assert.Empty(t, res)
+
+	// appending to a chunk does not overwrite the next one
+	chunks := Chunk([]int{1, 2, 3, 4}, 2)
+	_ = append(chunks[0], 9)
+	assert.Equal(t, []int{3, 4}, chunks[1])
+
+	assert.PanicsWithValue(t, "util: chunk size must be positive", func() { Chunk([]int{1}, 0) })
+}
@@ -38,8 +38,7 @@ func NormalizeDomainName(domain string) string {
 
 // ContainsStr checks if a slice of strings contains a specific string.
 //
-// This function iterates over the slice and returns true if the specified
-// string is found.
+// Deprecated: Use Contains.
 //
 // Parameters:
 //   - s: The slice of strings to search.
@@ -48,18 +47,12 @@ func NormalizeDomainName(domain string) string {
 // Returns:
 //   - A boolean value indicating whether the string is found in the slice.
 func ContainsStr(s []string, e string) bool {
-	for _, a := range s {
-		if a == e {
-			return true
-		}
-	}
-	return false
+	return Contains(s, e)
 }
 
 // ContainsInt checks if a slice of integers contains a specific integer.
 //
-// This function iterates over the slice and returns true if the specified
-// integer is found.
+// Deprecated: Use Contains.
 //
 // Parameters:
 //   - s: The slice of integers to search.
@@ -68,10 +61,55 @@ func ContainsStr(s []string, e string) bool {
 // Returns:
 //   - A boolean value indicating whether the integer is found in the slice.
 func ContainsInt(s []int, e int) bool {
-	for _, a := range s {
-		if a == e {
-			return true
-		}
+	return Contains(s, e)
+}
+
+// irregularPlurals are the English nouns whose plural does not follow the suffix rules of Pluralize.
+var irregularPlurals = map[string]string{
+	"person": "people", "child": "children", "man": "men", "woman": "women", "mouse": "mice",
+	"goose": "geese", "foot": "feet", "tooth": "teeth", "ox": "oxen", "leaf": "leaves",
+	"knife": "knives", "life": "lives", "wife": "wives", "half": "halves", "shelf": "shelves",
+	"wolf": "wolves", "calf": "calves", "index": "indices", "matrix": "matrices", "vertex": "vertices",
+	"criterion": "criteria", "phenomenon": "phenomena", "quiz": "quizzes",
+}
+
+// uncountableNouns are the English nouns whose plural is the noun itself.
+var uncountableNouns = map[string]bool{
+	"data": true, "metadata": true, "equipment": true, "information": true, "feedback": true,
+	"news": true, "money": true, "series": true, "species": true, "sheep": true, "fish": true,
+	"staff": true, "media": true, "software": true, "inventory": true,
+}
+
+// Pluralize returns the English plural of a noun in snake case, pluralizing its last word, e.g.
+// "product_categories" for "product_category" or "boxes" for "box". It knows the common
+// irregular and uncountable nouns and applies the suffix rules to the others.
+//
+// Parameters:
+//   - word: The noun in snake case.
+//
+// Returns:
+//   - The plural of the noun.
+func Pluralize(word string) string {
+	i := strings.LastIndex(word, "_") + 1
+	prefix, last := word[:i], strings.ToLower(word[i:])
+
+	if last == "" || uncountableNouns[last] {
+		return word
+	}
+	if plural, ok := irregularPlurals[last]; ok {
+		return prefix + plural
+	}
+
+	switch {
+	case strings.HasSuffix(last, "y") && len(last) > 1 && !strings.ContainsRune("aeiou", rune(last[len(last)-2])):
+		return prefix + last[:len(last)-1] + "ies"
+	case strings.HasSuffix(last, "is") && len(last) > 3:
+		// analysis, crisis, thesis
+		return prefix + last[:len(last)-2] + "es"
+	case strings.HasSuffix(last, "s"), strings.HasSuffix(last, "x"), strings.HasSuffix(last, "z"),
+		strings.HasSuffix(last, "ch"), strings.HasSuffix(last, "sh"):
+		return prefix + last + "es"
+	default:
+		return prefix + last + "s"
 	}
-	return false
 }
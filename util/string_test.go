@@ -0,0 +1,30 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPluralize(t *testing.T) {
+	for word, want := range map[string]string{
+		"order":            "orders",
+		"category":         "categories",
+		"product_category": "product_categories",
+		"day":              "days",
+		"box":              "boxes",
+		"address":          "addresses",
+		"status":           "statuses",
+		"branch":           "branches",
+		"wish":             "wishes",
+		"analysis":         "analyses",
+		"person":           "people",
+		"order_item":       "order_items",
+		"sales_person":     "sales_people",
+		"shelf":            "shelves",
+		"metadata":         "metadata",
+		"user_feedback":    "user_feedback",
+	} {
+		assert.Equal(t, want, Pluralize(word), word)
+	}
+}
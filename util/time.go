@@ -1,7 +1,10 @@
 package util
 
 import (
+	"errors"
+	"fmt"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -38,3 +41,75 @@ func ParseUnixTimestamp(timestamp int64) *time.Time {
 	// Return nil if the timestamp is invalid.
 	return nil
 }
+
+// ErrInvalidTime is returned by ParseFlexibleTime when the input matches none of its formats.
+var ErrInvalidTime = errors.New("invalid time")
+
+// flexibleTimeLayouts are the layouts tried by ParseFlexibleTime, in order.
+var flexibleTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	time.DateOnly,
+}
+
+// ParseFlexibleTime parses a time sent by a client in one of the common formats.
+//
+// The accepted formats are RFC 3339 (with or without fractional seconds), unix seconds
+// or milliseconds as a string of digits (13 digits or more are milliseconds), and the
+// "2006-01-02T15:04:05", "2006-01-02 15:04:05" and "2006-01-02" layouts, which carry no
+// zone and are read as UTC.
+//
+// Parameters:
+//   - s: The time to be parsed, leading and trailing spaces are ignored.
+//
+// Returns:
+//   - The parsed time.
+//   - An error wrapping ErrInvalidTime if s matches none of the formats.
+func ParseFlexibleTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+
+	if isDigits(s) {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: %q", ErrInvalidTime, s)
+		}
+		if len(s) >= 13 {
+			return time.UnixMilli(n).UTC(), nil
+		}
+		return time.Unix(n, 0).UTC(), nil
+	}
+
+	for _, layout := range flexibleTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("%w: %q", ErrInvalidTime, s)
+}
+
+// FormatRFC3339UTC formats a time as RFC 3339 in UTC, e.g. "2025-06-01T12:30:00Z", so
+// the times written by instances running in different time zones line up.
+//
+// Parameters:
+//   - t: The time to be formatted.
+//
+// Returns:
+//   - A string representing the time in UTC.
+func FormatRFC3339UTC(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// isDigits reports whether s is a non-empty string of ASCII digits.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFlexibleTime(t *testing.T) {
+	want := time.Date(2025, 6, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		input string
+		want  time.Time
+	}{
+		{"rfc3339 utc", "2025-06-01T12:30:00Z", want},
+		{"rfc3339 offset", "2025-06-01T16:00:00+03:30", want},
+		{"rfc3339 fraction", "2025-06-01T12:30:00.250Z", want.Add(250 * time.Millisecond)},
+		{"unix seconds", "1748781000", want},
+		{"unix milliseconds", "1748781000250", want.Add(250 * time.Millisecond)},
+		{"local layout", "2025-06-01T12:30:00", want},
+		{"space layout", "2025-06-01 12:30:00", want},
+		{"date only", "2025-06-01", time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{"spaces", " 2025-06-01 ", time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFlexibleTime(tt.input)
+			require.NoError(t, err)
+			assert.True(t, tt.want.Equal(got), "got %s, want %s", got, tt.want)
+		})
+	}
+}
+
+func TestParseFlexibleTimeInvalid(t *testing.T) {
+	for _, input := range []string{"", "yesterday", "2025-13-01", "01/06/2025", "99999999999999999999"} {
+		_, err := ParseFlexibleTime(input)
+		assert.ErrorIs(t, err, ErrInvalidTime, input)
+	}
+}
+
+func TestFormatRFC3339UTC(t *testing.T) {
+	tehran := time.FixedZone("IRST", 3*3600+1800)
+	assert.Equal(t, "2025-06-01T12:30:00Z", FormatRFC3339UTC(time.Date(2025, 6, 1, 16, 0, 0, 0, tehran)))
+}
+
+func TestFrozenClock(t *testing.T) {
+	start := time.Date(2025, 6, 1, 12, 30, 0, 0, time.UTC)
+	clock := NewFrozenClock(start)
+
+	assert.Equal(t, start, clock.Now())
+
+	clock.Advance(time.Hour)
+	assert.Equal(t, start.Add(time.Hour), clock.Now())
+
+	clock.Set(start)
+	assert.Equal(t, start, clock.Now())
+}
+
+func TestSystemClock(t *testing.T) {
+	before := time.Now()
+	now := SystemClock.Now()
+	assert.False(t, now.Before(before))
+}
@@ -0,0 +1,230 @@
+package validator
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a-aslani/wotop/model/payload"
+	"github.com/gin-gonic/gin"
+)
+
+// durationType is used to parse the time.Duration parameters with time.ParseDuration.
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// ValidateQuery binds the query parameters of a request to the fields of dest with a form tag
+// and validates it with the rules of its validate tags, e.g. for ?page=2&limit=50:
+//
+//	type ListRequest struct {
+//		Page  int `form:"page" default:"1" validate:"min:1"`
+//		Limit int `form:"limit" default:"20" validate:"min:1,max:100"`
+//	}
+//
+// A missing or empty parameter is given the value of the default tag of its field, before the
+// validation. A value that cannot be converted to the type of its field is reported with
+// ErrInvalidValue and the other rules of the field are skipped.
+//
+// Parameters:
+//   - c: The Gin context of the request.
+//   - dest: A pointer to the struct to fill.
+//
+// Returns:
+//   - A *payload.ValidationError listing the invalid parameters, answered as a validation error
+//     response by payload.WriteError.
+//   - ErrInvalidTypeInputData if dest is not a pointer to a struct.
+func ValidateQuery(c *gin.Context, dest any) error {
+	return bindAndValidate(dest, "form", c.Request.URL.Query())
+}
+
+// ValidateURI binds the path parameters of a request to the fields of dest with a uri tag and
+// validates it, as ValidateQuery does, e.g. uri:"id" for the route /orders/:id.
+//
+// Parameters:
+//   - c: The Gin context of the request.
+//   - dest: A pointer to the struct to fill.
+//
+// Returns:
+//   - A *payload.ValidationError listing the invalid parameters.
+//   - ErrInvalidTypeInputData if dest is not a pointer to a struct.
+func ValidateURI(c *gin.Context, dest any) error {
+	params := make(map[string][]string, len(c.Params))
+	for _, p := range c.Params {
+		params[p.Key] = []string{p.Value}
+	}
+	return bindAndValidate(dest, "uri", params)
+}
+
+// bindAndValidate sets the fields of dest tagged with tag from the values and validates it.
+//
+// Parameters:
+//   - dest: A pointer to the struct to fill.
+//   - tag: The tag naming the value of a field, form or uri.
+//   - values: The values keyed by name.
+//
+// Returns:
+//   - A *payload.ValidationError listing the invalid fields.
+//   - ErrInvalidTypeInputData if dest is not a pointer to a struct.
+func bindAndValidate(dest any, tag string, values map[string][]string) error {
+
+	val := reflect.ValueOf(dest)
+	if val.Kind() != reflect.Ptr || val.IsNil() || val.Elem().Kind() != reflect.Struct {
+		return ErrInvalidTypeInputData
+	}
+	val = val.Elem()
+
+	vld := New()
+
+	for i := 0; i < val.NumField(); i++ {
+
+		f := val.Type().Field(i)
+		key, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+		if !f.IsExported() || key == "" || key == "-" {
+			continue
+		}
+
+		raw, ok := values[key]
+		if !ok || len(raw) == 0 || (len(raw) == 1 && raw[0] == "" && f.Type.Kind() != reflect.String) {
+			def, hasDefault := f.Tag.Lookup("default")
+			if !hasDefault {
+				continue
+			}
+			raw = []string{def}
+			if f.Type.Kind() == reflect.Slice {
+				raw = strings.Split(def, ",")
+			}
+		}
+
+		if bad, err := setField(val.Field(i), raw); err != nil {
+			name := fieldName(f)
+			e := ErrInvalidValue.Var(name, bad, expectedType(f.Type))
+
+			msg := Message{
+				FieldName: name,
+				Code:      e.Code(),
+				Message:   e.Error(),
+				Rule:      "type",
+				Param:     expectedType(f.Type),
+			}
+			if !isSensitive(f) {
+				msg.Value = bad
+			}
+			vld.Errors = append(vld.Errors, msg)
+		}
+	}
+
+	// the fields which cannot be converted already have an error, the validator skips them
+	if _, err := vld.Validate(dest); err != nil {
+		return err
+	}
+
+	if len(vld.Errors) > 0 {
+		messages := make([]Message, len(vld.Errors))
+		for i, e := range vld.Errors {
+			messages[i] = e.(Message)
+		}
+		return &payload.ValidationError{Messages: messages}
+	}
+
+	return nil
+}
+
+// setField converts the values to the type of a field and sets it, a slice takes all the values
+// and the other types the first one.
+//
+// Parameters:
+//   - field: The field to set.
+//   - raw: The values of the field.
+//
+// Returns:
+//   - The value that cannot be converted.
+//   - An error if a value cannot be converted to the type of the field.
+func setField(field reflect.Value, raw []string) (string, error) {
+
+	if field.Kind() == reflect.Slice {
+		slice := reflect.MakeSlice(field.Type(), len(raw), len(raw))
+		for i, s := range raw {
+			if err := setValue(slice.Index(i), s); err != nil {
+				return s, err
+			}
+		}
+		field.Set(slice)
+		return "", nil
+	}
+
+	if err := setValue(field, raw[0]); err != nil {
+		return raw[0], err
+	}
+	return "", nil
+}
+
+// setValue converts a value to the type of a field and sets it, a pointer field is allocated.
+//
+// Parameters:
+//   - field: The field to set.
+//   - s: The value.
+//
+// Returns:
+//   - An error if the value cannot be converted to the type of the field.
+func setValue(field reflect.Value, s string) error {
+
+	if field.Kind() == reflect.Ptr {
+		ptr := reflect.New(field.Type().Elem())
+		if err := setValue(ptr.Elem(), s); err != nil {
+			return err
+		}
+		field.Set(ptr)
+		return nil
+	}
+
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(n)
+	default:
+		return ErrInvalidTypeInputData
+	}
+
+	return nil
+}
+
+// expectedType describes the type of a field in the ErrInvalidValue errors, e.g. "int" for
+// []int or *int.
+func expectedType(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	return t.String()
+}
@@ -0,0 +1,163 @@
+package validator
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop/model/payload"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type listOrdersRequest struct {
+	Page     int           `form:"page" default:"1" validate:"min:1"`
+	Limit    int           `form:"limit" default:"20" validate:"min:1,max:100"`
+	Status   []string      `form:"status" default:"open,paid"`
+	Discount float64       `form:"discount" validate:"max:0.5"`
+	Archived *bool         `form:"archived"`
+	Since    time.Duration `form:"since" default:"24h"`
+	Customer string        `form:"customer" validate:"digits"`
+}
+
+// serve runs handle for a request to the route and returns the response.
+func serve(t *testing.T, route, target string, handle func(c *gin.Context) error) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	engine := gin.New()
+	engine.GET(route, func(c *gin.Context) {
+		if err := handle(c); err != nil {
+			payload.WriteError(c, err, "trace")
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	return w
+}
+
+// validationMessages returns the messages of a *payload.ValidationError.
+func validationMessages(t *testing.T, err error) []Message {
+	t.Helper()
+
+	var ve *payload.ValidationError
+	require.ErrorAs(t, err, &ve)
+	return ve.Messages
+}
+
+func TestValidateQueryDefaults(t *testing.T) {
+	var req listOrdersRequest
+	w := serve(t, "/orders", "/orders?limit=&archived=true", func(c *gin.Context) error {
+		return ValidateQuery(c, &req)
+	})
+
+	require.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, 1, req.Page)
+	assert.Equal(t, 20, req.Limit, "an empty parameter takes the default")
+	assert.Equal(t, []string{"open", "paid"}, req.Status)
+	assert.Equal(t, 24*time.Hour, req.Since)
+	require.NotNil(t, req.Archived)
+	assert.True(t, *req.Archived)
+}
+
+func TestValidateQueryValues(t *testing.T) {
+	var req listOrdersRequest
+	w := serve(t, "/orders", "/orders?page=3&limit=100&status=shipped&status=paid&discount=0.25&customer=0042&since=90m", func(c *gin.Context) error {
+		return ValidateQuery(c, &req)
+	})
+
+	require.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, listOrdersRequest{
+		Page:     3,
+		Limit:    100,
+		Status:   []string{"shipped", "paid"},
+		Discount: 0.25,
+		Since:    90 * time.Minute,
+		Customer: "0042",
+	}, req)
+}
+
+func TestValidateQueryCoercionFailures(t *testing.T) {
+	var err error
+	w := serve(t, "/orders", "/orders?page=abc&limit=-5&archived=maybe&since=tomorrow", func(c *gin.Context) error {
+		var req listOrdersRequest
+		err = ValidateQuery(c, &req)
+		return err
+	})
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{
+		"success": false,
+		"error_code": "BAD_REQUEST",
+		"error_message": "validation failed",
+		"trace_id": "trace",
+		"data": {"errors": [
+			{"field_name": "page", "code": "ER0007", "message": "page has the invalid value \"abc\", expected int", "rule": "type", "param": "int", "value": "abc"},
+			{"field_name": "archived", "code": "ER0007", "message": "archived has the invalid value \"maybe\", expected bool", "rule": "type", "param": "bool", "value": "maybe"},
+			{"field_name": "since", "code": "ER0007", "message": "since has the invalid value \"tomorrow\", expected time.Duration", "rule": "type", "param": "time.Duration", "value": "tomorrow"},
+			{"field_name": "limit", "code": "ER0012", "message": "limit must be 1 or greater", "rule": "min", "param": "1", "value": "-5"}
+		]}
+	}`, w.Body.String())
+	assert.Len(t, validationMessages(t, err), 4, "a field which cannot be converted is not validated")
+}
+
+func TestValidateQueryRangeViolations(t *testing.T) {
+	tests := map[string]Message{
+		"/orders?page=0":                      {FieldName: "page", Code: "ER0012", Message: "page must be 1 or greater", Rule: "min", Param: "1", Value: "0"},
+		"/orders?limit=101":                   {FieldName: "limit", Code: "ER0013", Message: "limit must be 100 or less", Rule: "max", Param: "100", Value: "101"},
+		"/orders?discount=0.75":               {FieldName: "discount", Code: "ER0013", Message: "discount must be 0.5 or less", Rule: "max", Param: "0.5", Value: "0.75"},
+		"/orders?customer=12a":                {FieldName: "customer", Code: "ER0009", Message: "customer must contain only the digits 0-9", Rule: "digits", Value: "12a"},
+		"/orders?limit=999999999999999999999": {FieldName: "limit", Code: "ER0007", Message: `limit has the invalid value "999999999999999999999", expected int`, Rule: "type", Param: "int", Value: "999999999999999999999"},
+	}
+
+	for target, want := range tests {
+		t.Run(target, func(t *testing.T) {
+			var err error
+			serve(t, "/orders", target, func(c *gin.Context) error {
+				var req listOrdersRequest
+				err = ValidateQuery(c, &req)
+				return err
+			})
+			assert.Equal(t, []Message{want}, validationMessages(t, err))
+		})
+	}
+}
+
+type getOrderRequest struct {
+	ID   string `uri:"id" validate:"required,len:4"`
+	Line uint8  `uri:"line" validate:"min:1"`
+}
+
+func TestValidateURI(t *testing.T) {
+	var req getOrderRequest
+	w := serve(t, "/orders/:id/lines/:line", "/orders/A-42/lines/3", func(c *gin.Context) error {
+		return ValidateURI(c, &req)
+	})
+	require.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, getOrderRequest{ID: "A-42", Line: 3}, req)
+
+	var err error
+	serve(t, "/orders/:id/lines/:line", "/orders/A-4242/lines/300", func(c *gin.Context) error {
+		err = ValidateURI(c, &getOrderRequest{})
+		return err
+	})
+	// the conversion errors come first
+	assert.Equal(t, []Message{
+		{FieldName: "line", Code: "ER0007", Message: `line has the invalid value "300", expected uint8`, Rule: "type", Param: "uint8", Value: "300"},
+		{FieldName: "id", Code: "ER0011", Message: "the length of id must be exactly 4. You entered 6", Rule: "len", Param: "4", Value: "A-4242"},
+	}, validationMessages(t, err))
+}
+
+func TestValidateQueryInvalidDestination(t *testing.T) {
+	var err error
+	serve(t, "/orders", "/orders", func(c *gin.Context) error {
+		err = ValidateQuery(c, listOrdersRequest{})
+		return err
+	})
+	assert.ErrorIs(t, err, ErrInvalidTypeInputData)
+}
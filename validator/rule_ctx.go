@@ -0,0 +1,155 @@
+package validator
+
+//go:generate go run go.uber.org/mock/mockgen -destination mocks/repository_mock.go -package mockvalidator ./ Repository
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/a-aslani/wotop/model/apperror"
+)
+
+// RuleCtxFunc is a rule registered with RegisterRuleCtx.
+//
+// Parameters:
+//   - ctx: The context given to ValidateCtx, context.Background() with Validate.
+//   - name: The name of the field in the validation errors.
+//   - field: The value of the field.
+//   - param: The parameters of the rule in the validate tag, joined by colons, e.g.
+//     "users:email" for "unique:users:email".
+//
+// Returns:
+//   - The validation error of the field, nil when it is valid. Its field name is set when
+//     empty, and its rule, parameter and value are set as for the built-in rules.
+type RuleCtxFunc func(ctx context.Context, name string, field reflect.Value, param string) *Message
+
+// builtinRules are the names of the rules compiled by compileRules, they cannot be registered.
+var builtinRules = []string{"required", "email", "min", "max", "digits", "numeric", "len", "password_strength"}
+
+// ctxRules holds the RuleCtxFunc of the registered rules by name.
+var ctxRules sync.Map
+
+// RegisterRuleCtx registers a rule the validate tags can use by its name, e.g. a rule querying
+// a repository such as Unique. The rule gets the context of ValidateCtx, which
+// HttpRequestValidator calls with the context of the request, so a repository call shares its
+// deadline and its trace ID. The built-in rules ignore the context. Registering a name again
+// replaces its rule.
+//
+// Parameters:
+//   - name: The name of the rule in the validate tags, e.g. "unique".
+//   - fn: The rule.
+func RegisterRuleCtx(name string, fn RuleCtxFunc) {
+	for _, builtin := range builtinRules {
+		if name == builtin {
+			panic(fmt.Sprintf("validator: %q is a built-in rule", name))
+		}
+	}
+	ctxRules.Store(name, fn)
+}
+
+// ruleCtx runs the registered rule of a name on a field, the unknown rules are ignored.
+func (v *validator) ruleCtx(ruleName, name string, field reflect.Value, param string) {
+
+	fn, ok := ctxRules.Load(ruleName)
+	if !ok {
+		return
+	}
+
+	msg := fn.(RuleCtxFunc)(v.ctx, name, field, param)
+	if msg == nil {
+		return
+	}
+
+	if msg.FieldName == "" {
+		msg.FieldName = name
+	}
+	v.Errors = append(v.Errors, *msg)
+}
+
+// Repository looks up the values checked by the rules of Unique and Exists, e.g. the emails of
+// the users in a database.
+type Repository interface {
+	// Exists reports whether a record has the value.
+	//
+	// Parameters:
+	//   - ctx: The context of the validation.
+	//   - table: The table, or collection, of the records, e.g. "users".
+	//   - column: The column holding the value, e.g. "email".
+	//   - value: The value of the field.
+	//
+	// Returns:
+	//   - Whether a record has the value.
+	//   - An error if the repository cannot be queried.
+	Exists(ctx context.Context, table, column, value string) (bool, error)
+}
+
+// Unique returns a rule rejecting the values a record of the repository already has, e.g.
+// "Email must not already be registered":
+//
+//	validator.RegisterRuleCtx("unique", validator.Unique(userRepo))
+//
+//	type RegisterRequest struct {
+//		Email string `json:"email" validate:"required,email,unique:users:email"`
+//	}
+//
+// The parameter of the rule is the table and the column, the column being the name of the
+// field when it is omitted. The empty values are accepted, as required rejects them.
+//
+// Parameters:
+//   - repo: The repository of the records.
+//
+// Returns:
+//   - The rule, to register with RegisterRuleCtx.
+func Unique(repo Repository) RuleCtxFunc {
+	return repositoryRule(repo, true, ErrNotUnique)
+}
+
+// Exists returns a rule rejecting the values no record of the repository has, e.g. the ID of a
+// category referenced by a product, with the parameter of Unique:
+//
+//	validator.RegisterRuleCtx("exists", validator.Exists(categoryRepo))
+//
+//	type CreateProductRequest struct {
+//		CategoryID string `json:"category_id" validate:"required,exists:categories:id"`
+//	}
+//
+// Parameters:
+//   - repo: The repository of the records.
+//
+// Returns:
+//   - The rule, to register with RegisterRuleCtx.
+func Exists(repo Repository) RuleCtxFunc {
+	return repositoryRule(repo, false, ErrNotFound)
+}
+
+// repositoryRule returns the rule of Unique, rejecting the values found, or of Exists,
+// rejecting the values not found, with the error rejection.
+func repositoryRule(repo Repository, rejectFound bool, rejection apperror.ErrorType) RuleCtxFunc {
+	return func(ctx context.Context, name string, field reflect.Value, param string) *Message {
+
+		value := fieldValue(field)
+		if value == "" {
+			return nil
+		}
+
+		table, column, ok := strings.Cut(param, ":")
+		if !ok {
+			column = name
+		}
+
+		found, err := repo.Exists(ctx, table, column, value)
+		if err != nil {
+			e := ErrRuleUnavailable.Var(name)
+			return &Message{Code: e.Code(), Message: e.Error()}
+		}
+
+		if found == rejectFound {
+			e := rejection.Var(name)
+			return &Message{Code: e.Code(), Message: e.Error()}
+		}
+		return nil
+	}
+}
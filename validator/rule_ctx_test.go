@@ -0,0 +1,126 @@
+package validator
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/model/payload"
+	mockvalidator "github.com/a-aslani/wotop/validator/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// traceIDOf matches the contexts carrying a trace ID.
+func traceIDOf(traceID string) gomock.Matcher {
+	return gomock.Cond(func(ctx context.Context) bool {
+		got, ok := wotop.TraceIDFromContext(ctx)
+		return ok && got == traceID
+	})
+}
+
+func TestUniqueRuleGetsRequestContext(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := mockvalidator.NewMockRepository(ctrl)
+	RegisterRuleCtx("unique", Unique(repo))
+
+	type registerRequest struct {
+		Email    string `json:"email" validate:"required,email,unique:users:email"`
+		Nickname string `json:"nickname" validate:"unique:users"`
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	gomock.InOrder(
+		repo.EXPECT().Exists(traceIDOf("trace-1"), "users", "email", "taken@example.com").
+			DoAndReturn(func(ctx context.Context, _, _, _ string) (bool, error) {
+				_, hasDeadline := ctx.Deadline()
+				assert.True(t, hasDeadline, "the deadline of the request reaches the repository")
+				return true, nil
+			}),
+		repo.EXPECT().Exists(traceIDOf("trace-1"), "users", "nickname", "neo").Return(false, nil),
+	)
+
+	res, err := HttpRequestValidator(ctx, "trace-1", registerRequest{Email: "taken@example.com", Nickname: "neo"})
+	require.ErrorIs(t, err, ErrValidationError)
+
+	messages := res.(payload.Response).Data.(map[string]any)["errors"].([]any)
+	require.Len(t, messages, 1)
+	assert.Equal(t, Message{
+		FieldName: "email",
+		Code:      "ER0015",
+		Message:   "email is already taken",
+		Rule:      "unique",
+		Param:     "users:email",
+		Value:     "taken@example.com",
+	}, messages[0])
+
+	// the invalid and empty values do not reach the repository
+	_, err = HttpRequestValidator(ctx, "trace-1", registerRequest{Email: "not-an-email"})
+	assert.ErrorIs(t, err, ErrValidationError)
+}
+
+func TestExistsRule(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := mockvalidator.NewMockRepository(ctrl)
+	RegisterRuleCtx("exists", Exists(repo))
+
+	type productRequest struct {
+		CategoryID string `json:"category_id" validate:"required,exists:categories:id"`
+	}
+
+	ctx := wotop.WithTraceID(context.Background(), "trace-2")
+	repo.EXPECT().Exists(traceIDOf("trace-2"), "categories", "id", "c-1").Return(true, nil)
+	repo.EXPECT().Exists(traceIDOf("trace-2"), "categories", "id", "c-404").Return(false, nil)
+	repo.EXPECT().Exists(traceIDOf("trace-2"), "categories", "id", "c-2").Return(false, errors.New("connection refused"))
+
+	vld := New()
+	ok, err := vld.ValidateCtx(ctx, productRequest{CategoryID: "c-1"})
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	for id, code := range map[string]string{"c-404": "ER0016", "c-2": "ER0017"} {
+		vld := New()
+		ok, err := vld.ValidateCtx(ctx, productRequest{CategoryID: id})
+		require.NoError(t, err)
+		assert.False(t, ok)
+		require.Len(t, vld.Errors, 1)
+		assert.Equal(t, code, vld.Errors[0].(Message).Code)
+	}
+}
+
+func TestRegisterRuleCtx(t *testing.T) {
+	assert.Panics(t, func() { RegisterRuleCtx("email", nil) }, "the built-in rules cannot be replaced")
+
+	type request struct {
+		Code string `json:"code" validate:"even_length,not_registered_yet"`
+	}
+
+	// the rule registered after the type is compiled is run, Validate gives it the background context
+	vld := New()
+	ok, err := vld.Validate(request{Code: "abc"})
+	require.NoError(t, err)
+	assert.True(t, ok, "the unknown rules are ignored")
+
+	var got context.Context
+	RegisterRuleCtx("not_registered_yet", func(ctx context.Context, name string, field reflect.Value, _ string) *Message {
+		got = ctx
+		if field.Len()%2 == 0 {
+			return nil
+		}
+		return &Message{Code: "ER9999", Message: name + " must have an even length"}
+	})
+	t.Cleanup(func() { ctxRules.Delete("not_registered_yet") })
+
+	vld = New()
+	ok, err = vld.Validate(request{Code: "abc"})
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, context.Background(), got)
+	assert.Equal(t, Message{FieldName: "code", Code: "ER9999", Message: "code must have an even length", Rule: "not_registered_yet", Value: "abc"}, vld.Errors[0])
+}
@@ -0,0 +1,152 @@
+package validator
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Map is a map payload validated against a schema registered with RegisterMapSchema, e.g. the
+// body of a PATCH request bound into a map[string]any so the absent keys can be told from the
+// zero values.
+//
+// Fields:
+//   - Schema: The name of the schema.
+//   - Values: The values of the payload, by key.
+type Map struct {
+	Schema string
+	Values map[string]any
+}
+
+// mapKeyRules holds the compiled rules of a key of a map schema.
+type mapKeyRules struct {
+	key       string
+	rules     []rule
+	required  bool   // The key must be present, the other rules only run on the present keys.
+	sensitive bool   // The value of the key is not put in the validation errors.
+	expected  string // The type the rules need, e.g. "a string", empty when they take any value.
+}
+
+// mapSchemas holds the []mapKeyRules of the registered map schemas by name.
+var mapSchemas sync.Map
+
+// RegisterMapSchema registers the schema Map payloads are validated against. The rules of a
+// key are written as the validate tag of a struct field, e.g. "required,max:64". The keys
+// without required are optional: their rules only run when they are present and not null, as
+// a PATCH request leaves the absent ones unchanged. The keys the schema does not list are
+// rejected with ErrUnknownField. Registering a name again replaces its schema.
+//
+// Parameters:
+//   - name: The name of the schema, e.g. "product_patch".
+//   - rules: The rules of the accepted keys, by key.
+func RegisterMapSchema(name string, rules map[string]string) {
+
+	keys := make([]mapKeyRules, 0, len(rules))
+
+	for key, validateTag := range rules {
+		k := mapKeyRules{key: key}
+
+		// required is checked on the presence of the key, the zero values are accepted
+		var others []string
+		for _, tagRule := range strings.Split(validateTag, ",") {
+			switch ruleName, _, _ := strings.Cut(tagRule, ":"); strings.TrimSpace(ruleName) {
+			case "required":
+				k.required = true
+				continue
+			case "password_strength":
+				k.sensitive = true
+				k.expected = "a string"
+			case "email", "digits", "numeric":
+				k.expected = "a string"
+			case "len":
+				if k.expected == "" {
+					k.expected = "a string or a list"
+				}
+			}
+			others = append(others, tagRule)
+		}
+		k.rules = compileRules(strings.Join(others, ","))
+
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].key < keys[j].key })
+	mapSchemas.Store(name, keys)
+}
+
+// validateMap validates a Map against its schema: the keys of the schema in order, missing or
+// checked with their rules, and then the unknown keys in order.
+//
+// Parameters:
+//   - m: The map payload.
+//
+// Returns:
+//   - A boolean indicating whether the map is valid.
+//   - An error if the schema is not registered or a rule cannot be checked.
+func (v *validator) validateMap(m Map) (bool, error) {
+
+	cached, ok := mapSchemas.Load(m.Schema)
+	if !ok {
+		return false, fmt.Errorf("validator: map schema %q is not registered", m.Schema)
+	}
+	keys := cached.([]mapKeyRules)
+
+	accepted := make(map[string]struct{}, len(keys))
+
+	for _, k := range keys {
+		accepted[k.key] = struct{}{}
+
+		value, present := m.Values[k.key]
+		if !present || value == nil {
+			if k.required {
+				e := ErrIsRequired.Var(k.key)
+				v.Errors = append(v.Errors, Message{FieldName: k.key, Code: e.Code(), Message: e.Error(), Rule: "required"})
+			}
+			continue
+		}
+
+		field := reflect.ValueOf(value)
+		if !k.accepts(field) {
+			// the JSON value has another type than the rules need, e.g. a number for a string
+			shown := fieldValue(field)
+			if k.sensitive {
+				shown = ""
+			}
+			e := ErrInvalidValue.Var(k.key, shown, k.expected)
+			v.Errors = append(v.Errors, Message{FieldName: k.key, Code: e.Code(), Message: e.Error()})
+			continue
+		}
+
+		if err := v.check(k.key, field, k.rules, k.sensitive); err != nil {
+			return false, err
+		}
+	}
+
+	unknown := make([]string, 0)
+	for key := range m.Values {
+		if _, ok := accepted[key]; !ok {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+
+	for _, key := range unknown {
+		e := ErrUnknownField.Var(key)
+		v.Errors = append(v.Errors, Message{FieldName: key, Code: e.Code(), Message: e.Error()})
+	}
+
+	return len(v.Errors) == 0, nil
+}
+
+// accepts reports whether a value has the type the rules of the key need.
+func (k mapKeyRules) accepts(field reflect.Value) bool {
+	switch k.expected {
+	case "a string":
+		return field.Kind() == reflect.String
+	case "a string or a list":
+		return field.Kind() == reflect.String || field.Kind() == reflect.Slice || field.Kind() == reflect.Map
+	}
+	return true
+}
@@ -0,0 +1,119 @@
+package validator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func messagesOf(vld *validator) []Message {
+	messages := make([]Message, len(vld.Errors))
+	for i, e := range vld.Errors {
+		messages[i] = e.(Message)
+	}
+	return messages
+}
+
+type createItemRequest struct {
+	Name  string `json:"name" validate:"required,max:10"`
+	Email string `json:"email" validate:"email"`
+}
+
+func TestValidateSlice(t *testing.T) {
+
+	items := []createItemRequest{
+		{Name: "pen", Email: "a@example.com"},
+		{Name: "a very long name", Email: "b"},
+		{Name: "ink", Email: "c@example.com"},
+	}
+
+	vld := New()
+	ok, err := vld.Validate(items)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, []Message{
+		{FieldName: "[1].name", Code: "ER0005", Message: "the length of [1].name must be 10 characters or fewer. You entered 16 characters", Rule: "max", Param: "10", Value: "a very long name"},
+		{FieldName: "[1].email", Code: "ER0004", Message: "[1].email is not a valid email address", Rule: "email", Value: "b"},
+	}, messagesOf(vld))
+
+	vld = New()
+	ok, err = vld.Validate([]*createItemRequest{&items[0], nil})
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, "[1] is required", messagesOf(vld)[0].Message)
+
+	vld = New()
+	ok, err = vld.Validate(&[]createItemRequest{})
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	_, err = New().Validate([]string{"not", "structs"})
+	assert.ErrorIs(t, err, ErrInvalidTypeInputData)
+}
+
+func TestValidateMapSchema(t *testing.T) {
+
+	RegisterMapSchema("product_patch", map[string]string{
+		"name":     "required,min:3",
+		"price":    "min:0",
+		"code":     "digits",
+		"password": "password_strength",
+	})
+
+	tests := map[string]struct {
+		values   map[string]any
+		messages []Message
+	}{
+		"valid": {
+			values: map[string]any{"name": "Pencil", "price": 2.5},
+		},
+		"absent optional keys": {
+			values: map[string]any{"name": "Pencil", "price": nil},
+		},
+		"missing and invalid keys": {
+			values: map[string]any{"price": -1.0, "code": 42.0, "password": 7.0, "stock": 3.0, "color": "red"},
+			messages: []Message{
+				{FieldName: "code", Code: "ER0007", Message: `code has the invalid value "42", expected a string`},
+				{FieldName: "name", Code: "ER0003", Message: "name is required", Rule: "required"},
+				{FieldName: "password", Code: "ER0007", Message: `password has the invalid value "", expected a string`},
+				{FieldName: "price", Code: "ER0012", Message: "price must be 0 or greater", Rule: "min", Param: "0", Value: "-1"},
+				{FieldName: "color", Code: "ER0014", Message: "color is not an accepted field"},
+				{FieldName: "stock", Code: "ER0014", Message: "stock is not an accepted field"},
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			vld := New()
+			ok, err := vld.Validate(Map{Schema: "product_patch", Values: tt.values})
+			require.NoError(t, err)
+			assert.Equal(t, len(tt.messages) == 0, ok)
+			if len(tt.messages) > 0 {
+				assert.Equal(t, tt.messages, messagesOf(vld))
+			}
+		})
+	}
+
+	_, err := New().Validate(&Map{Schema: "unknown"})
+	assert.ErrorContains(t, err, `map schema "unknown" is not registered`)
+
+	// a map without schema is still rejected
+	_, err = New().Validate(map[string]any{"name": "Pencil"})
+	assert.ErrorIs(t, err, ErrInvalidTypeInputData)
+}
+
+func TestHttpRequestValidatorMap(t *testing.T) {
+
+	RegisterMapSchema("item_patch", map[string]string{"name": "max:10"})
+
+	res, err := HttpRequestValidator(context.Background(), "trace-1", Map{Schema: "item_patch", Values: map[string]any{"name": "a very long name"}})
+	assert.ErrorIs(t, err, ErrValidationError)
+	assert.NotNil(t, res)
+
+	res, err = HttpRequestValidator(context.Background(), "trace-1", []createItemRequest{{Name: "pen", Email: "a@example.com"}})
+	assert.NoError(t, err)
+	assert.Nil(t, res)
+}
@@ -2,13 +2,19 @@ package validator
 
 import (
 	"context"
+	"fmt"
+	"github.com/a-aslani/wotop"
 	"github.com/a-aslani/wotop/model/apperror"
 	"github.com/a-aslani/wotop/model/payload"
+	"github.com/a-aslani/wotop/password"
+	"net/http"
 	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 )
 
 const (
@@ -19,28 +25,77 @@ const (
 	// ErrIsRequired indicates that a required field is missing.
 	ErrIsRequired apperror.ErrorType = "ER0003 %s is required"
 	// ErrInvalidEmailAddress indicates an invalid email address format.
-	ErrInvalidEmailAddress apperror.ErrorType = "ER0004 %s is invalid email address"
+	ErrInvalidEmailAddress apperror.ErrorType = "ER0004 %s is not a valid email address"
 	// ErrMaxLen indicates that a field exceeds the maximum allowed length.
 	ErrMaxLen apperror.ErrorType = "ER0005 the length of %s must be %d characters or fewer. You entered %d characters"
 	// ErrMinLen indicates that a field is below the minimum required length.
-	ErrMinLen apperror.ErrorType = "ER0003 the length of %s must be %d characters or longer. You entered %d characters"
+	ErrMinLen apperror.ErrorType = "ER0008 the length of %s must be %d characters or longer. You entered %d characters"
+	// ErrWeakPassword indicates that a password does not reach the required strength score.
+	ErrWeakPassword apperror.ErrorType = "ER0006 %s is too weak, the password strength must be at least %d of 4"
+	// ErrInvalidValue indicates that a value cannot be converted to the type of the field.
+	ErrInvalidValue apperror.ErrorType = "ER0007 %s has the invalid value %q, expected %s"
+	// ErrNotDigits indicates that a field holds other characters than the digits 0-9.
+	ErrNotDigits apperror.ErrorType = "ER0009 %s must contain only the digits 0-9"
+	// ErrNotNumeric indicates that a field does not hold a number of the expected form.
+	ErrNotNumeric apperror.ErrorType = "ER0010 %s must be %s"
+	// ErrExactLen indicates that a field does not have the required length.
+	ErrExactLen apperror.ErrorType = "ER0011 the length of %s must be exactly %d. You entered %d"
+	// ErrMinValue indicates that a number is below the minimum.
+	ErrMinValue apperror.ErrorType = "ER0012 %s must be %s or greater"
+	// ErrMaxValue indicates that a number is above the maximum.
+	ErrMaxValue apperror.ErrorType = "ER0013 %s must be %s or less"
+	// ErrUnknownField indicates that a map has a key its schema does not accept.
+	ErrUnknownField apperror.ErrorType = "ER0014 %s is not an accepted field"
+	// ErrNotUnique indicates that a value is already taken, e.g. a registered email.
+	ErrNotUnique apperror.ErrorType = "ER0015 %s is already taken"
+	// ErrNotFound indicates that a value does not reference an existing record.
+	ErrNotFound apperror.ErrorType = "ER0016 %s does not exist"
+	// ErrRuleUnavailable indicates that a remote rule cannot reach its repository.
+	ErrRuleUnavailable apperror.ErrorType = "ER0017 %s cannot be checked now, try again later"
 )
 
+// init registers the errors in the catalog and maps the validation errors to 400 Bad Request,
+// see payload.WriteError.
+func init() {
+	apperror.Register("validator",
+		apperror.Entry{Err: ErrValidationError, Description: "The request has invalid fields, they are listed in the data of the response."},
+		apperror.Entry{Err: ErrInvalidTypeInputData, Description: "The validated value is not a struct, a slice of structs or a map with a schema."},
+		apperror.Entry{Err: ErrIsRequired, Description: "A required field is missing."},
+		apperror.Entry{Err: ErrInvalidEmailAddress, Description: "A field is not a valid email address."},
+		apperror.Entry{Err: ErrMaxLen, Description: "A field is longer than allowed."},
+		apperror.Entry{Err: ErrWeakPassword, Description: "A password is too easy to guess."},
+		apperror.Entry{Err: ErrInvalidValue, Description: "A value cannot be converted to the type of the field."},
+		apperror.Entry{Err: ErrMinLen, Description: "A field is shorter than allowed."},
+		apperror.Entry{Err: ErrNotDigits, Description: "A field holds other characters than the digits 0-9."},
+		apperror.Entry{Err: ErrNotNumeric, Description: "A field is not a number, or has a sign or decimals where none are allowed."},
+		apperror.Entry{Err: ErrExactLen, Description: "A field does not have the required length."},
+		apperror.Entry{Err: ErrMinValue, Description: "A number is smaller than allowed."},
+		apperror.Entry{Err: ErrMaxValue, Description: "A number is larger than allowed."},
+		apperror.Entry{Err: ErrUnknownField, Description: "A map has a key its schema does not accept, e.g. a field a PATCH request cannot change."},
+		apperror.Entry{Err: ErrNotUnique, Description: "A value must be unique and is already taken, e.g. a registered email."},
+		apperror.Entry{Err: ErrNotFound, Description: "A value does not reference an existing record."},
+		apperror.Entry{Err: ErrRuleUnavailable, Description: "A rule checked against a repository cannot reach it."},
+	)
+
+	apperror.MapCode(ErrValidationError.Code(), http.StatusBadRequest)
+	apperror.MapCode(ErrInvalidTypeInputData.Code(), http.StatusBadRequest)
+}
+
 var (
 	// timeType is used to check if a field is of type time.Time.
 	timeType = reflect.TypeOf(time.Time{})
+	// emailRegex matches the values accepted by the email rule.
+	emailRegex = regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
 )
 
-// Message represents a validation error message.
-type Message struct {
-	FieldName string `json:"field_name"` // The name of the field that caused the error.
-	Code      string `json:"code"`       // The error code.
-	Message   string `json:"message"`    // The error message.
-}
+// Message represents a validation error message, it is declared in payload so the
+// validation errors of the payload helpers share its format.
+type Message = payload.Message
 
 // validator is a struct that performs validation and stores errors.
 type validator struct {
-	Errors []any // A list of validation errors.
+	Errors []any           // A list of validation errors.
+	ctx    context.Context // The context given to the rules registered with RegisterRuleCtx.
 }
 
 // New creates a new instance of the validator.
@@ -50,23 +105,29 @@ type validator struct {
 func New() *validator {
 	return &validator{
 		Errors: make([]any, 0),
+		ctx:    context.Background(),
 	}
 }
 
-// HttpRequestValidator validates an HTTP request payload.
+// HttpRequestValidator validates an HTTP request payload with ValidateCtx, the trace ID is set
+// on the context of the rules when it has none.
 //
 // Parameters:
-//   - ctx: The context for managing request-scoped values.
+//   - ctx: The context for managing request-scoped values, given to the rules registered with RegisterRuleCtx.
 //   - traceID: A unique identifier for tracing the request.
-//   - input: The input data to be validated.
+//   - input: The input data to be validated, a struct, a slice of structs or a Map, see Validate.
 //
 // Returns:
 //   - An error response or nil if validation passes.
 //   - An error if validation fails.
 func HttpRequestValidator(ctx context.Context, traceID string, input interface{}) (any, error) {
 
+	if _, ok := wotop.TraceIDFromContext(ctx); !ok && traceID != "" {
+		ctx = wotop.WithTraceID(ctx, traceID)
+	}
+
 	vld := New()
-	isValid, err := vld.Validate(input)
+	isValid, err := vld.ValidateCtx(ctx, input)
 	if err != nil {
 		return payload.NewErrorResponse(err, traceID), err
 	}
@@ -80,6 +141,12 @@ func HttpRequestValidator(ctx context.Context, traceID string, input interface{}
 
 // Validate performs validation on the input data.
 //
+// The fields and rules of a struct type are read from its tags on its first validation,
+// later validations of the type run the rules compiled then, see typeRules. The elements of a
+// slice or an array of structs, e.g. the items of a bulk request, are validated one by one, the
+// field names of their errors prefixed by their index, e.g. "[1].email". A Map is validated
+// against its schema, see RegisterMapSchema.
+//
 // Parameters:
 //   - input: The input data to be validated.
 //
@@ -88,93 +155,559 @@ func HttpRequestValidator(ctx context.Context, traceID string, input interface{}
 //   - An error if the input type is invalid.
 func (v *validator) Validate(input interface{}) (bool, error) {
 
+	switch m := input.(type) {
+	case Map:
+		return v.validateMap(m)
+	case *Map:
+		if m != nil {
+			return v.validateMap(*m)
+		}
+	}
+
 	val := reflect.ValueOf(input)
 
 	if val.Kind() == reflect.Ptr && !val.IsNil() {
 		val = val.Elem()
 	}
 
-	if val.Kind() != reflect.Struct || val.Type().ConvertibleTo(timeType) {
+	switch {
+	case isStruct(val):
+		if err := v.validateStruct("", val); err != nil {
+			return false, err
+		}
+	case val.Kind() == reflect.Slice || val.Kind() == reflect.Array:
+		for i := 0; i < val.Len(); i++ {
+			if err := v.validateElement(i, val.Index(i)); err != nil {
+				return false, err
+			}
+		}
+	default:
 		return false, ErrInvalidTypeInputData
 	}
 
-	for i := 0; i < val.NumField(); i++ {
+	return len(v.Errors) == 0, nil
+}
 
-		nameTag := val.Type().Field(i).Tag.Get("name")
-		validateTag := val.Type().Field(i).Tag.Get("validate")
+// ValidateCtx performs validation on the input data like Validate, giving ctx to the rules
+// registered with RegisterRuleCtx, e.g. to query a repository with the deadline and the trace
+// ID of the request. The built-in rules ignore it.
+//
+// Parameters:
+//   - ctx: The context of the rules.
+//   - input: The input data to be validated.
+//
+// Returns:
+//   - A boolean indicating whether the input is valid.
+//   - An error if the input type is invalid.
+func (v *validator) ValidateCtx(ctx context.Context, input interface{}) (bool, error) {
+	previous := v.ctx
+	v.ctx = ctx
+	defer func() { v.ctx = previous }()
+
+	return v.Validate(input)
+}
+
+// isStruct reports whether a value is a struct with fields to validate, times are not.
+func isStruct(val reflect.Value) bool {
+	return val.Kind() == reflect.Struct && !val.Type().ConvertibleTo(timeType)
+}
+
+// validateStruct validates the fields of a struct with their compiled rules.
+//
+// Parameters:
+//   - prefix: The prefix of the field names in the errors, e.g. "[1].", empty for the payload.
+//   - val: The struct value.
+//
+// Returns:
+//   - An error if a rule cannot be checked.
+func (v *validator) validateStruct(prefix string, val reflect.Value) error {
+	for _, f := range typeRules(val.Type()) {
+		if err := v.check(prefix+f.name, val.Field(f.index), f.rules, f.sensitive); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateElement validates a struct element of a slice or an array, a nil element is
+// reported as missing.
+//
+// Parameters:
+//   - index: The index of the element.
+//   - elem: The element value, a struct or a pointer to one.
+//
+// Returns:
+//   - ErrInvalidTypeInputData if the element is not a struct, or an error if a rule cannot be
+//     checked.
+func (v *validator) validateElement(index int, elem reflect.Value) error {
+
+	name := fmt.Sprintf("[%d]", index)
+
+	for elem.Kind() == reflect.Ptr || elem.Kind() == reflect.Interface {
+		if elem.IsNil() {
+			v.required(name, elem)
+			return nil
+		}
+		elem = elem.Elem()
+	}
+
+	if !isStruct(elem) {
+		return ErrInvalidTypeInputData
+	}
+
+	return v.validateStruct(name+".", elem)
+}
+
+// rule is a compiled validation rule of a field.
+type rule func(v *validator, name string, field reflect.Value) error
+
+// fieldRules holds the compiled rules of a struct field with a validate tag.
+type fieldRules struct {
+	index     int    // The index of the field in the struct.
+	name      string // The name of the field in the validation errors.
+	rules     []rule // The rules of the field, in the order of the tag.
+	sensitive bool   // The value of the field is not put in the validation errors.
+}
+
+// rulesCache caches the []fieldRules of the validated struct types by reflect.Type.
+var rulesCache sync.Map
+
+// typeRules returns the compiled rules of the fields of a struct type, compiling them on the
+// first call for the type.
+//
+// Parameters:
+//   - t: The struct type.
+//
+// Returns:
+//   - The rules of the fields with a validate tag, in the order of the fields.
+func typeRules(t reflect.Type) []fieldRules {
+	if cached, ok := rulesCache.Load(t); ok {
+		return cached.([]fieldRules)
+	}
+
+	fields := make([]fieldRules, 0, t.NumField())
+
+	for i := 0; i < t.NumField(); i++ {
+
+		validateTag := t.Field(i).Tag.Get("validate")
 
 		if strings.TrimSpace(validateTag) == "" {
 			continue
 		}
 
-		name := strings.TrimSpace(nameTag)
-		if name == "" {
-			name = val.Type().Field(i).Tag.Get("json")
-			if name == "" {
-				name = val.Type().Field(i).Name
-			}
+		fields = append(fields, fieldRules{
+			index:     i,
+			name:      fieldName(t.Field(i)),
+			rules:     compileRules(validateTag),
+			sensitive: isSensitive(t.Field(i)),
+		})
+	}
+
+	cached, _ := rulesCache.LoadOrStore(t, fields)
+	return cached.([]fieldRules)
+}
+
+// fieldName returns the name of a field in the validation errors: its name tag, else its json
+// tag, else the name of its query or path parameter or of its configuration key, else the name
+// of the field.
+func fieldName(f reflect.StructField) string {
+	if name := strings.TrimSpace(f.Tag.Get("name")); name != "" {
+		return name
+	}
+	if name := f.Tag.Get("json"); name != "" {
+		return name
+	}
+	for _, tag := range []string{"form", "uri", "mapstructure"} {
+		if name, _, _ := strings.Cut(f.Tag.Get(tag), ","); name != "" && name != "-" {
+			return name
 		}
+	}
+	return f.Name
+}
 
-		if err := v.check(name, val.Field(i), validateTag); err != nil {
-			return false, err
+// isSensitive reports whether the value of a field must not be put in the validation errors:
+// it has the tag sensitive:"true", or the password_strength rule.
+func isSensitive(f reflect.StructField) bool {
+	if sensitive, _ := strconv.ParseBool(f.Tag.Get("sensitive")); sensitive {
+		return true
+	}
+	for _, tagRule := range strings.Split(f.Tag.Get("validate"), ",") {
+		if ruleName, _, _ := strings.Cut(tagRule, ":"); strings.TrimSpace(ruleName) == "password_strength" {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldValue returns the value of a field as it is put in the validation errors, the times in
+// RFC 3339 and nil pointers as an empty string.
+func fieldValue(field reflect.Value) string {
+	for field.Kind() == reflect.Ptr || field.Kind() == reflect.Interface {
+		if field.IsNil() {
+			return ""
 		}
+		field = field.Elem()
 	}
 
-	return len(v.Errors) == 0, nil
+	switch {
+	case !field.IsValid() || !field.CanInterface():
+		return ""
+	case field.Kind() == reflect.String:
+		return field.String()
+	case field.Type().ConvertibleTo(timeType):
+		return field.Convert(timeType).Interface().(time.Time).Format(time.RFC3339)
+	default:
+		return fmt.Sprint(field.Interface())
+	}
 }
 
-// check validates a single field based on its validation rules.
+// compileRules compiles the rules of a validate tag, the unknown rules are ignored.
+//
+// The min and max rules bound the value of the number fields and the length of the others.
+// The parameters of the rules are parsed once. A rule whose parameter is missing or is not a
+// number is compiled to the uncompiled check, so it fails when it is run, as it did before
+// the rules were cached.
 //
 // Parameters:
-//   - name: The name of the field.
-//   - field: The field value to be validated.
-//   - validateTag: The validation rules for the field.
+//   - validateTag: The validation rules of the field.
 //
 // Returns:
-//   - An error if validation fails.
-func (v *validator) check(name string, field reflect.Value, validateTag string) error {
+//   - The compiled rules.
+func compileRules(validateTag string) []rule {
 
-	rules := strings.Split(strings.TrimSpace(validateTag), ",")
+	var rules []rule
 
-	for _, rule := range rules {
+	for _, tagRule := range strings.Split(strings.TrimSpace(validateTag), ",") {
 
-		if v.checkHasOldError(name) {
-			return nil
-		}
-
-		r := strings.Split(strings.TrimSpace(rule), ":")
+		r := strings.Split(strings.TrimSpace(tagRule), ":")
+		compiled := len(rules)
 
 		switch strings.TrimSpace(r[0]) {
 		case "required":
-			v.required(name, field)
-			break
+			rules = append(rules, func(v *validator, name string, field reflect.Value) error {
+				v.required(name, field)
+				return nil
+			})
 		case "email":
-			v.email(name, field)
-			break
+			rules = append(rules, func(v *validator, name string, field reflect.Value) error {
+				v.email(name, field)
+				return nil
+			})
 		case "min":
-			if err := v.min(name, field, r[1]); err != nil {
-				return err
+			if minimum, err := lengthParam(r); err == nil {
+				rules = append(rules, func(v *validator, name string, field reflect.Value) error {
+					if isNumber(field) {
+						v.minValue(name, field, float64(minimum))
+						return nil
+					}
+					v.minLen(name, field, minimum)
+					return nil
+				})
+				break
 			}
-			break
+			bound, boundErr := boundParam(r)
+			rules = append(rules, func(v *validator, name string, field reflect.Value) error {
+				if isNumber(field) && boundErr == nil {
+					v.minValue(name, field, bound)
+					return nil
+				}
+				return v.min(name, field, r[1])
+			})
 		case "max":
-			if err := v.max(name, field, r[1]); err != nil {
-				return err
+			if maximum, err := lengthParam(r); err == nil {
+				rules = append(rules, func(v *validator, name string, field reflect.Value) error {
+					if isNumber(field) {
+						v.maxValue(name, field, float64(maximum))
+						return nil
+					}
+					v.maxLen(name, field, maximum)
+					return nil
+				})
+				break
 			}
+			bound, boundErr := boundParam(r)
+			rules = append(rules, func(v *validator, name string, field reflect.Value) error {
+				if isNumber(field) && boundErr == nil {
+					v.maxValue(name, field, bound)
+					return nil
+				}
+				return v.max(name, field, r[1])
+			})
+		case "digits":
+			rules = append(rules, func(v *validator, name string, field reflect.Value) error {
+				return v.digits(name, field)
+			})
+		case "numeric":
+			form, err := numericParam(r[1:])
+			rules = append(rules, func(v *validator, name string, field reflect.Value) error {
+				if err != nil {
+					return err
+				}
+				return v.numeric(name, field, form)
+			})
+		case "len":
+			length, err := exactLenParam(r)
+			rules = append(rules, func(v *validator, name string, field reflect.Value) error {
+				if err != nil {
+					return err
+				}
+				return v.exactLen(name, field, length)
+			})
+		case "password_strength":
+			if minimum, err := strengthParam(r[1:]); err == nil {
+				rules = append(rules, func(v *validator, name string, field reflect.Value) error {
+					v.minStrength(name, field, minimum)
+					return nil
+				})
+				break
+			}
+			rules = append(rules, func(v *validator, name string, field reflect.Value) error {
+				return v.passwordStrength(name, field, r[1:]...)
+			})
+		default:
+			// the rules registered with RegisterRuleCtx are looked up when they run, they may be
+			// registered after the type is compiled
+			ruleName, param := strings.TrimSpace(r[0]), strings.TrimSpace(strings.Join(r[1:], ":"))
+			if ruleName == "" {
+				break
+			}
+			rules = append(rules, func(v *validator, name string, field reflect.Value) error {
+				v.ruleCtx(ruleName, name, field, param)
+				return nil
+			})
+		}
+
+		if len(rules) > compiled {
+			rules[compiled] = named(rules[compiled], strings.TrimSpace(r[0]), strings.TrimSpace(strings.Join(r[1:], ":")))
+		}
+
+	}
+
+	return rules
+}
+
+// named wraps a compiled rule so the messages it adds carry the name and the parameter of the
+// rule, e.g. "min" and "8".
+//
+// Parameters:
+//   - r: The compiled rule.
+//   - ruleName: The name of the rule in the validate tag.
+//   - param: The parameters of the rule, joined by colons.
+//
+// Returns:
+//   - The wrapped rule.
+func named(r rule, ruleName, param string) rule {
+	return func(v *validator, name string, field reflect.Value) error {
+		added := len(v.Errors)
+		err := r(v, name, field)
+		for i := added; i < len(v.Errors); i++ {
+			msg := v.Errors[i].(Message)
+			msg.Rule, msg.Param = ruleName, param
+			v.Errors[i] = msg
+		}
+		return err
+	}
+}
+
+// lengthParam parses the length of a min or max rule, 1 when it is empty.
+//
+// Parameters:
+//   - r: The rule split on colons.
+//
+// Returns:
+//   - The length.
+//   - An error if the length is missing or is not a number.
+func lengthParam(r []string) (int, error) {
+	if len(r) < 2 {
+		return 0, fmt.Errorf("%s has no length", r[0])
+	}
+
+	m := strings.TrimSpace(r[1])
+	if m == "" {
+		return 1, nil
+	}
+	return strconv.Atoi(m)
+}
+
+// boundParam parses the bound of a min or max rule of a number field, e.g. "0.5".
+//
+// Parameters:
+//   - r: The rule split on colons.
+//
+// Returns:
+//   - The bound.
+//   - An error if the bound is missing or is not a number.
+func boundParam(r []string) (float64, error) {
+	if len(r) < 2 {
+		return 0, fmt.Errorf("%s has no bound", r[0])
+	}
+	return strconv.ParseFloat(strings.TrimSpace(r[1]), 64)
+}
+
+// strengthParam parses the minimum score of a password_strength rule, password.Strong when
+// it is not given.
+//
+// Parameters:
+//   - params: The parameters of the rule.
+//
+// Returns:
+//   - The minimum score.
+//   - An error if the score is not a number.
+func strengthParam(params []string) (int, error) {
+	if len(params) == 0 || strings.TrimSpace(params[0]) == "" {
+		return int(password.Strong), nil
+	}
+	return strconv.Atoi(strings.TrimSpace(params[0]))
+}
+
+// numericForm is the form of the numbers accepted by a numeric rule.
+type numericForm struct {
+	signed  bool // A leading + or - is allowed.
+	decimal bool // A fractional part is allowed.
+}
+
+// String describes the numbers of the form in the errors of the rule.
+func (f numericForm) String() string {
+	switch {
+	case f.signed && f.decimal:
+		return "a decimal number"
+	case f.decimal:
+		return "an unsigned decimal number"
+	case f.signed:
+		return "an integer"
+	default:
+		return "an unsigned integer"
+	}
+}
+
+// accepts reports whether s is a number of the form, e.g. "007", "-12" or "3.50".
+func (f numericForm) accepts(s string) bool {
+	if f.signed && s != "" && (s[0] == '+' || s[0] == '-') {
+		s = s[1:]
+	}
+
+	whole, fraction, hasFraction := strings.Cut(s, ".")
+	if hasFraction && (!f.decimal || fraction == "") {
+		return false
+	}
+
+	return whole != "" && onlyDigits(whole) && onlyDigits(fraction)
+}
+
+// numericParam parses the parameters of a numeric rule, "signed" and "decimal" in any order.
+//
+// Parameters:
+//   - params: The parameters of the rule.
+//
+// Returns:
+//   - The form of the accepted numbers, unsigned integers when no parameter is given.
+//   - An error if a parameter is unknown.
+func numericParam(params []string) (numericForm, error) {
+	var form numericForm
+
+	for _, p := range params {
+		switch strings.TrimSpace(p) {
+		case "signed":
+			form.signed = true
+		case "decimal":
+			form.decimal = true
+		case "":
+		default:
+			return form, fmt.Errorf("numeric has the unknown parameter %q, expected signed or decimal", p)
+		}
+	}
+
+	return form, nil
+}
+
+// exactLenParam parses the length of a len rule.
+//
+// Parameters:
+//   - r: The rule split on colons.
+//
+// Returns:
+//   - The length.
+//   - An error if the length is missing, is not a number or is negative.
+func exactLenParam(r []string) (int, error) {
+	if len(r) < 2 || strings.TrimSpace(r[1]) == "" {
+		return 0, fmt.Errorf("%s has no length", r[0])
+	}
+
+	length, err := strconv.Atoi(strings.TrimSpace(r[1]))
+	if err != nil {
+		return 0, err
+	}
+	if length < 0 {
+		return 0, fmt.Errorf("%s has the negative length %d", r[0], length)
+	}
+	return length, nil
+}
+
+// onlyDigits reports whether s holds only the ASCII digits 0-9, other unicode digits such
+// as "٣" are not accepted.
+func onlyDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// check validates a single field with its compiled rules, it stops at the first error of the
+// field. The error carries the value of the field unless it is sensitive.
+//
+// Parameters:
+//   - name: The name of the field.
+//   - field: The field value to be validated.
+//   - rules: The compiled rules of the field.
+//   - sensitive: Whether the value of the field is kept out of the error.
+//
+// Returns:
+//   - An error if a rule cannot be checked.
+func (v *validator) check(name string, field reflect.Value, rules []rule, sensitive bool) error {
+
+	added := len(v.Errors)
+
+	for _, r := range rules {
+
+		if v.checkHasOldError(name) {
 			break
 		}
 
+		if err := r(v, name, field); err != nil {
+			return err
+		}
+
+	}
+
+	if !sensitive {
+		for i := added; i < len(v.Errors); i++ {
+			msg := v.Errors[i].(Message)
+			msg.Value = fieldValue(field)
+			v.Errors[i] = msg
+		}
 	}
 
 	return nil
 }
 
-// required checks if a field is non-empty.
+// required checks if a field is non-empty, slices and maps are empty without elements.
 //
 // Parameters:
 //   - name: The name of the field.
 //   - field: The field value to be checked.
 func (v *validator) required(name string, field reflect.Value) {
-	if field.Interface() == reflect.Zero(field.Type()).Interface() {
+
+	var empty bool
+	switch field.Kind() {
+	case reflect.Slice, reflect.Map:
+		empty = field.Len() == 0
+	default:
+		empty = field.Interface() == reflect.Zero(field.Type()).Interface()
+	}
+
+	if empty {
 
 		err := ErrIsRequired.Var(name)
 
@@ -192,10 +725,9 @@ func (v *validator) required(name string, field reflect.Value) {
 //   - name: The name of the field.
 //   - field: The field value to be checked.
 func (v *validator) email(name string, field reflect.Value) {
-	var emailRegex = regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
 	if !emailRegex.MatchString(strings.TrimSpace(field.String())) {
 
-		err := ErrInvalidEmailAddress.Var(strings.TrimSpace(field.String()))
+		err := ErrInvalidEmailAddress.Var(strings.TrimSpace(name))
 
 		v.Errors = append(v.Errors, Message{
 			FieldName: name,
@@ -228,6 +760,18 @@ func (v *validator) min(name string, field reflect.Value, params string) error {
 		}
 	}
 
+	v.minLen(name, field, minimum)
+
+	return nil
+}
+
+// minLen checks if a field's length is greater than or equal to a parsed minimum value.
+//
+// Parameters:
+//   - name: The name of the field.
+//   - field: The field value to be checked.
+//   - minimum: The minimum length.
+func (v *validator) minLen(name string, field reflect.Value, minimum int) {
 	if len(strings.TrimSpace(field.String())) < minimum {
 
 		e := ErrMinLen.Var(strings.TrimSpace(name), minimum, len(strings.TrimSpace(field.String())))
@@ -238,8 +782,6 @@ func (v *validator) min(name string, field reflect.Value, params string) error {
 			Message:   e.Error(),
 		})
 	}
-
-	return nil
 }
 
 // max checks if a field's length is less than or equal to a maximum value.
@@ -265,6 +807,18 @@ func (v *validator) max(name string, field reflect.Value, params string) error {
 		}
 	}
 
+	v.maxLen(name, field, maximum)
+
+	return nil
+}
+
+// maxLen checks if a field's length is less than or equal to a parsed maximum value.
+//
+// Parameters:
+//   - name: The name of the field.
+//   - field: The field value to be checked.
+//   - maximum: The maximum length.
+func (v *validator) maxLen(name string, field reflect.Value, maximum int) {
 	if len(strings.TrimSpace(field.String())) > maximum {
 
 		e := ErrMaxLen.Var(strings.TrimSpace(name), maximum, len(strings.TrimSpace(field.String())))
@@ -275,6 +829,207 @@ func (v *validator) max(name string, field reflect.Value, params string) error {
 			Message:   e.Error(),
 		})
 	}
+}
+
+// isNumber reports whether a field is an integer or a float, bounded in value by the min and
+// max rules.
+func isNumber(field reflect.Value) bool {
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// numberValue returns the value of a number field as a float64.
+func numberValue(field reflect.Value) float64 {
+	switch {
+	case field.CanInt():
+		return float64(field.Int())
+	case field.CanUint():
+		return float64(field.Uint())
+	default:
+		return field.Float()
+	}
+}
+
+// minValue checks if a number field is greater than or equal to a minimum.
+//
+// Parameters:
+//   - name: The name of the field.
+//   - field: The field value to be checked.
+//   - minimum: The minimum value.
+func (v *validator) minValue(name string, field reflect.Value, minimum float64) {
+	if numberValue(field) < minimum {
+
+		e := ErrMinValue.Var(strings.TrimSpace(name), strconv.FormatFloat(minimum, 'f', -1, 64))
+
+		v.Errors = append(v.Errors, Message{
+			FieldName: name,
+			Code:      e.Code(),
+			Message:   e.Error(),
+		})
+	}
+}
+
+// maxValue checks if a number field is less than or equal to a maximum.
+//
+// Parameters:
+//   - name: The name of the field.
+//   - field: The field value to be checked.
+//   - maximum: The maximum value.
+func (v *validator) maxValue(name string, field reflect.Value, maximum float64) {
+	if numberValue(field) > maximum {
+
+		e := ErrMaxValue.Var(strings.TrimSpace(name), strconv.FormatFloat(maximum, 'f', -1, 64))
+
+		v.Errors = append(v.Errors, Message{
+			FieldName: name,
+			Code:      e.Code(),
+			Message:   e.Error(),
+		})
+	}
+}
+
+// passwordStrength checks if a field holds a password reaching a minimum strength score.
+//
+// Parameters:
+//   - name: The name of the field.
+//   - field: The field value to be checked.
+//   - params: The minimum password.Score between 0 and 4, defaults to 3.
+//
+// Returns:
+//   - An error if the minimum score is not a number.
+func (v *validator) passwordStrength(name string, field reflect.Value, params ...string) error {
+
+	minimum := int(password.Strong)
+
+	var err error
+
+	if len(params) > 0 && strings.TrimSpace(params[0]) != "" {
+		minimum, err = strconv.Atoi(strings.TrimSpace(params[0]))
+		if err != nil {
+			return err
+		}
+	}
+
+	v.minStrength(name, field, minimum)
+
+	return nil
+}
+
+// minStrength checks if a field holds a password reaching a parsed minimum strength score.
+//
+// Parameters:
+//   - name: The name of the field.
+//   - field: The field value to be checked.
+//   - minimum: The minimum password.Score.
+func (v *validator) minStrength(name string, field reflect.Value, minimum int) {
+	if int(password.Strength(field.String()).Score) < minimum {
+
+		e := ErrWeakPassword.Var(strings.TrimSpace(name), minimum)
+
+		v.Errors = append(v.Errors, Message{
+			FieldName: name,
+			Code:      e.Code(),
+			Message:   e.Error(),
+		})
+	}
+}
+
+// digits checks if a string field holds only the digits 0-9, e.g. a one-time code. An empty
+// field is skipped, the required rule rejects it.
+//
+// Parameters:
+//   - name: The name of the field.
+//   - field: The field value to be checked.
+//
+// Returns:
+//   - An error if the field is not a string.
+func (v *validator) digits(name string, field reflect.Value) error {
+	if field.Kind() != reflect.String {
+		return fmt.Errorf("the digits rule of %s needs a string field, not %s", name, field.Kind())
+	}
+
+	if s := field.String(); s != "" && !onlyDigits(s) {
+
+		e := ErrNotDigits.Var(strings.TrimSpace(name))
+
+		v.Errors = append(v.Errors, Message{
+			FieldName: name,
+			Code:      e.Code(),
+			Message:   e.Error(),
+		})
+	}
+
+	return nil
+}
+
+// numeric checks if a string field holds a number of the form of the rule. An empty field is
+// skipped, the required rule rejects it.
+//
+// Parameters:
+//   - name: The name of the field.
+//   - field: The field value to be checked.
+//   - form: Whether a sign and a fractional part are allowed.
+//
+// Returns:
+//   - An error if the field is not a string.
+func (v *validator) numeric(name string, field reflect.Value, form numericForm) error {
+	if field.Kind() != reflect.String {
+		return fmt.Errorf("the numeric rule of %s needs a string field, not %s", name, field.Kind())
+	}
+
+	if s := field.String(); s != "" && !form.accepts(s) {
+
+		e := ErrNotNumeric.Var(strings.TrimSpace(name), form)
+
+		v.Errors = append(v.Errors, Message{
+			FieldName: name,
+			Code:      e.Code(),
+			Message:   e.Error(),
+		})
+	}
+
+	return nil
+}
+
+// exactLen checks if a field has an exact length, counted in runes for strings and in
+// elements for slices, arrays and maps. An empty field is skipped, the required rule rejects
+// it.
+//
+// Parameters:
+//   - name: The name of the field.
+//   - field: The field value to be checked.
+//   - length: The required length.
+//
+// Returns:
+//   - An error if the field has no length.
+func (v *validator) exactLen(name string, field reflect.Value, length int) error {
+
+	var n int
+
+	switch field.Kind() {
+	case reflect.String:
+		n = utf8.RuneCountInString(field.String())
+	case reflect.Slice, reflect.Array, reflect.Map:
+		n = field.Len()
+	default:
+		return fmt.Errorf("the len rule of %s needs a string, slice, array or map field, not %s", name, field.Kind())
+	}
+
+	if n != 0 && n != length {
+
+		e := ErrExactLen.Var(strings.TrimSpace(name), length, n)
+
+		v.Errors = append(v.Errors, Message{
+			FieldName: name,
+			Code:      e.Code(),
+			Message:   e.Error(),
+		})
+	}
 
 	return nil
 }
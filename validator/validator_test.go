@@ -0,0 +1,322 @@
+package validator
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/a-aslani/wotop/model/apperror"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordStrengthRule(t *testing.T) {
+
+	type request struct {
+		Password string `json:"password" validate:"required,password_strength:3"`
+	}
+
+	vld := New()
+	ok, err := vld.Validate(request{Password: "password1"})
+	require.NoError(t, err)
+	assert.False(t, ok)
+	require.Len(t, vld.Errors, 1)
+	assert.Equal(t, "ER0006", vld.Errors[0].(Message).Code)
+
+	vld = New()
+	ok, err = vld.Validate(request{Password: "c0rrect-Horse-b4ttery"})
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestValidationErrorStatus(t *testing.T) {
+	status, ok := apperror.HTTPStatus(ErrValidationError)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+type signupRequest struct {
+	Name     string `json:"name" validate:"required,min:3,max:20"`
+	Email    string `name:"email_address" validate:"required,email"`
+	Password string `json:"password" validate:"required,password_strength"`
+	Referrer string `json:"referrer"`
+	Bio      string `validate:"max:10"`
+}
+
+func TestValidateCachedRules(t *testing.T) {
+
+	tests := map[string]struct {
+		input    signupRequest
+		messages []Message
+	}{
+		"valid": {
+			input: signupRequest{Name: "Ali", Email: "ali@example.com", Password: "c0rrect-Horse-b4ttery"},
+		},
+		"invalid": {
+			input: signupRequest{Name: "Al", Email: "ali", Bio: "a long biography"},
+			messages: []Message{
+				{FieldName: "name", Code: "ER0008", Message: "the length of name must be 3 characters or longer. You entered 2 characters", Rule: "min", Param: "3", Value: "Al"},
+				{FieldName: "email_address", Code: "ER0004", Message: "email_address is not a valid email address", Rule: "email", Value: "ali"},
+				{FieldName: "password", Code: "ER0003", Message: "password is required", Rule: "required"},
+				{FieldName: "Bio", Code: "ER0005", Message: "the length of Bio must be 10 characters or fewer. You entered 16 characters", Rule: "max", Param: "10", Value: "a long biography"},
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			// the first validation compiles the rules of the type, the second one runs them from the cache
+			for range 2 {
+				vld := New()
+				ok, err := vld.Validate(&tt.input)
+				require.NoError(t, err)
+				assert.Equal(t, len(tt.messages) == 0, ok)
+
+				messages := make([]Message, len(vld.Errors))
+				for i, e := range vld.Errors {
+					messages[i] = e.(Message)
+				}
+				assert.Equal(t, len(tt.messages), len(messages))
+				if len(tt.messages) > 0 {
+					assert.Equal(t, tt.messages, messages)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateSensitiveFields(t *testing.T) {
+
+	type request struct {
+		Password string `json:"password" validate:"required,password_strength"`
+		PIN      string `json:"pin" validate:"digits" sensitive:"true"`
+		Age      int    `json:"age" validate:"min:18"`
+	}
+
+	vld := New()
+	ok, err := vld.Validate(request{Password: "password1", PIN: "12a4", Age: 16})
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, []any{
+		Message{FieldName: "password", Code: "ER0006", Message: "password is too weak, the password strength must be at least 3 of 4", Rule: "password_strength"},
+		Message{FieldName: "pin", Code: "ER0009", Message: "pin must contain only the digits 0-9", Rule: "digits"},
+		Message{FieldName: "age", Code: "ER0012", Message: "age must be 18 or greater", Rule: "min", Param: "18", Value: "16"},
+	}, vld.Errors)
+}
+
+func TestValidateInvalidRuleParameter(t *testing.T) {
+
+	type request struct {
+		Code string `json:"code" validate:"required,max:ten"`
+	}
+
+	// the parameter is only parsed when the rule is reached
+	for range 2 {
+		ok, err := New().Validate(request{})
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		_, err = New().Validate(request{Code: "c-1"})
+		assert.Error(t, err)
+	}
+}
+
+func TestValidateConcurrently(t *testing.T) {
+
+	type request struct {
+		Name  string `json:"name" validate:"required,min:3"`
+		Email string `json:"email" validate:"required,email"`
+	}
+
+	var wg sync.WaitGroup
+	for i := range 32 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			input := request{Name: "Ali", Email: "ali@example.com"}
+			if i%2 == 1 {
+				input.Email = "ali"
+			}
+
+			vld := New()
+			ok, err := vld.Validate(input)
+			assert.NoError(t, err)
+			assert.Equal(t, i%2 == 0, ok)
+		}()
+	}
+	wg.Wait()
+}
+
+// largeRequest is a request with 40 validated fields, like the requests of the larger handlers.
+type largeRequest struct {
+	F01, F02, F03, F04, F05, F06, F07, F08, F09, F10 string `validate:"required,min:1,max:64"`
+	F11, F12, F13, F14, F15, F16, F17, F18, F19, F20 string `validate:"required,min:1,max:64"`
+	F21, F22, F23, F24, F25, F26, F27, F28, F29, F30 string `validate:"required,max:255"`
+	E01, E02, E03, E04, E05, E06, E07, E08, E09, E10 string `validate:"required,email"`
+}
+
+func newLargeRequest() largeRequest {
+	var req largeRequest
+	val := reflect.ValueOf(&req).Elem()
+	for i := 0; i < val.NumField(); i++ {
+		if strings.HasPrefix(val.Type().Field(i).Name, "E") {
+			val.Field(i).SetString("user@example.com")
+			continue
+		}
+		val.Field(i).SetString("value")
+	}
+	return req
+}
+
+func BenchmarkValidate(b *testing.B) {
+	req := newLargeRequest()
+	b.ReportAllocs()
+	for b.Loop() {
+		if ok, err := New().Validate(req); !ok || err != nil {
+			b.Fatal(ok, err)
+		}
+	}
+}
+
+// BenchmarkValidateUncached measures the validation when the tags are read and parsed on
+// every validation, as they were before the rules were cached.
+func BenchmarkValidateUncached(b *testing.B) {
+	req := newLargeRequest()
+	b.ReportAllocs()
+	for b.Loop() {
+		rulesCache.Delete(reflect.TypeOf(req))
+		if ok, err := New().Validate(req); !ok || err != nil {
+			b.Fatal(ok, err)
+		}
+	}
+}
+
+// messageCodes returns the codes of the validation errors by field.
+func messageCodes(errs []any) map[string]string {
+	codes := make(map[string]string, len(errs))
+	for _, e := range errs {
+		codes[e.(Message).FieldName] = e.(Message).Code
+	}
+	return codes
+}
+
+func TestNumericStringRules(t *testing.T) {
+
+	type request struct {
+		OTP        string   `json:"otp" validate:"required,digits,len:6"`
+		NationalID string   `json:"national_id" validate:"digits,len:10"`
+		Quantity   string   `json:"quantity" validate:"numeric"`
+		Balance    string   `json:"balance" validate:"numeric:signed:decimal"`
+		Price      string   `json:"price" validate:"numeric:decimal"`
+		Delta      string   `json:"delta" validate:"numeric:signed"`
+		Nickname   string   `json:"nickname" validate:"len:4"`
+		Tags       []string `json:"tags" validate:"len:2"`
+		Codes      []string `json:"codes" validate:"required,len:3"`
+	}
+
+	valid := request{
+		OTP:        "004215",
+		NationalID: "0012345678",
+		Quantity:   "007",
+		Balance:    "-12.50",
+		Price:      "3.5",
+		Delta:      "+4",
+		Nickname:   "ñoño",
+		Tags:       []string{"a", "b"},
+		Codes:      []string{"x", "y", "z"},
+	}
+
+	tests := map[string]struct {
+		mutate func(r *request)
+		codes  map[string]string
+	}{
+		"valid": {
+			mutate: func(r *request) {},
+			codes:  map[string]string{},
+		},
+		"empty optional fields are skipped": {
+			mutate: func(r *request) { *r = request{OTP: r.OTP, Codes: r.Codes} },
+			codes:  map[string]string{},
+		},
+		"required fields are reported once": {
+			mutate: func(r *request) { r.OTP, r.Codes = "", nil },
+			codes:  map[string]string{"otp": "ER0003", "codes": "ER0003"},
+		},
+		"unicode digits": {
+			mutate: func(r *request) { r.OTP, r.NationalID = "۱۲۳۴۵۶", "١٢٣٤٥٦٧٨٩٠" },
+			codes:  map[string]string{"otp": "ER0009", "national_id": "ER0009"},
+		},
+		"exact lengths": {
+			mutate: func(r *request) { r.OTP, r.Nickname, r.Tags = "04215", "آرمان", []string{"a"} },
+			codes:  map[string]string{"otp": "ER0011", "nickname": "ER0011", "tags": "ER0011"},
+		},
+		"signs and decimals": {
+			mutate: func(r *request) {
+				r.Quantity, r.Price, r.Delta, r.Balance = "-7", "-3.5", "4.0", "12."
+			},
+			codes: map[string]string{"quantity": "ER0010", "price": "ER0010", "delta": "ER0010", "balance": "ER0010"},
+		},
+		"not a number": {
+			mutate: func(r *request) { r.Quantity, r.Balance = "1e3", "-" },
+			codes:  map[string]string{"quantity": "ER0010", "balance": "ER0010"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			input := valid
+			tt.mutate(&input)
+
+			vld := New()
+			ok, err := vld.Validate(input)
+			require.NoError(t, err)
+			assert.Equal(t, len(tt.codes) == 0, ok)
+			assert.Equal(t, tt.codes, messageCodes(vld.Errors))
+		})
+	}
+}
+
+func TestNumericStringRuleMessages(t *testing.T) {
+
+	type request struct {
+		OTP     string `json:"otp" validate:"digits,len:6"`
+		Balance string `json:"balance" validate:"numeric:signed:decimal"`
+	}
+
+	vld := New()
+	_, err := vld.Validate(request{OTP: "12a456", Balance: "ten"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []any{
+		Message{FieldName: "otp", Code: "ER0009", Message: "otp must contain only the digits 0-9", Rule: "digits", Value: "12a456"},
+		Message{FieldName: "balance", Code: "ER0010", Message: "balance must be a decimal number", Rule: "numeric", Param: "signed:decimal", Value: "ten"},
+	}, vld.Errors)
+
+	vld = New()
+	_, err = vld.Validate(request{OTP: "1234567"})
+	require.NoError(t, err)
+	assert.Equal(t, []any{
+		Message{FieldName: "otp", Code: "ER0011", Message: "the length of otp must be exactly 6. You entered 7", Rule: "len", Param: "6", Value: "1234567"},
+	}, vld.Errors)
+}
+
+func TestNumericStringRuleInvalidParameter(t *testing.T) {
+
+	type unknownForm struct {
+		Amount string `validate:"numeric:hex"`
+	}
+	type missingLength struct {
+		Code string `validate:"len"`
+	}
+	type notAString struct {
+		Count int `validate:"digits"`
+	}
+
+	for _, input := range []any{unknownForm{Amount: "1"}, missingLength{Code: "1"}, notAString{Count: 1}} {
+		_, err := New().Validate(input)
+		assert.Error(t, err, "%T", input)
+	}
+}
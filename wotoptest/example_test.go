@@ -0,0 +1,157 @@
+package wotoptest_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/jwt"
+	"github.com/a-aslani/wotop/logger"
+	"github.com/a-aslani/wotop/model/payload"
+	"github.com/a-aslani/wotop/wotoptest"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// nopLogger discards the messages of the controller.
+type nopLogger struct{}
+
+func (nopLogger) Info(context.Context, string, ...any)    {}
+func (nopLogger) Error(context.Context, string, ...any)   {}
+func (nopLogger) Warning(context.Context, string, ...any) {}
+
+// getProductRequest and getProductResponse are the messages of a scaffolded usecase.
+type getProductRequest struct {
+	ID string `uri:"id"`
+}
+
+type getProductResponse struct {
+	ID     string `json:"id"`
+	Tenant string `json:"tenant"`
+}
+
+// getProductInteractor answers with the product of the tenant of the caller.
+type getProductInteractor struct{}
+
+func (getProductInteractor) Execute(ctx context.Context, req getProductRequest) (*getProductResponse, error) {
+	identity, _ := wotop.IdentityFromContext(ctx)
+	return &getProductResponse{ID: req.ID, Tenant: identity.Tenant}, nil
+}
+
+// controller is shaped like a generated HTTP controller.
+type controller struct {
+	wotop.UsecaseRegisterer
+	log logger.Logger
+}
+
+// getProductHandler is shaped like a handler generated by `wotop handler`.
+func (r *controller) getProductHandler() gin.HandlerFunc {
+	inport := wotop.MustGetInport[getProductRequest, getProductResponse](r.GetUsecase(getProductRequest{}))
+
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		traceID, _ := wotop.TraceIDFromContext(ctx)
+
+		var req getProductRequest
+		if err := c.ShouldBindUri(&req); err != nil {
+			c.JSON(http.StatusBadRequest, payload.NewErrorResponse(err, traceID))
+			return
+		}
+
+		res, err := inport.Execute(ctx, req)
+		if err != nil {
+			payload.WriteError(c, err, traceID)
+			return
+		}
+
+		c.JSON(http.StatusOK, payload.NewSuccessResponse(res, traceID))
+	}
+}
+
+func TestControllerThroughTestServer(t *testing.T) {
+	token := wotoptest.NewFakeToken()
+
+	server := wotoptest.NewTestServer(func(r wotop.UsecaseRegisterer, router *gin.Engine) {
+		r.AddUsecase(getProductInteractor{})
+
+		ctrl := &controller{UsecaseRegisterer: r, log: nopLogger{}}
+		auth := jwt.NewGinMiddleware(ctrl.log).Authentication(token)
+		router.GET("/v1/products/:id", auth, ctrl.getProductHandler())
+	})
+	defer server.Close()
+
+	t.Run("authenticated", func(t *testing.T) {
+		accessToken := token.Mint(jwt.Claims{ID: "user-1", Role: "admin", Tenant: "acme"})
+
+		res, err := server.DoJSON(http.MethodGet, "/v1/products/p-1", nil, wotoptest.Bearer(accessToken))
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, res.Status)
+		assert.True(t, res.Success)
+		assert.NotEmpty(t, res.TraceID)
+		assert.Equal(t, map[string]any{"id": "p-1", "tenant": "acme"}, res.Data)
+	})
+
+	t.Run("without a token", func(t *testing.T) {
+		res, err := server.DoJSON(http.MethodGet, "/v1/products/p-1", nil, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusUnauthorized, res.Status)
+		assert.False(t, res.Success)
+		assert.Equal(t, jwt.ErrUnauthorized.Code(), res.ErrorCode)
+	})
+
+	t.Run("with a deleted token", func(t *testing.T) {
+		accessToken := token.Mint(jwt.Claims{ID: "user-1"})
+		require.NoError(t, token.DeleteToken(context.Background(), accessToken, ""))
+
+		res, err := server.DoJSON(http.MethodGet, "/v1/products/p-1", nil, wotoptest.Bearer(accessToken))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, res.Status)
+	})
+}
+
+func TestFakeTokenRejectsForeignTokens(t *testing.T) {
+	accessToken := wotoptest.NewFakeToken().Mint(jwt.Claims{ID: "user-1"})
+
+	_, _, err := wotoptest.NewFakeToken().VerifyToken(accessToken)
+	assert.ErrorIs(t, err, jwt.ErrUnauthorized)
+}
+
+func TestFakeTokenExpiry(t *testing.T) {
+	token := wotoptest.NewFakeToken()
+
+	expired := jwt.Claims{ID: "user-1", Csrf: "csrf"}
+	expired.ExpiresAt = 1
+	accessToken := token.Mint(expired)
+
+	_, _, err := token.VerifyToken(accessToken)
+	assert.ErrorIs(t, err, jwt.ErrExpiredToken)
+
+	renewed, _, _, _, userID, err := token.RenewToken(context.Background(), accessToken, "", "csrf")
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", userID)
+
+	_, claims, err := token.VerifyToken(renewed)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.ID)
+}
+
+func ExampleTestServer_DoJSON() {
+	server := wotoptest.NewTestServer(func(r wotop.UsecaseRegisterer, router *gin.Engine) {
+		router.GET("/ping", func(c *gin.Context) {
+			c.JSON(http.StatusOK, payload.NewSuccessResponse("pong", "trace-1"))
+		})
+	})
+	defer server.Close()
+
+	res, err := server.DoJSON(http.MethodGet, "/ping", nil, nil)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(res.Status, res.Success, res.Data)
+	// Output: 200 true pong
+}
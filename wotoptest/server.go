@@ -0,0 +1,123 @@
+// Package wotoptest provides utilities to test the controllers of a wotop application
+// end-to-end, without booting the real server.
+package wotoptest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/a-aslani/wotop"
+	"github.com/a-aslani/wotop/model/payload"
+	"github.com/gin-gonic/gin"
+)
+
+// TestServer is an httptest.Server serving the routes of a controller.
+//
+// Fields:
+//   - Server: The running HTTP server, it must be closed with Close.
+//   - Registerer: The registerer the use cases of the controller are added to.
+//   - Router: The Gin router of the controller.
+type TestServer struct {
+	*httptest.Server
+	Registerer wotop.UsecaseRegisterer
+	Router     *gin.Engine
+}
+
+// NewTestServer starts a server with the routes and use cases set up by register, the
+// way a generated controller sets them up in RegisterRouter.
+//
+// Parameters:
+//   - register: Adds the use cases to the registerer and the routes to the router.
+//
+// Returns:
+//   - The running TestServer, the caller must close it.
+func NewTestServer(register func(r wotop.UsecaseRegisterer, router *gin.Engine)) *TestServer {
+	gin.SetMode(gin.TestMode)
+
+	s := &TestServer{
+		Registerer: wotop.NewBaseController(),
+		Router:     gin.New(),
+	}
+	register(s.Registerer, s.Router)
+
+	s.Server = httptest.NewServer(s.Router)
+	return s
+}
+
+// Do sends a request with a JSON body to the server.
+//
+// Parameters:
+//   - method: The HTTP method of the request.
+//   - path: The path of the request, with its query.
+//   - body: The body, marshaled to JSON when it is not nil.
+//   - headers: The headers of the request, it may be nil.
+//
+// Returns:
+//   - The response, the caller must close its body.
+//   - An error if the request cannot be sent.
+func (s *TestServer) Do(method, path string, body any, headers map[string]string) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, s.URL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	return s.Client().Do(req)
+}
+
+// DoJSON sends a request with a JSON body to the server and decodes the response envelope.
+//
+// Parameters:
+//   - method: The HTTP method of the request.
+//   - path: The path of the request, with its query.
+//   - body: The body, marshaled to JSON when it is not nil.
+//   - headers: The headers of the request, it may be nil.
+//
+// Returns:
+//   - The decoded payload.Response, its Status is set to the status code of the response.
+//   - An error if the request cannot be sent or the response is not a JSON envelope.
+func (s *TestServer) DoJSON(method, path string, body any, headers map[string]string) (payload.Response, error) {
+	resp, err := s.Do(method, path, body, headers)
+	if err != nil {
+		return payload.Response{}, err
+	}
+	defer resp.Body.Close()
+
+	var res payload.Response
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return payload.Response{}, fmt.Errorf("wotoptest: %s %s answered %d with no JSON envelope: %w", method, path, resp.StatusCode, err)
+	}
+	res.Status = resp.StatusCode
+
+	return res, nil
+}
+
+// Bearer returns the headers authenticating a request with an access token, e.g. one
+// minted by FakeToken.
+//
+// Parameters:
+//   - accessToken: The access token.
+//
+// Returns:
+//   - The headers to pass to Do or DoJSON.
+func Bearer(accessToken string) map[string]string {
+	return map[string]string{"Authorization": "Bearer " + accessToken}
+}
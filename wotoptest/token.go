@@ -0,0 +1,227 @@
+package wotoptest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/a-aslani/wotop/jwt"
+	"github.com/a-aslani/wotop/util"
+	gojwt "github.com/golang-jwt/jwt"
+)
+
+// FakeToken is a jwt.Token minting signed tokens for arbitrary claims, without storage,
+// so routes protected by jwt.GinMiddleware can be tested. It is safe for concurrent use.
+type FakeToken struct {
+	secret   []byte
+	validFor time.Duration
+
+	mu      sync.Mutex
+	blocked map[string]bool
+	actions map[string]bool // the IDs of the action tokens not consumed yet
+}
+
+var _ jwt.Token = (*FakeToken)(nil)
+
+// NewFakeToken creates a FakeToken signing its tokens with a random key, valid for an hour.
+//
+// Returns:
+//   - A new FakeToken.
+func NewFakeToken() *FakeToken {
+	return &FakeToken{
+		secret:   []byte(util.GenerateKey(32)),
+		validFor: time.Hour,
+		blocked:  map[string]bool{},
+		actions:  map[string]bool{},
+	}
+}
+
+// Mint signs an access token carrying the given claims. An hour of validity is given to
+// claims without expiry.
+//
+// Parameters:
+//   - claims: The claims of the token, such as the ID, role and tenant of the caller.
+//
+// Returns:
+//   - The signed access token.
+func (f *FakeToken) Mint(claims jwt.Claims) string {
+	if claims.ExpiresAt == 0 {
+		claims.ExpiresAt = time.Now().Add(f.validFor).Unix()
+	}
+
+	signed, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, &claims).SignedString(f.secret)
+	if err != nil {
+		// signing with an HMAC key cannot fail
+		panic(err)
+	}
+	return signed
+}
+
+// GenerateToken mints an access token for the user, the refresh token is an access token too.
+func (f *FakeToken) GenerateToken(_ context.Context, userId string, role string, sub string, tenant string) (accessToken, refreshToken, csrfSecret string, expiresAt int64, err error) {
+	csrfSecret = util.GenerateKey(32)
+	expiresAt = time.Now().Add(f.validFor).Unix()
+
+	claims := jwt.Claims{ID: userId, Role: role, Tenant: tenant, Csrf: csrfSecret}
+	claims.Subject = sub
+	claims.ExpiresAt = expiresAt
+
+	accessToken = f.Mint(claims)
+	refreshToken = accessToken
+	return
+}
+
+// GenerateCentrifugoJWT signs a Centrifugo token for the user with the given key.
+func (f *FakeToken) GenerateCentrifugoJWT(userId string, secretKey string, capsObj map[string]interface{}) (string, error) {
+	claims := gojwt.MapClaims{"sub": userId, "exp": time.Now().Add(f.validFor).Unix()}
+	if capsObj != nil {
+		claims["caps"] = capsObj
+	}
+	return gojwt.NewWithClaims(gojwt.SigningMethodHS256, claims).SignedString([]byte(secretKey))
+}
+
+// RenewToken mints a new access token with the claims of the old one, which may be expired.
+func (f *FakeToken) RenewToken(_ context.Context, oldAccessTokenString string, _ string, oldCsrfSecret string) (newAccessToken, newRefreshToken, newCsrfSecret string, expiresAt int64, userId string, err error) {
+	claims, err := f.parse(oldAccessTokenString, false)
+	if err != nil {
+		return
+	}
+	if !util.SecureCompare(oldCsrfSecret, claims.Csrf) {
+		err = jwt.ErrUnauthorized
+		return
+	}
+
+	claims.ExpiresAt = 0
+	newAccessToken = f.Mint(*claims)
+	newRefreshToken = newAccessToken
+	newCsrfSecret = claims.Csrf
+	expiresAt = time.Now().Add(f.validFor).Unix()
+	userId = claims.ID
+	return
+}
+
+// DeleteToken blocks the access token, VerifyToken rejects it afterwards.
+func (f *FakeToken) DeleteToken(_ context.Context, accessToken, _ string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.blocked[accessToken] = true
+	return nil
+}
+
+// VerifyToken checks that the token was minted by this FakeToken, is not expired and not deleted.
+func (f *FakeToken) VerifyToken(token string) (string, *jwt.Claims, error) {
+	claims, err := f.parse(token, true)
+	if err != nil {
+		return token, nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.blocked[token] {
+		return token, nil, jwt.ErrUnauthorized
+	}
+
+	return token, claims, nil
+}
+
+// VerifyTokenAt checks that the token was minted by this FakeToken, is not expired at the
+// given time and not deleted.
+func (f *FakeToken) VerifyTokenAt(token string, at time.Time) (string, *jwt.Claims, error) {
+	claims, err := f.parse(token, false)
+	if err != nil {
+		return token, nil, err
+	}
+	if !claims.VerifyExpiresAt(at.Unix(), false) {
+		return token, nil, jwt.ErrExpiredToken
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.blocked[token] {
+		return token, nil, jwt.ErrUnauthorized
+	}
+
+	return token, claims, nil
+}
+
+// ValidateForConnectionRefresh keeps the access token while it is valid for
+// jwt.ConnectionRefreshMargin more, else renews it with RenewToken.
+func (f *FakeToken) ValidateForConnectionRefresh(ctx context.Context, accessToken, refreshToken, csrf string) (*jwt.ConnectionRefresh, error) {
+	_, claims, err := f.VerifyTokenAt(accessToken, time.Now().Add(jwt.ConnectionRefreshMargin))
+	if err == nil {
+		return &jwt.ConnectionRefresh{AccessToken: accessToken, RefreshToken: refreshToken, CsrfSecret: csrf, UserID: claims.ID, ExpiresAt: time.Unix(claims.ExpiresAt, 0)}, nil
+	}
+	if err != jwt.ErrExpiredToken || refreshToken == "" {
+		return nil, err
+	}
+
+	newAccessToken, newRefreshToken, newCsrf, expiresAt, userID, err := f.RenewToken(ctx, accessToken, refreshToken, csrf)
+	if err != nil {
+		return nil, err
+	}
+	return &jwt.ConnectionRefresh{AccessToken: newAccessToken, RefreshToken: newRefreshToken, CsrfSecret: newCsrf, UserID: userID, ExpiresAt: time.Unix(expiresAt, 0), Renewed: true}, nil
+}
+
+// parse verifies the signature of a token minted by Mint and returns its claims.
+func (f *FakeToken) parse(token string, checkExpiry bool) (*jwt.Claims, error) {
+	parser := &gojwt.Parser{ValidMethods: []string{gojwt.SigningMethodHS256.Alg()}, SkipClaimsValidation: !checkExpiry}
+
+	var claims jwt.Claims
+	_, err := parser.ParseWithClaims(token, &claims, func(*gojwt.Token) (interface{}, error) {
+		return f.secret, nil
+	})
+	if err != nil {
+		if ve, ok := err.(*gojwt.ValidationError); ok && ve.Errors&gojwt.ValidationErrorExpired != 0 {
+			return nil, jwt.ErrExpiredToken
+		}
+		return nil, jwt.ErrUnauthorized
+	}
+
+	return &claims, nil
+}
+
+// GenerateActionToken signs a single-use action token for the subject.
+func (f *FakeToken) GenerateActionToken(_ context.Context, sub, tenant, action string, ttl time.Duration, payload map[string]any) (string, error) {
+	claims := jwt.ActionClaims{Action: action, Tenant: tenant, Payload: payload}
+	claims.Id = util.GenerateKey(16)
+	claims.Subject = sub
+	claims.ExpiresAt = time.Now().Add(ttl).Unix()
+
+	signed, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, &claims).SignedString(f.secret)
+	if err != nil {
+		return "", err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.actions[claims.Id] = true
+	return signed, nil
+}
+
+// ConsumeActionToken checks that the action token was generated by this FakeToken for the
+// action, is not expired and not consumed yet, and consumes it.
+func (f *FakeToken) ConsumeActionToken(_ context.Context, token, expectedAction string) (*jwt.ActionClaims, error) {
+	parser := &gojwt.Parser{ValidMethods: []string{gojwt.SigningMethodHS256.Alg()}}
+
+	var claims jwt.ActionClaims
+	_, err := parser.ParseWithClaims(token, &claims, func(*gojwt.Token) (interface{}, error) {
+		return f.secret, nil
+	})
+	if err != nil {
+		if ve, ok := err.(*gojwt.ValidationError); ok && ve.Errors&gojwt.ValidationErrorExpired != 0 {
+			return nil, jwt.ErrActionTokenExpired
+		}
+		return nil, jwt.ErrUnauthorized
+	}
+	if claims.Action != expectedAction {
+		return nil, jwt.ErrWrongAction.Var(expectedAction)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.actions[claims.Id] {
+		return nil, jwt.ErrActionTokenUsed
+	}
+	delete(f.actions, claims.Id)
+	return &claims, nil
+}